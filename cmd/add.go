@@ -1,30 +1,65 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/unkn0wn-root/git-go/internal/commands/add"
-	"github.com/unkn0wn-root/git-go/internal/core/discovery"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/commands/diff"
 )
 
+var addPatch bool
+
 var addCmd = &cobra.Command{
 	Use:   "add <pathspec>...",
 	Short: "Add file contents to the index",
 	Long:  "Add file contents to the index (staging area)",
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := discovery.FindRepositoryFromCwd()
+		repo, err := openWorkTree()
 		if err != nil {
-			return fmt.Errorf("not a git repository (or any of the parent directories)")
+			return err
+		}
+		if args, err = repoPaths(repo, args); err != nil {
+			return err
+		}
+		if !addPatch {
+			return add.AddFiles(repo, args)
 		}
 
-		repo := repository.New(workDir)
-		return add.AddFiles(repo, args)
+		input := bufio.NewReader(os.Stdin)
+		quit := false
+		for _, path := range args {
+			err := add.AddPatch(repo, path, func(hunk diff.DiffHunk) bool {
+				if quit {
+					return false
+				}
+				fd := &diff.FileDiff{OldPath: path, NewPath: path, Hunks: []diff.DiffHunk{hunk}}
+				fmt.Print(fd.String())
+				fmt.Print("Stage this hunk [y,n,q]? ")
+
+				answer, _ := input.ReadString('\n')
+				switch strings.TrimSpace(answer) {
+				case "y":
+					return true
+				case "q":
+					quit = true
+				}
+				return false
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	},
 }
 
 func init() {
+	addCmd.Flags().BoolVarP(&addPatch, "patch", "p", false, "choose hunks of each file to stage interactively")
+
 	rootCmd.AddCommand(addCmd)
 }
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/archive"
+)
+
+var (
+	archiveFormat string
+	archivePrefix string
+	archiveOutput string
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive [--format=<fmt>] [--prefix=<prefix>] [-o <file>] <tree-ish>",
+	Short: "Create an archive of files from a named tree",
+	Long: `Creates a tar, tar.gz or zip archive of the tree named by <tree-ish> and writes it
+to standard output or to the file given with -o. Without --format the format is taken
+from the output file's extension, and is tar otherwise.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		format := archiveFormat
+		if format == "" {
+			format = formatFromName(archiveOutput)
+		}
+
+		options := archive.ArchiveOptions{Prefix: archivePrefix}
+		if archiveOutput == "" {
+			if err := archive.ArchiveWithOptions(repo, args[0], format, os.Stdout, options); err != nil {
+				return fmt.Errorf("archive failed: %w", err)
+			}
+			return nil
+		}
+
+		out, err := os.Create(archiveOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", archiveOutput, err)
+		}
+		if err := archive.ArchiveWithOptions(repo, args[0], format, out, options); err != nil {
+			out.Close()
+			return fmt.Errorf("archive failed: %w", err)
+		}
+		if err := out.Sync(); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to write %s: %w", archiveOutput, err)
+		}
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", archiveOutput, err)
+		}
+		return nil
+	},
+}
+
+// formatFromName picks the archive format matching a file name's extension
+func formatFromName(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return archive.FormatZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archive.FormatTarGz
+	default:
+		return archive.FormatTar
+	}
+}
+
+func init() {
+	archiveCmd.Flags().StringVar(&archiveFormat, "format", "", "archive format: tar, tar.gz, tgz or zip")
+	archiveCmd.Flags().StringVar(&archivePrefix, "prefix", "", "prepend <prefix>/ to each path in the archive")
+	archiveCmd.Flags().StringVarP(&archiveOutput, "output", "o", "", "write the archive to <file> instead of standard output")
+
+	rootCmd.AddCommand(archiveCmd)
+}
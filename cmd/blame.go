@@ -7,33 +7,52 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/unkn0wn-root/git-go/internal/commands/blame"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/mailmap"
+)
+
+var (
+	blameLineRange string
+	blameFollow    bool
 )
 
 var blameCmd = &cobra.Command{
-	Use:   "blame <file>",
+	Use:   "blame [<rev>] <file>",
 	Short: "Show what revision and author last modified each line of a file",
 	Long:  "Annotate each line in the given file with information about the last commit that modified the line",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+			return err
 		}
+		repo.WithCache(objectCacheSize)
 
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+		options := blame.BlameOptions{Follow: blameFollow}
+		if options.Mailmap, err = mailmap.Load(repo); err != nil {
+			return err
+		}
+		paths, err := repoPaths(repo, args[len(args)-1:])
+		if err != nil {
+			return err
+		}
+		filePath := paths[0]
+		if len(args) == 2 {
+			options.Rev = args[0]
+		} else {
+			fullPath := filepath.Join(repo.WorkDir, filePath)
+			if _, err := os.Stat(fullPath); err != nil {
+				return fmt.Errorf("file does not exist: %s", filePath)
+			}
 		}
 
-		filePath := args[0]
-
-		fullPath := filepath.Join(workDir, filePath)
-		if _, err := os.Stat(fullPath); err != nil {
-			return fmt.Errorf("file does not exist: %s", filePath)
+		if blameLineRange != "" {
+			options.StartLine, options.EndLine, err = blame.ParseLineRange(blameLineRange)
+			if err != nil {
+				return err
+			}
 		}
 
-		result, err := blame.BlameFile(repo, filePath, "")
+		result, err := blame.BlameFile(repo, filePath, options)
 		if err != nil {
 			return fmt.Errorf("failed to blame file: %w", err)
 		}
@@ -44,5 +63,7 @@ var blameCmd = &cobra.Command{
 }
 
 func init() {
+	blameCmd.Flags().StringVarP(&blameLineRange, "lines", "L", "", "annotate only the line range start,end")
+	blameCmd.Flags().BoolVar(&blameFollow, "follow", false, "follow the file through renames")
 	rootCmd.AddCommand(blameCmd)
 }
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/branch"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var (
+	branchDelete      bool
+	branchForceDelete bool
+	branchMove        bool
+	branchVerbose     bool
+)
+
+var branchCmd = &cobra.Command{
+	Use:   "branch [<branchname> [<start-point>]]",
+	Short: "List, create, or delete branches",
+	Long: `List, create, rename, or delete branches.
+
+With no arguments, lists local branches.
+  -d <name>          Delete a fully merged branch
+  -D <name>          Delete a branch regardless of merge status
+  -m <old> <new>     Rename a branch`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case branchDelete || branchForceDelete:
+			if len(args) != 1 {
+				return fmt.Errorf("branch name required")
+			}
+			if err := branch.Delete(repo, args[0], branchForceDelete); err != nil {
+				return err
+			}
+			fmt.Printf("%s Deleted branch %s\n", display.Success("✓"), display.Branch(args[0]))
+
+		case branchMove:
+			if len(args) != 2 {
+				return fmt.Errorf("usage: branch -m <old> <new>")
+			}
+			if err := branch.Rename(repo, args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("%s Renamed branch %s to %s\n", display.Success("✓"), display.Branch(args[0]), display.Branch(args[1]))
+
+		case len(args) > 0:
+			startPoint := ""
+			if len(args) == 2 {
+				startPoint = args[1]
+			}
+			if err := branch.Create(repo, args[0], startPoint); err != nil {
+				return err
+			}
+
+		default:
+			branches, err := branch.List(repo)
+			if err != nil {
+				return err
+			}
+			printBranchList(branches)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	branchCmd.Flags().BoolVarP(&branchDelete, "delete", "d", false, "delete a fully merged branch")
+	branchCmd.Flags().BoolVarP(&branchForceDelete, "force-delete", "D", false, "delete a branch irrespective of its merged status")
+	branchCmd.Flags().BoolVarP(&branchMove, "move", "m", false, "rename a branch")
+	branchCmd.Flags().BoolVarP(&branchVerbose, "verbose", "v", false, "show hash and upstream for each branch")
+
+	rootCmd.AddCommand(branchCmd)
+}
+
+func printBranchList(branches []branch.BranchInfo) {
+	if !branchVerbose {
+		names := make([]string, 0, len(branches))
+		current := ""
+		for _, b := range branches {
+			names = append(names, b.Name)
+			if b.IsCurrent {
+				current = b.Name
+			}
+		}
+		fmt.Print(display.FormatBranchList(names, current))
+		return
+	}
+
+	for _, b := range branches {
+		marker := "  "
+		if b.IsCurrent {
+			marker = display.Success("* ")
+		}
+
+		upstream := ""
+		if b.Upstream != "" {
+			upstream = " " + display.Secondary("["+b.Upstream+"]")
+		}
+
+		fmt.Printf("%s%s %s%s\n", marker, display.Branch(b.Name), display.Hash(b.Hash), upstream)
+	}
+}
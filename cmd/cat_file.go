@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/catfile"
+)
+
+var (
+	catFileType   bool
+	catFileSize   bool
+	catFilePretty bool
+)
+
+var catFileCmd = &cobra.Command{
+	Use:   "cat-file (-t | -s | -p) <object>",
+	Short: "Provide content, type or size of repository objects",
+	Long: `Show the type (-t), size (-s) or content (-p) of an object. Trees are
+listed one entry per line; blobs, commits and tags are printed as stored.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var mode catfile.CatMode
+		switch {
+		case catFileType && !catFileSize && !catFilePretty:
+			mode = catfile.CatType
+		case catFileSize && !catFileType && !catFilePretty:
+			mode = catfile.CatSize
+		case catFilePretty && !catFileType && !catFileSize:
+			mode = catfile.CatPretty
+		default:
+			return fmt.Errorf("exactly one of -t, -s or -p is required")
+		}
+
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		out, err := catfile.Show(repo, args[0], mode)
+		if err != nil {
+			return fmt.Errorf("cat-file failed: %w", err)
+		}
+
+		fmt.Print(out)
+		return nil
+	},
+}
+
+func init() {
+	catFileCmd.Flags().BoolVarP(&catFileType, "type", "t", false, "show the object type")
+	catFileCmd.Flags().BoolVarP(&catFileSize, "size", "s", false, "show the object size")
+	catFileCmd.Flags().BoolVarP(&catFilePretty, "pretty", "p", false, "pretty-print the object content")
+
+	rootCmd.AddCommand(catFileCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/branch"
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var checkoutNewBranch bool
+
+var checkoutCmd = &cobra.Command{
+	Use:   "checkout [-b] <branch|commit>",
+	Short: "Switch branches",
+	Long: `Switch branches, updating the index and working tree.
+
+Only files that differ between the current and target commits are rewritten.
+The checkout is refused if local changes would be overwritten.
+Given a commit instead of a branch name, HEAD is detached at that commit.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+
+		if checkoutNewBranch {
+			if err := branch.Create(repo, name, ""); err != nil {
+				return err
+			}
+		}
+
+		result, err := checkout.SwitchBranch(repo, name)
+		if err != nil {
+			return err
+		}
+
+		if result.Detached {
+			fmt.Printf("%s HEAD is now at %s\n", display.Warning("!"), display.Hash(result.Commit))
+		} else if checkoutNewBranch {
+			fmt.Printf("%s Switched to a new branch '%s'\n", display.Success("✓"), display.Branch(result.Branch))
+		} else {
+			fmt.Printf("%s Switched to branch '%s'\n", display.Success("✓"), display.Branch(result.Branch))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	checkoutCmd.Flags().BoolVarP(&checkoutNewBranch, "branch", "b", false, "create a new branch and switch to it")
+
+	rootCmd.AddCommand(checkoutCmd)
+}
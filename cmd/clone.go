@@ -8,6 +8,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/unkn0wn-root/git-go/internal/commands/clone"
+	"github.com/unkn0wn-root/git-go/internal/transport/remote"
 	"github.com/unkn0wn-root/git-go/pkg/display"
 )
 
@@ -21,6 +22,8 @@ var (
 	cloneSingleBranch bool
 	cloneProgress     bool
 	cloneTimeout      time.Duration
+	cloneResumeDir    string
+	cloneNoTags       bool
 )
 
 var cloneCmd = &cobra.Command{
@@ -49,12 +52,19 @@ that is forked from the cloned repository's currently active branch.`,
 		options.SingleBranch = cloneSingleBranch
 		options.Progress = cloneProgress
 		options.Timeout = cloneTimeout
+		options.ResumeDir = cloneResumeDir
+		if cloneNoTags {
+			options.Tags = remote.TagNone
+		}
 
 		if options.Progress {
 			options.ProgressWriter = os.Stdout
 		}
 
 		cloner := clone.NewCloner()
+		if options.Progress {
+			cloner.WithProgress(newProgressPrinter())
+		}
 		ctx := context.Background()
 
 		if options.Progress {
@@ -80,7 +90,7 @@ func printCloneResult(result *clone.CloneResult, options clone.CloneOptions) {
 		fmt.Printf("%s Switched to branch %s\n", display.Success("✓"), display.Branch(result.DefaultBranch))
 	}
 
-	if len(result.FetchedRefs) > 0 {
+	if result.CheckedOut && len(result.FetchedRefs) > 0 {
 		branchCount := 0
 		for ref := range result.FetchedRefs {
 			if len(ref) > 11 && ref[:11] == "refs/heads/" {
@@ -96,6 +106,10 @@ func printCloneResult(result *clone.CloneResult, options clone.CloneOptions) {
 	if result.ObjectCount > 0 {
 		fmt.Printf("%s Received %s objects\n", display.Success("✓"), display.Emphasis(fmt.Sprintf("%d", result.ObjectCount)))
 	}
+
+	if result.CheckedOutFiles > 0 {
+		fmt.Printf("%s Checked out %s files\n", display.Success("✓"), display.Emphasis(fmt.Sprintf("%d", result.CheckedOutFiles)))
+	}
 }
 
 func init() {
@@ -107,7 +121,9 @@ func init() {
 	cloneCmd.Flags().BoolVar(&cloneShallow, "shallow-since", false, "create a shallow clone since a given time")
 	cloneCmd.Flags().BoolVar(&cloneSingleBranch, "single-branch", false, "clone only one branch")
 	cloneCmd.Flags().BoolVar(&cloneProgress, "progress", true, "show progress")
+	cloneCmd.Flags().BoolVar(&cloneNoTags, "no-tags", false, "do not clone any tags")
 	cloneCmd.Flags().DurationVar(&cloneTimeout, "timeout", 10*time.Minute, "timeout for clone operation")
+	cloneCmd.Flags().StringVar(&cloneResumeDir, "resume-dir", "", "keep the received pack in this directory so a retried clone resumes it")
 
 	rootCmd.AddCommand(cloneCmd)
 }
@@ -2,11 +2,9 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/unkn0wn-root/git-go/internal/commands/commit"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
 	"github.com/unkn0wn-root/git-go/pkg/display"
 )
 
@@ -14,6 +12,11 @@ var (
 	commitMessage string
 	authorName    string
 	authorEmail   string
+	noVerify      bool
+	signOff       bool
+	signKey       string
+	allowEmpty    bool
+	amend         bool
 )
 
 var commitCmd = &cobra.Command{
@@ -21,47 +24,32 @@ var commitCmd = &cobra.Command{
 	Short: "Record changes to the repository",
 	Long:  "Create a new commit with the changes in the index",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openWorkTree()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+			return err
 		}
 
-		repo := repository.New(workDir)
-
 		opts := commit.CommitOptions{
 			Message:     commitMessage,
 			AuthorName:  authorName,
 			AuthorEmail: authorEmail,
+			SkipHooks:   noVerify,
+			SignOff:     signOff,
+			SignKey:     signKey,
+			AllowEmpty:  allowEmpty,
+			Amend:       amend,
 		}
 
-		commitHash, err := commit.CreateCommit(repo, opts)
+		result, err := commit.CreateCommit(repo, opts)
 		if err != nil {
 			return err
 		}
 
-		branch, err := repo.GetCurrentBranch()
-		if err != nil {
-			branch = "main"
-		}
-
-		parents := []string{}
-		parentHash, _ := repo.GetHead()
-		if parentHash != "" {
-			parents = append(parents, parentHash)
-		}
-
-		if len(parents) == 0 {
-			fmt.Printf("[%s %s %s] %s\n",
-				display.Branch(branch),
-				display.Secondary("(root-commit)"),
-				display.Hash(commitHash),
-				commitMessage)
-		} else {
-			fmt.Printf("[%s %s] %s\n",
-				display.Branch(branch),
-				display.Hash(commitHash),
-				commitMessage)
+		branch := result.Branch
+		if branch == "" {
+			branch = "detached HEAD"
 		}
+		fmt.Print(display.FormatCommitResult(result.Hash, branch, result.Message, result.Root, result.FilesChanged, result.Insertions, result.Deletions))
 
 		return nil
 	},
@@ -71,7 +59,11 @@ func init() {
 	commitCmd.Flags().StringVarP(&commitMessage, "message", "m", "", "commit message")
 	commitCmd.Flags().StringVar(&authorName, "author-name", "", "author name")
 	commitCmd.Flags().StringVar(&authorEmail, "author-email", "", "author email")
-	commitCmd.MarkFlagRequired("message")
+	commitCmd.Flags().BoolVarP(&noVerify, "no-verify", "n", false, "bypass the pre-commit and commit-msg hooks")
+	commitCmd.Flags().BoolVarP(&signOff, "signoff", "s", false, "add a Signed-off-by trailer for the committer")
+	commitCmd.Flags().StringVarP(&signKey, "gpg-sign", "S", "", "sign the commit with an SSH key file or GPG key ID")
+	commitCmd.Flags().BoolVar(&allowEmpty, "allow-empty", false, "allow recording a commit with no changes")
+	commitCmd.Flags().BoolVar(&amend, "amend", false, "replace the tip of the current branch, reusing its message unless -m is given")
 
 	rootCmd.AddCommand(commitCmd)
 }
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/core/commitgraph"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var commitGraphCmd = &cobra.Command{
+	Use:   "commit-graph",
+	Short: "Write the commit-graph file",
+	Long:  "Manage the commit-graph file, which lets merge-base, log ranges and rev-list walk history without parsing commit objects.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var commitGraphWriteCmd = &cobra.Command{
+	Use:   "write",
+	Short: "Write a commit-graph of every commit in the repository",
+	Long:  "Write objects/info/commit-graph covering every commit in the object database, replacing any earlier graph. Commits made afterwards are still found, only without the speedup, until the graph is written again.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		count, err := commitgraph.Write(repo)
+		if err != nil {
+			return fmt.Errorf("commit-graph write failed: %w", err)
+		}
+
+		fmt.Printf("%s Wrote commit-graph with %d commits\n", display.Success("✓"), count)
+		return nil
+	},
+}
+
+func init() {
+	commitGraphCmd.AddCommand(commitGraphWriteCmd)
+	rootCmd.AddCommand(commitGraphCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/countobjects"
+)
+
+var countObjectsVerbose bool
+
+var countObjectsCmd = &cobra.Command{
+	Use:   "count-objects [-v]",
+	Short: "Count unpacked objects and their disk consumption",
+	Long: `Reports the number of loose objects and the space they take. With -v, also
+reports the packed objects and packs, loose objects that are already packed and
+could be pruned, and garbage files in the object database. A large loose count
+is a sign that gc is due.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		stats, err := countobjects.Count(repo)
+		if err != nil {
+			return fmt.Errorf("count-objects failed: %w", err)
+		}
+
+		if countObjectsVerbose {
+			for _, path := range stats.GarbagePaths {
+				fmt.Fprintf(os.Stderr, "warning: garbage found: %s\n", path)
+			}
+		}
+		fmt.Print(stats.String(countObjectsVerbose))
+		return nil
+	},
+}
+
+func init() {
+	countObjectsCmd.Flags().BoolVarP(&countObjectsVerbose, "verbose", "v", false, "report packs, prunable objects and garbage too")
+	rootCmd.AddCommand(countObjectsCmd)
+}
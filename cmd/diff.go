@@ -2,16 +2,17 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/unkn0wn-root/git-go/internal/commands/diff"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
 )
 
 var (
-	cached bool
-	staged bool
+	cached           bool
+	staged           bool
+	stat             bool
+	unified          int
+	includeUntracked bool
 )
 
 var diffCmd = &cobra.Command{
@@ -19,27 +20,38 @@ var diffCmd = &cobra.Command{
 	Short: "Show changes between commits, commit and working tree, etc",
 	Long:  "Show differences between the working directory and the index, or between commits",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+			return err
+		}
+		if args, err = repoPaths(repo, args); err != nil {
+			return err
 		}
 
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+		options := diff.DiffOptions{
+			ContextLines:     unified,
+			UnifiedZero:      unified == 0,
+			Stat:             stat,
+			IncludeUntracked: includeUntracked,
 		}
 
 		if cached || staged {
-			return diff.ShowStagedDiff(repo, args)
+			return diff.ShowStagedDiff(repo, args, options)
 		}
 
-		return diff.ShowWorkingTreeDiff(repo, args)
+		if repo.IsBare() {
+			return fmt.Errorf("this operation must be run in a work tree")
+		}
+		return diff.ShowWorkingTreeDiff(repo, args, options)
 	},
 }
 
 func init() {
 	diffCmd.Flags().BoolVar(&cached, "cached", false, "show diff between index and HEAD")
 	diffCmd.Flags().BoolVar(&staged, "staged", false, "show diff between index and HEAD (same as --cached)")
+	diffCmd.Flags().IntVarP(&unified, "unified", "U", 3, "generate diffs with <n> lines of context")
+	diffCmd.Flags().BoolVar(&stat, "stat", false, "show a per-file summary of changed lines instead of the patch")
+	diffCmd.Flags().BoolVar(&includeUntracked, "include-untracked", false, "show untracked files as added")
 
 	rootCmd.AddCommand(diffCmd)
 }
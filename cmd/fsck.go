@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/fsck"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Verify the connectivity and validity of objects",
+	Long: `Verify that every object hashes to its name, parses, and that every
+object it references exists. Commits no ref, reflog entry or other commit
+points at are reported as dangling.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		report, err := fsck.Check(repo)
+		if err != nil {
+			return fmt.Errorf("fsck failed: %w", err)
+		}
+
+		for _, h := range report.HashMismatches {
+			fmt.Printf("%s %s\n", display.Error("hash mismatch"), h)
+		}
+		for _, h := range report.Corrupt {
+			fmt.Printf("%s %s\n", display.Error("corrupt object"), h)
+		}
+		for _, missing := range report.Missing {
+			fmt.Printf("%s %s %s\n", display.Error("missing object"), missing.Hash,
+				display.Secondary("(referenced by "+missing.ReferencedBy+")"))
+		}
+		for _, h := range report.Dangling {
+			fmt.Printf("%s %s\n", display.Warning("dangling commit"), h)
+		}
+
+		if !report.OK() {
+			return fmt.Errorf("object store has errors (%d objects checked)", report.ObjectsChecked)
+		}
+
+		fmt.Printf("%s Checked %d objects\n", display.Success("✓"), report.ObjectsChecked)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fsckCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/gc"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var (
+	gcPrune   string
+	gcNoPrune bool
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Pack loose objects and prune unreachable ones",
+	Long: `Pack every reachable loose object into a new pack and remove the loose copies.
+
+Unreachable loose objects older than the grace period (two weeks by default)
+are pruned. Objects referenced from a reflog are kept unless --prune=now.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		options := gc.DefaultGCOptions()
+		switch {
+		case gcNoPrune:
+			options.Prune = false
+		case gcPrune == "now":
+			options.PruneNow = true
+		case gcPrune != "":
+			grace, err := time.ParseDuration(gcPrune)
+			if err != nil {
+				return fmt.Errorf("invalid --prune value '%s': use 'now' or a duration such as 72h", gcPrune)
+			}
+			options.GracePeriod = grace
+		}
+
+		result, err := gc.Run(repo, options)
+		if err != nil {
+			return fmt.Errorf("gc failed: %w", err)
+		}
+
+		fmt.Printf("%s Packed %d objects, pruned %d\n", display.Success("✓"), result.ObjectsPacked, result.ObjectsPruned)
+		return nil
+	},
+}
+
+func init() {
+	gcCmd.Flags().StringVar(&gcPrune, "prune", "", "prune unreachable objects older than this duration, or 'now'")
+	gcCmd.Flags().BoolVar(&gcNoPrune, "no-prune", false, "do not prune unreachable objects")
+	rootCmd.AddCommand(gcCmd)
+}
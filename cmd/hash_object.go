@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/hashobject"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+var (
+	hashObjectType  string
+	hashObjectWrite bool
+	hashObjectStdin bool
+)
+
+var hashObjectCmd = &cobra.Command{
+	Use:   "hash-object [-t <type>] [-w] (--stdin | <file>...)",
+	Short: "Compute object ID and optionally create an object from a file",
+	Long: `Computes the object ID for an object of the given type (blob by default)
+with the contents of the named files or of standard input, and with -w writes
+the object into the object database.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !hashObjectStdin && len(args) == 0 {
+			return fmt.Errorf("no input given; name files or use --stdin")
+		}
+
+		var repo *repository.Repository
+		if hashObjectWrite {
+			var err error
+			if repo, err = openRepository(); err != nil {
+				return err
+			}
+		}
+
+		hashContent := func(content []byte) error {
+			h, err := hashobject.Run(repo, content, hashObjectType, hashObjectWrite)
+			if err != nil {
+				return fmt.Errorf("hash-object failed: %w", err)
+			}
+			fmt.Println(h)
+			return nil
+		}
+
+		if hashObjectStdin {
+			content, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read standard input: %w", err)
+			}
+			if err := hashContent(content); err != nil {
+				return err
+			}
+		}
+		for _, path := range args {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			if err := hashContent(content); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	hashObjectCmd.Flags().StringVarP(&hashObjectType, "type", "t", "blob", "object type")
+	hashObjectCmd.Flags().BoolVarP(&hashObjectWrite, "write", "w", false, "write the object into the object database")
+	hashObjectCmd.Flags().BoolVar(&hashObjectStdin, "stdin", false, "read the object from standard input")
+
+	rootCmd.AddCommand(hashObjectCmd)
+}
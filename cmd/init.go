@@ -10,6 +10,8 @@ import (
 	"github.com/unkn0wn-root/git-go/pkg/display"
 )
 
+var initBare bool
+
 var initCmd = &cobra.Command{
 	Use:   "init [directory]",
 	Short: "Initialize a new Git repository",
@@ -31,20 +33,21 @@ var initCmd = &cobra.Command{
 		}
 
 		repo := repository.New(absPath)
+		if initBare {
+			repo = repository.NewBare(absPath)
+		}
 		if err := repo.Init(); err != nil {
 			return err
 		}
 
-		if workDir == "." {
-			fmt.Println(display.FormatInitResult(".", false))
-		} else {
-			fmt.Println(display.FormatInitResult(workDir, false))
-		}
+		fmt.Println(display.FormatInitResult(workDir, initBare))
 
 		return nil
 	},
 }
 
 func init() {
+	initCmd.Flags().BoolVar(&initBare, "bare", false, "create a bare repository without a working tree")
+
 	rootCmd.AddCommand(initCmd)
 }
@@ -2,38 +2,88 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/unkn0wn-root/git-go/internal/commands/log"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/mailmap"
 )
 
 var (
 	maxCount int
 	oneline  bool
 	graph    bool
+	showCo   bool
+	follow   bool
+	since    string
+	until    string
+	author   string
+	logStat  bool
+
+	logNoMailmap bool
 )
 
 var logCmd = &cobra.Command{
-	Use:   "log",
+	Use:   "log [<revision> | <A>..<B> | <A>...<B>] [-- <path>...]",
 	Short: "Show commit logs",
-	Long:  "Show the commit history starting from the current HEAD",
+	Long:  "Show the commit history starting from the current HEAD or the given revision, optionally limited to commits that changed the given paths",
+	Args:  cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+			return err
 		}
+		repo.WithCache(objectCacheSize)
 
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+		options := log.LogOptions{
+			MaxCount:      maxCount,
+			Oneline:       oneline,
+			Graph:         graph,
+			ShowCoAuthors: showCo,
+			Follow:        follow,
+			Author:        author,
+			Stat:          logStat,
 		}
 
-		options := log.LogOptions{
-			MaxCount: maxCount,
-			Oneline:  oneline,
-			Graph:    graph,
+		if !logNoMailmap {
+			if options.Mailmap, err = mailmap.Load(repo); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if since != "" {
+			t, err := log.ParseDate(since, now)
+			if err != nil {
+				return err
+			}
+			options.Since = &t
+		}
+		if until != "" {
+			t, err := log.ParseDate(until, now)
+			if err != nil {
+				return err
+			}
+			options.Until = &t
+		}
+
+		revArgs := args
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			revArgs = args[:dash]
+			if options.Paths, err = repoPaths(repo, args[dash:]); err != nil {
+				return err
+			}
+		}
+		if len(revArgs) > 1 {
+			return fmt.Errorf("too many revisions; separate paths with --")
+		}
+		if len(revArgs) > 0 {
+			if strings.Contains(revArgs[0], "..") {
+				options.Range = revArgs[0]
+			} else {
+				options.Revision = revArgs[0]
+			}
 		}
 
 		return log.ShowLog(repo, options)
@@ -44,6 +94,13 @@ func init() {
 	logCmd.Flags().IntVarP(&maxCount, "max-count", "n", 0, "limit the number of commits to output")
 	logCmd.Flags().BoolVar(&oneline, "oneline", false, "shorthand for --pretty=oneline --abbrev-commit")
 	logCmd.Flags().BoolVar(&graph, "graph", false, "draw a text-based graphical representation")
+	logCmd.Flags().BoolVar(&showCo, "co-authors", false, "show Co-authored-by trailers below the author")
+	logCmd.Flags().BoolVar(&follow, "follow", false, "continue listing the history of a file beyond renames")
+	logCmd.Flags().StringVar(&since, "since", "", "show commits more recent than a date")
+	logCmd.Flags().StringVar(&until, "until", "", "show commits older than a date")
+	logCmd.Flags().StringVar(&author, "author", "", "show commits whose author matches the given text")
+	logCmd.Flags().BoolVar(&logStat, "stat", false, "show a per-file summary of changed lines for each commit")
+	logCmd.Flags().BoolVar(&logNoMailmap, "no-mailmap", false, "show authors and committers as recorded, ignoring .mailmap")
 
 	rootCmd.AddCommand(logCmd)
 }
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/transport/remote"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var (
+	lsRemoteHeads bool
+	lsRemoteTags  bool
+)
+
+var lsRemoteCmd = &cobra.Command{
+	Use:   "ls-remote [<repository> [<patterns>...]]",
+	Short: "List references in a remote repository",
+	Long: `Displays the references available in a remote repository along with the associated commit IDs.
+<repository> is a configured remote name or a URL; without one, the default remote is used.
+When <patterns> are given, only references matching one of them are shown.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// outside a repository only URLs can be listed
+		repo, err := openRepository()
+		var gitDir string
+		if err == nil {
+			gitDir = repo.GitDir
+		}
+
+		auth, _ := remote.LoadRepoAuthConfig(gitDir)
+
+		var url string
+		if len(args) > 0 {
+			url = args[0]
+			args = args[1:]
+		}
+
+		// a configured remote name stands for its fetch URL
+		if gitDir != "" {
+			var r *remote.Remote
+			if url == "" {
+				if r, err = remote.GetDefaultRemote(repo); err != nil {
+					return fmt.Errorf("failed to get default remote: %w", err)
+				}
+			} else {
+				rc := remote.NewRemoteConfig(gitDir)
+				if err := rc.Load(); err == nil {
+					r, _ = rc.GetRemote(url)
+				}
+			}
+			if r != nil {
+				url = r.FetchURL
+				auth = auth.ForRemote(r)
+			}
+		}
+		if url == "" {
+			return fmt.Errorf("no remote repository specified")
+		}
+
+		refs, err := remote.LsRemote(context.Background(), url, auth, args...)
+		if err != nil {
+			return fmt.Errorf("ls-remote failed: %w", err)
+		}
+
+		var kinds []string
+		if lsRemoteHeads {
+			kinds = append(kinds, "refs/heads/*")
+		}
+		if lsRemoteTags {
+			kinds = append(kinds, "refs/tags/*")
+		}
+		if len(kinds) > 0 {
+			for ref := range refs {
+				if !strings.HasPrefix(ref, "refs/") || !remote.MatchRefPattern(ref, kinds) {
+					delete(refs, ref)
+				}
+			}
+		}
+
+		fmt.Print(display.FormatRemoteRefs(refs))
+		return nil
+	},
+}
+
+func init() {
+	lsRemoteCmd.Flags().BoolVar(&lsRemoteHeads, "heads", false, "limit to refs/heads")
+	lsRemoteCmd.Flags().BoolVar(&lsRemoteTags, "tags", false, "limit to refs/tags")
+
+	rootCmd.AddCommand(lsRemoteCmd)
+}
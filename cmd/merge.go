@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/merge"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var (
+	mergeNoFF    bool
+	mergeFFOnly  bool
+	mergeMessage string
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <commit>",
+	Short: "Join two development histories together",
+	Long: `Incorporate the changes from <commit> into the current branch. HEAD is
+fast-forwarded when possible, otherwise a merge commit is created.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+
+		result, err := merge.Merge(repo, args[0], merge.MergeOptions{
+			NoFF:    mergeNoFF,
+			FFOnly:  mergeFFOnly,
+			Message: mergeMessage,
+		})
+		if err != nil {
+			if result != nil {
+				for _, path := range result.Conflicts {
+					fmt.Printf("%s %s\n", display.Error("conflict:"), path)
+				}
+			}
+			return err
+		}
+
+		switch {
+		case result.UpToDate:
+			fmt.Println(display.Info("Already up to date."))
+		case result.FastForward:
+			fmt.Printf("%s Fast-forward to %s\n", display.Success("✓"), display.Hash(hash.ShortHash(result.Commit, 7)))
+		default:
+			fmt.Printf("%s Merge made by the 'recursive' strategy: %s\n", display.Success("✓"), display.Hash(hash.ShortHash(result.Commit, 7)))
+		}
+
+		for _, path := range result.UpdatedFiles {
+			fmt.Printf("  %s %s\n", display.Secondary("updated:"), path)
+		}
+		for _, path := range result.RemovedFiles {
+			fmt.Printf("  %s %s\n", display.Secondary("removed:"), path)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	mergeCmd.Flags().BoolVar(&mergeNoFF, "no-ff", false, "create a merge commit even when a fast-forward is possible")
+	mergeCmd.Flags().BoolVar(&mergeFFOnly, "ff-only", false, "refuse to merge unless the current HEAD can be fast-forwarded")
+	mergeCmd.Flags().StringVarP(&mergeMessage, "message", "m", "", "message for the merge commit")
+
+	rootCmd.AddCommand(mergeCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/mv"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv <source> <destination>",
+	Short: "Move or rename a file",
+	Long: `Move or rename a tracked file in the working tree and the index.
+
+If the destination is an existing directory, the file is moved into it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+		if args, err = repoPaths(repo, args); err != nil {
+			return err
+		}
+
+		if err := mv.Move(repo, args[0], args[1]); err != nil {
+			return fmt.Errorf("mv failed: %w", err)
+		}
+
+		fmt.Printf("%s Renamed %s -> %s\n", display.Success("✓"), display.Path(args[0]), display.Path(args[1]))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/plumbing"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+)
+
+var writeTreeCmd = &cobra.Command{
+	Use:   "write-tree",
+	Short: "Create a tree object from the current index",
+	Long:  "Creates a tree object using the current index and prints its name.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		treeHash, err := plumbing.WriteTree(repo)
+		if err != nil {
+			return fmt.Errorf("write-tree failed: %w", err)
+		}
+
+		fmt.Println(treeHash)
+		return nil
+	},
+}
+
+var readTreeCmd = &cobra.Command{
+	Use:   "read-tree <tree-ish>",
+	Short: "Read tree information into the index",
+	Long: `Replaces the index with the entries of the given tree, or of the tree of the
+given commit. The working tree is not updated.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+		treeHash, err := revparse.Resolve(repo, args[0]+"^{tree}")
+		if err != nil {
+			return fmt.Errorf("not a valid tree-ish: %w", err)
+		}
+
+		if err := plumbing.ReadTree(repo, treeHash); err != nil {
+			return fmt.Errorf("read-tree failed: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(writeTreeCmd)
+	rootCmd.AddCommand(readTreeCmd)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+// progressPrinter draws transfer progress on stderr, redrawing one line per
+// phase so that stdout stays clean
+type progressPrinter struct {
+	out io.Writer
+}
+
+func newProgressPrinter() *progressPrinter {
+	return &progressPrinter{out: os.Stderr}
+}
+
+func (pp *progressPrinter) Update(phase string, current, total int) {
+	if total <= 0 {
+		return
+	}
+	fmt.Fprintf(pp.out, "\r%s", display.FormatProgressWithStats(phase, current, total))
+	if current >= total {
+		fmt.Fprintln(pp.out)
+	}
+}
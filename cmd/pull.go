@@ -3,12 +3,13 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
 	"github.com/unkn0wn-root/git-go/internal/transport/pull"
+	"github.com/unkn0wn-root/git-go/internal/transport/remote"
 	"github.com/unkn0wn-root/git-go/pkg/display"
 )
 
@@ -21,6 +22,9 @@ var (
 	pullForce          bool
 	pullPrune          bool
 	pullDepth          int
+	pullTags           bool
+	pullNoTags         bool
+	pullDryRun         bool
 	pullTimeout        time.Duration
 )
 
@@ -30,14 +34,9 @@ var pullCmd = &cobra.Command{
 	Long: `Incorporates changes from a remote repository into the current branch.
 In its default mode, git pull is shorthand for git fetch followed by git merge FETCH_HEAD.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openWorkTree()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
-
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+			return err
 		}
 
 		options := pull.DefaultPullOptions()
@@ -68,9 +67,15 @@ In its default mode, git pull is shorthand for git fetch followed by git merge F
 		options.Force = pullForce
 		options.Prune = pullPrune
 		options.Depth = pullDepth
+		if pullTags {
+			options.Tags = remote.TagAll
+		} else if pullNoTags {
+			options.Tags = remote.TagNone
+		}
+		options.DryRun = pullDryRun
 		options.Timeout = pullTimeout
 
-		puller := pull.NewPuller(repo)
+		puller := pull.NewPuller(repo).WithProgress(newProgressPrinter())
 		ctx := context.Background()
 
 		fmt.Printf("%s Pulling from %s...\n", display.Info("⬇"), display.Emphasis(options.Remote))
@@ -80,12 +85,44 @@ In its default mode, git pull is shorthand for git fetch followed by git merge F
 			return fmt.Errorf("pull failed: %w", err)
 		}
 
+		if result.Plan != nil {
+			printFetchPlan(result.Plan)
+			return nil
+		}
+
 		printPullResult(result)
 		return nil
 	},
 }
 
+func printFetchPlan(plan *pull.FetchPlan) {
+	if len(plan.Refs) == 0 {
+		fmt.Println(display.Success("Already up to date."))
+		return
+	}
+
+	fmt.Printf("%s\n", display.Info("Dry run: these refs would be updated from "+plan.Remote))
+	for _, ref := range plan.Refs {
+		switch {
+		case ref.Rejected != "":
+			fmt.Printf(" %s %s -> %s (%s)\n", display.Error("! [rejected]"), ref.RemoteRef, display.Emphasis(ref.Ref), ref.Rejected)
+		case ref.OldHash == "":
+			fmt.Printf(" %s %s -> %s\n", display.Success("* [new]"), ref.RemoteRef, display.Emphasis(ref.Ref))
+		default:
+			fmt.Printf("   %s..%s %s -> %s", display.Hash(ref.OldHash[:7]), display.Hash(ref.NewHash[:7]), ref.RemoteRef, display.Emphasis(ref.Ref))
+			if ref.Counted {
+				fmt.Printf(" (%d ahead, %d behind)", ref.Ahead, ref.Behind)
+			}
+			fmt.Println()
+		}
+	}
+}
+
 func printPullResult(result *pull.PullResult) {
+	for _, ref := range slices.Sorted(maps.Keys(result.RejectedRefs)) {
+		fmt.Printf(" %s %s (%s)\n", display.Error("! [rejected]"), display.Emphasis(ref), result.RejectedRefs[ref])
+	}
+
 	if result.OldCommit == result.NewCommit {
 		fmt.Println(display.Success("Already up to date."))
 		return
@@ -142,6 +179,9 @@ func init() {
 	pullCmd.Flags().BoolVar(&pullForce, "force", false, "force pull even if it results in non-fast-forward")
 	pullCmd.Flags().BoolVar(&pullPrune, "prune", false, "remove remote tracking branches that no longer exist")
 	pullCmd.Flags().IntVar(&pullDepth, "depth", 0, "limit fetching to the specified number of commits")
+	pullCmd.Flags().BoolVarP(&pullTags, "tags", "t", false, "fetch all tags from the remote")
+	pullCmd.Flags().BoolVar(&pullNoTags, "no-tags", false, "do not fetch tags pointing at fetched commits")
+	pullCmd.Flags().BoolVar(&pullDryRun, "dry-run", false, "show which refs would be updated without fetching anything")
 	pullCmd.Flags().DurationVar(&pullTimeout, "timeout", 5*time.Minute, "timeout for pull operation")
 
 	rootCmd.AddCommand(pullCmd)
@@ -2,43 +2,45 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
 	"github.com/unkn0wn-root/git-go/internal/transport/push"
 	"github.com/unkn0wn-root/git-go/pkg/display"
+	giterrors "github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 var (
 	pushRemote      string
 	pushBranch      string
 	pushForce       bool
+	pushDelete      bool
+	pushLease       string
 	pushSetUpstream bool
 	pushAll         bool
 	pushTags        bool
 	pushDryRun      bool
+	pushNoVerify    bool
 	pushTimeout     time.Duration
 )
 
+// leaseFromTracking is the --force-with-lease value when no hash is given
+const leaseFromTracking = "tracking"
+
 var pushCmd = &cobra.Command{
 	Use:   "push [<remote>] [<branch>]",
 	Short: "Update remote refs along with associated objects",
 	Long: `Updates remote refs using local refs, while sending objects necessary to complete the given refs.
 When no remote is configured, the command defaults to 'origin'.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
-
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+			return err
 		}
+		repo.WithCache(objectCacheSize)
 
 		options := push.DefaultPushOptions()
 
@@ -55,15 +57,28 @@ When no remote is configured, the command defaults to 'origin'.`,
 		if pushBranch != "" {
 			options.Branch = pushBranch
 		}
+		// "git push origin :branch" pushes nothing into branch, deleting it
+		if branch, ok := strings.CutPrefix(options.Branch, ":"); ok {
+			options.Branch = branch
+			options.Delete = true
+		}
 
 		options.Force = pushForce
+		options.Delete = options.Delete || pushDelete
+		if cmd.Flags().Changed("force-with-lease") {
+			options.ForceWithLease = true
+			if pushLease != leaseFromTracking {
+				options.LeaseHash = pushLease
+			}
+		}
 		options.SetUpstream = pushSetUpstream
 		options.PushAll = pushAll
 		options.PushTags = pushTags
 		options.DryRun = pushDryRun
+		options.SkipHooks = pushNoVerify
 		options.Timeout = pushTimeout
 
-		pusher := push.NewPusher(repo)
+		pusher := push.NewPusher(repo).WithProgress(newProgressPrinter())
 		ctx := context.Background()
 
 		if pushDryRun {
@@ -82,7 +97,16 @@ When no remote is configured, the command defaults to 'origin'.`,
 			result, err = pusher.Push(ctx, options)
 		}
 
+		// a failed hook's output is already part of the error
+		if result != nil && result.HookOutput != "" && !errors.Is(err, giterrors.ErrHookFailed) {
+			fmt.Print(result.HookOutput)
+		}
+
 		if err != nil {
+			// show which refs the remote refused before the error itself
+			if result != nil && len(result.RejectedRefs) > 0 {
+				printPushResult(result)
+			}
 			return fmt.Errorf("push failed: %w", err)
 		}
 
@@ -106,7 +130,7 @@ func printPushResult(result *push.PushResult) {
 	updates := make(map[string]display.RefUpdate)
 	for refName, update := range result.UpdatedRefs {
 		updates[refName] = display.RefUpdate{
-			Status:  display.RefUpdateStatus(update.Status),
+			Status:  displayStatus(update.Status),
 			OldHash: update.OldHash,
 			NewHash: update.NewHash,
 		}
@@ -129,6 +153,17 @@ func printPushResult(result *push.PushResult) {
 		fmt.Println()
 	}
 
+	for _, reason := range result.RejectedRefs {
+		if reason == push.StaleInfo {
+			fmt.Println()
+			fmt.Print(display.FormatHintMessage([]string{
+				"The remote branch moved since it was last fetched, so the lease was not",
+				"honoured. Fetch and review the new remote work before forcing again.",
+			}))
+			return
+		}
+	}
+
 	if len(result.RejectedRefs) > 0 {
 		fmt.Println()
 		hints := []string{
@@ -142,6 +177,24 @@ func printPushResult(result *push.PushResult) {
 	}
 }
 
+// displayStatus maps a push status onto the one the display package prints
+func displayStatus(status push.RefUpdateStatus) display.RefUpdateStatus {
+	switch status {
+	case push.RefUpdateUpToDate:
+		return display.RefUpdateUpToDate
+	case push.RefUpdateFastForward:
+		return display.RefUpdateFastForward
+	case push.RefUpdateForced:
+		return display.RefUpdateForced
+	case push.RefUpdateDeleted:
+		return display.RefUpdateDeleted
+	case push.RefUpdateRejected, push.RefUpdateError:
+		return display.RefUpdateRejected
+	default:
+		return display.RefUpdateOK
+	}
+}
+
 func extractBranchName(refName string) string {
 	if refName == "" {
 		return ""
@@ -162,10 +215,14 @@ func init() {
 	pushCmd.Flags().StringVarP(&pushRemote, "remote", "r", "", "remote repository")
 	pushCmd.Flags().StringVarP(&pushBranch, "branch", "b", "", "branch to push")
 	pushCmd.Flags().BoolVarP(&pushForce, "force", "f", false, "force push even if it results in non-fast-forward")
+	pushCmd.Flags().StringVar(&pushLease, "force-with-lease", "", "force push only if the remote branch is at the given commit, or where it was last fetched")
+	pushCmd.Flags().Lookup("force-with-lease").NoOptDefVal = leaseFromTracking
+	pushCmd.Flags().BoolVarP(&pushDelete, "delete", "d", false, "delete the branch from the remote")
 	pushCmd.Flags().BoolVarP(&pushSetUpstream, "set-upstream", "u", false, "set upstream for the current branch")
 	pushCmd.Flags().BoolVar(&pushAll, "all", false, "push all branches")
 	pushCmd.Flags().BoolVar(&pushTags, "tags", false, "push all tags")
 	pushCmd.Flags().BoolVar(&pushDryRun, "dry-run", false, "show what would be pushed without actually pushing")
+	pushCmd.Flags().BoolVar(&pushNoVerify, "no-verify", false, "bypass the pre-push hook")
 	pushCmd.Flags().DurationVar(&pushTimeout, "timeout", 5*time.Minute, "timeout for push operation")
 
 	rootCmd.AddCommand(pushCmd)
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/rebase"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var (
+	rebaseOnto string
+	rebasePlan bool
+	rebaseTodo string
+)
+
+var rebaseCmd = &cobra.Command{
+	Use:   "rebase [--onto <newbase>] <upstream>",
+	Short: "Reapply commits on top of another base",
+	Long: `Replay the commits of the current branch that are not in <upstream> on top
+of <upstream>, or of <newbase> with --onto. --plan prints the todo list
+instead; edit it to squash or drop commits and pass it back with --todo.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+
+		plan, err := rebase.Plan(repo, rebaseOnto, args[0])
+		if err != nil {
+			return err
+		}
+
+		if rebasePlan {
+			for _, step := range plan.Steps {
+				fmt.Println(step.String())
+			}
+			return nil
+		}
+
+		if rebaseTodo != "" {
+			todo, err := os.ReadFile(rebaseTodo)
+			if err != nil {
+				return err
+			}
+			if plan.Steps, err = rebase.ParseTodo(repo, string(todo)); err != nil {
+				return err
+			}
+		}
+
+		result, err := rebase.Apply(repo, plan)
+		if err != nil {
+			if result != nil {
+				for _, step := range result.Steps {
+					for _, path := range step.Conflicts {
+						fmt.Printf("%s %s (%s)\n", display.Error("conflict:"), path, step.Step.String())
+					}
+				}
+			}
+			return err
+		}
+
+		fmt.Printf("%s Applied %d rebase steps, HEAD is now at %s\n", display.Success("✓"),
+			len(plan.Steps), display.Hash(hash.ShortHash(result.Head, 7)))
+		return nil
+	},
+}
+
+func init() {
+	rebaseCmd.Flags().StringVar(&rebaseOnto, "onto", "", "replay the commits on <newbase> instead of <upstream>")
+	rebaseCmd.Flags().BoolVar(&rebasePlan, "plan", false, "print the todo list instead of rebasing")
+	rebaseCmd.Flags().StringVar(&rebaseTodo, "todo", "", "replay the steps of an edited todo list file")
+
+	rootCmd.AddCommand(rebaseCmd)
+}
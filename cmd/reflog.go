@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/core/reflog"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var reflogCmd = &cobra.Command{
+	Use:   "reflog [<ref>]",
+	Short: "Show reference logs",
+	Long:  "Show the reflog of a reference (HEAD by default), newest entry first",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		ref := "HEAD"
+		if len(args) > 0 {
+			ref = args[0]
+		}
+
+		entries, err := reflog.Read(repo, ref)
+		if err != nil {
+			return err
+		}
+
+		for i, entry := range entries {
+			fmt.Printf("%s %s: %s\n", display.Hash(entry.NewHash), display.Secondary(fmt.Sprintf("%s@{%d}", ref, i)), entry.Message)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reflogCmd)
+}
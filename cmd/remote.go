@@ -2,14 +2,14 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
 	"github.com/unkn0wn-root/git-go/internal/transport/remote"
 	"github.com/unkn0wn-root/git-go/pkg/display"
 )
 
+var remoteSetURLPush bool
+
 var remoteCmd = &cobra.Command{
 	Use:   "remote",
 	Short: "Manage remote repositories",
@@ -25,14 +25,9 @@ var remoteAddCmd = &cobra.Command{
 	Long:  "Add a remote named <name> for the repository at <url>.",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
-
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+			return err
 		}
 
 		name := args[0]
@@ -61,14 +56,9 @@ var remoteRemoveCmd = &cobra.Command{
 	Long:  "Remove the remote named <name>. All remote-tracking branches and configuration settings for the remote are removed.",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
-
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+			return err
 		}
 
 		name := args[0]
@@ -94,14 +84,9 @@ var remoteListCmd = &cobra.Command{
 	Short: "List remote repositories",
 	Long:  "Show the remote repositories configured for this repository.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
-
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+			return err
 		}
 
 		rc := remote.NewRemoteConfig(repo.GitDir)
@@ -143,14 +128,9 @@ var remoteShowCmd = &cobra.Command{
 	Long:  "Show information about the remote named <name>.",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
-
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+			return err
 		}
 
 		name := args[0]
@@ -173,11 +153,73 @@ var remoteShowCmd = &cobra.Command{
 	},
 }
 
+var remoteRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a remote repository",
+	Long:  "Rename the remote named <old> to <new>. Remote-tracking branches and configuration settings for the remote are updated.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		rc := remote.NewRemoteConfig(repo.GitDir)
+		if err := rc.Load(); err != nil {
+			return fmt.Errorf("failed to load remote config: %w", err)
+		}
+
+		if err := rc.Rename(args[0], args[1]); err != nil {
+			return fmt.Errorf("failed to rename remote: %w", err)
+		}
+
+		fmt.Printf("%s %s\n", display.Success("✓"), display.FormatRemoteResult("rename", args[0], args[1]))
+		return nil
+	},
+}
+
+var remoteSetURLCmd = &cobra.Command{
+	Use:   "set-url <name> <url>",
+	Short: "Change the URL of a remote repository",
+	Long:  "Change the URL of the remote named <name>. With --push, only the URL used for pushing is changed.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		rc := remote.NewRemoteConfig(repo.GitDir)
+		if err := rc.Load(); err != nil {
+			return fmt.Errorf("failed to load remote config: %w", err)
+		}
+
+		if err := rc.SetURL(args[0], args[1], remoteSetURLPush); err != nil {
+			return fmt.Errorf("failed to set remote URL: %w", err)
+		}
+
+		kind := "URL"
+		if remoteSetURLPush {
+			kind = "push URL"
+		}
+		fmt.Printf("%s Set %s of remote %s to %s\n",
+			display.Success("✓"),
+			kind,
+			display.Emphasis(args[0]),
+			display.Path(args[1]))
+		return nil
+	},
+}
+
 func init() {
 	remoteCmd.AddCommand(remoteAddCmd)
 	remoteCmd.AddCommand(remoteRemoveCmd)
 	remoteCmd.AddCommand(remoteListCmd)
 	remoteCmd.AddCommand(remoteShowCmd)
+	remoteCmd.AddCommand(remoteRenameCmd)
+	remoteCmd.AddCommand(remoteSetURLCmd)
+
+	remoteSetURLCmd.Flags().BoolVar(&remoteSetURLPush, "push", false, "change the push URL only")
 
 	rootCmd.AddCommand(remoteCmd)
 }
@@ -5,15 +5,15 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/unkn0wn-root/git-go/internal/commands/reset"
-	"github.com/unkn0wn-root/git-go/internal/core/discovery"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
 	"github.com/unkn0wn-root/git-go/pkg/display"
 )
 
 var (
-	resetSoft  bool
-	resetMixed bool
-	resetHard  bool
+	resetSoft   bool
+	resetMixed  bool
+	resetHard   bool
+	resetDryRun bool
+	resetForce  bool
 )
 
 var resetCmd = &cobra.Command{
@@ -29,13 +29,11 @@ Reset modes:
 If no mode is specified, defaults to --mixed.
 If no commit is specified, defaults to HEAD.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := discovery.FindRepositoryFromCwd()
+		repo, err := openRepository()
 		if err != nil {
-			return fmt.Errorf("not a git repository (or any of the parent directories)")
+			return err
 		}
 
-		repo := repository.New(workDir)
-
 		// Determine reset mode
 		mode := reset.ResetModeMixed // default
 		modeCount := 0
@@ -56,6 +54,11 @@ If no commit is specified, defaults to HEAD.`,
 			return fmt.Errorf("cannot specify multiple reset modes")
 		}
 
+		// only a soft reset leaves the index and work tree alone
+		if mode != reset.ResetModeSoft && repo.IsBare() {
+			return fmt.Errorf("this operation must be run in a work tree")
+		}
+
 		target := ""
 		var paths []string
 
@@ -69,14 +72,36 @@ If no commit is specified, defaults to HEAD.`,
 			}
 		}
 
+		if paths, err = repoPaths(repo, paths); err != nil {
+			return err
+		}
 		if len(paths) > 0 && mode != reset.ResetModeMixed {
 			return fmt.Errorf("cannot specify paths with --soft or --hard")
 		}
 
-		if err := reset.Reset(repo, target, mode, paths); err != nil {
+		result, err := reset.Reset(repo, target, reset.ResetOptions{
+			Mode:   mode,
+			Paths:  paths,
+			DryRun: resetDryRun,
+			Force:  resetForce,
+		})
+		if err != nil {
 			return fmt.Errorf("reset failed: %w", err)
 		}
 
+		if resetDryRun {
+			for _, path := range result.Deleted {
+				fmt.Printf("Would delete %s\n", path)
+			}
+			for _, path := range result.Overwritten {
+				fmt.Printf("Would overwrite %s\n", path)
+			}
+			for _, path := range result.Restored {
+				fmt.Printf("Would restore %s\n", path)
+			}
+			return nil
+		}
+
 		if len(paths) == 0 {
 			fmt.Printf("%s HEAD is now at %s\n", display.Success("✓"), display.Hash(target))
 		}
@@ -89,6 +114,8 @@ func init() {
 	resetCmd.Flags().BoolVar(&resetSoft, "soft", false, "reset only HEAD")
 	resetCmd.Flags().BoolVar(&resetMixed, "mixed", false, "reset HEAD and index (default)")
 	resetCmd.Flags().BoolVar(&resetHard, "hard", false, "reset HEAD, index, and working tree")
+	resetCmd.Flags().BoolVarP(&resetDryRun, "dry-run", "n", false, "show the files a hard reset would change without changing anything")
+	resetCmd.Flags().BoolVarP(&resetForce, "force", "f", false, "let a hard reset overwrite untracked files")
 
 	rootCmd.AddCommand(resetCmd)
 }
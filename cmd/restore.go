@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/restore"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var restoreSource string
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [--source=<tree-ish>] <pathspec>...",
+	Short: "Restore working tree files",
+	Long: `Restore working tree files from the index or from a commit.
+
+Without --source, files are restored from the index.
+With --source, files are restored from the given commit, branch or tree.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+		if args, err = repoPaths(repo, args); err != nil {
+			return err
+		}
+
+		source := restore.SourceIndex
+		if restoreSource != "" {
+			source = restore.SourceTreeish(restoreSource)
+		}
+
+		if err := restore.RestoreFiles(repo, args, source); err != nil {
+			return fmt.Errorf("restore failed: %w", err)
+		}
+
+		for _, path := range args {
+			fmt.Printf("%s Restored %s\n", display.Success("✓"), display.Path(path))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	restoreCmd.Flags().StringVarP(&restoreSource, "source", "s", "", "restore from the given commit, branch or tree")
+
+	rootCmd.AddCommand(restoreCmd)
+}
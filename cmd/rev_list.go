@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/revlist"
+)
+
+var (
+	revListCount       bool
+	revListMaxCount    int
+	revListReverse     bool
+	revListFirstParent bool
+)
+
+var revListCmd = &cobra.Command{
+	Use:   "rev-list [<commit>... | ^<commit>... | <A>..<B> | <A>...<B>]",
+	Short: "List commit objects in reverse chronological order",
+	Long: `Lists the commits reachable from the given commits, leaving out those
+reachable from any commit given with a leading ^. Ranges may be given as
+A..B or A...B. With no commits, HEAD is used.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		opts := revlist.RevListOptions{
+			MaxCount:    revListMaxCount,
+			Reverse:     revListReverse,
+			FirstParent: revListFirstParent,
+		}
+		for _, arg := range args {
+			if rev, ok := strings.CutPrefix(arg, "^"); ok {
+				opts.Exclude = append(opts.Exclude, rev)
+			} else {
+				opts.Include = append(opts.Include, arg)
+			}
+		}
+
+		hashes, err := revlist.RevList(repo.WithCache(objectCacheSize), opts)
+		if err != nil {
+			return err
+		}
+
+		if revListCount {
+			fmt.Println(len(hashes))
+			return nil
+		}
+		for _, hash := range hashes {
+			fmt.Println(hash)
+		}
+		return nil
+	},
+}
+
+func init() {
+	revListCmd.Flags().BoolVar(&revListCount, "count", false, "print the number of commits instead of listing them")
+	revListCmd.Flags().IntVarP(&revListMaxCount, "max-count", "n", 0, "limit the number of commits to output")
+	revListCmd.Flags().BoolVar(&revListReverse, "reverse", false, "output the selected commits in reverse order")
+	revListCmd.Flags().BoolVar(&revListFirstParent, "first-parent", false, "follow only the first parent of merge commits")
+
+	rootCmd.AddCommand(revListCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/revert"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var revertMainline int
+
+var revertCmd = &cobra.Command{
+	Use:   "revert <commit>",
+	Short: "Revert an existing commit",
+	Long: `Create a new commit that undoes the changes made by <commit>. Reverting a
+merge commit requires -m to name the parent whose side is kept.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+
+		result, err := revert.Revert(repo, args[0], revert.RevertOptions{Mainline: revertMainline})
+		if err != nil {
+			if result != nil {
+				for _, path := range result.ConflictFiles {
+					fmt.Printf("%s %s\n", display.Error("conflict:"), path)
+				}
+			}
+			return err
+		}
+
+		fmt.Printf("%s Reverted %s in %s\n", display.Success("✓"),
+			display.Hash(hash.ShortHash(result.Reverted, 7)), display.Hash(hash.ShortHash(result.Commit, 7)))
+		return nil
+	},
+}
+
+func init() {
+	revertCmd.Flags().IntVarP(&revertMainline, "mainline", "m", 0, "parent number of the mainline when reverting a merge")
+
+	rootCmd.AddCommand(revertCmd)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/rm"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var (
+	rmCached bool
+	rmForce  bool
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm [--cached] [--force] <pathspec>...",
+	Short: "Remove files from the working tree and from the index",
+	Long: `Remove files from the index and the working tree.
+
+With --cached, files are only removed from the index and are left in the
+working tree as untracked files.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+		if args, err = repoPaths(repo, args); err != nil {
+			return err
+		}
+
+		if err := rm.Remove(repo, args, rm.RemoveOptions{Cached: rmCached, Force: rmForce}); err != nil {
+			return fmt.Errorf("rm failed: %w", err)
+		}
+
+		for _, path := range args {
+			fmt.Printf("%s rm '%s'\n", display.Success("✓"), display.Path(path))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rmCmd.Flags().BoolVar(&rmCached, "cached", false, "only remove from the index")
+	rmCmd.Flags().BoolVarP(&rmForce, "force", "f", false, "override the up-to-date check")
+
+	rootCmd.AddCommand(rmCmd)
+}
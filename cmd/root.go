@@ -1,13 +1,22 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/ssh"
 	"github.com/unkn0wn-root/git-go/pkg/display"
+	giterrors "github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
+// objectCacheSize bounds the parsed-object cache used by history-walking commands
+const objectCacheSize = 64 << 20
+
 var rootCmd = &cobra.Command{
 	Use:   "git-go",
 	Short: "A Git implementation in Go",
@@ -16,13 +25,79 @@ It supports the core Git functionality including init, add, commit, diff, blame
 	Version: "1.0.0",
 }
 
+// openRepository finds the repository the current directory belongs to
+func openRepository() (*repository.Repository, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	repo, err := repository.Discover(cwd)
+	if errors.Is(err, giterrors.ErrNotGitRepository) {
+		return nil, fmt.Errorf("not a git repository (or any of the parent directories)")
+	}
+	return repo, err
+}
+
+// openWorkTree is openRepository for commands that read or write the work
+// tree, which a bare repository does not have
+func openWorkTree() (*repository.Repository, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	if repo.IsBare() {
+		return nil, fmt.Errorf("this operation must be run in a work tree")
+	}
+	return repo, nil
+}
+
+// repoPaths rewrites paths given relative to the current directory as paths
+// relative to the top of the work tree
+func repoPaths(repo *repository.Repository, paths []string) ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	resolved := make([]string, len(paths))
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		rel, err := filepath.Rel(repo.WorkDir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s: outside repository", paths[i])
+		}
+		resolved[i] = filepath.ToSlash(rel)
+	}
+	return resolved, nil
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s %v\n", display.Error("Error:"), err)
+		explainHostKeyError(err)
 		os.Exit(1)
 	}
 }
 
+// explainHostKeyError tells the user what a refused SSH host key means
+func explainHostKeyError(err error) {
+	var hostKeyErr *ssh.HostKeyError
+	if !errors.As(err, &hostKeyErr) {
+		return
+	}
+	if hostKeyErr.Changed() {
+		known := hostKeyErr.Known[0]
+		fmt.Fprintf(os.Stderr, "The host key for %s does not match the one recorded in %s:%d.\n"+
+			"Someone could be intercepting the connection, or the host key was replaced.\n"+
+			"If the change is expected, remove that line and try again.\n", hostKeyErr.Host, known.Filename, known.Line)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s is not in known_hosts; verify its fingerprint and add it before connecting.\n", hostKeyErr.Host)
+}
+
 func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 }
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/shortlog"
+	"github.com/unkn0wn-root/git-go/internal/core/mailmap"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var (
+	shortlogSummary  bool
+	shortlogNumbered bool
+	shortlogEmail    bool
+)
+
+var shortlogCmd = &cobra.Command{
+	Use:   "shortlog [<revision> | <A>..<B>]",
+	Short: "Summarize commit history by author",
+	Long:  "Group the commits reachable from HEAD or the given revision by author, listing each author's commit subjects. Identities are merged through .mailmap.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+		repo.WithCache(objectCacheSize)
+
+		options := shortlog.ShortlogOptions{Email: shortlogEmail}
+		if options.Mailmap, err = mailmap.Load(repo); err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			if strings.Contains(args[0], "..") {
+				options.Range = args[0]
+			} else {
+				options.Revision = args[0]
+			}
+		}
+
+		groups, err := shortlog.Shortlog(repo, options)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(display.FormatShortlogGroups(groups, shortlogSummary, shortlogNumbered))
+		return nil
+	},
+}
+
+func init() {
+	shortlogCmd.Flags().BoolVarP(&shortlogSummary, "summary", "s", false, "show only a commit count per author")
+	shortlogCmd.Flags().BoolVarP(&shortlogNumbered, "numbered", "n", false, "sort authors by number of commits")
+	shortlogCmd.Flags().BoolVarP(&shortlogEmail, "email", "e", false, "show each author's email address")
+	rootCmd.AddCommand(shortlogCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/diff"
+	"github.com/unkn0wn-root/git-go/internal/commands/log"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+)
+
+var (
+	showStat    bool
+	showUnified int
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show [<commit>]",
+	Short: "Show a commit and the changes it introduces",
+	Long:  "Show the log entry of a commit, HEAD by default, followed by its diff. A merge commit is shown as a combined diff against all of its parents, listing only the changes that match none of them.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		revision := "HEAD"
+		if len(args) > 0 {
+			revision = args[0]
+		}
+		hash, err := revparse.ResolveCommit(repo, revision)
+		if err != nil {
+			return err
+		}
+
+		options := log.LogOptions{Revision: hash, MaxCount: 1}
+		entries, err := log.GetLog(repo, options)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			fmt.Print(entry.String(options))
+			fmt.Println()
+		}
+
+		return diff.ShowCommitDiff(repo, hash, diff.DiffOptions{
+			ContextLines: showUnified,
+			UnifiedZero:  showUnified == 0,
+			Stat:         showStat,
+		})
+	},
+}
+
+func init() {
+	showCmd.Flags().BoolVar(&showStat, "stat", false, "show a per-file summary of changed lines instead of the patch")
+	showCmd.Flags().IntVarP(&showUnified, "unified", "U", 3, "generate diffs with <n> lines of context")
+
+	rootCmd.AddCommand(showCmd)
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/stash"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var stashMessage string
+
+var stashCmd = &cobra.Command{
+	Use:   "stash",
+	Short: "Stash the changes in a dirty working directory away",
+	Long:  "Save local modifications to tracked files and revert the working directory to match HEAD. Without a subcommand, runs stash push.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stashPushCmd.RunE(cmd, args)
+	},
+}
+
+var stashPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Save local modifications to a new stash entry",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+
+		stashHash, err := stash.Push(repo, stashMessage)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s Saved working directory and index state %s\n",
+			display.Success("✓"), display.Hash(hash.ShortHash(stashHash, 7)))
+		return nil
+	},
+}
+
+var stashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the stash entries",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		stashes, err := stash.List(repo)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range stashes {
+			fmt.Printf("%s %s\n", display.Hash(hash.ShortHash(entry.Hash, 7)), entry.String())
+		}
+		return nil
+	},
+}
+
+var stashPopCmd = &cobra.Command{
+	Use:   "pop",
+	Short: "Apply the latest stash and remove it",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+
+		result, err := stash.Pop(repo)
+		if err != nil {
+			if result != nil {
+				for _, path := range result.Conflicts {
+					fmt.Printf("%s %s\n", display.Error("conflict:"), path)
+				}
+				fmt.Println(display.Hint("The stash entry is kept in case you need it again."))
+			}
+			return err
+		}
+
+		for _, path := range result.UpdatedFiles {
+			fmt.Printf("  %s %s\n", display.Secondary("updated:"), path)
+		}
+		for _, path := range result.RemovedFiles {
+			fmt.Printf("  %s %s\n", display.Secondary("removed:"), path)
+		}
+		fmt.Printf("%s Dropped stash %s\n", display.Success("✓"), display.Hash(hash.ShortHash(result.Stash, 7)))
+		return nil
+	},
+}
+
+var stashDropCmd = &cobra.Command{
+	Use:   "drop [<n>]",
+	Short: "Remove a single stash entry",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		n := 0
+		if len(args) > 0 {
+			n, err = strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid stash index %q", args[0])
+			}
+		}
+
+		if err := stash.Drop(repo, n); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s Dropped stash@{%d}\n", display.Success("✓"), n)
+		return nil
+	},
+}
+
+func init() {
+	stashCmd.Flags().StringVarP(&stashMessage, "message", "m", "", "describe the stash entry")
+	stashPushCmd.Flags().StringVarP(&stashMessage, "message", "m", "", "describe the stash entry")
+
+	stashCmd.AddCommand(stashPushCmd)
+	stashCmd.AddCommand(stashListCmd)
+	stashCmd.AddCommand(stashPopCmd)
+	stashCmd.AddCommand(stashDropCmd)
+	rootCmd.AddCommand(stashCmd)
+}
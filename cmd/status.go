@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/unkn0wn-root/git-go/internal/commands/status"
-	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+var (
+	statusPorcelain string
+	statusNul       bool
+	statusUntracked string
 )
 
 var statusCmd = &cobra.Command{
@@ -14,26 +18,52 @@ var statusCmd = &cobra.Command{
 	Short: "Show the working tree status",
 	Long:  "Show the working tree status including staged, unstaged, and untracked files",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workDir, err := os.Getwd()
+		repo, err := openWorkTree()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+			return err
 		}
 
-		repo := repository.New(workDir)
-		if !repo.Exists() {
-			return fmt.Errorf("not a git repository")
+		options := status.StatusOptions{}
+		switch statusUntracked {
+		case "normal":
+			options.Untracked = status.UntrackedNormal
+		case "all":
+			options.Untracked = status.UntrackedAll
+		case "no":
+			options.Untracked = status.UntrackedNo
+		default:
+			return fmt.Errorf("invalid untracked files mode %q", statusUntracked)
 		}
 
-		result, err := status.GetStatus(repo)
+		result, err := status.GetStatusWithOptions(repo, options)
 		if err != nil {
 			return fmt.Errorf("failed to get status: %w", err)
 		}
 
-		fmt.Print(result.String())
+		// -z implies the version 1 format unless another is asked for
+		if statusNul && statusPorcelain == "" {
+			statusPorcelain = "v1"
+		}
+		switch statusPorcelain {
+		case "":
+			fmt.Print(result.String())
+		case "v1", "1":
+			fmt.Print(result.Porcelain(1, statusNul))
+		case "v2", "2":
+			fmt.Print(result.Porcelain(2, statusNul))
+		default:
+			return fmt.Errorf("unsupported porcelain version %q", statusPorcelain)
+		}
 		return nil
 	},
 }
 
 func init() {
+	statusCmd.Flags().StringVar(&statusPorcelain, "porcelain", "", "give the output in an easy-to-parse format for scripts: v1 or v2")
+	statusCmd.Flags().Lookup("porcelain").NoOptDefVal = "v1"
+	statusCmd.Flags().StringVarP(&statusUntracked, "untracked-files", "u", "normal", "show untracked files: no, normal or all")
+	statusCmd.Flags().Lookup("untracked-files").NoOptDefVal = "all"
+	statusCmd.Flags().BoolVarP(&statusNul, "null", "z", false, "terminate entries with NUL")
+
 	rootCmd.AddCommand(statusCmd)
 }
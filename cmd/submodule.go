@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/core/submodule"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var submoduleCmd = &cobra.Command{
+	Use:   "submodule",
+	Short: "Inspect submodules",
+	Long:  "Inspect the repositories nested in the working tree as submodules",
+}
+
+var submoduleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the submodules",
+	Long: `Shows the commit recorded for each submodule and its path. The commit is
+prefixed with - when the submodule has not been cloned and with + when the
+commit checked out in it differs from the recorded one, which is then shown.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openWorkTree()
+		if err != nil {
+			return err
+		}
+
+		submodules, err := submodule.List(repo)
+		if err != nil {
+			return err
+		}
+
+		for _, sub := range submodules {
+			prefix, commit := " ", sub.Commit
+			if head, ok := submodule.Head(repo, sub.Path); !ok {
+				prefix = "-"
+			} else if head != sub.Commit {
+				prefix, commit = "+", head
+			}
+			fmt.Printf("%s%s %s\n", prefix, display.Hash(commit), display.Path(sub.Path))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	submoduleCmd.AddCommand(submoduleStatusCmd)
+	rootCmd.AddCommand(submoduleCmd)
+}
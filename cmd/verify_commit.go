@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/commands/commit"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var verifyCommitCmd = &cobra.Command{
+	Use:   "verify-commit <commit>",
+	Short: "Check the signature of a commit",
+	Long:  "Verify the GPG or SSH signature stored in the gpgsig header of a commit",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		hash, err := revparse.ResolveCommit(repo, args[0])
+		if err != nil {
+			return err
+		}
+
+		info, err := commit.VerifySignature(repo, hash)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s Good %s signature from %s\n", display.Success("✓"), info.Format, display.Info(info.Signer))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCommitCmd)
+}
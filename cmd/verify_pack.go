@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+var verifyPackVerbose bool
+
+var verifyPackCmd = &cobra.Command{
+	Use:   "verify-pack [-v] <pack>.idx...",
+	Short: "Validate packed Git archive files",
+	Long: `Reads the given pack index files and verifies the packs beside them. With -v,
+every object is listed with its type, size, size in the pack and offset, followed
+by its delta chain depth and base for deltified objects, and a histogram of
+chain lengths.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			idxPath := strings.TrimSuffix(arg, ".pack")
+			if !strings.HasSuffix(idxPath, ".idx") {
+				idxPath += ".idx"
+			}
+
+			entries, err := pack.Verify(idxPath)
+			if err != nil {
+				return fmt.Errorf("verify-pack failed: %w", err)
+			}
+
+			if verifyPackVerbose {
+				fmt.Print(formatVerifyPack(entries))
+				fmt.Printf("%s: %s\n", strings.TrimSuffix(idxPath, ".idx")+".pack", display.Success("ok"))
+			}
+		}
+		return nil
+	},
+}
+
+// formatVerifyPack lists entries as git verify-pack -v does
+func formatVerifyPack(entries []pack.PackEntry) string {
+	var buf strings.Builder
+	var chains []int
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "%s %-6s %d %d %d", e.Hash, e.Type, e.Size, e.PackedSize, e.Offset)
+		if e.Depth > 0 {
+			fmt.Fprintf(&buf, " %d %s", e.Depth, e.Base)
+		}
+		buf.WriteString("\n")
+
+		for len(chains) <= e.Depth {
+			chains = append(chains, 0)
+		}
+		chains[e.Depth]++
+	}
+
+	objects := func(n int) string {
+		if n == 1 {
+			return "1 object"
+		}
+		return fmt.Sprintf("%d objects", n)
+	}
+	if len(chains) > 0 {
+		fmt.Fprintf(&buf, "non delta: %s\n", objects(chains[0]))
+	}
+	for depth := 1; depth < len(chains); depth++ {
+		if chains[depth] > 0 {
+			fmt.Fprintf(&buf, "chain length = %d: %s\n", depth, objects(chains[depth]))
+		}
+	}
+	return buf.String()
+}
+
+func init() {
+	verifyPackCmd.Flags().BoolVarP(&verifyPackVerbose, "verbose", "v", false, "list objects and delta chain lengths")
+
+	rootCmd.AddCommand(verifyPackCmd)
+}
@@ -1,12 +1,15 @@
 package add
 
 import (
+	"bytes"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/commands/diff"
 	"github.com/unkn0wn-root/git-go/internal/core/gitignore"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
@@ -76,6 +79,15 @@ func addFile(repo *repository.Repository, idx *index.Index, filePath string, gi
 		return errors.NewGitError("add", filePath, err)
 	}
 
+	// Convert to Git-compatible path format (forward slashes)
+	gitPath := filepath.ToSlash(relPath)
+
+	autoCRLF, err := repo.AutoCRLFFor(gitPath)
+	if err != nil {
+		return errors.NewGitError("add", filePath, err)
+	}
+	content = autoCRLF.ToIndex(content)
+
 	info, err := os.Stat(filePath)
 	if err != nil {
 		return errors.NewGitError("add", filePath, err)
@@ -92,8 +104,6 @@ func addFile(repo *repository.Repository, idx *index.Index, filePath string, gi
 		mode = uint32(0o100755)
 	}
 
-	// Convert to Git-compatible path format (forward slashes)
-	gitPath := filepath.ToSlash(relPath)
 	if err := idx.AddWithFileInfo(gitPath, hash, mode, info); err != nil {
 		return errors.NewGitError("add", filePath, err)
 	}
@@ -134,3 +144,124 @@ func addDirectory(repo *repository.Repository, idx *index.Index, dirPath string,
 		return addFile(repo, idx, path, gi)
 	})
 }
+
+// AddPatch stages part of a file's changes. The working file is diffed
+// against its index blob, selectHunk is asked about each hunk, and a blob
+// holding only the accepted hunks is staged in place of the index blob.
+func AddPatch(repo *repository.Repository, path string, selectHunk func(hunk diff.DiffHunk) bool) error {
+	if !repo.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return errors.NewGitError("add", "", fmt.Errorf("load index: %w", err))
+	}
+
+	fullPath := filepath.Join(repo.WorkDir, path)
+	relPath, err := filepath.Rel(repo.WorkDir, fullPath)
+	if err != nil {
+		return errors.NewGitError("add", path, err)
+	}
+	gitPath := filepath.ToSlash(relPath)
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return errors.NewGitError("add", path, fmt.Errorf("pathspec did not match any files"))
+	}
+	autoCRLF, err := repo.AutoCRLFFor(gitPath)
+	if err != nil {
+		return errors.NewGitError("add", path, err)
+	}
+	content = autoCRLF.ToIndex(content)
+
+	var staged []byte
+	mode := uint32(objects.FileModeBlob)
+	if entry, ok := idx.Get(gitPath); ok {
+		blob, err := repo.LoadBlob(entry.Hash)
+		if err != nil {
+			return errors.NewObjectError(entry.Hash, "blob", err)
+		}
+		staged = blob.Content()
+		mode = entry.Mode
+	} else if info, err := os.Stat(fullPath); err == nil && info.Mode()&0o111 != 0 {
+		mode = uint32(objects.FileModeExecutable)
+	}
+
+	fileDiff := diff.ComputeFileDiff(staged, content, gitPath, gitPath)
+	if fileDiff.Binary {
+		return errors.NewGitError("add", path, fmt.Errorf("cannot stage part of a binary file"))
+	}
+
+	var selected []diff.DiffHunk
+	for _, hunk := range fileDiff.Hunks {
+		if selectHunk(hunk) {
+			selected = append(selected, hunk)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	patched := applyHunks(staged, content, selected)
+	hash, err := repo.StoreObject(objects.NewBlob(patched))
+	if err != nil {
+		return errors.NewGitError("add", path, err)
+	}
+
+	// no stat data is recorded since the working file still differs from
+	// the staged blob
+	if err := idx.Add(gitPath, hash, mode, int64(len(patched)), time.Time{}); err != nil {
+		return errors.NewGitError("add", path, err)
+	}
+
+	if err := idx.Save(); err != nil {
+		return errors.NewGitError("add", "", fmt.Errorf("failed to save index: %w", err))
+	}
+
+	return nil
+}
+
+// applyHunks rebuilds oldContent with the given hunks of its diff against
+// newContent applied. Hunks must be in file order; lines are copied whole so
+// line endings are kept as they are.
+func applyHunks(oldContent, newContent []byte, hunks []diff.DiffHunk) []byte {
+	oldLines := splitRawLines(oldContent)
+	newLines := splitRawLines(newContent)
+
+	var buf bytes.Buffer
+	pos := 0
+	for _, hunk := range hunks {
+		// a side with no lines starts at the line before the change
+		oldStart := hunk.OldStart
+		if hunk.OldCount > 0 {
+			oldStart--
+		}
+		newStart := hunk.NewStart
+		if hunk.NewCount > 0 {
+			newStart--
+		}
+
+		for _, line := range oldLines[pos:oldStart] {
+			buf.Write(line)
+		}
+		for _, line := range newLines[newStart : newStart+hunk.NewCount] {
+			buf.Write(line)
+		}
+		pos = oldStart + hunk.OldCount
+	}
+	for _, line := range oldLines[pos:] {
+		buf.Write(line)
+	}
+
+	return buf.Bytes()
+}
+
+// splitRawLines splits content after each newline, keeping the newlines
+func splitRawLines(content []byte) [][]byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -1,12 +1,15 @@
 package add
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/commands/diff"
 	"github.com/unkn0wn-root/git-go/internal/core/gitignore"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
@@ -237,3 +240,49 @@ func TestAddIgnoredFile(t *testing.T) {
 
 	assert.False(t, idx.IsStaged("test.log"))
 }
+
+func TestAddPatch(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	original := strings.Join(lines, "\n") + "\n"
+	testFile := filepath.Join(repo.WorkDir, "file.txt")
+	require.NoError(t, os.WriteFile(testFile, []byte(original), 0644))
+	require.NoError(t, AddFiles(repo, []string{"file.txt"}))
+
+	lines[1] = "line two"
+	lines[17] = "line eighteen"
+	require.NoError(t, os.WriteFile(testFile, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+	var offered []diff.DiffHunk
+	err := AddPatch(repo, "file.txt", func(hunk diff.DiffHunk) bool {
+		offered = append(offered, hunk)
+		return len(offered) == 1
+	})
+	require.NoError(t, err)
+	require.Len(t, offered, 2)
+
+	idx := index.New(repo.GitDir)
+	require.NoError(t, idx.Load())
+	entry, ok := idx.Get("file.txt")
+	require.True(t, ok)
+	blob, err := repo.LoadBlob(entry.Hash)
+	require.NoError(t, err)
+
+	expected := strings.Replace(original, "line 2\n", "line two\n", 1)
+	assert.Equal(t, expected, string(blob.Content()))
+}
+
+func TestApplyHunksKeepsLineEndings(t *testing.T) {
+	oldContent := []byte("a\r\nb\r\nx\r\nc")
+	newContent := []byte("a\r\nB\r\nx\r\nc\r\nd")
+	fileDiff := diff.ComputeFileDiffWithContext(oldContent, newContent, "f", "f", 0)
+	require.Len(t, fileDiff.Hunks, 2)
+
+	assert.Equal(t, "a\r\nB\r\nx\r\nc", string(applyHunks(oldContent, newContent, fileDiff.Hunks[:1])))
+	assert.Equal(t, "a\r\nb\r\nx\r\nc\r\nd", string(applyHunks(oldContent, newContent, fileDiff.Hunks[1:])))
+}
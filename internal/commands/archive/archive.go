@@ -0,0 +1,229 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+	FormatTgz   = "tgz"
+	FormatZip   = "zip"
+
+	// modes git archive gives entries in tar files, its default tar.umask
+	// of 002 applied
+	tarFileMode    = 0664
+	tarExecMode    = 0775
+	tarDirMode     = 0775
+	tarSymlinkMode = 0777
+
+	zipFileMode = 0644
+	zipExecMode = 0755
+	zipDirMode  = 0755
+)
+
+type ArchiveOptions struct {
+	// Prefix is prepended to every path in the archive; end it with a slash
+	// to put the tree in a directory
+	Prefix string
+}
+
+// entryWriter adds the entries of a tree to an archive in one format
+type entryWriter interface {
+	writeDir(name string) error
+	writeFile(name string, mode objects.FileMode, size int64, content io.Reader) error
+	writeSymlink(name, target string) error
+	Close() error
+}
+
+// Archive writes the tree treeish names to w in format, with no prefix
+func Archive(repo *repository.Repository, treeish, format string, w io.Writer) error {
+	return ArchiveWithOptions(repo, treeish, format, w, ArchiveOptions{})
+}
+
+// ArchiveWithOptions writes the tree treeish names to w as a tar, gzipped
+// tar or zip archive, as git archive does. Entries carry the commit's time
+// when treeish names a commit and the current time for a bare tree; the
+// commit's hash is recorded in the archive's comment.
+func ArchiveWithOptions(repo *repository.Repository, treeish, format string, w io.Writer, options ArchiveOptions) error {
+	treeHash, err := revparse.Resolve(repo, treeish+"^{tree}")
+	if err != nil {
+		return errors.NewGitError("archive", treeish, err)
+	}
+
+	mtime, commitHash := time.Now(), ""
+	if hash, err := revparse.Resolve(repo, treeish+"^{commit}"); err == nil {
+		commit, err := repo.LoadCommit(hash)
+		if err != nil {
+			return err
+		}
+		mtime, commitHash = commit.Committer().When, hash
+	}
+
+	var archive entryWriter
+	switch format {
+	case FormatTar:
+		archive, err = newTarArchive(w, nil, mtime, commitHash)
+	case FormatTarGz, FormatTgz:
+		gz := gzip.NewWriter(w)
+		gz.ModTime = mtime
+		archive, err = newTarArchive(gz, gz, mtime, commitHash)
+	case FormatZip:
+		archive, err = newZipArchive(w, mtime, commitHash)
+	default:
+		return errors.NewGitError("archive", format, fmt.Errorf("unknown archive format"))
+	}
+	if err != nil {
+		return errors.NewGitError("archive", "", err)
+	}
+
+	if strings.HasSuffix(options.Prefix, "/") {
+		if err := archive.writeDir(options.Prefix); err != nil {
+			return errors.NewGitError("archive", options.Prefix, err)
+		}
+	}
+
+	err = objects.WalkTree(repo, treeHash, func(path string, entry objects.TreeEntry) error {
+		name := options.Prefix + path
+		switch {
+		case entry.IsDir() || entry.IsSubmodule():
+			// a submodule's content is not part of this repository
+			return archive.writeDir(name + "/")
+		case entry.Mode == objects.FileModeSymlink:
+			blob, err := repo.LoadBlob(entry.Hash)
+			if err != nil {
+				return err
+			}
+			return archive.writeSymlink(name, string(blob.Content()))
+		default:
+			content, size, err := repo.OpenBlob(entry.Hash)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+			return archive.writeFile(name, entry.Mode, size, content)
+		}
+	})
+	if err != nil {
+		return errors.NewGitError("archive", treeish, err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return errors.NewGitError("archive", "", err)
+	}
+	return nil
+}
+
+type tarArchive struct {
+	tw    *tar.Writer
+	gz    *gzip.Writer
+	mtime time.Time
+}
+
+func newTarArchive(w io.Writer, gz *gzip.Writer, mtime time.Time, commitHash string) (*tarArchive, error) {
+	a := &tarArchive{tw: tar.NewWriter(w), gz: gz, mtime: mtime}
+	if commitHash != "" {
+		header := &tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			PAXRecords: map[string]string{"comment": commitHash},
+		}
+		if err := a.tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *tarArchive) writeDir(name string) error {
+	return a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: tarDirMode, ModTime: a.mtime})
+}
+
+func (a *tarArchive) writeFile(name string, mode objects.FileMode, size int64, content io.Reader) error {
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: tarFileMode, Size: size, ModTime: a.mtime}
+	if mode == objects.FileModeExecutable {
+		header.Mode = tarExecMode
+	}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(a.tw, content)
+	return err
+}
+
+func (a *tarArchive) writeSymlink(name, target string) error {
+	return a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: name, Linkname: target, Mode: tarSymlinkMode, ModTime: a.mtime})
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if a.gz != nil {
+		return a.gz.Close()
+	}
+	return nil
+}
+
+type zipArchive struct {
+	zw    *zip.Writer
+	mtime time.Time
+}
+
+func newZipArchive(w io.Writer, mtime time.Time, commitHash string) (*zipArchive, error) {
+	a := &zipArchive{zw: zip.NewWriter(w), mtime: mtime}
+	if commitHash != "" {
+		if err := a.zw.SetComment(commitHash); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *zipArchive) create(name string, method uint16, mode os.FileMode) (io.Writer, error) {
+	header := &zip.FileHeader{Name: name, Method: method, Modified: a.mtime}
+	header.SetMode(mode)
+	return a.zw.CreateHeader(header)
+}
+
+func (a *zipArchive) writeDir(name string) error {
+	_, err := a.create(name, zip.Store, os.ModeDir|zipDirMode)
+	return err
+}
+
+func (a *zipArchive) writeFile(name string, mode objects.FileMode, size int64, content io.Reader) error {
+	perm := os.FileMode(zipFileMode)
+	if mode == objects.FileModeExecutable {
+		perm = zipExecMode
+	}
+	w, err := a.create(name, zip.Deflate, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
+
+func (a *zipArchive) writeSymlink(name, target string) error {
+	w, err := a.create(name, zip.Store, os.ModeSymlink|0777)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, target)
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
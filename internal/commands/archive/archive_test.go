@@ -0,0 +1,230 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+// archived is what an archive holds at one path
+type archived struct {
+	content string
+	mode    os.FileMode
+}
+
+var commitTime = time.Unix(1700000000, 0).UTC()
+
+// setupArchiveRepo commits README, bin/run.sh (executable), docs/guide/intro.md,
+// a symlink and a submodule, returning the commit and what an archive of it
+// should contain
+func setupArchiveRepo(t *testing.T) (*repository.Repository, string, map[string]archived) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	blob := func(content string) string {
+		h, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		return h
+	}
+	tree := func(entries ...objects.TreeEntry) string {
+		h, err := repo.StoreObject(objects.NewTree(entries))
+		if err != nil {
+			t.Fatalf("Failed to store tree: %v", err)
+		}
+		return h
+	}
+
+	intro := strings.Repeat("introduction\n", 1000)
+	root := tree(
+		objects.TreeEntry{Mode: objects.FileModeBlob, Name: "README", Hash: blob("hello\n")},
+		objects.TreeEntry{Mode: objects.FileModeTree, Name: "bin", Hash: tree(
+			objects.TreeEntry{Mode: objects.FileModeExecutable, Name: "run.sh", Hash: blob("#!/bin/sh\necho run\n")},
+		)},
+		objects.TreeEntry{Mode: objects.FileModeTree, Name: "docs", Hash: tree(
+			objects.TreeEntry{Mode: objects.FileModeTree, Name: "guide", Hash: tree(
+				objects.TreeEntry{Mode: objects.FileModeBlob, Name: "intro.md", Hash: blob(intro)},
+			)},
+		)},
+		objects.TreeEntry{Mode: objects.FileModeSymlink, Name: "latest", Hash: blob("docs/guide")},
+		objects.TreeEntry{Mode: objects.FileModeCommit, Name: "vendor", Hash: "2222222222222222222222222222222222222222"},
+	)
+
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: commitTime}
+	commit, err := repo.StoreObject(objects.NewCommit(root, nil, sig, sig, "initial\n"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/main", commit); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	return repo, commit, map[string]archived{
+		"README":              {"hello\n", 0664},
+		"bin/":                {"", os.ModeDir | 0775},
+		"bin/run.sh":          {"#!/bin/sh\necho run\n", 0775},
+		"docs/":               {"", os.ModeDir | 0775},
+		"docs/guide/":         {"", os.ModeDir | 0775},
+		"docs/guide/intro.md": {intro, 0664},
+		"latest":              {"docs/guide", os.ModeSymlink | 0777},
+		"vendor/":             {"", os.ModeDir | 0775},
+	}
+}
+
+func readTar(t *testing.T, r io.Reader) (map[string]archived, string) {
+	entries := make(map[string]archived)
+	comment := ""
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read tar: %v", err)
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			comment = header.PAXRecords["comment"]
+			continue
+		}
+		if !header.ModTime.Equal(commitTime) {
+			t.Errorf("Expected %s to have the commit time, got %v", header.Name, header.ModTime)
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", header.Name, err)
+		}
+		entry := archived{content: string(content), mode: header.FileInfo().Mode()}
+		if header.Typeflag == tar.TypeSymlink {
+			entry.content = header.Linkname
+		}
+		entries[header.Name] = entry
+	}
+	return entries, comment
+}
+
+func assertEntries(t *testing.T, expected, got map[string]archived) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Errorf("Expected %d entries, got %d: %v", len(expected), len(got), got)
+	}
+	for name, want := range expected {
+		entry, ok := got[name]
+		if !ok {
+			t.Errorf("Missing %s", name)
+			continue
+		}
+		if entry.mode != want.mode {
+			t.Errorf("%s: expected mode %v, got %v", name, want.mode, entry.mode)
+		}
+		if entry.content != want.content {
+			t.Errorf("%s: expected content %q, got %q", name, want.content, entry.content)
+		}
+	}
+}
+
+func TestArchive_Tar(t *testing.T) {
+	repo, commit, expected := setupArchiveRepo(t)
+
+	var buf bytes.Buffer
+	if err := Archive(repo, "main", FormatTar, &buf); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	entries, comment := readTar(t, &buf)
+	assertEntries(t, expected, entries)
+	if comment != commit {
+		t.Errorf("Expected the commit %s in the global header, got %q", commit, comment)
+	}
+}
+
+func TestArchive_TarGzWithPrefix(t *testing.T) {
+	repo, _, files := setupArchiveRepo(t)
+
+	var buf bytes.Buffer
+	if err := ArchiveWithOptions(repo, "HEAD", FormatTarGz, &buf, ArchiveOptions{Prefix: "project/"}); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read gzip: %v", err)
+	}
+	entries, _ := readTar(t, gz)
+
+	expected := map[string]archived{"project/": {"", os.ModeDir | 0775}}
+	for name, entry := range files {
+		expected["project/"+name] = entry
+	}
+	assertEntries(t, expected, entries)
+}
+
+func TestArchive_Zip(t *testing.T) {
+	repo, commit, files := setupArchiveRepo(t)
+
+	var buf bytes.Buffer
+	if err := Archive(repo, "main", FormatZip, &buf); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+	if zr.Comment != commit {
+		t.Errorf("Expected the commit %s as the zip comment, got %q", commit, zr.Comment)
+	}
+
+	entries := make(map[string]archived)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		entries[f.Name] = archived{content: string(content), mode: f.Mode()}
+	}
+
+	// zip entries use the plain 0644 and 0755 modes
+	expected := make(map[string]archived)
+	for name, entry := range files {
+		switch entry.mode {
+		case 0664:
+			entry.mode = 0644
+		case 0775:
+			entry.mode = 0755
+		case os.ModeDir | 0775:
+			entry.mode = os.ModeDir | 0755
+		}
+		expected[name] = entry
+	}
+	assertEntries(t, expected, entries)
+}
+
+func TestArchive_Errors(t *testing.T) {
+	repo, _, _ := setupArchiveRepo(t)
+
+	if err := Archive(repo, "main", "rar", io.Discard); err == nil {
+		t.Error("Expected an unknown format to fail")
+	}
+	if err := Archive(repo, "missing", FormatTar, io.Discard); err == nil {
+		t.Error("Expected an unknown revision to fail")
+	}
+}
@@ -4,20 +4,24 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/core/mailmap"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/rename"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
 	"github.com/unkn0wn-root/git-go/pkg/display"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 const (
-	shortHashLength   = 8
-	contextWindowSize = 3
-	unknownAuthor     = "Unknown"
-	timeFormat        = "2006-01-02 15:04:05"
+	shortHashLength = 8
+	unknownAuthor   = "Unknown"
+	timeFormat      = "2006-01-02 15:04:05"
 
 	// line numbering starts from 1
 	firstLineNumber = 1
@@ -53,8 +57,27 @@ func (br *BlameResult) String() string {
 	return buf.String()
 }
 
-func BlameFile(repo *repository.Repository, filePath, commitHash string) (*BlameResult, error) {
-	if commitHash == "" {
+// BlameOptions selects what to annotate. Zero StartLine and EndLine mean the
+// first and last line of the file; an empty Rev means HEAD.
+type BlameOptions struct {
+	StartLine int
+	EndLine   int
+	Rev       string
+	// Follow keeps blaming a file's old name past the commit that renamed it
+	Follow bool
+	// Mailmap rewrites the author shown for each line
+	Mailmap *mailmap.Mailmap
+}
+
+func BlameFile(repo *repository.Repository, filePath string, options BlameOptions) (*BlameResult, error) {
+	var commitHash string
+	if options.Rev != "" {
+		hash, err := revparse.ResolveCommit(repo, options.Rev)
+		if err != nil {
+			return nil, errors.NewGitError("blame", filePath, err)
+		}
+		commitHash = hash
+	} else {
 		head, err := repo.GetHead()
 		if err != nil {
 			return nil, errors.NewGitError("blame", filePath, err)
@@ -72,28 +95,37 @@ func BlameFile(repo *repository.Repository, filePath, commitHash string) (*Blame
 	}
 
 	lines := splitLines(content)
-	blameLines := make([]BlameLine, len(lines))
+	start, end, err := lineRange(options, len(lines))
+	if err != nil {
+		return nil, errors.NewGitError("blame", filePath, err)
+	}
+
+	blameLines := make([]BlameLine, 0, end-start+1)
+	b := newBlamer(repo, options.Follow)
 
-	for i, line := range lines {
-		commit, err := findCommitForLine(repo, commitHash, filePath, i+firstLineNumber)
+	for lineNumber := start; lineNumber <= end; lineNumber++ {
+		line := lines[lineNumber-firstLineNumber]
+
+		commit, err := b.findCommit(commitHash, filePath, lineNumber, make(map[string]bool))
 		if err != nil {
-			blameLines[i] = BlameLine{
-				LineNumber: i + firstLineNumber,
+			blameLines = append(blameLines, BlameLine{
+				LineNumber: lineNumber,
 				Content:    line,
 				CommitHash: commitHash,
 				Author:     unknownAuthor,
 				AuthorTime: time.Now(),
-			}
+			})
 			continue
 		}
 
-		blameLines[i] = BlameLine{
-			LineNumber: i + firstLineNumber,
+		author := options.Mailmap.ResolveSignature(commit.Author())
+		blameLines = append(blameLines, BlameLine{
+			LineNumber: lineNumber,
 			Content:    line,
 			CommitHash: commit.Hash(),
-			Author:     commit.Author().Name,
+			Author:     author.Name,
 			AuthorTime: commit.Author().When,
-		}
+		})
 	}
 
 	return &BlameResult{
@@ -102,6 +134,72 @@ func BlameFile(repo *repository.Repository, filePath, commitHash string) (*Blame
 	}, nil
 }
 
+// lineRange resolves the requested range against a file of total lines. An
+// end past the last line is clamped, as git does.
+func lineRange(options BlameOptions, total int) (int, int, error) {
+	start, end := options.StartLine, options.EndLine
+	if start < 0 || end < 0 {
+		return 0, 0, fmt.Errorf("invalid line range %d,%d", start, end)
+	}
+	if start == 0 {
+		start = firstLineNumber
+	}
+	if end == 0 || end > total {
+		end = total
+	}
+
+	if total == 0 && options.StartLine == 0 {
+		// empty file, nothing to annotate
+		return firstLineNumber, 0, nil
+	}
+	if start > total {
+		return 0, 0, fmt.Errorf("file has only %d lines", total)
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("invalid line range %d,%d", options.StartLine, options.EndLine)
+	}
+
+	return start, end, nil
+}
+
+// ParseLineRange parses a -L argument of the form "start,end". Either side may
+// be empty, meaning the start or end of the file, and end may be written as
+// "+count" lines starting at start.
+func ParseLineRange(spec string) (int, int, error) {
+	startSpec, endSpec, found := strings.Cut(spec, ",")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid line range %q: expected start,end", spec)
+	}
+
+	var start, end int
+	var err error
+	if startSpec != "" {
+		if start, err = strconv.Atoi(startSpec); err != nil || start < firstLineNumber {
+			return 0, 0, fmt.Errorf("invalid line range %q: bad start line", spec)
+		}
+	}
+
+	switch {
+	case endSpec == "":
+	case strings.HasPrefix(endSpec, "+"):
+		count, err := strconv.Atoi(endSpec[1:])
+		if err != nil || count < 1 {
+			return 0, 0, fmt.Errorf("invalid line range %q: bad line count", spec)
+		}
+		end = max(start, firstLineNumber) + count - 1
+	default:
+		if end, err = strconv.Atoi(endSpec); err != nil || end < firstLineNumber {
+			return 0, 0, fmt.Errorf("invalid line range %q: bad end line", spec)
+		}
+	}
+
+	if start > 0 && end > 0 && start > end {
+		return 0, 0, fmt.Errorf("invalid line range %q: start is after end", spec)
+	}
+
+	return start, end, nil
+}
+
 func getFileContentAtCommit(repo *repository.Repository, commitHash, filePath string) ([]byte, error) {
 	commitObj, err := repo.LoadObject(commitHash)
 	if err != nil {
@@ -142,128 +240,255 @@ func getFileContentAtCommit(repo *repository.Repository, commitHash, filePath st
 	return nil, errors.NewGitError("blame", filePath, fmt.Errorf("file not found in commit"))
 }
 
-func findCommitForLine(repo *repository.Repository, commitHash, filePath string, lineNumber int) (*objects.Commit, error) {
-	return findCommitForLineRecursive(repo, commitHash, filePath, lineNumber, make(map[string]bool))
+// blamer passes lines of a file from commits to their parents. Each commit's
+// version of the file is aligned with each parent's once, and every line is
+// mapped through that alignment.
+type blamer struct {
+	repo   *repository.Repository
+	follow bool
+	steps  map[blameKey]*blameStep
+}
+
+type blameKey struct {
+	commit string
+	path   string
 }
 
-func findCommitForLineRecursive(repo *repository.Repository, commitHash, filePath string, lineNumber int, visited map[string]bool) (*objects.Commit, error) {
+// blameStep is the version of a file a commit has, aligned with the version
+// of each parent that has the file
+type blameStep struct {
+	commit  *objects.Commit
+	missing bool
+	lines   int
+	parents []parentFile
+}
+
+type parentFile struct {
+	hash string
+	path string
+	// lineMap holds each line's number in the parent, or 0 if the commit
+	// added or changed it
+	lineMap []int
+}
+
+func newBlamer(repo *repository.Repository, follow bool) *blamer {
+	return &blamer{repo: repo, follow: follow, steps: make(map[blameKey]*blameStep)}
+}
+
+// findCommit returns the commit that introduced the given line of filePath
+// as it is in commitHash
+func (b *blamer) findCommit(commitHash, filePath string, lineNumber int, visited map[string]bool) (*objects.Commit, error) {
 	// Prevent infinite loops in commit history
 	if visited[commitHash] {
 		return nil, fmt.Errorf("circular reference detected")
 	}
 	visited[commitHash] = true
 
-	commitObj, err := repo.LoadObject(commitHash)
+	step, err := b.step(commitHash, filePath)
 	if err != nil {
 		return nil, err
 	}
 
+	parents := step.commit.Parents()
+	if len(parents) == 0 {
+		// This is the initial commit
+		return step.commit, nil
+	}
+	if step.missing {
+		// File doesn't exist at this commit, try parent
+		return b.findCommit(parents[0], filePath, lineNumber, visited)
+	}
+	if lineNumber > step.lines {
+		// Line doesn't exist in current version
+		return step.commit, nil
+	}
+
+	// Pass blame to the first parent that carries the line unchanged. For a
+	// merge that may be any parent; only a line no parent has was introduced
+	// here.
+	for _, parent := range step.parents {
+		if mapped := parent.lineMap[lineNumber-firstLineNumber]; mapped > 0 {
+			return b.findCommit(parent.hash, parent.path, mapped, visited)
+		}
+	}
+
+	// Line was introduced or modified in this commit
+	return step.commit, nil
+}
+
+// step loads commitHash's version of filePath and aligns it with its
+// parents' versions, the first time it is asked for
+func (b *blamer) step(commitHash, filePath string) (*blameStep, error) {
+	key := blameKey{commit: commitHash, path: filePath}
+	if step, ok := b.steps[key]; ok {
+		return step, nil
+	}
+
+	commitObj, err := b.repo.LoadObject(commitHash)
+	if err != nil {
+		return nil, err
+	}
 	commit, ok := commitObj.(*objects.Commit)
 	if !ok {
 		return nil, errors.NewGitError("blame", filePath, fmt.Errorf("object is not a commit"))
 	}
 
-	parents := commit.Parents()
-	if len(parents) == 0 {
-		// This is the initial commit
-		return commit, nil
+	step := &blameStep{commit: commit}
+	b.steps[key] = step
+	if len(commit.Parents()) == 0 {
+		return step, nil
 	}
 
-	currentContent, err := getFileContentAtCommit(repo, commitHash, filePath)
+	content, err := getFileContentAtCommit(b.repo, commitHash, filePath)
 	if err != nil {
-		// File doesn't exist at this commit, try parent
-		if len(parents) > 0 {
-			return findCommitForLineRecursive(repo, parents[0], filePath, lineNumber, visited)
-		}
-		return commit, nil
-	}
-
-	currentLines := splitLines(currentContent)
-	if lineNumber > len(currentLines) {
-		// Line doesn't exist in current version
-		return commit, nil
+		step.missing = true
+		return step, nil
 	}
+	lines := splitLines(content)
+	step.lines = len(lines)
 
-	// Track line changes across parent commits
-	for _, parentHash := range parents {
-		parentContent, err := getFileContentAtCommit(repo, parentHash, filePath)
+	for _, parentHash := range commit.Parents() {
+		parentPath := filePath
+		parentContent, err := getFileContentAtCommit(b.repo, parentHash, filePath)
+		if err != nil && b.follow {
+			parentPath, parentContent, err = renamedFileContent(b.repo, commitHash, parentHash, filePath)
+		}
 		if err != nil {
-			// File didn't exist in parent, this commit introduced it
+			// File didn't exist in this parent
 			continue
 		}
 
-		parentLines := splitLines(parentContent)
-
-		// Map line numbers between current and parent versions
-		mappedLine := findLineInParent(currentLines, parentLines, lineNumber)
-		if mappedLine > 0 && mappedLine <= len(parentLines) {
-			// Line exists in parent, check if it's the same
-			if currentLines[lineNumber-firstLineNumber] == parentLines[mappedLine-firstLineNumber] {
-				// Line unchanged, continue tracking in parent
-				return findCommitForLineRecursive(repo, parentHash, filePath, mappedLine, visited)
-			}
-		}
+		step.parents = append(step.parents, parentFile{
+			hash:    parentHash,
+			path:    parentPath,
+			lineMap: alignLines(lines, splitLines(parentContent)),
+		})
 	}
-
-	// Line was introduced or modified in this commit
-	return commit, nil
+	return step, nil
 }
 
-// findLineInParent maps line numbers between file versions using simple content matching
-func findLineInParent(currentLines, parentLines []string, currentLineNum int) int {
-	if currentLineNum > len(currentLines) {
-		return 0
+// renamedFileContent looks for the path filePath had in parentHash when
+// commitHash renamed it, returning that path and its content
+func renamedFileContent(repo *repository.Repository, commitHash, parentHash, filePath string) (string, []byte, error) {
+	files, err := checkout.CommitFiles(repo, commitHash)
+	if err != nil {
+		return "", nil, err
+	}
+	parentFiles, err := checkout.CommitFiles(repo, parentHash)
+	if err != nil {
+		return "", nil, err
 	}
 
-	currentLine := currentLines[currentLineNum-firstLineNumber]
-
-	// Simple line matching approach (could be enhanced with LCS diff)
-	for i, parentLine := range parentLines {
-		if parentLine == currentLine {
-			// Found matching line, adjust for surrounding context
-			offset := findBestOffset(currentLines, parentLines, currentLineNum-firstLineNumber, i)
-			return i + firstLineNumber + offset
-		}
+	source, ok := rename.Source(parentFiles, files, filePath)
+	if !ok {
+		return "", nil, errors.NewGitError("blame", filePath, fmt.Errorf("file not found in commit"))
 	}
 
-	// Line not found in parent (newly added)
-	return 0
+	_, content, err := repo.ReadObjectData(parentFiles[source].Hash)
+	if err != nil {
+		return "", nil, err
+	}
+	return source, content, nil
 }
 
-// findBestOffset improves line mapping accuracy by checking surrounding context
-func findBestOffset(currentLines, parentLines []string, currentIdx, parentIdx int) int {
-	// Use small context window to find best line alignment
-	maxScore := -1
-	bestOffset := 0
+// alignLines returns, for each of currentLines, the number of the line of
+// parentLines it is kept as, or 0 if it was added or changed. The versions
+// are aligned by a shortest edit script found with Myers' linear space
+// algorithm.
+func alignLines(currentLines, parentLines []string) []int {
+	lineMap := make([]int, len(currentLines))
+	alignRange(currentLines, parentLines, 0, 0, lineMap)
+	return lineMap
+}
 
-	for offset := -contextWindowSize; offset <= contextWindowSize; offset++ {
-		score := 0
-		newParentIdx := parentIdx + offset
+// alignRange aligns a with b, which start at lines aOff and bOff of the whole
+// versions, recording matched lines in lineMap
+func alignRange(a, b []string, aOff, bOff int, lineMap []int) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		lineMap[aOff] = bOff + firstLineNumber
+		a, b = a[1:], b[1:]
+		aOff++
+		bOff++
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		lineMap[aOff+len(a)-1] = bOff + len(b) - 1 + firstLineNumber
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return
+	}
 
-		if newParentIdx < 0 || newParentIdx >= len(parentLines) {
-			continue
-		}
+	// with the common ends gone the edit script has at least two edits, so
+	// both halves around the middle snake are smaller than the whole
+	x, y, u, v := middleSnake(a, b)
+	alignRange(a[:x], b[:y], aOff, bOff, lineMap)
+	for i := 0; i < u-x; i++ {
+		lineMap[aOff+x+i] = bOff + y + i + firstLineNumber
+	}
+	alignRange(a[u:], b[v:], aOff+u, bOff+v, lineMap)
+}
 
-		// Score based on matching surrounding lines
-		for i := -contextWindowSize; i <= contextWindowSize; i++ {
-			curIdx := currentIdx + i
-			parIdx := newParentIdx + i
+// middleSnake finds the middle snake of a shortest edit script turning a
+// into b: the run of matching lines from (x, y) to (u, v) that the script's
+// halves meet on. Forward paths are searched from the start and backward
+// paths from the end until they overlap.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+
+	// forward[k] is the furthest x reached on diagonal k = x - y, and
+	// backward[c] the furthest distance from the end reached on diagonal
+	// c = delta - k
+	offset := maxD + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
 
-			if curIdx >= 0 && curIdx < len(currentLines) &&
-				parIdx >= 0 && parIdx < len(parentLines) {
-				if currentLines[curIdx] == parentLines[parIdx] {
-					score++
-				}
+			if c := delta - k; odd && c >= -(d-1) && c <= d-1 && x+backward[offset+c] >= n {
+				return startX, startY, x, y
 			}
 		}
 
-		if score > maxScore {
-			maxScore = score
-			bestOffset = offset
+		for c := -d; c <= d; c += 2 {
+			var x int
+			if c == -d || (c != d && backward[offset+c-1] < backward[offset+c+1]) {
+				x = backward[offset+c+1]
+			} else {
+				x = backward[offset+c-1] + 1
+			}
+			y := x - c
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+c] = x
+
+			if k := delta - c; !odd && k >= -d && k <= d && x+forward[offset+k] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
 		}
 	}
 
-	return bestOffset
+	// the paths always meet by maxD, as no script is longer than n+m
+	panic("blame: edit paths did not meet")
 }
 
 func splitLines(content []byte) []string {
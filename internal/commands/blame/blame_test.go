@@ -2,6 +2,7 @@ package blame
 
 import (
 	"bytes"
+	"math/rand"
 	"testing"
 	"time"
 
@@ -141,7 +142,7 @@ func TestBlameFile_NoCommits(t *testing.T) {
 		t.Fatalf("Failed to initialize test repository: %v", err)
 	}
 
-	result, err := BlameFile(repo, "test.txt", "")
+	result, err := BlameFile(repo, "test.txt", BlameOptions{})
 
 	if err == nil {
 		t.Error("Expected error for repository with no commits")
@@ -160,7 +161,7 @@ func TestBlameFile_FileNotFound(t *testing.T) {
 		t.Fatalf("Failed to get HEAD: %v", err)
 	}
 
-	result, err := BlameFile(repo, "nonexistent.txt", commitHash)
+	result, err := BlameFile(repo, "nonexistent.txt", BlameOptions{Rev: commitHash})
 
 	if err == nil {
 		t.Error("Expected error for non-existent file")
@@ -179,7 +180,7 @@ func TestBlameFile_SingleCommit(t *testing.T) {
 		t.Fatalf("Failed to get HEAD: %v", err)
 	}
 
-	result, err := BlameFile(repo, "test.txt", commitHash)
+	result, err := BlameFile(repo, "test.txt", BlameOptions{Rev: commitHash})
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -216,7 +217,7 @@ func TestBlameFile_UseHead(t *testing.T) {
 	mock := setupBasicMockRepo(t)
 	repo := setupTestRepository(t, mock)
 
-	result, err := BlameFile(repo, "test.txt", "")
+	result, err := BlameFile(repo, "test.txt", BlameOptions{})
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -231,6 +232,79 @@ func TestBlameFile_UseHead(t *testing.T) {
 	}
 }
 
+func TestBlameFile_LineRange(t *testing.T) {
+	mock := setupBasicMockRepo(t)
+	repo := setupTestRepository(t, mock)
+
+	result, err := BlameFile(repo, "test.txt", BlameOptions{StartLine: 2, EndLine: 3, Rev: "main"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(result.Lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(result.Lines))
+	}
+	for i, want := range []string{"line 2", "line 3"} {
+		if result.Lines[i].LineNumber != i+2 {
+			t.Errorf("Expected line number %d, got %d", i+2, result.Lines[i].LineNumber)
+		}
+		if result.Lines[i].Content != want {
+			t.Errorf("Expected content %q, got %q", want, result.Lines[i].Content)
+		}
+	}
+
+	// an end past the last line is clamped
+	result, err = BlameFile(repo, "test.txt", BlameOptions{StartLine: 3, EndLine: 40})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Lines) != 1 || result.Lines[0].LineNumber != 3 {
+		t.Errorf("Expected only line 3, got %+v", result.Lines)
+	}
+
+	if _, err := BlameFile(repo, "test.txt", BlameOptions{StartLine: 10}); err == nil {
+		t.Error("Expected error for a start line past the end of the file")
+	}
+	if _, err := BlameFile(repo, "test.txt", BlameOptions{Rev: "nonexistent"}); err == nil {
+		t.Error("Expected error for an unknown revision")
+	}
+}
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		spec       string
+		start, end int
+		wantErr    bool
+	}{
+		{spec: "10,40", start: 10, end: 40},
+		{spec: "10,", start: 10},
+		{spec: ",40", end: 40},
+		{spec: "10,+5", start: 10, end: 14},
+		{spec: "40,10", wantErr: true},
+		{spec: "0,10", wantErr: true},
+		{spec: "10", wantErr: true},
+		{spec: "a,b", wantErr: true},
+		{spec: "10,+0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		start, end, err := ParseLineRange(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLineRange(%q) expected error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLineRange(%q) unexpected error: %v", tt.spec, err)
+			continue
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("ParseLineRange(%q) = %d,%d, want %d,%d", tt.spec, start, end, tt.start, tt.end)
+		}
+	}
+}
+
 func TestGetFileContentAtCommit_Success(t *testing.T) {
 	mock := setupBasicMockRepo(t)
 	repo := setupTestRepository(t, mock)
@@ -266,59 +340,74 @@ func TestGetFileContentAtCommit_InvalidCommit(t *testing.T) {
 	}
 }
 
-func TestFindLineInParent_ExactMatch(t *testing.T) {
+func TestAlignLines_ExactMatch(t *testing.T) {
 	currentLines := []string{"line 1", "line 2", "line 3"}
 	parentLines := []string{"line 1", "line 2", "line 3"}
 
-	result := findLineInParent(currentLines, parentLines, 2)
-
-	if result != 2 {
-		t.Errorf("Expected line 2, got %d", result)
+	want := []int{1, 2, 3}
+	for i, got := range alignLines(currentLines, parentLines) {
+		if got != want[i] {
+			t.Errorf("line %d: expected %d, got %d", i+1, want[i], got)
+		}
 	}
 }
 
-func TestFindLineInParent_LineNotFound(t *testing.T) {
+func TestAlignLines_LineNotFound(t *testing.T) {
 	currentLines := []string{"line 1", "new line", "line 3"}
 	parentLines := []string{"line 1", "line 3"}
 
-	result := findLineInParent(currentLines, parentLines, 2)
-
-	if result != 0 {
-		t.Errorf("Expected 0 (not found), got %d", result)
-	}
-}
-
-func TestFindLineInParent_InvalidLineNumber(t *testing.T) {
-	currentLines := []string{"line 1", "line 2"}
-	parentLines := []string{"line 1", "line 2"}
-
-	result := findLineInParent(currentLines, parentLines, 5)
-
-	if result != 0 {
-		t.Errorf("Expected 0 for invalid line number, got %d", result)
+	want := []int{1, 0, 2}
+	for i, got := range alignLines(currentLines, parentLines) {
+		if got != want[i] {
+			t.Errorf("line %d: expected %d, got %d", i+1, want[i], got)
+		}
 	}
 }
 
-func TestFindBestOffset_NoOffset(t *testing.T) {
-	currentLines := []string{"line 1", "line 2", "line 3"}
-	parentLines := []string{"line 1", "line 2", "line 3"}
-
-	offset := findBestOffset(currentLines, parentLines, 1, 1)
-
-	if offset != 0 {
-		t.Errorf("Expected offset 0, got %d", offset)
+func TestAlignLines_LongestCommonSubsequence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(40))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for round := 0; round < 500; round++ {
+		currentLines, parentLines := randomLines(), randomLines()
+		lineMap := alignLines(currentLines, parentLines)
+
+		matched, last := 0, 0
+		for i, mapped := range lineMap {
+			if mapped == 0 {
+				continue
+			}
+			if mapped <= last || currentLines[i] != parentLines[mapped-1] {
+				t.Fatalf("%q vs %q: line %d mapped to %d out of order or to a different line", currentLines, parentLines, i+1, mapped)
+			}
+			matched, last = matched+1, mapped
+		}
+		if want := lcsLength(currentLines, parentLines); matched != want {
+			t.Fatalf("%q vs %q: matched %d lines, longest common subsequence has %d", currentLines, parentLines, matched, want)
+		}
 	}
 }
 
-func TestFindBestOffset_WithOffset(t *testing.T) {
-	currentLines := []string{"line 1", "line 2", "line 3", "line 4"}
-	parentLines := []string{"new line", "line 1", "line 2", "line 3", "line 4"}
-
-	offset := findBestOffset(currentLines, parentLines, 1, 2)
-
-	if offset != 0 {
-		t.Errorf("Expected offset 0, got %d", offset)
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
 	}
+	return prev[len(b)]
 }
 
 func TestSplitLines_EmptyContent(t *testing.T) {
@@ -377,7 +466,7 @@ func TestFindCommitForLineRecursive_CircularReference(t *testing.T) {
 	repo := setupTestRepository(t, mock)
 
 	visited := make(map[string]bool)
-	result, err := findCommitForLineRecursive(repo, "commit1", "test.txt", 1, visited)
+	result, err := newBlamer(repo, false).findCommit("commit1", "test.txt", 1, visited)
 
 	if err == nil {
 		t.Error("Expected error for circular reference")
@@ -387,12 +476,105 @@ func TestFindCommitForLineRecursive_CircularReference(t *testing.T) {
 	}
 }
 
-func setupBasicMockRepo(t *testing.T) *MockRepository {
+func TestBlameFile_MergeAttributesSideBranch(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize test repository: %v", err)
+	}
+
+	// the side branch adds a block whose braces also appear on main
+	base := storeFileCommit(t, repo, "test.txt", "Base", "{\nx\n}\n")
+	main := storeFileCommit(t, repo, "test.txt", "Main", "{\nX\n}\n", base)
+	side := storeFileCommit(t, repo, "test.txt", "Side", "{\nx\n}\n{\ny\n}\n", base)
+	merge := storeFileCommit(t, repo, "test.txt", "Merge", "{\nX\n}\n{\ny\n}\nresolved\n", main, side)
+
+	result, err := BlameFile(repo, "test.txt", BlameOptions{Rev: merge})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []string{base, main, base, side, side, side, merge}
+	if len(result.Lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %d", len(want), len(result.Lines))
+	}
+	for i, hash := range want {
+		if result.Lines[i].CommitHash != hash {
+			t.Errorf("line %d (%q): expected %s, got %s (%s)", i+1, result.Lines[i].Content,
+				hash, result.Lines[i].CommitHash, result.Lines[i].Author)
+		}
+	}
+}
+
+func TestBlameFile_Follow(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize test repository: %v", err)
+	}
+
+	created := storeFileCommit(t, repo, "old.txt", "Create", "one\ntwo\n")
+	renamed := storeFileCommit(t, repo, "new.txt", "Rename", "one\ntwo\n", created)
+	edited := storeFileCommit(t, repo, "new.txt", "Edit", "one\nTWO\n", renamed)
+
+	result, err := BlameFile(repo, "new.txt", BlameOptions{Rev: edited})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Lines[0].CommitHash != renamed {
+		t.Errorf("without follow, expected line 1 blamed on the rename, got %s", result.Lines[0].Author)
+	}
+
+	result, err = BlameFile(repo, "new.txt", BlameOptions{Rev: edited, Follow: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Lines[0].CommitHash != created {
+		t.Errorf("with follow, expected line 1 blamed on its creation, got %s", result.Lines[0].Author)
+	}
+	if result.Lines[1].CommitHash != edited {
+		t.Errorf("expected line 2 blamed on the edit, got %s", result.Lines[1].Author)
+	}
+}
+
+func TestAlignLines(t *testing.T) {
+	currentLines := []string{"{", "x", "}", "{", "new", "}"}
+	parentLines := []string{"{", "x", "}"}
+
+	want := []int{1, 2, 3, 0, 0, 0}
+	for i, got := range alignLines(currentLines, parentLines) {
+		if got != want[i] {
+			t.Errorf("line %d: expected %d, got %d", i+1, want[i], got)
+		}
+	}
+}
+
+func storeFileCommit(t *testing.T, repo *repository.Repository, path, author, content string, parents ...string) string {
+	t.Helper()
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: path, Hash: blobHash},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+
+	sig := &objects.Signature{Name: author, When: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, sig, sig, author))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+	return commitHash
+}
+
+func setupBasicMockRepo(t testing.TB) *MockRepository {
 	mock := NewMockRepository()
 	return mock
 }
 
-func setupTestRepository(t *testing.T, mock *MockRepository) *repository.Repository {
+func setupTestRepository(t testing.TB, mock *MockRepository) *repository.Repository {
 	tempDir := t.TempDir()
 
 	repo := repository.New(tempDir)
@@ -450,8 +632,8 @@ func setupTestRepository(t *testing.T, mock *MockRepository) *repository.Reposit
 }
 
 func BenchmarkBlameFile(b *testing.B) {
-	mock := setupBasicMockRepo(nil)
-	repo := setupTestRepository(nil, mock) // Note: passing nil for testing.T in benchmark
+	mock := setupBasicMockRepo(b)
+	repo := setupTestRepository(b, mock)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -460,7 +642,7 @@ func BenchmarkBlameFile(b *testing.B) {
 			b.Fatalf("Failed to get HEAD: %v", err)
 		}
 
-		_, err = BlameFile(repo, "test.txt", commitHash)
+		_, err = BlameFile(repo, "test.txt", BlameOptions{Rev: commitHash})
 		if err != nil {
 			b.Fatalf("Unexpected error: %v", err)
 		}
@@ -476,7 +658,7 @@ func BenchmarkSplitLines(b *testing.B) {
 	}
 }
 
-func BenchmarkFindLineInParent(b *testing.B) {
+func BenchmarkAlignLines(b *testing.B) {
 	currentLines := make([]string, 1000)
 	parentLines := make([]string, 1000)
 
@@ -487,6 +669,24 @@ func BenchmarkFindLineInParent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		findLineInParent(currentLines, parentLines, 500)
+		alignLines(currentLines, parentLines)
+	}
+}
+
+func BenchmarkBlameFile_Cached(b *testing.B) {
+	mock := setupBasicMockRepo(b)
+	repo := setupTestRepository(b, mock).WithCache(8 << 20)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		commitHash, err := repo.GetHead()
+		if err != nil {
+			b.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		_, err = BlameFile(repo, "test.txt", BlameOptions{Rev: commitHash})
+		if err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
 	}
 }
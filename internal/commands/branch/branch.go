@@ -0,0 +1,275 @@
+package branch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/config"
+	"github.com/unkn0wn-root/git-go/internal/core/mergebase"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	defaultDirMode = 0755
+	logsDir        = "logs"
+	headsPrefix    = "refs/heads/"
+	headRef        = "HEAD"
+	configFile     = "config"
+	packedRefsFile = "packed-refs"
+)
+
+type BranchInfo struct {
+	Name      string
+	Hash      string
+	IsCurrent bool
+	Upstream  string
+}
+
+// Create writes refs/heads/<name> pointing at startPoint (HEAD when empty)
+func Create(repo *repository.Repository, name, startPoint string) error {
+	if !repo.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	if err := validateName(name); err != nil {
+		return errors.NewGitError("branch", name, err)
+	}
+
+	refName := headsPrefix + name
+	if _, err := repo.ReadRef(refName); err == nil {
+		return errors.NewGitError("branch", name, errors.ErrBranchAlreadyExists)
+	}
+
+	if startPoint == "" {
+		startPoint = headRef
+	}
+
+	hash, err := revparse.ResolveCommit(repo, startPoint)
+	if err != nil {
+		return errors.NewGitError("branch", startPoint, fmt.Errorf("not a valid object name: %w", err))
+	}
+
+	if err := repo.UpdateRefWithMessage(refName, hash, "branch: Created from "+startPoint); err != nil {
+		return errors.NewGitError("branch", refName, err)
+	}
+
+	return nil
+}
+
+// Delete removes a branch. Unless force is set, it refuses to delete a
+// branch whose tip is not reachable from HEAD.
+func Delete(repo *repository.Repository, name string, force bool) error {
+	if !repo.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	refName := headsPrefix + name
+	hash, err := repo.ReadRef(refName)
+	if err != nil {
+		return errors.NewGitError("branch", name, errors.ErrBranchNotFound)
+	}
+
+	if current, err := repo.GetCurrentBranch(); err == nil && current == name {
+		return errors.NewGitError("branch", name, fmt.Errorf("cannot delete the branch '%s' which you are currently on", name))
+	}
+
+	if !force {
+		head, err := repo.GetHead()
+		if err != nil {
+			return errors.NewGitError("branch", name, err)
+		}
+
+		merged := false
+		if head != "" {
+			if merged, err = mergebase.IsAncestor(repo, hash, head); err != nil {
+				return errors.NewGitError("branch", name, err)
+			}
+		}
+		if !merged {
+			return errors.NewGitError("branch", name, errors.ErrBranchNotMerged)
+		}
+	}
+
+	if err := repo.DeleteRef(refName, hash); err != nil {
+		return errors.NewGitError("branch", refName, err)
+	}
+
+	return nil
+}
+
+// List returns all local branches sorted by name
+func List(repo *repository.Repository) ([]BranchInfo, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	names := make(map[string]bool)
+
+	headsDir := filepath.Join(repo.GitDir, "refs", "heads")
+	err := filepath.WalkDir(headsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".lock") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(headsDir, path)
+		if err != nil {
+			return err
+		}
+		names[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewGitError("branch", headsDir, err)
+	}
+
+	packed, err := readPackedBranches(repo)
+	if err != nil {
+		return nil, errors.NewGitError("branch", packedRefsFile, err)
+	}
+	for _, name := range packed {
+		names[name] = true
+	}
+
+	current, _ := repo.GetCurrentBranch()
+	upstreams, err := readUpstreams(repo)
+	if err != nil {
+		return nil, errors.NewGitError("branch", configFile, err)
+	}
+
+	var branches []BranchInfo
+	for name := range names {
+		hash, err := repo.ReadRef(headsPrefix + name)
+		if err != nil {
+			continue
+		}
+
+		branches = append(branches, BranchInfo{
+			Name:      name,
+			Hash:      hash,
+			IsCurrent: name == current,
+			Upstream:  upstreams[name],
+		})
+	}
+
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Name < branches[j].Name
+	})
+
+	return branches, nil
+}
+
+// Rename moves refs/heads/<old> to refs/heads/<new>, updating HEAD if it
+// pointed at the old branch
+func Rename(repo *repository.Repository, oldName, newName string) error {
+	if !repo.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	if err := validateName(newName); err != nil {
+		return errors.NewGitError("branch", newName, err)
+	}
+
+	oldRef := headsPrefix + oldName
+	newRef := headsPrefix + newName
+
+	hash, err := repo.ReadRef(oldRef)
+	if err != nil {
+		return errors.NewGitError("branch", oldName, errors.ErrBranchNotFound)
+	}
+
+	if _, err := repo.ReadRef(newRef); err == nil {
+		return errors.NewGitError("branch", newName, errors.ErrBranchAlreadyExists)
+	}
+
+	// carry the reflog over before the update so the rename is appended to it
+	oldLog := filepath.Join(repo.GitDir, logsDir, oldRef)
+	newLog := filepath.Join(repo.GitDir, logsDir, newRef)
+	if _, err := os.Stat(oldLog); err == nil {
+		if err := os.MkdirAll(filepath.Dir(newLog), defaultDirMode); err != nil {
+			return errors.NewGitError("branch", newRef, err)
+		}
+		if err := os.Rename(oldLog, newLog); err != nil {
+			return errors.NewGitError("branch", newRef, err)
+		}
+	}
+
+	message := fmt.Sprintf("Branch: renamed %s to %s", oldRef, newRef)
+	if err := repo.UpdateRefWithMessage(newRef, hash, message); err != nil {
+		return errors.NewGitError("branch", newRef, err)
+	}
+
+	if err := repo.DeleteRef(oldRef, hash); err != nil {
+		return errors.NewGitError("branch", oldRef, err)
+	}
+
+	if current, err := repo.GetCurrentBranch(); err == nil && current == oldName {
+		if err := repo.SetHead(newRef, message); err != nil {
+			return errors.NewGitError("branch", headRef, err)
+		}
+	}
+
+	return nil
+}
+
+// validateName checks name as a ref under refs/heads. HEAD and names that
+// would read as an option are refused as well, as git does.
+func validateName(name string) error {
+	if name == headRef || strings.HasPrefix(name, "-") {
+		return errors.ErrInvalidBranchName
+	}
+	if repository.CheckRefName(headsPrefix+name) != nil {
+		return errors.ErrInvalidBranchName
+	}
+	return nil
+}
+
+func readPackedBranches(repo *repository.Repository) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(repo.GitDir, packedRefsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(parts) == 2 && strings.HasPrefix(parts[1], headsPrefix) {
+			names = append(names, strings.TrimPrefix(parts[1], headsPrefix))
+		}
+	}
+
+	return names, nil
+}
+
+// readUpstreams maps branch names to "<remote>/<branch>" from [branch "..."] config sections
+func readUpstreams(repo *repository.Repository) (map[string]string, error) {
+	cfg, err := config.Load(filepath.Join(repo.GitDir, configFile))
+	if err != nil {
+		return nil, err
+	}
+
+	upstreams := make(map[string]string)
+	for _, name := range cfg.Subsections("branch") {
+		remote, hasRemote := cfg.Get("branch", name, "remote")
+		merge, hasMerge := cfg.Get("branch", name, "merge")
+		if hasRemote && hasMerge {
+			upstreams[name] = remote + "/" + strings.TrimPrefix(merge, headsPrefix)
+		}
+	}
+
+	return upstreams, nil
+}
@@ -0,0 +1,233 @@
+package branch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	giterrors "github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+func setupTestRepo(t *testing.T) (*repository.Repository, string) {
+	tempDir := t.TempDir()
+	repo := repository.New(tempDir)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commitHash := createCommit(t, repo, nil, "Initial commit")
+	if err := repo.UpdateRef("refs/heads/main", commitHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	return repo, commitHash
+}
+
+func createCommit(t *testing.T, repo *repository.Repository, parents []string, message string) string {
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte(message)))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+
+	tree := objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "file.txt", Hash: blobHash},
+	})
+	treeHash, err := repo.StoreObject(tree)
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+
+	author := &objects.Signature{
+		Name:  "Test Author",
+		Email: "test@example.com",
+		When:  time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, author, author, message))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+
+	return commitHash
+}
+
+func TestCreate(t *testing.T) {
+	repo, head := setupTestRepo(t)
+
+	if err := Create(repo, "feature", ""); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	hash, err := repo.ReadRef("refs/heads/feature")
+	if err != nil {
+		t.Fatalf("Failed to read new branch: %v", err)
+	}
+	if hash != head {
+		t.Errorf("Expected branch at %s, got %s", head, hash)
+	}
+
+	if err := Create(repo, "feature", ""); !errors.Is(err, giterrors.ErrBranchAlreadyExists) {
+		t.Errorf("Expected ErrBranchAlreadyExists, got %v", err)
+	}
+}
+
+func TestCreate_FromStartPoint(t *testing.T) {
+	repo, head := setupTestRepo(t)
+	second := createCommit(t, repo, []string{head}, "Second commit")
+	if err := repo.UpdateRef("refs/heads/main", second); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	if err := Create(repo, "old", head); err != nil {
+		t.Fatalf("Create from hash failed: %v", err)
+	}
+	if err := Create(repo, "copy", "main"); err != nil {
+		t.Fatalf("Create from branch failed: %v", err)
+	}
+
+	if hash, _ := repo.ReadRef("refs/heads/old"); hash != head {
+		t.Errorf("Expected old at %s, got %s", head, hash)
+	}
+	if hash, _ := repo.ReadRef("refs/heads/copy"); hash != second {
+		t.Errorf("Expected copy at %s, got %s", second, hash)
+	}
+
+	if err := Create(repo, "parent", "main~1"); err != nil {
+		t.Fatalf("Create from revision failed: %v", err)
+	}
+	if hash, _ := repo.ReadRef("refs/heads/parent"); hash != head {
+		t.Errorf("Expected parent at %s, got %s", head, hash)
+	}
+
+	if err := Create(repo, "bad", "does-not-exist"); err == nil {
+		t.Error("Expected error for unknown start point")
+	}
+}
+
+func TestCreate_InvalidName(t *testing.T) {
+	repo, _ := setupTestRepo(t)
+
+	for _, name := range []string{"", "-x", "a..b", "a b", "name.lock", "a~1", "HEAD", "dir/"} {
+		if err := Create(repo, name, ""); !errors.Is(err, giterrors.ErrInvalidBranchName) {
+			t.Errorf("Expected ErrInvalidBranchName for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	repo, _ := setupTestRepo(t)
+
+	if err := Create(repo, "merged", ""); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := Delete(repo, "merged", false); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(repo.GitDir, "refs", "heads", "merged")); !os.IsNotExist(err) {
+		t.Error("Expected branch ref to be removed")
+	}
+}
+
+func TestDelete_Packed(t *testing.T) {
+	repo, head := setupTestRepo(t)
+
+	packed := head + " refs/heads/packed\n"
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "packed-refs"), []byte(packed), 0644); err != nil {
+		t.Fatalf("Failed to write packed-refs: %v", err)
+	}
+
+	if err := Delete(repo, "packed", false); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := repo.ReadRef("refs/heads/packed"); err == nil {
+		t.Error("Expected packed branch to be removed")
+	}
+}
+
+func TestDelete_CurrentBranch(t *testing.T) {
+	repo, _ := setupTestRepo(t)
+
+	if err := Delete(repo, "main", true); err == nil {
+		t.Error("Expected error deleting the current branch")
+	}
+}
+
+func TestDelete_Unmerged(t *testing.T) {
+	repo, head := setupTestRepo(t)
+
+	side := createCommit(t, repo, []string{head}, "Side commit")
+	if err := repo.UpdateRef("refs/heads/side", side); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	if err := Delete(repo, "side", false); !errors.Is(err, giterrors.ErrBranchNotMerged) {
+		t.Fatalf("Expected ErrBranchNotMerged, got %v", err)
+	}
+
+	if err := Delete(repo, "side", true); err != nil {
+		t.Fatalf("Forced delete failed: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	repo, head := setupTestRepo(t)
+
+	if err := Create(repo, "feature/login", ""); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	config := "[branch \"main\"]\n\tremote = origin\n\tmerge = refs/heads/main\n"
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	branches, err := List(repo)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	if len(branches) != 2 {
+		t.Fatalf("Expected 2 branches, got %d", len(branches))
+	}
+
+	if branches[0].Name != "feature/login" || branches[0].IsCurrent {
+		t.Errorf("Unexpected first branch: %+v", branches[0])
+	}
+
+	main := branches[1]
+	if main.Name != "main" || !main.IsCurrent || main.Hash != head {
+		t.Errorf("Unexpected main branch: %+v", main)
+	}
+	if main.Upstream != "origin/main" {
+		t.Errorf("Expected upstream 'origin/main', got %q", main.Upstream)
+	}
+}
+
+func TestRename(t *testing.T) {
+	repo, head := setupTestRepo(t)
+
+	if err := Rename(repo, "main", "trunk"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	if hash, err := repo.ReadRef("refs/heads/trunk"); err != nil || hash != head {
+		t.Errorf("Expected trunk at %s, got %s (%v)", head, hash, err)
+	}
+
+	if _, err := repo.ReadRef("refs/heads/main"); err == nil {
+		t.Error("Expected old branch to be gone")
+	}
+
+	current, err := repo.GetCurrentBranch()
+	if err != nil {
+		t.Fatalf("GetCurrentBranch failed: %v", err)
+	}
+	if current != "trunk" {
+		t.Errorf("Expected HEAD to follow rename, got %q", current)
+	}
+}
@@ -0,0 +1,81 @@
+package catfile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// CatMode selects what Show prints about an object
+type CatMode int
+
+const (
+	// CatType prints the object's type, like -t
+	CatType CatMode = iota
+	// CatSize prints the size of the object's content in bytes, like -s
+	CatSize
+	// CatPretty prints the content, with trees listed one entry per line, like -p
+	CatPretty
+)
+
+// Show returns what mode asks for about the object name resolves to. name
+// may be a full or abbreviated hash or any revision Resolve accepts.
+func Show(repo *repository.Repository, name string, mode CatMode) (string, error) {
+	if !repo.Exists() {
+		return "", errors.ErrNotGitRepository
+	}
+
+	hash, err := revparse.Resolve(repo, name)
+	if err != nil {
+		return "", err
+	}
+
+	typ, content, err := repo.ReadObjectData(hash)
+	if err != nil {
+		return "", errors.NewObjectError(hash, "", err)
+	}
+
+	switch mode {
+	case CatType:
+		return typ.String() + "\n", nil
+	case CatSize:
+		return fmt.Sprintf("%d\n", len(content)), nil
+	case CatPretty:
+		if typ != objects.ObjectTypeTree {
+			// blobs print as they are; commits and tags are already text
+			return string(content), nil
+		}
+		tree, err := repo.LoadTree(hash)
+		if err != nil {
+			return "", err
+		}
+		return FormatTree(tree), nil
+	default:
+		return "", errors.NewGitError("cat-file", name, fmt.Errorf("unknown mode %d", mode))
+	}
+}
+
+// FormatTree lists a tree's entries as "<mode> <type> <hash>\t<name>" lines
+func FormatTree(tree *objects.Tree) string {
+	var buf strings.Builder
+	for _, entry := range tree.Entries() {
+		fmt.Fprintf(&buf, "%s %s %s\t%s\n", entry.Mode, entryType(entry), entry.Hash, entry.Name)
+	}
+	return buf.String()
+}
+
+// entryType is the type of object a tree entry points at
+func entryType(entry objects.TreeEntry) objects.ObjectType {
+	switch {
+	case entry.IsDir():
+		return objects.ObjectTypeTree
+	case entry.IsSubmodule():
+		return objects.ObjectTypeCommit
+	default:
+		return objects.ObjectTypeBlob
+	}
+}
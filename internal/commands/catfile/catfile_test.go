@@ -0,0 +1,118 @@
+package catfile
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/gc"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+type testRepo struct {
+	repo    *repository.Repository
+	blob    string
+	script  string
+	subtree string
+	tree    string
+	commit  string
+}
+
+func setupTestRepo(t *testing.T) *testRepo {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	store := func(obj objects.Object) string {
+		h, err := repo.StoreObject(obj)
+		if err != nil {
+			t.Fatalf("Failed to store %s: %v", obj.Type(), err)
+		}
+		return h
+	}
+
+	tr := &testRepo{repo: repo}
+	tr.blob = store(objects.NewBlob([]byte("hello\n")))
+	tr.script = store(objects.NewBlob([]byte("#!/bin/sh\n")))
+	tr.subtree = store(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "inner.txt", Hash: tr.blob},
+	}))
+	tr.tree = store(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeTree, Name: "dir", Hash: tr.subtree},
+		{Mode: objects.FileModeBlob, Name: "hello.txt", Hash: tr.blob},
+		{Mode: objects.FileModeExecutable, Name: "run.sh", Hash: tr.script},
+	}))
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)}
+	tr.commit = store(objects.NewCommit(tr.tree, nil, sig, sig, "initial\n"))
+
+	if err := repo.UpdateRef("refs/heads/main", tr.commit); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	return tr
+}
+
+func show(t *testing.T, repo *repository.Repository, name string, mode CatMode) string {
+	out, err := Show(repo, name, mode)
+	if err != nil {
+		t.Fatalf("Show(%s, %d) failed: %v", name, mode, err)
+	}
+	return out
+}
+
+func TestShow_TreeListing(t *testing.T) {
+	tr := setupTestRepo(t)
+
+	// the layout git cat-file -p prints for a tree
+	expected := fmt.Sprintf("040000 tree %s\tdir\n100644 blob %s\thello.txt\n100755 blob %s\trun.sh\n",
+		tr.subtree, tr.blob, tr.script)
+	if got := show(t, tr.repo, tr.tree, CatPretty); got != expected {
+		t.Errorf("Expected tree listing\n%q\ngot\n%q", expected, got)
+	}
+}
+
+func TestShow_Modes(t *testing.T) {
+	tr := setupTestRepo(t)
+
+	if got := show(t, tr.repo, tr.blob, CatType); got != "blob\n" {
+		t.Errorf("Expected blob type, got %q", got)
+	}
+	if got := show(t, tr.repo, tr.blob, CatSize); got != "6\n" {
+		t.Errorf("Expected size 6, got %q", got)
+	}
+	if got := show(t, tr.repo, tr.blob, CatPretty); got != "hello\n" {
+		t.Errorf("Expected raw blob content, got %q", got)
+	}
+
+	if got := show(t, tr.repo, "main", CatType); got != "commit\n" {
+		t.Errorf("Expected a ref to resolve to its commit, got %q", got)
+	}
+	expected := fmt.Sprintf("tree %s\nauthor Test <test@example.com> 1672574400 +0000\n"+
+		"committer Test <test@example.com> 1672574400 +0000\n\ninitial\n", tr.tree)
+	if got := show(t, tr.repo, tr.commit[:7], CatPretty); got != expected {
+		t.Errorf("Expected commit text\n%q\ngot\n%q", expected, got)
+	}
+
+	if _, err := Show(tr.repo, "0000000000000000000000000000000000000001", CatType); err == nil {
+		t.Error("Expected an error for a missing object")
+	}
+}
+
+func TestShow_PackedObjects(t *testing.T) {
+	tr := setupTestRepo(t)
+
+	if _, err := gc.Run(tr.repo, gc.GCOptions{}); err != nil {
+		t.Fatalf("gc failed: %v", err)
+	}
+	if _, err := tr.repo.LooseObjectInfo(tr.tree); err == nil {
+		t.Fatal("Expected the tree to be packed")
+	}
+
+	if got := show(t, tr.repo, tr.tree[:8], CatType); got != "tree\n" {
+		t.Errorf("Expected tree type, got %q", got)
+	}
+	if got := show(t, tr.repo, tr.subtree, CatPretty); got != fmt.Sprintf("100644 blob %s\tinner.txt\n", tr.blob) {
+		t.Errorf("Unexpected packed tree listing %q", got)
+	}
+}
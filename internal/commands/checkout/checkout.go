@@ -0,0 +1,406 @@
+package checkout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	defaultDirMode = 0755
+	headFile       = "HEAD"
+	headsPrefix    = "refs/heads/"
+)
+
+type CheckoutResult struct {
+	Branch       string
+	Commit       string
+	Detached     bool
+	UpdatedFiles []string
+	RemovedFiles []string
+}
+
+// ConflictError lists the paths whose local changes block a checkout
+type ConflictError struct {
+	Paths []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v:\n\t%s\nplease commit your changes or stash them before you switch branches",
+		errors.ErrLocalChanges, strings.Join(e.Paths, "\n\t"))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return errors.ErrLocalChanges
+}
+
+// SwitchBranch checks out a branch, or detaches HEAD when name is not a
+// branch but resolves to a commit. Only files that differ between the
+// current and target trees are touched.
+func SwitchBranch(repo *repository.Repository, name string) (*CheckoutResult, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	result := &CheckoutResult{}
+
+	targetHash, err := repo.ReadRef(headsPrefix + name)
+	if err == nil {
+		result.Branch = name
+	} else {
+		targetHash, err = revparse.ResolveCommit(repo, name)
+		if err != nil {
+			return nil, errors.NewGitError("checkout", name, fmt.Errorf("pathspec '%s' did not match any branch or commit: %w", name, err))
+		}
+		result.Detached = true
+	}
+	result.Commit = targetHash
+
+	targetFiles, err := CommitFiles(repo, targetHash)
+	if err != nil {
+		return nil, errors.NewGitError("checkout", name, err)
+	}
+
+	currentHash, err := repo.GetHead()
+	if err != nil {
+		return nil, errors.NewGitError("checkout", headFile, err)
+	}
+
+	currentFiles := make(map[string]objects.TreeEntry)
+	if currentHash != "" {
+		currentFiles, err = CommitFiles(repo, currentHash)
+		if err != nil {
+			return nil, errors.NewGitError("checkout", headFile, err)
+		}
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	changes := DiffFiles(currentFiles, targetFiles)
+
+	if conflicts := FindConflicts(repo, idx, currentFiles, targetFiles, changes); len(conflicts) > 0 {
+		return nil, &ConflictError{Paths: conflicts}
+	}
+
+	result.UpdatedFiles, result.RemovedFiles, err = ApplyFiles(repo, idx, targetFiles, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := idx.Save(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("save index: %w", err))
+	}
+
+	headTarget := targetHash
+	if !result.Detached {
+		headTarget = headsPrefix + name
+	}
+
+	message := fmt.Sprintf("checkout: moving from %s to %s", currentName(repo, currentHash), name)
+	if err := repo.SetHead(headTarget, message); err != nil {
+		return nil, errors.NewGitError("checkout", headFile, err)
+	}
+
+	return result, nil
+}
+
+// ApplyFiles brings each path in the working tree and idx to its entry in
+// target, deleting paths target lacks. Deletions go first, so a file that
+// becomes a directory, or a directory that becomes a file, is out of the way
+// before its replacement is written. It does not check for local changes
+// and does not save idx.
+func ApplyFiles(repo *repository.Repository, idx *index.Index, target map[string]objects.TreeEntry, paths []string) (updated, removed []string, err error) {
+	for _, path := range paths {
+		if _, inTarget := target[path]; inTarget {
+			continue
+		}
+		if err := removeFile(repo, path); err != nil {
+			return nil, nil, errors.NewGitError("checkout", path, err)
+		}
+		idx.Remove(path)
+		removed = append(removed, path)
+	}
+
+	for _, path := range paths {
+		entry, inTarget := target[path]
+		if !inTarget {
+			continue
+		}
+
+		info, err := writeFile(repo, path, entry)
+		if err != nil {
+			return nil, nil, errors.NewGitError("checkout", path, err)
+		}
+
+		if err := idx.AddWithFileInfo(path, entry.Hash, uint32(entry.Mode), info); err != nil {
+			return nil, nil, errors.NewIndexError(path, err)
+		}
+		updated = append(updated, path)
+	}
+
+	return updated, removed, nil
+}
+
+// currentName describes HEAD for reflog messages: the branch name, or the
+// commit hash when detached
+func currentName(repo *repository.Repository, currentHash string) string {
+	if branch, err := repo.GetCurrentBranch(); err == nil {
+		return branch
+	}
+	return currentHash
+}
+
+// CommitFiles flattens the tree of a commit into a path -> entry map
+func CommitFiles(repo *repository.Repository, commitHash string) (map[string]objects.TreeEntry, error) {
+	obj, err := repo.LoadObject(commitHash)
+	if err != nil {
+		return nil, errors.NewObjectError(commitHash, "commit", err)
+	}
+
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return nil, errors.NewObjectError(commitHash, "commit", errors.ErrInvalidCommit)
+	}
+
+	return TreeFiles(repo, commit.Tree())
+}
+
+// TreeFiles flattens a tree into a path -> entry map
+func TreeFiles(repo *repository.Repository, treeHash string) (map[string]objects.TreeEntry, error) {
+	files := make(map[string]objects.TreeEntry)
+	if err := collectFiles(repo, treeHash, "", files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// WriteTree stores a path -> entry map as nested trees, the inverse of
+// TreeFiles, and returns the hash of the root tree
+func WriteTree(repo *repository.Repository, files map[string]objects.TreeEntry) (string, error) {
+	var entries []objects.TreeEntry
+	dirs := make(map[string]map[string]objects.TreeEntry)
+	for path, entry := range files {
+		dir, rest, nested := strings.Cut(path, "/")
+		if !nested {
+			entry.Name = path
+			entries = append(entries, entry)
+			continue
+		}
+		if dirs[dir] == nil {
+			dirs[dir] = make(map[string]objects.TreeEntry)
+		}
+		dirs[dir][rest] = entry
+	}
+
+	for dir, dirFiles := range dirs {
+		treeHash, err := WriteTree(repo, dirFiles)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, objects.TreeEntry{Mode: objects.FileModeTree, Name: dir, Hash: treeHash})
+	}
+
+	// git orders a directory as if its name ended in a slash
+	sortName := func(entry objects.TreeEntry) string {
+		if entry.Mode == objects.FileModeTree {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortName(entries[i]) < sortName(entries[j])
+	})
+
+	return repo.StoreObject(objects.NewTree(entries))
+}
+
+func collectFiles(repo *repository.Repository, treeHash, prefix string, files map[string]objects.TreeEntry) error {
+	obj, err := repo.LoadObject(treeHash)
+	if err != nil {
+		return errors.NewObjectError(treeHash, "tree", err)
+	}
+
+	tree, ok := obj.(*objects.Tree)
+	if !ok {
+		return errors.NewObjectError(treeHash, "tree", errors.ErrInvalidTree)
+	}
+
+	for _, entry := range tree.Entries() {
+		path := entry.Name
+		if prefix != "" {
+			path = prefix + "/" + entry.Name
+		}
+
+		if entry.IsDir() {
+			if err := collectFiles(repo, entry.Hash, path, files); err != nil {
+				return err
+			}
+			continue
+		}
+
+		files[path] = entry
+	}
+
+	return nil
+}
+
+// IndexFiles returns the entries of idx as a path -> entry map comparable
+// with CommitFiles
+func IndexFiles(idx *index.Index) map[string]objects.TreeEntry {
+	files := make(map[string]objects.TreeEntry)
+	for path, entry := range idx.GetAllEntries() {
+		files[path] = objects.TreeEntry{Mode: objects.FileMode(entry.Mode), Name: path, Hash: entry.Hash}
+	}
+	return files
+}
+
+// DiffFiles returns the sorted paths whose entry differs between the two trees
+func DiffFiles(current, target map[string]objects.TreeEntry) []string {
+	var changed []string
+
+	for path, entry := range target {
+		if cur, ok := current[path]; !ok || cur.Hash != entry.Hash || cur.Mode != entry.Mode {
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range current {
+		if _, ok := target[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+// FindConflicts returns changed paths whose index or working copy differs from
+// the current commit, plus untracked files the target tree would overwrite
+func FindConflicts(repo *repository.Repository, idx *index.Index, current, target map[string]objects.TreeEntry, changes []string) []string {
+	var conflicts []string
+
+	for _, path := range changes {
+		cur, inCurrent := current[path]
+		tgt, inTarget := target[path]
+		idxEntry, inIndex := idx.Get(path)
+
+		workHash, workExists := WorkingHash(repo, path)
+
+		if !inCurrent {
+			// untracked or newly staged file that the target also has
+			if workExists && (!inTarget || workHash != tgt.Hash) {
+				conflicts = append(conflicts, path)
+			} else if inIndex && (!inTarget || idxEntry.Hash != tgt.Hash) {
+				conflicts = append(conflicts, path)
+			}
+			continue
+		}
+
+		if !inIndex || idxEntry.Hash != cur.Hash {
+			conflicts = append(conflicts, path)
+			continue
+		}
+
+		if workExists && workHash != cur.Hash {
+			conflicts = append(conflicts, path)
+		}
+	}
+
+	return conflicts
+}
+
+// WorkingHash returns the blob hash of a working tree file as it would be
+// staged, and false when the file cannot be read. A symlink hashes as its
+// target path
+func WorkingHash(repo *repository.Repository, path string) (string, bool) {
+	fullPath := filepath.Join(repo.WorkDir, filepath.FromSlash(path))
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return "", false
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return "", false
+		}
+		return hash.ComputeObjectHash(string(objects.ObjectTypeBlob), []byte(filepath.ToSlash(target))), true
+	}
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", false
+	}
+	if autoCRLF, err := repo.AutoCRLFFor(path); err == nil {
+		content = autoCRLF.ToIndex(content)
+	}
+	return hash.ComputeObjectHash(string(objects.ObjectTypeBlob), content), true
+}
+
+func writeFile(repo *repository.Repository, path string, entry objects.TreeEntry) (os.FileInfo, error) {
+	fullPath := filepath.Join(repo.WorkDir, filepath.FromSlash(path))
+	if err := clearPath(fullPath, entry.IsSubmodule()); err != nil {
+		return nil, err
+	}
+	if entry.IsSubmodule() {
+		if err := os.MkdirAll(fullPath, defaultDirMode); err != nil {
+			return nil, fmt.Errorf("create submodule directory: %w", err)
+		}
+		return os.Stat(fullPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), defaultDirMode); err != nil {
+		return nil, fmt.Errorf("create parent directory: %w", err)
+	}
+
+	if err := repo.WriteEntryFile(entry.Hash, fullPath, entry.Mode); err != nil {
+		return nil, errors.NewObjectError(entry.Hash, "blob", fmt.Errorf("write file: %w", err))
+	}
+
+	return os.Lstat(fullPath)
+}
+
+// clearPath makes way for an entry at fullPath: an empty directory where a
+// file goes, or a file where a directory goes. A directory that still holds
+// files is left in place and reported.
+func clearPath(fullPath string, dir bool) error {
+	info, err := os.Lstat(fullPath)
+	if err != nil || info.IsDir() == dir {
+		return nil
+	}
+	if err := os.Remove(fullPath); err != nil {
+		return fmt.Errorf("remove the entry in the way: %w", err)
+	}
+	return nil
+}
+
+// removeFile deletes a tracked file and any parent directories left empty.
+// A submodule's directory is only removed when it is empty, so a checked-out
+// submodule is left in place.
+func removeFile(repo *repository.Repository, path string) error {
+	fullPath := filepath.Join(repo.WorkDir, filepath.FromSlash(path))
+	if info, err := os.Lstat(fullPath); err == nil && info.IsDir() {
+		os.Remove(fullPath)
+	} else if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove file: %w", err)
+	}
+
+	for dir := filepath.Dir(fullPath); dir != repo.WorkDir && strings.HasPrefix(dir, repo.WorkDir); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+
+	return nil
+}
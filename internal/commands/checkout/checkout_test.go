@@ -0,0 +1,399 @@
+package checkout
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	giterrors "github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// storeCommit stores the given files as a nested tree and returns the commit hash
+func storeCommit(t *testing.T, repo *repository.Repository, files map[string]string, parents []string) string {
+	t.Helper()
+
+	type dir struct {
+		entries []objects.TreeEntry
+	}
+	dirs := map[string]*dir{"": {}}
+	var dirNames []string
+
+	for path, content := range files {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+
+		parent := filepath.ToSlash(filepath.Dir(path))
+		if parent == "." {
+			parent = ""
+		}
+		if dirs[parent] == nil {
+			dirs[parent] = &dir{}
+			dirNames = append(dirNames, parent)
+		}
+		dirs[parent].entries = append(dirs[parent].entries, objects.TreeEntry{
+			Mode: objects.FileModeBlob, Name: filepath.Base(path), Hash: blobHash,
+		})
+	}
+
+	// test fixtures only use a single directory level
+	for _, name := range dirNames {
+		subHash, err := repo.StoreObject(objects.NewTree(dirs[name].entries))
+		if err != nil {
+			t.Fatalf("Failed to store subtree: %v", err)
+		}
+		dirs[""].entries = append(dirs[""].entries, objects.TreeEntry{
+			Mode: objects.FileModeTree, Name: name, Hash: subHash,
+		})
+	}
+
+	treeHash, err := repo.StoreObject(objects.NewTree(dirs[""].entries))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+
+	author := &objects.Signature{
+		Name:  "Test Author",
+		Email: "test@example.com",
+		When:  time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, author, author, "commit"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+
+	return commitHash
+}
+
+// setupTestRepo creates main checked out with two files and a feature branch
+// that modifies one, deletes the other and adds a nested file
+func setupTestRepo(t *testing.T) (*repository.Repository, string, string) {
+	tempDir := t.TempDir()
+	repo := repository.New(tempDir)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	mainFiles := map[string]string{
+		"shared.txt":  "shared",
+		"changed.txt": "main version",
+		"removed.txt": "only on main",
+	}
+	mainHash := storeCommit(t, repo, mainFiles, nil)
+
+	featureHash := storeCommit(t, repo, map[string]string{
+		"shared.txt":  "shared",
+		"changed.txt": "feature version",
+		"pkg/new.txt": "new file",
+	}, []string{mainHash})
+
+	if err := repo.UpdateRef("refs/heads/main", mainHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/feature", featureHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	idx := index.New(repo.GitDir)
+	for path, content := range mainFiles {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		info, _ := os.Stat(fullPath)
+		blobHash, _ := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err := idx.AddWithFileInfo(path, blobHash, uint32(objects.FileModeBlob), info); err != nil {
+			t.Fatalf("Failed to add to index: %v", err)
+		}
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	return repo, mainHash, featureHash
+}
+
+func readFile(t *testing.T, repo *repository.Repository, path string) string {
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, path))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestSwitchBranch(t *testing.T) {
+	repo, _, featureHash := setupTestRepo(t)
+
+	sharedPath := filepath.Join(repo.WorkDir, "shared.txt")
+	before, err := os.Stat(sharedPath)
+	if err != nil {
+		t.Fatalf("Failed to stat shared file: %v", err)
+	}
+
+	result, err := SwitchBranch(repo, "feature")
+	if err != nil {
+		t.Fatalf("SwitchBranch failed: %v", err)
+	}
+
+	if result.Branch != "feature" || result.Detached || result.Commit != featureHash {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if got := readFile(t, repo, "changed.txt"); got != "feature version" {
+		t.Errorf("Expected 'feature version', got %q", got)
+	}
+	if got := readFile(t, repo, "pkg/new.txt"); got != "new file" {
+		t.Errorf("Expected 'new file', got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "removed.txt")); !os.IsNotExist(err) {
+		t.Error("Expected removed.txt to be deleted")
+	}
+
+	after, err := os.Stat(sharedPath)
+	if err != nil {
+		t.Fatalf("Failed to stat shared file: %v", err)
+	}
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Error("Expected unchanged file to be left untouched")
+	}
+
+	if len(result.UpdatedFiles) != 2 || len(result.RemovedFiles) != 1 {
+		t.Errorf("Expected 2 updated and 1 removed, got %v and %v", result.UpdatedFiles, result.RemovedFiles)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if _, ok := idx.Get("removed.txt"); ok {
+		t.Error("Expected removed.txt to be dropped from index")
+	}
+	if _, ok := idx.Get("pkg/new.txt"); !ok {
+		t.Error("Expected pkg/new.txt in index")
+	}
+
+	branch, err := repo.GetCurrentBranch()
+	if err != nil || branch != "feature" {
+		t.Errorf("Expected current branch 'feature', got %q (%v)", branch, err)
+	}
+
+	// and back again
+	if _, err := SwitchBranch(repo, "main"); err != nil {
+		t.Fatalf("SwitchBranch back failed: %v", err)
+	}
+	if got := readFile(t, repo, "removed.txt"); got != "only on main" {
+		t.Errorf("Expected removed.txt restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "pkg")); !os.IsNotExist(err) {
+		t.Error("Expected empty pkg directory to be removed")
+	}
+}
+
+func TestSwitchBranch_RefusesToOverwriteChanges(t *testing.T) {
+	repo, _, _ := setupTestRepo(t)
+
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "changed.txt"), []byte("local edit"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	_, err := SwitchBranch(repo, "feature")
+	if !errors.Is(err, giterrors.ErrLocalChanges) {
+		t.Fatalf("Expected ErrLocalChanges, got %v", err)
+	}
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || len(conflict.Paths) != 1 || conflict.Paths[0] != "changed.txt" {
+		t.Errorf("Expected conflict on changed.txt, got %v", err)
+	}
+
+	if got := readFile(t, repo, "changed.txt"); got != "local edit" {
+		t.Errorf("Expected local edit preserved, got %q", got)
+	}
+
+	branch, _ := repo.GetCurrentBranch()
+	if branch != "main" {
+		t.Errorf("Expected to stay on main, got %q", branch)
+	}
+}
+
+func TestSwitchBranch_KeepsUnrelatedChanges(t *testing.T) {
+	repo, _, _ := setupTestRepo(t)
+
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "shared.txt"), []byte("local edit"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	if _, err := SwitchBranch(repo, "feature"); err != nil {
+		t.Fatalf("SwitchBranch failed: %v", err)
+	}
+
+	if got := readFile(t, repo, "shared.txt"); got != "local edit" {
+		t.Errorf("Expected local edit carried over, got %q", got)
+	}
+}
+
+func TestSwitchBranch_UntrackedWouldBeOverwritten(t *testing.T) {
+	repo, _, _ := setupTestRepo(t)
+
+	if err := os.MkdirAll(filepath.Join(repo.WorkDir, "pkg"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "pkg", "new.txt"), []byte("untracked"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	_, err := SwitchBranch(repo, "feature")
+	if err == nil || !strings.Contains(err.Error(), "pkg/new.txt") {
+		t.Errorf("Expected conflict on pkg/new.txt, got %v", err)
+	}
+}
+
+func TestSwitchBranch_DetachedHead(t *testing.T) {
+	repo, _, featureHash := setupTestRepo(t)
+
+	result, err := SwitchBranch(repo, featureHash)
+	if err != nil {
+		t.Fatalf("SwitchBranch failed: %v", err)
+	}
+
+	if !result.Detached || result.Branch != "" {
+		t.Errorf("Expected detached result, got %+v", result)
+	}
+
+	content, err := os.ReadFile(filepath.Join(repo.GitDir, "HEAD"))
+	if err != nil {
+		t.Fatalf("Failed to read HEAD: %v", err)
+	}
+	if strings.TrimSpace(string(content)) != featureHash {
+		t.Errorf("Expected HEAD to contain %s, got %q", featureHash, content)
+	}
+
+	if got := readFile(t, repo, "changed.txt"); got != "feature version" {
+		t.Errorf("Expected 'feature version', got %q", got)
+	}
+}
+
+func TestSwitchBranch_Unknown(t *testing.T) {
+	repo, _, _ := setupTestRepo(t)
+
+	if _, err := SwitchBranch(repo, "nope"); err == nil {
+		t.Error("Expected error for unknown branch")
+	}
+}
+
+func TestSwitchBranch_FileAndDirectorySwap(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// HEAD stays on the unborn main until the first switch. "a" is a file on
+	// one and a directory on two; "d" is the other way round.
+	oneHash := storeCommit(t, repo, map[string]string{"a": "file a", "d/x": "in d"}, nil)
+	twoHash := storeCommit(t, repo, map[string]string{"a/b": "in a", "d": "file d"}, []string{oneHash})
+	if err := repo.UpdateRef("refs/heads/one", oneHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/two", twoHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	if _, err := SwitchBranch(repo, "one"); err != nil {
+		t.Fatalf("SwitchBranch to one failed: %v", err)
+	}
+	if _, err := SwitchBranch(repo, "two"); err != nil {
+		t.Fatalf("SwitchBranch to two failed: %v", err)
+	}
+	if got := readFile(t, repo, "a/b"); got != "in a" {
+		t.Errorf("Expected a/b to hold 'in a', got %q", got)
+	}
+	if got := readFile(t, repo, "d"); got != "file d" {
+		t.Errorf("Expected d to hold 'file d', got %q", got)
+	}
+
+	if _, err := SwitchBranch(repo, "one"); err != nil {
+		t.Fatalf("SwitchBranch back to one failed: %v", err)
+	}
+	if got := readFile(t, repo, "a"); got != "file a" {
+		t.Errorf("Expected a to hold 'file a', got %q", got)
+	}
+	if got := readFile(t, repo, "d/x"); got != "in d" {
+		t.Errorf("Expected d/x to hold 'in d', got %q", got)
+	}
+}
+
+func TestSwitchBranch_SymlinkTargets(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	storeBlob := func(content string) string {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		return blobHash
+	}
+	aHash := storeBlob("file a")
+	bHash := storeBlob("file b")
+
+	// both branches carry a.txt and b.txt; "link" points at a different one
+	storeLinkCommit := func(target string, parents []string) string {
+		treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+			{Mode: objects.FileModeBlob, Name: "a.txt", Hash: aHash},
+			{Mode: objects.FileModeBlob, Name: "b.txt", Hash: bHash},
+			{Mode: objects.FileModeSymlink, Name: "link", Hash: storeBlob(target)},
+		}))
+		if err != nil {
+			t.Fatalf("Failed to store tree: %v", err)
+		}
+		author := &objects.Signature{
+			Name:  "Test Author",
+			Email: "test@example.com",
+			When:  time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+		}
+		commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, author, author, "commit"))
+		if err != nil {
+			t.Fatalf("Failed to store commit: %v", err)
+		}
+		return commitHash
+	}
+	oneHash := storeLinkCommit("a.txt", nil)
+	twoHash := storeLinkCommit("b.txt", []string{oneHash})
+	if err := repo.UpdateRef("refs/heads/one", oneHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/two", twoHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	readLink := func() string {
+		t.Helper()
+		target, err := os.Readlink(filepath.Join(repo.WorkDir, "link"))
+		if err != nil {
+			t.Fatalf("Failed to read link: %v", err)
+		}
+		return target
+	}
+
+	for _, step := range []struct{ branch, target string }{
+		{"one", "a.txt"},
+		{"two", "b.txt"},
+		{"one", "a.txt"},
+	} {
+		if _, err := SwitchBranch(repo, step.branch); err != nil {
+			t.Fatalf("SwitchBranch to %s failed: %v", step.branch, err)
+		}
+		if got := readLink(); got != step.target {
+			t.Errorf("Expected link to point at %q on %s, got %q", step.target, step.branch, got)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/commands/fsck"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/internal/core/pack"
@@ -23,7 +24,6 @@ const (
 	gitSuffix           = ".git"
 	defaultDirMode      = 0755
 	defaultFileMode     = 0644
-	gitObjectNameLength = 38
 
 	// Default branch names
 	branchMain    = "main"
@@ -32,8 +32,10 @@ const (
 	branchTrunk   = "trunk"
 
 	// Git references
-	headsPrefix = "refs/heads/"
-	headRef     = "HEAD"
+	headsPrefix  = "refs/heads/"
+	tagsPrefix   = "refs/tags/"
+	peeledSuffix = "^{}"
+	headRef      = "HEAD"
 )
 
 type CloneOptions struct {
@@ -48,6 +50,13 @@ type CloneOptions struct {
 	Progress       bool
 	Timeout        time.Duration
 	ProgressWriter *os.File
+	// ResumeDir keeps the fetch response while it is received, so that a
+	// clone retried after an interrupted transfer does not fetch again what
+	// already arrived. It must lie outside the clone directory, which a
+	// failed clone removes.
+	ResumeDir string
+	// Tags selects the tags cloned along with the branches
+	Tags remote.TagMode
 }
 
 type CloneResult struct {
@@ -57,16 +66,29 @@ type CloneResult struct {
 	ClonedCommit  string
 	FetchedRefs   map[string]string
 	CheckedOut    bool
-	ObjectCount   int
+	// ObjectCount is the number of objects in the clone's object store
+	ObjectCount int
+	// CheckedOutFiles is the number of files written to the work tree
+	CheckedOutFiles int
 }
 
 type Cloner struct {
-	auth *remote.AuthConfig
+	auth     *remote.AuthConfig
+	progress pack.Progress
 }
 
 func NewCloner() *Cloner {
 	auth, _ := remote.LoadAuthConfig()
-	return &Cloner{auth: auth}
+	return &Cloner{auth: auth, progress: pack.NoProgress}
+}
+
+// WithProgress reports the transfer to progress. It returns c for chaining.
+func (c *Cloner) WithProgress(progress pack.Progress) *Cloner {
+	if progress == nil {
+		progress = pack.NoProgress
+	}
+	c.progress = progress
+	return c
 }
 
 func (c *Cloner) Clone(ctx context.Context, options CloneOptions) (*CloneResult, error) {
@@ -84,6 +106,9 @@ func (c *Cloner) Clone(ctx context.Context, options CloneOptions) (*CloneResult,
 	targetDir := options.Directory
 	if targetDir == "" {
 		targetDir = c.inferDirectoryName(options.URL)
+		if options.Bare {
+			targetDir += gitSuffix
+		}
 	}
 
 	absPath, err := filepath.Abs(targetDir)
@@ -91,7 +116,9 @@ func (c *Cloner) Clone(ctx context.Context, options CloneOptions) (*CloneResult,
 		return nil, fmt.Errorf("failed to resolve absolute path: %w", err)
 	}
 
-	if _, err := os.Stat(absPath); err == nil {
+	_, statErr := os.Stat(absPath)
+	existed := statErr == nil
+	if existed {
 		entries, err := os.ReadDir(absPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read target directory: %w", err)
@@ -106,6 +133,9 @@ func (c *Cloner) Clone(ctx context.Context, options CloneOptions) (*CloneResult,
 	}
 
 	repo := repository.New(absPath)
+	if options.Bare {
+		repo = repository.NewBare(absPath)
+	}
 	if err := repo.Init(); err != nil {
 		return nil, fmt.Errorf("failed to initialize repository: %w", err)
 	}
@@ -156,7 +186,15 @@ func (c *Cloner) Clone(ctx context.Context, options CloneOptions) (*CloneResult,
 	if options.SingleBranch {
 		wants = []string{commitHash}
 	} else {
-		for _, hash := range remoteRefs {
+		for refName, hash := range remoteRefs {
+			// tags are wanted below, or followed once the branches are in
+			if !strings.HasPrefix(refName, tagsPrefix) {
+				wants = append(wants, hash)
+			}
+		}
+	}
+	if options.Tags == remote.TagAll {
+		for _, hash := range remote.AdvertisedTags(remoteRefs) {
 			wants = append(wants, hash)
 		}
 	}
@@ -165,26 +203,75 @@ func (c *Cloner) Clone(ctx context.Context, options CloneOptions) (*CloneResult,
 		fmt.Fprintf(options.ProgressWriter, "Fetching objects...\n")
 	}
 
-	packReader, err := transport.FetchPack(ctx, wants, []string{})
+	var packReader remote.PackReader
+	var spooled string
+	if options.ResumeDir != "" {
+		var spool *os.File
+		spool, spooled, err = c.fetchResumable(ctx, transport, options.URL, options.ResumeDir, wants)
+		if spool != nil {
+			packReader = spool
+		}
+	} else {
+		packReader, err = transport.FetchPack(ctx, wants, []string{})
+	}
 	if err != nil {
+		discardClone(absPath, existed)
 		return nil, fmt.Errorf("failed to fetch pack: %w", err)
 	}
 	defer packReader.Close()
 
-	if err := c.processPack(repo, packReader); err != nil {
+	// a received response that turns out bad is no use to a retry either
+	discard := func() {
+		discardClone(absPath, existed)
+		if spooled != "" {
+			os.Remove(spooled)
+		}
+	}
+
+	if err := c.processPack(ctx, repo, packReader); err != nil {
+		discard()
 		return nil, fmt.Errorf("failed to process pack: %w", err)
 	}
 
-	result.ObjectCount = c.countObjects(repo)
+	// a pack missing objects would otherwise surface later as a failed
+	// checkout or log, in a repository that looks cloned
+	if err := fsck.CheckConnectivity(repo, wants); err != nil {
+		discard()
+		return nil, fmt.Errorf("remote sent an incomplete pack: %w", err)
+	}
+	if spooled != "" {
+		os.Remove(spooled)
+	}
+
+	tags := remote.FollowTags(remoteRefs, options.Tags, repo.HasObject)
+	if err := c.fetchTags(ctx, transport, repo, tags, remoteRefs); err != nil {
+		discardClone(absPath, existed)
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
 
-	if err := c.updateRemoteRefs(repo, remoteRefs, result.RemoteName, options.SingleBranch, defaultBranch); err != nil {
-		return nil, fmt.Errorf("failed to update remote refs: %w", err)
+	if result.ObjectCount, err = c.countObjects(repo); err != nil {
+		return nil, fmt.Errorf("failed to count objects: %w", err)
 	}
 
 	result.FetchedRefs = remoteRefs
 
-	if !options.Bare {
-		if err := c.createLocalBranch(repo, defaultBranch, commitHash); err != nil {
+	for name, hash := range tags {
+		if err := repo.UpdateRef(name, hash); err != nil {
+			discardClone(absPath, existed)
+			return nil, fmt.Errorf("failed to update ref %s: %w", name, err)
+		}
+	}
+
+	if options.Bare {
+		if err := c.copyBareRefs(repo, remoteRefs, options.SingleBranch, defaultBranch); err != nil {
+			return nil, fmt.Errorf("failed to update refs: %w", err)
+		}
+	} else {
+		if err := c.updateRemoteRefs(repo, remoteRefs, result.RemoteName, options.SingleBranch, defaultBranch); err != nil {
+			return nil, fmt.Errorf("failed to update remote refs: %w", err)
+		}
+
+		if err := c.createLocalBranch(repo, defaultBranch, commitHash, options.URL); err != nil {
 			return nil, fmt.Errorf("failed to create local branch: %w", err)
 		}
 
@@ -271,15 +358,57 @@ func (c *Cloner) determineDefaultBranch(remoteRefs map[string]string, preferredB
 	return ""
 }
 
-func (c *Cloner) processPack(repo *repository.Repository, packReader remote.PackReader) error {
-	processor := pack.NewPackProcessor(repo)
-	if err := processor.ProcessPack(packReader); err != nil {
+// fetchTags fetches the annotated tag objects of tags that did not come
+// with the branches, telling the remote it has the objects they tag
+func (c *Cloner) fetchTags(ctx context.Context, transport remote.Transport, repo *repository.Repository, tags, remoteRefs map[string]string) error {
+	var wants, haves []string
+	for name, hash := range tags {
+		if repo.HasObject(hash) {
+			continue
+		}
+		wants = append(wants, hash)
+		if target, ok := remoteRefs[name+peeledSuffix]; ok {
+			haves = append(haves, target)
+		}
+	}
+	if len(wants) == 0 {
+		return nil
+	}
+
+	packReader, err := transport.FetchPack(ctx, wants, haves)
+	if err != nil {
+		return err
+	}
+	defer packReader.Close()
+
+	return c.processPack(ctx, repo, packReader)
+}
+
+func (c *Cloner) processPack(ctx context.Context, repo *repository.Repository, packReader remote.PackReader) error {
+	processor := pack.NewPackProcessor(repo).WithProgress(c.progress)
+	if err := processor.ProcessPackContext(ctx, packReader); err != nil {
 		return fmt.Errorf("failed to process pack with full object transfer: %w", err)
 	}
 
 	return nil
 }
 
+// discardClone removes what a failed clone wrote to dir, leaving dir itself
+// when it existed beforehand
+func discardClone(dir string, existed bool) {
+	if !existed {
+		os.RemoveAll(dir)
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+}
+
 func (c *Cloner) updateRemoteRefs(repo *repository.Repository, remoteRefs map[string]string, remoteName string, singleBranch bool, defaultBranch string) error {
 	remoteRefsDir := filepath.Join(repo.GitDir, "refs", "remotes", remoteName)
 	if err := os.MkdirAll(remoteRefsDir, defaultDirMode); err != nil {
@@ -305,18 +434,38 @@ func (c *Cloner) updateRemoteRefs(repo *repository.Repository, remoteRefs map[st
 	return nil
 }
 
-func (c *Cloner) createLocalBranch(repo *repository.Repository, branchName, commitHash string) error {
-	branchRef := fmt.Sprintf("%s%s", headsPrefix, branchName)
-	if err := repo.UpdateRef(branchRef, commitHash); err != nil {
-		return fmt.Errorf("failed to create branch %s: %w", branchName, err)
+// copyBareRefs gives a bare clone the remote's branches under their own
+// names, with HEAD on the default branch; there are no remote-tracking refs
+// since nothing is checked out to track them
+func (c *Cloner) copyBareRefs(repo *repository.Repository, remoteRefs map[string]string, singleBranch bool, defaultBranch string) error {
+	for refName, hash := range remoteRefs {
+		if !strings.HasPrefix(refName, headsPrefix) {
+			continue
+		}
+		if singleBranch && strings.TrimPrefix(refName, headsPrefix) != defaultBranch {
+			continue
+		}
+
+		if err := repo.UpdateRef(refName, hash); err != nil {
+			return fmt.Errorf("failed to update ref %s: %w", refName, err)
+		}
 	}
 
-	headPath := filepath.Join(repo.GitDir, headRef)
-	headContent := fmt.Sprintf("ref: %s\n", branchRef)
-	if err := os.WriteFile(headPath, []byte(headContent), defaultFileMode); err != nil {
+	return repo.SetHead(headsPrefix+defaultBranch, "")
+}
+
+func (c *Cloner) createLocalBranch(repo *repository.Repository, branchName, commitHash, url string) error {
+	branchRef := fmt.Sprintf("%s%s", headsPrefix, branchName)
+
+	// point HEAD first so the branch creation is logged for HEAD too
+	if err := repo.SetHead(branchRef, ""); err != nil {
 		return fmt.Errorf("failed to update HEAD: %w", err)
 	}
 
+	if err := repo.UpdateRefWithMessage(branchRef, commitHash, "clone: from "+url); err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branchName, err)
+	}
+
 	return nil
 }
 
@@ -347,9 +496,7 @@ func (c *Cloner) checkoutBranch(repo *repository.Repository, commitHash string,
 		return err
 	}
 
-	for range updatedFiles {
-		result.ObjectCount++
-	}
+	result.CheckedOutFiles = len(updatedFiles)
 
 	if err := idx.Save(); err != nil {
 		return fmt.Errorf("failed to save index: %w", err)
@@ -358,22 +505,15 @@ func (c *Cloner) checkoutBranch(repo *repository.Repository, commitHash string,
 	return nil
 }
 
-func (c *Cloner) countObjects(repo *repository.Repository) int {
-	objectsDir := filepath.Join(repo.GitDir, "objects")
+// countObjects counts the objects stored in repo, loose and packed, each
+// once however many times it is stored
+func (c *Cloner) countObjects(repo *repository.Repository) (int, error) {
 	count := 0
-
-	filepath.Walk(objectsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if !info.IsDir() && len(filepath.Base(path)) == gitObjectNameLength {
-			// git object files are 38 characters (2 for directory + 38 for filename)
-			count++
-		}
+	err := repo.ForEachObject(func(string, objects.ObjectType) error {
+		count++
 		return nil
 	})
-
-	return count
+	return count, err
 }
 
 func DefaultCloneOptions() CloneOptions {
@@ -386,5 +526,6 @@ func DefaultCloneOptions() CloneOptions {
 		SingleBranch: false,
 		Progress:     true,
 		Timeout:      defaultCloneTimeout,
+		Tags:         remote.TagFollow,
 	}
 }
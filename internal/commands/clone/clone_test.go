@@ -2,13 +2,25 @@ package clone
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/push"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 func TestCloneOptions(t *testing.T) {
@@ -31,6 +43,11 @@ func TestCloner(t *testing.T) {
 		assert.NotNil(t, cloner)
 		assert.NotNil(t, cloner.auth)
 	})
+
+	t.Run("WithNilProgress", func(t *testing.T) {
+		cloner := NewCloner().WithProgress(nil)
+		assert.NotNil(t, cloner.progress)
+	})
 }
 
 func TestInferDirectoryName(t *testing.T) {
@@ -173,7 +190,242 @@ func TestCloneResult(t *testing.T) {
 		assert.Empty(t, result.FetchedRefs)
 		assert.False(t, result.CheckedOut)
 		assert.Equal(t, 0, result.ObjectCount)
+		assert.Equal(t, 0, result.CheckedOutFiles)
 		assert.Equal(t, "", result.DefaultBranch)
 		assert.Equal(t, "", result.ClonedCommit)
 	})
 }
+
+// newHTTPBackend serves the repositories under root over smart HTTP with
+// git http-backend, skipping the test when git is not installed
+func newHTTPBackend(t *testing.T, root string) *httptest.Server {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not installed")
+	}
+
+	server := httptest.NewServer(&cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		// REMOTE_USER lets http-backend accept pushes
+		Env: []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1", "REMOTE_USER=test"},
+	})
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBareRepositoryPushAndClone(t *testing.T) {
+	root := t.TempDir()
+	bare := repository.NewBare(filepath.Join(root, "repo.git"))
+	require.NoError(t, os.MkdirAll(bare.GitDir, 0755))
+	require.NoError(t, bare.Init())
+	assert.True(t, bare.Exists())
+	assert.FileExists(t, filepath.Join(bare.GitDir, "HEAD"))
+	assert.NoDirExists(t, filepath.Join(bare.GitDir, ".git"))
+
+	server := newHTTPBackend(t, root)
+	url := server.URL + "/repo.git"
+
+	local := repository.New(t.TempDir())
+	require.NoError(t, local.Init())
+	blob, err := local.StoreObject(objects.NewBlob([]byte("hello\n")))
+	require.NoError(t, err)
+	tree, err := local.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "a.txt", Hash: blob}}))
+	require.NoError(t, err)
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0).UTC()}
+	commit, err := local.StoreObject(objects.NewCommit(tree, nil, sig, sig, "initial\n"))
+	require.NoError(t, err)
+	require.NoError(t, local.UpdateRef("refs/heads/main", commit))
+	require.NoError(t, os.WriteFile(filepath.Join(local.GitDir, "config"),
+		[]byte("[remote \"origin\"]\n\turl = "+url+"\n"), 0644))
+
+	opts := push.DefaultPushOptions()
+	opts.Branch = "main"
+	_, err = push.NewPusher(local).Push(context.Background(), opts)
+	require.NoError(t, err)
+
+	pushed, err := bare.ReadRef("refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, commit, pushed)
+	head, err := bare.GetHead()
+	require.NoError(t, err)
+	assert.Equal(t, commit, head)
+
+	cloneOpts := DefaultCloneOptions()
+	cloneOpts.URL = url
+	cloneOpts.Directory = filepath.Join(t.TempDir(), "copy.git")
+	cloneOpts.Bare = true
+	cloneOpts.Progress = false
+	result, err := NewCloner().Clone(context.Background(), cloneOpts)
+	require.NoError(t, err)
+
+	cloned := result.Repository
+	assert.True(t, cloned.IsBare())
+	assert.False(t, result.CheckedOut)
+	assert.NoFileExists(t, filepath.Join(cloned.WorkDir, "a.txt"))
+	assert.NoFileExists(t, filepath.Join(cloned.GitDir, "index"))
+	assert.NoDirExists(t, filepath.Join(cloned.GitDir, "refs", "remotes"))
+	branch, err := cloned.GetCurrentBranch()
+	require.NoError(t, err)
+	assert.Equal(t, "main", branch)
+	head, err = cloned.GetHead()
+	require.NoError(t, err)
+	assert.Equal(t, commit, head)
+	_, err = cloned.LoadObject(blob)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.ObjectCount)
+	assert.Equal(t, 0, result.CheckedOutFiles)
+
+	cloneOpts.Directory = filepath.Join(t.TempDir(), "copy")
+	cloneOpts.Bare = false
+	result, err = NewCloner().Clone(context.Background(), cloneOpts)
+	require.NoError(t, err)
+	assert.True(t, result.CheckedOut)
+	assert.FileExists(t, filepath.Join(cloneOpts.Directory, "a.txt"))
+	// the commit, its tree and the blob, not the one checked-out file
+	assert.Equal(t, 3, result.ObjectCount)
+	assert.Equal(t, 1, result.CheckedOutFiles)
+}
+
+// pktLine frames payload as a pkt-line
+func pktLine(payload string) string {
+	return fmt.Sprintf("%04x%s", len(payload)+4, payload)
+}
+
+// newSourceRepo stores a commit of one file and returns the repository
+// with the commit, tree and blob hashes
+func newSourceRepo(t *testing.T) (*repository.Repository, string, string, string) {
+	source := repository.New(t.TempDir())
+	require.NoError(t, source.Init())
+	blob, err := source.StoreObject(objects.NewBlob([]byte("hello\n")))
+	require.NoError(t, err)
+	tree, err := source.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "a.txt", Hash: blob}}))
+	require.NoError(t, err)
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0).UTC()}
+	commit, err := source.StoreObject(objects.NewCommit(tree, nil, sig, sig, "initial\n"))
+	require.NoError(t, err)
+	return source, commit, tree, blob
+}
+
+// uploadPackResponse is what upload-pack answers a clone with: a NAK and
+// the pack on sideband channel 1
+func uploadPackResponse(t *testing.T, source *repository.Repository, hashes []string) []byte {
+	packData, _, err := pack.BuildPack(source, hashes)
+	require.NoError(t, err)
+
+	var buf strings.Builder
+	buf.WriteString(pktLine("NAK\n"))
+	for chunk := packData; len(chunk) > 0; {
+		n := min(len(chunk), 1000)
+		buf.WriteString(pktLine("\x01" + string(chunk[:n])))
+		chunk = chunk[n:]
+	}
+	buf.WriteString("0000")
+	return []byte(buf.String())
+}
+
+// newUploadPackServer advertises commit as refs/heads/main at /repo.git and
+// answers fetches with uploadPack
+func newUploadPackServer(t *testing.T, commit string, uploadPack http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repo.git/info/refs":
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+			fmt.Fprint(w, pktLine("# service=git-upload-pack\n")+"0000"+
+				pktLine(commit+" refs/heads/main\x00side-band-64k ofs-delta\n")+"0000")
+		case "/repo.git/git-upload-pack":
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+			uploadPack(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCloneIncompletePack(t *testing.T) {
+	source, commit, tree, blob := newSourceRepo(t)
+
+	// the pack leaves out the blob the tree points at
+	response := uploadPackResponse(t, source, []string{commit, tree})
+	server := newUploadPackServer(t, commit, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(response)
+	})
+
+	opts := DefaultCloneOptions()
+	opts.URL = server.URL + "/repo.git"
+	opts.Directory = filepath.Join(t.TempDir(), "copy")
+	opts.Progress = false
+	_, err := NewCloner().Clone(context.Background(), opts)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errors.ErrObjectNotFound)
+	assert.Contains(t, err.Error(), blob)
+	assert.NoDirExists(t, opts.Directory)
+
+	// a directory that was there before the clone is emptied, not removed
+	existing := t.TempDir()
+	opts.Directory = existing
+	_, err = NewCloner().Clone(context.Background(), opts)
+	require.Error(t, err)
+	assert.DirExists(t, existing)
+	entries, err := os.ReadDir(existing)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCloneResume(t *testing.T) {
+	source, commit, tree, blob := newSourceRepo(t)
+	response := uploadPackResponse(t, source, []string{commit, tree, blob})
+	cut := len(response) / 2
+
+	var requests, served int
+	server := newUploadPackServer(t, commit, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// promise the whole response, then drop the connection half way
+			w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+			w.Write(response[:cut])
+			served += cut
+			return
+		}
+
+		start := 0
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+			_, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start)
+			require.NoError(t, err)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(response)-1, len(response)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write(response[start:])
+		served += len(response) - start
+	})
+
+	resumeDir := t.TempDir()
+	opts := DefaultCloneOptions()
+	opts.URL = server.URL + "/repo.git"
+	opts.Directory = filepath.Join(t.TempDir(), "copy")
+	opts.Progress = false
+	opts.ResumeDir = resumeDir
+
+	_, err := NewCloner().Clone(context.Background(), opts)
+	require.Error(t, err)
+	assert.NoDirExists(t, opts.Directory)
+	kept, err := os.ReadDir(resumeDir)
+	require.NoError(t, err)
+	require.Len(t, kept, 1)
+	info, err := kept[0].Info()
+	require.NoError(t, err)
+	assert.Equal(t, int64(cut), info.Size())
+
+	result, err := NewCloner().Clone(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, len(response), served, "the retry should fetch only what the first attempt missed")
+	assert.Equal(t, 3, result.ObjectCount)
+	assert.FileExists(t, filepath.Join(opts.Directory, "a.txt"))
+
+	kept, err = os.ReadDir(resumeDir)
+	require.NoError(t, err)
+	assert.Empty(t, kept)
+}
@@ -0,0 +1,97 @@
+package clone
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/unkn0wn-root/git-go/internal/transport/remote"
+)
+
+const (
+	// a response still being received, and one received in full
+	partialSuffix  = ".partial"
+	responseSuffix = ".response"
+)
+
+// resumeKey names the spool files of a fetch, so that a retry asking the
+// same remote for the same objects finds what the last attempt received
+func resumeKey(url string, wants []string) string {
+	h := sha1.New()
+	io.WriteString(h, url)
+	for _, want := range slices.Sorted(slices.Values(wants)) {
+		io.WriteString(h, "\n"+want)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fetchResumable fetches the pack for wants into a spool file under dir and
+// returns it opened for reading, with its path. A response an earlier
+// attempt received in full is reused without fetching; one cut off part way
+// is resumed where it stopped when the transport and server support it.
+// An interrupted fetch leaves what arrived in dir for the next attempt.
+func (c *Cloner) fetchResumable(ctx context.Context, transport remote.Transport, url, dir string, wants []string) (*os.File, string, error) {
+	if err := os.MkdirAll(dir, defaultDirMode); err != nil {
+		return nil, "", fmt.Errorf("failed to create resume directory: %w", err)
+	}
+
+	base := filepath.Join(dir, resumeKey(url, wants))
+	complete := base + responseSuffix
+	if f, err := os.Open(complete); err == nil {
+		return f, complete, nil
+	}
+
+	partial := base + partialSuffix
+	spool, err := os.OpenFile(partial, os.O_CREATE|os.O_RDWR, defaultFileMode)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open %s: %w", partial, err)
+	}
+	defer spool.Close()
+
+	received, err := spool.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var body remote.PackReader
+	start := int64(0)
+	if resumable, ok := transport.(remote.ResumableTransport); ok {
+		body, start, err = resumable.FetchPackFrom(ctx, wants, []string{}, received)
+	} else {
+		body, err = transport.FetchPack(ctx, wants, []string{})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer body.Close()
+
+	if start != received {
+		if err := spool.Truncate(start); err != nil {
+			return nil, "", err
+		}
+		if _, err := spool.Seek(start, io.SeekStart); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if _, err := io.Copy(spool, body); err != nil {
+		return nil, "", fmt.Errorf("fetch interrupted, received data kept in %s: %w", partial, err)
+	}
+	if err := spool.Close(); err != nil {
+		return nil, "", err
+	}
+	if err := os.Rename(partial, complete); err != nil {
+		return nil, "", err
+	}
+
+	f, err := os.Open(complete)
+	if err != nil {
+		return nil, "", err
+	}
+	return f, complete, nil
+}
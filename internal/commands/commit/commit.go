@@ -3,147 +3,292 @@ package commit
 import (
 	"fmt"
 	"os"
-	"os/user"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/commands/diff"
+	"github.com/unkn0wn-root/git-go/internal/core/hooks"
+	"github.com/unkn0wn-root/git-go/internal/core/ident"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
+const (
+	commitEditMsgFile  = "COMMIT_EDITMSG"
+	signedOffByTrailer = "Signed-off-by"
+	defaultFileMode    = 0644
+)
+
 type CommitOptions struct {
 	Message     string
 	AuthorName  string
 	AuthorEmail string
+	// SkipHooks bypasses the pre-commit and commit-msg hooks
+	SkipHooks bool
+	// SignOff appends a Signed-off-by trailer for the committer
+	SignOff bool
+	// SignKey signs the commit with an SSH key file or a GPG key ID
+	SignKey string
+	// AllowEmpty records a commit even when the index matches HEAD
+	AllowEmpty bool
+	// Amend replaces HEAD instead of adding a child to it, keeping its
+	// parents and author, and its message when Message is empty
+	Amend bool
+}
+
+// CommitResult describes a new commit and how it changed its parent's tree.
+// Binary files count towards FilesChanged only.
+type CommitResult struct {
+	Hash string
+	// Branch is the branch the commit was made on, empty on a detached HEAD
+	Branch string
+	// Message is the message recorded, which an amend may have reused
+	Message string
+	// Root is set for a commit without parents
+	Root         bool
+	FilesChanged int
+	Insertions   int
+	Deletions    int
 }
 
-func CreateCommit(repo *repository.Repository, opts CommitOptions) (string, error) {
+func CreateCommit(repo *repository.Repository, opts CommitOptions) (*CommitResult, error) {
 	if !repo.Exists() {
-		return "", errors.ErrNotGitRepository
+		return nil, errors.ErrNotGitRepository
+	}
+
+	// pre-commit may restage files, so it runs before the index is read
+	if !opts.SkipHooks {
+		if _, err := hooks.Run(repo, hooks.PreCommit, nil); err != nil {
+			return nil, errors.NewGitError("commit", "", err)
+		}
 	}
 
 	idx := index.New(repo.GitDir)
 	if err := idx.Load(); err != nil {
-		return "", errors.NewGitError("commit", "", err)
+		return nil, errors.NewGitError("commit", "", err)
 	}
 
-	if !idx.HasChanges() {
-		return "", errors.ErrNothingToCommit
+	headHash, err := repo.GetHead()
+	if err != nil {
+		return nil, errors.NewGitError("commit", "", err)
 	}
 
-	if opts.Message == "" {
-		return "", errors.NewGitError("commit", "", fmt.Errorf("commit message is required"))
+	var head, amended *objects.Commit
+	if headHash != "" {
+		if head, err = loadCommit(repo, headHash); err != nil {
+			return nil, errors.NewGitError("commit", "", err)
+		}
+	}
+	if opts.Amend {
+		if head == nil {
+			return nil, errors.NewGitError("commit", "", fmt.Errorf("no commit to amend"))
+		}
+		amended = head
 	}
 
-	treeHash, err := createTreeFromIndex(repo, idx)
-	if err != nil {
-		return "", errors.NewGitError("commit", "", err)
+	message := opts.Message
+	if message == "" && amended != nil {
+		message = amended.Message()
+	}
+	if message == "" {
+		return nil, errors.NewGitError("commit", "", fmt.Errorf("commit message is required"))
+	}
+
+	// amending may only reword, so it needs no staged changes either
+	if !idx.HasChanges() && !opts.AllowEmpty && !opts.Amend {
+		return nil, errors.ErrNothingToCommit
 	}
 
-	parentHash, err := repo.GetHead()
+	author, committer, err := getSignatures(repo, opts.AuthorName, opts.AuthorEmail)
 	if err != nil {
-		return "", errors.NewGitError("commit", "", err)
+		return nil, errors.NewGitError("commit", "", err)
+	}
+	if amended != nil && opts.AuthorName == "" && opts.AuthorEmail == "" {
+		author = amended.Author()
+	}
+
+	if opts.SignOff {
+		message = objects.AppendTrailer(message, signedOffByTrailer, fmt.Sprintf("%s <%s>", committer.Name, committer.Email))
+	}
+
+	if !opts.SkipHooks {
+		message, err = runCommitMsgHook(repo, message)
+		if err != nil {
+			return nil, errors.NewGitError("commit", "", err)
+		}
 	}
 
-	author, committer, err := getSignatures(opts.AuthorName, opts.AuthorEmail)
+	treeHash, err := idx.WriteTreeTo(repo)
 	if err != nil {
-		return "", errors.NewGitError("commit", "", err)
+		return nil, errors.NewGitError("commit", "", err)
+	}
+
+	// the index loads every entry as staged, so an unchanged tree is what
+	// tells a commit is empty
+	if head != nil && treeHash == head.Tree() && !opts.AllowEmpty && !opts.Amend {
+		return nil, errors.ErrNothingToCommit
 	}
 
 	var parents []string
-	if parentHash != "" {
-		parents = []string{parentHash}
+	if amended != nil {
+		parents = amended.Parents()
+	} else if headHash != "" {
+		parents = []string{headHash}
+	}
+
+	parentHash := ""
+	if len(parents) > 0 {
+		parentHash = parents[0]
+	}
+
+	// counted before anything is written, so a failure leaves no commit
+	result := &CommitResult{}
+	if err := countChanges(repo, parentHash, treeHash, result); err != nil {
+		return nil, errors.NewGitError("commit", "", err)
+	}
+
+	commit := objects.NewCommit(treeHash, parents, author, committer, message)
+	if opts.SignKey != "" {
+		if err := signCommit(commit, opts.SignKey); err != nil {
+			return nil, errors.NewGitError("commit", "", err)
+		}
 	}
 
-	commit := objects.NewCommit(treeHash, parents, author, committer, opts.Message)
 	commitHash, err := repo.StoreObject(commit)
 	if err != nil {
-		return "", errors.NewGitError("commit", "", err)
+		return nil, errors.NewGitError("commit", "", err)
 	}
 
-	branch, err := repo.GetCurrentBranch()
+	headRef, detached, _, err := repo.HeadRef()
 	if err != nil {
-		return "", errors.NewGitError("commit", "", err)
+		return nil, errors.NewGitError("commit", "", err)
 	}
 
-	if branch == "" {
-		branch = "main"
+	reflogMsg := "commit: " + subject(message)
+	switch {
+	case amended != nil:
+		reflogMsg = "commit (amend): " + subject(message)
+	case parentHash == "":
+		reflogMsg = "commit (initial): " + subject(message)
 	}
 
-	refPath := fmt.Sprintf("refs/heads/%s", branch)
-	if err := repo.UpdateRef(refPath, commitHash); err != nil {
-		return "", errors.NewGitError("commit", "", err)
+	// a detached HEAD moves by itself, leaving every branch where it was
+	refPath, branch := "HEAD", ""
+	if !detached {
+		refPath, branch = headRef, strings.TrimPrefix(headRef, "refs/heads/")
+	}
+	if err := repo.UpdateRefWithMessage(refPath, commitHash, reflogMsg); err != nil {
+		return nil, errors.NewGitError("commit", "", err)
 	}
 
 	// after commit, mark staged files as committed but keep them in index
 	idx.MarkAsCommitted()
 
 	if err := idx.Save(); err != nil {
-		return "", errors.NewGitError("commit", "", err)
+		return nil, errors.NewGitError("commit", "", err)
 	}
 
-	return commitHash, nil
+	result.Hash, result.Branch, result.Message = commitHash, branch, message
+	result.Root = len(parents) == 0
+	return result, nil
 }
 
-func createTreeFromIndex(repo *repository.Repository, idx *index.Index) (string, error) {
-	entries := idx.GetAll()
-	if len(entries) == 0 {
-		return "", errors.NewGitError("commit", "", fmt.Errorf("no files in index"))
+func loadCommit(repo *repository.Repository, hash string) (*objects.Commit, error) {
+	obj, err := repo.LoadObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return nil, errors.NewObjectError(hash, "commit", errors.ErrInvalidCommit)
 	}
+	return commit, nil
+}
 
-	var treeEntries []objects.TreeEntry
-	for path, entry := range entries {
-		treeEntries = append(treeEntries, objects.TreeEntry{
-			Mode: objects.FileMode(entry.Mode),
-			Name: path,
-			Hash: entry.Hash,
-		})
+// countChanges diffs the new tree against the parent's, or an empty tree for
+// a root commit, and fills in the result's counts
+func countChanges(repo *repository.Repository, parentHash, treeHash string, result *CommitResult) error {
+	oldFiles := map[string]objects.TreeEntry{}
+	if parentHash != "" {
+		var err error
+		if oldFiles, err = checkout.CommitFiles(repo, parentHash); err != nil {
+			return err
+		}
+	}
+	newFiles, err := checkout.TreeFiles(repo, treeHash)
+	if err != nil {
+		return err
 	}
 
-	tree := objects.NewTree(treeEntries)
-	hash, err := repo.StoreObject(tree)
+	diffs, err := diff.TreeDiffs(repo, oldFiles, newFiles)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return hash, nil
+	result.FilesChanged = len(diffs)
+	for _, fd := range diffs {
+		added, removed := fd.Stat()
+		result.Insertions += added
+		result.Deletions += removed
+	}
+	return nil
 }
 
-func getSignatures(authorName, authorEmail string) (*objects.Signature, *objects.Signature, error) {
-	name := authorName
-	email := authorEmail
-
-	if name == "" {
-		if envName := os.Getenv("GIT_AUTHOR_NAME"); envName != "" {
-			name = envName
-		} else if u, err := user.Current(); err == nil {
-			name = u.Username
-		} else {
-			name = "Unknown"
-		}
+// runCommitMsgHook writes the message to COMMIT_EDITMSG, lets the commit-msg
+// hook validate or edit it, and returns the message read back from the file
+func runCommitMsgHook(repo *repository.Repository, message string) (string, error) {
+	if !hooks.Exists(repo, hooks.CommitMsg) {
+		return message, nil
 	}
 
-	if email == "" {
-		if envEmail := os.Getenv("GIT_AUTHOR_EMAIL"); envEmail != "" {
-			email = envEmail
-		} else {
-			email = "local@localhost.local"
-		}
+	msgPath := filepath.Join(repo.GitDir, commitEditMsgFile)
+	if err := os.WriteFile(msgPath, []byte(message), defaultFileMode); err != nil {
+		return "", fmt.Errorf("write %s: %w", commitEditMsgFile, err)
 	}
 
-	now := time.Now()
-	author := &objects.Signature{
-		Name:  name,
-		Email: email,
-		When:  now,
+	if _, err := hooks.Run(repo, hooks.CommitMsg, nil, msgPath); err != nil {
+		return "", err
 	}
 
-	committer := &objects.Signature{
-		Name:  name,
-		Email: email,
-		When:  now,
+	edited, err := os.ReadFile(msgPath)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", commitEditMsgFile, err)
+	}
+
+	message = strings.TrimRight(string(edited), "\n")
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("aborting commit due to empty commit message")
+	}
+
+	return message, nil
+}
+
+// getSignatures resolves the author and committer. Explicit author fields
+// are recorded for both, as before; blank ones fall back to ident.Resolve.
+func getSignatures(repo *repository.Repository, authorName, authorEmail string) (*objects.Signature, *objects.Signature, error) {
+	id, err := ident.Resolve(repo.GitDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	author, committer := id.Author, id.Committer
+	if authorName != "" {
+		author.Name, committer.Name = authorName, authorName
+	}
+	if authorEmail != "" {
+		author.Email, committer.Email = authorEmail, authorEmail
 	}
 
-	return author, committer, nil
+	now := time.Now()
+	return author.Signature(now), committer.Signature(now), nil
+}
+
+// subject returns the first line of a commit message
+func subject(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return line
 }
@@ -1,12 +1,20 @@
 package commit
 
 import (
+	stderrors "errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/reflog"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 func TestCreateCommit_Success(t *testing.T) {
@@ -34,16 +42,16 @@ func TestCreateCommit_Success(t *testing.T) {
 		AuthorEmail: "test@example.com",
 	}
 
-	commitHash, err := CreateCommit(repo, options)
+	result, err := CreateCommit(repo, options)
 	if err != nil {
 		t.Fatalf("Failed to create commit: %v", err)
 	}
 
-	if commitHash == "" {
+	if result.Hash == "" {
 		t.Error("Expected non-empty commit hash")
 	}
 
-	obj, err := repo.LoadObject(commitHash)
+	obj, err := repo.LoadObject(result.Hash)
 	if err != nil {
 		t.Fatalf("Failed to load commit object: %v", err)
 	}
@@ -127,12 +135,12 @@ func TestCreateCommit_WithParent(t *testing.T) {
 		AuthorEmail: "test@example.com",
 	}
 
-	firstCommitHash, err := CreateCommit(repo, options1)
+	firstResult, err := CreateCommit(repo, options1)
 	if err != nil {
 		t.Fatalf("Failed to create first commit: %v", err)
 	}
 
-	if err := repo.UpdateRef("refs/heads/main", firstCommitHash); err != nil {
+	if err := repo.UpdateRef("refs/heads/main", firstResult.Hash); err != nil {
 		t.Fatalf("Failed to update ref: %v", err)
 	}
 
@@ -154,12 +162,12 @@ func TestCreateCommit_WithParent(t *testing.T) {
 		AuthorEmail: "test@example.com",
 	}
 
-	secondCommitHash, err := CreateCommit(repo, options2)
+	secondResult, err := CreateCommit(repo, options2)
 	if err != nil {
 		t.Fatalf("Failed to create second commit: %v", err)
 	}
 
-	obj, err := repo.LoadObject(secondCommitHash)
+	obj, err := repo.LoadObject(secondResult.Hash)
 	if err != nil {
 		t.Fatalf("Failed to load second commit: %v", err)
 	}
@@ -172,8 +180,8 @@ func TestCreateCommit_WithParent(t *testing.T) {
 	parents := commit.Parents()
 	if len(parents) != 1 {
 		t.Errorf("Expected 1 parent, got %d", len(parents))
-	} else if parents[0] != firstCommitHash {
-		t.Errorf("Expected parent %s, got %s", firstCommitHash, parents[0])
+	} else if parents[0] != firstResult.Hash {
+		t.Errorf("Expected parent %s, got %s", firstResult.Hash, parents[0])
 	}
 }
 
@@ -202,7 +210,7 @@ func TestCreateCommit_UpdatesHead(t *testing.T) {
 		AuthorEmail: "test@example.com",
 	}
 
-	commitHash, err := CreateCommit(repo, options)
+	result, err := CreateCommit(repo, options)
 	if err != nil {
 		t.Fatalf("Failed to create commit: %v", err)
 	}
@@ -212,8 +220,76 @@ func TestCreateCommit_UpdatesHead(t *testing.T) {
 		t.Fatalf("Failed to get HEAD: %v", err)
 	}
 
-	if headHash != commitHash {
-		t.Errorf("Expected HEAD to be %s, got %s", commitHash, headHash)
+	if headHash != result.Hash {
+		t.Errorf("Expected HEAD to be %s, got %s", result.Hash, headHash)
+	}
+}
+
+func TestCreateCommit_DetachedHead(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := setupTestRepository(t, tempDir)
+
+	stage := func(content string) {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		idx := index.New(repo.GitDir)
+		if err := idx.Load(); err != nil {
+			t.Fatalf("Failed to load index: %v", err)
+		}
+		idx.Add("test.txt", blobHash, uint32(objects.FileModeBlob), int64(len(content)), time.Now())
+		if err := idx.Save(); err != nil {
+			t.Fatalf("Failed to save index: %v", err)
+		}
+	}
+	options := CommitOptions{
+		Message:     "Test commit",
+		AuthorName:  "Test Author",
+		AuthorEmail: "test@example.com",
+	}
+
+	stage("on main")
+	first, err := CreateCommit(repo, options)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if err := repo.SetHead(first.Hash, "checkout: moving from main to "+first.Hash); err != nil {
+		t.Fatalf("Failed to detach HEAD: %v", err)
+	}
+
+	stage("detached")
+	options.Message = "Detached commit"
+	second, err := CreateCommit(repo, options)
+	if err != nil {
+		t.Fatalf("Failed to commit on a detached HEAD: %v", err)
+	}
+	if second.Branch != "" {
+		t.Errorf("Expected no branch for a detached commit, got %q", second.Branch)
+	}
+
+	ref, detached, head, err := repo.HeadRef()
+	if err != nil {
+		t.Fatalf("Failed to read HEAD: %v", err)
+	}
+	if !detached || ref != "" || head != second.Hash {
+		t.Errorf("Expected HEAD detached at %s, got ref %q detached %v hash %q", second.Hash, ref, detached, head)
+	}
+
+	mainHash, err := repo.ReadRef("refs/heads/main")
+	if err != nil {
+		t.Fatalf("Failed to read main: %v", err)
+	}
+	if mainHash != first.Hash {
+		t.Errorf("Expected main to stay at %s, got %s", first.Hash, mainHash)
+	}
+
+	commit, err := repo.LoadCommit(second.Hash)
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	if parents := commit.Parents(); len(parents) != 1 || parents[0] != first.Hash {
+		t.Errorf("Expected parent %s, got %v", first.Hash, parents)
 	}
 }
 
@@ -251,12 +327,12 @@ func TestCreateCommit_MultipleFiles(t *testing.T) {
 		AuthorEmail: "test@example.com",
 	}
 
-	commitHash, err := CreateCommit(repo, options)
+	result, err := CreateCommit(repo, options)
 	if err != nil {
 		t.Fatalf("Failed to create commit: %v", err)
 	}
 
-	obj, err := repo.LoadObject(commitHash)
+	obj, err := repo.LoadObject(result.Hash)
 	if err != nil {
 		t.Fatalf("Failed to load commit: %v", err)
 	}
@@ -378,3 +454,470 @@ func BenchmarkCreateCommit(b *testing.B) {
 		}
 	}
 }
+
+func stageTestFile(t *testing.T, repo *repository.Repository) {
+	content := []byte("test content")
+	blobHash, err := repo.StoreObject(objects.NewBlob(content))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	idx.Add("test.txt", blobHash, uint32(objects.FileModeBlob), int64(len(content)), time.Now())
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+}
+
+func writeHook(t *testing.T, repo *repository.Repository, name, script string) {
+	hooksDir := filepath.Join(repo.GitDir, "hooks")
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		t.Fatalf("Failed to create hooks dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(hooksDir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+}
+
+func TestCreateCommit_PreCommitHookAborts(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+	writeHook(t, repo, "pre-commit", "echo 'trailing whitespace in test.txt'\nexit 1\n")
+
+	_, err := CreateCommit(repo, CommitOptions{Message: "Test commit"})
+	if !stderrors.Is(err, errors.ErrHookFailed) {
+		t.Fatalf("Expected hook failure, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "trailing whitespace in test.txt") {
+		t.Errorf("Expected hook output in error, got %q", err.Error())
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	if head != "" {
+		t.Errorf("Expected no commit to be created, HEAD is %s", head)
+	}
+}
+
+func TestCreateCommit_CommitMsgHookEditsMessage(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+	writeHook(t, repo, "commit-msg", "printf '\\n\\nRefs: #42\\n' >> \"$1\"\n")
+
+	result, err := CreateCommit(repo, CommitOptions{Message: "Test commit"})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	obj, err := repo.LoadObject(result.Hash)
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	if msg := obj.(*objects.Commit).Message(); msg != "Test commit\n\nRefs: #42" {
+		t.Errorf("Expected hook-edited message, got %q", msg)
+	}
+}
+
+func TestCreateCommit_CommitMsgHookRejects(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+	writeHook(t, repo, "commit-msg", "grep -q '^feat:' \"$1\" || { echo 'message must start with feat:' >&2; exit 1; }\n")
+
+	_, err := CreateCommit(repo, CommitOptions{Message: "Test commit"})
+	if !stderrors.Is(err, errors.ErrHookFailed) {
+		t.Fatalf("Expected hook failure, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "message must start with feat:") {
+		t.Errorf("Expected hook output in error, got %q", err.Error())
+	}
+
+	if _, err := CreateCommit(repo, CommitOptions{Message: "feat: add test"}); err != nil {
+		t.Errorf("Expected accepted message to commit, got %v", err)
+	}
+}
+
+func TestCreateCommit_SkipHooks(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+	writeHook(t, repo, "pre-commit", "exit 1\n")
+	writeHook(t, repo, "commit-msg", "exit 1\n")
+
+	if _, err := CreateCommit(repo, CommitOptions{Message: "Test commit", SkipHooks: true}); err != nil {
+		t.Errorf("Expected hooks to be skipped, got %v", err)
+	}
+}
+
+func TestCreateCommit_SignOff(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+
+	result, err := CreateCommit(repo, CommitOptions{
+		Message:     "Test commit\n\nSome details.",
+		AuthorName:  "Test Author",
+		AuthorEmail: "test@example.com",
+		SignOff:     true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	obj, err := repo.LoadObject(result.Hash)
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	commit := obj.(*objects.Commit)
+
+	expected := "Test commit\n\nSome details.\n\nSigned-off-by: Test Author <test@example.com>"
+	if commit.Message() != expected {
+		t.Errorf("Expected message %q, got %q", expected, commit.Message())
+	}
+
+	signers := commit.Trailers()["Signed-off-by"]
+	if len(signers) != 1 || signers[0] != "Test Author <test@example.com>" {
+		t.Errorf("Expected one sign-off trailer, got %v", signers)
+	}
+}
+
+func TestCreateCommit_ConfigIdentity(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "")
+	t.Setenv("GIT_AUTHOR_EMAIL", "")
+	t.Setenv("GIT_COMMITTER_NAME", "")
+	t.Setenv("GIT_COMMITTER_EMAIL", "committer@example.com")
+
+	config := "[user]\n\tname = Config User\n\temail = config@example.com\n"
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	result, err := CreateCommit(repo, CommitOptions{Message: "Test commit"})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	obj, err := repo.LoadObject(result.Hash)
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	commit := obj.(*objects.Commit)
+
+	if author := commit.Author(); author.Name != "Config User" || author.Email != "config@example.com" {
+		t.Errorf("Expected author from config, got %s <%s>", author.Name, author.Email)
+	}
+	if committer := commit.Committer(); committer.Name != "Config User" || committer.Email != "committer@example.com" {
+		t.Errorf("Expected committer email from environment, got %s <%s>", committer.Name, committer.Email)
+	}
+}
+
+func TestCreateCommit_SSHSignature(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+
+	tempDir := t.TempDir()
+	keyPath := filepath.Join(tempDir, "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", keyPath).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to generate key: %v: %s", err, out)
+	}
+
+	repo := setupTestRepository(t, filepath.Join(tempDir, "repo"))
+	stageTestFile(t, repo)
+
+	result, err := CreateCommit(repo, CommitOptions{
+		Message:     "Signed commit",
+		AuthorName:  "Test Author",
+		AuthorEmail: "test@example.com",
+		SignKey:     keyPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	info, err := VerifySignature(repo, result.Hash)
+	if err != nil {
+		t.Fatalf("Expected signature to verify: %v", err)
+	}
+	if info.Format != SignatureFormatSSH {
+		t.Errorf("Expected format %q, got %q", SignatureFormatSSH, info.Format)
+	}
+	if !strings.HasPrefix(info.Signer, "SHA256:") {
+		t.Errorf("Expected key fingerprint as signer, got %q", info.Signer)
+	}
+
+	// a commit with the same signature over a different message must fail
+	obj, err := repo.LoadObject(result.Hash)
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	signed := obj.(*objects.Commit)
+	forged := objects.NewCommit(signed.Tree(), signed.Parents(), signed.Author(), signed.Committer(), "Forged commit")
+	forged.SetGPGSignature(signed.GPGSignature())
+	forgedHash, err := repo.StoreObject(forged)
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+
+	if _, err := VerifySignature(repo, forgedHash); !stderrors.Is(err, errors.ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+}
+
+func TestVerifySignature_Unsigned(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+
+	result, err := CreateCommit(repo, CommitOptions{Message: "Unsigned commit"})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	if _, err := VerifySignature(repo, result.Hash); !stderrors.Is(err, errors.ErrUnsignedCommit) {
+		t.Errorf("Expected ErrUnsignedCommit, got %v", err)
+	}
+}
+
+func stageContent(t *testing.T, repo *repository.Repository, path string, content []byte) {
+	blobHash, err := repo.StoreObject(objects.NewBlob(content))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	idx.Add(path, blobHash, uint32(objects.FileModeBlob), int64(len(content)), time.Now())
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+}
+
+func TestCreateCommit_ReportsChanges(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	options := CommitOptions{Message: "commit", AuthorName: "Test Author", AuthorEmail: "test@example.com"}
+
+	stageContent(t, repo, "notes.txt", []byte("one\ntwo\nthree\n"))
+	stageContent(t, repo, "image.bin", []byte{0x89, 0x00, 0x01})
+	result, err := CreateCommit(repo, options)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if result.FilesChanged != 2 || result.Insertions != 3 || result.Deletions != 0 {
+		t.Errorf("root commit: got %d files, +%d -%d, want 2 files, +3 -0",
+			result.FilesChanged, result.Insertions, result.Deletions)
+	}
+	if !result.Root {
+		t.Error("Expected the first commit to be reported as a root commit")
+	}
+
+	stageContent(t, repo, "notes.txt", []byte("one\n2\nthree\nfour\n"))
+	stageContent(t, repo, "image.bin", []byte{0x89, 0x00, 0x02})
+	result, err = CreateCommit(repo, options)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if result.FilesChanged != 2 || result.Insertions != 2 || result.Deletions != 1 {
+		t.Errorf("edit: got %d files, +%d -%d, want 2 files, +2 -1",
+			result.FilesChanged, result.Insertions, result.Deletions)
+	}
+	if result.Branch != "main" {
+		t.Errorf("Branch = %q, want main", result.Branch)
+	}
+	if result.Root {
+		t.Error("Expected a commit with a parent not to be a root commit")
+	}
+}
+
+func TestCreateCommit_AllowEmpty(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+
+	first, err := CreateCommit(repo, CommitOptions{Message: "First"})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	if _, err := CreateCommit(repo, CommitOptions{Message: "Empty"}); !stderrors.Is(err, errors.ErrNothingToCommit) {
+		t.Fatalf("Expected ErrNothingToCommit without AllowEmpty, got %v", err)
+	}
+
+	empty, err := CreateCommit(repo, CommitOptions{Message: "Empty", AllowEmpty: true})
+	if err != nil {
+		t.Fatalf("Failed to create empty commit: %v", err)
+	}
+	if empty.FilesChanged != 0 {
+		t.Errorf("Expected no changed files, got %d", empty.FilesChanged)
+	}
+
+	firstCommit := mustLoadCommit(t, repo, first.Hash)
+	emptyCommit := mustLoadCommit(t, repo, empty.Hash)
+	if emptyCommit.Tree() != firstCommit.Tree() {
+		t.Errorf("Expected tree %s, got %s", firstCommit.Tree(), emptyCommit.Tree())
+	}
+	if parents := emptyCommit.Parents(); len(parents) != 1 || parents[0] != first.Hash {
+		t.Errorf("Expected parent %s, got %v", first.Hash, parents)
+	}
+}
+
+func TestCreateCommit_UnchangedTreeIsStable(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	options := CommitOptions{Message: "commit", AuthorName: "Test Author", AuthorEmail: "test@example.com"}
+
+	for i := 0; i < 8; i++ {
+		stageContent(t, repo, fmt.Sprintf("file%d.txt", i), []byte(fmt.Sprintf("content %d\n", i)))
+	}
+	if _, err := CreateCommit(repo, options); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	// re-adding a file unchanged leaves the tree, and so the commit, alone
+	for i := 0; i < 5; i++ {
+		stageContent(t, repo, "file3.txt", []byte("content 3\n"))
+		if _, err := CreateCommit(repo, options); !stderrors.Is(err, errors.ErrNothingToCommit) {
+			t.Fatalf("attempt %d: expected ErrNothingToCommit, got %v", i, err)
+		}
+	}
+}
+
+func TestCreateCommit_NestedTree(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	options := CommitOptions{Message: "commit", AuthorName: "Test Author", AuthorEmail: "test@example.com"}
+
+	stageContent(t, repo, "a.txt", []byte("a\n"))
+	stageContent(t, repo, "a/b.txt", []byte("b\n"))
+	stageContent(t, repo, "d/x", []byte("x\n"))
+	result, err := CreateCommit(repo, options)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	tree, err := repo.LoadTree(mustLoadCommit(t, repo, result.Hash).Tree())
+	if err != nil {
+		t.Fatalf("Failed to load tree: %v", err)
+	}
+	// the directory "a" sorts as "a/", after "a.txt"
+	var names []string
+	for _, entry := range tree.Entries() {
+		names = append(names, entry.Name)
+	}
+	if strings.Join(names, " ") != "a.txt a d" {
+		t.Errorf("Expected root entries [a.txt a d], got %v", names)
+	}
+
+	// the index keeps the trees it wrote for the next commit to reuse
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if cached := idx.CachedTrees(); len(cached) != 3 || cached[0] != tree.Hash() {
+		t.Errorf("Expected the root and two subtrees cached, got %v", cached)
+	}
+
+	stageContent(t, repo, "d/x", []byte("changed\n"))
+	amended, err := CreateCommit(repo, CommitOptions{Amend: true, AuthorName: "Test Author", AuthorEmail: "test@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to amend: %v", err)
+	}
+	amendedTree, err := repo.LoadTree(mustLoadCommit(t, repo, amended.Hash).Tree())
+	if err != nil {
+		t.Fatalf("Failed to load tree: %v", err)
+	}
+	if entries := amendedTree.Entries(); len(entries) != 3 || entries[1].Hash != tree.Entries()[1].Hash || entries[2].Hash == tree.Entries()[2].Hash {
+		t.Errorf("Expected the amend to rewrite only d, got %+v", entries)
+	}
+}
+
+func TestCreateCommit_Amend(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageContent(t, repo, "a.txt", []byte("a\n"))
+	base, err := CreateCommit(repo, CommitOptions{Message: "Base"})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	stageContent(t, repo, "b.txt", []byte("b\n"))
+	tip, err := CreateCommit(repo, CommitOptions{Message: "Add b", AuthorName: "Original", AuthorEmail: "original@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	stageContent(t, repo, "c.txt", []byte("c\n"))
+	amended, err := CreateCommit(repo, CommitOptions{Amend: true})
+	if err != nil {
+		t.Fatalf("Failed to amend: %v", err)
+	}
+	if amended.Hash == tip.Hash {
+		t.Fatal("Expected amend to create a new commit")
+	}
+
+	commit := mustLoadCommit(t, repo, amended.Hash)
+	if parents := commit.Parents(); len(parents) != 1 || parents[0] != base.Hash {
+		t.Errorf("Expected parent %s, got %v", base.Hash, parents)
+	}
+	if commit.Message() != "Add b" {
+		t.Errorf("Expected reused message 'Add b', got %q", commit.Message())
+	}
+	if amended.Message != "Add b" {
+		t.Errorf("Expected result message 'Add b', got %q", amended.Message)
+	}
+	if commit.Author().Name != "Original" {
+		t.Errorf("Expected reused author 'Original', got %q", commit.Author().Name)
+	}
+	if amended.FilesChanged != 2 {
+		t.Errorf("Expected 2 files changed against the parent, got %d", amended.FilesChanged)
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		t.Fatalf("Failed to read HEAD: %v", err)
+	}
+	if head != amended.Hash {
+		t.Errorf("Expected branch at %s, got %s", amended.Hash, head)
+	}
+
+	entries, err := reflog.Read(repo, "main")
+	if err != nil {
+		t.Fatalf("Failed to read reflog: %v", err)
+	}
+	if entries[0].OldHash != tip.Hash || entries[0].NewHash != amended.Hash ||
+		entries[0].Message != "commit (amend): Add b" {
+		t.Errorf("Unexpected reflog entry %+v", entries[0])
+	}
+
+	reworded, err := CreateCommit(repo, CommitOptions{Message: "Add b and c", Amend: true})
+	if err != nil {
+		t.Fatalf("Failed to reword: %v", err)
+	}
+	if got := mustLoadCommit(t, repo, reworded.Hash).Message(); got != "Add b and c" {
+		t.Errorf("Expected message 'Add b and c', got %q", got)
+	}
+}
+
+func TestCreateCommit_AmendWithoutHead(t *testing.T) {
+	repo := setupTestRepository(t, t.TempDir())
+	stageTestFile(t, repo)
+
+	if _, err := CreateCommit(repo, CommitOptions{Message: "Amend", Amend: true}); err == nil {
+		t.Error("Expected an error amending without a commit")
+	}
+}
+
+func mustLoadCommit(t *testing.T, repo *repository.Repository, hash string) *objects.Commit {
+	obj, err := repo.LoadObject(hash)
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		t.Fatalf("Expected commit object, got %T", obj)
+	}
+	return commit
+}
@@ -0,0 +1,212 @@
+package countobjects
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/packindex"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	objectsDirName = "objects"
+	packDirName    = "pack"
+	packExt        = ".pack"
+	idxExt         = ".idx"
+
+	// a loose object lives at objects/xx/<38 hex digits>
+	fanoutNameLength = 2
+)
+
+// packCompanionExts are files git keeps beside a pack; they are garbage only
+// when the pack itself is missing
+var packCompanionExts = []string{".keep", ".bitmap", ".promisor", ".rev", ".mtimes"}
+
+// ObjectStats describes the object database. Sizes are in bytes.
+type ObjectStats struct {
+	Count int
+	Size  int64
+	// InPack counts objects across all packs, an object in two packs twice
+	InPack   int
+	Packs    int
+	SizePack int64
+	// PrunePackable counts loose objects that are also packed
+	PrunePackable int
+	// GarbagePaths are files in the object database that are neither objects
+	// nor packs, such as temporary files and packs without an index
+	GarbagePaths []string
+	SizeGarbage  int64
+}
+
+// Count reports how many objects the repository stores loose and packed,
+// and how much space they and any garbage take
+func Count(repo *repository.Repository) (*ObjectStats, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	stats := &ObjectStats{}
+	objectsDir := filepath.Join(repo.GitDir, objectsDirName)
+
+	indexes, err := countPacks(filepath.Join(objectsDir, packDirName), stats)
+	if err != nil {
+		return nil, errors.NewGitError("count-objects", packDirName, err)
+	}
+
+	dirs, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return nil, errors.NewGitError("count-objects", objectsDir, err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != fanoutNameLength || !isHex(dir.Name()) {
+			continue
+		}
+		if err := countLoose(filepath.Join(objectsDir, dir.Name()), dir.Name(), indexes, stats); err != nil {
+			return nil, errors.NewGitError("count-objects", dir.Name(), err)
+		}
+	}
+
+	sort.Strings(stats.GarbagePaths)
+	return stats, nil
+}
+
+// countLoose counts the objects in one fan-out directory, treating any other
+// file there as garbage
+func countLoose(dir, prefix string, indexes []*packindex.Index, stats *ObjectStats) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return err
+		}
+
+		objHash := prefix + file.Name()
+		if !hash.ValidateHash(objHash) {
+			stats.addGarbage(filepath.Join(dir, file.Name()), info.Size())
+			continue
+		}
+
+		stats.Count++
+		stats.Size += info.Size()
+		if inPacks(objHash, indexes) {
+			stats.PrunePackable++
+		}
+	}
+	return nil
+}
+
+// countPacks counts the packs that have both a pack and an index file and
+// returns their parsed indexes. Anything else in the directory is garbage.
+func countPacks(dir string, stats *ObjectStats) ([]*packindex.Index, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+		sizes[file.Name()] = info.Size()
+	}
+
+	var indexes []*packindex.Index
+	for name, size := range sizes {
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		_, hasPack := sizes[base+packExt]
+		_, hasIdx := sizes[base+idxExt]
+
+		switch {
+		case ext == idxExt && hasPack:
+			idx, err := packindex.Read(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			indexes = append(indexes, idx)
+			stats.Packs++
+			stats.InPack += len(idx.Entries)
+			stats.SizePack += size + sizes[base+packExt]
+		case ext == packExt && hasIdx:
+			// counted with its index
+		case isPackCompanion(ext) && hasPack && hasIdx:
+		default:
+			stats.addGarbage(filepath.Join(dir, name), size)
+		}
+	}
+	return indexes, nil
+}
+
+func isPackCompanion(ext string) bool {
+	for _, companion := range packCompanionExts {
+		if ext == companion {
+			return true
+		}
+	}
+	return false
+}
+
+// inPacks reports whether any of the indexes lists objHash
+func inPacks(objHash string, indexes []*packindex.Index) bool {
+	for _, idx := range indexes {
+		entries := idx.Entries
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].Hash >= objHash })
+		if i < len(entries) && entries[i].Hash == objHash {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ObjectStats) addGarbage(path string, size int64) {
+	s.GarbagePaths = append(s.GarbagePaths, path)
+	s.SizeGarbage += size
+}
+
+// String renders the stats as git count-objects does, sizes in kibibytes:
+// a one-line summary of loose objects, or with verbose every figure
+func (s *ObjectStats) String(verbose bool) string {
+	if !verbose {
+		return fmt.Sprintf("%d objects, %d kilobytes\n", s.Count, s.Size/1024)
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "count: %d\n", s.Count)
+	fmt.Fprintf(&buf, "size: %d\n", s.Size/1024)
+	fmt.Fprintf(&buf, "in-pack: %d\n", s.InPack)
+	fmt.Fprintf(&buf, "packs: %d\n", s.Packs)
+	fmt.Fprintf(&buf, "size-pack: %d\n", s.SizePack/1024)
+	fmt.Fprintf(&buf, "prune-packable: %d\n", s.PrunePackable)
+	fmt.Fprintf(&buf, "garbage: %d\n", len(s.GarbagePaths))
+	fmt.Fprintf(&buf, "size-garbage: %d\n", s.SizeGarbage/1024)
+	return buf.String()
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package countobjects
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/gc"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+// setupCountRepo packs a commit's three objects, then leaves two new loose
+// blobs, a loose copy of a packed blob, a temporary object file and a pack
+// with no index behind
+func setupCountRepo(t *testing.T) *repository.Repository {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	store := func(obj objects.Object) string {
+		h, err := repo.StoreObject(obj)
+		if err != nil {
+			t.Fatalf("Failed to store object: %v", err)
+		}
+		return h
+	}
+
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+	blob := store(objects.NewBlob([]byte("hello\n")))
+	tree := store(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "hello.txt", Hash: blob}}))
+	commit := store(objects.NewCommit(tree, nil, sig, sig, "initial\n"))
+	if err := repo.UpdateRef("refs/heads/main", commit); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	blobPath := filepath.Join(repo.GitDir, "objects", blob[:2], blob[2:])
+	looseBlob, err := os.ReadFile(blobPath)
+	if err != nil {
+		t.Fatalf("Failed to read loose blob: %v", err)
+	}
+
+	if _, err := gc.Run(repo, gc.GCOptions{}); err != nil {
+		t.Fatalf("gc failed: %v", err)
+	}
+
+	store(objects.NewBlob([]byte("loose one\n")))
+	store(objects.NewBlob([]byte("loose two\n")))
+
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		t.Fatalf("Failed to create fan-out directory: %v", err)
+	}
+	if err := os.WriteFile(blobPath, looseBlob, 0444); err != nil {
+		t.Fatalf("Failed to restore loose blob: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(blobPath), "tmp_obj_abc123"), []byte("partial"), 0644); err != nil {
+		t.Fatalf("Failed to write temporary object: %v", err)
+	}
+	strayPack := filepath.Join(repo.GitDir, "objects", "pack", "pack-0000000000000000000000000000000000000000.pack")
+	if err := os.WriteFile(strayPack, []byte("PACK"), 0444); err != nil {
+		t.Fatalf("Failed to write stray pack: %v", err)
+	}
+
+	return repo
+}
+
+func TestCount(t *testing.T) {
+	repo := setupCountRepo(t)
+
+	stats, err := Count(repo)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+
+	if stats.Count != 3 {
+		t.Errorf("Count = %d, want 3", stats.Count)
+	}
+	if stats.InPack != 3 {
+		t.Errorf("InPack = %d, want 3", stats.InPack)
+	}
+	if stats.Packs != 1 {
+		t.Errorf("Packs = %d, want 1", stats.Packs)
+	}
+	if stats.PrunePackable != 1 {
+		t.Errorf("PrunePackable = %d, want 1", stats.PrunePackable)
+	}
+	if len(stats.GarbagePaths) != 2 {
+		t.Fatalf("GarbagePaths = %v, want the temporary object and the stray pack", stats.GarbagePaths)
+	}
+	if stats.SizeGarbage != int64(len("partial")+len("PACK")) {
+		t.Errorf("SizeGarbage = %d, want %d", stats.SizeGarbage, len("partial")+len("PACK"))
+	}
+
+	var looseSize int64
+	err = filepath.Walk(filepath.Join(repo.GitDir, "objects"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && len(filepath.Base(filepath.Dir(path))) == 2 && !strings.HasPrefix(info.Name(), "tmp_") {
+			looseSize += info.Size()
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk objects: %v", err)
+	}
+	if stats.Size != looseSize {
+		t.Errorf("Size = %d, want %d", stats.Size, looseSize)
+	}
+	if stats.SizePack == 0 {
+		t.Error("SizePack = 0, want the size of the pack and its index")
+	}
+
+	if got := stats.String(false); got != "3 objects, 0 kilobytes\n" {
+		t.Errorf("String(false) = %q", got)
+	}
+}
+
+func TestCount_MatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := setupCountRepo(t)
+
+	stats, err := Count(repo)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+
+	cmd := exec.Command("git", "count-objects", "-v")
+	cmd.Dir = repo.WorkDir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git count-objects failed: %v", err)
+	}
+
+	// sizes are left out: git reports blocks on disk rather than file sizes
+	want := parseVerbose(string(out))
+	got := parseVerbose(stats.String(true))
+	for _, field := range []string{"count", "in-pack", "packs", "prune-packable", "garbage"} {
+		if got[field] != want[field] {
+			t.Errorf("%s = %s, git reports %s", field, got[field], want[field])
+		}
+	}
+}
+
+func parseVerbose(out string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if name, value, ok := strings.Cut(line, ": "); ok {
+			fields[name] = value
+		}
+	}
+	return fields
+}
@@ -0,0 +1,307 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// CombinedLine is a line of a merge's combined diff. Marks has a column per
+// parent: '+' for a result line that parent lacks, '-' for a parent line the
+// result lost, and ' ' otherwise.
+type CombinedLine struct {
+	Marks   string
+	Content string
+}
+
+// removed reports whether l is a parent line rather than a result line
+func (l CombinedLine) removed() bool { return strings.ContainsRune(l.Marks, '-') }
+
+type CombinedHunk struct {
+	// OldStarts and OldCounts hold each parent's range, in parent order
+	OldStarts []int
+	OldCounts []int
+	NewStart  int
+	NewCount  int
+	Lines     []CombinedLine
+}
+
+// CombinedDiff is the "diff --cc" of one path of a merge: the result against
+// every parent at once, keeping only hunks where the result differs from
+// all of them
+type CombinedDiff struct {
+	Path  string
+	Hunks []CombinedHunk
+	// Binary is set when any side is binary; no lines are diffed then
+	Binary bool
+}
+
+func (cd *CombinedDiff) String() string {
+	if cd.Binary {
+		return display.FormatBinaryDiff(cd.Path) + "\n"
+	}
+
+	hunks := make([]display.CombinedDiffHunk, len(cd.Hunks))
+	for i, hunk := range cd.Hunks {
+		lines := make([]display.CombinedDiffLine, len(hunk.Lines))
+		for j, line := range hunk.Lines {
+			lines[j] = display.CombinedDiffLine{Marks: line.Marks, Content: line.Content}
+		}
+		hunks[i] = display.CombinedDiffHunk{
+			OldStarts: hunk.OldStarts,
+			OldCounts: hunk.OldCounts,
+			NewStart:  hunk.NewStart,
+			NewCount:  hunk.NewCount,
+			Lines:     lines,
+		}
+	}
+	return display.FormatCombinedDiff(cd.Path, hunks)
+}
+
+// CombinedDiffs diffs a merge commit against all of its parents. Like git's
+// --cc, a path is shown only when the merge took it from none of the
+// parents, and within it only the hunks that differ from every parent.
+func CombinedDiffs(repo *repository.Repository, commitHash string, contextLines int) ([]*CombinedDiff, error) {
+	commit, err := repo.LoadCommit(commitHash)
+	if err != nil {
+		return nil, errors.NewGitError("diff", commitHash, err)
+	}
+	parents := commit.Parents()
+	if len(parents) < 2 {
+		return nil, errors.NewGitError("diff", commitHash, fmt.Errorf("not a merge commit"))
+	}
+
+	files, err := checkout.TreeFiles(repo, commit.Tree())
+	if err != nil {
+		return nil, err
+	}
+	parentFiles := make([]map[string]objects.TreeEntry, len(parents))
+	for i, parent := range parents {
+		if parentFiles[i], err = checkout.CommitFiles(repo, parent); err != nil {
+			return nil, err
+		}
+	}
+
+	var diffs []*CombinedDiff
+
+	for _, path := range checkout.DiffFiles(parentFiles[0], files) {
+		if !differsFromAll(path, files, parentFiles) {
+			continue
+		}
+
+		content, big, err := entryContent(repo, files, path)
+		if err != nil {
+			return nil, errors.NewGitError("diff", path, err)
+		}
+		binary := big || isBinary(content)
+
+		parentContents := make([][]byte, len(parents))
+		for i := range parents {
+			parentContents[i], big, err = entryContent(repo, parentFiles[i], path)
+			if err != nil {
+				return nil, errors.NewGitError("diff", path, err)
+			}
+			binary = binary || big || isBinary(parentContents[i])
+		}
+
+		if binary {
+			diffs = append(diffs, &CombinedDiff{Path: path, Binary: true})
+			continue
+		}
+		hunks := combinedHunks(combineLines(parentContents, content), len(parents), contextLines)
+		if len(hunks) > 0 {
+			diffs = append(diffs, &CombinedDiff{Path: path, Hunks: hunks})
+		}
+	}
+
+	return diffs, nil
+}
+
+// differsFromAll reports whether the result's entry for path, or its
+// absence, matches none of the parents'
+func differsFromAll(path string, files map[string]objects.TreeEntry, parentFiles []map[string]objects.TreeEntry) bool {
+	entry, ok := files[path]
+	for _, parent := range parentFiles {
+		parentEntry, parentOk := parent[path]
+		if ok == parentOk && entry.Hash == parentEntry.Hash && entry.Mode == parentEntry.Mode {
+			return false
+		}
+	}
+	return true
+}
+
+// combineLines lays the result's lines out with the lines each parent lost
+// before the result line they were removed ahead of. A line several parents
+// lost at the same place is shown once, marked in each of their columns.
+func combineLines(parents [][]byte, result []byte) []CombinedLine {
+	resultLines := splitLines(result)
+	marks := make([][]byte, len(resultLines))
+	for i := range marks {
+		marks[i] = []byte(strings.Repeat(" ", len(parents)))
+	}
+	// lost[i] holds the removed lines shown before result line i
+	lost := make([][]CombinedLine, len(resultLines)+1)
+
+	for p, parent := range parents {
+		// lines removed within a run of changes go before the run's first
+		// result line, as in a unified diff
+		var removed []string
+		i, anchor := 0, 0
+		flush := func() {
+			lost[anchor] = mergeLost(lost[anchor], removed, p, len(parents))
+			removed = nil
+		}
+		for _, line := range textDiff(parent, result, "", "", 0).Lines {
+			switch line.Type {
+			case LineRemoved:
+				removed = append(removed, line.Content)
+			case LineAdded:
+				marks[i][p] = '+'
+				i++
+			default:
+				flush()
+				i++
+				anchor = i
+			}
+		}
+		flush()
+	}
+
+	var lines []CombinedLine
+	for i := range lost {
+		lines = append(lines, lost[i]...)
+		if i < len(resultLines) {
+			lines = append(lines, CombinedLine{Marks: string(marks[i]), Content: strings.TrimSuffix(resultLines[i], noNewlineMark)})
+		}
+	}
+	return lines
+}
+
+// mergeLost marks the lines parent p lost in column p, reusing, in order,
+// lines other parents lost with the same content. Like git, a line with no
+// match goes last.
+func mergeLost(lost []CombinedLine, removed []string, p, parents int) []CombinedLine {
+	next := 0
+	for _, content := range removed {
+		at := slices.IndexFunc(lost[next:], func(l CombinedLine) bool {
+			return l.Content == content && l.Marks[p] == ' '
+		})
+		if at < 0 {
+			marks := []byte(strings.Repeat(" ", parents))
+			marks[p] = '-'
+			lost = append(lost, CombinedLine{Marks: string(marks), Content: content})
+			next = len(lost)
+			continue
+		}
+		at += next
+		marks := []byte(lost[at].Marks)
+		marks[p] = '-'
+		lost[at].Marks = string(marks)
+		next = at + 1
+	}
+	return lost
+}
+
+// combinedHunks groups runs of changed lines into hunks with context, after
+// dropping the runs some parent has no changes in: there the merge simply
+// took that parent's side
+func combinedHunks(lines []CombinedLine, parents, contextLines int) []CombinedHunk {
+	unchanged := strings.Repeat(" ", parents)
+
+	var regions []ChangeRegion
+	for i := 0; i < len(lines); {
+		if lines[i].Marks == unchanged {
+			i++
+			continue
+		}
+		start := i
+		for i < len(lines) && lines[i].Marks != unchanged {
+			i++
+		}
+		if changedByAll(lines[start:i], parents) {
+			regions = append(regions, ChangeRegion{Start: start, End: i - 1})
+		}
+	}
+	if len(regions) == 0 {
+		return nil
+	}
+
+	contextLines = max(contextLines, 0)
+	var hunks []CombinedHunk
+	start := max(0, regions[0].Start-contextLines)
+	end := min(len(lines), regions[0].End+contextLines+1)
+	for _, region := range regions[1:] {
+		nextStart := max(0, region.Start-contextLines)
+		if nextStart <= end {
+			end = min(len(lines), region.End+contextLines+1)
+			continue
+		}
+		hunks = append(hunks, createCombinedHunk(lines, parents, start, end))
+		start, end = nextStart, min(len(lines), region.End+contextLines+1)
+	}
+	hunks = append(hunks, createCombinedHunk(lines, parents, start, end))
+
+	return hunks
+}
+
+func changedByAll(lines []CombinedLine, parents int) bool {
+	for p := range parents {
+		if !slices.ContainsFunc(lines, func(l CombinedLine) bool { return l.Marks[p] != ' ' }) {
+			return false
+		}
+	}
+	return true
+}
+
+// createCombinedHunk builds the hunk for lines[start:end]. A parent's side
+// holds the result lines it did not lack and the lines it lost.
+func createCombinedHunk(lines []CombinedLine, parents, start, end int) CombinedHunk {
+	inParent := func(l CombinedLine, p int) bool {
+		if l.removed() {
+			return l.Marks[p] == '-'
+		}
+		return l.Marks[p] != '+'
+	}
+
+	hunk := CombinedHunk{
+		OldStarts: make([]int, parents),
+		OldCounts: make([]int, parents),
+		Lines:     lines[start:end],
+	}
+	for i, line := range lines[:end] {
+		before := i < start
+		for p := range parents {
+			if !inParent(line, p) {
+				continue
+			}
+			if before {
+				hunk.OldStarts[p]++
+			} else {
+				hunk.OldCounts[p]++
+			}
+		}
+		if !line.removed() {
+			if before {
+				hunk.NewStart++
+			} else {
+				hunk.NewCount++
+			}
+		}
+	}
+
+	for p := range parents {
+		if hunk.OldCounts[p] > 0 {
+			hunk.OldStarts[p]++
+		}
+	}
+	if hunk.NewCount > 0 {
+		hunk.NewStart++
+	}
+	return hunk
+}
@@ -1,12 +1,19 @@
 package diff
 
 import (
-	"bufio"
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/commands/status"
+	"github.com/unkn0wn-root/git-go/internal/core/attributes"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
@@ -16,10 +23,23 @@ import (
 )
 
 const (
-	maxLinesForMemory = 10000
-	chunkSize         = 1000
+	maxLinesForMemory   = 10000
+	chunkSize           = 1000
+	defaultContextLines = 3
+	// binarySniffLen is how much of a file is searched for a NUL byte, as
+	// git does, to decide it is binary
+	binarySniffLen = 8000
+	// noNewlineMark ends the last line of a file missing its final newline
+	// while lines are compared; no line split at newlines can contain it
+	noNewlineMark = "\n"
+	// subprojectPrefix starts the one line a gitlink is diffed as
+	subprojectPrefix = "Subproject commit "
 )
 
+// bigFileThreshold is the blob size above which content is not loaded into
+// memory and the file is diffed as binary, like git's core.bigFileThreshold
+var bigFileThreshold int64 = 512 << 20
+
 type LineType int
 
 const (
@@ -46,6 +66,9 @@ type DiffLine struct {
 	Content string
 	OldLine int
 	NewLine int
+	// NoNewline is set on the last line of a file that does not end in a
+	// newline, which is shown followed by "\ No newline at end of file"
+	NoNewline bool
 }
 
 type DiffHunk struct {
@@ -61,19 +84,38 @@ type FileDiff struct {
 	NewPath string
 	Lines   []DiffLine
 	Hunks   []DiffHunk
+	// Binary is set when either side is binary; no lines are diffed then
+	Binary bool
+	// NewMode is set when the diff creates the file and DeletedMode when it
+	// deletes it, the missing side then being shown as /dev/null
+	NewMode     objects.FileMode
+	DeletedMode objects.FileMode
 }
 
 func (fd *FileDiff) String() string {
+	if fd.Binary {
+		return display.FormatBinaryDiff(fd.NewPath) + "\n"
+	}
+
+	header := display.FormatDiffHeader(fd.OldPath, fd.NewPath)
+	switch {
+	case fd.NewMode != 0:
+		header = display.FormatNewFileHeader(fd.NewPath, fd.NewMode.String())
+	case fd.DeletedMode != 0:
+		header = display.FormatDeletedFileHeader(fd.OldPath, fd.DeletedMode.String())
+	}
+
 	if len(fd.Hunks) > 0 {
 		hunks := make([]display.DiffHunk, len(fd.Hunks))
 		for i, hunk := range fd.Hunks {
 			lines := make([]display.DiffLine, len(hunk.Lines))
 			for j, line := range hunk.Lines {
 				lines[j] = display.DiffLine{
-					Type:    display.DiffLineType(line.Type),
-					Content: line.Content,
-					OldLine: line.OldLine,
-					NewLine: line.NewLine,
+					Type:      display.DiffLineType(line.Type),
+					Content:   line.Content,
+					OldLine:   line.OldLine,
+					NewLine:   line.NewLine,
+					NoNewline: line.NoNewline,
 				}
 			}
 			hunks[i] = display.DiffHunk{
@@ -84,28 +126,45 @@ func (fd *FileDiff) String() string {
 				Lines:    lines,
 			}
 		}
-		return display.FormatFileHunks(fd.OldPath, fd.NewPath, hunks)
+		return header + display.FormatHunks(hunks)
 	}
 
 	// fallback to original line-based format if no hunks
 	lines := make([]display.DiffLine, len(fd.Lines))
 	for i, line := range fd.Lines {
 		lines[i] = display.DiffLine{
-			Type:    display.DiffLineType(line.Type),
-			Content: line.Content,
-			OldLine: line.OldLine,
-			NewLine: line.NewLine,
+			Type:      display.DiffLineType(line.Type),
+			Content:   line.Content,
+			OldLine:   line.OldLine,
+			NewLine:   line.NewLine,
+			NoNewline: line.NoNewline,
 		}
 	}
 
-	return display.FormatFileDiff(fd.OldPath, fd.NewPath, lines)
+	return header + display.FormatLines(lines)
 }
 
 func ComputeFileDiff(oldContent, newContent []byte, oldPath, newPath string) *FileDiff {
-	return ComputeFileDiffWithContext(oldContent, newContent, oldPath, newPath, 3)
+	return ComputeFileDiffWithContext(oldContent, newContent, oldPath, newPath, defaultContextLines)
+}
+
+// SetContext regroups the diff's lines into hunks with the given number of
+// context lines. A count covering the whole file gives a single hunk.
+func (fd *FileDiff) SetContext(contextLines int) {
+	if !fd.Binary {
+		fd.Hunks = createOptimizedHunks(fd.Lines, contextLines)
+	}
 }
 
 func ComputeFileDiffWithContext(oldContent, newContent []byte, oldPath, newPath string, contextLines int) *FileDiff {
+	if isBinary(oldContent) || isBinary(newContent) {
+		return &FileDiff{OldPath: oldPath, NewPath: newPath, Binary: true}
+	}
+	return textDiff(oldContent, newContent, oldPath, newPath, contextLines)
+}
+
+// textDiff diffs content line by line without checking for binary content
+func textDiff(oldContent, newContent []byte, oldPath, newPath string, contextLines int) *FileDiff {
 	oldLines := splitLines(oldContent)
 	newLines := splitLines(newContent)
 	// empty files
@@ -125,7 +184,7 @@ func ComputeFileDiffWithContext(oldContent, newContent []byte, oldPath, newPath
 
 	// LCS algorithm to compute optimal diff
 	lcs := longestCommonSubsequence(oldLines, newLines)
-	diffLines := generateDiffLines(oldLines, newLines, lcs)
+	diffLines := markNoNewline(generateDiffLines(oldLines, newLines, lcs))
 	hunks := createOptimizedHunks(diffLines, contextLines)
 
 	return &FileDiff{
@@ -188,6 +247,7 @@ func computeLargeFileDiff(oldLines, newLines []string, oldPath, newPath string,
 		}
 	}
 
+	diffLines = markNoNewline(diffLines)
 	hunks := createOptimizedHunks(diffLines, contextLines)
 
 	return &FileDiff{
@@ -206,152 +266,437 @@ func getChunk(lines []string, start, size int) []string {
 	return lines[start:end]
 }
 
-func ShowWorkingTreeDiff(repo *repository.Repository, paths []string) error {
-	idx := index.New(repo.GitDir)
-	if err := idx.Load(); err != nil {
-		return errors.NewGitError("diff", "", err)
+// Stat counts the lines the diff adds and removes
+func (fd *FileDiff) Stat() (added, removed int) {
+	for _, line := range fd.Lines {
+		switch line.Type {
+		case LineAdded:
+			added++
+		case LineRemoved:
+			removed++
+		}
 	}
+	return added, removed
+}
 
-	entries := idx.GetAll()
+// Stats summarises diffs for display.FormatDiffStat
+func Stats(diffs []*FileDiff) []display.DiffStat {
+	stats := make([]display.DiffStat, len(diffs))
+	for i, fd := range diffs {
+		added, removed := fd.Stat()
+		stats[i] = display.DiffStat{Path: fd.NewPath, Added: added, Removed: removed, Binary: fd.Binary}
+	}
+	return stats
+}
 
-	for path, entry := range entries {
-		if len(paths) > 0 && !utils.ContainsPath(paths, path) {
-			continue
-		}
+// attributeDiff diffs a path, letting its attributes override binary
+// detection: "-diff", or "binary", always diffs it as binary, and "diff" or
+// "text" always line by line
+func attributeDiff(attrs attributes.Attributes, oldContent, newContent []byte, path string) *FileDiff {
+	switch {
+	case attrs.IsUnset("diff"):
+		return &FileDiff{OldPath: path, NewPath: path, Binary: true}
+	case attrs.IsSet("diff") || attrs.IsSet("text"):
+		return textDiff(oldContent, newContent, path, path, defaultContextLines)
+	}
+	return ComputeFileDiff(oldContent, newContent, path, path)
+}
 
-		fullPath := filepath.Join(repo.WorkDir, path)
-		workingContent, err := os.ReadFile(fullPath)
+// TreeDiffs diffs every path whose entry differs between two flattened
+// trees, in path order. A path missing on one side diffs against empty
+// content.
+func TreeDiffs(repo *repository.Repository, oldFiles, newFiles map[string]objects.TreeEntry) ([]*FileDiff, error) {
+	matcher, err := repo.Attributes()
+	if err != nil {
+		return nil, errors.NewGitError("diff", "", err)
+	}
+
+	var diffs []*FileDiff
+
+	for _, path := range checkout.DiffFiles(oldFiles, newFiles) {
+		oldContent, oldBig, err := entryContent(repo, oldFiles, path)
 		if err != nil {
-			continue
+			return nil, errors.NewGitError("diff", path, err)
 		}
-
-		obj, err := repo.LoadObject(entry.Hash)
+		newContent, newBig, err := entryContent(repo, newFiles, path)
 		if err != nil {
-			continue
+			return nil, errors.NewGitError("diff", path, err)
 		}
 
-		blob, ok := obj.(*objects.Blob)
-		if !ok {
-			continue
+		fd := &FileDiff{OldPath: path, NewPath: path, Binary: true}
+		if !oldBig && !newBig {
+			fd = attributeDiff(matcher.Resolve(path), oldContent, newContent, path)
 		}
-
-		indexContent := blob.Content()
-
-		if !bytes.Equal(indexContent, workingContent) {
-			fileDiff := ComputeFileDiff(indexContent, workingContent, path, path)
-			fmt.Print(fileDiff.String())
+		if entry, ok := oldFiles[path]; !ok {
+			fd.NewMode = newFiles[path].Mode
+		} else if _, ok := newFiles[path]; !ok {
+			fd.DeletedMode = entry.Mode
 		}
+		diffs = append(diffs, fd)
 	}
 
-	return nil
+	return diffs, nil
 }
 
-func ShowStagedDiff(repo *repository.Repository, paths []string) error {
-	idx := index.New(repo.GitDir)
-	if err := idx.Load(); err != nil {
-		return errors.NewGitError("diff", "", err)
+// CommitDiffs diffs a commit against its first parent, or against an empty
+// tree for a root commit
+func CommitDiffs(repo *repository.Repository, commitHash string) ([]*FileDiff, error) {
+	commit, err := repo.LoadCommit(commitHash)
+	if err != nil {
+		return nil, errors.NewGitError("diff", commitHash, err)
 	}
 
-	headHash, err := repo.GetHead()
+	newFiles, err := checkout.TreeFiles(repo, commit.Tree())
 	if err != nil {
-		return errors.NewGitError("diff", "", err)
+		return nil, err
 	}
 
-	if headHash == "" {
-		entries := idx.GetAll()
-		for path := range entries {
-			if len(paths) > 0 && !utils.ContainsPath(paths, path) {
-				continue
-			}
-			fmt.Printf("%s\n", display.FormatNewFile(path))
+	oldFiles := map[string]objects.TreeEntry{}
+	if parents := commit.Parents(); len(parents) > 0 {
+		if oldFiles, err = checkout.CommitFiles(repo, parents[0]); err != nil {
+			return nil, err
 		}
-		return nil
 	}
 
-	headCommit, err := repo.LoadObject(headHash)
+	return TreeDiffs(repo, oldFiles, newFiles)
+}
+
+// isBinary reports whether content has a NUL byte near its start
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLen)], 0) >= 0
+}
+
+func entryContent(repo *repository.Repository, files map[string]objects.TreeEntry, path string) ([]byte, bool, error) {
+	entry, ok := files[path]
+	if !ok {
+		return nil, false, nil
+	}
+	// a gitlink names a commit of the submodule, shown as git shows it
+	if entry.IsSubmodule() {
+		return []byte(subprojectPrefix + entry.Hash + "\n"), false, nil
+	}
+	return blobContent(repo, entry.Hash)
+}
+
+// blobContent loads a blob, unless it is bigger than bigFileThreshold, in
+// which case it reports big without reading it
+func blobContent(repo *repository.Repository, blobHash string) ([]byte, bool, error) {
+	reader, size, err := repo.OpenBlob(blobHash)
 	if err != nil {
-		return errors.NewGitError("diff", "", fmt.Errorf("load HEAD commit: %w", err))
+		return nil, false, err
 	}
+	defer reader.Close()
 
-	commit, ok := headCommit.(*objects.Commit)
-	if !ok {
-		return errors.NewGitError("diff", "", fmt.Errorf("HEAD is not a commit"))
+	if size > bigFileThreshold {
+		return nil, true, nil
 	}
+	content, err := io.ReadAll(reader)
+	return content, false, err
+}
 
-	headTree, err := repo.LoadObject(commit.Tree())
+// fileBlobHash hashes a working tree file as a blob without reading it into
+// memory
+func fileBlobHash(path string, size int64) (string, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return errors.NewGitError("diff", "", fmt.Errorf("load HEAD tree: %w", err))
+		return "", err
 	}
+	defer file.Close()
 
-	tree, ok := headTree.(*objects.Tree)
-	if !ok {
-		return errors.NewGitError("diff", "", fmt.Errorf("HEAD tree is not a tree object"))
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", objects.ObjectTypeBlob, size)
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WorkingTreeDiffs diffs the index against the working tree, in path order.
+// A tracked file missing from the working tree diffs as deleted.
+func WorkingTreeDiffs(repo *repository.Repository, paths []string) ([]*FileDiff, error) {
+	return WorkingTreeDiffsWithOptions(repo, paths, DiffOptions{})
+}
+
+// WorkingTreeDiffsWithOptions is WorkingTreeDiffs that, with
+// IncludeUntracked, also diffs every untracked, unignored file as added
+func WorkingTreeDiffsWithOptions(repo *repository.Repository, paths []string, options DiffOptions) ([]*FileDiff, error) {
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewGitError("diff", "", err)
 	}
 
-	headFiles := make(map[string]string)
-	for _, entry := range tree.Entries() {
-		headFiles[entry.Name] = entry.Hash
+	matcher, err := repo.Attributes()
+	if err != nil {
+		return nil, errors.NewGitError("diff", "", err)
 	}
 
 	entries := idx.GetAll()
-	for path, entry := range entries {
-		if len(paths) > 0 && !utils.ContainsPath(paths, path) {
+	sorted := make([]string, 0, len(entries))
+	for path := range entries {
+		if len(paths) == 0 || utils.ContainsPath(paths, path) {
+			sorted = append(sorted, path)
+		}
+	}
+	sort.Strings(sorted)
+
+	var diffs []*FileDiff
+	for _, path := range sorted {
+		if objects.FileMode(entries[path].Mode) == objects.FileModeCommit {
 			continue
 		}
 
-		headHash, existsInHead := headFiles[path]
+		fullPath := filepath.Join(repo.WorkDir, path)
+		info, err := os.Stat(fullPath)
+		if os.IsNotExist(err) {
+			indexContent, big, err := blobContent(repo, entries[path].Hash)
+			if err != nil {
+				continue
+			}
+			fd := &FileDiff{OldPath: path, NewPath: path, Binary: true}
+			if !big {
+				fd = attributeDiff(matcher.Resolve(path), indexContent, nil, path)
+			}
+			fd.DeletedMode = objects.FileMode(entries[path].Mode)
+			diffs = append(diffs, fd)
+			continue
+		}
+		if err != nil {
+			continue
+		}
 
-		if !existsInHead {
-			fmt.Printf("%s\n", display.FormatNewFile(path))
+		indexContent, big, err := blobContent(repo, entries[path].Hash)
+		if err != nil {
 			continue
 		}
 
-		if headHash == entry.Hash {
+		if big || info.Size() > bigFileThreshold {
+			workingHash, err := fileBlobHash(fullPath, info.Size())
+			if err == nil && workingHash != entries[path].Hash {
+				diffs = append(diffs, &FileDiff{OldPath: path, NewPath: path, Binary: true})
+			}
 			continue
 		}
 
-		headObj, err := repo.LoadObject(headHash)
+		workingContent, err := os.ReadFile(fullPath)
 		if err != nil {
-			return errors.NewGitError("diff", path, fmt.Errorf("load HEAD object: %w", err))
+			continue
 		}
+		autoCRLF, err := repo.AutoCRLFFor(path)
+		if err != nil {
+			return nil, errors.NewGitError("diff", path, err)
+		}
+		workingContent = autoCRLF.ToIndex(workingContent)
 
-		indexObj, err := repo.LoadObject(entry.Hash)
+		if !bytes.Equal(indexContent, workingContent) {
+			diffs = append(diffs, attributeDiff(matcher.Resolve(path), indexContent, workingContent, path))
+		}
+	}
+
+	if options.IncludeUntracked {
+		untracked, err := untrackedDiffs(repo, paths, matcher)
 		if err != nil {
-			return errors.NewGitError("diff", path, fmt.Errorf("load index object: %w", err))
+			return nil, err
 		}
+		diffs = append(diffs, untracked...)
+		sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].NewPath < diffs[j].NewPath })
+	}
+
+	return diffs, nil
+}
 
-		headBlob, ok := headObj.(*objects.Blob)
-		if !ok {
+// untrackedDiffs diffs every untracked file that .gitignore does not
+// exclude against empty content
+func untrackedDiffs(repo *repository.Repository, paths []string, matcher *attributes.Matcher) ([]*FileDiff, error) {
+	result, err := status.GetStatusWithOptions(repo, status.StatusOptions{Untracked: status.UntrackedAll})
+	if err != nil {
+		return nil, errors.NewGitError("diff", "", err)
+	}
+
+	var diffs []*FileDiff
+	for _, entry := range result.Entries {
+		path := entry.Path
+		if entry.WorkStatus != status.StatusUntracked || (len(paths) > 0 && !utils.ContainsPath(paths, path)) {
 			continue
 		}
 
-		indexBlob, ok := indexObj.(*objects.Blob)
-		if !ok {
+		fullPath := filepath.Join(repo.WorkDir, path)
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			continue
+		}
+		mode := objects.FileModeBlob
+		if info.Mode()&0o111 != 0 {
+			mode = objects.FileModeExecutable
+		}
+		if info.Size() > bigFileThreshold {
+			diffs = append(diffs, &FileDiff{OldPath: path, NewPath: path, Binary: true, NewMode: mode})
 			continue
 		}
 
-		fileDiff := ComputeFileDiff(headBlob.Content(), indexBlob.Content(), path, path)
-		fmt.Print(fileDiff.String())
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		autoCRLF, err := repo.AutoCRLFFor(path)
+		if err != nil {
+			return nil, errors.NewGitError("diff", path, err)
+		}
+		fd := attributeDiff(matcher.Resolve(path), nil, autoCRLF.ToIndex(content), path)
+		fd.NewMode = mode
+		diffs = append(diffs, fd)
+	}
+	return diffs, nil
+}
+
+// StagedDiffs diffs HEAD against the index, in path order
+func StagedDiffs(repo *repository.Repository, paths []string) ([]*FileDiff, error) {
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewGitError("diff", "", err)
+	}
+
+	headHash, err := repo.GetHead()
+	if err != nil {
+		return nil, errors.NewGitError("diff", "", err)
+	}
+
+	headFiles := map[string]objects.TreeEntry{}
+	if headHash != "" {
+		if headFiles, err = checkout.CommitFiles(repo, headHash); err != nil {
+			return nil, errors.NewGitError("diff", "", fmt.Errorf("load HEAD tree: %w", err))
+		}
+	}
+
+	indexFiles := checkout.IndexFiles(idx)
+	if len(paths) > 0 {
+		headFiles = filterPaths(headFiles, paths)
+		indexFiles = filterPaths(indexFiles, paths)
 	}
 
+	return TreeDiffs(repo, headFiles, indexFiles)
+}
+
+func filterPaths(files map[string]objects.TreeEntry, paths []string) map[string]objects.TreeEntry {
+	filtered := make(map[string]objects.TreeEntry)
+	for path, entry := range files {
+		if utils.ContainsPath(paths, path) {
+			filtered[path] = entry
+		}
+	}
+	return filtered
+}
+
+// DiffOptions controls how ShowWorkingTreeDiff, ShowStagedDiff and
+// ShowCommitDiff print
+type DiffOptions struct {
+	// ContextLines is the number of unchanged lines around each change;
+	// zero means the default of 3 unless UnifiedZero is set
+	ContextLines int
+	// UnifiedZero shows no context at all, as -U0 does
+	UnifiedZero bool
+	// Stat prints a per-file summary instead of the patch
+	Stat bool
+	// IncludeUntracked shows untracked files as added in working tree diffs
+	IncludeUntracked bool
+}
+
+func (o DiffOptions) contextLines() int {
+	switch {
+	case o.UnifiedZero:
+		return 0
+	case o.ContextLines > 0:
+		return o.ContextLines
+	default:
+		return defaultContextLines
+	}
+}
+
+func ShowWorkingTreeDiff(repo *repository.Repository, paths []string, options DiffOptions) error {
+	diffs, err := WorkingTreeDiffsWithOptions(repo, paths, options)
+	if err != nil {
+		return err
+	}
+	printDiffs(diffs, options)
 	return nil
 }
 
+func ShowStagedDiff(repo *repository.Repository, paths []string, options DiffOptions) error {
+	diffs, err := StagedDiffs(repo, paths)
+	if err != nil {
+		return err
+	}
+	printDiffs(diffs, options)
+	return nil
+}
+
+// ShowCommitDiff prints the changes a commit introduces: a combined diff
+// against all parents for a merge, otherwise a diff against its parent.
+// A merge's --stat is taken against its first parent, as git does.
+func ShowCommitDiff(repo *repository.Repository, commitHash string, options DiffOptions) error {
+	commit, err := repo.LoadCommit(commitHash)
+	if err != nil {
+		return errors.NewGitError("diff", commitHash, err)
+	}
+
+	if len(commit.Parents()) > 1 && !options.Stat {
+		diffs, err := CombinedDiffs(repo, commitHash, options.contextLines())
+		if err != nil {
+			return err
+		}
+		for _, combined := range diffs {
+			fmt.Print(combined.String())
+		}
+		return nil
+	}
+
+	diffs, err := CommitDiffs(repo, commitHash)
+	if err != nil {
+		return err
+	}
+	printDiffs(diffs, options)
+	return nil
+}
+
+func printDiffs(diffs []*FileDiff, options DiffOptions) {
+	if options.Stat {
+		fmt.Print(display.FormatDiffStat(Stats(diffs)))
+		return
+	}
+	for _, fileDiff := range diffs {
+		fileDiff.SetContext(options.contextLines())
+		fmt.Print(fileDiff.String())
+	}
+}
+
+// splitLines splits content into lines without their line endings. A last
+// line with no newline keeps noNewlineMark at its end instead, so that it
+// never matches the same text followed by a newline.
 func splitLines(content []byte) []string {
 	if len(content) == 0 {
 		return []string{}
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-	var lines []string
-
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	text, complete := strings.CutSuffix(string(content), "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	if !complete {
+		lines[len(lines)-1] += noNewlineMark
 	}
 
 	return lines
 }
 
+// markNoNewline moves noNewlineMark from the content of diffLines into
+// their NoNewline flag
+func markNoNewline(diffLines []DiffLine) []DiffLine {
+	for i := range diffLines {
+		diffLines[i].Content, diffLines[i].NoNewline = strings.CutSuffix(diffLines[i].Content, noNewlineMark)
+	}
+	return diffLines
+}
+
 func longestCommonSubsequence(a, b []string) [][]int {
 	m, n := len(a), len(b)
 	// empty sequences
@@ -465,14 +810,8 @@ func createOptimizedHunks(diffLines []DiffLine, contextLines int) []DiffHunk {
 		return []DiffHunk{}
 	}
 
-	// 2 pass: create hunks with context and merge overlapping ones
-	hunks := createHunksFromRegions(diffLines, changeRegions, contextLines)
-
-	// 3 pass: merge overlapping hunks and split oversized ones
-	hunks = mergeOverlappingHunks(hunks)
-	hunks = splitOversizedHunks(hunks, 100) // Max 100 lines per hunk
-
-	return hunks
+	// 2 pass: widen regions by the context and merge those that then touch
+	return createHunksFromRegions(diffLines, changeRegions, max(contextLines, 0))
 }
 
 type ChangeRegion struct {
@@ -516,120 +855,46 @@ func findChangeRegions(diffLines []DiffLine) []ChangeRegion {
 func createHunksFromRegions(diffLines []DiffLine, regions []ChangeRegion, contextLines int) []DiffHunk {
 	var hunks []DiffHunk
 
-	for _, region := range regions {
-		// calculate hunk boundaries with context
-		hunkStart := utils.Max(0, region.Start-contextLines)
-		hunkEnd := utils.Min(len(diffLines), region.End+contextLines+1)
-
-		var hunkLines []DiffLine
-		for i := hunkStart; i < hunkEnd; i++ {
-			hunkLines = append(hunkLines, diffLines[i])
-		}
-
-		if len(hunkLines) > 0 {
-			hunk := createHunkFromLines(hunkLines)
-			hunks = append(hunks, hunk)
+	start := utils.Max(0, regions[0].Start-contextLines)
+	end := utils.Min(len(diffLines), regions[0].End+contextLines+1)
+	for _, region := range regions[1:] {
+		nextStart := utils.Max(0, region.Start-contextLines)
+		if nextStart <= end {
+			end = utils.Min(len(diffLines), region.End+contextLines+1)
+			continue
 		}
+		hunks = append(hunks, createHunk(diffLines, start, end))
+		start, end = nextStart, utils.Min(len(diffLines), region.End+contextLines+1)
 	}
+	hunks = append(hunks, createHunk(diffLines, start, end))
 
 	return hunks
 }
 
-// ceate a hunk from a slice of diff lines
-func createHunkFromLines(lines []DiffLine) DiffHunk {
-	hunk := DiffHunk{Lines: lines}
-
-	// find first line with valid line numbers for start positions
-	for _, line := range lines {
-		if line.OldLine > 0 || line.NewLine > 0 {
-			if line.OldLine > 0 {
-				hunk.OldStart = line.OldLine
-			} else {
-				hunk.OldStart = 1
-			}
-			if line.NewLine > 0 {
-				hunk.NewStart = line.NewLine
-			} else {
-				hunk.NewStart = 1
-			}
-			break
-		}
-	}
-
-	calculateHunkCounts(&hunk)
-	return hunk
-}
-
-// merges hunks that have overlapping context
-func mergeOverlappingHunks(hunks []DiffHunk) []DiffHunk {
-	if len(hunks) <= 1 {
-		return hunks
-	}
-
-	var merged []DiffHunk
-	current := hunks[0]
-
-	for i := 1; i < len(hunks); i++ {
-		next := hunks[i]
-
-		// check if hunks overlap or are very close
-		currentEnd := current.OldStart + current.OldCount
-		nextStart := next.OldStart
-
-		if nextStart <= currentEnd+6 { // Merge if within 6 lines
-			current.Lines = append(current.Lines, next.Lines...)
-			calculateHunkCounts(&current)
-		} else {
-			merged = append(merged, current)
-			current = next
+// createHunk builds the hunk for diffLines[start:end]. Like git, a side with
+// no lines in the hunk starts at the line before it.
+func createHunk(diffLines []DiffLine, start, end int) DiffHunk {
+	oldBefore, newBefore := 0, 0
+	for _, line := range diffLines[:start] {
+		if line.Type != LineAdded {
+			oldBefore++
 		}
-	}
-
-	merged = append(merged, current)
-	return merged
-}
-
-// splits hunks that are too large
-func splitOversizedHunks(hunks []DiffHunk, maxLines int) []DiffHunk {
-	var result []DiffHunk
-	for _, hunk := range hunks {
-		if len(hunk.Lines) <= maxLines {
-			result = append(result, hunk)
-			continue
+		if line.Type != LineRemoved {
+			newBefore++
 		}
-
-		split := splitHunk(hunk, maxLines)
-		result = append(result, split...)
 	}
 
-	return result
-}
-
-// splits a single hunk into multiple smaller hunks
-func splitHunk(hunk DiffHunk, maxLines int) []DiffHunk {
-	var result []DiffHunk
-	lines := hunk.Lines
-	for i := 0; i < len(lines); i += maxLines {
-		end := utils.Min(len(lines), i+maxLines)
-		subLines := lines[i:end]
+	hunk := DiffHunk{Lines: diffLines[start:end]}
+	calculateHunkCounts(&hunk)
 
-		if len(subLines) > 0 {
-			subHunk := createHunkFromLines(subLines)
-			result = append(result, subHunk)
-		}
+	hunk.OldStart, hunk.NewStart = oldBefore, newBefore
+	if hunk.OldCount > 0 {
+		hunk.OldStart++
 	}
-
-	return result
-}
-
-func hasChangeInRange(diffLines []DiffLine, start, range_ int) bool {
-	end := utils.Min(len(diffLines), start+range_)
-	for i := start; i < end; i++ {
-		if diffLines[i].Type == LineAdded || diffLines[i].Type == LineRemoved {
-			return true
-		}
+	if hunk.NewCount > 0 {
+		hunk.NewStart++
 	}
-	return false
+	return hunk
 }
 
 // calculateHunkCounts calculates the old and new line counts for a hunk
@@ -1,9 +1,19 @@
 package diff
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/display"
 )
 
 func TestComputeFileDiff(t *testing.T) {
@@ -57,6 +67,29 @@ func TestIdenticalFiles(t *testing.T) {
 	}
 }
 
+func TestComputeFileDiffTrailingNewline(t *testing.T) {
+	noNewline := DiffLine{Type: LineRemoved, Content: "b", OldLine: 2, NoNewline: true}
+	withNewline := DiffLine{Type: LineAdded, Content: "b", NewLine: 2}
+
+	added := ComputeFileDiff([]byte("a\nb"), []byte("a\nb\n"), "f", "f")
+	require.Len(t, added.Hunks, 1)
+	assert.ElementsMatch(t, []DiffLine{noNewline, withNewline}, added.Hunks[0].Lines[1:])
+	assert.Contains(t, added.String(), "-b\n\\ No newline at end of file\n")
+
+	noNewline.Type, noNewline.OldLine, noNewline.NewLine = LineAdded, 0, 2
+	withNewline.Type, withNewline.OldLine, withNewline.NewLine = LineRemoved, 2, 0
+	removed := ComputeFileDiff([]byte("a\nb\n"), []byte("a\nb"), "f", "f")
+	require.Len(t, removed.Hunks, 1)
+	assert.ElementsMatch(t, []DiffLine{noNewline, withNewline}, removed.Hunks[0].Lines[1:])
+	assert.Contains(t, removed.String(), "+b\n\\ No newline at end of file\n")
+
+	unchanged := ComputeFileDiff([]byte("a\nb"), []byte("A\nb"), "f", "f")
+	require.Len(t, unchanged.Hunks, 1)
+	last := unchanged.Hunks[0].Lines[len(unchanged.Hunks[0].Lines)-1]
+	assert.Equal(t, DiffLine{Type: LineContext, Content: "b", OldLine: 2, NewLine: 2, NoNewline: true}, last)
+	assert.Equal(t, 1, strings.Count(unchanged.String(), "No newline at end of file"))
+}
+
 func TestLineTypeString(t *testing.T) {
 	tests := []struct {
 		lineType LineType
@@ -94,3 +127,281 @@ func TestFileDiffString(t *testing.T) {
 	assert.Contains(t, result, "-removed line")
 	assert.Contains(t, result, "+added line")
 }
+
+func TestFileDiffStat(t *testing.T) {
+	fileDiff := ComputeFileDiff([]byte("a\nb\nc\n"), []byte("a\nB\nc\nd\ne\n"), "f.txt", "f.txt")
+
+	added, removed := fileDiff.Stat()
+	assert.Equal(t, 3, added)
+	assert.Equal(t, 1, removed)
+}
+
+func storeCommit(t *testing.T, repo *repository.Repository, files map[string]string, parents ...string) string {
+	t.Helper()
+	var entries []objects.TreeEntry
+	for path, content := range files {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		require.NoError(t, err)
+		entries = append(entries, objects.TreeEntry{Mode: objects.FileModeBlob, Name: path, Hash: blobHash})
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree(entries))
+	require.NoError(t, err)
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, sig, sig, "commit"))
+	require.NoError(t, err)
+	return commitHash
+}
+
+func TestCommitDiffs(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	root := storeCommit(t, repo, map[string]string{"a.txt": "1\n2\n", "gone.txt": "x\n"})
+	child := storeCommit(t, repo, map[string]string{"a.txt": "1\ntwo\n3\n", "new.txt": "n\n"}, root)
+
+	diffs, err := CommitDiffs(repo, root)
+	require.NoError(t, err)
+	assert.Equal(t, []display.DiffStat{
+		{Path: "a.txt", Added: 2},
+		{Path: "gone.txt", Added: 1},
+	}, Stats(diffs))
+
+	diffs, err = CommitDiffs(repo, child)
+	require.NoError(t, err)
+	assert.Equal(t, []display.DiffStat{
+		{Path: "a.txt", Added: 2, Removed: 1},
+		{Path: "gone.txt", Removed: 1},
+		{Path: "new.txt", Added: 1},
+	}, Stats(diffs))
+	assert.Equal(t, objects.FileModeBlob, diffs[1].DeletedMode)
+	assert.Equal(t, objects.FileModeBlob, diffs[2].NewMode)
+	assert.Zero(t, diffs[0].NewMode|diffs[0].DeletedMode)
+}
+
+func TestCombinedDiffs(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	base := storeCommit(t, repo, map[string]string{
+		"f.txt": "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		"g.txt": "g\n",
+	})
+	ours := storeCommit(t, repo, map[string]string{
+		"f.txt": "1\ntwo\n3\n4\n5\n6\n7\n8\nnine\n",
+		"g.txt": "ours\n",
+	}, base)
+	theirs := storeCommit(t, repo, map[string]string{
+		"f.txt": "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n",
+		"g.txt": "g\n",
+	}, base)
+	// the conflict on line 2 is resolved to neither side, line 9 and g.txt
+	// are taken from ours
+	merge := storeCommit(t, repo, map[string]string{
+		"f.txt": "1\nresolved\n3\n4\n5\n6\n7\n8\nnine\n",
+		"g.txt": "ours\n",
+	}, ours, theirs)
+
+	diffs, err := CombinedDiffs(repo, merge, 1)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, "f.txt", diffs[0].Path)
+	require.Len(t, diffs[0].Hunks, 1)
+
+	hunk := diffs[0].Hunks[0]
+	assert.Equal(t, []int{1, 1}, hunk.OldStarts)
+	assert.Equal(t, []int{3, 3}, hunk.OldCounts)
+	assert.Equal(t, 1, hunk.NewStart)
+	assert.Equal(t, 3, hunk.NewCount)
+	assert.Equal(t, []CombinedLine{
+		{Marks: "  ", Content: "1"},
+		{Marks: "- ", Content: "two"},
+		{Marks: " -", Content: "TWO"},
+		{Marks: "++", Content: "resolved"},
+		{Marks: "  ", Content: "3"},
+	}, hunk.Lines)
+
+	result := diffs[0].String()
+	assert.Contains(t, result, "diff --cc f.txt")
+	assert.Contains(t, result, "@@@ -1,3 -1,3 +1,3 @@@")
+	assert.Contains(t, result, "++resolved")
+
+	_, err = CombinedDiffs(repo, ours, 3)
+	assert.Error(t, err)
+}
+
+func TestFormatDiffStat(t *testing.T) {
+	out := display.FormatDiffStat([]display.DiffStat{
+		{Path: "a.txt", Added: 2, Removed: 1},
+		{Path: "longer/name.go", Added: 10},
+	})
+
+	assert.Equal(t, " a.txt          |  3 ++-\n"+
+		" longer/name.go | 10 ++++++++++\n"+
+		" 2 files changed, 12 insertions(+), 1 deletion(-)\n", out)
+}
+
+func TestComputeFileDiffBinary(t *testing.T) {
+	fd := ComputeFileDiff([]byte("text\n"), []byte("te\x00xt\n"), "a.bin", "a.bin")
+	assert.True(t, fd.Binary)
+	added, removed := fd.Stat()
+	assert.Equal(t, 0, added)
+	assert.Equal(t, 0, removed)
+	assert.True(t, Stats([]*FileDiff{fd})[0].Binary)
+}
+
+func TestTreeDiffsBigFile(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	defer func(threshold int64) { bigFileThreshold = threshold }(bigFileThreshold)
+	bigFileThreshold = 8
+
+	storeFile := func(content string) map[string]objects.TreeEntry {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		require.NoError(t, err)
+		return map[string]objects.TreeEntry{"big.txt": {Mode: objects.FileModeBlob, Name: "big.txt", Hash: blobHash}}
+	}
+
+	diffs, err := TreeDiffs(repo, storeFile("small\n"), storeFile("grown past the threshold\n"))
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.True(t, diffs[0].Binary)
+	assert.Empty(t, diffs[0].Lines)
+
+	diffs, err = TreeDiffs(repo, storeFile("one\n"), storeFile("two\n"))
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.False(t, diffs[0].Binary)
+}
+
+func TestTreeDiffsSubmodule(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	// the submodule's commits are not objects of this repository
+	gitlink := func(commitHash string) map[string]objects.TreeEntry {
+		return map[string]objects.TreeEntry{"lib": {Mode: objects.FileModeCommit, Name: "lib", Hash: commitHash}}
+	}
+	oldHash, newHash := strings.Repeat("a", 40), strings.Repeat("b", 40)
+
+	diffs, err := TreeDiffs(repo, gitlink(oldHash), gitlink(newHash))
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Contains(t, diffs[0].String(), "-Subproject commit "+oldHash)
+	assert.Contains(t, diffs[0].String(), "+Subproject commit "+newHash)
+	added, removed := diffs[0].Stat()
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 1, removed)
+}
+
+func TestTreeDiffsAttributes(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+	require.NoError(t, os.WriteFile(filepath.Join(repo.WorkDir, ".gitattributes"),
+		[]byte("*.lock -diff\n*.dat text\n"), 0644))
+
+	files := func(contents map[string]string) map[string]objects.TreeEntry {
+		entries := make(map[string]objects.TreeEntry)
+		for path, content := range contents {
+			blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+			require.NoError(t, err)
+			entries[path] = objects.TreeEntry{Mode: objects.FileModeBlob, Name: path, Hash: blobHash}
+		}
+		return entries
+	}
+
+	diffs, err := TreeDiffs(repo,
+		files(map[string]string{"deps.lock": "a\n", "raw.dat": "a\x00\n", "plain.txt": "a\n"}),
+		files(map[string]string{"deps.lock": "b\n", "raw.dat": "b\x00\n", "plain.txt": "b\n"}))
+	require.NoError(t, err)
+	require.Len(t, diffs, 3)
+
+	assert.True(t, diffs[0].Binary, "-diff forces a binary diff")
+	assert.False(t, diffs[1].Binary)
+	assert.False(t, diffs[2].Binary, "text forces a line diff")
+	added, removed := diffs[2].Stat()
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 1, removed)
+}
+
+func TestSetContextHunkHeaders(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 1; i <= 20; i++ {
+		line := fmt.Sprint(i)
+		oldLines = append(oldLines, line)
+		switch i {
+		case 5:
+			line = "five"
+		case 12:
+			line = "twelve"
+		}
+		newLines = append(newLines, line)
+		if i == 17 {
+			newLines = append(newLines, "inserted")
+		}
+	}
+	fd := ComputeFileDiff([]byte(strings.Join(oldLines, "\n")+"\n"), []byte(strings.Join(newLines, "\n")+"\n"), "f", "f")
+
+	headers := func(context int) []string {
+		fd.SetContext(context)
+		var result []string
+		for _, h := range fd.Hunks {
+			result = append(result, fmt.Sprintf("-%d,%d +%d,%d", h.OldStart, h.OldCount, h.NewStart, h.NewCount))
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"-5,1 +5,1", "-12,1 +12,1", "-17,0 +18,1"}, headers(0))
+	assert.Equal(t, []string{"-4,3 +4,3", "-11,3 +11,3", "-17,2 +17,3"}, headers(1))
+	assert.Equal(t, []string{"-1,20 +1,21"}, headers(10))
+	assert.Equal(t, []string{"-1,20 +1,21"}, headers(1000))
+
+	fd.SetContext(0)
+	for _, line := range fd.Hunks[2].Lines {
+		assert.Equal(t, LineAdded, line.Type)
+	}
+}
+
+func TestWorkingTreeDiffsDeletedAndUntracked(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	idx := index.New(repo.GitDir)
+	track := func(path, content string) {
+		full := filepath.Join(repo.WorkDir, path)
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		require.NoError(t, err)
+		info, err := os.Stat(full)
+		require.NoError(t, err)
+		require.NoError(t, idx.Add(path, blobHash, uint32(objects.FileModeBlob), info.Size(), info.ModTime()))
+	}
+	track("kept.txt", "same\n")
+	track("gone.txt", "one\ntwo\n")
+	require.NoError(t, idx.Save())
+
+	require.NoError(t, os.Remove(filepath.Join(repo.WorkDir, "gone.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.WorkDir, "new.txt"), []byte("a\nb\nc\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.WorkDir, ".gitignore"), []byte("*.log\n.gitignore\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.WorkDir, "debug.log"), []byte("ignored\n"), 0644))
+
+	diffs, err := WorkingTreeDiffs(repo, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []display.DiffStat{{Path: "gone.txt", Removed: 2}}, Stats(diffs))
+	require.Len(t, diffs, 1)
+	deleted := diffs[0].String()
+	assert.Contains(t, deleted, "-one")
+	assert.Contains(t, deleted, "deleted file mode 100644\n--- a/gone.txt\n+++ /dev/null\n")
+
+	diffs, err = WorkingTreeDiffsWithOptions(repo, nil, DiffOptions{IncludeUntracked: true})
+	require.NoError(t, err)
+	assert.Equal(t, []display.DiffStat{
+		{Path: "gone.txt", Removed: 2},
+		{Path: "new.txt", Added: 3},
+	}, Stats(diffs))
+
+	diffs, err = WorkingTreeDiffsWithOptions(repo, []string{"new.txt"}, DiffOptions{IncludeUntracked: true})
+	require.NoError(t, err)
+	assert.Equal(t, []display.DiffStat{{Path: "new.txt", Added: 3}}, Stats(diffs))
+	assert.Contains(t, diffs[0].String(), "new file mode 100644\n--- /dev/null\n+++ b/new.txt\n")
+}
@@ -0,0 +1,269 @@
+package fsck
+
+import (
+	stderrors "errors"
+	"fmt"
+	"sort"
+
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/reflog"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const headRef = "HEAD"
+
+// MissingObject is a hash referenced by an object or ref that is not in the store
+type MissingObject struct {
+	Hash         string
+	ReferencedBy string
+}
+
+type FsckReport struct {
+	ObjectsChecked int
+	// HashMismatches lists objects whose content does not hash to their name
+	HashMismatches []string
+	// Corrupt lists objects that could not be read or parsed
+	Corrupt  []string
+	Missing  []MissingObject
+	Dangling []string
+}
+
+// OK reports whether the store is consistent; dangling commits are not errors
+func (r *FsckReport) OK() bool {
+	return len(r.HashMismatches) == 0 && len(r.Corrupt) == 0 && len(r.Missing) == 0
+}
+
+// Check verifies every loose and packed object: its content must hash to its
+// name, it must parse, and everything it references must exist. Commits that
+// nothing points at (no ref, reflog entry or other commit) are reported as
+// dangling.
+func Check(repo *repository.Repository) (*FsckReport, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	types := make(map[string]objects.ObjectType)
+	err := repo.ForEachObject(func(h string, typ objects.ObjectType) error {
+		types[h] = typ
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewGitError("fsck", "", err)
+	}
+
+	report := &FsckReport{ObjectsChecked: len(types)}
+	referenced := make(map[string]bool)
+
+	hashes := make([]string, 0, len(types))
+	for h := range types {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	for _, h := range hashes {
+		if types[h] == "" {
+			report.Corrupt = append(report.Corrupt, h)
+			continue
+		}
+
+		objType, data, err := repo.ReadObjectData(h)
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, h)
+			continue
+		}
+
+		if hash.ComputeObjectHash(string(objType), data) != h {
+			report.HashMismatches = append(report.HashMismatches, h)
+			continue
+		}
+
+		refs, err := references(objType, data)
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, h)
+			continue
+		}
+
+		for _, ref := range refs {
+			referenced[ref] = true
+			if _, ok := types[ref]; !ok {
+				report.Missing = append(report.Missing, MissingObject{Hash: ref, ReferencedBy: h})
+			}
+		}
+	}
+
+	roots, err := refRoots(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	refNames := make([]string, 0, len(roots))
+	for name := range roots {
+		refNames = append(refNames, name)
+	}
+	sort.Strings(refNames)
+
+	for _, name := range refNames {
+		target := roots[name]
+		referenced[target] = true
+		if _, ok := types[target]; !ok {
+			report.Missing = append(report.Missing, MissingObject{Hash: target, ReferencedBy: name})
+		}
+	}
+
+	logs, err := reflog.All(repo)
+	if err != nil {
+		return nil, errors.NewGitError("fsck", "", err)
+	}
+	for _, entries := range logs {
+		for _, entry := range entries {
+			referenced[entry.OldHash] = true
+			referenced[entry.NewHash] = true
+		}
+	}
+
+	for _, h := range hashes {
+		if types[h] == objects.ObjectTypeCommit && !referenced[h] {
+			report.Dangling = append(report.Dangling, h)
+		}
+	}
+
+	return report, nil
+}
+
+// CheckConnectivity confirms that everything reachable from tips is in the
+// store, as after a fetch: commits, trees and tags are read and parsed, blobs
+// only looked up. It fails on the first missing or mistyped object with an
+// error naming the object that references it.
+func CheckConnectivity(repo *repository.Repository, tips []string) error {
+	type pending struct {
+		hash string
+		// want is the type the referencing object expects; empty for a tip
+		want         objects.ObjectType
+		referencedBy string
+	}
+
+	stack := make([]pending, 0, len(tips))
+	for _, tip := range tips {
+		stack = append(stack, pending{hash: tip, referencedBy: tip})
+	}
+	seen := make(map[string]bool)
+
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[next.hash] {
+			continue
+		}
+		seen[next.hash] = true
+
+		if next.want == objects.ObjectTypeBlob {
+			if !repo.HasObject(next.hash) {
+				return missingObject(next.hash, next.want, next.referencedBy)
+			}
+			continue
+		}
+
+		objType, data, err := repo.ReadObjectData(next.hash)
+		if stderrors.Is(err, errors.ErrObjectNotFound) {
+			return missingObject(next.hash, next.want, next.referencedBy)
+		}
+		if err != nil {
+			return err
+		}
+		if next.want != "" && objType != next.want {
+			return errors.NewObjectError(next.hash, string(objType),
+				fmt.Errorf("%w: expected %s, referenced by %s", errors.ErrInvalidObjectType, next.want, next.referencedBy))
+		}
+
+		if objType == objects.ObjectTypeTag {
+			target, err := objects.ParseTagTarget(data)
+			if err != nil {
+				return errors.NewObjectError(next.hash, string(objType), err)
+			}
+			stack = append(stack, pending{hash: target, referencedBy: next.hash})
+			continue
+		}
+
+		obj, err := objects.ParseObject(objType, data)
+		if err != nil {
+			return errors.NewObjectError(next.hash, string(objType), err)
+		}
+		switch o := obj.(type) {
+		case *objects.Commit:
+			stack = append(stack, pending{hash: o.Tree(), want: objects.ObjectTypeTree, referencedBy: next.hash})
+			for _, parent := range o.Parents() {
+				stack = append(stack, pending{hash: parent, want: objects.ObjectTypeCommit, referencedBy: next.hash})
+			}
+		case *objects.Tree:
+			for _, entry := range o.Entries() {
+				switch {
+				case entry.IsSubmodule():
+					// the commit lives in the submodule's repository
+				case entry.IsDir():
+					stack = append(stack, pending{hash: entry.Hash, want: objects.ObjectTypeTree, referencedBy: next.hash})
+				default:
+					stack = append(stack, pending{hash: entry.Hash, want: objects.ObjectTypeBlob, referencedBy: next.hash})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func missingObject(h string, want objects.ObjectType, referencedBy string) error {
+	if h == referencedBy {
+		return errors.NewObjectError(h, string(want), errors.ErrObjectNotFound)
+	}
+	return errors.NewObjectError(h, string(want), fmt.Errorf("%w, referenced by %s", errors.ErrObjectNotFound, referencedBy))
+}
+
+// refRoots returns every ref plus a detached HEAD
+func refRoots(repo *repository.Repository) (map[string]string, error) {
+	roots, err := repo.ListRefs()
+	if err != nil {
+		return nil, errors.NewGitError("fsck", "", err)
+	}
+
+	if head, err := repo.GetHead(); err == nil && head != "" {
+		roots[headRef] = head
+	}
+
+	return roots, nil
+}
+
+// references returns the hashes an object points at
+func references(objType objects.ObjectType, data []byte) ([]string, error) {
+	if objType == objects.ObjectTypeTag {
+		target, err := objects.ParseTagTarget(data)
+		if err != nil {
+			return nil, err
+		}
+		if !hash.ValidateHash(target) {
+			return nil, errors.ErrInvalidHash
+		}
+		return []string{target}, nil
+	}
+
+	obj, err := objects.ParseObject(objType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	switch o := obj.(type) {
+	case *objects.Tree:
+		for _, entry := range o.Entries() {
+			if entry.IsSubmodule() {
+				continue
+			}
+			refs = append(refs, entry.Hash)
+		}
+	case *objects.Commit:
+		refs = append(refs, o.Tree())
+		refs = append(refs, o.Parents()...)
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,259 @@
+package fsck
+
+import (
+	"bytes"
+	"compress/zlib"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+func setupTestRepo(t *testing.T) (*repository.Repository, string) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("hello\n")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "hello.txt", Hash: blobHash},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	commitHash := storeCommit(t, repo, treeHash, "initial")
+
+	if err := repo.UpdateRef("refs/heads/main", commitHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	return repo, commitHash
+}
+
+func storeCommit(t *testing.T, repo *repository.Repository, treeHash, message string, parents ...string) string {
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, sig, sig, message))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+	return commitHash
+}
+
+// writeLoose writes raw bytes as the loose object named objHash
+func writeLoose(t *testing.T, repo *repository.Repository, objHash string, raw []byte) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(raw)
+	zw.Close()
+
+	dir := filepath.Join(repo.GitDir, "objects", objHash[:2])
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create object dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, objHash[2:]), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write object: %v", err)
+	}
+}
+
+func TestCheck_Clean(t *testing.T) {
+	repo, _ := setupTestRepo(t)
+
+	report, err := Check(repo)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if !report.OK() {
+		t.Errorf("Expected clean report, got %+v", report)
+	}
+	if report.ObjectsChecked != 3 {
+		t.Errorf("Expected 3 objects checked, got %d", report.ObjectsChecked)
+	}
+	if len(report.Dangling) != 0 {
+		t.Errorf("Expected no dangling commits, got %v", report.Dangling)
+	}
+}
+
+func TestCheck_MissingObjects(t *testing.T) {
+	repo, commitHash := setupTestRepo(t)
+
+	missingBlob := "1111111111111111111111111111111111111111"
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "gone.txt", Hash: missingBlob},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	second := storeCommit(t, repo, treeHash, "second", commitHash)
+	if err := repo.UpdateRef("refs/heads/main", second); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	missingCommit := "2222222222222222222222222222222222222222"
+	if err := repo.UpdateRef("refs/heads/broken", missingCommit); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	report, err := Check(repo)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	expected := []MissingObject{
+		{Hash: missingBlob, ReferencedBy: treeHash},
+		{Hash: missingCommit, ReferencedBy: "refs/heads/broken"},
+	}
+	if len(report.Missing) != len(expected) {
+		t.Fatalf("Expected %d missing objects, got %+v", len(expected), report.Missing)
+	}
+	for i, want := range expected {
+		if report.Missing[i] != want {
+			t.Errorf("Missing[%d]: expected %+v, got %+v", i, want, report.Missing[i])
+		}
+	}
+	if report.OK() {
+		t.Error("Expected report with missing objects not to be OK")
+	}
+}
+
+func TestCheck_HashMismatchAndCorrupt(t *testing.T) {
+	repo, _ := setupTestRepo(t)
+
+	mismatched := "3333333333333333333333333333333333333333"
+	writeLoose(t, repo, mismatched, []byte("blob 5\x00other"))
+
+	corrupt := "4444444444444444444444444444444444444444"
+	writeLoose(t, repo, corrupt, []byte("not an object"))
+
+	report, err := Check(repo)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if len(report.HashMismatches) != 1 || report.HashMismatches[0] != mismatched {
+		t.Errorf("Expected hash mismatch for %s, got %v", mismatched, report.HashMismatches)
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0] != corrupt {
+		t.Errorf("Expected corrupt object %s, got %v", corrupt, report.Corrupt)
+	}
+}
+
+func TestCheck_Dangling(t *testing.T) {
+	repo, commitHash := setupTestRepo(t)
+
+	treeHash, err := repo.StoreObject(objects.NewTree(nil))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	dangling := storeCommit(t, repo, treeHash, "dangling", commitHash)
+
+	// a commit that only the reflog remembers is not dangling
+	reflogged := storeCommit(t, repo, treeHash, "reflogged", commitHash)
+	if err := repo.UpdateRefWithMessage("refs/heads/main", reflogged, "commit: reflogged"); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	if err := repo.UpdateRefWithMessage("refs/heads/main", commitHash, "reset: moving to HEAD~1"); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	report, err := Check(repo)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if len(report.Dangling) != 1 || report.Dangling[0] != dangling {
+		t.Errorf("Expected dangling commit %s, got %v", dangling, report.Dangling)
+	}
+	if !report.OK() {
+		t.Errorf("Dangling commits should not fail the check: %+v", report)
+	}
+}
+
+func TestCheck_Tag(t *testing.T) {
+	repo, commitHash := setupTestRepo(t)
+
+	tagFor := func(target string) string {
+		content := fmt.Sprintf("object %s\ntype commit\ntag v1\ntagger Test <test@example.com> 0 +0000\n\nrelease\n", target)
+		tagHash := hash.ComputeObjectHash("tag", []byte(content))
+		writeLoose(t, repo, tagHash, []byte(fmt.Sprintf("tag %d\x00%s", len(content), content)))
+		return tagHash
+	}
+
+	tagFor(commitHash)
+	missing := "5555555555555555555555555555555555555555"
+	badTag := tagFor(missing)
+
+	report, err := Check(repo)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if len(report.Missing) != 1 || report.Missing[0] != (MissingObject{Hash: missing, ReferencedBy: badTag}) {
+		t.Errorf("Expected only the tag target to be missing, got %+v", report.Missing)
+	}
+	if len(report.Corrupt) != 0 || len(report.HashMismatches) != 0 {
+		t.Errorf("Expected tags to verify, got %+v", report)
+	}
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	repo, commitHash := setupTestRepo(t)
+
+	if err := CheckConnectivity(repo, []string{commitHash}); err != nil {
+		t.Fatalf("Expected a complete history, got %v", err)
+	}
+
+	commit, err := repo.LoadCommit(commitHash)
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	tree, err := repo.LoadTree(commit.Tree())
+	if err != nil {
+		t.Fatalf("Failed to load tree: %v", err)
+	}
+	blobHash := tree.Entries()[0].Hash
+	if err := repo.RemoveLooseObject(blobHash); err != nil {
+		t.Fatalf("Failed to remove blob: %v", err)
+	}
+
+	err = CheckConnectivity(repo, []string{commitHash})
+	if !stderrors.Is(err, errors.ErrObjectNotFound) {
+		t.Fatalf("Expected the missing blob to be reported, got %v", err)
+	}
+	if !strings.Contains(err.Error(), blobHash) || !strings.Contains(err.Error(), commit.Tree()) {
+		t.Errorf("Expected the error to name the blob and its tree, got %v", err)
+	}
+
+	if err := CheckConnectivity(repo, []string{strings.Repeat("ab", 20)}); !stderrors.Is(err, errors.ErrObjectNotFound) {
+		t.Errorf("Expected a missing tip to be reported, got %v", err)
+	}
+}
+
+func TestCheckConnectivity_WrongType(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("not a tree\n")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	commitHash := storeCommit(t, repo, blobHash, "bad tree")
+
+	if err := CheckConnectivity(repo, []string{commitHash}); !stderrors.Is(err, errors.ErrInvalidObjectType) {
+		t.Errorf("Expected a commit pointing at a blob as its tree to fail, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package gc
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
+	"github.com/unkn0wn-root/git-go/internal/core/reflog"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	DefaultGracePeriod = 14 * 24 * time.Hour
+
+	hashSize = 20
+	nullHash = "0000000000000000000000000000000000000000"
+)
+
+var pseudoRefs = []string{"ORIG_HEAD", "MERGE_HEAD"}
+
+type GCOptions struct {
+	// Prune removes unreachable loose objects older than GracePeriod
+	Prune       bool
+	GracePeriod time.Duration
+	// PruneNow prunes regardless of age and no longer treats reflog entries
+	// as reachable, like --prune=now
+	PruneNow bool
+}
+
+type GCResult struct {
+	ObjectsPacked int
+	ObjectsPruned int
+	// PackFile is the path of the new pack, empty when nothing was packed
+	PackFile string
+}
+
+func DefaultGCOptions() GCOptions {
+	return GCOptions{
+		Prune:       true,
+		GracePeriod: DefaultGracePeriod,
+	}
+}
+
+// Run packs every reachable loose object into a new pack, removes the loose
+// copies, and optionally prunes unreachable loose objects. Objects are
+// reachable from refs, HEAD, ORIG_HEAD, MERGE_HEAD, the index and, unless
+// PruneNow is set, any reflog entry.
+func Run(repo *repository.Repository, options GCOptions) (*GCResult, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	roots, err := collectRoots(repo, !options.PruneNow)
+	if err != nil {
+		return nil, err
+	}
+
+	reachable, err := walkReachable(repo, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var loose []string
+	err = repo.ForEachObject(func(h string, _ objects.ObjectType) error {
+		if _, err := repo.LooseObjectInfo(h); err == nil {
+			loose = append(loose, h)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewGitError("gc", "", err)
+	}
+	sort.Strings(loose)
+
+	var toPack, unreachable []string
+	for _, h := range loose {
+		if reachable[h] {
+			toPack = append(toPack, h)
+		} else {
+			unreachable = append(unreachable, h)
+		}
+	}
+
+	result := &GCResult{}
+
+	if len(toPack) > 0 {
+		packData, entries, err := pack.BuildPack(repo, toPack)
+		if err != nil {
+			return nil, errors.NewGitError("gc", "", err)
+		}
+
+		idxData, err := pack.BuildIndex(entries, packData[len(packData)-hashSize:])
+		if err != nil {
+			return nil, errors.NewGitError("gc", "", err)
+		}
+
+		result.PackFile, err = repo.StorePack(packData, idxData)
+		if err != nil {
+			return nil, err
+		}
+
+		// the pack is in place, so the loose copies are redundant
+		for _, h := range toPack {
+			if err := repo.RemoveLooseObject(h); err != nil {
+				return nil, err
+			}
+		}
+		result.ObjectsPacked = len(toPack)
+	}
+
+	if !options.Prune && !options.PruneNow {
+		return result, nil
+	}
+
+	cutoff := time.Now().Add(-options.GracePeriod)
+	for _, h := range unreachable {
+		if !options.PruneNow {
+			info, err := repo.LooseObjectInfo(h)
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+		}
+
+		if err := repo.RemoveLooseObject(h); err != nil {
+			return nil, err
+		}
+		result.ObjectsPruned++
+	}
+
+	return result, nil
+}
+
+// collectRoots returns the hashes of every ref, HEAD, ORIG_HEAD and
+// MERGE_HEAD, the index and optionally every reflog entry
+func collectRoots(repo *repository.Repository, includeReflog bool) ([]string, error) {
+	refs, err := repo.ListRefs()
+	if err != nil {
+		return nil, errors.NewGitError("gc", "", err)
+	}
+
+	var roots []string
+	for _, h := range refs {
+		roots = append(roots, h)
+	}
+
+	if head, err := repo.GetHead(); err == nil && head != "" {
+		roots = append(roots, head)
+	}
+
+	// a merge or reset in progress still needs the commits it recorded
+	for _, name := range pseudoRefs {
+		content, err := os.ReadFile(filepath.Join(repo.GitDir, name))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if fields := strings.Fields(line); len(fields) > 0 && hash.ValidateHash(fields[0]) {
+				roots = append(roots, fields[0])
+			}
+		}
+	}
+
+	// staged content is not in any commit yet
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewIndexError("", err)
+	}
+	for _, entry := range idx.GetAllEntries() {
+		roots = append(roots, entry.Hash)
+	}
+	for _, stages := range idx.Unmerged() {
+		for _, entry := range stages {
+			roots = append(roots, entry.Hash)
+		}
+	}
+	roots = append(roots, idx.CachedTrees()...)
+
+	if includeReflog {
+		logs, err := reflog.All(repo)
+		if err != nil {
+			return nil, errors.NewGitError("gc", "", err)
+		}
+		for _, entries := range logs {
+			for _, entry := range entries {
+				roots = append(roots, entry.OldHash, entry.NewHash)
+			}
+		}
+	}
+
+	return roots, nil
+}
+
+// walkReachable marks every object reachable from roots. Missing objects are
+// skipped; reporting them is fsck's job.
+func walkReachable(repo *repository.Repository, roots []string) (map[string]bool, error) {
+	reachable := make(map[string]bool)
+	stack := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if root != nullHash {
+			stack = append(stack, root)
+		}
+	}
+
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if reachable[h] {
+			continue
+		}
+
+		objType, data, err := repo.ReadObjectData(h)
+		if err != nil {
+			if stderrors.Is(err, errors.ErrObjectNotFound) {
+				continue
+			}
+			return nil, errors.NewGitError("gc", h, err)
+		}
+		reachable[h] = true
+
+		switch objType {
+		case objects.ObjectTypeCommit, objects.ObjectTypeTree:
+			obj, err := objects.ParseObject(objType, data)
+			if err != nil {
+				return nil, errors.NewObjectError(h, string(objType), err)
+			}
+
+			switch o := obj.(type) {
+			case *objects.Commit:
+				stack = append(stack, o.Tree())
+				stack = append(stack, o.Parents()...)
+			case *objects.Tree:
+				for _, entry := range o.Entries() {
+					if !entry.IsSubmodule() {
+						stack = append(stack, entry.Hash)
+					}
+				}
+			}
+		case objects.ObjectTypeTag:
+			target, err := objects.ParseTagTarget(data)
+			if err != nil {
+				return nil, errors.NewObjectError(h, string(objType), err)
+			}
+			stack = append(stack, target)
+		}
+	}
+
+	return reachable, nil
+}
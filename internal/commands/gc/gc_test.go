@@ -0,0 +1,222 @@
+package gc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/add"
+	"github.com/unkn0wn-root/git-go/internal/commands/commit"
+	"github.com/unkn0wn-root/git-go/internal/commands/fsck"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+type testRepo struct {
+	repo      *repository.Repository
+	reachable []string
+	reflogged string
+	dangling  string
+}
+
+// setupTestRepo builds a branch with two commits, a commit only the reflog
+// remembers, and a blob nothing references
+func setupTestRepo(t *testing.T) *testRepo {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	store := func(obj objects.Object) string {
+		h, err := repo.StoreObject(obj)
+		if err != nil {
+			t.Fatalf("Failed to store object: %v", err)
+		}
+		return h
+	}
+
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+	blob := store(objects.NewBlob([]byte("hello\n")))
+	tree := store(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "hello.txt", Hash: blob}}))
+	first := store(objects.NewCommit(tree, nil, sig, sig, "first"))
+	second := store(objects.NewCommit(tree, []string{first}, sig, sig, "second"))
+
+	otherBlob := store(objects.NewBlob([]byte("amended\n")))
+	otherTree := store(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "hello.txt", Hash: otherBlob}}))
+	reflogged := store(objects.NewCommit(otherTree, []string{second}, sig, sig, "undone"))
+
+	for _, h := range []string{first, second, reflogged, second} {
+		if err := repo.UpdateRefWithMessage("refs/heads/main", h, "test"); err != nil {
+			t.Fatalf("Failed to update ref: %v", err)
+		}
+	}
+
+	dangling := store(objects.NewBlob([]byte("dangling\n")))
+
+	return &testRepo{
+		repo:      repo,
+		reachable: []string{blob, tree, first, second},
+		reflogged: reflogged,
+		dangling:  dangling,
+	}
+}
+
+func age(t *testing.T, repo *repository.Repository, h string, by time.Duration) {
+	old := time.Now().Add(-by)
+	path := filepath.Join(repo.GitDir, "objects", h[:2], h[2:])
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Failed to age object: %v", err)
+	}
+}
+
+func TestRun_PacksReachableObjects(t *testing.T) {
+	tr := setupTestRepo(t)
+
+	result, err := Run(tr.repo, GCOptions{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// reachable objects plus the reflogged commit, its tree and blob
+	if result.ObjectsPacked != len(tr.reachable)+3 {
+		t.Errorf("Expected %d objects packed, got %d", len(tr.reachable)+3, result.ObjectsPacked)
+	}
+	if result.ObjectsPruned != 0 {
+		t.Errorf("Expected nothing pruned without Prune, got %d", result.ObjectsPruned)
+	}
+	if _, err := os.Stat(result.PackFile); err != nil {
+		t.Errorf("Expected pack file %s: %v", result.PackFile, err)
+	}
+
+	for _, h := range append(tr.reachable, tr.reflogged) {
+		if _, err := tr.repo.LooseObjectInfo(h); !os.IsNotExist(err) {
+			t.Errorf("Expected loose copy of %s to be removed, got %v", h, err)
+		}
+		if _, err := tr.repo.LoadObject(h); err != nil {
+			t.Errorf("Failed to load packed object %s: %v", h, err)
+		}
+	}
+
+	if _, err := tr.repo.LooseObjectInfo(tr.dangling); err != nil {
+		t.Errorf("Expected unreachable object to stay loose: %v", err)
+	}
+
+	report, err := fsck.Check(tr.repo)
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected consistent store after gc, got %+v", report)
+	}
+
+	// a second run has nothing left to pack
+	result, err = Run(tr.repo, GCOptions{})
+	if err != nil {
+		t.Fatalf("Second run failed: %v", err)
+	}
+	if result.ObjectsPacked != 0 || result.PackFile != "" {
+		t.Errorf("Expected no new pack, got %+v", result)
+	}
+}
+
+func TestRun_PruneRespectsGracePeriod(t *testing.T) {
+	tr := setupTestRepo(t)
+
+	result, err := Run(tr.repo, DefaultGCOptions())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.ObjectsPruned != 0 {
+		t.Errorf("Expected recent objects to survive, got %d pruned", result.ObjectsPruned)
+	}
+
+	age(t, tr.repo, tr.dangling, 30*24*time.Hour)
+
+	result, err = Run(tr.repo, DefaultGCOptions())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.ObjectsPruned != 1 {
+		t.Errorf("Expected 1 object pruned, got %d", result.ObjectsPruned)
+	}
+	if _, err := tr.repo.LooseObjectInfo(tr.dangling); !os.IsNotExist(err) {
+		t.Errorf("Expected old unreachable object to be pruned, got %v", err)
+	}
+	if _, err := tr.repo.LoadObject(tr.reflogged); err != nil {
+		t.Errorf("Expected reflogged commit to survive pruning: %v", err)
+	}
+}
+
+func TestRun_PruneNowIgnoresReflog(t *testing.T) {
+	tr := setupTestRepo(t)
+
+	result, err := Run(tr.repo, GCOptions{PruneNow: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.ObjectsPacked != len(tr.reachable) {
+		t.Errorf("Expected %d objects packed, got %d", len(tr.reachable), result.ObjectsPacked)
+	}
+	// dangling blob plus the reflogged commit, its tree and blob
+	if result.ObjectsPruned != 4 {
+		t.Errorf("Expected 4 objects pruned, got %d", result.ObjectsPruned)
+	}
+	if _, err := tr.repo.LoadObject(tr.reflogged); err == nil {
+		t.Error("Expected reflogged commit to be pruned with PruneNow")
+	}
+}
+
+func TestRun_PruneNowKeepsStagedObjects(t *testing.T) {
+	tr := setupTestRepo(t)
+
+	if err := os.WriteFile(filepath.Join(tr.repo.WorkDir, "b.txt"), []byte("staged\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := add.AddFiles(tr.repo, []string{"b.txt"}); err != nil {
+		t.Fatalf("Failed to stage file: %v", err)
+	}
+
+	result, err := Run(tr.repo, GCOptions{PruneNow: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// only the dangling blob and the reflogged commit, tree and blob go
+	if result.ObjectsPruned != 4 {
+		t.Errorf("Expected 4 objects pruned, got %d", result.ObjectsPruned)
+	}
+
+	if _, err := commit.CreateCommit(tr.repo, commit.CommitOptions{
+		Message:     "after gc",
+		AuthorName:  "Test",
+		AuthorEmail: "test@example.com",
+		SkipHooks:   true,
+	}); err != nil {
+		t.Fatalf("Commit after gc failed: %v", err)
+	}
+
+	report, err := fsck.Check(tr.repo)
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected consistent store after gc and commit, got %+v", report)
+	}
+}
+
+func TestRun_PruneNowKeepsOrigHead(t *testing.T) {
+	tr := setupTestRepo(t)
+
+	if err := os.WriteFile(filepath.Join(tr.repo.GitDir, "ORIG_HEAD"), []byte(tr.reflogged+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ORIG_HEAD: %v", err)
+	}
+
+	if _, err := Run(tr.repo, GCOptions{PruneNow: true}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if _, err := tr.repo.LoadObject(tr.reflogged); err != nil {
+		t.Errorf("Expected the ORIG_HEAD commit to survive pruning: %v", err)
+	}
+}
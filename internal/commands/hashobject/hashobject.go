@@ -0,0 +1,47 @@
+package hashobject
+
+import (
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// Run returns the hash content has as an object of objType, a blob when
+// objType is empty. With write, the object is also stored in repo as a
+// loose object; repo may be nil otherwise. Trees, commits and tags must
+// parse as their type.
+func Run(repo *repository.Repository, content []byte, objType string, write bool) (string, error) {
+	if objType == "" {
+		objType = objects.ObjectTypeBlob.String()
+	}
+	typ, err := objects.ParseObjectType(objType)
+	if err != nil {
+		return "", errors.NewGitError("hash-object", objType, err)
+	}
+	if err := validate(typ, content); err != nil {
+		return "", errors.NewGitError("hash-object", objType, err)
+	}
+
+	if !write {
+		return hash.ComputeObjectHash(typ.String(), content), nil
+	}
+
+	if repo == nil || !repo.Exists() {
+		return "", errors.ErrNotGitRepository
+	}
+	return repo.StoreRawObject(typ, content)
+}
+
+func validate(typ objects.ObjectType, content []byte) error {
+	switch typ {
+	case objects.ObjectTypeTag:
+		_, err := objects.ParseTagTarget(content)
+		return err
+	case objects.ObjectTypeBlob:
+		return nil
+	default:
+		_, err := objects.ParseObject(typ, content)
+		return err
+	}
+}
@@ -0,0 +1,78 @@
+package hashobject
+
+import (
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+func TestRun_MatchesGit(t *testing.T) {
+	// hashes printed by git hash-object for the same bytes
+	tests := []struct {
+		name     string
+		objType  string
+		content  string
+		expected string
+	}{
+		{"empty blob", "", "", "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"},
+		{"blob", "blob", "hello\n", "ce013625030ba8dba906f756967f9e9ca394464a"},
+		{"empty tree", "tree", "", "4b825dc642cb6eb9a060e54bf8d69288fbee4904"},
+		{"commit", "commit", "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+			"author A <a@b> 0 +0000\ncommitter A <a@b> 0 +0000\n\nm\n", "09c9dfe00ddfa1db2b5ba1953e2536a5e84b21da"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Run(nil, []byte(tt.content), tt.objType, false)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRun_Write(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	h, err := Run(repo, []byte("hello\n"), "", false)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if _, err := repo.LooseObjectInfo(h); err == nil {
+		t.Error("Expected nothing stored without write")
+	}
+
+	written, err := Run(repo, []byte("hello\n"), "", true)
+	if err != nil {
+		t.Fatalf("Run with write failed: %v", err)
+	}
+	if written != h {
+		t.Errorf("Expected written hash %s, got %s", h, written)
+	}
+	typ, content, err := repo.ReadObjectData(written)
+	if err != nil {
+		t.Fatalf("Failed to read stored object: %v", err)
+	}
+	if typ != objects.ObjectTypeBlob || string(content) != "hello\n" {
+		t.Errorf("Unexpected stored object %s %q", typ, content)
+	}
+}
+
+func TestRun_Invalid(t *testing.T) {
+	if _, err := Run(nil, []byte("x"), "widget", false); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+	if _, err := Run(nil, []byte("not a commit"), "commit", false); err == nil {
+		t.Error("Expected an error for a malformed commit")
+	}
+	if _, err := Run(nil, []byte("x"), "", true); err == nil {
+		t.Error("Expected an error writing without a repository")
+	}
+}
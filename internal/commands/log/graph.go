@@ -0,0 +1,292 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// graphCommit is an entry placed in the graph: the row carrying the commit,
+// the row drawn beside the rest of its text, and the rows routing lanes on
+// to the next commit
+type graphCommit struct {
+	node    display.GraphRow
+	text    display.GraphRow
+	routing []display.GraphRow
+	width   int
+}
+
+// setGraphParents fills in GraphParents, replacing parents that were filtered
+// out with their nearest ancestors that were not. Excluded commits, outside
+// the requested range, end an edge.
+func setGraphParents(repo *repository.Repository, entries []LogEntry, excluded map[string]bool) error {
+	shown := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		shown[entry.Hash] = true
+	}
+
+	hidden := make(map[string][]string)
+	var resolve func(hash string) ([]string, error)
+	resolve = func(hash string) ([]string, error) {
+		if shown[hash] {
+			return []string{hash}, nil
+		}
+		if excluded[hash] {
+			return nil, nil
+		}
+		if ancestors, ok := hidden[hash]; ok {
+			return ancestors, nil
+		}
+		// mark before recursing so a malformed cycle terminates
+		hidden[hash] = nil
+
+		obj, err := repo.LoadObject(hash)
+		if err != nil {
+			return nil, errors.NewGitError("log", "", fmt.Errorf("load commit %s: %w", hash, err))
+		}
+		commit, ok := obj.(*objects.Commit)
+		if !ok {
+			return nil, errors.NewGitError("log", "", fmt.Errorf("object %s is not a commit", hash))
+		}
+
+		ancestors, err := resolveAll(commit.Parents(), resolve)
+		if err != nil {
+			return nil, err
+		}
+		hidden[hash] = ancestors
+		return ancestors, nil
+	}
+
+	for i := range entries {
+		parents, err := resolveAll(entries[i].Parents, resolve)
+		if err != nil {
+			return err
+		}
+		entries[i].GraphParents = parents
+	}
+	return nil
+}
+
+func resolveAll(hashes []string, resolve func(string) ([]string, error)) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+	for _, hash := range hashes {
+		ancestors, err := resolve(hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, ancestor := range ancestors {
+			if !seen[ancestor] {
+				seen[ancestor] = true
+				result = append(result, ancestor)
+			}
+		}
+	}
+	return result, nil
+}
+
+// topoOrder sorts entries so every commit comes before its parents, keeping
+// each line of history together: the reverse of a depth-first postorder that
+// visits parents in order, so a merged branch is listed before the first
+// parent's history continues
+func topoOrder(entries []LogEntry) []LogEntry {
+	byHash := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		byHash[entry.Hash] = i
+	}
+
+	type frame struct {
+		index int
+		next  int
+	}
+
+	visited := make([]bool, len(entries))
+	postorder := make([]int, 0, len(entries))
+	for start := range entries {
+		if visited[start] {
+			continue
+		}
+		visited[start] = true
+		stack := []frame{{index: start}}
+
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			parents := entries[top.index].GraphParents
+			if top.next < len(parents) {
+				parent, ok := byHash[parents[top.next]]
+				top.next++
+				if ok && !visited[parent] {
+					visited[parent] = true
+					stack = append(stack, frame{index: parent})
+				}
+				continue
+			}
+			postorder = append(postorder, top.index)
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	sorted := make([]LogEntry, len(entries))
+	for i, index := range postorder {
+		sorted[len(entries)-1-i] = entries[index]
+	}
+	return sorted
+}
+
+// layoutGraph assigns each entry, in topological order, a column and the
+// lane movements that follow it. Lanes hold the commit expected next in
+// them; a merge opens a lane per extra parent beside its own, and lanes
+// waiting for the same commit are folded into the leftmost one.
+func layoutGraph(entries []LogEntry) []graphCommit {
+	var lanes []string
+	layout := make([]graphCommit, 0, len(entries))
+
+	for _, entry := range entries {
+		col := laneIndex(lanes, entry.Hash)
+		if col < 0 {
+			lanes = append(lanes, entry.Hash)
+			col = len(lanes) - 1
+		}
+
+		parents := entry.GraphParents
+		gc := graphCommit{
+			node:  display.GraphRow{Node: col, Edges: straightEdges(len(lanes), -1)},
+			text:  display.GraphRow{Node: -1, Edges: straightEdges(len(lanes), -1)},
+			width: len(lanes),
+		}
+
+		if len(parents) == 0 {
+			// the lane ends here
+			gc.text.Edges = straightEdges(len(lanes), col)
+			if col < len(lanes)-1 {
+				gc.routing = append(gc.routing, removeLaneRow(len(lanes), col, col))
+			}
+			lanes = append(lanes[:col], lanes[col+1:]...)
+		} else {
+			lanes[col] = parents[0]
+			for i, parent := range parents[1:] {
+				at := col + 1 + i
+				gc.routing = append(gc.routing, insertLaneRow(len(lanes), at))
+				lanes = append(lanes[:at], append([]string{parent}, lanes[at:]...)...)
+			}
+		}
+
+		for {
+			dup, twin := duplicateLane(lanes)
+			if dup < 0 {
+				break
+			}
+			gc.routing = append(gc.routing, removeLaneRow(len(lanes), dup, twin))
+			lanes = append(lanes[:dup], lanes[dup+1:]...)
+		}
+
+		layout = append(layout, gc)
+	}
+
+	return layout
+}
+
+func laneIndex(lanes []string, hash string) int {
+	for i, lane := range lanes {
+		if lane == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// duplicateLane returns the leftmost lane waiting for the same commit as an
+// earlier one, and that earlier lane, or -1, -1
+func duplicateLane(lanes []string) (int, int) {
+	first := make(map[string]int, len(lanes))
+	for i, lane := range lanes {
+		if twin, ok := first[lane]; ok {
+			return i, twin
+		}
+		first[lane] = i
+	}
+	return -1, -1
+}
+
+// straightEdges runs every lane straight down, except skip
+func straightEdges(lanes, skip int) []display.GraphEdge {
+	edges := make([]display.GraphEdge, 0, lanes)
+	for i := 0; i < lanes; i++ {
+		if i != skip {
+			edges = append(edges, display.GraphEdge{From: i, To: i})
+		}
+	}
+	return edges
+}
+
+// insertLaneRow opens a lane at column at, branching off the lane to its
+// left and pushing the lanes after it one column right
+func insertLaneRow(lanes, at int) display.GraphRow {
+	row := display.GraphRow{Node: -1}
+	for i := 0; i < lanes; i++ {
+		if i < at {
+			row.Edges = append(row.Edges, display.GraphEdge{From: i, To: i})
+		} else {
+			row.Edges = append(row.Edges, display.GraphEdge{From: i, To: i + 1})
+		}
+	}
+	row.Edges = append(row.Edges, display.GraphEdge{From: at - 1, To: at})
+	return row
+}
+
+// removeLaneRow folds lane col into lane into, or just ends it when they are
+// the same, pulling the lanes after it one column left
+func removeLaneRow(lanes, col, into int) display.GraphRow {
+	row := display.GraphRow{Node: -1}
+	for i := 0; i < lanes; i++ {
+		switch {
+		case i < col:
+			row.Edges = append(row.Edges, display.GraphEdge{From: i, To: i})
+		case i > col:
+			row.Edges = append(row.Edges, display.GraphEdge{From: i, To: i - 1})
+		case into != col:
+			row.Edges = append(row.Edges, display.GraphEdge{From: i, To: into})
+		}
+	}
+	return row
+}
+
+// formatGraph prefixes each entry's text with its graph rows
+func formatGraph(entries []LogEntry, options LogOptions) string {
+	layout := layoutGraph(entries)
+
+	var buf strings.Builder
+	for i, entry := range entries {
+		gc := layout[i]
+
+		lines := strings.Split(strings.TrimSuffix(entry.String(options), "\n"), "\n")
+		for j, line := range lines {
+			row := gc.text
+			if j == 0 {
+				row = gc.node
+			}
+			buf.WriteString(strings.TrimRight(display.FormatGraphRow(row, gc.width)+line, " "))
+			buf.WriteString("\n")
+		}
+
+		width := gc.width
+		for _, row := range gc.routing {
+			buf.WriteString(strings.TrimRight(display.FormatGraphRow(row, width), " "))
+			buf.WriteString("\n")
+			width = max(width, len(row.Edges))
+		}
+
+		if !options.Oneline && i < len(entries)-1 {
+			next := layout[i+1]
+			spacer := display.GraphRow{Node: -1, Edges: straightEdges(next.width, -1)}
+			buf.WriteString(strings.TrimRight(display.FormatGraphRow(spacer, next.width), " "))
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,78 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+func TestGetLogGraphTopoOrder(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	base := storeFilesCommit(t, repo, "base", map[string]string{"a.txt": "1"})
+	main1 := storeFilesCommit(t, repo, "main1", map[string]string{"a.txt": "2"}, base)
+	side1 := storeFilesCommit(t, repo, "side1", map[string]string{"a.txt": "1", "b.txt": "1"}, base)
+	side2 := storeFilesCommit(t, repo, "side2", map[string]string{"a.txt": "1", "b.txt": "2"}, side1)
+	merge := storeFilesCommit(t, repo, "merge", map[string]string{"a.txt": "2", "b.txt": "2"}, main1, side2)
+
+	entries, err := GetLog(repo, LogOptions{Revision: merge, Graph: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{merge, side2, side1, main1, base}, logHashes(entries))
+
+	short := func(h string) string { return hash.ShortHash(h, 7) }
+	want := strings.Join([]string{
+		fmt.Sprintf("* %s merge", short(merge)),
+		"|\\",
+		fmt.Sprintf("| * %s side2", short(side2)),
+		fmt.Sprintf("| * %s side1", short(side1)),
+		fmt.Sprintf("* | %s main1", short(main1)),
+		"|/",
+		fmt.Sprintf("* %s base", short(base)),
+	}, "\n") + "\n"
+	assert.Equal(t, want, formatGraph(entries, LogOptions{Oneline: true}))
+
+	// MaxCount applies to the topological order
+	entries, err = GetLog(repo, LogOptions{Revision: merge, Graph: true, MaxCount: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{merge, side2, side1}, logHashes(entries))
+}
+
+func TestGetLogGraphSkipsFilteredCommits(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	base := storeFilesCommit(t, repo, "base", map[string]string{"a.txt": "1"})
+	other := storeFilesCommit(t, repo, "other", map[string]string{"a.txt": "1", "b.txt": "1"}, base)
+	top := storeFilesCommit(t, repo, "top", map[string]string{"a.txt": "2", "b.txt": "1"}, other)
+
+	entries, err := GetLog(repo, LogOptions{Revision: top, Graph: true, Paths: []string{"a.txt"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{top, base}, logHashes(entries))
+	assert.Equal(t, []string{base}, entries[0].GraphParents)
+}
+
+func TestLayoutGraphOctopusAndRoots(t *testing.T) {
+	entries := []LogEntry{
+		{Hash: "m", GraphParents: []string{"a", "b", "c"}},
+		{Hash: "c"},
+		{Hash: "b"},
+		{Hash: "a"},
+	}
+
+	layout := layoutGraph(entries)
+	require.Len(t, layout, 4)
+
+	nodes := make([]int, len(layout))
+	for i, gc := range layout {
+		nodes[i] = gc.node.Node
+	}
+	assert.Equal(t, []int{0, 2, 1, 0}, nodes)
+	assert.Len(t, layout[0].routing, 2, "one row per extra parent")
+	assert.Empty(t, layout[len(layout)-1].routing)
+}
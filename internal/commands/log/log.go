@@ -2,19 +2,53 @@ package log
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/commands/diff"
 	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/mailmap"
+	"github.com/unkn0wn-root/git-go/internal/core/mergebase"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/rename"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
 	"github.com/unkn0wn-root/git-go/pkg/display"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
+const coAuthoredByTrailer = "Co-authored-by"
+
 type LogOptions struct {
 	MaxCount int
 	Oneline  bool
 	Graph    bool
+	// Revision is the starting point of the walk; empty means HEAD
+	Revision string
+	// Range is "A..B", the commits reachable from B but not A, or "A...B",
+	// those reachable from either but not both. An omitted side means HEAD.
+	Range string
+	// ShowCoAuthors lists Co-authored-by trailers below the author
+	ShowCoAuthors bool
+	// Paths limits the log to commits that changed a file at or below one of
+	// these paths
+	Paths []string
+	// Follow continues the history of a single path past renames
+	Follow bool
+	// Since and Until bound the committer date, inclusively
+	Since *time.Time
+	Until *time.Time
+	// Author keeps commits whose "Name <email>" contains it
+	Author string
+	// Stat shows each commit's per-file changes against its first parent
+	Stat bool
+	// FirstParent follows only the first parent of merge commits
+	FirstParent bool
+	// Mailmap rewrites the author and committer shown for each commit
+	Mailmap *mailmap.Mailmap
 }
 
 type LogEntry struct {
@@ -23,27 +57,45 @@ type LogEntry struct {
 	Committer *objects.Signature
 	Message   string
 	Parents   []string
+	CoAuthors []string
+	// GraphParents are the parents --graph draws edges to: Parents with
+	// filtered-out commits replaced by their nearest shown ancestors
+	GraphParents []string
+	// Stats is filled in when LogOptions.Stat is set
+	Stats []display.DiffStat
 }
 
 func (le *LogEntry) String(options LogOptions) string {
 	if options.Oneline {
 		shortHash := hash.ShortHash(le.Hash, 7)
 		messageLine := strings.Split(le.Message, "\n")[0]
-		return fmt.Sprintf("%s %s", display.Hash(shortHash), messageLine)
+		line := fmt.Sprintf("%s %s", display.Hash(shortHash), messageLine)
+		if options.Stat && len(le.Stats) > 0 {
+			line += "\n" + strings.TrimSuffix(display.FormatDiffStat(le.Stats), "\n")
+		}
+		return line
 	}
 
 	var buf strings.Builder
 	buf.WriteString(fmt.Sprintf("%s %s\n", display.Emphasis("commit"), le.Hash))
 
-	if le.Author.Name != le.Committer.Name || le.Author.Email != le.Committer.Email ||
-		le.Author.When.Unix() != le.Committer.When.Unix() {
-		buf.WriteString(fmt.Sprintf("%s     %s\n", display.Info("Author:"), le.Author.String()))
-		buf.WriteString(fmt.Sprintf("%s %s\n", display.Info("AuthorDate:"), display.Secondary(le.Author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))))
-		buf.WriteString(fmt.Sprintf("%s     %s\n", display.Info("Commit:"), le.Committer.String()))
-		buf.WriteString(fmt.Sprintf("%s %s\n", display.Info("CommitDate:"), display.Secondary(le.Committer.When.Format("Mon Jan 2 15:04:05 2006 -0700"))))
+	author := options.Mailmap.ResolveSignature(le.Author)
+	committer := options.Mailmap.ResolveSignature(le.Committer)
+	if author.Name != committer.Name || author.Email != committer.Email ||
+		author.When.Unix() != committer.When.Unix() {
+		buf.WriteString(fmt.Sprintf("%s     %s\n", display.Info("Author:"), author.String()))
+		buf.WriteString(fmt.Sprintf("%s %s\n", display.Info("AuthorDate:"), display.Secondary(author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))))
+		buf.WriteString(fmt.Sprintf("%s     %s\n", display.Info("Commit:"), committer.String()))
+		buf.WriteString(fmt.Sprintf("%s %s\n", display.Info("CommitDate:"), display.Secondary(committer.When.Format("Mon Jan 2 15:04:05 2006 -0700"))))
 	} else {
-		buf.WriteString(fmt.Sprintf("%s %s\n", display.Info("Author:"), le.Author.String()))
-		buf.WriteString(fmt.Sprintf("%s   %s\n", display.Info("Date:"), display.Secondary(le.Author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))))
+		buf.WriteString(fmt.Sprintf("%s %s\n", display.Info("Author:"), author.String()))
+		buf.WriteString(fmt.Sprintf("%s   %s\n", display.Info("Date:"), display.Secondary(author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))))
+	}
+
+	if options.ShowCoAuthors {
+		for _, coAuthor := range le.CoAuthors {
+			buf.WriteString(fmt.Sprintf("%s %s\n", display.Info("Co-author:"), coAuthor))
+		}
 	}
 
 	buf.WriteString("\n")
@@ -58,6 +110,13 @@ func (le *LogEntry) String(options LogOptions) string {
 		}
 	}
 
+	if options.Stat && len(le.Stats) > 0 {
+		if !strings.HasSuffix(le.Message, "\n") {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(display.FormatDiffStat(le.Stats))
+	}
+
 	return buf.String()
 }
 
@@ -66,23 +125,132 @@ func GetLog(repo *repository.Repository, options LogOptions) ([]LogEntry, error)
 		return nil, errors.ErrNotGitRepository
 	}
 
-	headHash, err := repo.GetHead()
-	if err != nil || headHash == "" {
-		return []LogEntry{}, nil // No commits yet
+	if options.Follow && len(options.Paths) != 1 {
+		return nil, errors.NewGitError("log", "", fmt.Errorf("--follow requires exactly one path"))
 	}
 
-	var entries []LogEntry
-	visited := make(map[string]bool)
+	var starts []string
+	excluded := make(map[string]bool)
+	switch {
+	case options.Range != "":
+		if options.Revision != "" {
+			return nil, errors.NewGitError("log", options.Range, fmt.Errorf("cannot combine a range with a revision"))
+		}
+		var err error
+		starts, excluded, err = ResolveRange(repo, options.Range)
+		if err != nil {
+			return nil, errors.NewGitError("log", options.Range, err)
+		}
+	case options.Revision != "":
+		hash, err := revparse.ResolveCommit(repo, options.Revision)
+		if err != nil {
+			return nil, errors.NewGitError("log", options.Revision, err)
+		}
+		starts = []string{hash}
+	default:
+		hash, err := repo.GetHead()
+		if err != nil || hash == "" {
+			return []LogEntry{}, nil // No commits yet
+		}
+		starts = []string{hash}
+	}
+
+	walkOptions := options
+	if options.Graph {
+		// the graph is ordered after the walk, so count only once it is
+		walkOptions.MaxCount = 0
+	}
 
-	err = walkCommits(repo, headHash, &entries, visited, options)
+	entries, err := Walk(repo, starts, excluded, walkOptions)
 	if err != nil {
 		return nil, err
 	}
 
+	if options.Graph {
+		if err := setGraphParents(repo, entries, excluded); err != nil {
+			return nil, err
+		}
+		entries = topoOrder(entries)
+		if options.MaxCount > 0 && len(entries) > options.MaxCount {
+			entries = entries[:options.MaxCount]
+		}
+	}
+
+	return entries, nil
+}
+
+// Walk lists the commits reachable from starts that are not in excluded,
+// applying the filters and MaxCount of options. The range and revision
+// options are ignored.
+func Walk(repo *repository.Repository, starts []string, excluded map[string]bool, options LogOptions) ([]LogEntry, error) {
+	var entries []LogEntry
+	// excluded commits are never walked, so they are neither listed nor
+	// counted against MaxCount
+	visited := make(map[string]bool, len(excluded))
+	for hash := range excluded {
+		visited[hash] = true
+	}
+
+	for _, start := range starts {
+		if err := walkCommits(repo, start, &entries, visited, options, options.Paths); err != nil {
+			return nil, err
+		}
+	}
 	return entries, nil
 }
 
-func walkCommits(repo *repository.Repository, commitHash string, entries *[]LogEntry, visited map[string]bool, options LogOptions) error {
+// ResolveRange resolves an A..B or A...B range to the commits to walk from
+// and the commits to leave out
+func ResolveRange(repo *repository.Repository, spec string) ([]string, map[string]bool, error) {
+	left, right, symmetric := spec, "", false
+	if l, r, ok := strings.Cut(spec, "..."); ok {
+		left, right, symmetric = l, r, true
+	} else if l, r, ok := strings.Cut(spec, ".."); ok {
+		left, right = l, r
+	} else {
+		return nil, nil, fmt.Errorf("invalid range %q: expected A..B or A...B", spec)
+	}
+
+	resolve := func(side string) (string, error) {
+		if side == "" {
+			side = "HEAD"
+		}
+		return revparse.ResolveCommit(repo, side)
+	}
+	from, err := resolve(left)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := resolve(right)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fromAncestors, err := mergebase.Ancestors(repo, from)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !symmetric {
+		return []string{to}, fromAncestors, nil
+	}
+
+	toAncestors, err := mergebase.Ancestors(repo, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	common := make(map[string]bool)
+	for hash := range toAncestors {
+		if fromAncestors[hash] {
+			common[hash] = true
+		}
+	}
+	return []string{from, to}, common, nil
+}
+
+// walkCommits appends commitHash and its ancestors to entries. paths are the
+// names options.Paths have at commitHash, which differ from the originals
+// only when following a rename.
+func walkCommits(repo *repository.Repository, commitHash string, entries *[]LogEntry, visited map[string]bool, options LogOptions, paths []string) error {
 	if visited[commitHash] {
 		return nil
 	}
@@ -103,22 +271,52 @@ func walkCommits(repo *repository.Repository, commitHash string, entries *[]LogE
 		return errors.NewGitError("log", "", fmt.Errorf("object %s is not a commit", commitHash))
 	}
 
-	entry := LogEntry{
-		Hash:      commitHash,
-		Author:    commit.Author(),
-		Committer: commit.Committer(),
-		Message:   commit.Message(),
-		Parents:   commit.Parents(),
+	parents := commit.Parents()
+	if options.FirstParent && len(parents) > 1 {
+		parents = parents[:1]
+	}
+	parentPaths := make([][]string, len(parents))
+	for i := range parentPaths {
+		parentPaths[i] = paths
 	}
 
-	*entries = append(*entries, entry)
+	show := matchesFilters(commit, options)
+	if len(paths) > 0 {
+		touched, nextPaths, err := touchedPaths(repo, commitHash, parents, paths, options.Follow)
+		if err != nil {
+			return errors.NewGitError("log", "", err)
+		}
+		show = show && touched
+		parentPaths = nextPaths
+	}
+
+	if show {
+		entry := LogEntry{
+			Hash:      commitHash,
+			Author:    commit.Author(),
+			Committer: commit.Committer(),
+			Message:   commit.Message(),
+			Parents:   parents,
+			CoAuthors: coAuthors(commit),
+		}
+
+		if options.Stat {
+			diffs, err := diff.CommitDiffs(repo, commitHash)
+			if err != nil {
+				return err
+			}
+			entry.Stats = diff.Stats(diffs)
+		}
+
+		*entries = append(*entries, entry)
+	}
 
 	// Continue with parents
-	for _, parentHash := range commit.Parents() {
+	for i, parentHash := range parents {
 		if options.MaxCount > 0 && len(*entries) >= options.MaxCount {
 			break
 		}
-		err := walkCommits(repo, parentHash, entries, visited, options)
+		err := walkCommits(repo, parentHash, entries, visited, options, parentPaths[i])
 		if err != nil {
 			return err
 		}
@@ -127,6 +325,163 @@ func walkCommits(repo *repository.Repository, commitHash string, entries *[]LogE
 	return nil
 }
 
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var relativeUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// ParseDate parses a --since/--until value: an absolute date such as
+// 2024-01-31 or 2024-01-31 12:00:00 in local time, or a relative one such as
+// "2 weeks ago" or "3.days.ago" counted back from now
+func ParseDate(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	fields := strings.Fields(strings.ReplaceAll(value, ".", " "))
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		unit := strings.TrimSuffix(fields[1], "s")
+		if err == nil && n >= 0 {
+			switch unit {
+			case "month":
+				return now.AddDate(0, -n, 0), nil
+			case "year":
+				return now.AddDate(-n, 0, 0), nil
+			}
+			if d, ok := relativeUnits[unit]; ok {
+				return now.Add(-time.Duration(n) * d), nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// matchesFilters applies the date and author filters of options
+func matchesFilters(commit *objects.Commit, options LogOptions) bool {
+	if committer := commit.Committer(); committer != nil {
+		if options.Since != nil && committer.When.Before(*options.Since) {
+			return false
+		}
+		if options.Until != nil && committer.When.After(*options.Until) {
+			return false
+		}
+	}
+
+	if options.Author != "" {
+		author := commit.Author()
+		if author == nil || !strings.Contains(fmt.Sprintf("%s <%s>", author.Name, author.Email), options.Author) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// touchedPaths reports whether a commit changed any of paths compared to
+// every one of its parents, so merges that took a path unchanged from one
+// side are skipped. It also returns the paths to look for in each parent;
+// with follow, a path the commit renamed is tracked under its old name.
+func touchedPaths(repo *repository.Repository, commitHash string, parents, paths []string, follow bool) (bool, [][]string, error) {
+	files, err := checkout.CommitFiles(repo, commitHash)
+	if err != nil {
+		return false, nil, err
+	}
+
+	parentPaths := make([][]string, len(parents))
+	if len(parents) == 0 {
+		return pathsChanged(files, nil, paths), parentPaths, nil
+	}
+
+	changed := true
+	for i, parentHash := range parents {
+		parentFiles, err := checkout.CommitFiles(repo, parentHash)
+		if err != nil {
+			return false, nil, err
+		}
+
+		parentPaths[i] = paths
+		if follow {
+			if source, ok := rename.Source(parentFiles, files, paths[0]); ok {
+				parentPaths[i] = []string{source}
+			}
+		}
+
+		if !pathsChanged(files, parentFiles, paths) {
+			changed = false
+		}
+	}
+
+	return changed, parentPaths, nil
+}
+
+// pathsChanged reports whether any file at or below paths differs between
+// the two trees
+func pathsChanged(files, parentFiles map[string]objects.TreeEntry, paths []string) bool {
+	for path, entry := range files {
+		if !matchesPaths(path, paths) {
+			continue
+		}
+		if parent, ok := parentFiles[path]; !ok || parent.Hash != entry.Hash || parent.Mode != entry.Mode {
+			return true
+		}
+	}
+
+	for path := range parentFiles {
+		if _, ok := files[path]; !ok && matchesPaths(path, paths) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesPaths(file string, paths []string) bool {
+	for _, path := range paths {
+		path = strings.TrimSuffix(path, "/")
+		if file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// coAuthors returns the Co-authored-by trailers of a commit, matching the key
+// case-insensitively as Git does
+func coAuthors(commit *objects.Commit) []string {
+	trailers := commit.Trailers()
+
+	keys := make([]string, 0, len(trailers))
+	for key := range trailers {
+		if strings.EqualFold(key, coAuthoredByTrailer) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var result []string
+	for _, key := range keys {
+		result = append(result, trailers[key]...)
+	}
+	return result
+}
+
 func ShowLog(repo *repository.Repository, options LogOptions) error {
 	entries, err := GetLog(repo, options)
 	if err != nil {
@@ -138,8 +493,16 @@ func ShowLog(repo *repository.Repository, options LogOptions) error {
 		return nil
 	}
 
+	if options.Graph {
+		fmt.Print(formatGraph(entries, options))
+		return nil
+	}
+
 	for i, entry := range entries {
 		fmt.Print(entry.String(options))
+		if options.Oneline {
+			fmt.Println()
+		}
 
 		// add separator between commits (except for last one and oneline format)
 		if !options.Oneline && i < len(entries)-1 {
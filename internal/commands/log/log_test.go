@@ -3,14 +3,17 @@ package log
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/mailmap"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/display"
 )
 
 func TestShowLog(t *testing.T) {
@@ -276,6 +279,33 @@ func TestLogEntryStringSameAuthorCommitter(t *testing.T) {
 	assert.NotContains(t, full, "Commit:")
 }
 
+func TestLogEntryStringCoAuthors(t *testing.T) {
+	author := &objects.Signature{
+		Name:  "John Doe",
+		Email: "john@example.com",
+		When:  time.Unix(1234567890, 0),
+	}
+
+	commit := objects.NewCommit("tree", nil, author, author,
+		"Pair on feature\n\nCo-authored-by: Ann Roe <ann@example.com>\nco-authored-by: Bob Poe <bob@example.com>")
+
+	entry := LogEntry{
+		Hash:      "abcdef1234567890abcdef1234567890abcdef12",
+		Author:    author,
+		Committer: author,
+		Message:   commit.Message(),
+		CoAuthors: coAuthors(commit),
+	}
+
+	assert.Equal(t, []string{"Ann Roe <ann@example.com>", "Bob Poe <bob@example.com>"}, entry.CoAuthors)
+
+	assert.NotContains(t, entry.String(LogOptions{}), "Co-author:")
+
+	full := entry.String(LogOptions{ShowCoAuthors: true})
+	assert.Contains(t, full, "Co-author: Ann Roe <ann@example.com>")
+	assert.Contains(t, full, "Co-author: Bob Poe <bob@example.com>")
+}
+
 func createTestCommit(t *testing.T, repo *repository.Repository, message, filename, content string) string {
 	idx := index.New(repo.GitDir)
 	err := idx.Load()
@@ -392,7 +422,7 @@ func TestWalkCommits(t *testing.T) {
 	visited := make(map[string]bool)
 	opts := LogOptions{MaxCount: 0, Oneline: false}
 
-	err = walkCommits(repo, hash2, &entries, visited, opts)
+	err = walkCommits(repo, hash2, &entries, visited, opts, nil)
 	require.NoError(t, err)
 
 	assert.Len(t, entries, 2)
@@ -429,3 +459,225 @@ func BenchmarkGetLog(b *testing.B) {
 		}
 	}
 }
+
+func storeFilesCommit(t *testing.T, repo *repository.Repository, message string, files map[string]string, parents ...string) string {
+	author := &objects.Signature{Name: "Test Author", Email: "test@example.com", When: time.Now()}
+	return storeCommitAs(t, repo, author, message, files, parents...)
+}
+
+func storeCommitAs(t *testing.T, repo *repository.Repository, author *objects.Signature, message string, files map[string]string, parents ...string) string {
+	var treeEntries []objects.TreeEntry
+	for path, content := range files {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		require.NoError(t, err)
+		treeEntries = append(treeEntries, objects.TreeEntry{Mode: objects.FileModeBlob, Name: path, Hash: blobHash})
+	}
+
+	treeHash, err := repo.StoreObject(objects.NewTree(treeEntries))
+	require.NoError(t, err)
+
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, author, author, message))
+	require.NoError(t, err)
+
+	return commitHash
+}
+
+func TestGetLogFollow(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	created := storeFilesCommit(t, repo, "Create", map[string]string{"old.txt": "content", "other.txt": "a"})
+	renamed := storeFilesCommit(t, repo, "Rename", map[string]string{"new.txt": "content", "other.txt": "a"}, created)
+	unrelated := storeFilesCommit(t, repo, "Unrelated", map[string]string{"new.txt": "content", "other.txt": "b"}, renamed)
+	edited := storeFilesCommit(t, repo, "Edit", map[string]string{"new.txt": "changed", "other.txt": "b"}, unrelated)
+
+	entries, err := GetLog(repo, LogOptions{Revision: edited, Paths: []string{"new.txt"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{edited, renamed}, logHashes(entries))
+
+	entries, err = GetLog(repo, LogOptions{Revision: edited, Paths: []string{"new.txt"}, Follow: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{edited, renamed, created}, logHashes(entries))
+
+	_, err = GetLog(repo, LogOptions{Revision: edited, Follow: true})
+	assert.Error(t, err)
+}
+
+func logHashes(entries []LogEntry) []string {
+	var result []string
+	for _, entry := range entries {
+		result = append(result, entry.Hash)
+	}
+	return result
+}
+
+func TestGetLogDateFilter(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	day := func(d int) *objects.Signature {
+		return &objects.Signature{Name: "Test Author", Email: "test@example.com", When: time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC)}
+	}
+	first := storeCommitAs(t, repo, day(1), "First", map[string]string{"a.txt": "1"})
+	second := storeCommitAs(t, repo, day(10), "Second", map[string]string{"a.txt": "2"}, first)
+	third := storeCommitAs(t, repo, day(20), "Third", map[string]string{"a.txt": "3"}, second)
+
+	since := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	entries, err := GetLog(repo, LogOptions{Revision: third, Since: &since})
+	require.NoError(t, err)
+	assert.Equal(t, []string{third, second}, logHashes(entries))
+
+	entries, err = GetLog(repo, LogOptions{Revision: third, Until: &until})
+	require.NoError(t, err)
+	assert.Equal(t, []string{second, first}, logHashes(entries))
+
+	entries, err = GetLog(repo, LogOptions{Revision: third, Since: &since, Until: &until})
+	require.NoError(t, err)
+	assert.Equal(t, []string{second}, logHashes(entries))
+}
+
+func TestGetLogAuthorFilter(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	alice := &objects.Signature{Name: "Alice Smith", Email: "alice@example.com", When: time.Now()}
+	bob := &objects.Signature{Name: "Bob Jones", Email: "bob@corp.test", When: time.Now()}
+
+	first := storeCommitAs(t, repo, alice, "First", map[string]string{"a.txt": "1"})
+	second := storeCommitAs(t, repo, bob, "Second", map[string]string{"a.txt": "2"}, first)
+	third := storeCommitAs(t, repo, alice, "Third", map[string]string{"a.txt": "3"}, second)
+
+	entries, err := GetLog(repo, LogOptions{Revision: third, Author: "Alice"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{third, first}, logHashes(entries))
+
+	entries, err = GetLog(repo, LogOptions{Revision: third, Author: "@corp.test"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{second}, logHashes(entries))
+
+	entries, err = GetLog(repo, LogOptions{Revision: third, Author: "Carol"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestGetLogPathFilter(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	first := storeFilesCommit(t, repo, "First", map[string]string{"src/main.go": "1", "README": "r"})
+	second := storeFilesCommit(t, repo, "Second", map[string]string{"src/main.go": "1", "README": "r2"}, first)
+	third := storeFilesCommit(t, repo, "Third", map[string]string{"src/main.go": "2", "README": "r2"}, second)
+	fourth := storeFilesCommit(t, repo, "Fourth", map[string]string{"README": "r2"}, third)
+
+	entries, err := GetLog(repo, LogOptions{Revision: fourth, Paths: []string{"src"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{fourth, third, first}, logHashes(entries))
+
+	entries, err = GetLog(repo, LogOptions{Revision: fourth, Paths: []string{"README"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{second, first}, logHashes(entries))
+
+	entries, err = GetLog(repo, LogOptions{Revision: fourth, Paths: []string{"missing.txt"}})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"2024-01-31":           time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		"2024-01-31 08:30:00":  time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC),
+		"2024-01-31T08:30:00Z": time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC),
+		"2 weeks ago":          now.AddDate(0, 0, -14),
+		"3.days.ago":           now.AddDate(0, 0, -3),
+		"1 month ago":          now.AddDate(0, -1, 0),
+	}
+	for value, want := range tests {
+		got, err := ParseDate(value, now)
+		require.NoError(t, err, value)
+		assert.True(t, want.Equal(got), "%s: got %v, want %v", value, got, want)
+	}
+
+	_, err := ParseDate("next tuesday", now)
+	assert.Error(t, err)
+}
+
+func TestGetLogRange(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	// base - m1 - m2        (main)
+	//    \
+	//     f1 - f2 - f3      (feature)
+	base := storeFilesCommit(t, repo, "base", map[string]string{"a.txt": "0"})
+	m1 := storeFilesCommit(t, repo, "m1", map[string]string{"a.txt": "m1"}, base)
+	m2 := storeFilesCommit(t, repo, "m2", map[string]string{"a.txt": "m2"}, m1)
+	f1 := storeFilesCommit(t, repo, "f1", map[string]string{"a.txt": "f1"}, base)
+	f2 := storeFilesCommit(t, repo, "f2", map[string]string{"a.txt": "f2"}, f1)
+	f3 := storeFilesCommit(t, repo, "f3", map[string]string{"a.txt": "f3"}, f2)
+	require.NoError(t, repo.UpdateRef("refs/heads/main", m2))
+	require.NoError(t, repo.UpdateRef("refs/heads/feature", f3))
+
+	tests := []struct {
+		spec     string
+		maxCount int
+		want     []string
+	}{
+		{spec: "main..feature", want: []string{f3, f2, f1}},
+		{spec: "feature..main", want: []string{m2, m1}},
+		{spec: "main...feature", want: []string{m2, m1, f3, f2, f1}},
+		{spec: "feature..", want: []string{m2, m1}},
+		{spec: "main..main", want: nil},
+		{spec: "main..feature", maxCount: 2, want: []string{f3, f2}},
+	}
+
+	for _, tt := range tests {
+		entries, err := GetLog(repo, LogOptions{Range: tt.spec, MaxCount: tt.maxCount})
+		require.NoError(t, err, tt.spec)
+		if tt.maxCount > 0 {
+			assert.Equal(t, tt.want, logHashes(entries), tt.spec)
+		} else {
+			assert.ElementsMatch(t, tt.want, logHashes(entries), tt.spec)
+		}
+	}
+
+	_, err := GetLog(repo, LogOptions{Range: "main"})
+	assert.Error(t, err)
+	_, err = GetLog(repo, LogOptions{Range: "main..nope"})
+	assert.Error(t, err)
+}
+
+func TestGetLogStat(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	first := storeFilesCommit(t, repo, "First\n", map[string]string{"a.txt": "1\n"})
+	second := storeFilesCommit(t, repo, "Second\n", map[string]string{"a.txt": "1\n2\n", "b.txt": "b\n"}, first)
+
+	entries, err := GetLog(repo, LogOptions{Revision: second, Stat: true})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, []display.DiffStat{{Path: "a.txt", Added: 1}, {Path: "b.txt", Added: 1}}, entries[0].Stats)
+	assert.Equal(t, []display.DiffStat{{Path: "a.txt", Added: 1}}, entries[1].Stats)
+	assert.Contains(t, entries[0].String(LogOptions{Stat: true}), "2 files changed, 2 insertions(+)")
+}
+
+func TestLogEntryString_Mailmap(t *testing.T) {
+	when := time.Unix(1700000000, 0)
+	sig := &objects.Signature{Name: "jd", Email: "jdoe@old-host.org", When: when}
+	entry := LogEntry{Hash: "0123456789abcdef0123456789abcdef01234567", Author: sig, Committer: sig, Message: "Fix\n"}
+
+	m, err := mailmap.Parse(strings.NewReader("Jane Doe <jane@example.com> <jdoe@old-host.org>\n"))
+	require.NoError(t, err)
+
+	out := entry.String(LogOptions{Mailmap: m})
+	assert.Contains(t, out, "Jane Doe <jane@example.com>")
+	assert.NotContains(t, out, "jdoe@old-host.org")
+	assert.NotContains(t, out, "Commit:")
+
+	assert.Contains(t, entry.String(LogOptions{}), "jd <jdoe@old-host.org>")
+}
@@ -0,0 +1,200 @@
+package merge
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/core/ident"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/mergebase"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/internal/core/treemerge"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const headsPrefix = "refs/heads/"
+
+type MergeOptions struct {
+	// NoFF creates a merge commit even when a fast-forward is possible
+	NoFF bool
+	// FFOnly refuses to merge unless HEAD can be fast-forwarded
+	FFOnly bool
+	// Message replaces the default "Merge branch '<ref>'" message
+	Message string
+}
+
+type MergeResult struct {
+	// Commit is the new HEAD: the merge commit, or the fast-forward target
+	Commit       string
+	FastForward  bool
+	UpToDate     bool
+	UpdatedFiles []string
+	RemovedFiles []string
+	// Conflicts lists paths changed on both sides that could not be merged;
+	// nothing is written when it is set
+	Conflicts []string
+}
+
+// Merge joins the history of otherRef into the current branch, fast-forwarding
+// when HEAD is an ancestor of it and otherwise creating a merge commit with
+// both heads as parents
+func Merge(repo *repository.Repository, otherRef string, options MergeOptions) (*MergeResult, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	if options.NoFF && options.FFOnly {
+		return nil, errors.NewGitError("merge", otherRef, fmt.Errorf("--no-ff and --ff-only are mutually exclusive"))
+	}
+
+	other, err := revparse.ResolveCommit(repo, otherRef)
+	if err != nil {
+		return nil, errors.NewGitError("merge", otherRef, err)
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		return nil, errors.NewGitError("merge", otherRef, err)
+	}
+
+	headFiles := make(map[string]objects.TreeEntry)
+	if head != "" {
+		headFiles, err = checkout.CommitFiles(repo, head)
+		if err != nil {
+			return nil, errors.NewGitError("merge", otherRef, err)
+		}
+	}
+
+	otherFiles, err := checkout.CommitFiles(repo, other)
+	if err != nil {
+		return nil, errors.NewGitError("merge", otherRef, err)
+	}
+
+	var base string
+	if head != "" {
+		bases, err := mergebase.Find(repo, head, other)
+		if err != nil {
+			return nil, errors.NewGitError("merge", otherRef, err)
+		}
+		if len(bases) > 0 {
+			base = bases[0]
+		}
+		if base == other {
+			return &MergeResult{Commit: head, UpToDate: true}, nil
+		}
+		if base == "" {
+			return nil, errors.NewGitError("merge", otherRef, errors.ErrUnrelatedHistories)
+		}
+	}
+
+	fastForward := head == "" || base == head
+	if !fastForward && options.FFOnly {
+		return nil, errors.NewGitError("merge", otherRef, fmt.Errorf("%w: not possible to fast-forward", errors.ErrNonFastForward))
+	}
+
+	// an unborn branch is always fast-forwarded, there is nothing to merge with
+	createCommit := !fastForward || (options.NoFF && head != "")
+
+	result := &MergeResult{}
+	targetFiles := otherFiles
+	if createCommit {
+		baseFiles, err := checkout.CommitFiles(repo, base)
+		if err != nil {
+			return nil, errors.NewGitError("merge", otherRef, err)
+		}
+
+		merged, err := treemerge.Merge(repo, baseFiles, headFiles, otherFiles)
+		if err != nil {
+			return nil, errors.NewGitError("merge", otherRef, err)
+		}
+		if len(merged.Conflicts) > 0 {
+			result.Conflicts = merged.Conflicts
+			return result, errors.NewGitError("merge", otherRef, errors.ErrMergeConflict)
+		}
+		targetFiles = merged.Files
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	if staged := checkout.DiffFiles(headFiles, checkout.IndexFiles(idx)); len(staged) > 0 {
+		return nil, errors.NewGitError("merge", otherRef, fmt.Errorf("your index contains uncommitted changes"))
+	}
+
+	changes := checkout.DiffFiles(headFiles, targetFiles)
+	if conflicts := checkout.FindConflicts(repo, idx, headFiles, targetFiles, changes); len(conflicts) > 0 {
+		return nil, &checkout.ConflictError{Paths: conflicts}
+	}
+
+	result.UpdatedFiles, result.RemovedFiles, err = checkout.ApplyFiles(repo, idx, targetFiles, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	branch, branchErr := repo.GetCurrentBranch()
+
+	newHead := other
+	reflogMsg := fmt.Sprintf("merge %s: Fast-forward", otherRef)
+	if !createCommit {
+		result.FastForward = true
+	} else {
+		treeHash, err := idx.WriteTreeTo(repo)
+		if err != nil {
+			return nil, errors.NewGitError("merge", otherRef, err)
+		}
+
+		message := options.Message
+		if message == "" {
+			message = defaultMessage(repo, otherRef, branch)
+		}
+
+		id, err := ident.Resolve(repo.GitDir)
+		if err != nil {
+			return nil, errors.NewGitError("merge", otherRef, err)
+		}
+		now := time.Now()
+		author, committer := id.Author.Signature(now), id.Committer.Signature(now)
+		newHead, err = repo.StoreObject(objects.NewCommit(treeHash, []string{head, other}, author, committer, message))
+		if err != nil {
+			return nil, errors.NewGitError("merge", otherRef, err)
+		}
+		reflogMsg = fmt.Sprintf("merge %s: Merge made by the 'recursive' strategy.", otherRef)
+	}
+
+	if branchErr == nil {
+		err = repo.UpdateRefWithMessage(headsPrefix+branch, newHead, reflogMsg)
+	} else {
+		err = repo.SetHead(newHead, reflogMsg)
+	}
+	if err != nil {
+		return nil, errors.NewGitError("merge", otherRef, err)
+	}
+
+	idx.MarkAsCommitted()
+	if err := idx.Save(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("save index: %w", err))
+	}
+
+	result.Commit = newHead
+	return result, nil
+}
+
+// defaultMessage names what was merged the way Git does, mentioning the
+// target branch unless it is main or master
+func defaultMessage(repo *repository.Repository, otherRef, branch string) string {
+	message := fmt.Sprintf("Merge commit '%s'", otherRef)
+	if _, err := repo.ReadRef(headsPrefix + otherRef); err == nil {
+		message = fmt.Sprintf("Merge branch '%s'", otherRef)
+	}
+
+	if branch != "" && branch != "main" && branch != "master" {
+		message += " into " + branch
+	}
+
+	return message
+}
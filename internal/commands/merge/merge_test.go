@@ -0,0 +1,193 @@
+package merge
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/testutil"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+func switchBranch(t *testing.T, repo *repository.Repository, name string) {
+	t.Helper()
+	if _, err := checkout.SwitchBranch(repo, name); err != nil {
+		t.Fatalf("Failed to switch to %s: %v", name, err)
+	}
+}
+
+func readFile(t *testing.T, repo *repository.Repository, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, path))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+// setupDivergedRepo creates main and feature branches that both changed a.txt
+// since their common base, on different lines
+func setupDivergedRepo(t *testing.T) (*repository.Repository, string, string) {
+	t.Helper()
+
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	base := testutil.CommitFiles(t, repo, "base", map[string]string{"a.txt": "one\ntwo\nthree\n"})
+	if err := repo.UpdateRef("refs/heads/feature", base); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+
+	mainHead := testutil.CommitFiles(t, repo, "main change", map[string]string{"a.txt": "ONE\ntwo\nthree\n"})
+
+	switchBranch(t, repo, "feature")
+	featureHead := testutil.CommitFiles(t, repo, "feature change", map[string]string{"a.txt": "one\ntwo\nTHREE\n", "b.txt": "feature\n"})
+	switchBranch(t, repo, "main")
+
+	return repo, mainHead, featureHead
+}
+
+func TestMerge_ThreeWay(t *testing.T) {
+	repo, mainHead, featureHead := setupDivergedRepo(t)
+
+	result, err := Merge(repo, "feature", MergeOptions{})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if result.FastForward {
+		t.Error("Expected a merge commit, not a fast-forward")
+	}
+
+	if got := readFile(t, repo, "a.txt"); got != "ONE\ntwo\nTHREE\n" {
+		t.Errorf("Expected both changes merged, got %q", got)
+	}
+	if got := readFile(t, repo, "b.txt"); got != "feature\n" {
+		t.Errorf("Expected b.txt from feature, got %q", got)
+	}
+
+	head, err := repo.ReadRef("refs/heads/main")
+	if err != nil || head != result.Commit {
+		t.Fatalf("Expected main at merge commit %s, got %s (%v)", result.Commit, head, err)
+	}
+
+	obj, err := repo.LoadObject(result.Commit)
+	if err != nil {
+		t.Fatalf("Failed to load merge commit: %v", err)
+	}
+	mergeCommit := obj.(*objects.Commit)
+
+	parents := mergeCommit.Parents()
+	if len(parents) != 2 || parents[0] != mainHead || parents[1] != featureHead {
+		t.Errorf("Expected parents [%s %s], got %v", mainHead, featureHead, parents)
+	}
+	if mergeCommit.Message() != "Merge branch 'feature'" {
+		t.Errorf("Unexpected merge message %q", mergeCommit.Message())
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if _, ok := idx.Get("b.txt"); !ok {
+		t.Error("Expected b.txt in the index after the merge")
+	}
+
+	again, err := Merge(repo, "feature", MergeOptions{})
+	if err != nil || !again.UpToDate {
+		t.Errorf("Expected second merge to be up to date, got %+v (%v)", again, err)
+	}
+}
+
+func TestMerge_FastForward(t *testing.T) {
+	repo, _, featureHead := setupDivergedRepo(t)
+
+	if err := repo.UpdateRef("refs/heads/behind", featureHead); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	switchBranch(t, repo, "feature")
+	ahead := testutil.CommitFiles(t, repo, "more", map[string]string{"c.txt": "c\n"})
+	switchBranch(t, repo, "behind")
+
+	result, err := Merge(repo, "feature", MergeOptions{FFOnly: true})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if !result.FastForward || result.Commit != ahead {
+		t.Errorf("Expected fast-forward to %s, got %+v", ahead, result)
+	}
+	if got := readFile(t, repo, "c.txt"); got != "c\n" {
+		t.Errorf("Expected c.txt checked out, got %q", got)
+	}
+}
+
+func TestMerge_NoFF(t *testing.T) {
+	repo, _, featureHead := setupDivergedRepo(t)
+
+	if err := repo.UpdateRef("refs/heads/behind", featureHead); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	switchBranch(t, repo, "feature")
+	ahead := testutil.CommitFiles(t, repo, "more", map[string]string{"c.txt": "c\n"})
+	switchBranch(t, repo, "behind")
+
+	result, err := Merge(repo, "feature", MergeOptions{NoFF: true, Message: "Bring in feature"})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if result.FastForward {
+		t.Fatal("Expected a merge commit with --no-ff")
+	}
+
+	obj, err := repo.LoadObject(result.Commit)
+	if err != nil {
+		t.Fatalf("Failed to load merge commit: %v", err)
+	}
+	mergeCommit := obj.(*objects.Commit)
+	if parents := mergeCommit.Parents(); len(parents) != 2 || parents[0] != featureHead || parents[1] != ahead {
+		t.Errorf("Unexpected parents %v", parents)
+	}
+	if mergeCommit.Message() != "Bring in feature" {
+		t.Errorf("Expected custom message, got %q", mergeCommit.Message())
+	}
+}
+
+func TestMerge_FFOnlyRefusesDivergedHistory(t *testing.T) {
+	repo, mainHead, _ := setupDivergedRepo(t)
+
+	if _, err := Merge(repo, "feature", MergeOptions{FFOnly: true}); !stderrors.Is(err, errors.ErrNonFastForward) {
+		t.Fatalf("Expected ErrNonFastForward, got %v", err)
+	}
+	if head, _ := repo.GetHead(); head != mainHead {
+		t.Errorf("Expected HEAD unchanged, got %s", head)
+	}
+}
+
+func TestMerge_Conflict(t *testing.T) {
+	repo, mainHead, _ := setupDivergedRepo(t)
+
+	switchBranch(t, repo, "feature")
+	testutil.CommitFiles(t, repo, "clash", map[string]string{"a.txt": "uno\ntwo\nTHREE\n"})
+	switchBranch(t, repo, "main")
+
+	result, err := Merge(repo, "feature", MergeOptions{})
+	if !stderrors.Is(err, errors.ErrMergeConflict) {
+		t.Fatalf("Expected ErrMergeConflict, got %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0] != "a.txt" {
+		t.Errorf("Expected conflict on a.txt, got %v", result.Conflicts)
+	}
+
+	if head, _ := repo.GetHead(); head != mainHead {
+		t.Errorf("Expected HEAD unchanged, got %s", head)
+	}
+	if got := readFile(t, repo, "a.txt"); got != "ONE\ntwo\nthree\n" {
+		t.Errorf("Expected working tree untouched, got %q", got)
+	}
+}
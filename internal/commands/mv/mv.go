@@ -0,0 +1,87 @@
+package mv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// Move renames the tracked file src to dst in both the working tree and the
+// index. When dst is an existing directory the file keeps its name inside
+// it. If the index cannot be saved the file is moved back.
+func Move(repo *repository.Repository, src, dst string) error {
+	if !repo.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	srcPath := filepath.ToSlash(filepath.Clean(src))
+	entry, ok := idx.Get(srcPath)
+	if !ok {
+		return errors.NewGitError("mv", src, fmt.Errorf("not under version control"))
+	}
+
+	srcFull := filepath.Join(repo.WorkDir, filepath.FromSlash(srcPath))
+	srcInfo, err := os.Lstat(srcFull)
+	if err != nil {
+		return errors.NewGitError("mv", src, fmt.Errorf("bad source: %w", err))
+	}
+
+	dstPath := filepath.ToSlash(filepath.Clean(dst))
+	dstFull := filepath.Join(repo.WorkDir, filepath.FromSlash(dstPath))
+	if info, err := os.Stat(dstFull); err == nil && info.IsDir() {
+		dstPath = filepath.ToSlash(filepath.Join(dstPath, filepath.Base(srcFull)))
+		dstFull = filepath.Join(repo.WorkDir, filepath.FromSlash(dstPath))
+	}
+
+	if dstPath == srcPath {
+		return errors.NewGitError("mv", dst, fmt.Errorf("source and destination are the same"))
+	}
+	if _, err := os.Lstat(dstFull); err == nil {
+		return errors.NewGitError("mv", dst, fmt.Errorf("destination exists"))
+	}
+	if _, ok := idx.Get(dstPath); ok {
+		return errors.NewGitError("mv", dst, fmt.Errorf("destination exists in the index"))
+	}
+
+	unchanged := idx.StatUnchanged(srcPath, srcInfo)
+	if err := os.Rename(srcFull, dstFull); err != nil {
+		return errors.NewGitError("mv", src, err)
+	}
+
+	if err := idx.Remove(srcPath); err != nil {
+		return rollback(dstFull, srcFull, errors.NewIndexError(srcPath, err))
+	}
+	// a file that matched its entry takes the stat data it has after the
+	// rename, so it still matches; any other is left to be rehashed
+	dstInfo, err := os.Lstat(dstFull)
+	if err == nil && unchanged {
+		err = idx.AddWithFileInfo(dstPath, entry.Hash, entry.Mode, dstInfo)
+	} else {
+		err = idx.Add(dstPath, entry.Hash, entry.Mode, entry.Size, entry.ModTime)
+	}
+	if err != nil {
+		return rollback(dstFull, srcFull, errors.NewIndexError(dstPath, err))
+	}
+	if err := idx.Save(); err != nil {
+		return rollback(dstFull, srcFull, errors.NewIndexError("", fmt.Errorf("save index: %w", err)))
+	}
+
+	return nil
+}
+
+// rollback moves the file back after the index could not be updated
+func rollback(from, to string, err error) error {
+	if renameErr := os.Rename(from, to); renameErr != nil {
+		return fmt.Errorf("%w (and moving the file back failed: %v)", err, renameErr)
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package mv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/add"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+func setupTestRepo(t *testing.T, files ...string) *repository.Repository {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	for _, name := range files {
+		path := filepath.Join(repo.WorkDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name+" content"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := add.AddFiles(repo, files); err != nil {
+		t.Fatalf("Failed to add files: %v", err)
+	}
+	return repo
+}
+
+func loadIndex(t *testing.T, repo *repository.Repository) *index.Index {
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	return idx
+}
+
+func TestMove(t *testing.T) {
+	repo := setupTestRepo(t, "old.txt")
+	before, _ := loadIndex(t, repo).Get("old.txt")
+
+	if err := Move(repo, "old.txt", "new.txt"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+
+	idx := loadIndex(t, repo)
+	if _, ok := idx.Get("old.txt"); ok {
+		t.Error("old.txt should have been removed from the index")
+	}
+	after, ok := idx.Get("new.txt")
+	if !ok {
+		t.Fatal("new.txt should be in the index")
+	}
+	if after.Hash != before.Hash || after.Mode != before.Mode {
+		t.Errorf("Expected hash %s mode %o, got %s %o", before.Hash, before.Mode, after.Hash, after.Mode)
+	}
+
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "old.txt")); !os.IsNotExist(err) {
+		t.Error("old.txt should no longer exist in the working tree")
+	}
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, "new.txt"))
+	if err != nil || string(content) != "old.txt content" {
+		t.Errorf("Expected new.txt to hold the moved content, got %q (%v)", content, err)
+	}
+}
+
+func TestMove_KeepsCleanFileMatching(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	path := filepath.Join(repo.WorkDir, "old.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to write old.txt: %v", err)
+	}
+	// written well before the index, so the entry is not racily clean
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatalf("Failed to set times: %v", err)
+	}
+	if err := add.AddFiles(repo, []string{"old.txt"}); err != nil {
+		t.Fatalf("Failed to add files: %v", err)
+	}
+
+	if err := Move(repo, "old.txt", "new.txt"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+
+	info, err := os.Lstat(filepath.Join(repo.WorkDir, "new.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat new.txt: %v", err)
+	}
+	if !loadIndex(t, repo).StatUnchanged("new.txt", info) {
+		t.Error("Expected the moved file to match its entry without rehashing")
+	}
+}
+
+func TestMove_IntoDirectory(t *testing.T) {
+	repo := setupTestRepo(t, "file.txt", "docs/readme.md")
+
+	if err := Move(repo, "file.txt", "docs"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+
+	idx := loadIndex(t, repo)
+	if _, ok := idx.Get("docs/file.txt"); !ok {
+		t.Error("docs/file.txt should be in the index")
+	}
+	if _, ok := idx.Get("file.txt"); ok {
+		t.Error("file.txt should have been removed from the index")
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "docs", "file.txt")); err != nil {
+		t.Errorf("docs/file.txt should exist: %v", err)
+	}
+}
+
+func TestMove_Errors(t *testing.T) {
+	repo := setupTestRepo(t, "a.txt", "b.txt")
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "untracked.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write untracked file: %v", err)
+	}
+
+	if err := Move(repo, "untracked.txt", "c.txt"); err == nil {
+		t.Error("Expected an error moving an untracked file")
+	}
+	if err := Move(repo, "a.txt", "b.txt"); err == nil {
+		t.Error("Expected an error when the destination exists")
+	}
+
+	idx := loadIndex(t, repo)
+	if _, ok := idx.Get("a.txt"); !ok {
+		t.Error("A failed move must leave the index unchanged")
+	}
+}
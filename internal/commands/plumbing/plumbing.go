@@ -0,0 +1,80 @@
+// Package plumbing holds low-level commands that move trees between the
+// object store and the index
+package plumbing
+
+import (
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// WriteTree stores the tree the index describes, and every subtree, and
+// returns its hash. An empty index writes the empty tree.
+func WriteTree(repo *repository.Repository) (string, error) {
+	if !repo.Exists() {
+		return "", errors.ErrNotGitRepository
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return "", errors.NewGitError("write-tree", "", err)
+	}
+
+	treeHash, err := idx.WriteTreeTo(repo)
+	if stderrors.Is(err, errors.ErrNothingToCommit) {
+		return repo.StoreObject(objects.NewTree(nil))
+	}
+	if err != nil {
+		return "", errors.NewGitError("write-tree", "", err)
+	}
+
+	// keep the cache tree WriteTreeTo computed
+	if err := idx.Save(); err != nil {
+		return "", errors.NewGitError("write-tree", "", err)
+	}
+	return treeHash, nil
+}
+
+// ReadTree replaces the index with the entries of treeHash. The working tree
+// is left untouched.
+func ReadTree(repo *repository.Repository, treeHash string) error {
+	if !repo.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return errors.NewGitError("read-tree", treeHash, err)
+	}
+	idx.Clear()
+
+	err := objects.WalkTree(repo, treeHash, func(entryPath string, entry objects.TreeEntry) error {
+		if entry.IsDir() {
+			return nil
+		}
+
+		// a gitlink names a commit of another repository, which has no size here
+		var size int64
+		if !entry.IsSubmodule() {
+			var err error
+			if size, err = repo.ObjectSize(entry.Hash); err != nil {
+				return errors.NewObjectError(entry.Hash, "blob", fmt.Errorf("read blob size: %w", err))
+			}
+		}
+
+		if err := idx.Add(entryPath, entry.Hash, uint32(entry.Mode), size, time.Now()); err != nil {
+			return errors.NewIndexError(entryPath, fmt.Errorf("failed to add file to index: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.NewGitError("read-tree", treeHash, err)
+	}
+
+	return idx.Save()
+}
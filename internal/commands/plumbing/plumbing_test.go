@@ -0,0 +1,145 @@
+package plumbing
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+func setupTestRepo(t *testing.T) *repository.Repository {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	return repo
+}
+
+func storeTree(t *testing.T, repo *repository.Repository, files map[string]string) string {
+	store := func(obj objects.Object) string {
+		h, err := repo.StoreObject(obj)
+		if err != nil {
+			t.Fatalf("Failed to store %s: %v", obj.Type(), err)
+		}
+		return h
+	}
+
+	var rootEntries, subEntries []objects.TreeEntry
+	for name, content := range files {
+		entry := objects.TreeEntry{Mode: objects.FileModeBlob, Name: name, Hash: store(objects.NewBlob([]byte(content)))}
+		if name == "run.sh" {
+			entry.Mode = objects.FileModeExecutable
+		}
+		if name == "inner.txt" {
+			subEntries = append(subEntries, entry)
+		} else {
+			rootEntries = append(rootEntries, entry)
+		}
+	}
+	if len(subEntries) > 0 {
+		rootEntries = append(rootEntries, objects.TreeEntry{Mode: objects.FileModeTree, Name: "dir", Hash: store(objects.NewTree(subEntries))})
+	}
+	sort.Slice(rootEntries, func(i, j int) bool { return rootEntries[i].Name < rootEntries[j].Name })
+	return store(objects.NewTree(rootEntries))
+}
+
+func TestReadTreeWriteTreeRoundTrip(t *testing.T) {
+	repo := setupTestRepo(t)
+	treeHash := storeTree(t, repo, map[string]string{"a.txt": "a\n", "run.sh": "#!/bin/sh\n", "inner.txt": "inner\n"})
+
+	if err := ReadTree(repo, treeHash); err != nil {
+		t.Fatalf("ReadTree failed: %v", err)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	entries := idx.GetAll()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 index entries, got %d", len(entries))
+	}
+	if entry, ok := entries["dir/inner.txt"]; !ok {
+		t.Error("Expected dir/inner.txt in the index")
+	} else if entry.Size != int64(len("inner\n")) {
+		t.Errorf("Expected the blob size in the entry, got %d", entry.Size)
+	}
+	if entries["run.sh"].Mode != uint32(objects.FileModeExecutable) {
+		t.Errorf("Expected run.sh to keep its executable mode, got %o", entries["run.sh"].Mode)
+	}
+
+	written, err := WriteTree(repo)
+	if err != nil {
+		t.Fatalf("WriteTree failed: %v", err)
+	}
+	if written != treeHash {
+		t.Errorf("Expected WriteTree to reproduce %s, got %s", treeHash, written)
+	}
+}
+
+func TestReadTreeReplacesIndex(t *testing.T) {
+	repo := setupTestRepo(t)
+	first := storeTree(t, repo, map[string]string{"old.txt": "old\n", "inner.txt": "inner\n"})
+	second := storeTree(t, repo, map[string]string{"new.txt": "new\n"})
+
+	if err := ReadTree(repo, first); err != nil {
+		t.Fatalf("ReadTree failed: %v", err)
+	}
+	if err := ReadTree(repo, second); err != nil {
+		t.Fatalf("ReadTree failed: %v", err)
+	}
+
+	written, err := WriteTree(repo)
+	if err != nil {
+		t.Fatalf("WriteTree failed: %v", err)
+	}
+	if written != second {
+		t.Errorf("Expected only the second tree in the index, got %s", written)
+	}
+}
+
+func TestWriteTreeEmptyIndex(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	written, err := WriteTree(repo)
+	if err != nil {
+		t.Fatalf("WriteTree failed: %v", err)
+	}
+	if written != "4b825dc642cb6eb9a060e54bf8d69288fbee4904" {
+		t.Errorf("Expected the empty tree, got %s", written)
+	}
+	if _, err := repo.LoadTree(written); err != nil {
+		t.Errorf("Expected the empty tree to be stored: %v", err)
+	}
+}
+
+func TestReadTreeMissing(t *testing.T) {
+	repo := setupTestRepo(t)
+	if err := ReadTree(repo, "1111111111111111111111111111111111111111"); err == nil {
+		t.Error("Expected an error for a missing tree")
+	}
+}
+
+func TestReadTreeSubmodule(t *testing.T) {
+	repo := setupTestRepo(t)
+	// the gitlink's commit lives in the submodule, not in this repository
+	gitlink := "2222222222222222222222222222222222222222"
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeCommit, Name: "lib", Hash: gitlink}}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+
+	if err := ReadTree(repo, treeHash); err != nil {
+		t.Fatalf("ReadTree failed: %v", err)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if entry, ok := idx.Get("lib"); !ok || entry.Hash != gitlink || entry.Mode != uint32(objects.FileModeCommit) {
+		t.Errorf("Expected the gitlink in the index, got %+v", entry)
+	}
+}
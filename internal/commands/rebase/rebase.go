@@ -0,0 +1,338 @@
+package rebase
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/commands/revlist"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/ident"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/internal/core/treemerge"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const headsPrefix = "refs/heads/"
+
+type Action string
+
+const (
+	ActionPick   Action = "pick"
+	ActionSquash Action = "squash"
+	ActionEdit   Action = "edit"
+	ActionDrop   Action = "drop"
+)
+
+// actionAbbreviations are the one-letter forms accepted in a todo list
+var actionAbbreviations = map[string]Action{
+	"p": ActionPick,
+	"s": ActionSquash,
+	"e": ActionEdit,
+	"d": ActionDrop,
+}
+
+// RebaseStep is one line of a rebase todo list
+type RebaseStep struct {
+	Action Action
+	Commit string
+	// Subject is the first line of the commit's message, for the reader
+	Subject string
+}
+
+func (s RebaseStep) String() string {
+	return fmt.Sprintf("%s %s %s", s.Action, hash.ShortHash(s.Commit, 7), s.Subject)
+}
+
+type StepResult struct {
+	Step RebaseStep
+	// Commit is the rewritten commit; a squash's replaces the one of the
+	// step it was squashed into. It is empty for a dropped step and for a
+	// pick whose changes are already in the new base.
+	Commit string
+	// Conflicts lists the paths the step's changes could not be applied to
+	Conflicts []string
+}
+
+// RebasePlan is what Plan decided: the todo list, the commit it replays
+// onto, and the HEAD it was planned from, which Apply checks has not moved
+type RebasePlan struct {
+	Onto     string
+	OrigHead string
+	Steps    []RebaseStep
+}
+
+type RebaseResult struct {
+	Head         string
+	Steps        []StepResult
+	UpdatedFiles []string
+	RemovedFiles []string
+}
+
+// Plan lists the commits reachable from HEAD but not from upstream, oldest
+// first, as a todo list picking every one of them onto onto, or onto
+// upstream when onto is empty. Merge commits are left out, as git does
+// without --rebase-merges. Nothing is written; the plan is handed to Apply,
+// its steps edited or replaced by ParseTodo first if need be.
+func Plan(repo *repository.Repository, onto, upstream string) (*RebasePlan, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	if onto == "" {
+		onto = upstream
+	}
+	ontoHash, err := revparse.ResolveCommit(repo, onto)
+	if err != nil {
+		return nil, errors.NewGitError("rebase", onto, err)
+	}
+	upstreamHash, err := revparse.ResolveCommit(repo, upstream)
+	if err != nil {
+		return nil, errors.NewGitError("rebase", upstream, err)
+	}
+
+	head, err := repo.GetHead()
+	if err != nil || head == "" {
+		return nil, errors.NewGitError("rebase", upstream, fmt.Errorf("HEAD does not point at a commit"))
+	}
+
+	hashes, err := revlist.RevList(repo, revlist.RevListOptions{
+		Include: []string{head},
+		Exclude: []string{upstreamHash},
+		Reverse: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []RebaseStep{}
+	for _, commitHash := range hashes {
+		commit, err := repo.LoadCommit(commitHash)
+		if err != nil {
+			return nil, errors.NewObjectError(commitHash, "commit", err)
+		}
+		if len(commit.Parents()) > 1 {
+			continue
+		}
+		subject, _, _ := strings.Cut(commit.Message(), "\n")
+		steps = append(steps, RebaseStep{Action: ActionPick, Commit: commitHash, Subject: subject})
+	}
+
+	return &RebasePlan{Onto: ontoHash, OrigHead: head, Steps: steps}, nil
+}
+
+// ParseTodo reads a todo list in the form RebaseStep.String writes, as
+// edited by a user: blank lines and lines starting with '#' are skipped,
+// actions may be abbreviated to their first letter and commits to a
+// unique prefix.
+func ParseTodo(repo *repository.Repository, todo string) ([]RebaseStep, error) {
+	var steps []RebaseStep
+	for i, line := range strings.Split(todo, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			return nil, errors.NewGitError("rebase", "", fmt.Errorf("line %d: expected an action and a commit", i+1))
+		}
+
+		action := Action(fields[0])
+		if abbreviated, ok := actionAbbreviations[fields[0]]; ok {
+			action = abbreviated
+		}
+		switch action {
+		case ActionPick, ActionSquash, ActionEdit, ActionDrop:
+		default:
+			return nil, errors.NewGitError("rebase", "", fmt.Errorf("line %d: unknown action %q", i+1, fields[0]))
+		}
+
+		commitHash, err := revparse.ResolveCommit(repo, fields[1])
+		if err != nil {
+			return nil, errors.NewGitError("rebase", "", fmt.Errorf("line %d: %w", i+1, err))
+		}
+
+		step := RebaseStep{Action: action, Commit: commitHash}
+		if len(fields) == 3 {
+			step.Subject = fields[2]
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// Apply replays the plan's steps on its base and moves the current branch
+// to the result. A pick applies a commit's changes as a new commit,
+// a squash folds them and its message into the commit before it, and a
+// drop leaves the commit out. The new commits are built before anything
+// is changed, so a step that conflicts is reported in the result with its
+// paths and leaves HEAD, the index and the working tree as they were.
+func Apply(repo *repository.Repository, plan *RebasePlan) (*RebaseResult, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	onto, steps := plan.Onto, plan.Steps
+	head, err := repo.GetHead()
+	if err != nil || head != plan.OrigHead {
+		return nil, errors.NewGitError("rebase", "", fmt.Errorf("HEAD has moved since the rebase was planned"))
+	}
+
+	id, err := ident.Resolve(repo.GitDir)
+	if err != nil {
+		return nil, errors.NewGitError("rebase", "", err)
+	}
+	committer := id.Committer.Signature(time.Now())
+
+	tip := onto
+	tipFiles, err := checkout.CommitFiles(repo, onto)
+	if err != nil {
+		return nil, errors.NewGitError("rebase", onto, err)
+	}
+	// last is the newest commit this rebase put at tip, which a squash
+	// folds into, and lastStep its index in result.Steps
+	var last *objects.Commit
+	lastStep := -1
+
+	result := &RebaseResult{}
+	for _, step := range steps {
+		stepResult := StepResult{Step: step}
+
+		switch step.Action {
+		case ActionDrop:
+			result.Steps = append(result.Steps, stepResult)
+			continue
+		case ActionPick:
+		case ActionSquash:
+			if last == nil {
+				return result, errors.NewGitError("rebase", step.Commit, fmt.Errorf("cannot squash without a previous commit"))
+			}
+		default:
+			return result, errors.NewGitError("rebase", step.Commit, fmt.Errorf("action %q is not supported", step.Action))
+		}
+
+		commit, err := repo.LoadCommit(step.Commit)
+		if err != nil {
+			return result, errors.NewObjectError(step.Commit, "commit", err)
+		}
+		parents := commit.Parents()
+		if len(parents) > 1 {
+			return result, errors.NewGitError("rebase", step.Commit, fmt.Errorf("cannot replay a merge commit"))
+		}
+
+		commitFiles, err := checkout.TreeFiles(repo, commit.Tree())
+		if err != nil {
+			return result, errors.NewGitError("rebase", step.Commit, err)
+		}
+
+		// a commit already on top of tip is kept as it is
+		if step.Action == ActionPick && len(parents) == 1 && parents[0] == tip {
+			tip, tipFiles, last = step.Commit, commitFiles, commit
+			stepResult.Commit = tip
+			lastStep = len(result.Steps)
+			result.Steps = append(result.Steps, stepResult)
+			continue
+		}
+
+		parentFiles := make(map[string]objects.TreeEntry)
+		if len(parents) == 1 {
+			if parentFiles, err = checkout.CommitFiles(repo, parents[0]); err != nil {
+				return result, errors.NewGitError("rebase", step.Commit, err)
+			}
+		}
+
+		merged, err := treemerge.Merge(repo, parentFiles, tipFiles, commitFiles)
+		if err != nil {
+			return result, errors.NewGitError("rebase", step.Commit, err)
+		}
+		if len(merged.Conflicts) > 0 {
+			stepResult.Conflicts = merged.Conflicts
+			result.Steps = append(result.Steps, stepResult)
+			return result, errors.NewGitError("rebase", step.Commit, errors.ErrMergeConflict)
+		}
+
+		if step.Action == ActionPick && len(checkout.DiffFiles(tipFiles, merged.Files)) == 0 {
+			result.Steps = append(result.Steps, stepResult)
+			continue
+		}
+
+		treeHash, err := checkout.WriteTree(repo, merged.Files)
+		if err != nil {
+			return result, errors.NewGitError("rebase", step.Commit, err)
+		}
+
+		newParents, author, message := []string{tip}, commit.Author(), commit.Message()
+		if step.Action == ActionSquash {
+			newParents, author = last.Parents(), last.Author()
+			message = strings.TrimRight(last.Message(), "\n") + "\n\n" + message
+		}
+		rewritten := objects.NewCommit(treeHash, newParents, author, committer, message)
+		if tip, err = repo.StoreObject(rewritten); err != nil {
+			return result, errors.NewGitError("rebase", step.Commit, err)
+		}
+		tipFiles, last = merged.Files, rewritten
+
+		stepResult.Commit = tip
+		if step.Action == ActionSquash {
+			result.Steps[lastStep].Commit = ""
+		}
+		lastStep = len(result.Steps)
+		result.Steps = append(result.Steps, stepResult)
+	}
+
+	if err := finish(repo, head, tip, onto, tipFiles, result); err != nil {
+		return result, err
+	}
+	result.Head = tip
+	return result, nil
+}
+
+// finish checks out the rebased tip and moves the current branch, or a
+// detached HEAD, to it
+func finish(repo *repository.Repository, head, tip, onto string, tipFiles map[string]objects.TreeEntry, result *RebaseResult) error {
+	headFiles, err := checkout.CommitFiles(repo, head)
+	if err != nil {
+		return errors.NewGitError("rebase", head, err)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	if staged := checkout.DiffFiles(headFiles, checkout.IndexFiles(idx)); len(staged) > 0 {
+		return errors.NewGitError("rebase", "", fmt.Errorf("your index contains uncommitted changes"))
+	}
+
+	changes := checkout.DiffFiles(headFiles, tipFiles)
+	if conflicts := checkout.FindConflicts(repo, idx, headFiles, tipFiles, changes); len(conflicts) > 0 {
+		return &checkout.ConflictError{Paths: conflicts}
+	}
+
+	result.UpdatedFiles, result.RemovedFiles, err = checkout.ApplyFiles(repo, idx, tipFiles, changes)
+	if err != nil {
+		return err
+	}
+
+	reflogMsg := "rebase (finish): onto " + onto
+	if branch, err := repo.GetCurrentBranch(); err == nil {
+		reflogMsg = fmt.Sprintf("rebase (finish): %s%s onto %s", headsPrefix, branch, onto)
+		err = repo.UpdateRefWithMessage(headsPrefix+branch, tip, reflogMsg)
+		if err != nil {
+			return errors.NewGitError("rebase", "", err)
+		}
+	} else if err := repo.SetHead(tip, reflogMsg); err != nil {
+		return errors.NewGitError("rebase", "", err)
+	}
+
+	idx.MarkAsCommitted()
+	if err := idx.Save(); err != nil {
+		return errors.NewIndexError("", fmt.Errorf("save index: %w", err))
+	}
+	return nil
+}
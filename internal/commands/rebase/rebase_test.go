@@ -0,0 +1,298 @@
+package rebase
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/testutil"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// storeCommit stores a commit on parent with files changed, without
+// touching HEAD or the working tree
+func storeCommit(t *testing.T, repo *repository.Repository, parent, message string, files map[string]string) string {
+	t.Helper()
+
+	tree, err := checkout.CommitFiles(repo, parent)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", parent, err)
+	}
+	for path, content := range files {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		tree[path] = objects.TreeEntry{Mode: objects.FileModeBlob, Name: path, Hash: blobHash}
+	}
+	treeHash, err := checkout.WriteTree(repo, tree)
+	if err != nil {
+		t.Fatalf("Failed to write tree: %v", err)
+	}
+
+	sig := &objects.Signature{Name: "Test Author", Email: "test@example.com", When: time.Now()}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, []string{parent}, sig, sig, message))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+	return commitHash
+}
+
+func readFile(t *testing.T, repo *repository.Repository, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, path))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+// history lists the messages of the first-parent chain from HEAD down to,
+// but not including, stop
+func history(t *testing.T, repo *repository.Repository, stop string) []string {
+	t.Helper()
+	head, err := repo.GetHead()
+	if err != nil {
+		t.Fatalf("Failed to read HEAD: %v", err)
+	}
+
+	var messages []string
+	for commitHash := head; commitHash != stop; {
+		c, err := repo.LoadCommit(commitHash)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", commitHash, err)
+		}
+		messages = append(messages, c.Message())
+		if len(c.Parents()) == 0 {
+			t.Fatalf("Reached the root without meeting %s", stop)
+		}
+		commitHash = c.Parents()[0]
+	}
+	return messages
+}
+
+// setupDiverged commits three changes on the current branch and stores an
+// upstream commit that adds c.txt to their base
+func setupDiverged(t *testing.T) (*repository.Repository, string, []string) {
+	t.Helper()
+
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	base := testutil.CommitFiles(t, repo, "base", map[string]string{"a.txt": "1\n2\n3\n"})
+	commits := []string{
+		testutil.CommitFiles(t, repo, "one", map[string]string{"a.txt": "one\n2\n3\n"}),
+		testutil.CommitFiles(t, repo, "two", map[string]string{"b.txt": "b\n"}),
+		testutil.CommitFiles(t, repo, "three", map[string]string{"a.txt": "one\n2\nthree\n"}),
+	}
+	upstream := storeCommit(t, repo, base, "upstream", map[string]string{"c.txt": "c\n"})
+
+	return repo, upstream, commits
+}
+
+func TestPlanAndApply_Pick(t *testing.T) {
+	repo, upstream, commits := setupDiverged(t)
+
+	plan, err := Plan(repo, "", upstream)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	want := []RebaseStep{
+		{Action: ActionPick, Commit: commits[0], Subject: "one"},
+		{Action: ActionPick, Commit: commits[1], Subject: "two"},
+		{Action: ActionPick, Commit: commits[2], Subject: "three"},
+	}
+	if !slices.Equal(plan.Steps, want) {
+		t.Fatalf("Expected plan %v, got %v", want, plan.Steps)
+	}
+	if plan.Onto != upstream || plan.OrigHead != commits[2] {
+		t.Errorf("Expected the plan onto %s from %s, got %s from %s", upstream, commits[2], plan.Onto, plan.OrigHead)
+	}
+	// stock git would take a rebase-merge directory for a rebase in progress
+	if _, err := os.Stat(filepath.Join(repo.GitDir, "rebase-merge")); !os.IsNotExist(err) {
+		t.Errorf("Expected planning to write nothing, got %v", err)
+	}
+
+	result, err := Apply(repo, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if got := history(t, repo, upstream); !slices.Equal(got, []string{"three", "two", "one"}) {
+		t.Errorf("Expected the three commits replayed onto upstream, got %q", got)
+	}
+	if head, _ := repo.GetHead(); head != result.Head {
+		t.Errorf("Expected HEAD at %s, got %s", result.Head, head)
+	}
+	for i, step := range result.Steps {
+		if step.Commit == "" || step.Commit == commits[i] {
+			t.Errorf("Step %d: expected a rewritten commit, got %q", i, step.Commit)
+		}
+	}
+
+	for path, content := range map[string]string{"a.txt": "one\n2\nthree\n", "b.txt": "b\n", "c.txt": "c\n"} {
+		if got := readFile(t, repo, path); got != content {
+			t.Errorf("Expected %s to be %q, got %q", path, content, got)
+		}
+	}
+}
+
+func TestApply_PickInPlace(t *testing.T) {
+	repo, _, commits := setupDiverged(t)
+
+	plan, err := Plan(repo, "", commits[0])
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	result, err := Apply(repo, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Head != commits[2] {
+		t.Errorf("Expected commits already on their base to be kept, got HEAD %s", result.Head)
+	}
+}
+
+func TestApply_Squash(t *testing.T) {
+	repo, upstream, commits := setupDiverged(t)
+
+	plan, err := Plan(repo, "", upstream)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	plan.Steps[1].Action = ActionSquash
+
+	result, err := Apply(repo, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if got := history(t, repo, upstream); !slices.Equal(got, []string{"three", "one\n\ntwo"}) {
+		t.Errorf("Expected two squashed into one, got %q", got)
+	}
+	if result.Steps[0].Commit != "" || result.Steps[1].Commit == "" {
+		t.Errorf("Expected the squash to replace the commit it folds into, got %+v", result.Steps[:2])
+	}
+
+	squashed, err := repo.LoadCommit(result.Steps[1].Commit)
+	if err != nil {
+		t.Fatalf("Failed to load squashed commit: %v", err)
+	}
+	files, err := checkout.TreeFiles(repo, squashed.Tree())
+	if err != nil {
+		t.Fatalf("Failed to read squashed tree: %v", err)
+	}
+	if _, ok := files["b.txt"]; !ok {
+		t.Error("Expected the squashed commit to carry the changes of both")
+	}
+	if commits[1] == result.Steps[1].Commit {
+		t.Error("Expected the squashed commit to be new")
+	}
+}
+
+func TestApply_Drop(t *testing.T) {
+	repo, upstream, _ := setupDiverged(t)
+
+	plan, err := Plan(repo, "", upstream)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	plan.Steps[1].Action = ActionDrop
+
+	result, err := Apply(repo, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if got := history(t, repo, upstream); !slices.Equal(got, []string{"three", "one"}) {
+		t.Errorf("Expected two to be dropped, got %q", got)
+	}
+	if result.Steps[1].Commit != "" {
+		t.Errorf("Expected no commit for the dropped step, got %s", result.Steps[1].Commit)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected b.txt of the dropped commit to be removed, got %v", err)
+	}
+}
+
+func TestApply_Conflict(t *testing.T) {
+	repo, _, commits := setupDiverged(t)
+
+	c, err := repo.LoadCommit(commits[0])
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	upstream := storeCommit(t, repo, c.Parents()[0], "upstream", map[string]string{"a.txt": "uno\n2\n3\n"})
+
+	plan, err := Plan(repo, "", upstream)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	result, err := Apply(repo, plan)
+	if !stderrors.Is(err, errors.ErrMergeConflict) {
+		t.Fatalf("Expected ErrMergeConflict, got %v", err)
+	}
+	if len(result.Steps) != 1 || !slices.Equal(result.Steps[0].Conflicts, []string{"a.txt"}) {
+		t.Errorf("Expected the first step to conflict on a.txt, got %+v", result.Steps)
+	}
+
+	if head, _ := repo.GetHead(); head != commits[2] {
+		t.Errorf("Expected HEAD unchanged at %s, got %s", commits[2], head)
+	}
+	if got := readFile(t, repo, "a.txt"); got != "one\n2\nthree\n" {
+		t.Errorf("Expected working tree untouched, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo.GitDir, "rebase-merge")); !os.IsNotExist(err) {
+		t.Errorf("Expected no rebase state left behind, got %v", err)
+	}
+}
+
+func TestApply_HeadMoved(t *testing.T) {
+	repo, upstream, commits := setupDiverged(t)
+
+	plan, err := Plan(repo, "", upstream)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/main", commits[1]); err != nil {
+		t.Fatalf("Failed to move branch: %v", err)
+	}
+	if _, err := Apply(repo, plan); err == nil {
+		t.Error("Expected a plan made from another HEAD to be refused")
+	}
+}
+
+func TestParseTodo(t *testing.T) {
+	repo, upstream, commits := setupDiverged(t)
+
+	plan, err := Plan(repo, "", upstream)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	todo := "# edited\n" + plan.Steps[0].String() + "\n\ns " + commits[1][:10] + "\nd " + commits[2] + " three\n"
+
+	parsed, err := ParseTodo(repo, todo)
+	if err != nil {
+		t.Fatalf("ParseTodo failed: %v", err)
+	}
+	want := []RebaseStep{
+		{Action: ActionPick, Commit: commits[0], Subject: "one"},
+		{Action: ActionSquash, Commit: commits[1]},
+		{Action: ActionDrop, Commit: commits[2], Subject: "three"},
+	}
+	if !slices.Equal(parsed, want) {
+		t.Errorf("Expected %v, got %v", want, parsed)
+	}
+
+	if _, err := ParseTodo(repo, "reword "+commits[0]); err == nil {
+		t.Error("Expected an unknown action to be rejected")
+	}
+}
@@ -4,23 +4,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 const (
 	defaultDirMode     = 0755
-	directoryMode      = 0o040000
 	gitHashLength      = 40
 	minShortHashLength = 4
-	hashPrefixLength   = 2
 	headRef            = "HEAD"
-	headsPrefix        = "refs/heads/"
-	objectsDir         = "objects"
 )
 
 type ResetMode int
@@ -45,55 +45,161 @@ func (m ResetMode) String() string {
 	}
 }
 
-func Reset(repo *repository.Repository, target string, mode ResetMode, paths []string) error {
+// ResetOptions selects what Reset changes
+type ResetOptions struct {
+	Mode ResetMode
+	// Paths limits a mixed reset to these index entries
+	Paths []string
+	// DryRun reports what a hard reset would change without touching
+	// HEAD, the index or the working tree
+	DryRun bool
+	// Force lets a hard reset overwrite untracked files
+	Force bool
+}
+
+// ResetResult lists the working tree files a hard reset deletes, overwrites
+// and restores. It is empty for soft, mixed and path resets.
+type ResetResult struct {
+	Deleted     []string
+	Overwritten []string
+	Restored    []string
+}
+
+// UntrackedError lists the untracked files a hard reset would overwrite
+type UntrackedError struct {
+	Paths []string
+}
+
+func (e *UntrackedError) Error() string {
+	return fmt.Sprintf("%v:\n\t%s\nplease move or remove them before you reset, or use --force",
+		errors.ErrUntrackedOverwritten, strings.Join(e.Paths, "\n\t"))
+}
+
+func (e *UntrackedError) Unwrap() error {
+	return errors.ErrUntrackedOverwritten
+}
+
+func Reset(repo *repository.Repository, target string, opts ResetOptions) (*ResetResult, error) {
 	if !repo.Exists() {
-		return errors.ErrNotGitRepository
+		return nil, errors.ErrNotGitRepository
 	}
 
+	result := &ResetResult{}
+
 	// if paths are specified, do a pathspec reset (mixed mode only)
-	if len(paths) > 0 {
-		return resetPaths(repo, target, paths)
+	if len(opts.Paths) > 0 {
+		if opts.DryRun {
+			return result, nil
+		}
+		return result, resetPaths(repo, target, opts.Paths)
 	}
 
 	targetHash, err := resolveTarget(repo, target)
 	if err != nil {
-		return errors.NewGitError("reset", target, fmt.Errorf("failed to resolve target '%s': %w", target, err))
+		return nil, errors.NewGitError("reset", target, fmt.Errorf("failed to resolve target '%s': %w", target, err))
 	}
 
-	targetObj, err := repo.LoadObject(targetHash)
+	targetCommit, err := repo.LoadCommit(targetHash)
 	if err != nil {
-		return errors.NewObjectError(targetHash, "commit", err)
+		return nil, errors.NewObjectError(targetHash, "commit", err)
+	}
+
+	if opts.Mode == ResetModeHard {
+		untracked, err := planWorkingTree(repo, targetCommit.Tree(), result)
+		if err != nil {
+			return nil, errors.NewGitError("reset", "", err)
+		}
+		if len(untracked) > 0 && !opts.Force && !opts.DryRun {
+			return nil, &UntrackedError{Paths: untracked}
+		}
 	}
 
-	targetCommit, ok := targetObj.(*objects.Commit)
-	if !ok {
-		return errors.NewObjectError(targetHash, "commit", errors.ErrInvalidCommit)
+	if opts.DryRun {
+		return result, nil
 	}
 
-	// update HEAD reference
-	currentBranch, err := repo.GetCurrentBranch()
+	// update HEAD reference, or HEAD itself when it is detached
+	refPath, detached, _, err := repo.HeadRef()
 	if err != nil {
-		return errors.NewGitError("reset", "", err)
+		return nil, errors.NewGitError("reset", "", err)
+	}
+	if detached {
+		refPath = headRef
+	}
+
+	reflogTarget := target
+	if reflogTarget == "" {
+		reflogTarget = headRef
 	}
 
-	refPath := fmt.Sprintf("%s%s", headsPrefix, currentBranch)
-	if err := repo.UpdateRef(refPath, targetHash); err != nil {
-		return errors.NewGitError("reset", refPath, err)
+	if err := repo.UpdateRefWithMessage(refPath, targetHash, "reset: moving to "+reflogTarget); err != nil {
+		return nil, errors.NewGitError("reset", refPath, err)
 	}
 
-	if mode != ResetModeSoft {
+	if opts.Mode != ResetModeSoft {
 		if err := resetIndex(repo, targetCommit.Tree()); err != nil {
-			return errors.NewIndexError("", err)
+			return nil, errors.NewIndexError("", err)
 		}
 	}
 
-	if mode == ResetModeHard {
-		if err := resetWorkingTree(repo, targetCommit.Tree()); err != nil {
-			return errors.NewGitError("reset", "", err)
+	if opts.Mode == ResetModeHard {
+		if err := resetWorkingTree(repo, targetCommit.Tree(), result.Deleted); err != nil {
+			return nil, errors.NewGitError("reset", "", err)
 		}
 	}
 
-	return nil
+	return result, nil
+}
+
+// planWorkingTree fills result with the changes a hard reset to treeHash
+// makes to the working tree, and returns the untracked files it would
+// overwrite with different content
+func planWorkingTree(repo *repository.Repository, treeHash string, result *ResetResult) ([]string, error) {
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+	tracked := idx.GetAll()
+
+	targetFiles := make(map[string]bool)
+	var untracked []string
+	err := objects.WalkTree(repo, treeHash, func(path string, entry objects.TreeEntry) error {
+		if entry.IsDir() || entry.IsSubmodule() {
+			return nil
+		}
+		targetFiles[path] = true
+
+		if !fileExists(repo, path) {
+			result.Restored = append(result.Restored, path)
+			return nil
+		}
+		result.Overwritten = append(result.Overwritten, path)
+		if _, ok := tracked[path]; ok {
+			return nil
+		}
+		// an untracked file already holding the target content loses nothing
+		if workHash, ok := checkout.WorkingHash(repo, path); !ok || workHash != entry.Hash {
+			untracked = append(untracked, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path := range tracked {
+		if !targetFiles[path] && fileExists(repo, path) {
+			result.Deleted = append(result.Deleted, path)
+		}
+	}
+	sort.Strings(result.Deleted)
+
+	return untracked, nil
+}
+
+func fileExists(repo *repository.Repository, path string) bool {
+	_, err := os.Lstat(filepath.Join(repo.WorkDir, filepath.FromSlash(path)))
+	return err == nil
 }
 
 func resetPaths(repo *repository.Repository, target string, paths []string) error {
@@ -103,26 +209,16 @@ func resetPaths(repo *repository.Repository, target string, paths []string) erro
 		return errors.NewGitError("reset", target, fmt.Errorf("'%s': %w", target, err))
 	}
 
-	targetObj, err := repo.LoadObject(targetHash)
+	targetCommit, err := repo.LoadCommit(targetHash)
 	if err != nil {
 		return errors.NewObjectError(targetHash, "commit", fmt.Errorf("load target commit: %w", err))
 	}
 
-	targetCommit, ok := targetObj.(*objects.Commit)
-	if !ok {
-		return errors.NewObjectError(targetHash, "commit", errors.ErrInvalidCommit)
-	}
-
-	treeObj, err := repo.LoadObject(targetCommit.Tree())
+	tree, err := repo.LoadTree(targetCommit.Tree())
 	if err != nil {
 		return errors.NewObjectError(targetCommit.Tree(), "tree", fmt.Errorf("load target tree: %w", err))
 	}
 
-	tree, ok := treeObj.(*objects.Tree)
-	if !ok {
-		return errors.NewObjectError(targetCommit.Tree(), "tree", errors.ErrInvalidTree)
-	}
-
 	idx := index.New(repo.GitDir)
 	if err := idx.Load(); err != nil {
 		return errors.NewIndexError("", fmt.Errorf("load index: %w", err))
@@ -145,147 +241,73 @@ func resetIndex(repo *repository.Repository, treeHash string) error {
 	idx := index.New(repo.GitDir)
 	idx.Clear()
 
-	treeObj, err := repo.LoadObject(treeHash)
-	if err != nil {
-		return errors.NewObjectError(treeHash, "tree", fmt.Errorf("load tree: %w", err))
-	}
-
-	tree, ok := treeObj.(*objects.Tree)
-	if !ok {
-		return errors.NewObjectError(treeHash, "tree", errors.ErrInvalidTree)
-	}
-
-	if err := addTreeToIndex(repo, idx, tree, ""); err != nil {
+	if err := addTreeToIndex(repo, idx, treeHash); err != nil {
 		return err
 	}
 
 	return idx.Save()
 }
 
-func resetWorkingTree(repo *repository.Repository, treeHash string) error {
-	treeObj, err := repo.LoadObject(treeHash)
-	if err != nil {
-		return errors.NewObjectError(treeHash, "tree", fmt.Errorf("load tree: %w", err))
-	}
-
-	tree, ok := treeObj.(*objects.Tree)
-	if !ok {
-		return errors.NewObjectError(treeHash, "tree", errors.ErrInvalidTree)
-	}
-
-	// remove all tracked files from working tree
-	idx := index.New(repo.GitDir)
-	if err := idx.Load(); err != nil {
-		return errors.NewIndexError("", fmt.Errorf("load index: %w", err))
-	}
-
-	for path := range idx.GetAll() {
-		fullPath := filepath.Join(repo.WorkDir, path)
+// resetWorkingTree removes the tracked files missing from treeHash and
+// writes out every file in it
+func resetWorkingTree(repo *repository.Repository, treeHash string, deleted []string) error {
+	for _, path := range deleted {
+		fullPath := filepath.Join(repo.WorkDir, filepath.FromSlash(path))
 		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
 			return errors.NewGitError("reset", path, fmt.Errorf("remove file '%s': %w", path, err))
 		}
 	}
 
-	return restoreTreeToWorkingDir(repo, tree, "")
+	return restoreTreeToWorkingDir(repo, treeHash)
 }
 
-func addTreeToIndex(repo *repository.Repository, idx *index.Index, tree *objects.Tree, basePath string) error {
-	for _, entry := range tree.Entries() {
-		entryPath := entry.Name
-		if basePath != "" {
-			entryPath = filepath.Join(basePath, entry.Name)
+func addTreeToIndex(repo *repository.Repository, idx *index.Index, treeHash string) error {
+	return objects.WalkTree(repo, treeHash, func(entryPath string, entry objects.TreeEntry) error {
+		if entry.IsDir() {
+			return nil
 		}
-
-		if entry.Mode == directoryMode { // Directory
-			subtreeObj, err := repo.LoadObject(entry.Hash)
-			if err != nil {
-				return errors.NewObjectError(entry.Hash, "tree", fmt.Errorf("load subtree: %w", err))
-			}
-
-			subtree, ok := subtreeObj.(*objects.Tree)
-			if !ok {
-				return errors.NewObjectError(entry.Hash, "tree", errors.ErrInvalidTree)
-			}
-
-			if err := addTreeToIndex(repo, idx, subtree, entryPath); err != nil {
-				return err
-			}
-		} else { // File
-			blobObj, err := repo.LoadObject(entry.Hash)
-			if err != nil {
-				return errors.NewObjectError(entry.Hash, "blob", fmt.Errorf("load blob: %w", err))
-			}
-
-			blob, ok := blobObj.(*objects.Blob)
-			if !ok {
-				return errors.NewObjectError(entry.Hash, "blob", errors.ErrInvalidBlob)
-			}
-
-			// index with current time as modification time
-			if err := idx.Add(entryPath, entry.Hash, uint32(entry.Mode), blob.Size(), time.Now()); err != nil {
-				return errors.NewIndexError(entryPath, fmt.Errorf("failed to add file to index: %w", err))
-			}
+		if entry.IsSubmodule() {
+			return idx.Add(entryPath, entry.Hash, uint32(entry.Mode), 0, time.Now())
 		}
-	}
 
-	return nil
-}
+		blob, err := repo.LoadBlob(entry.Hash)
+		if err != nil {
+			return errors.NewObjectError(entry.Hash, "blob", fmt.Errorf("load blob: %w", err))
+		}
 
-func restoreTreeToWorkingDir(repo *repository.Repository, tree *objects.Tree, basePath string) error {
-	for _, entry := range tree.Entries() {
-		entryPath := entry.Name
-		if basePath != "" {
-			entryPath = filepath.Join(basePath, entry.Name)
+		// index with current time as modification time
+		if err := idx.Add(entryPath, entry.Hash, uint32(entry.Mode), blob.Size(), time.Now()); err != nil {
+			return errors.NewIndexError(entryPath, fmt.Errorf("failed to add file to index: %w", err))
 		}
+		return nil
+	})
+}
 
-		fullPath := filepath.Join(repo.WorkDir, entryPath)
+func restoreTreeToWorkingDir(repo *repository.Repository, treeHash string) error {
+	return objects.WalkTree(repo, treeHash, func(entryPath string, entry objects.TreeEntry) error {
+		fullPath := filepath.Join(repo.WorkDir, filepath.FromSlash(entryPath))
 
-		if entry.Mode == directoryMode { // Directory
+		if entry.IsDir() || entry.IsSubmodule() {
 			if err := os.MkdirAll(fullPath, defaultDirMode); err != nil {
 				return errors.NewGitError("reset", entryPath, fmt.Errorf("create directory '%s': %w", entryPath, err))
 			}
+			return nil
+		}
 
-			subtreeObj, err := repo.LoadObject(entry.Hash)
-			if err != nil {
-				return errors.NewObjectError(entry.Hash, "tree", fmt.Errorf("load subtree: %w", err))
-			}
-
-			subtree, ok := subtreeObj.(*objects.Tree)
-			if !ok {
-				return errors.NewObjectError(entry.Hash, "tree", errors.ErrInvalidTree)
-			}
-
-			if err := restoreTreeToWorkingDir(repo, subtree, entryPath); err != nil {
-				return err
-			}
-		} else { // File
-			// Load blob
-			blobObj, err := repo.LoadObject(entry.Hash)
-			if err != nil {
-				return errors.NewObjectError(entry.Hash, "blob", fmt.Errorf("load blob: %w", err))
-			}
-
-			blob, ok := blobObj.(*objects.Blob)
-			if !ok {
-				return errors.NewObjectError(entry.Hash, "blob", errors.ErrInvalidBlob)
-			}
-
-			if err := os.MkdirAll(filepath.Dir(fullPath), defaultDirMode); err != nil {
-				return errors.NewGitError("reset", entryPath, fmt.Errorf("create parent directory for '%s': %w", entryPath, err))
-			}
-
-			if err := os.WriteFile(fullPath, blob.Content(), os.FileMode(entry.Mode)); err != nil {
-				return errors.NewGitError("reset", entryPath, fmt.Errorf("write file '%s': %w", entryPath, err))
-			}
+		if err := os.MkdirAll(filepath.Dir(fullPath), defaultDirMode); err != nil {
+			return errors.NewGitError("reset", entryPath, fmt.Errorf("create parent directory for '%s': %w", entryPath, err))
 		}
-	}
 
-	return nil
+		if err := repo.WriteEntryFile(entry.Hash, fullPath, entry.Mode); err != nil {
+			return errors.NewGitError("reset", entryPath, fmt.Errorf("write file '%s': %w", entryPath, err))
+		}
+		return nil
+	})
 }
 
 func resetPathInIndex(idx *index.Index, tree *objects.Tree, path string) error {
 	for _, entry := range tree.Entries() {
-		if entry.Name == path && entry.Mode != directoryMode {
+		if entry.Name == path && !entry.IsDir() {
 			idx.Remove(path)
 
 			if err := idx.Add(path, entry.Hash, uint32(entry.Mode), 0, time.Now()); err != nil {
@@ -299,15 +321,18 @@ func resetPathInIndex(idx *index.Index, tree *objects.Tree, path string) error {
 	return idx.Remove(path)
 }
 
-// resolveTarget resolves a target reference to a commit hash
+// resolveTarget resolves a target revision (ref, hash, HEAD@{n}, HEAD~n, ...) to a commit hash
 func resolveTarget(repo *repository.Repository, target string) (string, error) {
 	if target == "" || target == headRef {
 		return repo.GetHead()
 	}
 
-	// full hash first
-	if len(target) == gitHashLength {
-		return target, nil
+	if hash, err := revparse.Resolve(repo, target); err == nil {
+		// an annotated tag stands for the commit it points at
+		if commit, err := revparse.ResolveCommit(repo, hash); err == nil {
+			return commit, nil
+		}
+		return hash, nil
 	}
 
 	// short hash - expand to full hash by finding matching object
@@ -318,63 +343,14 @@ func resolveTarget(repo *repository.Repository, target string) (string, error) {
 		}
 	}
 
-	// branch reference
-	branchRef := fmt.Sprintf("%s%s", headsPrefix, target)
-	if hash, err := readRef(repo, branchRef); err == nil {
-		return hash, nil
-	}
-
 	return "", errors.NewGitError("reset", target, fmt.Errorf("unable to resolve target '%s'", target))
 }
 
-// expandShortHash finds the full hash for a short hash
+// expandShortHash finds the full hash for a short hash among loose and packed objects
 func expandShortHash(repo *repository.Repository, shortHash string) (string, error) {
-	objectsDirPath := filepath.Join(repo.GitDir, objectsDir)
-
-	// short hash format: first 2 chars as directory, rest as filename prefix
 	if len(shortHash) < minShortHashLength {
 		return "", errors.NewGitError("reset", shortHash, fmt.Errorf("short hash too short"))
 	}
 
-	prefix := shortHash[:hashPrefixLength]
-	suffix := shortHash[hashPrefixLength:]
-
-	dirPath := filepath.Join(objectsDirPath, prefix)
-	entries, err := os.ReadDir(dirPath)
-	if err != nil {
-		return "", errors.NewGitError("reset", dirPath, fmt.Errorf("read objects directory: %w", err))
-	}
-
-	var matches []string
-	for _, entry := range entries {
-		if !entry.IsDir() && len(entry.Name()) >= len(suffix) && entry.Name()[:len(suffix)] == suffix {
-			fullHash := prefix + entry.Name()
-			matches = append(matches, fullHash)
-		}
-	}
-
-	if len(matches) == 0 {
-		return "", errors.NewGitError("reset", shortHash, fmt.Errorf("no objects found matching short hash"))
-	}
-
-	if len(matches) > 1 {
-		return "", errors.NewGitError("reset", shortHash, fmt.Errorf("short hash is ambiguous"))
-	}
-
-	return matches[0], nil
-}
-
-func readRef(repo *repository.Repository, refPath string) (string, error) {
-	fullPath := filepath.Join(repo.GitDir, refPath)
-	content, err := os.ReadFile(fullPath)
-	if err != nil {
-		return "", err
-	}
-
-	hash := string(content)
-	if len(hash) > gitHashLength {
-		hash = hash[:gitHashLength]
-	}
-
-	return hash, nil
+	return repo.ExpandPrefix(shortHash)
 }
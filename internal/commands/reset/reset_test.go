@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -129,7 +130,7 @@ func TestReset_NonExistentRepository(t *testing.T) {
 	tempDir := t.TempDir()
 	repo := repository.New(tempDir)
 
-	err := Reset(repo, "HEAD", ResetModeMixed, nil)
+	_, err := Reset(repo, "HEAD", ResetOptions{Mode: ResetModeMixed})
 	if err != giterrors.ErrNotGitRepository {
 		t.Errorf("Expected ErrNotGitRepository, got %v", err)
 	}
@@ -147,7 +148,7 @@ func TestReset_SoftMode(t *testing.T) {
 		t.Fatalf("Expected HEAD to be %q, got %q", commit2Hash, head)
 	}
 
-	err = Reset(repo, commit1Hash, ResetModeSoft, nil)
+	_, err = Reset(repo, commit1Hash, ResetOptions{Mode: ResetModeSoft})
 	if err != nil {
 		t.Fatalf("Reset failed: %v", err)
 	}
@@ -192,7 +193,7 @@ func TestReset_MixedMode(t *testing.T) {
 		t.Fatalf("Expected HEAD to be %q, got %q", commit2Hash, head)
 	}
 
-	err = Reset(repo, commit1Hash, ResetModeMixed, nil)
+	_, err = Reset(repo, commit1Hash, ResetOptions{Mode: ResetModeMixed})
 	if err != nil {
 		t.Fatalf("Reset failed: %v", err)
 	}
@@ -244,7 +245,7 @@ func TestReset_HardMode(t *testing.T) {
 		t.Fatalf("Failed to modify working file: %v", err)
 	}
 
-	err = Reset(repo, commit1Hash, ResetModeHard, nil)
+	_, err = Reset(repo, commit1Hash, ResetOptions{Mode: ResetModeHard})
 	if err != nil {
 		t.Fatalf("Reset failed: %v", err)
 	}
@@ -277,11 +278,111 @@ func TestReset_HardMode(t *testing.T) {
 	}
 }
 
+func TestReset_HardModeNestedDirectory(t *testing.T) {
+	repo, commit1Hash, _ := setupTestRepo(t)
+
+	store := func(obj objects.Object) string {
+		hash, err := repo.StoreObject(obj)
+		if err != nil {
+			t.Fatalf("Failed to store object: %v", err)
+		}
+		return hash
+	}
+
+	libHash := store(objects.NewBlob([]byte("package lib\n")))
+	innerHash := store(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "lib.go", Hash: libHash},
+	}))
+	outerHash := store(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeTree, Name: "lib", Hash: innerHash},
+	}))
+	testHash := store(objects.NewBlob([]byte("initial content")))
+	treeHash := store(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeTree, Name: "pkg", Hash: outerHash},
+		{Mode: objects.FileModeBlob, Name: "test.txt", Hash: testHash},
+	}))
+	author := &objects.Signature{Name: "Test Author", Email: "test@example.com", When: time.Now()}
+	commitHash := store(objects.NewCommit(treeHash, []string{commit1Hash}, author, author, "Add nested directory"))
+
+	if _, err := Reset(repo, commitHash, ResetOptions{Mode: ResetModeHard}); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+
+	for _, dir := range []string{"pkg", "pkg/lib"} {
+		info, err := os.Stat(filepath.Join(repo.WorkDir, dir))
+		if err != nil {
+			t.Fatalf("Expected directory %s: %v", dir, err)
+		}
+		if !info.IsDir() {
+			t.Errorf("Expected %s to be restored as a directory", dir)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, "pkg", "lib", "lib.go"))
+	if err != nil {
+		t.Fatalf("Failed to read nested file: %v", err)
+	}
+	if string(content) != "package lib\n" {
+		t.Errorf("Unexpected nested file content %q", string(content))
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	entry, ok := idx.Get("pkg/lib/lib.go")
+	if !ok {
+		t.Fatal("Expected pkg/lib/lib.go in the index")
+	}
+	if entry.Hash != libHash {
+		t.Errorf("Expected index hash %s, got %s", libHash, entry.Hash)
+	}
+	if _, ok := idx.Get("pkg"); ok {
+		t.Error("Directories must not be added to the index")
+	}
+}
+
+func TestReset_HardModeAutoCRLF(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, commit1Hash, _ := setupTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte("[core]\n\tautocrlf = true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("one\ntwo\n")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "test.txt", Hash: blobHash},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	author := &objects.Signature{Name: "Test Author", Email: "test@example.com", When: time.Now()}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, []string{commit1Hash}, author, author, "Two lines"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+
+	if _, err := Reset(repo, commitHash, ResetOptions{Mode: ResetModeHard}); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, "test.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read working file: %v", err)
+	}
+	if string(content) != "one\r\ntwo\r\n" {
+		t.Errorf("Expected CRLF line endings, got %q", content)
+	}
+}
+
 func TestReset_DefaultMode(t *testing.T) {
 	repo, commit1Hash, _ := setupTestRepo(t)
 	createSecondCommit(t, repo, commit1Hash)
 
-	err := Reset(repo, commit1Hash, ResetModeDefault, nil)
+	_, err := Reset(repo, commit1Hash, ResetOptions{Mode: ResetModeDefault})
 	if err != nil {
 		t.Fatalf("Reset failed: %v", err)
 	}
@@ -350,7 +451,7 @@ func TestReset_WithPaths(t *testing.T) {
 		t.Fatalf("Failed to save index: %v", err)
 	}
 
-	err = Reset(repo, commit1Hash, ResetModeMixed, []string{"test.txt"})
+	_, err = Reset(repo, commit1Hash, ResetOptions{Mode: ResetModeMixed, Paths: []string{"test.txt"}})
 	if err != nil {
 		t.Fatalf("Path reset failed: %v", err)
 	}
@@ -495,7 +596,7 @@ func TestExpandShortHash_NotFound(t *testing.T) {
 func TestReset_InvalidTarget(t *testing.T) {
 	repo, _, _ := setupTestRepo(t)
 
-	err := Reset(repo, "nonexistent", ResetModeMixed, nil)
+	_, err := Reset(repo, "nonexistent", ResetOptions{Mode: ResetModeMixed})
 	if err == nil {
 		t.Error("Expected error for invalid target")
 	}
@@ -510,7 +611,7 @@ func TestReset_InvalidCommitObject(t *testing.T) {
 		t.Fatalf("Failed to store blob: %v", err)
 	}
 
-	err = Reset(repo, blobHash, ResetModeMixed, nil)
+	_, err = Reset(repo, blobHash, ResetOptions{Mode: ResetModeMixed})
 	if err == nil {
 		t.Error("Expected error when resetting to non-commit object")
 	}
@@ -519,7 +620,7 @@ func TestReset_InvalidCommitObject(t *testing.T) {
 func TestResetPaths_NonExistentPath(t *testing.T) {
 	repo, commitHash, _ := setupTestRepo(t)
 
-	err := Reset(repo, commitHash, ResetModeMixed, []string{"nonexistent.txt"})
+	_, err := Reset(repo, commitHash, ResetOptions{Mode: ResetModeMixed, Paths: []string{"nonexistent.txt"}})
 	if err == nil {
 		t.Error("Expected error when resetting non-existent path")
 	}
@@ -553,7 +654,7 @@ func TestResetPaths_RemoveFromIndex(t *testing.T) {
 		t.Fatalf("Failed to save index: %v", err)
 	}
 
-	err = Reset(repo, commitHash, ResetModeMixed, []string{"extra.txt"})
+	_, err = Reset(repo, commitHash, ResetOptions{Mode: ResetModeMixed, Paths: []string{"extra.txt"}})
 	if err != nil {
 		t.Fatalf("Path reset failed: %v", err)
 	}
@@ -573,3 +674,161 @@ func TestResetPaths_RemoveFromIndex(t *testing.T) {
 		t.Error("test.txt should still be in index")
 	}
 }
+
+func TestReset_UndoViaReflog(t *testing.T) {
+	repo, commit1Hash, _ := setupTestRepo(t)
+	commit2Hash, _ := createSecondCommit(t, repo, commit1Hash)
+
+	if _, err := Reset(repo, "HEAD~1", ResetOptions{Mode: ResetModeHard}); err != nil {
+		t.Fatalf("Reset to HEAD~1 failed: %v", err)
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	if head != commit1Hash {
+		t.Fatalf("Expected HEAD to be %q, got %q", commit1Hash, head)
+	}
+
+	if _, err := Reset(repo, "HEAD@{1}", ResetOptions{Mode: ResetModeHard}); err != nil {
+		t.Fatalf("Reset to HEAD@{1} failed: %v", err)
+	}
+
+	head, err = repo.GetHead()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	if head != commit2Hash {
+		t.Errorf("Expected HEAD@{1} to restore %q, got %q", commit2Hash, head)
+	}
+
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, "test.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read working file: %v", err)
+	}
+	if string(content) != "modified content" {
+		t.Errorf("Expected working file to be restored, got %q", content)
+	}
+}
+
+func TestReset_HardModeDryRunAndUntracked(t *testing.T) {
+	repo, commit1Hash, _ := setupTestRepo(t)
+
+	store := func(obj objects.Object) string {
+		hash, err := repo.StoreObject(obj)
+		if err != nil {
+			t.Fatalf("Failed to store object: %v", err)
+		}
+		return hash
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repo.WorkDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// old.txt is tracked but absent from the target; new.txt is untracked
+	oldHash := store(objects.NewBlob([]byte("old")))
+	write("old.txt", "old")
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if err := idx.Add("old.txt", oldHash, uint32(objects.FileModeBlob), 3, time.Now()); err != nil {
+		t.Fatalf("Failed to add old.txt: %v", err)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+	write("new.txt", "untracked work")
+
+	treeHash := store(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "new.txt", Hash: store(objects.NewBlob([]byte("committed")))},
+		{Mode: objects.FileModeBlob, Name: "restored.txt", Hash: store(objects.NewBlob([]byte("restored")))},
+		{Mode: objects.FileModeBlob, Name: "test.txt", Hash: store(objects.NewBlob([]byte("initial content")))},
+	}))
+	author := &objects.Signature{Name: "Test Author", Email: "test@example.com", When: time.Now()}
+	targetHash := store(objects.NewCommit(treeHash, []string{commit1Hash}, author, author, "Target"))
+
+	result, err := Reset(repo, targetHash, ResetOptions{Mode: ResetModeHard, DryRun: true})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Deleted, []string{"old.txt"}) {
+		t.Errorf("Expected to delete [old.txt], got %v", result.Deleted)
+	}
+	if !reflect.DeepEqual(result.Overwritten, []string{"new.txt", "test.txt"}) {
+		t.Errorf("Expected to overwrite [new.txt test.txt], got %v", result.Overwritten)
+	}
+	if !reflect.DeepEqual(result.Restored, []string{"restored.txt"}) {
+		t.Errorf("Expected to restore [restored.txt], got %v", result.Restored)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "restored.txt")); !os.IsNotExist(err) {
+		t.Error("Dry run must not write files")
+	}
+
+	_, err = Reset(repo, targetHash, ResetOptions{Mode: ResetModeHard})
+	var untrackedErr *UntrackedError
+	if !errors.As(err, &untrackedErr) || !errors.Is(err, giterrors.ErrUntrackedOverwritten) {
+		t.Fatalf("Expected an untracked files error, got %v", err)
+	}
+	if !reflect.DeepEqual(untrackedErr.Paths, []string{"new.txt"}) {
+		t.Errorf("Expected untracked [new.txt], got %v", untrackedErr.Paths)
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	if head != commit1Hash {
+		t.Errorf("A refused reset must not move HEAD, got %q", head)
+	}
+
+	if _, err := Reset(repo, targetHash, ResetOptions{Mode: ResetModeHard, Force: true}); err != nil {
+		t.Fatalf("Forced reset failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "old.txt")); !os.IsNotExist(err) {
+		t.Error("Expected old.txt to be deleted")
+	}
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, "new.txt"))
+	if err != nil || string(content) != "committed" {
+		t.Errorf("Expected new.txt to be overwritten, got %q (%v)", content, err)
+	}
+}
+
+func TestReset_HardModeUntrackedMatchingTarget(t *testing.T) {
+	repo, commit1Hash, _ := setupTestRepo(t)
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("same")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "same.txt", Hash: blobHash},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	author := &objects.Signature{Name: "Test Author", Email: "test@example.com", When: time.Now()}
+	targetHash, err := repo.StoreObject(objects.NewCommit(treeHash, []string{commit1Hash}, author, author, "Target"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+
+	// the untracked copy already holds what the target would write
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "same.txt"), []byte("same"), 0644); err != nil {
+		t.Fatalf("Failed to write same.txt: %v", err)
+	}
+
+	if _, err := Reset(repo, targetHash, ResetOptions{Mode: ResetModeHard}); err != nil {
+		t.Fatalf("Expected reset over an identical untracked file to succeed, got %v", err)
+	}
+	head, err := repo.GetHead()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	if head != targetHash {
+		t.Errorf("Expected HEAD %s, got %s", targetHash, head)
+	}
+}
@@ -0,0 +1,166 @@
+package restore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const defaultDirMode = 0755
+
+// RestoreSource selects where restored content comes from.
+// The zero value restores from the index.
+type RestoreSource struct {
+	Treeish string
+}
+
+var SourceIndex = RestoreSource{}
+
+func SourceTreeish(treeish string) RestoreSource {
+	return RestoreSource{Treeish: treeish}
+}
+
+func (s RestoreSource) IsIndex() bool {
+	return s.Treeish == ""
+}
+
+func RestoreFile(repo *repository.Repository, path string, source RestoreSource) error {
+	return RestoreFiles(repo, []string{path}, source)
+}
+
+// RestoreFiles overwrites each working tree path with its version from source.
+// All paths are validated before any file is written.
+func RestoreFiles(repo *repository.Repository, paths []string, source RestoreSource) error {
+	if !repo.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	if len(paths) == 0 {
+		return errors.NewGitError("restore", "", fmt.Errorf("no paths specified"))
+	}
+
+	type target struct {
+		path string
+		hash string
+		mode objects.FileMode
+	}
+
+	var targets []target
+	if source.IsIndex() {
+		idx := index.New(repo.GitDir)
+		if err := idx.Load(); err != nil {
+			return errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+		}
+
+		for _, path := range paths {
+			gitPath := filepath.ToSlash(filepath.Clean(path))
+			entry, ok := idx.Get(gitPath)
+			if !ok {
+				return errors.NewGitError("restore", path, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", path))
+			}
+			targets = append(targets, target{path: gitPath, hash: entry.Hash, mode: objects.FileMode(entry.Mode)})
+		}
+	} else {
+		tree, err := resolveTree(repo, source.Treeish)
+		if err != nil {
+			return errors.NewGitError("restore", source.Treeish, err)
+		}
+
+		for _, path := range paths {
+			gitPath := filepath.ToSlash(filepath.Clean(path))
+			entry, err := findTreeEntry(repo, tree, gitPath)
+			if err != nil {
+				return errors.NewGitError("restore", path, err)
+			}
+			targets = append(targets, target{path: gitPath, hash: entry.Hash, mode: entry.Mode})
+		}
+	}
+
+	for _, t := range targets {
+		if err := writeBlob(repo, t.path, t.hash, t.mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeBlob(repo *repository.Repository, path, hash string, mode objects.FileMode) error {
+	fullPath := filepath.Join(repo.WorkDir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(fullPath), defaultDirMode); err != nil {
+		return errors.NewGitError("restore", path, fmt.Errorf("create parent directory: %w", err))
+	}
+
+	if err := repo.WriteEntryFile(hash, fullPath, mode); err != nil {
+		return errors.NewObjectError(hash, "blob", fmt.Errorf("write file: %w", err))
+	}
+
+	return nil
+}
+
+// resolveTree peels any revision, such as HEAD~1 or an annotated tag, to
+// the tree it names
+func resolveTree(repo *repository.Repository, treeish string) (*objects.Tree, error) {
+	hash, err := revparse.Resolve(repo, treeish+"^{tree}")
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := repo.LoadTree(hash)
+	if err != nil {
+		return nil, errors.NewObjectError(hash, "tree", err)
+	}
+	return tree, nil
+}
+
+// findTreeEntry looks up a slash-separated path, descending into subtrees
+func findTreeEntry(repo *repository.Repository, tree *objects.Tree, path string) (*objects.TreeEntry, error) {
+	parts := strings.Split(path, "/")
+	current := tree
+
+	for i, part := range parts {
+		var found *objects.TreeEntry
+		for _, entry := range current.Entries() {
+			if entry.Name == part {
+				e := entry
+				found = &e
+				break
+			}
+		}
+
+		if found == nil {
+			return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", path)
+		}
+
+		if i == len(parts)-1 {
+			if found.IsDir() {
+				return nil, fmt.Errorf("'%s' is a directory", path)
+			}
+			return found, nil
+		}
+
+		if !found.IsDir() {
+			return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", path)
+		}
+
+		obj, err := repo.LoadObject(found.Hash)
+		if err != nil {
+			return nil, errors.NewObjectError(found.Hash, "tree", err)
+		}
+
+		subtree, ok := obj.(*objects.Tree)
+		if !ok {
+			return nil, errors.NewObjectError(found.Hash, "tree", errors.ErrInvalidTree)
+		}
+		current = subtree
+	}
+
+	return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", path)
+}
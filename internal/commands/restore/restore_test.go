@@ -0,0 +1,289 @@
+package restore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+func setupTestRepo(t *testing.T) *repository.Repository {
+	tempDir := t.TempDir()
+	repo := repository.New(tempDir)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	files := map[string]string{
+		"test.txt":     "committed content",
+		"dir/file.txt": "nested content",
+	}
+
+	idx := index.New(repo.GitDir)
+	blobs := make(map[string]string)
+	for path, content := range files {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		blobs[path] = blobHash
+
+		if err := idx.Add(path, blobHash, uint32(objects.FileModeBlob), int64(len(content)), time.Now()); err != nil {
+			t.Fatalf("Failed to add to index: %v", err)
+		}
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	subtree := objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "file.txt", Hash: blobs["dir/file.txt"]},
+	})
+	subtreeHash, err := repo.StoreObject(subtree)
+	if err != nil {
+		t.Fatalf("Failed to store subtree: %v", err)
+	}
+
+	tree := objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeTree, Name: "dir", Hash: subtreeHash},
+		{Mode: objects.FileModeBlob, Name: "test.txt", Hash: blobs["test.txt"]},
+	})
+	treeHash, err := repo.StoreObject(tree)
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+
+	author := &objects.Signature{
+		Name:  "Test Author",
+		Email: "test@example.com",
+		When:  time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, nil, author, author, "Initial commit"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+
+	if err := repo.UpdateRef("refs/heads/main", commitHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	return repo
+}
+
+func readFile(t *testing.T, repo *repository.Repository, path string) string {
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, path))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestRestoreFile_ModifiedFromIndex(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "test.txt"), []byte("local edits"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	if err := RestoreFile(repo, "test.txt", SourceIndex); err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+
+	if got := readFile(t, repo, "test.txt"); got != "committed content" {
+		t.Errorf("Expected 'committed content', got %q", got)
+	}
+}
+
+func TestRestoreFile_DeletedFromIndex(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := os.RemoveAll(filepath.Join(repo.WorkDir, "dir")); err != nil {
+		t.Fatalf("Failed to delete directory: %v", err)
+	}
+
+	if err := RestoreFile(repo, "dir/file.txt", SourceIndex); err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+
+	if got := readFile(t, repo, "dir/file.txt"); got != "nested content" {
+		t.Errorf("Expected 'nested content', got %q", got)
+	}
+}
+
+func TestRestoreFiles_FromHead(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "test.txt"), []byte("local edits"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(repo.WorkDir, "dir", "file.txt")); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	if err := RestoreFiles(repo, []string{"test.txt", "dir/file.txt"}, SourceTreeish("HEAD")); err != nil {
+		t.Fatalf("RestoreFiles failed: %v", err)
+	}
+
+	if got := readFile(t, repo, "test.txt"); got != "committed content" {
+		t.Errorf("Expected 'committed content', got %q", got)
+	}
+	if got := readFile(t, repo, "dir/file.txt"); got != "nested content" {
+		t.Errorf("Expected 'nested content', got %q", got)
+	}
+}
+
+func TestRestoreFile_FromParentCommit(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	parent, err := repo.ReadRef("refs/heads/main")
+	if err != nil {
+		t.Fatalf("Failed to read main: %v", err)
+	}
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("second content")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "test.txt", Hash: blobHash},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	author := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, []string{parent}, author, author, "second"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/main", commitHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	if err := RestoreFile(repo, "test.txt", SourceTreeish("HEAD~1")); err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+	if got := readFile(t, repo, "test.txt"); got != "committed content" {
+		t.Errorf("Expected 'committed content', got %q", got)
+	}
+
+	if err := RestoreFile(repo, "test.txt", SourceTreeish(commitHash[:7])); err != nil {
+		t.Fatalf("RestoreFile from a short hash failed: %v", err)
+	}
+	if got := readFile(t, repo, "test.txt"); got != "second content" {
+		t.Errorf("Expected 'second content', got %q", got)
+	}
+}
+
+func TestRestoreFile_FromBranchExecutable(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("#!/bin/sh\n")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	tree := objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeExecutable, Name: "run.sh", Hash: blobHash},
+	})
+	treeHash, err := repo.StoreObject(tree)
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	author := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, nil, author, author, "script"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/tools", commitHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+
+	if err := RestoreFile(repo, "run.sh", SourceTreeish("tools")); err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(repo.WorkDir, "run.sh"))
+	if err != nil {
+		t.Fatalf("Failed to stat restored file: %v", err)
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("Expected executable mode, got %v", info.Mode().Perm())
+	}
+}
+
+func TestRestoreFile_Symlink(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("test.txt")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if err := idx.Add("link", blobHash, uint32(objects.FileModeSymlink), 8, time.Now()); err != nil {
+		t.Fatalf("Failed to add to index: %v", err)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	// a regular file in the way is replaced by the link
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "link"), []byte("not a link"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := RestoreFile(repo, "link", SourceIndex); err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+
+	target, err := os.Readlink(filepath.Join(repo.WorkDir, "link"))
+	if err != nil {
+		t.Fatalf("Expected a symlink: %v", err)
+	}
+	if target != "test.txt" {
+		t.Errorf("Expected the link to point at test.txt, got %q", target)
+	}
+	if got := readFile(t, repo, "test.txt"); got != "committed content" {
+		t.Errorf("Expected the link target untouched, got %q", got)
+	}
+}
+
+func TestRestoreFile_Untracked(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := RestoreFile(repo, "missing.txt", SourceIndex); err == nil {
+		t.Error("Expected error for untracked path from index")
+	}
+
+	if err := RestoreFile(repo, "missing.txt", SourceTreeish("HEAD")); err == nil {
+		t.Error("Expected error for untracked path from HEAD")
+	}
+}
+
+func TestRestoreFiles_NoPartialWrite(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, "test.txt"), []byte("local edits"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	if err := RestoreFiles(repo, []string{"test.txt", "missing.txt"}, SourceIndex); err == nil {
+		t.Fatal("Expected error for untracked path")
+	}
+
+	if got := readFile(t, repo, "test.txt"); got != "local edits" {
+		t.Errorf("Expected file to be untouched, got %q", got)
+	}
+}
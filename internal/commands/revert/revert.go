@@ -0,0 +1,189 @@
+package revert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/core/ident"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/internal/core/treemerge"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const headsPrefix = "refs/heads/"
+
+type RevertOptions struct {
+	// Mainline is the 1-based parent a merge commit is reverted against
+	Mainline int
+}
+
+type RevertResult struct {
+	Commit       string
+	Reverted     string
+	UpdatedFiles []string
+	RemovedFiles []string
+	// ConflictFiles lists paths the inverse change could not be applied to;
+	// nothing is written when it is set
+	ConflictFiles []string
+}
+
+// Revert creates a commit on HEAD that undoes the changes introduced by rev,
+// relative to its first parent or to the Mainline parent of a merge
+func Revert(repo *repository.Repository, rev string, opts RevertOptions) (*RevertResult, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	commitHash, err := revparse.ResolveCommit(repo, rev)
+	if err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+
+	obj, err := repo.LoadObject(commitHash)
+	if err != nil {
+		return nil, errors.NewObjectError(commitHash, "commit", err)
+	}
+	target, ok := obj.(*objects.Commit)
+	if !ok {
+		return nil, errors.NewObjectError(commitHash, "commit", errors.ErrInvalidCommit)
+	}
+
+	parent, err := selectParent(target, commitHash, opts.Mainline)
+	if err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+
+	head, err := repo.GetHead()
+	if err != nil || head == "" {
+		return nil, errors.NewGitError("revert", rev, fmt.Errorf("HEAD does not point at a commit"))
+	}
+
+	targetFiles, err := checkout.TreeFiles(repo, target.Tree())
+	if err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+
+	parentFiles := make(map[string]objects.TreeEntry)
+	if parent != "" {
+		parentFiles, err = checkout.CommitFiles(repo, parent)
+		if err != nil {
+			return nil, errors.NewGitError("revert", rev, err)
+		}
+	}
+
+	headFiles, err := checkout.CommitFiles(repo, head)
+	if err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+
+	// the inverse change: base is the commit, theirs its parent
+	merged, err := treemerge.Merge(repo, targetFiles, headFiles, parentFiles)
+	if err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+
+	result := &RevertResult{Reverted: commitHash}
+	if len(merged.Conflicts) > 0 {
+		result.ConflictFiles = merged.Conflicts
+		return result, errors.NewGitError("revert", rev, errors.ErrMergeConflict)
+	}
+
+	changes := checkout.DiffFiles(headFiles, merged.Files)
+	if len(changes) == 0 {
+		return nil, errors.NewGitError("revert", rev, errors.ErrNothingToCommit)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	if staged := checkout.DiffFiles(headFiles, checkout.IndexFiles(idx)); len(staged) > 0 {
+		return nil, errors.NewGitError("revert", rev, fmt.Errorf("your index contains uncommitted changes"))
+	}
+
+	if conflicts := checkout.FindConflicts(repo, idx, headFiles, merged.Files, changes); len(conflicts) > 0 {
+		return nil, &checkout.ConflictError{Paths: conflicts}
+	}
+
+	result.UpdatedFiles, result.RemovedFiles, err = checkout.ApplyFiles(repo, idx, merged.Files, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	treeHash, err := idx.WriteTreeTo(repo)
+	if err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+
+	message := revertMessage(target, commitHash, parent, opts.Mainline > 0)
+	id, err := ident.Resolve(repo.GitDir)
+	if err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+	now := time.Now()
+	author, committer := id.Author.Signature(now), id.Committer.Signature(now)
+	revertHash, err := repo.StoreObject(objects.NewCommit(treeHash, []string{head}, author, committer, message))
+	if err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+
+	reflogMsg := "revert: " + strings.SplitN(message, "\n", 2)[0]
+	if branch, err := repo.GetCurrentBranch(); err == nil {
+		err = repo.UpdateRefWithMessage(headsPrefix+branch, revertHash, reflogMsg)
+		if err != nil {
+			return nil, errors.NewGitError("revert", rev, err)
+		}
+	} else if err := repo.SetHead(revertHash, reflogMsg); err != nil {
+		return nil, errors.NewGitError("revert", rev, err)
+	}
+
+	idx.MarkAsCommitted()
+	if err := idx.Save(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("save index: %w", err))
+	}
+
+	result.Commit = revertHash
+	return result, nil
+}
+
+// selectParent returns the parent the commit's changes are computed against,
+// or "" for a root commit
+func selectParent(commit *objects.Commit, commitHash string, mainline int) (string, error) {
+	parents := commit.Parents()
+
+	if len(parents) > 1 {
+		if mainline == 0 {
+			return "", fmt.Errorf("commit %s is a merge but no -m option was given", commitHash)
+		}
+		if mainline < 1 || mainline > len(parents) {
+			return "", fmt.Errorf("commit %s does not have parent %d", commitHash, mainline)
+		}
+		return parents[mainline-1], nil
+	}
+
+	if mainline != 0 {
+		return "", fmt.Errorf("mainline was specified but commit %s is not a merge", commitHash)
+	}
+
+	if len(parents) == 0 {
+		return "", nil
+	}
+	return parents[0], nil
+}
+
+func revertMessage(commit *objects.Commit, commitHash, parent string, isMerge bool) string {
+	subject, _, _ := strings.Cut(commit.Message(), "\n")
+
+	body := fmt.Sprintf("This reverts commit %s.", commitHash)
+	if isMerge {
+		body = fmt.Sprintf("This reverts commit %s, reversing\nchanges made to %s.", commitHash, parent)
+	}
+
+	return fmt.Sprintf("Revert \"%s\"\n\n%s", subject, body)
+}
@@ -0,0 +1,157 @@
+package revert
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/testutil"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+func setupTestRepo(t *testing.T) *repository.Repository {
+	t.Helper()
+
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	return repo
+}
+
+func readFile(t *testing.T, repo *repository.Repository, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, path))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestRevert(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	testutil.CommitFiles(t, repo, "initial", map[string]string{"a.txt": "one\ntwo\nthree\nfour\n"})
+	target := testutil.CommitFiles(t, repo, "change first line", map[string]string{"a.txt": "ONE\ntwo\nthree\nfour\n", "b.txt": "added\n"})
+	testutil.CommitFiles(t, repo, "change last line", map[string]string{"a.txt": "ONE\ntwo\nthree\nFOUR\n"})
+
+	result, err := Revert(repo, target, RevertOptions{})
+	if err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+
+	if got := readFile(t, repo, "a.txt"); got != "one\ntwo\nthree\nFOUR\n" {
+		t.Errorf("Expected only the reverted change undone, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected b.txt added by the commit to be removed, got %v", err)
+	}
+
+	head, err := repo.GetHead()
+	if err != nil || head != result.Commit {
+		t.Fatalf("Expected HEAD at revert commit %s, got %s (%v)", result.Commit, head, err)
+	}
+
+	obj, err := repo.LoadObject(result.Commit)
+	if err != nil {
+		t.Fatalf("Failed to load revert commit: %v", err)
+	}
+	revertCommit := obj.(*objects.Commit)
+
+	expected := "Revert \"change first line\"\n\nThis reverts commit " + target + "."
+	if revertCommit.Message() != expected {
+		t.Errorf("Expected message %q, got %q", expected, revertCommit.Message())
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if _, ok := idx.Get("b.txt"); ok {
+		t.Error("Expected b.txt removed from the index")
+	}
+}
+
+func TestRevert_Conflict(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	testutil.CommitFiles(t, repo, "initial", map[string]string{"a.txt": "one\n"})
+	target := testutil.CommitFiles(t, repo, "two", map[string]string{"a.txt": "two\n"})
+	before := testutil.CommitFiles(t, repo, "three", map[string]string{"a.txt": "three\n"})
+
+	result, err := Revert(repo, target, RevertOptions{})
+	if !stderrors.Is(err, errors.ErrMergeConflict) {
+		t.Fatalf("Expected ErrMergeConflict, got %v", err)
+	}
+	if len(result.ConflictFiles) != 1 || result.ConflictFiles[0] != "a.txt" {
+		t.Errorf("Expected conflict on a.txt, got %v", result.ConflictFiles)
+	}
+
+	if head, _ := repo.GetHead(); head != before {
+		t.Errorf("Expected HEAD unchanged at %s, got %s", before, head)
+	}
+	if got := readFile(t, repo, "a.txt"); got != "three\n" {
+		t.Errorf("Expected working tree untouched, got %q", got)
+	}
+}
+
+func TestRevert_MergeRequiresMainline(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	base := testutil.CommitFiles(t, repo, "initial", map[string]string{"a.txt": "a\n"})
+	side := testutil.CommitFiles(t, repo, "side", map[string]string{"b.txt": "b\n"})
+
+	obj, err := repo.LoadObject(side)
+	if err != nil {
+		t.Fatalf("Failed to load commit: %v", err)
+	}
+	sig := &objects.Signature{Name: "Test Author", Email: "test@example.com", When: time.Now()}
+	merge, err := repo.StoreObject(objects.NewCommit(obj.(*objects.Commit).Tree(), []string{base, side}, sig, sig, "Merge side"))
+	if err != nil {
+		t.Fatalf("Failed to store merge: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/main", merge); err != nil {
+		t.Fatalf("Failed to update branch: %v", err)
+	}
+
+	if _, err := Revert(repo, merge, RevertOptions{}); err == nil || !strings.Contains(err.Error(), "no -m option") {
+		t.Fatalf("Expected error asking for -m, got %v", err)
+	}
+
+	if _, err := Revert(repo, merge, RevertOptions{Mainline: 3}); err == nil {
+		t.Fatal("Expected error for a parent number out of range")
+	}
+
+	result, err := Revert(repo, merge, RevertOptions{Mainline: 1})
+	if err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected b.txt from the merged side to be removed, got %v", err)
+	}
+
+	obj, err = repo.LoadObject(result.Commit)
+	if err != nil {
+		t.Fatalf("Failed to load revert commit: %v", err)
+	}
+	if msg := obj.(*objects.Commit).Message(); !strings.Contains(msg, "reversing\nchanges made to "+base) {
+		t.Errorf("Unexpected merge revert message %q", msg)
+	}
+}
+
+func TestRevert_MainlineOnNonMerge(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	testutil.CommitFiles(t, repo, "initial", map[string]string{"a.txt": "a\n"})
+	target := testutil.CommitFiles(t, repo, "change", map[string]string{"a.txt": "b\n"})
+
+	if _, err := Revert(repo, target, RevertOptions{Mainline: 1}); err == nil {
+		t.Fatal("Expected error when -m is given for a non-merge commit")
+	}
+}
@@ -0,0 +1,100 @@
+package revlist
+
+import (
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/log"
+	"github.com/unkn0wn-root/git-go/internal/core/mergebase"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/revparse"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+type RevListOptions struct {
+	// Include are the revisions to walk from, or A..B and A...B ranges;
+	// HEAD when neither Include nor Exclude is given
+	Include []string
+	// Exclude are revisions whose ancestors are left out, as in ^A
+	Exclude []string
+	// MaxCount stops the listing after this many commits; zero is no limit
+	MaxCount int
+	// Reverse lists the selected commits oldest first
+	Reverse bool
+	// FirstParent follows only the first parent of merge commits
+	FirstParent bool
+}
+
+// RevList returns the hashes of the commits reachable from the included
+// revisions but not from the excluded ones, newest first unless reversed
+func RevList(repo *repository.Repository, opts RevListOptions) ([]string, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	var starts []string
+	excluded := make(map[string]bool)
+	for _, spec := range opts.Include {
+		if !strings.Contains(spec, "..") {
+			hash, err := revparse.ResolveCommit(repo, spec)
+			if err != nil {
+				return nil, errors.NewGitError("rev-list", spec, err)
+			}
+			starts = append(starts, hash)
+			continue
+		}
+
+		rangeStarts, rangeExcluded, err := log.ResolveRange(repo, spec)
+		if err != nil {
+			return nil, errors.NewGitError("rev-list", spec, err)
+		}
+		starts = append(starts, rangeStarts...)
+		for hash := range rangeExcluded {
+			excluded[hash] = true
+		}
+	}
+
+	if len(opts.Include) == 0 && len(opts.Exclude) == 0 {
+		head, err := repo.GetHead()
+		if err != nil || head == "" {
+			return []string{}, nil // No commits yet
+		}
+		starts = []string{head}
+	}
+
+	if len(opts.Exclude) > 0 {
+		var excludes []string
+		for _, spec := range opts.Exclude {
+			hash, err := revparse.ResolveCommit(repo, spec)
+			if err != nil {
+				return nil, errors.NewGitError("rev-list", spec, err)
+			}
+			excludes = append(excludes, hash)
+		}
+		ancestors, err := mergebase.Ancestors(repo, excludes...)
+		if err != nil {
+			return nil, errors.NewGitError("rev-list", "", err)
+		}
+		for hash := range ancestors {
+			excluded[hash] = true
+		}
+	}
+
+	entries, err := log.Walk(repo, starts, excluded, log.LogOptions{
+		MaxCount:    opts.MaxCount,
+		FirstParent: opts.FirstParent,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(entries))
+	for i, entry := range entries {
+		hashes[i] = entry.Hash
+	}
+	if opts.Reverse {
+		for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+			hashes[i], hashes[j] = hashes[j], hashes[i]
+		}
+	}
+	return hashes, nil
+}
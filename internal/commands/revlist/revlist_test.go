@@ -0,0 +1,145 @@
+package revlist
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+// branchedRepo builds root-a1-a2-merge on main and root-b1-b2-b3 on feature,
+// with merge joining a2 and b3
+type branchedRepo struct {
+	repo                *repository.Repository
+	root, a1, a2, merge string
+	b1, b2, b3          string
+}
+
+func setupBranchedRepo(t *testing.T) *branchedRepo {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	treeHash, err := repo.StoreObject(objects.NewTree(nil))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	when := time.Unix(1700000000, 0)
+	commit := func(message string, parents ...string) string {
+		when = when.Add(time.Minute)
+		sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: when}
+		hash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, sig, sig, message))
+		if err != nil {
+			t.Fatalf("Failed to store commit: %v", err)
+		}
+		return hash
+	}
+
+	r := &branchedRepo{repo: repo}
+	r.root = commit("root")
+	r.a1 = commit("a1", r.root)
+	r.a2 = commit("a2", r.a1)
+	r.b1 = commit("b1", r.root)
+	r.b2 = commit("b2", r.b1)
+	r.b3 = commit("b3", r.b2)
+	r.merge = commit("merge", r.a2, r.b3)
+
+	if err := repo.UpdateRef("refs/heads/main", r.merge); err != nil {
+		t.Fatalf("Failed to update main: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/feature", r.b3); err != nil {
+		t.Fatalf("Failed to update feature: %v", err)
+	}
+	if err := repo.UpdateRef("refs/heads/old-main", r.a2); err != nil {
+		t.Fatalf("Failed to update old-main: %v", err)
+	}
+	return r
+}
+
+func TestRevListCounts(t *testing.T) {
+	r := setupBranchedRepo(t)
+
+	tests := []struct {
+		name string
+		opts RevListOptions
+		want int
+	}{
+		{"all from merge", RevListOptions{Include: []string{"main"}}, 7},
+		{"first parent", RevListOptions{Include: []string{"main"}, FirstParent: true}, 4},
+		{"excluding feature", RevListOptions{Include: []string{"main"}, Exclude: []string{"feature"}}, 3},
+		{"ahead", RevListOptions{Include: []string{"feature..old-main"}}, 2},
+		{"behind", RevListOptions{Include: []string{"old-main..feature"}}, 3},
+		{"symmetric", RevListOptions{Include: []string{"old-main...feature"}}, 5},
+		{"merged", RevListOptions{Include: []string{"main..feature"}}, 0},
+		{"max count", RevListOptions{Include: []string{"main"}, MaxCount: 3}, 3},
+		{"two tips", RevListOptions{Include: []string{"old-main", "feature"}, Exclude: []string{r.root}}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashes, err := RevList(r.repo, tt.opts)
+			if err != nil {
+				t.Fatalf("RevList failed: %v", err)
+			}
+			if len(hashes) != tt.want {
+				t.Errorf("Expected %d commits, got %d", tt.want, len(hashes))
+			}
+		})
+	}
+}
+
+func TestRevListFirstParentOrder(t *testing.T) {
+	r := setupBranchedRepo(t)
+
+	hashes, err := RevList(r.repo, RevListOptions{Include: []string{"main"}, FirstParent: true})
+	if err != nil {
+		t.Fatalf("RevList failed: %v", err)
+	}
+	want := []string{r.merge, r.a2, r.a1, r.root}
+	if !reflect.DeepEqual(hashes, want) {
+		t.Errorf("Expected %v, got %v", want, hashes)
+	}
+
+	hashes, err = RevList(r.repo, RevListOptions{Include: []string{"main"}, FirstParent: true, MaxCount: 2, Reverse: true})
+	if err != nil {
+		t.Fatalf("RevList failed: %v", err)
+	}
+	want = []string{r.a2, r.merge}
+	if !reflect.DeepEqual(hashes, want) {
+		t.Errorf("Expected %v, got %v", want, hashes)
+	}
+}
+
+func TestRevListExcludesAncestors(t *testing.T) {
+	r := setupBranchedRepo(t)
+
+	hashes, err := RevList(r.repo, RevListOptions{Include: []string{"main"}, Exclude: []string{"old-main"}})
+	if err != nil {
+		t.Fatalf("RevList failed: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, hash := range hashes {
+		got[hash] = true
+	}
+	for _, hash := range []string{r.merge, r.b1, r.b2, r.b3} {
+		if !got[hash] {
+			t.Errorf("Expected %s to be listed", hash)
+		}
+	}
+	for _, hash := range []string{r.root, r.a1, r.a2} {
+		if got[hash] {
+			t.Errorf("Expected %s to be left out", hash)
+		}
+	}
+}
+
+func TestRevListUnknownRevision(t *testing.T) {
+	r := setupBranchedRepo(t)
+
+	if _, err := RevList(r.repo, RevListOptions{Include: []string{"nope"}}); err == nil {
+		t.Error("Expected an error for an unknown revision")
+	}
+}
@@ -0,0 +1,107 @@
+package rm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// RemoveOptions controls what Remove deletes
+type RemoveOptions struct {
+	// Cached only untracks the files, leaving them in the working tree
+	Cached bool
+	// Force removes files even when that loses staged or local changes
+	Force bool
+}
+
+// Remove stops tracking each path and, unless opts.Cached is set, deletes
+// it from the working tree. Every path is checked before anything changes.
+func Remove(repo *repository.Repository, paths []string, opts RemoveOptions) error {
+	if !repo.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	if len(paths) == 0 {
+		return errors.NewGitError("rm", "", fmt.Errorf("no paths specified"))
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	headFiles := map[string]objects.TreeEntry{}
+	if headHash, err := repo.GetHead(); err == nil && headHash != "" {
+		if headFiles, err = checkout.CommitFiles(repo, headHash); err != nil {
+			return errors.NewGitError("rm", "", err)
+		}
+	}
+
+	gitPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		gitPath := filepath.ToSlash(filepath.Clean(path))
+		entry, ok := idx.Get(gitPath)
+		if !ok {
+			return errors.NewGitError("rm", path, fmt.Errorf("pathspec '%s' did not match any files", path))
+		}
+
+		if !opts.Force {
+			if err := checkRemovable(repo, gitPath, entry, headFiles, opts.Cached); err != nil {
+				return errors.NewGitError("rm", path, err)
+			}
+		}
+		gitPaths = append(gitPaths, gitPath)
+	}
+
+	for _, gitPath := range gitPaths {
+		if err := idx.Remove(gitPath); err != nil {
+			return errors.NewIndexError(gitPath, err)
+		}
+	}
+
+	if err := idx.Save(); err != nil {
+		return errors.NewIndexError("", fmt.Errorf("save index: %w", err))
+	}
+
+	if opts.Cached {
+		return nil
+	}
+
+	for _, gitPath := range gitPaths {
+		if err := os.Remove(filepath.Join(repo.WorkDir, filepath.FromSlash(gitPath))); err != nil && !os.IsNotExist(err) {
+			return errors.NewGitError("rm", gitPath, err)
+		}
+	}
+
+	return nil
+}
+
+// checkRemovable refuses to drop content that exists nowhere else: staged
+// content that differs from HEAD, or local changes that were never staged.
+// Untracking alone is allowed as long as the file still holds the staged
+// content or HEAD still has it.
+func checkRemovable(repo *repository.Repository, path string, entry *index.IndexEntry, headFiles map[string]objects.TreeEntry, cached bool) error {
+	head, inHead := headFiles[path]
+	staged := !inHead || head.Hash != entry.Hash
+
+	workHash, inWorking := checkout.WorkingHash(repo, path)
+	modified := inWorking && workHash != entry.Hash
+
+	switch {
+	case staged && modified:
+		return fmt.Errorf("file has staged content different from both the file and HEAD (use --force to remove it)")
+	case cached:
+		return nil
+	case staged:
+		return fmt.Errorf("file has changes staged in the index (use --cached to keep the file, or --force to remove it)")
+	case modified:
+		return fmt.Errorf("file has local modifications (use --cached to keep the file, or --force to remove it)")
+	}
+	return nil
+}
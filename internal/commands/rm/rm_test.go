@@ -0,0 +1,135 @@
+package rm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/add"
+	"github.com/unkn0wn-root/git-go/internal/commands/commit"
+	"github.com/unkn0wn-root/git-go/internal/commands/status"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+// setupTestRepo commits each file with its name as content
+func setupTestRepo(t *testing.T, files ...string) *repository.Repository {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	for _, name := range files {
+		writeFile(t, repo, name, name)
+	}
+	if err := add.AddFiles(repo, files); err != nil {
+		t.Fatalf("Failed to add files: %v", err)
+	}
+	_, err := commit.CreateCommit(repo, commit.CommitOptions{
+		Message:     "Initial commit",
+		AuthorName:  "Test Author",
+		AuthorEmail: "test@example.com",
+		SkipHooks:   true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	return repo
+}
+
+func writeFile(t *testing.T, repo *repository.Repository, name, content string) {
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func isTracked(t *testing.T, repo *repository.Repository, path string) bool {
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	_, ok := idx.Get(path)
+	return ok
+}
+
+func TestRemove(t *testing.T) {
+	repo := setupTestRepo(t, "a.txt", "b.txt")
+
+	if err := Remove(repo, []string{"a.txt"}, RemoveOptions{}); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	if isTracked(t, repo, "a.txt") {
+		t.Error("a.txt should have been removed from the index")
+	}
+	if !isTracked(t, repo, "b.txt") {
+		t.Error("b.txt should still be tracked")
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "a.txt")); !os.IsNotExist(err) {
+		t.Error("a.txt should have been deleted from the working tree")
+	}
+}
+
+func TestRemove_Cached(t *testing.T) {
+	repo := setupTestRepo(t, "a.txt")
+
+	if err := Remove(repo, []string{"a.txt"}, RemoveOptions{Cached: true}); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	if isTracked(t, repo, "a.txt") {
+		t.Error("a.txt should have been removed from the index")
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "a.txt")); err != nil {
+		t.Errorf("a.txt should be left in the working tree: %v", err)
+	}
+
+	result, err := status.GetStatus(repo)
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	var untracked bool
+	for _, entry := range result.Entries {
+		if entry.Path == "a.txt" && entry.WorkStatus == status.StatusUntracked {
+			untracked = true
+		}
+	}
+	if !untracked {
+		t.Errorf("Expected a.txt to show as untracked, got %+v", result.Entries)
+	}
+}
+
+func TestRemove_StagedChanges(t *testing.T) {
+	repo := setupTestRepo(t, "a.txt")
+	writeFile(t, repo, "a.txt", "staged")
+	if err := add.AddFiles(repo, []string{"a.txt"}); err != nil {
+		t.Fatalf("Failed to stage change: %v", err)
+	}
+
+	if err := Remove(repo, []string{"a.txt"}, RemoveOptions{}); err == nil {
+		t.Fatal("Expected an error removing a file with staged changes")
+	}
+	if !isTracked(t, repo, "a.txt") {
+		t.Error("A refused remove must leave the index unchanged")
+	}
+
+	// the staged content is still in the file, so only untracking is safe
+	if err := Remove(repo, []string{"a.txt"}, RemoveOptions{Cached: true}); err != nil {
+		t.Fatalf("Cached remove failed: %v", err)
+	}
+
+	writeFile(t, repo, "b.txt", "new")
+	if err := add.AddFiles(repo, []string{"b.txt"}); err != nil {
+		t.Fatalf("Failed to stage b.txt: %v", err)
+	}
+	writeFile(t, repo, "b.txt", "newer")
+	if err := Remove(repo, []string{"b.txt"}, RemoveOptions{Cached: true}); err == nil {
+		t.Fatal("Expected an error when the staged content differs from both the file and HEAD")
+	}
+	if err := Remove(repo, []string{"b.txt"}, RemoveOptions{Force: true}); err != nil {
+		t.Fatalf("Forced remove failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "b.txt")); !os.IsNotExist(err) {
+		t.Error("b.txt should have been deleted")
+	}
+}
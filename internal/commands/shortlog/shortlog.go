@@ -0,0 +1,44 @@
+package shortlog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/log"
+	"github.com/unkn0wn-root/git-go/internal/core/mailmap"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+type ShortlogOptions struct {
+	// Revision and Range select the commits as they do for log
+	Revision string
+	Range    string
+	// Email groups by "Name <email>" rather than by name alone
+	Email bool
+	// Mailmap merges the identities an author committed under
+	Mailmap *mailmap.Mailmap
+}
+
+// Shortlog groups the subjects of the selected commits by author, each
+// author's subjects oldest first
+func Shortlog(repo *repository.Repository, options ShortlogOptions) (map[string][]string, error) {
+	entries, err := log.GetLog(repo, log.LogOptions{Revision: options.Revision, Range: options.Range})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]string)
+	// entries are newest first; walk backwards so subjects read oldest first
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		name, email := options.Mailmap.Resolve(entry.Author.Name, entry.Author.Email)
+		author := name
+		if options.Email {
+			author = fmt.Sprintf("%s <%s>", name, email)
+		}
+
+		subject, _, _ := strings.Cut(entry.Message, "\n")
+		groups[author] = append(groups[author], subject)
+	}
+	return groups, nil
+}
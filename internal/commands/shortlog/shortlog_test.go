@@ -0,0 +1,108 @@
+package shortlog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/mailmap"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/display"
+)
+
+type testCommit struct {
+	name, email, subject string
+}
+
+// setupShortlogRepo commits an empty tree once per commit, oldest first, and
+// points main at the last
+func setupShortlogRepo(t *testing.T, commits []testCommit) *repository.Repository {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	tree, err := repo.StoreObject(objects.NewTree(nil))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+
+	var parents []string
+	when := time.Unix(1700000000, 0)
+	for i, c := range commits {
+		sig := &objects.Signature{Name: c.name, Email: c.email, When: when.Add(time.Duration(i) * time.Hour)}
+		hash, err := repo.StoreObject(objects.NewCommit(tree, parents, sig, sig, c.subject+"\n"))
+		if err != nil {
+			t.Fatalf("Failed to store commit: %v", err)
+		}
+		parents = []string{hash}
+	}
+	if err := repo.UpdateRef("refs/heads/main", parents[0]); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	return repo
+}
+
+var testCommits = []testCommit{
+	{"Jane Doe", "jane@example.com", "Add parser"},
+	{"Bob", "bob@example.com", "Fix typo"},
+	{"jd", "jdoe@old-host.org", "Handle empty input"},
+	{"Jane Doe", "jane@example.com", "Document parser"},
+}
+
+func TestShortlog_MailmapCombinesIdentities(t *testing.T) {
+	repo := setupShortlogRepo(t, testCommits)
+	mailmapContent := "Jane Doe <jane@example.com> <jdoe@old-host.org>\n"
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, ".mailmap"), []byte(mailmapContent), 0644); err != nil {
+		t.Fatalf("Failed to write .mailmap: %v", err)
+	}
+	m, err := mailmap.Load(repo)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	groups, err := Shortlog(repo, ShortlogOptions{Mailmap: m})
+	if err != nil {
+		t.Fatalf("Shortlog failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"Jane Doe": {"Add parser", "Handle empty input", "Document parser"},
+		"Bob":      {"Fix typo"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups = %v, want %v", groups, want)
+	}
+
+	if got, want := display.FormatShortlogGroups(groups, true, true), "     3\tJane Doe\n     1\tBob\n"; got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+}
+
+func TestShortlog_WithoutMailmap(t *testing.T) {
+	repo := setupShortlogRepo(t, testCommits)
+
+	groups, err := Shortlog(repo, ShortlogOptions{Email: true})
+	if err != nil {
+		t.Fatalf("Shortlog failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"Jane Doe <jane@example.com>": {"Add parser", "Document parser"},
+		"Bob <bob@example.com>":       {"Fix typo"},
+		"jd <jdoe@old-host.org>":      {"Handle empty input"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups = %v, want %v", groups, want)
+	}
+
+	wantOutput := "Bob <bob@example.com> (1):\n      Fix typo\n\n" +
+		"Jane Doe <jane@example.com> (2):\n      Add parser\n      Document parser\n\n" +
+		"jd <jdoe@old-host.org> (1):\n      Handle empty input\n\n"
+	if got := display.FormatShortlogGroups(groups, false, false); got != wantOutput {
+		t.Errorf("output = %q, want %q", got, wantOutput)
+	}
+}
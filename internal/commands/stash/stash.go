@@ -0,0 +1,373 @@
+package stash
+
+import (
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/checkout"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/ident"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/reflog"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	stashRef      = "refs/stash"
+	logsDir       = "logs"
+	shortHashLen  = 7
+	executableBit = 0o111
+	refFileMode   = 0644
+)
+
+// StashEntry is one saved stash, Index being n in stash@{n}
+type StashEntry struct {
+	Index   int
+	Hash    string
+	Message string
+}
+
+func (e StashEntry) String() string {
+	return fmt.Sprintf("stash@{%d}: %s", e.Index, e.Message)
+}
+
+type PopResult struct {
+	Stash        string
+	UpdatedFiles []string
+	RemovedFiles []string
+	// Conflicts lists paths that block the pop; nothing is changed when set
+	Conflicts []string
+}
+
+// Push saves the index and working tree state of tracked files as a stash
+// commit under refs/stash and resets them to HEAD. The stash commit has HEAD
+// and a commit of the index as parents, and the working tree as its tree.
+func Push(repo *repository.Repository, message string) (string, error) {
+	if !repo.Exists() {
+		return "", errors.ErrNotGitRepository
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		return "", errors.NewGitError("stash", "", err)
+	}
+	if head == "" {
+		return "", errors.NewGitError("stash", "", fmt.Errorf("you do not have the initial commit yet"))
+	}
+
+	headObj, err := repo.LoadObject(head)
+	if err != nil {
+		return "", errors.NewObjectError(head, "commit", err)
+	}
+	headCommit, ok := headObj.(*objects.Commit)
+	if !ok {
+		return "", errors.NewObjectError(head, "commit", errors.ErrInvalidCommit)
+	}
+
+	headFiles, err := checkout.CommitFiles(repo, head)
+	if err != nil {
+		return "", errors.NewGitError("stash", "", err)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return "", errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	indexFiles := checkout.IndexFiles(idx)
+	workIdx, workFiles, err := snapshotWorkTree(repo, idx)
+	if err != nil {
+		return "", errors.NewGitError("stash", "", err)
+	}
+
+	changes := union(checkout.DiffFiles(headFiles, indexFiles), checkout.DiffFiles(headFiles, workFiles))
+	if len(changes) == 0 {
+		return "", errors.NewGitError("stash", "", fmt.Errorf("no local changes to save"))
+	}
+
+	indexTree, err := idx.WriteTreeTo(repo)
+	if err != nil {
+		return "", errors.NewGitError("stash", "", fmt.Errorf("write index tree: %w", err))
+	}
+
+	workTree, err := workIdx.WriteTreeTo(repo)
+	if err != nil {
+		return "", errors.NewGitError("stash", "", fmt.Errorf("write working tree: %w", err))
+	}
+
+	branch, err := repo.GetCurrentBranch()
+	if err != nil {
+		branch = "(no branch)"
+	}
+	subject, _, _ := strings.Cut(headCommit.Message(), "\n")
+	base := fmt.Sprintf("%s: %s %s", branch, hash.ShortHash(head, shortHashLen), subject)
+
+	id, err := ident.Resolve(repo.GitDir)
+	if err != nil {
+		return "", errors.NewGitError("stash", "", err)
+	}
+	now := time.Now()
+	author, committer := id.Author.Signature(now), id.Committer.Signature(now)
+
+	indexCommit := objects.NewCommit(indexTree, []string{head}, author, committer, "index on "+base)
+	indexHash, err := repo.StoreObject(indexCommit)
+	if err != nil {
+		return "", errors.NewGitError("stash", "", err)
+	}
+
+	stashMessage := "WIP on " + base
+	if message != "" {
+		stashMessage = fmt.Sprintf("On %s: %s", branch, message)
+	}
+
+	stashCommit := objects.NewCommit(workTree, []string{head, indexHash}, author, committer, stashMessage)
+	stashHash, err := repo.StoreObject(stashCommit)
+	if err != nil {
+		return "", errors.NewGitError("stash", "", err)
+	}
+
+	if err := repo.UpdateRefWithMessage(stashRef, stashHash, stashMessage); err != nil {
+		return "", errors.NewGitError("stash", "", err)
+	}
+
+	// tracked files that only exist in the index or working tree go away too
+	if _, _, err := checkout.ApplyFiles(repo, idx, headFiles, changes); err != nil {
+		return "", err
+	}
+
+	if err := idx.Save(); err != nil {
+		return "", errors.NewIndexError("", fmt.Errorf("save index: %w", err))
+	}
+
+	return stashHash, nil
+}
+
+// Pop applies the latest stash onto the working tree and index and drops it.
+// Paths changed both by the stash and since it was made, or with local
+// changes, are reported as conflicts and the stash is left in place.
+func Pop(repo *repository.Repository) (*PopResult, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	stashHash, err := repo.ReadRef(stashRef)
+	if err != nil {
+		return nil, errors.NewGitError("stash", "", fmt.Errorf("no stash entries found"))
+	}
+
+	obj, err := repo.LoadObject(stashHash)
+	if err != nil {
+		return nil, errors.NewObjectError(stashHash, "commit", err)
+	}
+	stashCommit, ok := obj.(*objects.Commit)
+	if !ok || len(stashCommit.Parents()) < 2 {
+		return nil, errors.NewObjectError(stashHash, "commit", fmt.Errorf("not a stash commit"))
+	}
+
+	baseFiles, err := checkout.CommitFiles(repo, stashCommit.Parents()[0])
+	if err != nil {
+		return nil, errors.NewGitError("stash", "", err)
+	}
+	indexFiles, err := checkout.CommitFiles(repo, stashCommit.Parents()[1])
+	if err != nil {
+		return nil, errors.NewGitError("stash", "", err)
+	}
+	workFiles, err := checkout.TreeFiles(repo, stashCommit.Tree())
+	if err != nil {
+		return nil, errors.NewGitError("stash", "", err)
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		return nil, errors.NewGitError("stash", "", err)
+	}
+	headFiles := make(map[string]objects.TreeEntry)
+	if head != "" {
+		headFiles, err = checkout.CommitFiles(repo, head)
+		if err != nil {
+			return nil, errors.NewGitError("stash", "", err)
+		}
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	indexChanges := checkout.DiffFiles(baseFiles, indexFiles)
+	changes := union(checkout.DiffFiles(baseFiles, workFiles), indexChanges)
+
+	result := &PopResult{Stash: stashHash}
+	for _, path := range changes {
+		// HEAD moved this path since the stash was made, to something else
+		if !sameEntry(headFiles, baseFiles, path) && !sameEntry(headFiles, workFiles, path) {
+			result.Conflicts = append(result.Conflicts, path)
+		}
+	}
+	result.Conflicts = union(result.Conflicts, checkout.FindConflicts(repo, idx, headFiles, workFiles, changes))
+
+	if len(result.Conflicts) > 0 {
+		return result, errors.NewGitError("stash", "", errors.ErrMergeConflict)
+	}
+
+	result.UpdatedFiles, result.RemovedFiles, err = checkout.ApplyFiles(repo, idx, workFiles, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	// ApplyFiles staged the working version; restore the stashed index state,
+	// or HEAD's version for paths whose change was never staged
+	staged := make(map[string]bool, len(indexChanges))
+	for _, path := range indexChanges {
+		staged[path] = true
+	}
+	for _, path := range changes {
+		want := headFiles
+		if staged[path] {
+			want = indexFiles
+		}
+		if sameEntry(want, workFiles, path) {
+			continue
+		}
+
+		entry, ok := want[path]
+		if !ok {
+			idx.Remove(path)
+			continue
+		}
+		if err := idx.Add(path, entry.Hash, uint32(entry.Mode), 0, time.Now()); err != nil {
+			return nil, errors.NewIndexError(path, err)
+		}
+	}
+
+	if err := idx.Save(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("save index: %w", err))
+	}
+
+	if err := Drop(repo, 0); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// List returns the saved stashes, newest first
+func List(repo *repository.Repository) ([]StashEntry, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	entries, err := reflog.Read(repo, stashRef)
+	if err != nil {
+		if stderrors.Is(err, errors.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	stashes := make([]StashEntry, len(entries))
+	for i, entry := range entries {
+		stashes[i] = StashEntry{Index: i, Hash: entry.NewHash, Message: entry.Message}
+	}
+
+	return stashes, nil
+}
+
+// Drop removes stash@{n}, deleting refs/stash once no stashes are left
+func Drop(repo *repository.Repository, n int) error {
+	remaining, err := reflog.Delete(repo, stashRef, n)
+	if err != nil {
+		return errors.NewGitError("stash", "", err)
+	}
+
+	refPath := filepath.Join(repo.GitDir, stashRef)
+	if len(remaining) == 0 {
+		if err := os.Remove(refPath); err != nil && !os.IsNotExist(err) {
+			return errors.NewGitError("stash", stashRef, err)
+		}
+		if err := os.Remove(filepath.Join(repo.GitDir, logsDir, stashRef)); err != nil && !os.IsNotExist(err) {
+			return errors.NewGitError("stash", stashRef, err)
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(refPath, []byte(remaining[0].NewHash+"\n"), refFileMode); err != nil {
+		return errors.NewGitError("stash", stashRef, err)
+	}
+
+	return nil
+}
+
+// snapshotWorkTree stores the working copy of every tracked file as a blob
+// and returns an in-memory index describing it. Deleted files are left out.
+func snapshotWorkTree(repo *repository.Repository, idx *index.Index) (*index.Index, map[string]objects.TreeEntry, error) {
+	workIdx := index.New(repo.GitDir)
+	files := make(map[string]objects.TreeEntry)
+
+	for path, entry := range idx.GetAllEntries() {
+		fullPath := filepath.Join(repo.WorkDir, filepath.FromSlash(path))
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		blobHash, err := repo.StoreObject(objects.NewBlob(content))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		mode := objects.FileMode(entry.Mode)
+		if info.Mode()&executableBit != 0 {
+			mode = objects.FileModeExecutable
+		} else if mode == objects.FileModeExecutable {
+			mode = objects.FileModeBlob
+		}
+
+		if err := workIdx.AddWithFileInfo(path, blobHash, uint32(mode), info); err != nil {
+			return nil, nil, err
+		}
+		files[path] = objects.TreeEntry{Mode: mode, Name: path, Hash: blobHash}
+	}
+
+	return workIdx, files, nil
+}
+
+func sameEntry(a, b map[string]objects.TreeEntry, path string) bool {
+	ea, inA := a[path]
+	eb, inB := b[path]
+	if inA != inB {
+		return false
+	}
+	return !inA || (ea.Hash == eb.Hash && ea.Mode == eb.Mode)
+}
+
+// union merges sorted path lists without duplicates
+func union(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var result []string
+	for _, list := range [][]string{a, b} {
+		for _, path := range list {
+			if !seen[path] {
+				seen[path] = true
+				result = append(result, path)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,271 @@
+package stash
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/commit"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+func setupTestRepo(t *testing.T) *repository.Repository {
+	t.Helper()
+
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	stageFiles(t, repo, map[string]string{"a.txt": "a1\n", "b.txt": "b1\n"})
+	commitIndex(t, repo, "initial")
+
+	return repo
+}
+
+// stageFiles writes files to the working tree and adds them to the index
+func stageFiles(t *testing.T, repo *repository.Repository, files map[string]string) {
+	t.Helper()
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+
+	for path, content := range files {
+		writeFile(t, repo, path, content)
+
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+
+		info, err := os.Stat(filepath.Join(repo.WorkDir, path))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+
+		if err := idx.AddWithFileInfo(path, blobHash, uint32(objects.FileModeBlob), info); err != nil {
+			t.Fatalf("Failed to stage %s: %v", path, err)
+		}
+	}
+
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+}
+
+func commitIndex(t *testing.T, repo *repository.Repository, message string) string {
+	t.Helper()
+
+	result, err := commit.CreateCommit(repo, commit.CommitOptions{
+		Message:     message,
+		AuthorName:  "Test Author",
+		AuthorEmail: "test@example.com",
+		SkipHooks:   true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	return result.Hash
+}
+
+func writeFile(t *testing.T, repo *repository.Repository, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo.WorkDir, path), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func readFile(t *testing.T, repo *repository.Repository, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(repo.WorkDir, path))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func indexHash(t *testing.T, repo *repository.Repository, path string) string {
+	t.Helper()
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	entry, ok := idx.Get(path)
+	if !ok {
+		return ""
+	}
+	return entry.Hash
+}
+
+func blobHash(t *testing.T, repo *repository.Repository, content string) string {
+	t.Helper()
+	hash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	return hash
+}
+
+func TestPushAndPop(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	// b and c are staged, a is only changed in the working tree
+	stageFiles(t, repo, map[string]string{"b.txt": "b2\n", "c.txt": "c1\n"})
+	writeFile(t, repo, "a.txt", "a2\n")
+	writeFile(t, repo, "b.txt", "b3\n")
+
+	stashHash, err := Push(repo, "")
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if got := readFile(t, repo, "a.txt"); got != "a1\n" {
+		t.Errorf("Expected a.txt reset to HEAD, got %q", got)
+	}
+	if got := readFile(t, repo, "b.txt"); got != "b1\n" {
+		t.Errorf("Expected b.txt reset to HEAD, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo.WorkDir, "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected newly added c.txt to be removed, got %v", err)
+	}
+	if got := indexHash(t, repo, "b.txt"); got != blobHash(t, repo, "b1\n") {
+		t.Errorf("Expected index entry for b.txt reset to HEAD, got %s", got)
+	}
+
+	stashes, err := List(repo)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(stashes) != 1 || stashes[0].Hash != stashHash {
+		t.Fatalf("Expected one stash %s, got %+v", stashHash, stashes)
+	}
+	if !strings.HasPrefix(stashes[0].String(), "stash@{0}: WIP on main: ") {
+		t.Errorf("Unexpected stash description %q", stashes[0].String())
+	}
+
+	obj, err := repo.LoadObject(stashHash)
+	if err != nil {
+		t.Fatalf("Failed to load stash commit: %v", err)
+	}
+	if parents := obj.(*objects.Commit).Parents(); len(parents) != 2 {
+		t.Errorf("Expected stash commit with HEAD and index parents, got %v", parents)
+	}
+
+	result, err := Pop(repo)
+	if err != nil {
+		t.Fatalf("Pop failed: %v", err)
+	}
+	if result.Stash != stashHash {
+		t.Errorf("Expected popped stash %s, got %s", stashHash, result.Stash)
+	}
+
+	for path, want := range map[string]string{"a.txt": "a2\n", "b.txt": "b3\n", "c.txt": "c1\n"} {
+		if got := readFile(t, repo, path); got != want {
+			t.Errorf("Expected %s to be %q after pop, got %q", path, want, got)
+		}
+	}
+
+	// the index gets back what was staged, not the working copy
+	if got := indexHash(t, repo, "a.txt"); got != blobHash(t, repo, "a1\n") {
+		t.Errorf("Expected unstaged a.txt to keep HEAD in the index, got %s", got)
+	}
+	if got := indexHash(t, repo, "b.txt"); got != blobHash(t, repo, "b2\n") {
+		t.Errorf("Expected staged b.txt restored in the index, got %s", got)
+	}
+	if got := indexHash(t, repo, "c.txt"); got != blobHash(t, repo, "c1\n") {
+		t.Errorf("Expected staged c.txt restored in the index, got %s", got)
+	}
+
+	stashes, err = List(repo)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(stashes) != 0 {
+		t.Errorf("Expected stash to be dropped, got %+v", stashes)
+	}
+	if _, err := repo.ReadRef(stashRef); err == nil {
+		t.Error("Expected refs/stash to be removed with the last stash")
+	}
+}
+
+func TestPush_NoChanges(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if _, err := Push(repo, ""); err == nil {
+		t.Error("Expected error when there is nothing to stash")
+	}
+}
+
+func TestPush_MultipleStashes(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	writeFile(t, repo, "a.txt", "first\n")
+	first, err := Push(repo, "first change")
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	writeFile(t, repo, "a.txt", "second\n")
+	second, err := Push(repo, "")
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	stashes, err := List(repo)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(stashes) != 2 || stashes[0].Hash != second || stashes[1].Hash != first {
+		t.Fatalf("Expected stashes newest first, got %+v", stashes)
+	}
+	if stashes[1].Message != "On main: first change" {
+		t.Errorf("Unexpected message %q", stashes[1].Message)
+	}
+
+	if _, err := Pop(repo); err != nil {
+		t.Fatalf("Pop failed: %v", err)
+	}
+	if got := readFile(t, repo, "a.txt"); got != "second\n" {
+		t.Errorf("Expected latest stash applied, got %q", got)
+	}
+
+	ref, err := repo.ReadRef(stashRef)
+	if err != nil || ref != first {
+		t.Errorf("Expected refs/stash to point at remaining stash %s, got %s (%v)", first, ref, err)
+	}
+}
+
+func TestPop_Conflict(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	writeFile(t, repo, "a.txt", "stashed\n")
+	stashHash, err := Push(repo, "")
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	stageFiles(t, repo, map[string]string{"a.txt": "committed\n"})
+	commitIndex(t, repo, "change a")
+
+	result, err := Pop(repo)
+	if !stderrors.Is(err, errors.ErrMergeConflict) {
+		t.Fatalf("Expected ErrMergeConflict, got %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0] != "a.txt" {
+		t.Errorf("Expected conflict on a.txt, got %v", result.Conflicts)
+	}
+
+	if got := readFile(t, repo, "a.txt"); got != "committed\n" {
+		t.Errorf("Expected working tree untouched, got %q", got)
+	}
+	if ref, err := repo.ReadRef(stashRef); err != nil || ref != stashHash {
+		t.Errorf("Expected stash to be kept after a conflict, got %s (%v)", ref, err)
+	}
+}
@@ -0,0 +1,199 @@
+package status
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+)
+
+var nullHash = strings.Repeat("0", 40)
+
+// unmergedCodes maps the stages present for a conflicted path, as a bit per
+// stage, to its two-letter code
+var unmergedCodes = map[int]string{
+	1 << 1:             "DD",
+	1 << 2:             "AU",
+	1<<1 | 1<<2:        "UD",
+	1 << 3:             "UA",
+	1<<1 | 1<<3:        "DU",
+	1<<2 | 1<<3:        "AA",
+	1<<1 | 1<<2 | 1<<3: "UU",
+}
+
+// Porcelain formats the status for scripts as git status --porcelain does:
+// version 1 prints "XY path" lines and version 2 adds modes and object
+// hashes. With nulTerminated, entries end in NUL and paths are not quoted.
+func (sr *StatusResult) Porcelain(v int, nulTerminated bool) string {
+	term := "\n"
+	if nulTerminated {
+		term = "\x00"
+	}
+
+	var tracked, untracked strings.Builder
+	for _, entry := range sr.Entries {
+		if entry.WorkStatus == StatusUntracked {
+			if v == 2 {
+				untracked.WriteString("? " + porcelainPath(entry.Path, nulTerminated, false) + term)
+			} else {
+				untracked.WriteString("?? " + porcelainPath(entry.Path, nulTerminated, true) + term)
+			}
+			// a path deleted from the index can still be in the work tree
+			if entry.IndexStatus == StatusUnmodified {
+				continue
+			}
+		}
+
+		if v == 2 {
+			tracked.WriteString(entry.porcelainV2(nulTerminated) + term)
+		} else {
+			tracked.WriteString(entry.porcelainV1(nulTerminated) + term)
+		}
+	}
+
+	return tracked.String() + untracked.String()
+}
+
+func (e *StatusEntry) porcelainV1(nulTerminated bool) string {
+	path := porcelainPath(e.Path, nulTerminated, true)
+	switch {
+	case e.Stages != nil:
+		return e.unmergedCode() + " " + path
+	case e.OrigPath == "":
+		return e.code(' ') + " " + path
+	case nulTerminated:
+		return e.code(' ') + " " + path + "\x00" + e.OrigPath
+	default:
+		return e.code(' ') + " " + porcelainPath(e.OrigPath, false, true) + " -> " + path
+	}
+}
+
+func (e *StatusEntry) porcelainV2(nulTerminated bool) string {
+	path := porcelainPath(e.Path, nulTerminated, false)
+	if e.Stages != nil {
+		modes := make([]objects.FileMode, 4)
+		hashes := []string{"", nullHash, nullHash, nullHash}
+		for _, stage := range e.Stages {
+			modes[stage.StageNumber] = objects.FileMode(stage.Mode)
+			hashes[stage.StageNumber] = stage.Hash
+		}
+		return fmt.Sprintf("u %s %s %06o %06o %06o %06o %s %s %s %s",
+			e.unmergedCode(), e.submoduleState(), modes[1], modes[2], modes[3], e.WorkMode,
+			hashes[1], hashes[2], hashes[3], path)
+	}
+
+	headHash, indexHash := orNull(e.HeadHash), orNull(e.IndexHash)
+	if e.OrigPath == "" {
+		return fmt.Sprintf("1 %s %s %06o %06o %06o %s %s %s",
+			e.code('.'), e.submoduleState(), e.HeadMode, e.IndexMode, e.WorkMode, headHash, indexHash, path)
+	}
+
+	sep := "\t"
+	if nulTerminated {
+		sep = "\x00"
+	}
+	return fmt.Sprintf("2 %s %s %06o %06o %06o %s %s R100 %s%s%s",
+		e.code('.'), e.submoduleState(), e.HeadMode, e.IndexMode, e.WorkMode, headHash, indexHash,
+		path, sep, porcelainPath(e.OrigPath, nulTerminated, false))
+}
+
+// code returns the XY letters of a tracked entry, unchanged being shown as
+// the given character
+func (e *StatusEntry) code(unchanged byte) string {
+	letter := func(s FileStatus) byte {
+		switch s {
+		case StatusAdded:
+			return 'A'
+		case StatusModified:
+			return 'M'
+		case StatusDeleted:
+			return 'D'
+		case StatusRenamed:
+			return 'R'
+		}
+		return unchanged
+	}
+	return string([]byte{letter(e.IndexStatus), letter(e.WorkStatus)})
+}
+
+// submoduleState is the porcelain v2 <sub> field: "N..." for a file, and
+// for a submodule "S" followed by C when its checked out commit differs from
+// the index. Changes inside the submodule are not looked at.
+func (e *StatusEntry) submoduleState() string {
+	switch {
+	case !e.Submodule:
+		return "N..."
+	case e.SubmoduleCommit != "" && e.SubmoduleCommit != e.IndexHash:
+		return "SC.."
+	default:
+		return "S..."
+	}
+}
+
+func (e *StatusEntry) unmergedCode() string {
+	mask := 0
+	for _, stage := range e.Stages {
+		mask |= 1 << stage.StageNumber
+	}
+	return unmergedCodes[mask]
+}
+
+func orNull(hash string) string {
+	if hash == "" {
+		return nullHash
+	}
+	return hash
+}
+
+// porcelainPath quotes path as a C string when it has control characters,
+// quotes, backslashes or non-ASCII bytes, or a space when quoteSpace is set.
+// NUL-terminated output is never quoted.
+func porcelainPath(path string, nulTerminated, quoteSpace bool) string {
+	if nulTerminated {
+		return path
+	}
+
+	needsQuote := false
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || (quoteSpace && c == ' ') {
+			needsQuote = true
+			break
+		}
+	}
+	if !needsQuote {
+		return path
+	}
+
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\a':
+			buf.WriteString(`\a`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\v':
+			buf.WriteString(`\v`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&buf, `\%03o`, c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
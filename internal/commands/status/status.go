@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/unkn0wn-root/git-go/internal/core/gitignore"
 	"github.com/unkn0wn-root/git-go/internal/core/hash"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/rename"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/core/submodule"
 	"github.com/unkn0wn-root/git-go/pkg/display"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
@@ -23,6 +29,7 @@ const (
 	StatusDeleted
 	StatusRenamed
 	StatusUnmodified
+	StatusUnmerged
 )
 
 func (s FileStatus) String() string {
@@ -37,6 +44,8 @@ func (s FileStatus) String() string {
 		return "D "
 	case StatusRenamed:
 		return "R "
+	case StatusUnmerged:
+		return "U "
 	default:
 		return "  "
 	}
@@ -51,10 +60,27 @@ type StatusEntry struct {
 	Path        string
 	IndexStatus FileStatus
 	WorkStatus  FileStatus
+	// OrigPath is the HEAD path of a staged rename
+	OrigPath string
+	// HeadMode, IndexMode and WorkMode are the path's mode in HEAD, the
+	// index and the working tree, zero where it is absent
+	HeadMode  objects.FileMode
+	IndexMode objects.FileMode
+	WorkMode  objects.FileMode
+	HeadHash  string
+	IndexHash string
+	// Stages holds the stage entries of a path left conflicted by a merge
+	Stages []*index.IndexEntry
+	// Submodule marks a gitlink, whose hashes are commits; SubmoduleCommit
+	// is the commit it has checked out, empty when it has not been cloned
+	Submodule       bool
+	SubmoduleCommit string
 }
 
 type StatusResult struct {
+	// Branch is the current branch, or the commit HEAD is detached at
 	Branch     string
+	Detached   bool
 	Entries    []StatusEntry
 	HasChanges bool
 	IsInitial  bool
@@ -64,50 +90,85 @@ func (sr *StatusResult) String() string {
 	// Convert to display format for colored output
 	entries := make([]display.StatusEntry, len(sr.Entries))
 	for i, entry := range sr.Entries {
+		path := entry.Path
+		if entry.OrigPath != "" {
+			path = entry.OrigPath + " -> " + entry.Path
+		}
+		if entry.Submodule && entry.WorkStatus == StatusModified {
+			path += " (new commits)"
+		}
 		entries[i] = display.StatusEntry{
-			Path:        entry.Path,
+			Path:        path,
 			IndexStatus: display.FileStatus(entry.IndexStatus),
 			WorkStatus:  display.FileStatus(entry.WorkStatus),
 		}
 	}
 
-	return display.FormatStatusResult(sr.Branch, entries, sr.IsInitial)
+	branch := sr.Branch
+	if sr.Detached {
+		branch = hash.ShortHash(branch, 7)
+	}
+	return display.FormatStatusResult(branch, sr.Detached, entries, sr.IsInitial)
+}
+
+// UntrackedMode is how untracked files are listed
+type UntrackedMode int
+
+const (
+	// UntrackedNormal shows a wholly untracked directory as one "dir/" entry
+	UntrackedNormal UntrackedMode = iota
+	// UntrackedAll lists every untracked file
+	UntrackedAll
+	// UntrackedNo leaves untracked files out
+	UntrackedNo
+)
+
+type StatusOptions struct {
+	Untracked UntrackedMode
 }
 
 func GetStatus(repo *repository.Repository) (*StatusResult, error) {
+	return GetStatusWithOptions(repo, StatusOptions{})
+}
+
+func GetStatusWithOptions(repo *repository.Repository, options StatusOptions) (*StatusResult, error) {
 	if !repo.Exists() {
 		return nil, errors.ErrNotGitRepository
 	}
 
-	branch, err := repo.GetCurrentBranch()
-	if err != nil {
+	headRef, detached, headHash, err := repo.HeadRef()
+	isInitial := err != nil || headHash == ""
+
+	branch := strings.TrimPrefix(headRef, "refs/heads/")
+	switch {
+	case detached:
+		branch = headHash
+	case branch == "":
 		branch = "main" // default
 	}
 
-	headHash, err := repo.GetHead()
-	isInitial := (err != nil || headHash == "")
-
 	idx := index.New(repo.GitDir)
 	if err := idx.Load(); err != nil {
 		return nil, errors.NewGitError("status", "", fmt.Errorf("load index: %w", err))
 	}
 
-	var headFiles map[string]string
+	var headFiles map[string]objects.TreeEntry
 	if !isInitial {
 		headFiles, err = getHeadFiles(repo, headHash)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		headFiles = make(map[string]string)
+		headFiles = make(map[string]objects.TreeEntry)
 	}
 
-	workingFiles, err := getWorkingFiles(repo)
+	workingFiles, err := getWorkingFiles(repo, idx)
 	if err != nil {
 		return nil, err
 	}
 
 	indexFiles := idx.GetAll()
+	unmerged := idx.Unmerged()
 
 	allFiles := make(map[string]bool)
 	for path := range headFiles {
@@ -120,21 +181,44 @@ func GetStatus(repo *repository.Repository) (*StatusResult, error) {
 		allFiles[path] = true
 	}
 
-	var entries []StatusEntry
+	entries := make(map[string]*StatusEntry)
 
 	for path := range allFiles {
-		entry := StatusEntry{Path: path}
+		entry := &StatusEntry{Path: path}
 
-		headHash, inHead := headFiles[path]
+		headEntry, inHead := headFiles[path]
 		indexEntry, inIndex := indexFiles[path]
-		workingHash, inWorking := workingFiles[path]
+		working, inWorking := workingFiles[path]
+
+		if inHead {
+			entry.HeadMode, entry.HeadHash = headEntry.Mode, headEntry.Hash
+		}
+		if working.mode == objects.FileModeCommit {
+			entry.Submodule, entry.SubmoduleCommit = true, working.commit
+		}
+		// the work tree mode is that of a tracked file
+		if inWorking && (inIndex || unmerged[path] != nil) {
+			entry.WorkMode = working.mode
+		}
+
+		if stages, ok := unmerged[path]; ok {
+			entry.Stages = stages
+			entry.IndexStatus = StatusUnmerged
+			entry.WorkStatus = StatusUnmerged
+			entries[path] = entry
+			continue
+		}
+
+		if inIndex {
+			entry.IndexMode, entry.IndexHash = objects.FileMode(indexEntry.Mode), indexEntry.Hash
+		}
 
 		// Determine index status (HEAD vs Index)
 		if !inHead && inIndex {
 			entry.IndexStatus = StatusAdded
 		} else if inHead && !inIndex {
 			entry.IndexStatus = StatusDeleted
-		} else if inHead && inIndex && headHash != indexEntry.Hash {
+		} else if inHead && inIndex && (headEntry.Hash != indexEntry.Hash || headEntry.Mode != entry.IndexMode) {
 			entry.IndexStatus = StatusModified
 		} else {
 			entry.IndexStatus = StatusUnmodified
@@ -145,100 +229,209 @@ func GetStatus(repo *repository.Repository) (*StatusResult, error) {
 			entry.WorkStatus = StatusUntracked
 		} else if inIndex && !inWorking {
 			entry.WorkStatus = StatusDeleted
-		} else if inIndex && inWorking && indexEntry.Hash != workingHash {
+		} else if inIndex && inWorking && (indexEntry.Hash != working.hash || entry.IndexMode != working.mode) {
 			entry.WorkStatus = StatusModified
 		} else {
 			entry.WorkStatus = StatusUnmodified
 		}
 
 		if entry.IndexStatus != StatusUnmodified || entry.WorkStatus != StatusUnmodified {
-			entries = append(entries, entry)
+			entries[path] = entry
 		}
 	}
 
+	detectRenames(entries)
+	listUntracked(entries, trackedDirs(idx), options.Untracked)
+
+	sorted := make([]StatusEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, *entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
 	return &StatusResult{
 		Branch:     branch,
-		Entries:    entries,
-		HasChanges: len(entries) > 0,
+		Detached:   detached,
+		Entries:    sorted,
+		HasChanges: len(sorted) > 0,
 		IsInitial:  isInitial,
 	}, nil
 }
 
-func getHeadFiles(repo *repository.Repository, headHash string) (map[string]string, error) {
-	commitObj, err := repo.LoadObject(headHash)
-	if err != nil {
-		return nil, err
+// detectRenames pairs staged additions with staged deletions of the same
+// blob, turning each pair into a single rename of the added path
+func detectRenames(entries map[string]*StatusEntry) {
+	deleted := make(map[string]objects.TreeEntry)
+	added := make(map[string]objects.TreeEntry)
+	for path, entry := range entries {
+		switch entry.IndexStatus {
+		case StatusDeleted:
+			deleted[path] = objects.TreeEntry{Mode: entry.HeadMode, Hash: entry.HeadHash}
+		case StatusAdded:
+			added[path] = objects.TreeEntry{Mode: entry.IndexMode, Hash: entry.IndexHash}
+		}
 	}
-
-	commit, ok := commitObj.(*objects.Commit)
-	if !ok {
-		return nil, errors.NewGitError("status", "", fmt.Errorf("HEAD is not a commit"))
+	if len(deleted) == 0 || len(added) == 0 {
+		return
 	}
 
-	treeObj, err := repo.LoadObject(commit.Tree())
-	if err != nil {
-		return nil, err
+	for newPath, oldPath := range rename.Detect(deleted, added) {
+		entry, source := entries[newPath], entries[oldPath]
+		entry.IndexStatus = StatusRenamed
+		entry.OrigPath = oldPath
+		entry.HeadMode, entry.HeadHash = source.HeadMode, source.HeadHash
+
+		// a file left at the old path is untracked now
+		if source.WorkStatus == StatusUntracked {
+			source.IndexStatus = StatusUnmodified
+			source.HeadMode, source.HeadHash = 0, ""
+		} else {
+			delete(entries, oldPath)
+		}
 	}
+}
 
-	tree, ok := treeObj.(*objects.Tree)
-	if !ok {
-		return nil, errors.NewGitError("status", "", fmt.Errorf("commit tree is not a tree object"))
+// listUntracked applies mode to the untracked entries, collapsing the files
+// of a directory with no tracked files into a single entry for the topmost
+// such directory
+func listUntracked(entries map[string]*StatusEntry, tracked map[string]bool, mode UntrackedMode) {
+	if mode == UntrackedAll {
+		return
 	}
 
-	files := make(map[string]string)
-	if err := walkTree(repo, tree, "", files); err != nil {
-		return nil, err
+	for filePath, entry := range entries {
+		if entry.WorkStatus != StatusUntracked {
+			continue
+		}
+
+		dir := ""
+		if mode == UntrackedNormal {
+			dir = untrackedDir(filePath, tracked)
+			if dir == "" {
+				continue
+			}
+		}
+
+		// a path deleted from the index keeps its staged deletion
+		if entry.IndexStatus == StatusUnmodified {
+			delete(entries, filePath)
+		} else {
+			entry.WorkStatus = StatusUnmodified
+		}
+		if dir != "" {
+			entries[dir+"/"] = &StatusEntry{Path: dir + "/", IndexStatus: StatusUnmodified, WorkStatus: StatusUntracked}
+		}
 	}
+}
 
-	return files, nil
+// untrackedDir returns the topmost directory above filePath that holds no
+// tracked file, or "" when every directory above it does
+func untrackedDir(filePath string, tracked map[string]bool) string {
+	for i := 0; i < len(filePath); i++ {
+		if filePath[i] == '/' && !tracked[filePath[:i]] {
+			return filePath[:i]
+		}
+	}
+	return ""
 }
 
-func walkTree(repo *repository.Repository, tree *objects.Tree, prefix string, files map[string]string) error {
-	for _, entry := range tree.Entries() {
-		path := entry.Name
-		if prefix != "" {
-			path = filepath.Join(prefix, entry.Name)
+// trackedDirs returns every directory holding an index entry at any depth
+func trackedDirs(idx *index.Index) map[string]bool {
+	dirs := make(map[string]bool)
+	for filePath := range idx.GetAllEntries() {
+		for dir := path.Dir(filePath); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
 		}
+	}
+	return dirs
+}
+
+func getHeadFiles(repo *repository.Repository, headHash string) (map[string]objects.TreeEntry, error) {
+	commit, err := repo.LoadCommit(headHash)
+	if err != nil {
+		return nil, err
+	}
 
+	files := make(map[string]objects.TreeEntry)
+	err = objects.WalkTree(repo, commit.Tree(), func(path string, entry objects.TreeEntry) error {
 		switch entry.Mode {
-		case objects.FileModeTree:
-			subtreeObj, err := repo.LoadObject(entry.Hash)
-			if err != nil {
-				return err
-			}
-			subtree, ok := subtreeObj.(*objects.Tree)
-			if !ok {
-				return fmt.Errorf("object %s is not a tree", entry.Hash)
-			}
-			if err := walkTree(repo, subtree, path, files); err != nil {
-				return err
-			}
-		case objects.FileModeBlob, objects.FileModeExecutable:
-			gitPath := filepath.ToSlash(path)
-			files[gitPath] = entry.Hash
+		case objects.FileModeBlob, objects.FileModeExecutable, objects.FileModeCommit:
+			files[path] = entry
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	return files, nil
 }
 
-func getWorkingFiles(repo *repository.Repository) (map[string]string, error) {
-	files := make(map[string]string)
+// workingFile is a working tree file's blob hash and mode. For a submodule
+// the hash is a commit and commit is the one checked out, if any.
+type workingFile struct {
+	hash   string
+	mode   objects.FileMode
+	commit string
+}
+
+// getWorkingFiles hashes the files in the working tree, reusing the index
+// hash of files whose stat data shows they have not changed. Ignored files
+// are left out unless they are tracked.
+func getWorkingFiles(repo *repository.Repository, idx *index.Index) (map[string]workingFile, error) {
+	files := make(map[string]workingFile)
+
+	gi, err := gitignore.NewGitIgnore(repo.WorkDir)
+	if err != nil {
+		return nil, errors.NewGitError("status", "", fmt.Errorf("load gitignore: %w", err))
+	}
+	tracked := trackedDirs(idx)
 
-	err := filepath.WalkDir(repo.WorkDir, func(path string, d fs.DirEntry, err error) error {
+	err = filepath.WalkDir(repo.WorkDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
+		relPath, err := filepath.Rel(repo.WorkDir, path)
+		if err != nil {
+			return err
+		}
+		gitPath := filepath.ToSlash(relPath)
+
 		if d.IsDir() {
 			if d.Name() == ".git" {
 				return filepath.SkipDir
 			}
+			if gitPath != "." && !tracked[gitPath] && gi.IsIgnored(gitPath, true) {
+				return filepath.SkipDir
+			}
+			// a submodule is compared by the commit it has checked out, one
+			// not cloned yet being taken to be at the recorded commit
+			if entry, ok := idx.Get(gitPath); ok && objects.FileMode(entry.Mode) == objects.FileModeCommit {
+				commit, cloned := submodule.Head(repo, gitPath)
+				working := workingFile{hash: entry.Hash, mode: objects.FileModeCommit}
+				if cloned {
+					working.hash, working.commit = commit, commit
+				}
+				files[gitPath] = working
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		relPath, err := filepath.Rel(repo.WorkDir, path)
-		if err != nil {
-			return err
+		if _, ok := idx.Get(gitPath); !ok && gi.IsIgnored(gitPath, false) {
+			return nil
+		}
+
+		mode := objects.FileModeBlob
+		info, err := d.Info()
+		if err == nil && info.Mode()&0o111 != 0 {
+			mode = objects.FileModeExecutable
+		}
+		if err == nil && idx.StatUnchanged(gitPath, info) {
+			entry, _ := idx.Get(gitPath)
+			files[gitPath] = workingFile{hash: entry.Hash, mode: mode}
+			return nil
 		}
 
 		content, err := os.ReadFile(path)
@@ -246,9 +439,13 @@ func getWorkingFiles(repo *repository.Repository) (map[string]string, error) {
 			return err
 		}
 
-		objHash := hash.ComputeObjectHash("blob", content)
-		gitPath := filepath.ToSlash(relPath)
-		files[gitPath] = objHash
+		// content is hashed as add would store it, so converted line endings
+		// are not reported as modifications
+		autoCRLF, err := repo.AutoCRLFFor(gitPath)
+		if err != nil {
+			return err
+		}
+		files[gitPath] = workingFile{hash: hash.ComputeObjectHash("blob", autoCRLF.ToIndex(content)), mode: mode}
 
 		return nil
 	})
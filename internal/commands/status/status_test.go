@@ -3,7 +3,10 @@ package status
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -231,6 +234,88 @@ func TestGetStatus_ModifiedFile(t *testing.T) {
 	}
 }
 
+func TestGetStatus_ModifiedAndStagedFile(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := setupRepoWithCommit(t, tempDir)
+
+	newContent := []byte("modified and staged content")
+	if err := os.WriteFile(filepath.Join(tempDir, "test.txt"), newContent, 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	blobHash, err := repo.StoreObject(objects.NewBlob(newContent))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	idx.Add("test.txt", blobHash, uint32(objects.FileModeBlob), int64(len(newContent)), time.Now())
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	status, err := GetStatus(repo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(status.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(status.Entries))
+	}
+	entry := status.Entries[0]
+	if entry.IndexStatus != StatusModified {
+		t.Errorf("Expected staged modified status, got %v", entry.IndexStatus)
+	}
+	if entry.WorkStatus != StatusUnmodified {
+		t.Errorf("Expected unmodified working status, got %v", entry.WorkStatus)
+	}
+
+	output := status.String()
+	staged := strings.Index(output, "Changes to be committed:")
+	if staged < 0 {
+		t.Fatalf("Expected staged changes section, got:\n%s", output)
+	}
+	if !strings.Contains(output[staged:], "M  test.txt") {
+		t.Errorf("Expected 'M  test.txt' in staged section, got:\n%s", output)
+	}
+	if strings.Contains(output, "Changes not staged for commit:") {
+		t.Errorf("Expected no unstaged section, got:\n%s", output)
+	}
+}
+
+func TestGetStatus_StagedDeletion(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := setupRepoWithCommit(t, tempDir)
+
+	if err := os.Remove(filepath.Join(tempDir, "test.txt")); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if err := idx.Remove("test.txt"); err != nil {
+		t.Fatalf("Failed to remove index entry: %v", err)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	status, err := GetStatus(repo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(status.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(status.Entries))
+	}
+	if entry := status.Entries[0]; entry.IndexStatus != StatusDeleted || entry.WorkStatus != StatusUnmodified {
+		t.Errorf("Expected staged deletion, got index %v, work %v", entry.IndexStatus, entry.WorkStatus)
+	}
+}
+
 func TestGetStatus_DeletedFile(t *testing.T) {
 	tempDir := t.TempDir()
 	repo := setupRepoWithCommit(t, tempDir)
@@ -263,6 +348,129 @@ func TestGetStatus_DeletedFile(t *testing.T) {
 	}
 }
 
+func TestGetStatus_UntrackedDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := setupRepoWithCommit(t, tempDir)
+
+	files := map[string]string{
+		".gitignore":             "*.log\nignored/\n",
+		"build/out/deep/app.bin": "binary",
+		"build/out/app.map":      "map",
+		"build/readme.txt":       "readme",
+		"logs/debug.log":         "ignored file",
+		"ignored/file.txt":       "ignored directory",
+		"test.txt.orig":          "untracked next to tracked",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	untracked := func(options StatusOptions) []string {
+		status, err := GetStatusWithOptions(repo, options)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var paths []string
+		for _, entry := range status.Entries {
+			if entry.WorkStatus == StatusUntracked {
+				paths = append(paths, entry.Path)
+			}
+		}
+		return paths
+	}
+
+	expected := []string{".gitignore", "build/", "test.txt.orig"}
+	if got := untracked(StatusOptions{}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	expected = []string{".gitignore", "build/out/app.map", "build/out/deep/app.bin", "build/readme.txt", "test.txt.orig"}
+	if got := untracked(StatusOptions{Untracked: UntrackedAll}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if got := untracked(StatusOptions{Untracked: UntrackedNo}); len(got) != 0 {
+		t.Errorf("Expected no untracked entries, got %v", got)
+	}
+}
+
+func TestGetStatus_UntrackedDirectoryBelowTracked(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := setupRepoWithCommit(t, tempDir)
+
+	content := []byte("tracked")
+	if err := os.MkdirAll(filepath.Join(tempDir, "src", "new", "pkg"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "src", "main.go"), content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "src", "new", "pkg", "a.go"), content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	blobHash, err := repo.StoreObject(objects.NewBlob(content))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	idx.Add("src/main.go", blobHash, uint32(objects.FileModeBlob), int64(len(content)), time.Now())
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	status, err := GetStatus(repo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var untracked []string
+	for _, entry := range status.Entries {
+		if entry.WorkStatus == StatusUntracked {
+			untracked = append(untracked, entry.Path)
+		}
+	}
+	if expected := []string{"src/new/"}; !reflect.DeepEqual(untracked, expected) {
+		t.Errorf("Expected %v, got %v", expected, untracked)
+	}
+}
+
+func TestGetStatus_DetachedHead(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := setupRepoWithCommit(t, tempDir)
+
+	headHash, err := repo.GetHead()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	if err := repo.SetHead(headHash, "checkout: moving from main to "+headHash); err != nil {
+		t.Fatalf("Failed to detach HEAD: %v", err)
+	}
+
+	status, err := GetStatus(repo)
+	if err != nil {
+		t.Fatalf("Unexpected error on a detached HEAD: %v", err)
+	}
+	if !status.Detached || status.Branch != headHash {
+		t.Errorf("Expected detached at %s, got detached %v branch %q", headHash, status.Detached, status.Branch)
+	}
+	if status.HasChanges {
+		t.Errorf("Expected a clean tree, got %v", status.Entries)
+	}
+
+	expected := "HEAD detached at " + headHash[:7]
+	if output := status.String(); !strings.HasPrefix(output, expected) {
+		t.Errorf("Expected output to start with %q, got:\n%s", expected, output)
+	}
+}
+
 func TestGetHeadFiles_Success(t *testing.T) {
 	tempDir := t.TempDir()
 	repo := setupRepoWithCommit(t, tempDir)
@@ -299,7 +507,7 @@ func TestGetWorkingFiles_Success(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	files, err := getWorkingFiles(repo)
+	files, err := getWorkingFiles(repo, index.New(repo.GitDir))
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -322,11 +530,11 @@ func TestGetWorkingFiles_SkipsGitDir(t *testing.T) {
 	}
 
 	gitFile := filepath.Join(tempDir, ".git", "config")
-	if err := os.WriteFile(gitFile, []byte("test config"), 0644); err != nil {
+	if err := os.WriteFile(gitFile, []byte("[test]\n\tkey = value\n"), 0644); err != nil {
 		t.Fatalf("Failed to create git file: %v", err)
 	}
 
-	files, err := getWorkingFiles(repo)
+	files, err := getWorkingFiles(repo, index.New(repo.GitDir))
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -433,9 +641,234 @@ func BenchmarkGetWorkingFiles(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := getWorkingFiles(repo)
+		_, err := getWorkingFiles(repo, index.New(repo.GitDir))
 		if err != nil {
 			b.Fatalf("Unexpected error: %v", err)
 		}
 	}
 }
+
+func TestGetStatus_AutoCRLF(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tempDir := t.TempDir()
+	repo := repository.New(tempDir)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte("[core]\n\tautocrlf = true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("one\ntwo\n")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	working := []byte("one\r\ntwo\r\n")
+	if err := os.WriteFile(filepath.Join(tempDir, "crlf.txt"), working, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	idx := index.New(repo.GitDir)
+	idx.Add("crlf.txt", blobHash, uint32(objects.FileModeBlob), int64(len(working)), time.Unix(0, 0))
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	status, err := GetStatus(repo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, entry := range status.Entries {
+		if entry.Path == "crlf.txt" && entry.WorkStatus == StatusModified {
+			t.Error("Expected CRLF working copy of an LF blob not to be modified")
+		}
+	}
+}
+
+func TestStatusResult_Porcelain(t *testing.T) {
+	blobHash := strings.Repeat("a", 40)
+	result := &StatusResult{Entries: []StatusEntry{
+		{Path: "new name.txt", OrigPath: "old.txt", IndexStatus: StatusRenamed, WorkStatus: StatusUnmodified,
+			HeadMode: objects.FileModeBlob, IndexMode: objects.FileModeBlob, WorkMode: objects.FileModeBlob,
+			HeadHash: blobHash, IndexHash: blobHash},
+		{Path: "untracked.txt", IndexStatus: StatusUnmodified, WorkStatus: StatusUntracked},
+	}}
+
+	tests := []struct {
+		v        int
+		nul      bool
+		expected string
+	}{
+		{1, false, "R  old.txt -> \"new name.txt\"\n?? untracked.txt\n"},
+		{1, true, "R  new name.txt\x00old.txt\x00?? untracked.txt\x00"},
+		{2, false, "2 R. N... 100644 100644 100644 " + blobHash + " " + blobHash + " R100 new name.txt\told.txt\n? untracked.txt\n"},
+	}
+	for _, test := range tests {
+		if got := result.Porcelain(test.v, test.nul); got != test.expected {
+			t.Errorf("Porcelain(%d, %v):\nexpected %q\ngot      %q", test.v, test.nul, test.expected, got)
+		}
+	}
+}
+
+func TestPorcelainPath(t *testing.T) {
+	tests := []struct {
+		path       string
+		quoteSpace bool
+		expected   string
+	}{
+		{"plain.txt", true, "plain.txt"},
+		{"with space.txt", true, `"with space.txt"`},
+		{"with space.txt", false, "with space.txt"},
+		{"tab\there", false, `"tab\there"`},
+		{"ü.txt", false, `"\303\274.txt"`},
+		{`quo"te`, false, `"quo\"te"`},
+	}
+	for _, test := range tests {
+		if got := porcelainPath(test.path, false, test.quoteSpace); got != test.expected {
+			t.Errorf("porcelainPath(%q): expected %s, got %s", test.path, test.expected, got)
+		}
+	}
+}
+
+// runGit runs git in dir, skipping the test when git is not installed
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "HOME="+dir, "GIT_CONFIG_NOSYSTEM=1")
+	out, err := cmd.Output()
+	if err != nil && !strings.HasPrefix(args[0], "merge") {
+		t.Fatalf("git %v failed: %v", args, err)
+	}
+	return string(out)
+}
+
+func assertMatchesGit(t *testing.T, dir string) {
+	t.Helper()
+	status, err := GetStatus(repository.New(dir))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	formats := []struct {
+		args []string
+		v    int
+		nul  bool
+	}{
+		{[]string{"status", "--porcelain"}, 1, false},
+		{[]string{"status", "--porcelain", "-z"}, 1, true},
+		{[]string{"status", "--porcelain=v2"}, 2, false},
+		{[]string{"status", "--porcelain=v2", "-z"}, 2, true},
+	}
+	for _, format := range formats {
+		expected := runGit(t, dir, format.args...)
+		if got := status.Porcelain(format.v, format.nul); got != expected {
+			t.Errorf("git %v:\nexpected %q\ngot      %q", format.args, expected, got)
+		}
+	}
+}
+
+func TestPorcelain_MatchesGit(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("old.txt", "moved\n")
+	write("deleted.txt", "deleted\n")
+	write("modified.txt", "modified\n")
+	write("with space.txt", "space\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+
+	runGit(t, dir, "mv", "old.txt", "new.txt")
+	runGit(t, dir, "rm", "-q", "--cached", "deleted.txt")
+	write("modified.txt", "modified\nstaged\n")
+	runGit(t, dir, "add", "modified.txt")
+	write("modified.txt", "modified\nstaged\nunstaged\n")
+	write("with space.txt", "space\nchanged\n")
+	write("untracked.txt", "untracked\n")
+	if err := os.MkdirAll(filepath.Join(dir, "build", "out"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	write("build/out/app.bin", "binary\n")
+
+	assertMatchesGit(t, dir)
+}
+
+func TestPorcelain_MatchesGitUnmerged(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("both.txt", "base\n")
+	write("deleted.txt", "base\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "base")
+
+	runGit(t, dir, "checkout", "-q", "-b", "other")
+	write("both.txt", "theirs\n")
+	write("added.txt", "theirs\n")
+	runGit(t, dir, "rm", "-q", "deleted.txt")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "theirs")
+
+	runGit(t, dir, "checkout", "-q", "main")
+	write("both.txt", "ours\n")
+	write("added.txt", "ours\n")
+	write("deleted.txt", "ours\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "ours")
+	runGit(t, dir, "merge", "-q", "other")
+
+	assertMatchesGit(t, dir)
+}
+
+func TestPorcelain_MatchesGitSubmodule(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+
+	lib := filepath.Join(dir, "lib")
+	if err := os.MkdirAll(lib, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	runGit(t, lib, "init", "-q")
+	if err := os.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib\n"), 0644); err != nil {
+		t.Fatalf("Failed to write lib.go: %v", err)
+	}
+	runGit(t, lib, "add", ".")
+	runGit(t, lib, "commit", "-q", "-m", "lib")
+	recorded := strings.TrimSpace(runGit(t, lib, "rev-parse", "HEAD"))
+
+	runGit(t, dir, "add", "lib")
+	runGit(t, dir, "commit", "-q", "-m", "add lib")
+	assertMatchesGit(t, dir)
+
+	runGit(t, lib, "commit", "-q", "--allow-empty", "-m", "next")
+	assertMatchesGit(t, dir)
+
+	status, err := GetStatus(repository.New(dir))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(status.Entries) != 1 || !status.Entries[0].Submodule {
+		t.Fatalf("Expected one submodule entry, got %+v", status.Entries)
+	}
+	entry := status.Entries[0]
+	if entry.IndexHash != recorded || entry.SubmoduleCommit == recorded {
+		t.Errorf("Expected recorded commit %s and a newer checked out one, got %s and %s", recorded, entry.IndexHash, entry.SubmoduleCommit)
+	}
+	if !strings.Contains(status.String(), "lib (new commits)") {
+		t.Errorf("Expected the long format to note new commits, got:\n%s", status.String())
+	}
+}
@@ -0,0 +1,289 @@
+// Package attributes reads .gitattributes files and resolves the attributes
+// that apply to a path
+package attributes
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	attributesFile = ".gitattributes"
+	infoAttributes = "info/attributes"
+	macroPrefix    = "[attr]"
+)
+
+// State is whether an attribute is set, unset, given a value, or not
+// mentioned at all
+type State int
+
+const (
+	Unspecified State = iota
+	Set
+	Unset
+	Valued
+)
+
+// Value is the state of one attribute for a path
+type Value struct {
+	State State
+	// Text holds the value for Valued attributes
+	Text string
+}
+
+// Attributes maps attribute names to their values for one path. Attributes
+// that no line mentions are absent.
+type Attributes map[string]Value
+
+// IsSet reports whether name is set, as in "text"
+func (a Attributes) IsSet(name string) bool {
+	return a[name].State == Set
+}
+
+// IsUnset reports whether name is unset, as in "-text"
+func (a Attributes) IsUnset(name string) bool {
+	return a[name].State == Unset
+}
+
+// Get returns the value of name when it is given one, as in "eol=crlf"
+func (a Attributes) Get(name string) (string, bool) {
+	v := a[name]
+	return v.Text, v.State == Valued
+}
+
+type assignment struct {
+	name  string
+	value Value
+}
+
+type rule struct {
+	regex       *regexp.Regexp
+	assignments []assignment
+}
+
+// file is a parsed attributes file; dir is the directory it applies to,
+// relative to the work tree with forward slashes
+type file struct {
+	dir   string
+	rules []rule
+}
+
+// Matcher resolves attributes from the .gitattributes files of a work tree,
+// reading each directory's file the first time a path below it is resolved
+type Matcher struct {
+	workDir string
+	root    *file
+	info    *file
+	macros  map[string][]assignment
+	dirs    map[string]*file
+}
+
+// Load reads the root .gitattributes of workDir and the repository's
+// info/attributes in gitDir. Missing files are treated as empty.
+func Load(workDir, gitDir string) (*Matcher, error) {
+	m := &Matcher{
+		workDir: workDir,
+		macros: map[string][]assignment{
+			"binary": {
+				{name: "diff", value: Value{State: Unset}},
+				{name: "merge", value: Value{State: Unset}},
+				{name: "text", value: Value{State: Unset}},
+			},
+		},
+		dirs: make(map[string]*file),
+	}
+
+	// macros may only be defined at the top level
+	var err error
+	if m.info, err = m.load(filepath.Join(gitDir, infoAttributes), "", true); err != nil {
+		return nil, err
+	}
+	if m.root, err = m.load(filepath.Join(workDir, attributesFile), "", true); err != nil {
+		return nil, err
+	}
+	m.dirs[""] = m.root
+
+	return m, nil
+}
+
+func (m *Matcher) load(filePath, dir string, allowMacros bool) (*file, error) {
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return &file{dir: dir}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return m.parse(f, dir, allowMacros)
+}
+
+func (m *Matcher) parse(r io.Reader, dir string, allowMacros bool) (*file, error) {
+	parsed := &file{dir: dir}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		assignments := parseAssignments(fields[1:])
+		if name, ok := strings.CutPrefix(fields[0], macroPrefix); ok {
+			if allowMacros && name != "" {
+				m.macros[name] = assignments
+			}
+			continue
+		}
+
+		// negative patterns are not allowed in attributes files
+		if strings.HasPrefix(fields[0], "!") {
+			continue
+		}
+		regex, err := regexp.Compile(patternToRegex(fields[0]))
+		if err != nil {
+			continue
+		}
+		parsed.rules = append(parsed.rules, rule{regex: regex, assignments: assignments})
+	}
+
+	return parsed, scanner.Err()
+}
+
+func parseAssignments(fields []string) []assignment {
+	assignments := make([]assignment, 0, len(fields))
+	for _, field := range fields {
+		switch {
+		case strings.HasPrefix(field, "-"):
+			assignments = append(assignments, assignment{name: field[1:], value: Value{State: Unset}})
+		case strings.HasPrefix(field, "!"):
+			assignments = append(assignments, assignment{name: field[1:], value: Value{State: Unspecified}})
+		case strings.Contains(field, "="):
+			name, text, _ := strings.Cut(field, "=")
+			assignments = append(assignments, assignment{name: name, value: Value{State: Valued, Text: text}})
+		default:
+			assignments = append(assignments, assignment{name: field, value: Value{State: Set}})
+		}
+	}
+	return assignments
+}
+
+// patternToRegex turns an attributes pattern into a regular expression
+// matched against a path relative to the file's directory. Patterns without
+// a slash match the last path component at any depth.
+func patternToRegex(pattern string) string {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var buf strings.Builder
+	if anchored {
+		buf.WriteString("^")
+	} else {
+		buf.WriteString("(^|/)")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			buf.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			buf.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(pattern[i+1:], ']'); end >= 0 {
+				class := pattern[i+1 : i+1+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				buf.WriteString("[" + class + "]")
+				i += end + 1
+			} else {
+				buf.WriteString(regexp.QuoteMeta("["))
+			}
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	buf.WriteString("$")
+	return buf.String()
+}
+
+// Resolve returns the attributes of a work tree path, given with forward
+// slashes. Deeper .gitattributes files override those above them, later
+// lines override earlier ones, and info/attributes overrides them all.
+func (m *Matcher) Resolve(filePath string) Attributes {
+	filePath = strings.TrimPrefix(path.Clean(filePath), "/")
+
+	files := []*file{m.root}
+	dir := ""
+	for _, part := range strings.Split(path.Dir(filePath), "/") {
+		if part == "." {
+			break
+		}
+		dir = path.Join(dir, part)
+		files = append(files, m.dirFile(dir))
+	}
+	files = append(files, m.info)
+
+	attrs := make(Attributes)
+	for _, f := range files {
+		rel := filePath
+		if f.dir != "" {
+			rel = strings.TrimPrefix(filePath, f.dir+"/")
+		}
+		for _, r := range f.rules {
+			if r.regex.MatchString(rel) {
+				m.apply(attrs, r.assignments, 0)
+			}
+		}
+	}
+
+	for name, v := range attrs {
+		if v.State == Unspecified {
+			delete(attrs, name)
+		}
+	}
+	return attrs
+}
+
+// maxMacroDepth bounds macro expansion so that self-referencing macros end
+const maxMacroDepth = 8
+
+func (m *Matcher) apply(attrs Attributes, assignments []assignment, depth int) {
+	for _, a := range assignments {
+		attrs[a.name] = a.value
+		if expansion, ok := m.macros[a.name]; ok && a.value.State == Set && depth < maxMacroDepth {
+			m.apply(attrs, expansion, depth+1)
+		}
+	}
+}
+
+// dirFile returns the attributes file of a subdirectory, reading it once.
+// Unreadable files are treated as empty.
+func (m *Matcher) dirFile(dir string) *file {
+	if f, ok := m.dirs[dir]; ok {
+		return f
+	}
+	f, err := m.load(filepath.Join(m.workDir, filepath.FromSlash(dir), attributesFile), dir, false)
+	if err != nil {
+		f = &file{dir: dir}
+	}
+	m.dirs[dir] = f
+	return f
+}
@@ -0,0 +1,104 @@
+package attributes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	workDir := t.TempDir()
+	gitDir := filepath.Join(workDir, ".git")
+
+	writeFile(t, filepath.Join(workDir, ".gitattributes"),
+		"*.txt text eol=lf\n"+
+			"*.txt -text\n"+
+			"docs/*.md diff\n"+
+			"/top.c export-ignore\n")
+	writeFile(t, filepath.Join(workDir, "sub", ".gitattributes"), "*.txt text\nnested/*.txt !text\n")
+	writeFile(t, filepath.Join(gitDir, "info", "attributes"), "override.txt -diff\n")
+
+	m, err := Load(workDir, gitDir)
+	require.NoError(t, err)
+
+	// later lines in the same file win
+	attrs := m.Resolve("a.txt")
+	assert.True(t, attrs.IsUnset("text"))
+	eol, ok := attrs.Get("eol")
+	assert.True(t, ok)
+	assert.Equal(t, "lf", eol)
+
+	// a deeper file overrides the root
+	assert.True(t, m.Resolve("sub/a.txt").IsSet("text"))
+
+	// ! returns an attribute to unspecified
+	_, mentioned := m.Resolve("sub/nested/a.txt")["text"]
+	assert.False(t, mentioned)
+
+	// patterns with a slash are anchored to their file's directory
+	assert.True(t, m.Resolve("docs/readme.md").IsSet("diff"))
+	assert.False(t, m.Resolve("other/docs/readme.md").IsSet("diff"))
+	assert.True(t, m.Resolve("top.c").IsSet("export-ignore"))
+	assert.False(t, m.Resolve("sub/top.c").IsSet("export-ignore"))
+
+	// info/attributes overrides everything
+	assert.True(t, m.Resolve("sub/override.txt").IsUnset("diff"))
+}
+
+func TestResolveBinaryMacro(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, ".gitattributes"),
+		"[attr]generated -diff linguist-generated\n"+
+			"*.png binary\n"+
+			"*.pb.go generated\n")
+
+	m, err := Load(workDir, filepath.Join(workDir, ".git"))
+	require.NoError(t, err)
+
+	attrs := m.Resolve("img/logo.png")
+	assert.True(t, attrs.IsSet("binary"))
+	assert.True(t, attrs.IsUnset("text"))
+	assert.True(t, attrs.IsUnset("diff"))
+	assert.True(t, attrs.IsUnset("merge"))
+
+	attrs = m.Resolve("api/service.pb.go")
+	assert.True(t, attrs.IsUnset("diff"))
+	assert.True(t, attrs.IsSet("linguist-generated"))
+
+	assert.Empty(t, m.Resolve("main.go"))
+}
+
+func TestPatternToRegex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.txt", "a/b/c.txt", true},
+		{"*.txt", "c.txt.bak", false},
+		{"/build", "build", true},
+		{"/build", "src/build", false},
+		{"**/vendor/*.go", "a/vendor/x.go", true},
+		{"**/vendor/*.go", "vendor/x.go", true},
+		{"lib/**", "lib/a/b.c", true},
+		{"file?.[ch]", "file1.c", true},
+		{"file?.[!ch]", "file1.c", false},
+	}
+	for _, tt := range tests {
+		m := Matcher{}
+		f, err := m.parse(strings.NewReader(tt.pattern+" x\n"), "", false)
+		require.NoError(t, err)
+		require.Len(t, f.rules, 1)
+		assert.Equal(t, tt.match, f.rules[0].regex.MatchString(tt.path), "%s against %s", tt.pattern, tt.path)
+	}
+}
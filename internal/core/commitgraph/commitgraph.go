@@ -0,0 +1,482 @@
+// Package commitgraph writes and reads git's commit-graph file, which keeps
+// every commit's parents, root tree, commit time and generation number in
+// one sorted table. Reachability walks use it to step from commit to parent
+// without inflating and parsing commit objects.
+package commitgraph
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	signature   = "CGPH"
+	version     = 1
+	hashVersion = 1 // SHA-1
+	hashSize    = 20
+
+	headerSize     = 8
+	chunkEntrySize = 12
+	fanoutSize     = 256 * 4
+	// a CDAT row: root tree, two parent positions, then generation and
+	// commit time packed into eight bytes
+	commitDataSize = hashSize + 16
+
+	chunkOIDFanout   = 0x4f494446 // "OIDF"
+	chunkOIDLookup   = 0x4f49444c // "OIDL"
+	chunkCommitData  = 0x43444154 // "CDAT"
+	chunkExtraEdges  = 0x45444745 // "EDGE"
+	parentNone       = 0x70000000
+	parentExtraEdges = 0x80000000
+	lastEdge         = 0x80000000
+
+	// GenerationMax is the largest generation number the file can hold;
+	// deeper commits are stored with it
+	GenerationMax = 0x3fffffff
+	// GenerationInfinity is the generation of a commit the graph does not
+	// hold, which may be anywhere in history
+	GenerationInfinity = 0xffffffff
+
+	graphDirName    = "info"
+	graphFileName   = "commit-graph"
+	tempGraphPrefix = "tmp_graph_"
+)
+
+// Commit is what the graph records about one commit
+type Commit struct {
+	Tree    string
+	Parents []string
+	// Generation is one more than the largest generation of the parents,
+	// one for a root commit, capped at GenerationMax. A commit can only
+	// reach commits of lower generation.
+	Generation uint32
+	// CommitTime is the committer date in seconds since the epoch
+	CommitTime int64
+}
+
+// Graph is a parsed commit-graph file. A nil Graph holds no commits.
+type Graph struct {
+	data   []byte
+	count  uint32
+	fanout []byte
+	oids   []byte
+	cdat   []byte
+	edges  []byte
+}
+
+// Path returns where repo keeps its commit-graph
+func Path(repo *repository.Repository) string {
+	return filepath.Join(repo.GitDir, "objects", graphDirName, graphFileName)
+}
+
+// Load reads the repository's commit-graph. A repository without one
+// yields a nil Graph and no error.
+func Load(repo *repository.Repository) (*Graph, error) {
+	data, err := os.ReadFile(Path(repo))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewGitError("commit-graph", graphFileName, err)
+	}
+	graph, err := Parse(data)
+	if err != nil {
+		return nil, errors.NewGitError("commit-graph", graphFileName, err)
+	}
+	return graph, nil
+}
+
+// Parse reads a commit-graph from data, checking its header and that its
+// chunks are where and as large as they claim. It does not verify the
+// trailing checksum; Verify does.
+func Parse(data []byte) (*Graph, error) {
+	if len(data) < headerSize+chunkEntrySize+hashSize {
+		return nil, fmt.Errorf("%w: commit-graph too short", errors.ErrCorruptedRepository)
+	}
+	if string(data[:4]) != signature {
+		return nil, fmt.Errorf("%w: bad commit-graph signature %q", errors.ErrCorruptedRepository, data[:4])
+	}
+	if data[4] != version || data[5] != hashVersion {
+		return nil, fmt.Errorf("%w: unsupported commit-graph version %d, hash version %d", errors.ErrCorruptedRepository, data[4], data[5])
+	}
+	if data[7] != 0 {
+		return nil, fmt.Errorf("%w: split commit-graphs are not supported", errors.ErrCorruptedRepository)
+	}
+
+	numChunks := int(data[6])
+	tableEnd := headerSize + (numChunks+1)*chunkEntrySize
+	trailer := len(data) - hashSize
+	if tableEnd > trailer {
+		return nil, fmt.Errorf("%w: commit-graph chunk table truncated", errors.ErrCorruptedRepository)
+	}
+
+	graph := &Graph{data: data}
+	for i := 0; i < numChunks; i++ {
+		entry := data[headerSize+i*chunkEntrySize:]
+		id := binary.BigEndian.Uint32(entry)
+		start := binary.BigEndian.Uint64(entry[4:])
+		end := binary.BigEndian.Uint64(entry[4+chunkEntrySize:])
+		if start < uint64(tableEnd) || end < start || end > uint64(trailer) {
+			return nil, fmt.Errorf("%w: commit-graph chunk %08x out of bounds", errors.ErrCorruptedRepository, id)
+		}
+		chunk := data[start:end]
+
+		switch id {
+		case chunkOIDFanout:
+			graph.fanout = chunk
+		case chunkOIDLookup:
+			graph.oids = chunk
+		case chunkCommitData:
+			graph.cdat = chunk
+		case chunkExtraEdges:
+			graph.edges = chunk
+		}
+	}
+
+	if len(graph.fanout) != fanoutSize {
+		return nil, fmt.Errorf("%w: commit-graph fanout chunk missing or malformed", errors.ErrCorruptedRepository)
+	}
+	graph.count = binary.BigEndian.Uint32(graph.fanout[fanoutSize-4:])
+	if uint64(len(graph.oids)) != uint64(graph.count)*hashSize {
+		return nil, fmt.Errorf("%w: commit-graph lookup chunk does not match fanout", errors.ErrCorruptedRepository)
+	}
+	if uint64(len(graph.cdat)) != uint64(graph.count)*commitDataSize {
+		return nil, fmt.Errorf("%w: commit-graph commit data chunk does not match fanout", errors.ErrCorruptedRepository)
+	}
+	if len(graph.edges)%4 != 0 {
+		return nil, fmt.Errorf("%w: commit-graph extra edges chunk malformed", errors.ErrCorruptedRepository)
+	}
+
+	return graph, nil
+}
+
+// Verify checks that data ends with the SHA-1 of everything before it
+func Verify(data []byte) error {
+	if len(data) < hashSize {
+		return errors.ErrCorruptedRepository
+	}
+	trailer := len(data) - hashSize
+	if sum := sha1.Sum(data[:trailer]); !bytes.Equal(sum[:], data[trailer:]) {
+		return fmt.Errorf("%w: commit-graph checksum mismatch", errors.ErrCorruptedRepository)
+	}
+	return nil
+}
+
+// Len returns the number of commits in the graph
+func (g *Graph) Len() int {
+	if g == nil {
+		return 0
+	}
+	return int(g.count)
+}
+
+// Lookup returns what the graph records about a commit, and false when the
+// graph does not hold it
+func (g *Graph) Lookup(hashStr string) (*Commit, bool) {
+	pos, ok := g.position(hashStr)
+	if !ok {
+		return nil, false
+	}
+	commit, err := g.commitAt(pos)
+	if err != nil {
+		return nil, false
+	}
+	return commit, true
+}
+
+// position finds a commit's row with the fanout and a binary search
+func (g *Graph) position(hashStr string) (uint32, bool) {
+	if g == nil {
+		return 0, false
+	}
+	raw, err := hex.DecodeString(hashStr)
+	if err != nil || len(raw) != hashSize {
+		return 0, false
+	}
+
+	lo := uint32(0)
+	if raw[0] > 0 {
+		lo = binary.BigEndian.Uint32(g.fanout[(int(raw[0])-1)*4:])
+	}
+	hi := binary.BigEndian.Uint32(g.fanout[int(raw[0])*4:])
+	if hi > g.count || lo > hi {
+		return 0, false
+	}
+
+	i := sort.Search(int(hi-lo), func(i int) bool {
+		return bytes.Compare(g.oidAt(lo+uint32(i)), raw) >= 0
+	})
+	pos := lo + uint32(i)
+	if pos < hi && bytes.Equal(g.oidAt(pos), raw) {
+		return pos, true
+	}
+	return 0, false
+}
+
+func (g *Graph) oidAt(pos uint32) []byte {
+	return g.oids[pos*hashSize : (pos+1)*hashSize]
+}
+
+func (g *Graph) commitAt(pos uint32) (*Commit, error) {
+	row := g.cdat[pos*commitDataSize : (pos+1)*commitDataSize]
+	commit := &Commit{Tree: hex.EncodeToString(row[:hashSize])}
+
+	parent1 := binary.BigEndian.Uint32(row[hashSize:])
+	parent2 := binary.BigEndian.Uint32(row[hashSize+4:])
+	genAndTimeHigh := binary.BigEndian.Uint32(row[hashSize+8:])
+	timeLow := binary.BigEndian.Uint32(row[hashSize+12:])
+
+	commit.Generation = genAndTimeHigh >> 2
+	commit.CommitTime = int64(genAndTimeHigh&0x3)<<32 | int64(timeLow)
+
+	if parent1 != parentNone {
+		if err := g.appendParent(commit, parent1); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case parent2 == parentNone:
+	case parent2&parentExtraEdges != 0:
+		for edge := parent2 &^ parentExtraEdges; ; edge++ {
+			if uint64(edge)*4+4 > uint64(len(g.edges)) {
+				return nil, fmt.Errorf("%w: commit-graph extra edge out of range", errors.ErrCorruptedRepository)
+			}
+			value := binary.BigEndian.Uint32(g.edges[edge*4:])
+			if err := g.appendParent(commit, value&^lastEdge); err != nil {
+				return nil, err
+			}
+			if value&lastEdge != 0 {
+				break
+			}
+		}
+	default:
+		if err := g.appendParent(commit, parent2); err != nil {
+			return nil, err
+		}
+	}
+
+	return commit, nil
+}
+
+func (g *Graph) appendParent(commit *Commit, pos uint32) error {
+	if pos >= g.count {
+		return fmt.Errorf("%w: commit-graph parent position %d out of range", errors.ErrCorruptedRepository, pos)
+	}
+	commit.Parents = append(commit.Parents, hex.EncodeToString(g.oidAt(pos)))
+	return nil
+}
+
+// Write builds a commit-graph of every commit in the object store and
+// replaces the repository's graph with it, returning the number of commits
+// written. Every parent of a stored commit must be stored too.
+func Write(repo *repository.Repository) (int, error) {
+	if !repo.Exists() {
+		return 0, errors.ErrNotGitRepository
+	}
+
+	var hashes []string
+	err := repo.ForEachObject(func(h string, typ objects.ObjectType) error {
+		if typ == objects.ObjectTypeCommit {
+			hashes = append(hashes, h)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.NewGitError("commit-graph", "", err)
+	}
+	sort.Strings(hashes)
+
+	positions := make(map[string]uint32, len(hashes))
+	for i, h := range hashes {
+		positions[h] = uint32(i)
+	}
+
+	commits := make([]*objects.Commit, len(hashes))
+	for i, h := range hashes {
+		commit, err := repo.LoadCommit(h)
+		if err != nil {
+			return 0, errors.NewObjectError(h, "commit", err)
+		}
+		for _, parent := range commit.Parents() {
+			if _, ok := positions[parent]; !ok {
+				return 0, errors.NewObjectError(parent, "commit",
+					fmt.Errorf("%w, parent of %s", errors.ErrObjectNotFound, h))
+			}
+		}
+		commits[i] = commit
+	}
+
+	data, err := encode(hashes, commits, positions, generations(commits, positions))
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFile(Path(repo), data); err != nil {
+		return 0, errors.NewGitError("commit-graph", graphFileName, err)
+	}
+	return len(hashes), nil
+}
+
+// generations numbers every commit one more than its highest parent,
+// walking parents before children without recursion so that deep histories
+// do not exhaust the stack
+func generations(commits []*objects.Commit, positions map[string]uint32) []uint32 {
+	gens := make([]uint32, len(commits))
+	for start := range commits {
+		if gens[start] != 0 {
+			continue
+		}
+		stack := []uint32{uint32(start)}
+		for len(stack) > 0 {
+			pos := stack[len(stack)-1]
+			if gens[pos] != 0 {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			gen, ready := uint32(1), true
+			for _, parent := range commits[pos].Parents() {
+				parentPos := positions[parent]
+				if gens[parentPos] == 0 {
+					stack = append(stack, parentPos)
+					ready = false
+					continue
+				}
+				gen = max(gen, min(gens[parentPos]+1, GenerationMax))
+			}
+			if ready {
+				gens[pos] = gen
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return gens
+}
+
+// encode lays out the header, chunk table, OIDF, OIDL, CDAT and, when a
+// commit has more than two parents, EDGE chunks, followed by the checksum
+func encode(hashes []string, commits []*objects.Commit, positions map[string]uint32, gens []uint32) ([]byte, error) {
+	var fanout [fanoutSize]byte
+	var oids, cdat, edges bytes.Buffer
+	var counts [256]uint32
+
+	for i, h := range hashes {
+		raw, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, errors.NewObjectError(h, "commit", errors.ErrInvalidHash)
+		}
+		counts[raw[0]]++
+		oids.Write(raw)
+
+		commit := commits[i]
+		tree, err := hex.DecodeString(commit.Tree())
+		if err != nil || len(tree) != hashSize {
+			return nil, errors.NewObjectError(h, "commit", errors.ErrInvalidCommit)
+		}
+		cdat.Write(tree)
+
+		parents := commit.Parents()
+		parent1, parent2 := uint32(parentNone), uint32(parentNone)
+		if len(parents) > 0 {
+			parent1 = positions[parents[0]]
+		}
+		switch {
+		case len(parents) == 2:
+			parent2 = positions[parents[1]]
+		case len(parents) > 2:
+			parent2 = parentExtraEdges | uint32(edges.Len()/4)
+			for j, parent := range parents[1:] {
+				value := positions[parent]
+				if j == len(parents)-2 {
+					value |= lastEdge
+				}
+				binary.Write(&edges, binary.BigEndian, value)
+			}
+		}
+
+		commitTime := uint64(commit.Committer().When.Unix())
+		binary.Write(&cdat, binary.BigEndian, parent1)
+		binary.Write(&cdat, binary.BigEndian, parent2)
+		binary.Write(&cdat, binary.BigEndian, gens[i]<<2|uint32(commitTime>>32)&0x3)
+		binary.Write(&cdat, binary.BigEndian, uint32(commitTime))
+	}
+
+	total := uint32(0)
+	for i, n := range counts {
+		total += n
+		binary.BigEndian.PutUint32(fanout[i*4:], total)
+	}
+
+	type chunk struct {
+		id   uint32
+		data []byte
+	}
+	chunks := []chunk{
+		{chunkOIDFanout, fanout[:]},
+		{chunkOIDLookup, oids.Bytes()},
+		{chunkCommitData, cdat.Bytes()},
+	}
+	if edges.Len() > 0 {
+		chunks = append(chunks, chunk{chunkExtraEdges, edges.Bytes()})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(signature)
+	buf.Write([]byte{version, hashVersion, byte(len(chunks)), 0})
+
+	offset := uint64(headerSize + (len(chunks)+1)*chunkEntrySize)
+	for _, c := range chunks {
+		binary.Write(&buf, binary.BigEndian, c.id)
+		binary.Write(&buf, binary.BigEndian, offset)
+		offset += uint64(len(c.data))
+	}
+	binary.Write(&buf, binary.BigEndian, uint32(0))
+	binary.Write(&buf, binary.BigEndian, offset)
+
+	for _, c := range chunks {
+		buf.Write(c.data)
+	}
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes(), nil
+}
+
+// writeFile replaces path with data through a temporary file, so that
+// readers never see a half-written graph
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempGraphPrefix)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0444); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package commitgraph
+
+import (
+	stderrors "errors"
+	"os"
+	"os/exec"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+type history struct {
+	t    *testing.T
+	repo *repository.Repository
+	tree string
+	tick int64
+}
+
+func newHistory(t *testing.T) *history {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	tree, err := repo.StoreObject(objects.NewTree(nil))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	return &history{t: t, repo: repo, tree: tree}
+}
+
+func (h *history) commit(message string, parents ...string) string {
+	h.t.Helper()
+	h.tick++
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000+h.tick, 0)}
+	hash, err := h.repo.StoreObject(objects.NewCommit(h.tree, parents, sig, sig, message))
+	if err != nil {
+		h.t.Fatalf("Failed to store commit: %v", err)
+	}
+	return hash
+}
+
+func TestWriteAndLookup(t *testing.T) {
+	h := newHistory(t)
+	root := h.commit("root")
+	a := h.commit("a", root)
+	b := h.commit("b", root)
+	c := h.commit("c", a)
+	octopus := h.commit("octopus", c, b, a)
+	merge := h.commit("merge", octopus, b)
+
+	count, err := Write(h.repo)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("Expected 6 commits written, got %d", count)
+	}
+
+	graph, err := Load(h.repo)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if graph.Len() != 6 {
+		t.Errorf("Expected a graph of 6 commits, got %d", graph.Len())
+	}
+
+	tests := []struct {
+		hash       string
+		parents    []string
+		generation uint32
+		tick       int64
+	}{
+		{root, nil, 1, 1},
+		{a, []string{root}, 2, 2},
+		{b, []string{root}, 2, 3},
+		{c, []string{a}, 3, 4},
+		{octopus, []string{c, b, a}, 4, 5},
+		{merge, []string{octopus, b}, 5, 6},
+	}
+	for _, tt := range tests {
+		commit, ok := graph.Lookup(tt.hash)
+		if !ok {
+			t.Errorf("Expected %s in the graph", tt.hash)
+			continue
+		}
+		if !slices.Equal(commit.Parents, tt.parents) {
+			t.Errorf("%s: expected parents %v, got %v", tt.hash, tt.parents, commit.Parents)
+		}
+		if commit.Generation != tt.generation {
+			t.Errorf("%s: expected generation %d, got %d", tt.hash, tt.generation, commit.Generation)
+		}
+		if commit.CommitTime != 1700000000+tt.tick {
+			t.Errorf("%s: expected commit time %d, got %d", tt.hash, 1700000000+tt.tick, commit.CommitTime)
+		}
+		if commit.Tree != h.tree {
+			t.Errorf("%s: expected tree %s, got %s", tt.hash, h.tree, commit.Tree)
+		}
+	}
+
+	if _, ok := graph.Lookup(h.tree); ok {
+		t.Error("Expected a tree not to be found")
+	}
+	if _, ok := graph.Lookup("not a hash"); ok {
+		t.Error("Expected an invalid hash not to be found")
+	}
+
+	data, err := os.ReadFile(Path(h.repo))
+	if err != nil {
+		t.Fatalf("Failed to read graph: %v", err)
+	}
+	if err := Verify(data); err != nil {
+		t.Errorf("Expected a valid checksum, got %v", err)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	h := newHistory(t)
+
+	graph, err := Load(h.repo)
+	if err != nil || graph != nil {
+		t.Fatalf("Expected no graph and no error, got %v, %v", graph, err)
+	}
+	if _, ok := graph.Lookup(h.tree); ok {
+		t.Error("Expected a nil graph to hold nothing")
+	}
+}
+
+func TestWrite_MissingParent(t *testing.T) {
+	h := newHistory(t)
+	h.commit("orphaned", "0123456789abcdef0123456789abcdef01234567")
+
+	if _, err := Write(h.repo); !stderrors.Is(err, errors.ErrObjectNotFound) {
+		t.Errorf("Expected a missing parent to be reported, got %v", err)
+	}
+}
+
+func TestParse_Corrupt(t *testing.T) {
+	h := newHistory(t)
+	h.commit("root")
+	if _, err := Write(h.repo); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	data, err := os.ReadFile(Path(h.repo))
+	if err != nil {
+		t.Fatalf("Failed to read graph: %v", err)
+	}
+
+	badSignature := slices.Clone(data)
+	badSignature[0] = 'X'
+	truncated := data[:len(data)/2]
+	badChunk := slices.Clone(data)
+	// point the OIDF chunk past the end of the file
+	badChunk[headerSize+4] = 0xff
+
+	for name, corrupt := range map[string][]byte{"signature": badSignature, "truncated": truncated, "chunk": badChunk} {
+		if _, err := Parse(corrupt); !stderrors.Is(err, errors.ErrCorruptedRepository) {
+			t.Errorf("%s: expected ErrCorruptedRepository, got %v", name, err)
+		}
+	}
+
+	flipped := slices.Clone(data)
+	flipped[len(flipped)-hashSize-1] ^= 0xff
+	if err := Verify(flipped); !stderrors.Is(err, errors.ErrCorruptedRepository) {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+}
+
+// TestWrite_MatchesGit has git verify a graph written here and checks that
+// one written by git reads back the same
+func TestWrite_MatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	h := newHistory(t)
+	root := h.commit("root")
+	a := h.commit("a", root)
+	b := h.commit("b", root)
+	c := h.commit("c", a, b)
+	tip := h.commit("octopus", c, a, b)
+
+	if _, err := Write(h.repo); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	ours, err := Load(h.repo)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"--git-dir", h.repo.GitDir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	git("commit-graph", "verify")
+
+	if err := os.Remove(Path(h.repo)); err != nil {
+		t.Fatalf("Failed to remove graph: %v", err)
+	}
+	if err := h.repo.UpdateRef("refs/heads/main", tip); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	git("commit-graph", "write", "--reachable")
+	theirs, err := Load(h.repo)
+	if err != nil {
+		t.Fatalf("Failed to load git's graph: %v", err)
+	}
+
+	for _, hash := range []string{root, a, b, c, tip} {
+		want, _ := ours.Lookup(hash)
+		got, ok := theirs.Lookup(hash)
+		if !ok {
+			t.Errorf("Expected %s in git's graph", hash)
+			continue
+		}
+		if !slices.Equal(got.Parents, want.Parents) || got.Generation != want.Generation ||
+			got.CommitTime != want.CommitTime || got.Tree != want.Tree {
+			t.Errorf("%s: git wrote %+v, we wrote %+v", hash, got, want)
+		}
+	}
+}
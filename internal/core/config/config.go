@@ -0,0 +1,460 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const configFileMode = 0644
+
+// Config is a parsed git config file. Sections keep their lines as read so
+// the file can be written back without disturbing comments or layout.
+type Config struct {
+	path     string
+	sections []*section
+}
+
+type section struct {
+	name       string
+	subsection string
+	// header is the raw "[...]" line; the leading section holding lines before
+	// any header has none
+	header string
+	lines  []*line
+}
+
+// line is a key/value entry, or a blank or comment line when key is empty
+type line struct {
+	key   string
+	value string
+	raw   string
+}
+
+// Load reads the config file at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.NewGitError("config", path, err)
+	}
+
+	cfg, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.NewGitError("config", path, err)
+	}
+	cfg.path = path
+
+	return cfg, nil
+}
+
+// Parse reads the git config format: "[section]" or "[section \"sub\"]"
+// headers followed by "key = value" lines. Section names and keys are
+// case-insensitive; subsections are not.
+func Parse(r io.Reader) (*Config, error) {
+	cfg := &Config{}
+	current := &section{}
+	cfg.sections = append(cfg.sections, current)
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := scanner.Text()
+		trimmed := strings.TrimSpace(raw)
+
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' {
+			current.lines = append(current.lines, &line{raw: raw})
+			continue
+		}
+
+		if trimmed[0] == '[' {
+			name, subsection, err := parseHeader(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", errors.ErrInvalidConfig, lineNo, err)
+			}
+			current = &section{name: name, subsection: subsection, header: raw}
+			cfg.sections = append(cfg.sections, current)
+			continue
+		}
+
+		// a trailing backslash continues the value on the next line
+		for strings.HasSuffix(trimmed, "\\") && !strings.HasSuffix(trimmed, "\\\\") && scanner.Scan() {
+			lineNo++
+			next := scanner.Text()
+			raw += "\n" + next
+			trimmed = trimmed[:len(trimmed)-1] + next
+		}
+
+		key, value, err := parseEntry(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", errors.ErrInvalidConfig, lineNo, err)
+		}
+		if current.header == "" {
+			return nil, fmt.Errorf("%w: line %d: key %q outside of a section", errors.ErrInvalidConfig, lineNo, key)
+		}
+		current.lines = append(current.lines, &line{key: key, value: value, raw: raw})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Get returns the last value of section.subsection.key, as Git does when a
+// key is set more than once
+func (c *Config) Get(sectionName, subsection, key string) (string, bool) {
+	values := c.GetAll(sectionName, subsection, key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// GetAll returns every value of a multi-valued key in file order
+func (c *Config) GetAll(sectionName, subsection, key string) []string {
+	sectionName, key = strings.ToLower(sectionName), strings.ToLower(key)
+
+	var values []string
+	for _, s := range c.sections {
+		if s.name != sectionName || s.subsection != subsection {
+			continue
+		}
+		for _, l := range s.lines {
+			if l.key == key {
+				values = append(values, l.value)
+			}
+		}
+	}
+	return values
+}
+
+// Subsections returns the distinct subsections of a section in file order
+func (c *Config) Subsections(sectionName string) []string {
+	sectionName = strings.ToLower(sectionName)
+
+	seen := make(map[string]bool)
+	var subsections []string
+	for _, s := range c.sections {
+		if s.name == sectionName && s.subsection != "" && !seen[s.subsection] {
+			seen[s.subsection] = true
+			subsections = append(subsections, s.subsection)
+		}
+	}
+	return subsections
+}
+
+// Set gives key a single value, replacing the first existing value in place
+// and dropping any others. A missing section is appended to the file.
+func (c *Config) Set(sectionName, subsection, key, value string) {
+	sectionName, key = strings.ToLower(sectionName), strings.ToLower(key)
+
+	replaced := false
+	for _, s := range c.sections {
+		if s.name != sectionName || s.subsection != subsection {
+			continue
+		}
+		kept := s.lines[:0]
+		for _, l := range s.lines {
+			if l.key == key {
+				if replaced {
+					continue
+				}
+				// an unchanged value keeps its original formatting
+				if l.value != value {
+					l.value, l.raw = value, ""
+				}
+				replaced = true
+			}
+			kept = append(kept, l)
+		}
+		s.lines = kept
+	}
+
+	if !replaced {
+		c.Add(sectionName, subsection, key, value)
+	}
+}
+
+// Add appends another value to a multi-valued key such as remote.<name>.fetch
+func (c *Config) Add(sectionName, subsection, key, value string) {
+	sectionName, key = strings.ToLower(sectionName), strings.ToLower(key)
+
+	var target *section
+	for _, s := range c.sections {
+		if s.name == sectionName && s.subsection == subsection {
+			target = s
+		}
+	}
+	if target == nil {
+		target = &section{name: sectionName, subsection: subsection}
+		c.sections = append(c.sections, target)
+	}
+
+	// keep trailing blank lines and comments after the new entry
+	at := len(target.lines)
+	for at > 0 && target.lines[at-1].key == "" {
+		at--
+	}
+	target.lines = append(target.lines, nil)
+	copy(target.lines[at+1:], target.lines[at:])
+	target.lines[at] = &line{key: key, value: value}
+}
+
+// Unset removes every value of key, reporting whether any existed
+func (c *Config) Unset(sectionName, subsection, key string) bool {
+	sectionName, key = strings.ToLower(sectionName), strings.ToLower(key)
+
+	removed := false
+	for _, s := range c.sections {
+		if s.name != sectionName || s.subsection != subsection {
+			continue
+		}
+		kept := s.lines[:0]
+		for _, l := range s.lines {
+			if l.key == key {
+				removed = true
+				continue
+			}
+			kept = append(kept, l)
+		}
+		s.lines = kept
+	}
+	return removed
+}
+
+// RemoveSection drops every section.subsection block along with its lines
+func (c *Config) RemoveSection(sectionName, subsection string) bool {
+	sectionName = strings.ToLower(sectionName)
+
+	removed := false
+	kept := c.sections[:0]
+	for _, s := range c.sections {
+		if s.name == sectionName && s.subsection == subsection {
+			removed = true
+			continue
+		}
+		kept = append(kept, s)
+	}
+	c.sections = kept
+	return removed
+}
+
+// RenameSection moves every section.oldSub block to section.newSub
+func (c *Config) RenameSection(sectionName, oldSub, newSub string) bool {
+	sectionName = strings.ToLower(sectionName)
+
+	renamed := false
+	for _, s := range c.sections {
+		if s.name == sectionName && s.subsection == oldSub {
+			s.subsection, s.header = newSub, ""
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// Save writes the config back to the file it was loaded from. Unchanged lines
+// are written exactly as they were read.
+func (c *Config) Save() error {
+	if c.path == "" {
+		return errors.NewGitError("config", "", fmt.Errorf("config was not loaded from a file"))
+	}
+
+	if err := os.WriteFile(c.path, c.Bytes(), configFileMode); err != nil {
+		return errors.NewGitError("config", c.path, err)
+	}
+	return nil
+}
+
+// Bytes serializes the config in the git config format
+func (c *Config) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, s := range c.sections {
+		if s.name != "" {
+			header := s.header
+			if header == "" {
+				header = formatHeader(s.name, s.subsection)
+			}
+			buf.WriteString(header)
+			buf.WriteByte('\n')
+		}
+
+		for _, l := range s.lines {
+			raw := l.raw
+			if raw == "" && l.key != "" {
+				raw = "\t" + l.key + " = " + formatValue(l.value)
+			}
+			buf.WriteString(raw)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func formatHeader(name, subsection string) string {
+	if subsection == "" {
+		return "[" + name + "]"
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(subsection)
+	return fmt.Sprintf("[%s \"%s\"]", name, escaped)
+}
+
+// formatValue escapes a value and quotes it when whitespace at either end or
+// a comment character would otherwise be lost
+func formatValue(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\b", `\b`).Replace(value)
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;") {
+		return `"` + escaped + `"`
+	}
+	return escaped
+}
+
+// parseHeader splits "[name]", "[name \"sub\"]" or the legacy "[name.sub]"
+func parseHeader(header string) (string, string, error) {
+	end := strings.LastIndexByte(header, ']')
+	if end == -1 {
+		return "", "", fmt.Errorf("unterminated section header %q", header)
+	}
+	if rest := strings.TrimSpace(header[end+1:]); rest != "" && rest[0] != '#' && rest[0] != ';' {
+		return "", "", fmt.Errorf("unexpected content after section header %q", header)
+	}
+	inner := header[1:end]
+
+	name, quoted, hasSub := strings.Cut(inner, " ")
+	if !hasSub {
+		name, sub, legacy := strings.Cut(inner, ".")
+		if !validName(name, true) {
+			return "", "", fmt.Errorf("invalid section name %q", name)
+		}
+		if legacy {
+			return strings.ToLower(name), strings.ToLower(sub), nil
+		}
+		return strings.ToLower(name), "", nil
+	}
+
+	if !validName(name, true) {
+		return "", "", fmt.Errorf("invalid section name %q", name)
+	}
+
+	quoted = strings.TrimSpace(quoted)
+	if len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+		return "", "", fmt.Errorf("invalid subsection in %q", header)
+	}
+
+	var sub strings.Builder
+	body := quoted[1 : len(quoted)-1]
+	for i := 0; i < len(body); i++ {
+		if body[i] == '\\' && i+1 < len(body) {
+			i++
+		}
+		sub.WriteByte(body[i])
+	}
+
+	return strings.ToLower(name), sub.String(), nil
+}
+
+// parseEntry splits "key = value". A bare key is a boolean set to true.
+func parseEntry(entry string) (string, string, error) {
+	key, rawValue, hasValue := strings.Cut(entry, "=")
+	if !hasValue {
+		if i := strings.IndexAny(key, "#;"); i != -1 {
+			key = key[:i]
+		}
+	}
+	key = strings.TrimSpace(key)
+	if !validName(key, false) {
+		return "", "", fmt.Errorf("invalid key %q", key)
+	}
+	key = strings.ToLower(key)
+
+	if !hasValue {
+		return key, "true", nil
+	}
+
+	value, err := parseValue(rawValue)
+	if err != nil {
+		return "", "", fmt.Errorf("key %q: %v", key, err)
+	}
+	return key, value, nil
+}
+
+// parseValue unquotes a value, resolving escapes, dropping trailing comments
+// and trimming whitespace outside of quotes
+func parseValue(raw string) (string, error) {
+	var value strings.Builder
+	var space strings.Builder
+	inQuote := false
+
+scan:
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '\\':
+			if i+1 == len(raw) {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			i++
+			var unescaped byte
+			switch raw[i] {
+			case 'n':
+				unescaped = '\n'
+			case 't':
+				unescaped = '\t'
+			case 'b':
+				unescaped = '\b'
+			case '\\', '"':
+				unescaped = raw[i]
+			default:
+				return "", fmt.Errorf("invalid escape \\%c", raw[i])
+			}
+			value.WriteString(space.String())
+			space.Reset()
+			value.WriteByte(unescaped)
+		case c == '"':
+			inQuote = !inQuote
+		case !inQuote && (c == '#' || c == ';'):
+			break scan
+		case !inQuote && (c == ' ' || c == '\t'):
+			if value.Len() > 0 {
+				space.WriteByte(c)
+			}
+		default:
+			value.WriteString(space.String())
+			space.Reset()
+			value.WriteByte(c)
+		}
+	}
+
+	if inQuote {
+		return "", fmt.Errorf("unterminated quote")
+	}
+	return value.String(), nil
+}
+
+// validName reports whether s is a valid section name or key: alphanumerics
+// and '-', with keys also required to start with a letter
+func validName(s string, isSection bool) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9', c == '-':
+			if !isSection && i == 0 {
+				return false
+			}
+		case c == '.' && isSection:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const sample = `# leading comment
+[core]
+	bare = false
+	FileMode = true
+[user]
+	name = "Jane  Doe" ; trailing comment
+	email = jane@example.com # another
+[remote "origin"]
+	url = https://example.com/repo.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[branch.Main]
+	remote = origin
+[alias]
+	lg = log \
+--oneline
+	quoted = "say \"hi\"\tnow"
+	flag
+`
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		section, subsection, key string
+		want                     string
+	}{
+		{"core", "", "bare", "false"},
+		{"CORE", "", "filemode", "true"},
+		{"user", "", "name", "Jane  Doe"},
+		{"user", "", "email", "jane@example.com"},
+		{"remote", "origin", "url", "https://example.com/repo.git"},
+		{"remote", "origin", "fetch", "+refs/tags/*:refs/tags/*"},
+		{"branch", "main", "remote", "origin"},
+		{"alias", "", "lg", "log --oneline"},
+		{"alias", "", "quoted", "say \"hi\"\tnow"},
+		{"alias", "", "flag", "true"},
+	}
+	for _, tt := range tests {
+		got, ok := cfg.Get(tt.section, tt.subsection, tt.key)
+		if !ok || got != tt.want {
+			t.Errorf("Get(%s, %s, %s) = %q, %v; want %q", tt.section, tt.subsection, tt.key, got, ok, tt.want)
+		}
+	}
+
+	if fetch := cfg.GetAll("remote", "origin", "fetch"); len(fetch) != 2 || fetch[0] != "+refs/heads/*:refs/remotes/origin/*" {
+		t.Errorf("Unexpected fetch values %v", fetch)
+	}
+
+	if _, ok := cfg.Get("remote", "Origin", "url"); ok {
+		t.Error("Expected subsections to be case-sensitive")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"key = value\n",
+		"[core\n",
+		"[remote \"origin]\n",
+		"[core]\n\t1key = x\n",
+		"[core]\n\tkey = \"unterminated\n",
+	} {
+		if _, err := Parse(strings.NewReader(input)); !stderrors.Is(err, errors.ErrInvalidConfig) {
+			t.Errorf("Parse(%q): expected ErrInvalidConfig, got %v", input, err)
+		}
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "config"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := cfg.Get("user", "", "name"); ok {
+		t.Error("Expected an empty config")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(sample), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if name, _ := cfg.Get("user", "", "name"); name != "Jane  Doe" {
+		t.Errorf("Expected user.name from file, got %q", name)
+	}
+}
+
+func TestSetUnsetSave_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	original := "# keep me\n[core]\n\tbare = false ; comment\n\n[branch \"main\"]\n\tremote = origin\n\tmerge = refs/heads/main\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != original {
+		t.Fatalf("Expected unchanged config to round-trip, got %q", data)
+	}
+
+	cfg.Set("branch", "main", "remote", "upstream")
+	cfg.Set("branch", "feature", "remote", "origin")
+	cfg.Set("user", "", "name", " padded ")
+	cfg.Add("remote", "origin", "fetch", "+refs/heads/*:refs/remotes/origin/*")
+	cfg.Add("remote", "origin", "fetch", "+refs/tags/*:refs/tags/*")
+	if !cfg.Unset("core", "", "bare") {
+		t.Error("Expected core.bare to be unset")
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	expected := "# keep me\n[core]\n\n[branch \"main\"]\n\tremote = upstream\n\tmerge = refs/heads/main\n" +
+		"[branch \"feature\"]\n\tremote = origin\n[user]\n\tname = \" padded \"\n" +
+		"[remote \"origin\"]\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n\tfetch = +refs/tags/*:refs/tags/*\n"
+	if data, _ := os.ReadFile(path); string(data) != expected {
+		t.Errorf("Unexpected config:\n%s\nwant:\n%s", data, expected)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if name, _ := reloaded.Get("user", "", "name"); name != " padded " {
+		t.Errorf("Expected quoted value to survive, got %q", name)
+	}
+	if fetch := reloaded.GetAll("remote", "origin", "fetch"); len(fetch) != 2 {
+		t.Errorf("Expected two fetch refspecs, got %v", fetch)
+	}
+}
+
+func TestSet_ReplacesMultipleValues(t *testing.T) {
+	cfg, err := Parse(strings.NewReader("[remote \"origin\"]\n\tfetch = a\n\turl = u\n\tfetch = b\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	cfg.Set("remote", "origin", "fetch", "c")
+	if got := string(cfg.Bytes()); got != "[remote \"origin\"]\n\tfetch = c\n\turl = u\n" {
+		t.Errorf("Unexpected config %q", got)
+	}
+
+	if !cfg.RemoveSection("remote", "origin") || len(cfg.Subsections("remote")) != 0 {
+		t.Error("Expected the remote section to be removed")
+	}
+}
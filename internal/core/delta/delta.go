@@ -0,0 +1,97 @@
+// Package delta applies git's binary deltas, as stored in OFS_DELTA and
+// REF_DELTA pack entries
+package delta
+
+import "fmt"
+
+// Apply rebuilds an object from its base and a delta: two varint sizes
+// followed by copy and insert instructions
+func Apply(baseData, deltaData []byte) ([]byte, error) {
+	if len(deltaData) == 0 {
+		return nil, fmt.Errorf("empty delta data")
+	}
+
+	offset := 0
+
+	baseSize, offset := ReadSize(deltaData, offset)
+	if baseSize != int64(len(baseData)) {
+		return nil, fmt.Errorf("base size mismatch: expected %d, got %d", len(baseData), baseSize)
+	}
+
+	resultSize, offset := ReadSize(deltaData, offset)
+
+	result := make([]byte, 0, resultSize)
+	for offset < len(deltaData) {
+		instruction := deltaData[offset]
+		offset++
+
+		if instruction&0x80 != 0 {
+			// Copy instruction: bits 0-3 say which offset bytes follow,
+			// bits 4-6 which size bytes
+			var fields [7]int64
+			for bit := range fields {
+				if instruction&(1<<bit) == 0 {
+					continue
+				}
+				if offset >= len(deltaData) {
+					return nil, fmt.Errorf("copy instruction extends beyond delta data")
+				}
+				fields[bit] = int64(deltaData[offset])
+				offset++
+			}
+			copyOffset := fields[0] | fields[1]<<8 | fields[2]<<16 | fields[3]<<24
+			copySize := fields[4] | fields[5]<<8 | fields[6]<<16
+
+			if copySize == 0 {
+				copySize = 0x10000
+			}
+
+			if copyOffset < 0 || copySize < 0 ||
+				copyOffset >= int64(len(baseData)) ||
+				copyOffset+copySize > int64(len(baseData)) {
+				return nil, fmt.Errorf("invalid copy operation: offset=%d, size=%d, base_len=%d",
+					copyOffset, copySize, len(baseData))
+			}
+
+			result = append(result, baseData[copyOffset:copyOffset+copySize]...)
+
+		} else if instruction != 0 {
+			// insert instruction
+			insertSize := int(instruction)
+			if offset+insertSize > len(deltaData) {
+				return nil, fmt.Errorf("insert extends beyond delta data")
+			}
+
+			result = append(result, deltaData[offset:offset+insertSize]...)
+			offset += insertSize
+		} else {
+			return nil, fmt.Errorf("invalid delta instruction: 0")
+		}
+	}
+
+	if int64(len(result)) != resultSize {
+		return nil, fmt.Errorf("result size mismatch: expected %d, got %d", resultSize, len(result))
+	}
+
+	return result, nil
+}
+
+// ReadSize decodes the little-endian varint at offset, returning it and the
+// offset just past it
+func ReadSize(data []byte, offset int) (int64, int) {
+	if offset >= len(data) {
+		return 0, offset
+	}
+
+	size := int64(data[offset] & 0x7f)
+	shift := 7
+	offset++
+
+	for offset < len(data) && data[offset-1]&0x80 != 0 {
+		size |= int64(data[offset]&0x7f) << shift
+		shift += 7
+		offset++
+	}
+
+	return size, offset
+}
@@ -0,0 +1,38 @@
+package delta
+
+import "testing"
+
+func TestApply(t *testing.T) {
+	base := []byte("hello world")
+
+	// copy "world" (offset 6, size 5), insert ", hi"
+	d := []byte{11, 9, 0x80 | 0x01 | 0x10, 6, 5, 4, ',', ' ', 'h', 'i'}
+	result, err := Apply(base, d)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if string(result) != "world, hi" {
+		t.Errorf("Expected %q, got %q", "world, hi", result)
+	}
+
+	tests := map[string][]byte{
+		"base size mismatch":   {10, 1, 1, 'x'},
+		"result size":          {11, 2, 1, 'x'},
+		"truncated copy":       {11, 5, 0x80 | 0x01 | 0x10, 6},
+		"copy past base":       {11, 5, 0x80 | 0x01 | 0x10, 8, 5},
+		"truncated insert":     {11, 3, 3, 'x'},
+		"reserved zero opcode": {11, 0, 0},
+	}
+	for name, d := range tests {
+		if _, err := Apply(base, d); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReadSize(t *testing.T) {
+	size, offset := ReadSize([]byte{0x91, 0x2e, 0xff}, 0)
+	if size != 0x1711 || offset != 2 {
+		t.Errorf("Expected size 0x1711 at offset 2, got %#x at %d", size, offset)
+	}
+}
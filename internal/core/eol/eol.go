@@ -0,0 +1,147 @@
+// Package eol converts line endings between the working tree and the object
+// store as core.autocrlf asks
+package eol
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/attributes"
+	"github.com/unkn0wn-root/git-go/internal/core/config"
+)
+
+const (
+	configFile       = "config"
+	globalConfigFile = ".gitconfig"
+
+	// binarySniffLen is how much of a file is searched for a NUL byte to
+	// decide it is binary and must not be converted
+	binarySniffLen = 8000
+)
+
+// AutoCRLF is the core.autocrlf setting
+type AutoCRLF int
+
+const (
+	// AutoCRLFFalse stores and checks out content unchanged
+	AutoCRLFFalse AutoCRLF = iota
+	// AutoCRLFInput turns CRLF into LF when content is added
+	AutoCRLFInput
+	// AutoCRLFTrue also turns LF into CRLF when files are checked out
+	AutoCRLFTrue
+)
+
+// ParseAutoCRLF reads a core.autocrlf value; anything unrecognised is false
+func ParseAutoCRLF(value string) AutoCRLF {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "input":
+		return AutoCRLFInput
+	case "true", "yes", "on", "1":
+		return AutoCRLFTrue
+	default:
+		return AutoCRLFFalse
+	}
+}
+
+// Load reads core.autocrlf from the global config and then the repository's
+// config in gitDir, the later one winning
+func Load(gitDir string) (AutoCRLF, error) {
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, globalConfigFile))
+	}
+	paths = append(paths, filepath.Join(gitDir, configFile))
+
+	setting := AutoCRLFFalse
+	for _, path := range paths {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return AutoCRLFFalse, err
+		}
+		if v, ok := cfg.Get("core", "", "autocrlf"); ok {
+			setting = ParseAutoCRLF(v)
+		}
+	}
+	return setting, nil
+}
+
+// ForAttributes adjusts the setting for a path's attributes: "-text", or
+// "binary", turns conversion off, and "text" normalizes on add even when
+// core.autocrlf is false
+func (a AutoCRLF) ForAttributes(attrs attributes.Attributes) AutoCRLF {
+	switch {
+	case attrs.IsUnset("text"):
+		return AutoCRLFFalse
+	case attrs.IsSet("text"):
+		return max(a, AutoCRLFInput)
+	}
+	return a
+}
+
+// IsBinary reports whether content has a NUL byte near its start
+func IsBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLen)], 0) >= 0
+}
+
+// ToIndex returns content as it should be hashed and stored: with CRLF
+// turned into LF unless the setting is false or content is binary
+func (a AutoCRLF) ToIndex(content []byte) []byte {
+	if a == AutoCRLFFalse || IsBinary(content) || !bytes.Contains(content, []byte("\r\n")) {
+		return content
+	}
+	return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+}
+
+// ToWorkTree returns content as it should be written to the working tree:
+// with bare LF turned into CRLF when the setting is true and content is text
+func (a AutoCRLF) ToWorkTree(content []byte) []byte {
+	if a != AutoCRLFTrue || IsBinary(content) {
+		return content
+	}
+	var buf bytes.Buffer
+	if _, err := a.CopyToWorkTree(&buf, bytes.NewReader(content)); err != nil {
+		return content
+	}
+	return buf.Bytes()
+}
+
+// CopyToWorkTree copies src to dst as ToWorkTree converts it, streaming so
+// that large files are never held in memory
+func (a AutoCRLF) CopyToWorkTree(dst io.Writer, src io.Reader) (int64, error) {
+	reader := bufio.NewReaderSize(src, binarySniffLen)
+	if a != AutoCRLFTrue {
+		return io.Copy(dst, reader)
+	}
+	if head, _ := reader.Peek(binarySniffLen); IsBinary(head) {
+		return io.Copy(dst, reader)
+	}
+
+	writer := bufio.NewWriter(dst)
+	var written int64
+	var prev byte
+	for {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		if c == '\n' && prev != '\r' {
+			if err := writer.WriteByte('\r'); err != nil {
+				return written, err
+			}
+			written++
+		}
+		if err := writer.WriteByte(c); err != nil {
+			return written, err
+		}
+		written++
+		prev = c
+	}
+	return written, writer.Flush()
+}
@@ -0,0 +1,114 @@
+package eol
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/core/attributes"
+)
+
+func TestParseAutoCRLF(t *testing.T) {
+	tests := map[string]AutoCRLF{
+		"true":  AutoCRLFTrue,
+		"TRUE":  AutoCRLFTrue,
+		"input": AutoCRLFInput,
+		"false": AutoCRLFFalse,
+		"":      AutoCRLFFalse,
+		"bogus": AutoCRLFFalse,
+	}
+	for value, want := range tests {
+		if got := ParseAutoCRLF(value); got != want {
+			t.Errorf("ParseAutoCRLF(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestToIndex(t *testing.T) {
+	crlf := []byte("a\r\nb\r\n")
+	if got := AutoCRLFFalse.ToIndex(crlf); !bytes.Equal(got, crlf) {
+		t.Errorf("false: got %q", got)
+	}
+	for _, setting := range []AutoCRLF{AutoCRLFInput, AutoCRLFTrue} {
+		if got := setting.ToIndex(crlf); string(got) != "a\nb\n" {
+			t.Errorf("%v: got %q", setting, got)
+		}
+	}
+
+	binary := []byte("a\r\n\x00b\r\n")
+	if got := AutoCRLFTrue.ToIndex(binary); !bytes.Equal(got, binary) {
+		t.Errorf("binary content was converted: %q", got)
+	}
+}
+
+func TestToWorkTree(t *testing.T) {
+	lf := []byte("a\nb\r\nc")
+	if got := AutoCRLFInput.ToWorkTree(lf); !bytes.Equal(got, lf) {
+		t.Errorf("input: got %q", got)
+	}
+	if got := AutoCRLFTrue.ToWorkTree(lf); string(got) != "a\r\nb\r\nc" {
+		t.Errorf("true: got %q", got)
+	}
+
+	binary := []byte("a\n\x00b\n")
+	if got := AutoCRLFTrue.ToWorkTree(binary); !bytes.Equal(got, binary) {
+		t.Errorf("binary content was converted: %q", got)
+	}
+}
+
+func TestCopyToWorkTreeLarge(t *testing.T) {
+	content := strings.Repeat("line\n", 5000)
+	var buf bytes.Buffer
+	n, err := AutoCRLFTrue.CopyToWorkTree(&buf, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("CopyToWorkTree failed: %v", err)
+	}
+	want := strings.Repeat("line\r\n", 5000)
+	if buf.String() != want || n != int64(len(want)) {
+		t.Errorf("got %d bytes, want %d", n, len(want))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	gitDir := t.TempDir()
+
+	if got, err := Load(gitDir); err != nil || got != AutoCRLFFalse {
+		t.Errorf("no config: got %v, %v", got, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[core]\n\tautocrlf = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Load(gitDir); got != AutoCRLFTrue {
+		t.Errorf("global config: got %v", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(gitDir, "config"), []byte("[core]\n\tautocrlf = input\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Load(gitDir); got != AutoCRLFInput {
+		t.Errorf("repository config should win: got %v", got)
+	}
+}
+
+func TestForAttributes(t *testing.T) {
+	tests := []struct {
+		setting AutoCRLF
+		attrs   attributes.Attributes
+		want    AutoCRLF
+	}{
+		{AutoCRLFTrue, attributes.Attributes{}, AutoCRLFTrue},
+		{AutoCRLFTrue, attributes.Attributes{"text": {State: attributes.Unset}}, AutoCRLFFalse},
+		{AutoCRLFFalse, attributes.Attributes{"text": {State: attributes.Set}}, AutoCRLFInput},
+		{AutoCRLFTrue, attributes.Attributes{"text": {State: attributes.Set}}, AutoCRLFTrue},
+	}
+	for _, tt := range tests {
+		if got := tt.setting.ForAttributes(tt.attrs); got != tt.want {
+			t.Errorf("%v.ForAttributes(%v) = %v, want %v", tt.setting, tt.attrs, got, tt.want)
+		}
+	}
+}
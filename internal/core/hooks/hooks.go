@@ -0,0 +1,84 @@
+package hooks
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	hooksDir = "hooks"
+
+	PreCommit = "pre-commit"
+	CommitMsg = "commit-msg"
+	PrePush   = "pre-push"
+
+	// server-side hooks, run by receive-pack
+	PreReceive  = "pre-receive"
+	Update      = "update"
+	PostReceive = "post-receive"
+)
+
+// HookError reports a hook that exited with a nonzero status, along with
+// everything it wrote to stdout and stderr
+type HookError struct {
+	Hook     string
+	ExitCode int
+	Output   string
+}
+
+func (e *HookError) Error() string {
+	msg := fmt.Sprintf("%v: %s exited with status %d", errors.ErrHookFailed, e.Hook, e.ExitCode)
+	if output := strings.TrimSpace(e.Output); output != "" {
+		msg += ":\n" + output
+	}
+	return msg
+}
+
+func (e *HookError) Unwrap() error {
+	return errors.ErrHookFailed
+}
+
+// Path returns the path of the named hook under .git/hooks
+func Path(repo *repository.Repository, name string) string {
+	return filepath.Join(repo.GitDir, hooksDir, name)
+}
+
+// Exists reports whether the named hook is present and executable
+func Exists(repo *repository.Repository, name string) bool {
+	info, err := os.Stat(Path(repo, name))
+	return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
+
+// Run executes the named hook from the work tree root with args and stdin,
+// returning its combined output. Missing or non-executable hooks are skipped.
+func Run(repo *repository.Repository, name string, stdin io.Reader, args ...string) (string, error) {
+	if !Exists(repo, name) {
+		return "", nil
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(Path(repo, name), args...)
+	cmd.Dir = repo.WorkDir
+	cmd.Stdin = stdin
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if stderrors.As(err, &exitErr) {
+			return output.String(), &HookError{Hook: name, ExitCode: exitErr.ExitCode(), Output: output.String()}
+		}
+		return output.String(), errors.NewGitError("hook", name, err)
+	}
+
+	return output.String(), nil
+}
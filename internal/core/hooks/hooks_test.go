@@ -0,0 +1,88 @@
+package hooks
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+func setupTestRepo(t *testing.T) *repository.Repository {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	return repo
+}
+
+func writeHook(t *testing.T, repo *repository.Repository, name, script string, mode os.FileMode) {
+	path := Path(repo, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create hooks dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), mode); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+}
+
+func TestRun_Missing(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	output, err := Run(repo, PreCommit, nil)
+	if err != nil || output != "" {
+		t.Errorf("Expected missing hook to be skipped, got %q, %v", output, err)
+	}
+}
+
+func TestRun_NotExecutable(t *testing.T) {
+	repo := setupTestRepo(t)
+	writeHook(t, repo, PreCommit, "exit 1\n", 0644)
+
+	if Exists(repo, PreCommit) {
+		t.Error("Expected non-executable hook not to exist")
+	}
+	if _, err := Run(repo, PreCommit, nil); err != nil {
+		t.Errorf("Expected non-executable hook to be skipped, got %v", err)
+	}
+}
+
+func TestRun_Success(t *testing.T) {
+	repo := setupTestRepo(t)
+	writeHook(t, repo, CommitMsg, "echo \"arg=$1\"\ncat\necho warn >&2\n", 0755)
+
+	output, err := Run(repo, CommitMsg, strings.NewReader("from stdin\n"), "msgfile")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, want := range []string{"arg=msgfile", "from stdin", "warn"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+func TestRun_Failure(t *testing.T) {
+	repo := setupTestRepo(t)
+	writeHook(t, repo, PreCommit, "echo 'lint failed' >&2\nexit 3\n", 0755)
+
+	_, err := Run(repo, PreCommit, nil)
+	if !stderrors.Is(err, errors.ErrHookFailed) {
+		t.Fatalf("Expected hook failure, got %v", err)
+	}
+
+	var hookErr *HookError
+	if !stderrors.As(err, &hookErr) {
+		t.Fatalf("Expected HookError, got %T", err)
+	}
+	if hookErr.ExitCode != 3 || !strings.Contains(hookErr.Output, "lint failed") {
+		t.Errorf("Unexpected hook error %+v", hookErr)
+	}
+	if !strings.Contains(err.Error(), "lint failed") {
+		t.Errorf("Expected error message to include hook output, got %q", err.Error())
+	}
+}
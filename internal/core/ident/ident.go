@@ -0,0 +1,107 @@
+package ident
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/config"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+)
+
+const (
+	configFile       = "config"
+	globalConfigFile = ".gitconfig"
+
+	defaultName  = "Unknown"
+	defaultEmail = "local@localhost.local"
+)
+
+// Person is a name and email pair, without a timestamp
+type Person struct {
+	Name  string
+	Email string
+}
+
+// Signature stamps the person with when
+func (p Person) Signature(when time.Time) *objects.Signature {
+	return &objects.Signature{Name: p.Name, Email: p.Email, When: when}
+}
+
+// Identity holds the author and committer a new commit is recorded with
+type Identity struct {
+	Author    Person
+	Committer Person
+}
+
+// Resolve determines the author and committer identity. The GIT_AUTHOR_* and
+// GIT_COMMITTER_* environment variables take precedence over user.name and
+// user.email from the repository config, which take precedence over the
+// global ~/.gitconfig. The OS user name and a placeholder email are the last
+// resort. An empty gitDir reads the global config only.
+func Resolve(gitDir string) (*Identity, error) {
+	name, email, err := configIdentity(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		if u, err := user.Current(); err == nil && u.Username != "" {
+			name = u.Username
+		} else {
+			name = defaultName
+		}
+	}
+	if email == "" {
+		email = firstNonEmpty(os.Getenv("EMAIL"), defaultEmail)
+	}
+
+	return &Identity{
+		Author: Person{
+			Name:  firstNonEmpty(os.Getenv("GIT_AUTHOR_NAME"), name),
+			Email: firstNonEmpty(os.Getenv("GIT_AUTHOR_EMAIL"), email),
+		},
+		Committer: Person{
+			Name:  firstNonEmpty(os.Getenv("GIT_COMMITTER_NAME"), name),
+			Email: firstNonEmpty(os.Getenv("GIT_COMMITTER_EMAIL"), email),
+		},
+	}, nil
+}
+
+// configIdentity reads user.name and user.email, the repository config
+// overriding the global one key by key
+func configIdentity(gitDir string) (string, string, error) {
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, globalConfigFile))
+	}
+	if gitDir != "" {
+		paths = append(paths, filepath.Join(gitDir, configFile))
+	}
+
+	var name, email string
+	for _, path := range paths {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return "", "", err
+		}
+		if v, ok := cfg.Get("user", "", "name"); ok {
+			name = v
+		}
+		if v, ok := cfg.Get("user", "", "email"); ok {
+			email = v
+		}
+	}
+
+	return name, email, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package ident
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// isolate points HOME at an empty directory and clears identity variables
+func isolate(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL", "EMAIL"} {
+		t.Setenv(name, "")
+	}
+	return home
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// newGitDir returns an empty git directory with no config of its own
+func newGitDir(t *testing.T) string {
+	t.Helper()
+	gitDir := filepath.Join(t.TempDir(), ".git")
+	if err := os.Mkdir(gitDir, 0755); err != nil {
+		t.Fatalf("Failed to create git directory: %v", err)
+	}
+	return gitDir
+}
+
+func TestResolve_Layering(t *testing.T) {
+	home := isolate(t)
+	gitDir := newGitDir(t)
+
+	writeFile(t, filepath.Join(home, globalConfigFile), "[user]\n\tname = Global Name\n\temail = global@example.com\n")
+	writeFile(t, filepath.Join(gitDir, configFile), "[user]\n\temail = repo@example.com\n")
+
+	id, err := Resolve(gitDir)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	want := Person{Name: "Global Name", Email: "repo@example.com"}
+	if id.Author != want || id.Committer != want {
+		t.Errorf("Expected %+v for both, got %+v", want, id)
+	}
+
+	t.Setenv("GIT_AUTHOR_NAME", "Env Author")
+	t.Setenv("GIT_COMMITTER_EMAIL", "committer@example.com")
+
+	id, err = Resolve(gitDir)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if id.Author != (Person{Name: "Env Author", Email: "repo@example.com"}) {
+		t.Errorf("Unexpected author %+v", id.Author)
+	}
+	if id.Committer != (Person{Name: "Global Name", Email: "committer@example.com"}) {
+		t.Errorf("Unexpected committer %+v", id.Committer)
+	}
+}
+
+func TestResolve_Fallback(t *testing.T) {
+	isolate(t)
+
+	id, err := Resolve(newGitDir(t))
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if id.Author.Name == "" || id.Author.Email != defaultEmail {
+		t.Errorf("Expected fallback identity, got %+v", id.Author)
+	}
+}
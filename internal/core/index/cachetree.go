@@ -0,0 +1,203 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	extensionHeaderSize = 8
+	treeExtension       = "TREE"
+	checksumSize        = 20
+)
+
+// cacheTree is the TREE extension: the tree hash of every directory the
+// index describes, so unchanged directories need not be rehashed
+type cacheTree struct {
+	name string
+	// entryCount is the number of index entries below this directory, or -1
+	// once an entry under it has changed and hash is stale
+	entryCount int
+	hash       string
+	subtrees   []*cacheTree
+}
+
+func (t *cacheTree) valid() bool {
+	return t != nil && t.entryCount >= 0
+}
+
+func (t *cacheTree) subtree(name string) *cacheTree {
+	for _, sub := range t.subtrees {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// invalidate marks the directories containing path as stale
+func (t *cacheTree) invalidate(path string) {
+	node := t
+	parts := strings.Split(path, "/")
+	for i := 0; node != nil; i++ {
+		node.entryCount = -1
+		if i == len(parts)-1 {
+			return
+		}
+		node = node.subtree(parts[i])
+	}
+}
+
+// CachedTrees returns the tree hashes the TREE extension records for
+// directories whose entries have not changed since
+func (idx *Index) CachedTrees() []string {
+	var hashes []string
+	var walk func(node *cacheTree)
+	walk = func(node *cacheTree) {
+		if node == nil {
+			return
+		}
+		if node.valid() {
+			hashes = append(hashes, node.hash)
+		}
+		for _, sub := range node.subtrees {
+			walk(sub)
+		}
+	}
+	walk(idx.cacheTree)
+	return hashes
+}
+
+// readExtensions parses the extensions between the last entry and the
+// trailing checksum. Unknown optional extensions, whose signature starts with
+// an uppercase letter, are skipped; unknown required ones are an error.
+func (idx *Index) readExtensions(data []byte) error {
+	for len(data) > 0 {
+		if len(data) < extensionHeaderSize {
+			return fmt.Errorf("truncated extension header")
+		}
+
+		signature := string(data[:4])
+		size := binary.BigEndian.Uint32(data[4:8])
+		data = data[extensionHeaderSize:]
+		if uint64(size) > uint64(len(data)) {
+			return fmt.Errorf("extension %q overruns the index", signature)
+		}
+		payload := data[:size]
+		data = data[size:]
+
+		switch {
+		case signature == treeExtension:
+			tree, rest, err := readCacheTree(payload)
+			if err != nil {
+				return fmt.Errorf("TREE extension: %w", err)
+			}
+			if len(rest) != 0 {
+				return fmt.Errorf("TREE extension: %d trailing bytes", len(rest))
+			}
+			idx.cacheTree = tree
+		case signature[0] >= 'A' && signature[0] <= 'Z':
+			// optional extension we do not use
+		default:
+			return fmt.Errorf("unsupported required extension %q", signature)
+		}
+	}
+	return nil
+}
+
+// readCacheTree parses one directory and its subtrees, in pre-order:
+// "<name>\0<entry count> <subtree count>\n" then the hash when valid
+func readCacheTree(data []byte) (*cacheTree, []byte, error) {
+	nul := bytes.IndexByte(data, 0)
+	if nul == -1 {
+		return nil, nil, fmt.Errorf("unterminated path")
+	}
+	node := &cacheTree{name: string(data[:nul])}
+	data = data[nul+1:]
+
+	newline := bytes.IndexByte(data, '\n')
+	if newline == -1 {
+		return nil, nil, fmt.Errorf("unterminated counts for %q", node.name)
+	}
+	counts := strings.Fields(string(data[:newline]))
+	data = data[newline+1:]
+	if len(counts) != 2 {
+		return nil, nil, fmt.Errorf("malformed counts for %q", node.name)
+	}
+
+	entryCount, err := strconv.Atoi(counts[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("entry count for %q: %w", node.name, err)
+	}
+	subtreeCount, err := strconv.Atoi(counts[1])
+	if err != nil || subtreeCount < 0 {
+		return nil, nil, fmt.Errorf("invalid subtree count for %q", node.name)
+	}
+	node.entryCount = entryCount
+
+	if entryCount >= 0 {
+		if len(data) < checksumSize {
+			return nil, nil, fmt.Errorf("truncated hash for %q", node.name)
+		}
+		node.hash = hex.EncodeToString(data[:checksumSize])
+		data = data[checksumSize:]
+	}
+
+	for i := 0; i < subtreeCount; i++ {
+		var sub *cacheTree
+		sub, data, err = readCacheTree(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		node.subtrees = append(node.subtrees, sub)
+	}
+
+	return node, data, nil
+}
+
+func writeCacheTree(buf *bytes.Buffer, node *cacheTree) error {
+	buf.WriteString(node.name)
+	buf.WriteByte(0)
+	fmt.Fprintf(buf, "%d %d\n", node.entryCount, len(node.subtrees))
+
+	if node.entryCount >= 0 {
+		hashBytes, err := hex.DecodeString(node.hash)
+		if err != nil || len(hashBytes) != checksumSize {
+			return fmt.Errorf("invalid cache tree hash %q", node.hash)
+		}
+		buf.Write(hashBytes)
+	}
+
+	subtrees := append([]*cacheTree(nil), node.subtrees...)
+	sort.Slice(subtrees, func(i, j int) bool {
+		return subtrees[i].name < subtrees[j].name
+	})
+	for _, sub := range subtrees {
+		if err := writeCacheTree(buf, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTreeExtension appends the TREE extension when a cache tree is known
+func (idx *Index) writeTreeExtension(buf *bytes.Buffer) error {
+	if idx.cacheTree == nil {
+		return nil
+	}
+
+	var payload bytes.Buffer
+	if err := writeCacheTree(&payload, idx.cacheTree); err != nil {
+		return err
+	}
+
+	buf.WriteString(treeExtension)
+	binary.Write(buf, binary.BigEndian, uint32(payload.Len()))
+	buf.Write(payload.Bytes())
+	return nil
+}
@@ -1,6 +1,7 @@
 package index
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha1"
 	"encoding/binary"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
@@ -22,6 +24,11 @@ const (
 	indexVersion    = 2
 	fixedHeaderSize = 62
 	maxPathLength   = 0xFFF
+
+	// versions 3 and 4 are read but never written
+	minIndexVersion = 2
+	maxIndexVersion = 4
+	extendedFlag    = 0x4000
 )
 
 type IndexEntry struct {
@@ -44,6 +51,13 @@ type IndexEntry struct {
 type Index struct {
 	entries map[string]*IndexEntry
 	gitDir  string
+	// modTime is the index file's mtime when loaded, used to detect entries
+	// written in the same instant as their file was last modified
+	modTime   time.Time
+	cacheTree *cacheTree
+	// unmerged holds the entries of paths left conflicted by a merge, in
+	// stage order; entries keeps only the last stage of each
+	unmerged map[string][]*IndexEntry
 }
 
 func New(gitDir string) *Index {
@@ -64,9 +78,15 @@ func (idx *Index) Load() error {
 	}
 	defer file.Close()
 
+	if info, err := file.Stat(); err == nil {
+		idx.modTime = info.ModTime()
+	}
+
+	reader := bufio.NewReader(file)
+
 	// git index format: 12-byte header + entries + checksum
 	header := make([]byte, 12)
-	if _, err := io.ReadFull(file, header); err != nil {
+	if _, err := io.ReadFull(reader, header); err != nil {
 		if err == io.EOF {
 			return nil // Empty index
 		}
@@ -78,19 +98,36 @@ func (idx *Index) Load() error {
 		return errors.NewIndexError(indexPath, fmt.Errorf("invalid index signature"))
 	}
 
-	// only support index version 2
 	version := binary.BigEndian.Uint32(header[4:8])
-	if version != indexVersion {
+	if version < minIndexVersion || version > maxIndexVersion {
 		return errors.NewIndexError(indexPath, fmt.Errorf("unsupported index version: %d", version))
 	}
 
 	entryCount := binary.BigEndian.Uint32(header[8:12])
+	prevPath := ""
 	for i := uint32(0); i < entryCount; i++ {
-		entry, err := idx.readIndexEntry(file)
+		entry, err := idx.readIndexEntry(reader, version, prevPath)
 		if err != nil {
 			return errors.NewIndexError(indexPath, fmt.Errorf("%d: %w", i, err))
 		}
 		idx.entries[entry.Path] = entry
+		if entry.StageNumber > 0 {
+			if idx.unmerged == nil {
+				idx.unmerged = make(map[string][]*IndexEntry)
+			}
+			idx.unmerged[entry.Path] = append(idx.unmerged[entry.Path], entry)
+		}
+		prevPath = entry.Path
+	}
+
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		return errors.NewIndexError(indexPath, err)
+	}
+	if len(rest) >= checksumSize {
+		if err := idx.readExtensions(rest[:len(rest)-checksumSize]); err != nil {
+			return errors.NewIndexError(indexPath, err)
+		}
 	}
 
 	return nil
@@ -125,6 +162,10 @@ func (idx *Index) Save() error {
 		}
 	}
 
+	if err := idx.writeTreeExtension(&buf); err != nil {
+		return errors.NewIndexError(indexPath, err)
+	}
+
 	hash := sha1.Sum(buf.Bytes())
 	buf.Write(hash[:])
 
@@ -140,6 +181,7 @@ func (idx *Index) Add(path, objHash string, mode uint32, size int64, modTime tim
 		return errors.NewIndexError(path, errors.ErrInvalidHash)
 	}
 
+	idx.invalidate(path)
 	idx.entries[path] = &IndexEntry{
 		Path:         path,
 		Hash:         objHash,
@@ -166,6 +208,7 @@ func (idx *Index) AddWithFileInfo(path, objHash string, mode uint32, fileInfo os
 	// get filesystem metadata using platform-specific func
 	ctime, ctimeNs, dev, ino, uid, gid := getStatTimes(fileInfo)
 
+	idx.invalidate(path)
 	idx.entries[path] = &IndexEntry{
 		Path:         path,
 		Hash:         objHash,
@@ -184,11 +227,39 @@ func (idx *Index) AddWithFileInfo(path, objHash string, mode uint32, fileInfo os
 	return nil
 }
 
+// StatUnchanged reports whether the file at path still matches the stat data
+// recorded in its entry, so its content need not be rehashed. Entries whose
+// file changed no earlier than the index was written are racily clean and
+// never match, since a same-instant edit leaves the stat data unchanged.
+func (idx *Index) StatUnchanged(path string, fileInfo os.FileInfo) bool {
+	entry, ok := idx.entries[path]
+	if !ok || idx.modTime.IsZero() {
+		return false
+	}
+
+	modTime := fileInfo.ModTime()
+	if !modTime.Before(idx.modTime) {
+		return false
+	}
+
+	ctime, ctimeNs, dev, ino, uid, gid := getStatTimes(fileInfo)
+	return uint32(entry.Size) == uint32(fileInfo.Size()) &&
+		uint32(entry.ModTime.Unix()) == uint32(modTime.Unix()) &&
+		entry.ModTimeNs == uint32(modTime.Nanosecond()) &&
+		uint32(entry.CreateTime.Unix()) == uint32(ctime.Unix()) &&
+		entry.CreateTimeNs == ctimeNs &&
+		entry.Dev == dev &&
+		entry.Ino == ino &&
+		entry.UID == uid &&
+		entry.GID == gid
+}
+
 func (idx *Index) Remove(path string) error {
 	if _, exists := idx.entries[path]; !exists {
 		return errors.ErrFileNotStaged
 	}
 
+	idx.invalidate(path)
 	delete(idx.entries, path)
 	return nil
 }
@@ -238,11 +309,50 @@ func (idx *Index) HasChanges() bool {
 	return false
 }
 
+// Unmerged returns the stage entries of each path left conflicted by a merge:
+// stage 1 is the common ancestor, 2 ours and 3 theirs
+func (idx *Index) Unmerged() map[string][]*IndexEntry {
+	result := make(map[string][]*IndexEntry, len(idx.unmerged))
+	for k, v := range idx.unmerged {
+		result[k] = v
+	}
+	return result
+}
+
 func (idx *Index) Clear() {
 	idx.entries = make(map[string]*IndexEntry)
+	idx.cacheTree = nil
+	idx.unmerged = nil
+}
+
+// invalidate forgets what is cached about path after its entry changes; a
+// changed entry also resolves any conflict on it
+func (idx *Index) invalidate(path string) {
+	delete(idx.unmerged, path)
+	if idx.cacheTree != nil {
+		idx.cacheTree.invalidate(filepath.ToSlash(path))
+	}
+}
+
+// ObjectStore persists objects; *repository.Repository satisfies it
+type ObjectStore interface {
+	StoreObject(obj objects.Object) (string, error)
 }
 
+// objectChecker is implemented by stores that can tell whether they already
+// hold an object, letting WriteTreeTo reuse cached trees
+type objectChecker interface {
+	HasObject(hash string) bool
+}
+
+// WriteTree computes the hash of the tree the staged entries describe
 func (idx *Index) WriteTree() (string, error) {
+	return idx.WriteTreeTo(nil)
+}
+
+// WriteTreeTo is WriteTree that also writes every tree object to store, so
+// the result can be used as the tree of a commit
+func (idx *Index) WriteTreeTo(store ObjectStore) (string, error) {
 	if !idx.HasChanges() {
 		return "", errors.ErrNothingToCommit
 	}
@@ -260,7 +370,7 @@ func (idx *Index) WriteTree() (string, error) {
 			continue
 		}
 
-		parts := strings.Split(entry.Path, string(filepath.Separator))
+		parts := strings.Split(entry.Path, "/")
 		current := root
 
 		for i := 0; i < len(parts)-1; i++ {
@@ -279,7 +389,13 @@ func (idx *Index) WriteTree() (string, error) {
 		current.files[fileName] = entry
 	}
 
-	return idx.writeTreeRecursive(root)
+	tree, err := idx.writeTreeRecursive(root, idx.cacheTree, store)
+	if err != nil {
+		return "", err
+	}
+
+	idx.cacheTree = tree
+	return tree.hash, nil
 }
 
 type dirNode struct {
@@ -288,10 +404,13 @@ type dirNode struct {
 	files    map[string]*IndexEntry
 }
 
-func (idx *Index) readIndexEntry(file io.Reader) (*IndexEntry, error) {
+// readIndexEntry reads one entry. Version 3 entries may carry a second
+// flags word; version 4 entries store their path as a suffix of prevPath
+// and are not padded.
+func (idx *Index) readIndexEntry(reader *bufio.Reader, version uint32, prevPath string) (*IndexEntry, error) {
 	// git index entry: 62-byte fixed header + variable-length path
 	header := make([]byte, fixedHeaderSize)
-	if _, err := io.ReadFull(file, header); err != nil {
+	if _, err := io.ReadFull(reader, header); err != nil {
 		return nil, fmt.Errorf("failed to read entry header: %w", err)
 	}
 
@@ -310,54 +429,68 @@ func (idx *Index) readIndexEntry(file io.Reader) (*IndexEntry, error) {
 	flags := binary.BigEndian.Uint16(header[60:62])
 	hashStr := hex.EncodeToString(hashBytes)
 
-	var pathBytes []byte
+	entrySize := fixedHeaderSize
+	if flags&extendedFlag != 0 {
+		if version < 3 {
+			return nil, fmt.Errorf("extended flags in a version %d index", version)
+		}
+		// skip-worktree and intent-to-add live here; they are not kept
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(reader, extended); err != nil {
+			return nil, err
+		}
+		entrySize += len(extended)
+	}
 
-	// path length is stored in lower 12 bits of flags
-	pathLen := flags & maxPathLength
-	if pathLen == maxPathLength {
-		// path >= 4095 chars: read until null terminator
-		var pathBuf bytes.Buffer
-		buf := make([]byte, 1)
-		for {
-			if _, err := io.ReadFull(file, buf); err != nil {
+	var path string
+	if version >= 4 {
+		strip, err := readVarint(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read path prefix: %w", err)
+		}
+		if strip > uint64(len(prevPath)) {
+			return nil, fmt.Errorf("path prefix length %d exceeds previous path %q", strip, prevPath)
+		}
+		suffix, err := reader.ReadString(0)
+		if err != nil {
+			return nil, err
+		}
+		path = prevPath[:len(prevPath)-int(strip)] + strings.TrimSuffix(suffix, "\x00")
+	} else {
+		// path length is stored in lower 12 bits of flags; longer paths are
+		// read up to their null terminator
+		pathLen := int(flags & maxPathLength)
+		if pathLen == maxPathLength {
+			name, err := reader.ReadString(0)
+			if err != nil {
 				return nil, err
 			}
-			if buf[0] == 0 {
-				break
+			path = strings.TrimSuffix(name, "\x00")
+		} else {
+			// read path + null terminator
+			pathBytes := make([]byte, pathLen+1)
+			if _, err := io.ReadFull(reader, pathBytes); err != nil {
+				return nil, err
 			}
-			pathBuf.WriteByte(buf[0])
-		}
-		pathBytes = pathBuf.Bytes()
-		pathLen = uint16(len(pathBytes))
-	} else {
-		// read path + null terminator
-		pathBytes = make([]byte, pathLen)
-		if _, err := io.ReadFull(file, pathBytes); err != nil {
-			return nil, err
+			path = string(pathBytes[:pathLen])
 		}
-		// read and discard null terminator
-		nullByte := make([]byte, 1)
-		if _, err := io.ReadFull(file, nullByte); err != nil {
+
+		// index entries are padded to 8-byte alignment
+		entrySize += len(path) + 1 // +1 for null terminator
+		padding := (8 - (entrySize % 8)) % 8
+		if _, err := reader.Discard(padding); err != nil {
 			return nil, err
 		}
 	}
 
-	// index entries are padded to 8-byte alignment
-	entrySize := 62 + int(pathLen) + 1 // +1 for null terminator
-	padding := (8 - (entrySize % 8)) % 8
-	if padding > 0 {
-		padBytes := make([]byte, padding)
-		io.ReadFull(file, padBytes)
-	}
-
-	modTime := time.Unix(int64(mtime), 0)
-	createTime := time.Unix(int64(ctime), 0)
+	modTime := time.Unix(int64(mtime), int64(mmtime))
+	createTime := time.Unix(int64(ctime), int64(cmtime))
 
 	// extract stage number from flags (bits 12-13)
 	stageNumber := int((flags >> 12) & 0x3)
 
 	return &IndexEntry{
-		Path:         string(pathBytes),
+		Path:         path,
 		Hash:         hashStr,
 		Mode:         mode,
 		Size:         int64(size),
@@ -374,6 +507,24 @@ func (idx *Index) readIndexEntry(file io.Reader) (*IndexEntry, error) {
 	}, nil
 }
 
+// readVarint decodes the offset varint of index v4 path compression, in
+// which each continuation byte also adds one to the value
+func readVarint(reader *bufio.Reader) (uint64, error) {
+	c, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	value := uint64(c & 0x7f)
+	for c&0x80 != 0 {
+		if c, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+		value = ((value + 1) << 7) | uint64(c&0x7f)
+	}
+	return value, nil
+}
+
 func (idx *Index) writeIndexEntry(buf *bytes.Buffer, entry *IndexEntry) error {
 	hashBytes, err := hex.DecodeString(entry.Hash)
 	if err != nil {
@@ -427,7 +578,16 @@ func (idx *Index) writeIndexEntry(buf *bytes.Buffer, entry *IndexEntry) error {
 	return nil
 }
 
-func (idx *Index) writeTreeRecursive(node *dirNode) (string, error) {
+// writeTreeRecursive hashes node, reusing the cached hash of a directory
+// whose entries are unchanged, and returns the directory's fresh cache tree
+func (idx *Index) writeTreeRecursive(node *dirNode, cached *cacheTree, store ObjectStore) (*cacheTree, error) {
+	entryCount := node.entryCount()
+	if cached.valid() && cached.entryCount == entryCount && cached.name == node.name && storeHas(store, cached.hash) {
+		return cached, nil
+	}
+
+	result := &cacheTree{name: node.name, entryCount: entryCount}
+
 	type treeEntry struct {
 		mode  uint32
 		name  string
@@ -439,14 +599,20 @@ func (idx *Index) writeTreeRecursive(node *dirNode) (string, error) {
 
 	// subdirectories
 	for name, child := range node.children {
-		childHash, err := idx.writeTreeRecursive(child)
+		var cachedChild *cacheTree
+		if cached != nil {
+			cachedChild = cached.subtree(name)
+		}
+
+		childTree, err := idx.writeTreeRecursive(child, cachedChild, store)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+		result.subtrees = append(result.subtrees, childTree)
 		entries = append(entries, treeEntry{
 			mode:  0o040000, // Directory mode
 			name:  name,
-			hash:  childHash,
+			hash:  childTree.hash,
 			isDir: true,
 		})
 	}
@@ -460,30 +626,61 @@ func (idx *Index) writeTreeRecursive(node *dirNode) (string, error) {
 		})
 	}
 
-	// sort entries (directories and files together, lexicographically)
+	// git orders a directory as if its name ended in a slash
+	sortName := func(entry treeEntry) string {
+		if entry.isDir {
+			return entry.name + "/"
+		}
+		return entry.name
+	}
 	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].name < entries[j].name
+		return sortName(entries[i]) < sortName(entries[j])
 	})
 
-	// build tree object data
-	var buf bytes.Buffer
+	if len(entries) == 0 {
+		return nil, errors.NewIndexError("", fmt.Errorf("empty tree"))
+	}
+
+	treeEntries := make([]objects.TreeEntry, 0, len(entries))
 	for _, entry := range entries {
-		buf.WriteString(fmt.Sprintf("%06o", entry.mode))
-		buf.WriteByte(' ')
-		buf.WriteString(entry.name)
-		buf.WriteByte(0)
+		if !hash.ValidateHash(entry.hash) {
+			return nil, errors.NewIndexError(entry.name, fmt.Errorf("invalid hash %s", entry.hash))
+		}
+		treeEntries = append(treeEntries, objects.TreeEntry{
+			Mode: objects.FileMode(entry.mode),
+			Name: entry.name,
+			Hash: entry.hash,
+		})
+	}
+	tree := objects.NewTree(treeEntries)
 
-		hashBytes, err := hex.DecodeString(entry.hash)
+	if store != nil {
+		treeHash, err := store.StoreObject(tree)
 		if err != nil {
-			return "", errors.NewIndexError(entry.name, fmt.Errorf("invalid hash %s: %w", entry.hash, err))
+			return nil, err
 		}
-		buf.Write(hashBytes)
+		result.hash = treeHash
+		return result, nil
 	}
 
-	if buf.Len() == 0 {
-		return "", errors.NewIndexError("", fmt.Errorf("empty tree"))
+	result.hash = hash.ComputeObjectHash(string(objects.ObjectTypeTree), tree.Data())
+	return result, nil
+}
+
+func (node *dirNode) entryCount() int {
+	count := len(node.files)
+	for _, child := range node.children {
+		count += child.entryCount()
 	}
+	return count
+}
 
-	treeHash := hash.ComputeObjectHash("tree", buf.Bytes())
-	return treeHash, nil
+// storeHas reports whether a cached tree can be reused: a hash-only write
+// needs nothing stored, otherwise the store must already hold the tree
+func storeHas(store ObjectStore, treeHash string) bool {
+	if store == nil {
+		return true
+	}
+	checker, ok := store.(objectChecker)
+	return ok && checker.HasObject(treeHash)
 }
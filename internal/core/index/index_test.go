@@ -1,6 +1,9 @@
 package index
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
 	"os"
 	"path/filepath"
 	"testing"
@@ -8,6 +11,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
 )
 
 func TestNew(t *testing.T) {
@@ -160,3 +165,235 @@ func TestSaveAndLoad(t *testing.T) {
 	assert.Equal(t, hash2, entry2.Hash)
 	assert.Equal(t, uint32(0o100755), entry2.Mode)
 }
+
+func TestStatDataRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	gitDir := filepath.Join(dir, ".git")
+	require.NoError(t, os.Mkdir(gitDir, 0755))
+
+	path := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+	mtime := time.Unix(1700000000, 123456789)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	idx := New(gitDir)
+	hash := "abc123def456789012345678901234567890abcd"
+	require.NoError(t, idx.AddWithFileInfo("file.txt", hash, 0o100644, info))
+	require.NoError(t, idx.Save())
+
+	idx2 := New(gitDir)
+	require.NoError(t, idx2.Load())
+
+	original, _ := idx.Get("file.txt")
+	loaded, ok := idx2.Get("file.txt")
+	require.True(t, ok)
+	assert.Equal(t, original.ModTimeNs, loaded.ModTimeNs)
+	assert.Equal(t, uint32(123456789), loaded.ModTimeNs)
+	assert.Equal(t, original.CreateTimeNs, loaded.CreateTimeNs)
+	assert.Equal(t, original.Dev, loaded.Dev)
+	assert.Equal(t, original.Ino, loaded.Ino)
+	assert.Equal(t, original.UID, loaded.UID)
+	assert.Equal(t, original.GID, loaded.GID)
+	assert.True(t, loaded.ModTime.Equal(mtime))
+
+	assert.True(t, idx2.StatUnchanged("file.txt", info))
+	assert.False(t, idx2.StatUnchanged("missing.txt", info))
+
+	touched := mtime.Add(time.Second)
+	require.NoError(t, os.Chtimes(path, touched, touched))
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.False(t, idx2.StatUnchanged("file.txt", info))
+}
+
+func TestStatUnchanged_RacilyClean(t *testing.T) {
+	dir := t.TempDir()
+	gitDir := filepath.Join(dir, ".git")
+	require.NoError(t, os.Mkdir(gitDir, 0755))
+
+	path := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	idx := New(gitDir)
+	require.NoError(t, idx.AddWithFileInfo("file.txt", "abc123def456789012345678901234567890abcd", 0o100644, info))
+	require.NoError(t, idx.Save())
+
+	// the file was modified in the same instant the index was written
+	indexPath := filepath.Join(gitDir, "index")
+	require.NoError(t, os.Chtimes(indexPath, info.ModTime(), info.ModTime()))
+
+	idx2 := New(gitDir)
+	require.NoError(t, idx2.Load())
+	assert.False(t, idx2.StatUnchanged("file.txt", info))
+}
+
+func TestTreeExtensionRoundTrip(t *testing.T) {
+	gitDir := filepath.Join(t.TempDir(), ".git")
+	require.NoError(t, os.Mkdir(gitDir, 0755))
+
+	idx := New(gitDir)
+	require.NoError(t, idx.Add("README", "abc123def456789012345678901234567890abcd", 0o100644, 1, time.Now()))
+	require.NoError(t, idx.Add("src/main.go", "def456abc7890123456789012345678901abcdef", 0o100644, 1, time.Now()))
+	require.NoError(t, idx.Add("src/util/util.go", "0123456789abcdef0123456789abcdef01234567", 0o100644, 1, time.Now()))
+
+	treeHash, err := idx.WriteTree()
+	require.NoError(t, err)
+	require.NoError(t, idx.Save())
+
+	data, err := os.ReadFile(filepath.Join(gitDir, "index"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "TREE")
+
+	loaded := New(gitDir)
+	require.NoError(t, loaded.Load())
+	require.NotNil(t, loaded.cacheTree)
+	assert.Equal(t, treeHash, loaded.cacheTree.hash)
+	assert.Equal(t, 3, loaded.cacheTree.entryCount)
+
+	src := loaded.cacheTree.subtree("src")
+	require.NotNil(t, src)
+	assert.Equal(t, 2, src.entryCount)
+	require.NotNil(t, src.subtree("util"))
+
+	again, err := loaded.WriteTree()
+	require.NoError(t, err)
+	assert.Equal(t, treeHash, again)
+
+	// changing a file invalidates its directories but not its siblings
+	require.NoError(t, loaded.Add("src/main.go", "1111111111111111111111111111111111111111", 0o100644, 1, time.Now()))
+	assert.Equal(t, -1, loaded.cacheTree.entryCount)
+	assert.Equal(t, -1, src.entryCount)
+	assert.Equal(t, 1, src.subtree("util").entryCount)
+
+	changed, err := loaded.WriteTree()
+	require.NoError(t, err)
+	assert.NotEqual(t, treeHash, changed)
+
+	fresh := New(gitDir)
+	for path, entry := range loaded.GetAll() {
+		require.NoError(t, fresh.Add(path, entry.Hash, entry.Mode, entry.Size, entry.ModTime))
+	}
+	expected, err := fresh.WriteTree()
+	require.NoError(t, err)
+	assert.Equal(t, expected, changed)
+}
+
+func TestLoadSkipsOptionalExtensions(t *testing.T) {
+	gitDir := filepath.Join(t.TempDir(), ".git")
+	require.NoError(t, os.Mkdir(gitDir, 0755))
+
+	idx := New(gitDir)
+	require.NoError(t, idx.Add("file.txt", "abc123def456789012345678901234567890abcd", 0o100644, 1, time.Now()))
+	require.NoError(t, idx.Save())
+
+	indexPath := filepath.Join(gitDir, "index")
+	data, err := os.ReadFile(indexPath)
+	require.NoError(t, err)
+	body := data[:len(data)-20]
+
+	withExtension := func(signature string) []byte {
+		var buf bytes.Buffer
+		buf.Write(body)
+		buf.WriteString(signature)
+		binary.Write(&buf, binary.BigEndian, uint32(3))
+		buf.WriteString("xyz")
+		sum := sha1.Sum(buf.Bytes())
+		buf.Write(sum[:])
+		return buf.Bytes()
+	}
+
+	require.NoError(t, os.WriteFile(indexPath, withExtension("UNTR"), 0644))
+	loaded := New(gitDir)
+	require.NoError(t, loaded.Load())
+	_, ok := loaded.Get("file.txt")
+	assert.True(t, ok)
+
+	require.NoError(t, os.WriteFile(indexPath, withExtension("link"), 0644))
+	assert.Error(t, New(gitDir).Load())
+}
+
+// The fixtures were written by Git for the same tree; v3 and v4 also hold an
+// intent-to-add entry, which needs the v3 extended flags
+func TestLoadIndexVersions(t *testing.T) {
+	base := map[string]string{
+		"a.txt":         "eaa5fa8755fc20f08d0b3da347a5d1868404e462",
+		"dir/b.txt":     "23d3550c6477d90cbce6073c88169bc6ac555f08",
+		"dir/sub/c.txt": "4697a8eb618798e6324c0ddb6e44b7aed4af48c1",
+		"dir/sub/d.txt": "c5ef58732167511eef9c41722bed0c79d2920f94",
+		"zeta":          "fd08df0afa4d1d3faece37798d169e5a46d9d3fd",
+	}
+
+	for _, version := range []string{"v2", "v3", "v4"} {
+		t.Run(version, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "index-"+version))
+			require.NoError(t, err)
+
+			gitDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(gitDir, "index"), data, 0644))
+
+			idx := New(gitDir)
+			require.NoError(t, idx.Load())
+
+			expected := make(map[string]string)
+			for path, hash := range base {
+				expected[path] = hash
+			}
+			if version != "v2" {
+				expected["intent.txt"] = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+			}
+
+			got := make(map[string]string)
+			for path, entry := range idx.GetAll() {
+				got[path] = entry.Hash
+				assert.Equal(t, uint32(0o100644), entry.Mode, path)
+			}
+			assert.Equal(t, expected, got)
+
+			// indexes are always written back as version 2
+			require.NoError(t, idx.Save())
+			written, err := os.ReadFile(filepath.Join(gitDir, "index"))
+			require.NoError(t, err)
+			assert.Equal(t, uint32(2), binary.BigEndian.Uint32(written[4:8]))
+
+			reloaded := New(gitDir)
+			require.NoError(t, reloaded.Load())
+			assert.Len(t, reloaded.GetAll(), len(expected))
+		})
+	}
+}
+
+func TestLoadRejectsUnknownVersion(t *testing.T) {
+	gitDir := t.TempDir()
+	header := []byte("DIRC\x00\x00\x00\x05\x00\x00\x00\x00")
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "index"), header, 0644))
+	assert.Error(t, New(gitDir).Load())
+}
+
+// hashingStore stores nothing but hashes objects as a repository would
+type hashingStore struct{}
+
+func (hashingStore) StoreObject(obj objects.Object) (string, error) {
+	return hash.ComputeObjectHash(string(obj.Type()), obj.Data()), nil
+}
+
+func TestWriteTreeMatchesStoredTree(t *testing.T) {
+	idx := New(t.TempDir())
+	require.NoError(t, idx.Add("a.txt", "abc123def456789012345678901234567890abcd", 0o100644, 1, time.Now()))
+	require.NoError(t, idx.Add("a/b.txt", "def456abc7890123456789012345678901abcdef", 0o100644, 1, time.Now()))
+
+	computed, err := idx.WriteTree()
+	require.NoError(t, err)
+
+	fresh := New(t.TempDir())
+	for path, entry := range idx.GetAll() {
+		require.NoError(t, fresh.Add(path, entry.Hash, entry.Mode, entry.Size, entry.ModTime))
+	}
+	stored, err := fresh.WriteTreeTo(hashingStore{})
+	require.NoError(t, err)
+	assert.Equal(t, stored, computed)
+}
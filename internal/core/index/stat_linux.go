@@ -9,7 +9,11 @@ import (
 )
 
 func getStatTimes(fileInfo os.FileInfo) (ctime time.Time, ctimeNs uint32, dev uint32, ino uint32, uid uint32, gid uint32) {
-	stat := fileInfo.Sys().(*syscall.Stat_t)
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		modTime := fileInfo.ModTime()
+		return time.Unix(modTime.Unix(), 0), uint32(modTime.Nanosecond()), 0, 0, 0, 0
+	}
 	return time.Unix(stat.Ctim.Sec, 0),
 		uint32(stat.Ctim.Nsec),
 		uint32(stat.Dev),
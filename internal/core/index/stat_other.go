@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package index
+
+import (
+	"os"
+	"time"
+)
+
+// getStatTimes falls back to the modification time on platforms without a
+// Unix stat structure; device, inode and owner are recorded as zero
+func getStatTimes(fileInfo os.FileInfo) (ctime time.Time, ctimeNs uint32, dev uint32, ino uint32, uid uint32, gid uint32) {
+	modTime := fileInfo.ModTime()
+	return time.Unix(modTime.Unix(), 0), uint32(modTime.Nanosecond()), 0, 0, 0, 0
+}
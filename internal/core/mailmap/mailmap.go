@@ -0,0 +1,164 @@
+package mailmap
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const mailmapFile = ".mailmap"
+
+// Mailmap maps the names and emails commits were made under to canonical
+// ones. A nil Mailmap maps every identity to itself.
+type Mailmap struct {
+	// entries is keyed by lowercased commit email
+	entries map[string]*entry
+}
+
+// entry is the mapping for one commit email: name and email apply to any
+// commit name, and byName holds mappings for particular commit names
+type entry struct {
+	name   string
+	email  string
+	byName map[string]identity
+}
+
+type identity struct {
+	name  string
+	email string
+}
+
+// Load reads the .mailmap file at the top of the work tree. A missing file
+// yields an empty mailmap.
+func Load(repo *repository.Repository) (*Mailmap, error) {
+	f, err := os.Open(filepath.Join(repo.WorkDir, mailmapFile))
+	if os.IsNotExist(err) {
+		return &Mailmap{entries: make(map[string]*entry)}, nil
+	}
+	if err != nil {
+		return nil, errors.NewGitError("mailmap", mailmapFile, err)
+	}
+	defer f.Close()
+
+	m, err := Parse(f)
+	if err != nil {
+		return nil, errors.NewGitError("mailmap", mailmapFile, err)
+	}
+	return m, nil
+}
+
+// Parse reads mailmap lines in any of git's forms:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+//
+// Lines starting with # and lines without an email are ignored.
+func Parse(r io.Reader) (*Mailmap, error) {
+	m := &Mailmap{entries: make(map[string]*entry)}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name1, email1, rest, ok := parseNameEmail(line)
+		if !ok {
+			continue
+		}
+		if name2, email2, _, ok := parseNameEmail(rest); ok {
+			m.add(name1, email1, name2, email2)
+		} else {
+			m.add(name1, "", "", email1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// parseNameEmail splits "Name <email>" off the front of s, returning what
+// follows it
+func parseNameEmail(s string) (string, string, string, bool) {
+	open := strings.IndexByte(s, '<')
+	if open < 0 {
+		return "", "", "", false
+	}
+	end := strings.IndexByte(s[open:], '>')
+	if end < 0 {
+		return "", "", "", false
+	}
+	end += open
+
+	return strings.TrimSpace(s[:open]), strings.TrimSpace(s[open+1 : end]), s[end+1:], true
+}
+
+func (m *Mailmap) add(properName, properEmail, commitName, commitEmail string) {
+	key := strings.ToLower(commitEmail)
+	e := m.entries[key]
+	if e == nil {
+		e = &entry{byName: make(map[string]identity)}
+		m.entries[key] = e
+	}
+
+	if commitName == "" {
+		if properName != "" {
+			e.name = properName
+		}
+		if properEmail != "" {
+			e.email = properEmail
+		}
+		return
+	}
+	e.byName[strings.ToLower(commitName)] = identity{name: properName, email: properEmail}
+}
+
+// Resolve returns the canonical name and email for an identity, matching
+// emails and names without regard to case. A mapping for the exact name
+// and email wins over one for the email alone; parts a mapping leaves out
+// are kept.
+func (m *Mailmap) Resolve(name, email string) (string, string) {
+	if m == nil {
+		return name, email
+	}
+	e, ok := m.entries[strings.ToLower(email)]
+	if !ok {
+		return name, email
+	}
+
+	mapped := identity{name: e.name, email: e.email}
+	if byName, ok := e.byName[strings.ToLower(name)]; ok {
+		mapped = byName
+	}
+	if mapped.name != "" {
+		name = mapped.name
+	}
+	if mapped.email != "" {
+		email = mapped.email
+	}
+	return name, email
+}
+
+// ResolveSignature returns sig with its identity resolved, sharing sig when
+// nothing changes
+func (m *Mailmap) ResolveSignature(sig *objects.Signature) *objects.Signature {
+	if m == nil || sig == nil {
+		return sig
+	}
+	name, email := m.Resolve(sig.Name, sig.Email)
+	if name == sig.Name && email == sig.Email {
+		return sig
+	}
+	return &objects.Signature{Name: name, Email: email, When: sig.When}
+}
@@ -0,0 +1,84 @@
+package mailmap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+const testMailmap = `# canonical identities
+Jane Doe <jane@example.com>
+Jane Doe <jane@example.com> <jdoe@old-host.org>
+<ops@example.com> <root@build-01>
+Build Bot <bot@example.com> ci <ci@example.com>
+`
+
+func TestResolve(t *testing.T) {
+	m, err := Parse(strings.NewReader(testMailmap))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name, email         string
+		wantName, wantEmail string
+	}{
+		{"jane", "jane@example.com", "Jane Doe", "jane@example.com"},
+		{"J. Doe", "jdoe@old-host.org", "Jane Doe", "jane@example.com"},
+		{"J. Doe", "JDoe@Old-Host.org", "Jane Doe", "jane@example.com"},
+		{"root", "root@build-01", "root", "ops@example.com"},
+		{"ci", "ci@example.com", "Build Bot", "bot@example.com"},
+		{"CI", "ci@example.com", "Build Bot", "bot@example.com"},
+		{"someone else", "ci@example.com", "someone else", "ci@example.com"},
+		{"Unmapped", "unmapped@example.com", "Unmapped", "unmapped@example.com"},
+	}
+	for _, tt := range tests {
+		name, email := m.Resolve(tt.name, tt.email)
+		assert.Equal(t, tt.wantName, name, "%s <%s>", tt.name, tt.email)
+		assert.Equal(t, tt.wantEmail, email, "%s <%s>", tt.name, tt.email)
+	}
+}
+
+func TestResolveNil(t *testing.T) {
+	var m *Mailmap
+	name, email := m.Resolve("Jane", "jane@example.com")
+	assert.Equal(t, "Jane", name)
+	assert.Equal(t, "jane@example.com", email)
+
+	sig := &objects.Signature{Name: "Jane", Email: "jane@example.com", When: time.Now()}
+	assert.Same(t, sig, m.ResolveSignature(sig))
+}
+
+func TestResolveSignature(t *testing.T) {
+	m, err := Parse(strings.NewReader(testMailmap))
+	require.NoError(t, err)
+
+	when := time.Unix(1700000000, 0)
+	sig := m.ResolveSignature(&objects.Signature{Name: "jd", Email: "jdoe@old-host.org", When: when})
+	assert.Equal(t, "Jane Doe", sig.Name)
+	assert.Equal(t, "jane@example.com", sig.Email)
+	assert.True(t, sig.When.Equal(when))
+}
+
+func TestLoad(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	m, err := Load(repo)
+	require.NoError(t, err)
+	name, email := m.Resolve("jd", "jdoe@old-host.org")
+	assert.Equal(t, "jd", name)
+	assert.Equal(t, "jdoe@old-host.org", email)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.WorkDir, ".mailmap"), []byte(testMailmap), 0644))
+	m, err = Load(repo)
+	require.NoError(t, err)
+	name, email = m.Resolve("jd", "jdoe@old-host.org")
+	assert.Equal(t, "Jane Doe", name)
+	assert.Equal(t, "jane@example.com", email)
+}
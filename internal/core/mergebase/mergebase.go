@@ -0,0 +1,300 @@
+package mergebase
+
+import (
+	"container/heap"
+	"slices"
+	"sort"
+
+	"github.com/unkn0wn-root/git-go/internal/core/commitgraph"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// Find returns the best common ancestors of a and b: the common ancestors
+// that are not themselves ancestors of another common ancestor. There is more
+// than one after criss-cross merges. The result is ordered newest committer
+// date first, so callers that need a single base take the first element. It
+// is empty when the histories are unrelated.
+func Find(repo *repository.Repository, a, b string) ([]string, error) {
+	if a == b {
+		return []string{a}, nil
+	}
+
+	w := newWalker(repo)
+
+	candidates, err := w.paintDownToCommon(a, b)
+	if err != nil {
+		return nil, err
+	}
+	best, err := w.removeRedundant(candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(best, func(i, j int) bool {
+		ti, tj := w.commits[best[i]].when, w.commits[best[j]].when
+		if ti != tj {
+			return ti > tj
+		}
+		return best[i] < best[j]
+	})
+
+	return best, nil
+}
+
+// IsAncestor reports whether ancestor is reachable from descendant, a
+// commit counting as its own ancestor
+func IsAncestor(repo *repository.Repository, ancestor, descendant string) (bool, error) {
+	bases, err := Find(repo, ancestor, descendant)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(bases, ancestor), nil
+}
+
+// paint flags record which sides of a merge-base walk reach a commit
+const (
+	paintA = 1 << iota
+	paintB
+	// paintStale marks ancestors of a common ancestor, which cannot be best
+	paintStale
+	paintResult
+)
+
+// paintDownToCommon walks back from a and b together, highest generation
+// and then newest first, painting every commit with the sides that reach
+// it. A commit painted by both is a common ancestor; its history is painted
+// stale, and the walk stops once only stale commits are queued, so the
+// history below the merge bases is not visited.
+func (w *walker) paintDownToCommon(a, b string) ([]string, error) {
+	paint := map[string]int{a: paintA, b: paintB}
+	queue := &commitQueue{}
+	nonStale := 0
+
+	push := func(hash string, stale bool) error {
+		info, err := w.commit(hash)
+		if err != nil {
+			return err
+		}
+		heap.Push(queue, queueEntry{hash: hash, info: info, stale: stale})
+		if !stale {
+			nonStale++
+		}
+		return nil
+	}
+	if err := push(a, false); err != nil {
+		return nil, err
+	}
+	if err := push(b, false); err != nil {
+		return nil, err
+	}
+
+	var found []string
+	for nonStale > 0 {
+		entry := heap.Pop(queue).(queueEntry)
+		if !entry.stale {
+			nonStale--
+		}
+
+		flags := paint[entry.hash] & (paintA | paintB | paintStale)
+		if flags&(paintA|paintB) == paintA|paintB {
+			if paint[entry.hash]&paintResult == 0 {
+				paint[entry.hash] |= paintResult
+				found = append(found, entry.hash)
+			}
+			flags |= paintStale
+		}
+
+		for _, parent := range entry.info.parents {
+			if paint[parent]&flags == flags {
+				continue
+			}
+			paint[parent] |= flags
+			if err := push(parent, flags&paintStale != 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// a common ancestor found early can turn out to lie below another
+	var candidates []string
+	for _, hash := range found {
+		if paint[hash]&paintStale == 0 {
+			candidates = append(candidates, hash)
+		}
+	}
+	return candidates, nil
+}
+
+// removeRedundant drops the candidates that another candidate reaches. The
+// walk from their parents stops at commits of lower generation than every
+// candidate, which cannot reach any of them.
+func (w *walker) removeRedundant(candidates []string) ([]string, error) {
+	if len(candidates) < 2 {
+		return candidates, nil
+	}
+
+	minGeneration := uint32(commitgraph.GenerationInfinity)
+	var stack []string
+	for _, hash := range candidates {
+		info := w.commits[hash]
+		minGeneration = min(minGeneration, info.generation)
+		stack = append(stack, info.parents...)
+	}
+
+	reached := make(map[string]bool)
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reached[current] {
+			continue
+		}
+		reached[current] = true
+
+		info, err := w.commit(current)
+		if err != nil {
+			return nil, err
+		}
+		if info.generation < minGeneration {
+			continue
+		}
+		stack = append(stack, info.parents...)
+	}
+
+	var best []string
+	for _, hash := range candidates {
+		if !reached[hash] {
+			best = append(best, hash)
+		}
+	}
+	return best, nil
+}
+
+// queueEntry is a commit waiting in a merge-base walk, with whether it was
+// stale when queued
+type queueEntry struct {
+	hash  string
+	info  *commitInfo
+	stale bool
+}
+
+// commitQueue is a max-heap of commits by generation, then committer date,
+// so that a commit comes out before any of its ancestors
+type commitQueue []queueEntry
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	a, b := q[i].info, q[j].info
+	if a.generation != b.generation {
+		return a.generation > b.generation
+	}
+	if a.when != b.when {
+		return a.when > b.when
+	}
+	return q[i].hash < q[j].hash
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x any) { *q = append(*q, x.(queueEntry)) }
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
+
+// Ancestors returns the set of commits reachable from starts, starts
+// included
+func Ancestors(repo *repository.Repository, starts ...string) (map[string]bool, error) {
+	return newWalker(repo).ancestors(starts)
+}
+
+// commitInfo is what a reachability walk needs to know of a commit
+type commitInfo struct {
+	parents []string
+	// when is the committer date in seconds since the epoch
+	when int64
+	// generation comes from the commit-graph, GenerationInfinity for a
+	// commit it does not hold
+	generation uint32
+}
+
+// walker hands out commits for reachability walks, from the commit-graph
+// when it holds them and by loading the commit object otherwise, caching
+// each so that it is looked up once however many walks pass it
+type walker struct {
+	repo    *repository.Repository
+	graph   *commitgraph.Graph
+	commits map[string]*commitInfo
+}
+
+func newWalker(repo *repository.Repository) *walker {
+	// the graph only speeds walks up; one that cannot be read is ignored
+	// and every commit loaded from the object store instead
+	graph, err := commitgraph.Load(repo)
+	if err != nil {
+		graph = nil
+	}
+	return &walker{repo: repo, graph: graph, commits: make(map[string]*commitInfo)}
+}
+
+func (w *walker) commit(hash string) (*commitInfo, error) {
+	if info, ok := w.commits[hash]; ok {
+		return info, nil
+	}
+
+	var info *commitInfo
+	if c, ok := w.graph.Lookup(hash); ok {
+		info = &commitInfo{parents: c.Parents, when: c.CommitTime, generation: c.Generation}
+	} else {
+		obj, err := w.repo.LoadObject(hash)
+		if err != nil {
+			return nil, errors.NewObjectError(hash, "commit", err)
+		}
+		commit, ok := obj.(*objects.Commit)
+		if !ok {
+			return nil, errors.NewObjectError(hash, "commit", errors.ErrInvalidCommit)
+		}
+		info = &commitInfo{
+			parents:    commit.Parents(),
+			when:       commit.Committer().When.Unix(),
+			generation: commitgraph.GenerationInfinity,
+		}
+	}
+
+	w.commits[hash] = info
+	return info, nil
+}
+
+// ancestors returns the set of commits reachable from starts, starts included
+func (w *walker) ancestors(starts []string) (map[string]bool, error) {
+	visited := make(map[string]bool)
+	queue := append([]string(nil), starts...)
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		commit, err := w.commit(current)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, parent := range commit.parents {
+			if !visited[parent] {
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	return visited, nil
+}
@@ -0,0 +1,324 @@
+package mergebase
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/commitgraph"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+// graph stores commits with increasing committer dates
+type graph struct {
+	t    testing.TB
+	repo *repository.Repository
+	tree string
+	tick int64
+}
+
+func newGraph(t testing.TB) *graph {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	tree, err := repo.StoreObject(objects.NewTree(nil))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+
+	return &graph{t: t, repo: repo, tree: tree}
+}
+
+func (g *graph) commit(message string, parents ...string) string {
+	g.t.Helper()
+
+	g.tick++
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000+g.tick, 0)}
+	hash, err := g.repo.StoreObject(objects.NewCommit(g.tree, parents, sig, sig, message))
+	if err != nil {
+		g.t.Fatalf("Failed to store commit: %v", err)
+	}
+	return hash
+}
+
+func TestFind_Linear(t *testing.T) {
+	g := newGraph(t)
+	root := g.commit("root")
+	a := g.commit("a", root)
+	b := g.commit("b", a)
+
+	bases, err := Find(g.repo, b, a)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(bases) != 1 || bases[0] != a {
+		t.Errorf("Expected [%s], got %v", a, bases)
+	}
+
+	if bases, _ := Find(g.repo, b, b); len(bases) != 1 || bases[0] != b {
+		t.Errorf("Expected a commit to be its own merge base, got %v", bases)
+	}
+}
+
+// A naive breadth-first intersection from a reaches root through the merge's
+// second parent before it reaches x along the longer first-parent chain
+func TestFind_PrefersLowestAncestor(t *testing.T) {
+	g := newGraph(t)
+	root := g.commit("root")
+	x := g.commit("x", root)
+	a1 := g.commit("a1", x)
+	a2 := g.commit("a2", a1)
+	a := g.commit("a", a2, root)
+	b := g.commit("b", x)
+
+	bases, err := Find(g.repo, a, b)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(bases) != 1 || bases[0] != x {
+		t.Errorf("Expected [%s], got %v", x, bases)
+	}
+}
+
+// Criss-cross: each side merged the other's first commit, so both are best
+// common ancestors and neither is an ancestor of the other
+func TestFind_CrissCross(t *testing.T) {
+	g := newGraph(t)
+	root := g.commit("root")
+	left := g.commit("left", root)
+	right := g.commit("right", root)
+	leftMerge := g.commit("left merge", left, right)
+	rightMerge := g.commit("right merge", right, left)
+	a := g.commit("a", leftMerge)
+	b := g.commit("b", rightMerge)
+
+	bases, err := Find(g.repo, a, b)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	// newest first
+	if len(bases) != 2 || bases[0] != right || bases[1] != left {
+		t.Errorf("Expected [%s %s], got %v", right, left, bases)
+	}
+}
+
+func TestFind_Unrelated(t *testing.T) {
+	g := newGraph(t)
+	a := g.commit("a")
+	b := g.commit("b")
+
+	bases, err := Find(g.repo, a, b)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(bases) != 0 {
+		t.Errorf("Expected no merge base, got %v", bases)
+	}
+}
+
+// The graph file answers for the commits written into it, and commits made
+// afterwards are loaded from the object store
+func TestFind_CommitGraph(t *testing.T) {
+	g := newGraph(t)
+	root := g.commit("root")
+	left := g.commit("left", root)
+	right := g.commit("right", root)
+	leftMerge := g.commit("left merge", left, right)
+	rightMerge := g.commit("right merge", right, left)
+
+	if _, err := commitgraph.Write(g.repo); err != nil {
+		t.Fatalf("Failed to write commit-graph: %v", err)
+	}
+	a := g.commit("a", leftMerge)
+	b := g.commit("b", rightMerge)
+
+	bases, err := Find(g.repo, a, b)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(bases) != 2 || bases[0] != right || bases[1] != left {
+		t.Errorf("Expected [%s %s], got %v", right, left, bases)
+	}
+
+	ancestors, err := Ancestors(g.repo, a)
+	if err != nil {
+		t.Fatalf("Ancestors failed: %v", err)
+	}
+	for _, hash := range []string{a, leftMerge, left, right, root} {
+		if !ancestors[hash] {
+			t.Errorf("Expected %s to be an ancestor of a", hash)
+		}
+	}
+	if len(ancestors) != 5 {
+		t.Errorf("Expected 5 ancestors, got %d", len(ancestors))
+	}
+}
+
+// bestCommonAncestors computes merge bases the slow, obvious way: every
+// common ancestor that no other common ancestor reaches
+func bestCommonAncestors(t *testing.T, repo *repository.Repository, a, b string) []string {
+	ofA, err := Ancestors(repo, a)
+	if err != nil {
+		t.Fatalf("Ancestors failed: %v", err)
+	}
+	ofB, err := Ancestors(repo, b)
+	if err != nil {
+		t.Fatalf("Ancestors failed: %v", err)
+	}
+
+	var common []string
+	for hash := range ofA {
+		if ofB[hash] {
+			common = append(common, hash)
+		}
+	}
+
+	var best []string
+	for _, candidate := range common {
+		dominated := false
+		for _, other := range common {
+			if other == candidate {
+				continue
+			}
+			reach, err := Ancestors(repo, other)
+			if err != nil {
+				t.Fatalf("Ancestors failed: %v", err)
+			}
+			if reach[candidate] {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			best = append(best, candidate)
+		}
+	}
+	slices.Sort(best)
+	return best
+}
+
+func TestFind_MatchesExhaustive(t *testing.T) {
+	g := newGraph(t)
+	rng := rand.New(rand.NewSource(1))
+
+	commits := []string{g.commit("root")}
+	for i := 1; i < 40; i++ {
+		parents := []string{commits[rng.Intn(len(commits))]}
+		if rng.Intn(3) == 0 {
+			if other := commits[rng.Intn(len(commits))]; other != parents[0] {
+				parents = append(parents, other)
+			}
+		}
+		commits = append(commits, g.commit(fmt.Sprintf("c%d", i), parents...))
+	}
+
+	check := func(t *testing.T) {
+		for i := 0; i < 60; i++ {
+			a, b := commits[rng.Intn(len(commits))], commits[rng.Intn(len(commits))]
+			bases, err := Find(g.repo, a, b)
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+			slices.Sort(bases)
+			if want := bestCommonAncestors(t, g.repo, a, b); !slices.Equal(bases, want) {
+				t.Errorf("Find(%s, %s): expected %v, got %v", a, b, want, bases)
+			}
+		}
+	}
+
+	t.Run("Objects", check)
+	if _, err := commitgraph.Write(g.repo); err != nil {
+		t.Fatalf("Failed to write commit-graph: %v", err)
+	}
+	t.Run("CommitGraph", check)
+}
+
+// deepHistory stores a trunk of depth commits and two branches of ten
+// commits forked from its tip, returning the branch tips and the fork point
+func deepHistory(b *testing.B, depth int) (*graph, string, string, string) {
+	g := newGraph(b)
+	tip := g.commit("root")
+	for i := 1; i < depth; i++ {
+		tip = g.commit(fmt.Sprintf("trunk %d", i), tip)
+	}
+	left, right := tip, tip
+	for i := 0; i < 10; i++ {
+		left = g.commit(fmt.Sprintf("left %d", i), left)
+		right = g.commit(fmt.Sprintf("right %d", i), right)
+	}
+	return g, left, right, tip
+}
+
+func BenchmarkFind_DeepHistory(b *testing.B) {
+	g, left, right, fork := deepHistory(b, 2000)
+
+	run := func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bases, err := Find(g.repo, left, right)
+			if err != nil || !slices.Equal(bases, []string{fork}) {
+				b.Fatalf("Expected [%s], got %v, %v", fork, bases, err)
+			}
+		}
+	}
+
+	b.Run("Objects", run)
+	if _, err := commitgraph.Write(g.repo); err != nil {
+		b.Fatalf("Failed to write commit-graph: %v", err)
+	}
+	b.Run("CommitGraph", run)
+}
+
+// BenchmarkFind_Diverged finds the fork point of two branches a hundred
+// commits long on trunks of growing depth. The walk stops at the fork, so
+// its cost stays flat as the trunk grows, where intersecting the full
+// ancestor sets grows with it.
+func BenchmarkFind_Diverged(b *testing.B) {
+	for _, depth := range []int{100, 1000, 4000} {
+		g := newGraph(b)
+		fork := g.commit("root")
+		for i := 1; i < depth; i++ {
+			fork = g.commit(fmt.Sprintf("trunk %d", i), fork)
+		}
+		left, right := fork, fork
+		for i := 0; i < 100; i++ {
+			left = g.commit(fmt.Sprintf("left %d", i), left)
+			right = g.commit(fmt.Sprintf("right %d", i), right)
+		}
+
+		b.Run(fmt.Sprintf("PaintDown/depth=%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bases, err := Find(g.repo, left, right)
+				if err != nil || !slices.Equal(bases, []string{fork}) {
+					b.Fatalf("Expected [%s], got %v, %v", fork, bases, err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("Intersection/depth=%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ofLeft, err := Ancestors(g.repo, left)
+				if err != nil {
+					b.Fatal(err)
+				}
+				ofRight, err := Ancestors(g.repo, right)
+				if err != nil {
+					b.Fatal(err)
+				}
+				common := 0
+				for hash := range ofRight {
+					if ofLeft[hash] {
+						common++
+					}
+				}
+				if common != depth {
+					b.Fatalf("Expected %d common ancestors, got %d", depth, common)
+				}
+			}
+		})
+	}
+}
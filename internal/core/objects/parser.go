@@ -22,6 +22,10 @@ const (
 	parentHeader    = "parent"
 	authorHeader    = "author"
 	committerHeader = "committer"
+	gpgSigHeader    = "gpgsig"
+
+	// tag header keys
+	objectHeader = "object"
 
 	// radix for integer parsing
 	decimalBase     = 10
@@ -101,7 +105,9 @@ func parseCommit(data []byte) (*Commit, error) {
 	var author *Signature
 	var committer *Signature
 	var messageLines []string
+	var sigLines []string
 	inMessage := false
+	lastKey := ""
 
 	// Parse Git commit format: headers followed by blank line and message
 	for scanner.Scan() {
@@ -116,12 +122,21 @@ func parseCommit(data []byte) (*Commit, error) {
 			continue
 		}
 
+		// multi-line header values continue on lines starting with a space
+		if strings.HasPrefix(line, " ") {
+			if lastKey == gpgSigHeader {
+				sigLines = append(sigLines, line[1:])
+			}
+			continue
+		}
+
 		parts := strings.SplitN(line, " ", headerParts)
 		if len(parts) != headerParts {
 			return nil, errors.NewGitError("parse-commit", "", fmt.Errorf("invalid commit line: %s", line))
 		}
 
 		key, value := parts[0], parts[1]
+		lastKey = key
 		switch key {
 		case treeHeader:
 			tree = value
@@ -139,6 +154,8 @@ func parseCommit(data []byte) (*Commit, error) {
 			if err != nil {
 				return nil, errors.NewGitError("parse-commit", "", fmt.Errorf("invalid committer signature: %w", err))
 			}
+		case gpgSigHeader:
+			sigLines = append(sigLines, value)
 		}
 	}
 
@@ -156,7 +173,70 @@ func parseCommit(data []byte) (*Commit, error) {
 
 	message := strings.Join(messageLines, "\n")
 
-	return NewCommit(tree, parents, author, committer, message), nil
+	commit := NewCommit(tree, parents, author, committer, message)
+	commit.gpgSig = strings.Join(sigLines, "\n")
+	return commit, nil
+}
+
+// ExtractSignature splits raw commit data into the signed payload and the
+// gpgsig header value. The payload is the data byte-for-byte minus the
+// header, so it verifies even when re-serializing would not reproduce it.
+func ExtractSignature(data []byte) ([]byte, string) {
+	var payload bytes.Buffer
+	var sigLines []string
+	inSig := false
+
+	rest := data
+	for len(rest) > 0 {
+		end := bytes.IndexByte(rest, '\n')
+		if end == -1 {
+			end = len(rest) - 1
+		}
+		line := rest[:end+1]
+		rest = rest[end+1:]
+
+		trimmed := bytes.TrimSuffix(line, []byte("\n"))
+		if len(trimmed) == 0 {
+			// end of headers, the message is copied verbatim
+			payload.Write(line)
+			payload.Write(rest)
+			break
+		}
+
+		if inSig && trimmed[0] == ' ' {
+			sigLines = append(sigLines, string(trimmed[1:]))
+			continue
+		}
+		inSig = false
+
+		if value, ok := bytes.CutPrefix(trimmed, []byte(gpgSigHeader+" ")); ok {
+			sigLines = append(sigLines, string(value))
+			inSig = true
+			continue
+		}
+
+		payload.Write(line)
+	}
+
+	return payload.Bytes(), strings.Join(sigLines, "\n")
+}
+
+// ParseTagTarget returns the hash named by the object header of an annotated tag
+func ParseTagTarget(data []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		if key == objectHeader {
+			return value, nil
+		}
+	}
+
+	return "", errors.NewGitError("parse-tag", "", errors.ErrInvalidObjectFormat)
 }
 
 func SerializeObject(obj Object) []byte {
@@ -0,0 +1,160 @@
+package objects
+
+import "strings"
+
+// trailer blocks containing one of these need only be 25% trailers
+var gitGeneratedTrailerPrefixes = []string{
+	"Signed-off-by: ",
+	"(cherry picked from commit ",
+}
+
+// Trailers parses the trailer block of the commit message
+func (c *Commit) Trailers() map[string][]string {
+	return ParseTrailers(c.message)
+}
+
+// ParseTrailers returns the "Key: value" trailers from the last paragraph of
+// message, keyed as written. Following Git, the paragraph only counts as a
+// trailer block when it is not the subject and consists entirely of trailers,
+// or at least 25% trailers when it holds a Git-generated one such as
+// Signed-off-by. Indented lines continue the previous trailer's value.
+func ParseTrailers(message string) map[string][]string {
+	trailers := make(map[string][]string)
+
+	lines := messageLines(message)
+	start := trailerBlockStart(lines)
+	if start < 0 {
+		return trailers
+	}
+
+	lastKey := ""
+	for _, line := range lines[start:] {
+		if isContinuation(line) {
+			if lastKey != "" {
+				values := trailers[lastKey]
+				values[len(values)-1] += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		key, value, ok := parseTrailerLine(line)
+		if !ok {
+			lastKey = ""
+			continue
+		}
+		trailers[key] = append(trailers[key], value)
+		lastKey = key
+	}
+
+	return trailers
+}
+
+// AppendTrailer adds "key: value" to the trailer block of message, starting a
+// new block after a blank line when there is none. The trailer is not repeated
+// when it is already the last line.
+func AppendTrailer(message, key, value string) string {
+	trailer := key + ": " + value
+
+	lines := messageLines(message)
+	body := strings.Join(lines, "\n")
+
+	if trailerBlockStart(lines) < 0 {
+		if body == "" {
+			return trailer
+		}
+		return body + "\n\n" + trailer
+	}
+
+	if lines[len(lines)-1] == trailer {
+		return body
+	}
+	return body + "\n" + trailer
+}
+
+// messageLines splits message into lines without trailing blank lines
+func messageLines(message string) []string {
+	message = strings.TrimRight(message, " \t\n")
+	if message == "" {
+		return nil
+	}
+	return strings.Split(message, "\n")
+}
+
+// trailerBlockStart returns the index of the first line of the trailer block,
+// or -1 when the last paragraph is not one
+func trailerBlockStart(lines []string) int {
+	start := len(lines)
+	for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+		start--
+	}
+
+	// a single paragraph is the subject, never a trailer block
+	if start == 0 {
+		return -1
+	}
+
+	var trailerLines, otherLines int
+	gitGenerated := false
+	inTrailer := false
+
+	for _, line := range lines[start:] {
+		if isContinuation(line) && inTrailer {
+			continue
+		}
+
+		generated := isGitGenerated(line)
+		if generated {
+			gitGenerated = true
+		}
+
+		if _, _, ok := parseTrailerLine(line); ok || generated {
+			trailerLines++
+			inTrailer = true
+			continue
+		}
+
+		otherLines++
+		inTrailer = false
+	}
+
+	if trailerLines == 0 {
+		return -1
+	}
+	if otherLines == 0 || (gitGenerated && trailerLines*4 >= trailerLines+otherLines) {
+		return start
+	}
+	return -1
+}
+
+func isGitGenerated(line string) bool {
+	for _, prefix := range gitGeneratedTrailerPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isContinuation(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t')
+}
+
+// parseTrailerLine splits "Key: value" where the key is letters, digits and dashes
+func parseTrailerLine(line string) (string, string, bool) {
+	sep := strings.IndexByte(line, ':')
+	if sep <= 0 {
+		return "", "", false
+	}
+
+	key := strings.TrimRight(line[:sep], " \t")
+	if key == "" {
+		return "", "", false
+	}
+	for _, r := range key {
+		if !(r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return "", "", false
+		}
+	}
+
+	return key, strings.TrimSpace(line[sep+1:]), true
+}
@@ -86,6 +86,17 @@ type TreeEntry struct {
 	Hash string
 }
 
+// IsDir reports whether the entry is a subtree
+func (e TreeEntry) IsDir() bool {
+	return e.Mode&fileTypeMask == FileModeTree
+}
+
+// IsSubmodule reports whether the entry is a gitlink, the commit a submodule
+// is checked out at. The commit lives in the submodule's own repository.
+func (e TreeEntry) IsSubmodule() bool {
+	return e.Mode&fileTypeMask == FileModeCommit
+}
+
 type FileMode uint32
 
 const (
@@ -93,12 +104,22 @@ const (
 	FileModeExecutable FileMode = 0o100755
 	FileModeSymlink    FileMode = 0o120000
 	FileModeTree       FileMode = 0o040000
+	FileModeCommit     FileMode = 0o160000
+
+	// fileTypeMask selects the bits of a mode that give the entry's type
+	fileTypeMask FileMode = 0o170000
 )
 
 func (m FileMode) String() string {
 	return fmt.Sprintf("%06o", uint32(m))
 }
 
+// treeString is the mode as tree objects store it, without the leading zero
+// String pads a subtree's mode with
+func (m FileMode) treeString() string {
+	return strconv.FormatUint(uint64(m), 8)
+}
+
 func ParseFileMode(s string) (FileMode, error) {
 	mode, err := strconv.ParseUint(s, 8, 32)
 	if err != nil {
@@ -125,7 +146,7 @@ func (t *Tree) Type() ObjectType {
 func (t *Tree) Size() int64 {
 	var size int64
 	for _, entry := range t.entries {
-		size += int64(len(entry.Mode.String()) + 1 + len(entry.Name) + 1 + 20)
+		size += int64(len(entry.Mode.treeString()) + 1 + len(entry.Name) + 1 + 20)
 	}
 	return size
 }
@@ -133,7 +154,7 @@ func (t *Tree) Size() int64 {
 func (t *Tree) Data() []byte {
 	var buf bytes.Buffer
 	for _, entry := range t.entries {
-		buf.WriteString(entry.Mode.String())
+		buf.WriteString(entry.Mode.treeString())
 		buf.WriteByte(' ')
 		buf.WriteString(entry.Name)
 		buf.WriteByte(0)
@@ -210,6 +231,7 @@ type Commit struct {
 	parents   []string
 	author    *Signature
 	committer *Signature
+	gpgSig    string
 	message   string
 }
 
@@ -235,11 +257,25 @@ func (c *Commit) Size() int64 {
 	}
 	size += int64(len("author ") + len(c.author.String()) + 1)
 	size += int64(len("committer ") + len(c.committer.String()) + 1)
+	if c.gpgSig != "" {
+		// continuation lines carry a leading space
+		size += int64(len("gpgsig ") + len(c.gpgSig) + strings.Count(c.gpgSig, "\n") + 1)
+	}
 	size += int64(1 + len(c.message))
 	return size
 }
 
 func (c *Commit) Data() []byte {
+	return c.serialize(true)
+}
+
+// Payload returns the commit data without the gpgsig header, which is the
+// content a commit signature is made over
+func (c *Commit) Payload() []byte {
+	return c.serialize(false)
+}
+
+func (c *Commit) serialize(withSignature bool) []byte {
 	var buf bytes.Buffer
 	buf.WriteString("tree ")
 	buf.WriteString(c.tree)
@@ -258,6 +294,13 @@ func (c *Commit) Data() []byte {
 	buf.WriteString("committer ")
 	buf.WriteString(c.committer.String())
 	buf.WriteByte('\n')
+
+	if withSignature && c.gpgSig != "" {
+		buf.WriteString("gpgsig ")
+		buf.WriteString(strings.ReplaceAll(c.gpgSig, "\n", "\n "))
+		buf.WriteByte('\n')
+	}
+
 	buf.WriteByte('\n')
 	buf.WriteString(c.message)
 
@@ -291,3 +334,13 @@ func (c *Commit) Committer() *Signature {
 func (c *Commit) Message() string {
 	return c.message
 }
+
+// GPGSignature returns the armored signature from the gpgsig header, or an
+// empty string for unsigned commits
+func (c *Commit) GPGSignature() string {
+	return c.gpgSig
+}
+
+func (c *Commit) SetGPGSignature(sig string) {
+	c.gpgSig = strings.TrimRight(sig, "\n")
+}
@@ -1,11 +1,15 @@
 package objects
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 func TestBlob(t *testing.T) {
@@ -68,6 +72,39 @@ func TestCommit(t *testing.T) {
 	assert.True(t, commit.Size() > 0)
 }
 
+func TestCommitGPGSignature(t *testing.T) {
+	sig := &Signature{
+		Name:  "John Doe",
+		Email: "john@example.com",
+		When:  time.Unix(1234567890, 0),
+	}
+	armor := "-----BEGIN PGP SIGNATURE-----\n\niQEzBAABCAAdFiEE\n=abcd\n-----END PGP SIGNATURE-----"
+
+	commit := NewCommit("abc123", []string{"parent1"}, sig, sig, "Signed change\n\nBody")
+	unsigned := commit.Data()
+	commit.SetGPGSignature(armor + "\n")
+
+	data := commit.Data()
+	assert.Equal(t, int64(len(data)), commit.Size())
+	assert.Equal(t, unsigned, commit.Payload())
+	assert.Contains(t, string(data), "\ngpgsig -----BEGIN PGP SIGNATURE-----\n \n iQEzBAABCAAdFiEE\n =abcd\n -----END PGP SIGNATURE-----\n\nSigned change")
+
+	parsed, err := ParseObject(ObjectTypeCommit, data)
+	assert.NoError(t, err)
+	parsedCommit := parsed.(*Commit)
+	assert.Equal(t, armor, parsedCommit.GPGSignature())
+	assert.Equal(t, "Signed change\n\nBody", parsedCommit.Message())
+	assert.Equal(t, data, parsedCommit.Data())
+
+	payload, extracted := ExtractSignature(data)
+	assert.Equal(t, armor, extracted)
+	assert.Equal(t, unsigned, payload)
+
+	payload, extracted = ExtractSignature(unsigned)
+	assert.Empty(t, extracted)
+	assert.Equal(t, unsigned, payload)
+}
+
 func TestParseSignature(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -117,6 +154,18 @@ func TestParseSignature(t *testing.T) {
 	}
 }
 
+func TestTreeEntryIsDir(t *testing.T) {
+	assert.True(t, TreeEntry{Mode: FileModeTree}.IsDir())
+	assert.False(t, TreeEntry{Mode: FileModeBlob}.IsDir())
+	assert.False(t, TreeEntry{Mode: FileModeExecutable}.IsDir())
+	assert.False(t, TreeEntry{Mode: FileModeSymlink}.IsDir())
+	assert.False(t, TreeEntry{Mode: FileModeCommit}.IsDir())
+
+	mode, err := ParseFileMode("40000")
+	require.NoError(t, err)
+	assert.True(t, TreeEntry{Mode: mode}.IsDir())
+}
+
 func TestFileMode(t *testing.T) {
 	tests := []struct {
 		mode     FileMode
@@ -126,6 +175,7 @@ func TestFileMode(t *testing.T) {
 		{FileModeExecutable, "100755"},
 		{FileModeSymlink, "120000"},
 		{FileModeTree, "040000"},
+		{FileModeCommit, "160000"},
 	}
 
 	for _, tt := range tests {
@@ -163,3 +213,170 @@ func TestObjectType(t *testing.T) {
 	_, err := ParseObjectType("invalid")
 	assert.Error(t, err)
 }
+
+func TestParseTrailers(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected map[string][]string
+	}{
+		{
+			name:     "subject only",
+			message:  "Fix: handle empty input",
+			expected: map[string][]string{},
+		},
+		{
+			name:     "body without trailers",
+			message:  "Add feature\n\nThis explains the change.\nAcross two lines.\n",
+			expected: map[string][]string{},
+		},
+		{
+			name:    "trailer block",
+			message: "Add feature\n\nBody text.\n\nSigned-off-by: Jane Doe <jane@example.com>\nCo-authored-by: John Doe <john@example.com>\nCo-authored-by: Ann Roe <ann@example.com>\n",
+			expected: map[string][]string{
+				"Signed-off-by":  {"Jane Doe <jane@example.com>"},
+				"Co-authored-by": {"John Doe <john@example.com>", "Ann Roe <ann@example.com>"},
+			},
+		},
+		{
+			name:    "trailers right after subject",
+			message: "Add feature\n\nReviewed-by: Ann Roe <ann@example.com>",
+			expected: map[string][]string{
+				"Reviewed-by": {"Ann Roe <ann@example.com>"},
+			},
+		},
+		{
+			name:    "continuation lines",
+			message: "Add feature\n\nNote: this trailer\n  wraps onto a second line\nAcked-by: Bob",
+			expected: map[string][]string{
+				"Note":     {"this trailer wraps onto a second line"},
+				"Acked-by": {"Bob"},
+			},
+		},
+		{
+			name:     "mixed paragraph is not a trailer block",
+			message:  "Add feature\n\nFixes: #12\nthis line is prose",
+			expected: map[string][]string{},
+		},
+		{
+			name:    "git-generated trailer tolerates prose",
+			message: "Add feature\n\nSigned-off-by: Jane Doe <jane@example.com>\nsome prose\n(cherry picked from commit abc123)",
+			expected: map[string][]string{
+				"Signed-off-by": {"Jane Doe <jane@example.com>"},
+			},
+		},
+		{
+			name:     "trailer block in body paragraph only",
+			message:  "Add feature\n\nFixes: #12\n\nFinal words.",
+			expected: map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseTrailers(tt.message))
+		})
+	}
+
+	commit := NewCommit("tree", nil, &Signature{}, &Signature{}, "Subject\n\nCo-authored-by: A <a@example.com>")
+	assert.Equal(t, []string{"A <a@example.com>"}, commit.Trailers()["Co-authored-by"])
+}
+
+func TestAppendTrailer(t *testing.T) {
+	const signOff = "Jane Doe <jane@example.com>"
+
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{"subject only", "Add feature", "Add feature\n\nSigned-off-by: " + signOff},
+		{"body", "Add feature\n\nSome body.\n", "Add feature\n\nSome body.\n\nSigned-off-by: " + signOff},
+		{"existing block", "Add feature\n\nAcked-by: Bob\n", "Add feature\n\nAcked-by: Bob\nSigned-off-by: " + signOff},
+		{"already signed", "Add feature\n\nSigned-off-by: " + signOff, "Add feature\n\nSigned-off-by: " + signOff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, AppendTrailer(tt.message, "Signed-off-by", signOff))
+		})
+	}
+}
+
+type treeMap map[string]*Tree
+
+func (m treeMap) LoadTree(hash string) (*Tree, error) {
+	if tree, ok := m[hash]; ok {
+		return tree, nil
+	}
+	return nil, errors.ErrObjectNotFound
+}
+
+func TestWalkTree(t *testing.T) {
+	trees := treeMap{
+		"root": NewTree([]TreeEntry{
+			{Mode: FileModeBlob, Name: "a.txt", Hash: "a"},
+			{Mode: FileModeTree, Name: "src", Hash: "src"},
+			{Mode: FileModeTree, Name: "vendor", Hash: "vendor"},
+		}),
+		"src": NewTree([]TreeEntry{
+			{Mode: FileModeTree, Name: "pkg", Hash: "pkg"},
+			{Mode: FileModeExecutable, Name: "run.sh", Hash: "run"},
+		}),
+		"pkg":    NewTree([]TreeEntry{{Mode: FileModeBlob, Name: "lib.go", Hash: "lib"}}),
+		"vendor": NewTree([]TreeEntry{{Mode: FileModeBlob, Name: "dep.go", Hash: "dep"}}),
+	}
+
+	var paths []string
+	err := WalkTree(trees, "root", func(path string, entry TreeEntry) error {
+		paths = append(paths, path)
+		if entry.Name == "vendor" {
+			return SkipTree
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "src", "src/pkg", "src/pkg/lib.go", "src/run.sh", "vendor"}, paths)
+
+	delete(trees, "pkg")
+	err = WalkTree(trees, "root", func(string, TreeEntry) error { return nil })
+	assert.ErrorIs(t, err, errors.ErrObjectNotFound)
+}
+
+func TestTreeGitlink(t *testing.T) {
+	// written by git for a README, a submodule at lib/dep and one at vendor
+	data, err := os.ReadFile(filepath.Join("testdata", "tree-gitlink"))
+	require.NoError(t, err)
+
+	obj, err := ParseObject(ObjectTypeTree, data)
+	require.NoError(t, err)
+	tree := obj.(*Tree)
+
+	entries := tree.Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, "vendor", entries[2].Name)
+	assert.Equal(t, FileModeCommit, entries[2].Mode)
+	assert.Equal(t, "2222222222222222222222222222222222222222", entries[2].Hash)
+	assert.True(t, entries[2].IsSubmodule())
+	assert.False(t, entries[2].IsDir())
+	assert.False(t, entries[0].IsSubmodule())
+	assert.False(t, entries[1].IsSubmodule())
+
+	serialized := SerializeObject(NewTree(entries))
+	assert.Equal(t, data, serialized[bytes.IndexByte(serialized, 0)+1:])
+
+	// the walk passes gitlinks on without loading the commits they name
+	trees := treeMap{
+		"root": tree,
+		entries[1].Hash: NewTree([]TreeEntry{
+			{Mode: FileModeCommit, Name: "dep", Hash: "1111111111111111111111111111111111111111"},
+		}),
+	}
+	var paths []string
+	err = WalkTree(trees, "root", func(path string, entry TreeEntry) error {
+		paths = append(paths, path)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README", "lib", "lib/dep", "vendor"}, paths)
+}
@@ -0,0 +1,49 @@
+package objects
+
+import (
+	stderrors "errors"
+	"path"
+)
+
+// SkipTree may be returned by a WalkTree callback for a subtree entry to
+// leave that subtree's entries unvisited; for other entries it is ignored
+var SkipTree = stderrors.New("skip this tree")
+
+// TreeLoader loads tree objects by hash
+type TreeLoader interface {
+	LoadTree(hash string) (*Tree, error)
+}
+
+// WalkTree calls fn for every entry beneath treeHash, depth first, with the
+// entry's path relative to that tree joined by forward slashes. A subtree is
+// passed to fn before its own entries. Submodule entries are passed like
+// files; the commits they name belong to another repository and are never
+// loaded.
+func WalkTree(loader TreeLoader, treeHash string, fn func(path string, entry TreeEntry) error) error {
+	return walkTree(loader, treeHash, "", fn)
+}
+
+func walkTree(loader TreeLoader, treeHash, prefix string, fn func(string, TreeEntry) error) error {
+	tree, err := loader.LoadTree(treeHash)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range tree.Entries() {
+		entryPath := path.Join(prefix, entry.Name)
+
+		if err := fn(entryPath, entry); err == SkipTree {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if err := walkTree(loader, entry.Hash, entryPath, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -3,17 +3,20 @@ package pack
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"crypto/sha1"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 
+	"github.com/unkn0wn-root/git-go/internal/core/delta"
 	"github.com/unkn0wn-root/git-go/internal/core/hash"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 type DeltaOpType int
@@ -34,10 +37,41 @@ const (
 )
 
 type PackProcessor struct {
+	// MaxCacheBytes bounds the inflated object data held in memory while a
+	// pack is processed; zero means no bound. Within a bound, objects are
+	// written out as soon as no unresolved delta needs them, and under
+	// pressure earlier, their data reinflated from the pack if needed again.
+	MaxCacheBytes int64
+
 	repo          *repository.Repository
 	packData      []byte
 	objectCache   map[int64]*PackObject
 	resolvedCache map[string]*PackObject
+	progress      Progress
+	messages      RemoteMessageHandler
+
+	// dependents counts, by base offset, the deltas not yet resolved
+	// against each object
+	dependents map[int64]int
+	// cached holds the objects whose Data counts toward cacheBytes, oldest
+	// first. Entries left behind by objects evicted elsewhere, or cached
+	// again since, are stale and dropped once they are half the queue.
+	cached         []cacheEntry
+	cacheGen       uint64
+	staleEntries   int
+	cacheBytes     int64
+	peakCacheBytes int64
+}
+
+// cacheEntry is an object's place in the eviction queue, live while the
+// object still has its data and was not queued again since
+type cacheEntry struct {
+	obj *PackObject
+	gen uint64
+}
+
+func (e cacheEntry) live() bool {
+	return e.obj.Data != nil && e.obj.cacheGen == e.gen
 }
 
 type PackObject struct {
@@ -51,6 +85,12 @@ type PackObject struct {
 	IsDelta       bool
 	PackType      int
 	RawData       []byte // Compressed or delta data
+
+	// dataOffset is where a non-delta's compressed data starts in the pack
+	dataOffset int
+	stored     bool
+	// cacheGen matches the entry of the cache queue that holds the object
+	cacheGen uint64
 }
 
 type PackHeader struct {
@@ -71,12 +111,39 @@ func NewPackProcessor(repo *repository.Repository) *PackProcessor {
 		repo:          repo,
 		objectCache:   make(map[int64]*PackObject),
 		resolvedCache: make(map[string]*PackObject),
+		dependents:    make(map[int64]int),
+		progress:      NoProgress,
+		messages:      StderrRemoteMessages,
+	}
+}
+
+// WithProgress reports unpacking, delta resolution and storing to progress.
+// It returns p for chaining.
+func (p *PackProcessor) WithProgress(progress Progress) *PackProcessor {
+	p.progress = progressOrDefault(progress)
+	return p
+}
+
+// WithRemoteMessages sends the server's sideband progress and error messages
+// to handler instead of stderr. It returns p for chaining.
+func (p *PackProcessor) WithRemoteMessages(handler RemoteMessageHandler) *PackProcessor {
+	if handler == nil {
+		handler = StderrRemoteMessages
 	}
+	p.messages = handler
+	return p
 }
 
 func (p *PackProcessor) ProcessPack(reader io.Reader) error {
+	return p.ProcessPackContext(context.Background(), reader)
+}
+
+// ProcessPackContext is ProcessPack that stops between objects once ctx is
+// done. Objects are only ever written whole, so a cancelled run leaves no
+// partial objects behind.
+func (p *PackProcessor) ProcessPackContext(ctx context.Context, reader io.Reader) error {
 	var err error
-	rawData, err := io.ReadAll(reader)
+	rawData, err := io.ReadAll(&contextReader{ctx: ctx, reader: reader})
 	if err != nil {
 		return fmt.Errorf("failed to read pack data: %w", err)
 	}
@@ -101,9 +168,6 @@ func (p *PackProcessor) ProcessPack(reader io.Reader) error {
 		return fmt.Errorf("failed to parse pack header: %w", err)
 	}
 
-	// Log pack processing summary
-	fmt.Printf("Processing pack: %d objects\n", header.Objects)
-
 	if header.Signature != "PACK" {
 		return fmt.Errorf("invalid pack signature: %s", header.Signature)
 	}
@@ -113,24 +177,37 @@ func (p *PackProcessor) ProcessPack(reader io.Reader) error {
 	}
 
 	// parse all objects without resolving deltas
-	if err := p.parseAllObjects(header.Objects); err != nil {
+	if err := p.parseAllObjects(ctx, header.Objects); err != nil {
 		return fmt.Errorf("failed to parse objects: %w", err)
 	}
 
 	// resolve delta objects
-	if err := p.resolveAllDeltas(); err != nil {
+	if err := p.resolveAllDeltas(ctx); err != nil {
 		return fmt.Errorf("failed to resolve deltas: %w", err)
 	}
 
 	// store all resolved objects
-	if err := p.storeAllObjects(); err != nil {
+	if err := p.storeAllObjects(ctx); err != nil {
 		return fmt.Errorf("failed to store objects: %w", err)
 	}
 
-	fmt.Printf("Pack processing complete: %d objects processed and stored\n", len(p.resolvedCache))
 	return nil
 }
 
+// contextReader fails reads once ctx is done so that reading a stalled
+// stream stops at the next chunk
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(buf []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(buf)
+}
+
 func (p *PackProcessor) extractPackFromPacketLine(data []byte) ([]byte, error) {
 	// check if this is already a pack file
 	if len(data) >= 4 && string(data[:4]) == "PACK" {
@@ -138,7 +215,7 @@ func (p *PackProcessor) extractPackFromPacketLine(data []byte) ([]byte, error) {
 	}
 
 	// parse Git smart protocol response
-	parser := &GitProtocolParser{data: data}
+	parser := &GitProtocolParser{data: data, messages: p.messages}
 	return parser.ExtractPackData()
 }
 
@@ -178,17 +255,27 @@ func (p *PackProcessor) parsePackHeader() (*PackHeader, error) {
 	}, nil
 }
 
-func (p *PackProcessor) parseAllObjects(objectCount uint32) error {
+func (p *PackProcessor) parseAllObjects(ctx context.Context, objectCount uint32) error {
 	offset := 12
 
 	for i := uint32(0); i < objectCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		obj, nextOffset, err := p.parsePackObject(offset)
 		if err != nil {
 			return fmt.Errorf("failed to parse object %d at offset %d: %w", i, offset, err)
 		}
 
 		p.objectCache[int64(offset)] = obj
+		if !obj.IsDelta {
+			if err := p.cache(obj); err != nil {
+				return err
+			}
+		}
 		offset = nextOffset
+		p.progress.Update("Unpacking objects", int(i)+1, int(objectCount))
 	}
 
 	return nil
@@ -213,7 +300,8 @@ func (p *PackProcessor) parsePackObject(offset int) (*PackObject, int, error) {
 
 	switch objType {
 	case OBJ_COMMIT, OBJ_TREE, OBJ_BLOB, OBJ_TAG:
-		obj.Type = p.packTypeToObjectType(objType)
+		obj.Type = packTypeToObjectType(objType)
+		obj.dataOffset = newOffset
 		var err error
 		obj.Data, newOffset, err = p.parseCompressedData(newOffset, size)
 		if err != nil {
@@ -277,28 +365,38 @@ func (p *PackProcessor) parseObjectHeader(offset int) (int, int64, int) {
 }
 
 func (p *PackProcessor) parseCompressedData(offset int, expectedSize int64) ([]byte, int, error) {
+	objData, err := p.inflate(offset, expectedSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// find the end of compressed data by trying to decompress
+	compressedSize := p.findCompressedDataEnd(offset)
+	return objData, offset + compressedSize, nil
+}
+
+// inflate decompresses the zlib stream at offset, which must hold exactly
+// expectedSize bytes
+func (p *PackProcessor) inflate(offset int, expectedSize int64) ([]byte, error) {
 	if offset >= len(p.packData) {
-		return nil, 0, fmt.Errorf("offset beyond data")
+		return nil, fmt.Errorf("offset beyond data")
 	}
 
 	reader, err := zlib.NewReader(bytes.NewReader(p.packData[offset:]))
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create zlib reader: %w", err)
+		return nil, fmt.Errorf("failed to create zlib reader: %w", err)
 	}
 	defer reader.Close()
 
 	objData, err := io.ReadAll(reader)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to decompress data: %w", err)
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
 	}
 
 	if int64(len(objData)) != expectedSize {
-		return nil, 0, fmt.Errorf("decompressed size mismatch: expected %d, got %d", expectedSize, len(objData))
+		return nil, fmt.Errorf("decompressed size mismatch: expected %d, got %d", expectedSize, len(objData))
 	}
-
-	// find the end of compressed data by trying to decompress
-	compressedSize := p.findCompressedDataEnd(offset)
-	return objData, offset + compressedSize, nil
+	return objData, nil
 }
 
 func (p *PackProcessor) findCompressedDataEnd(start int) int {
@@ -359,7 +457,7 @@ func (p *PackProcessor) parseRefDelta(offset int) (string, int, error) {
 	return baseHash, offset + 20, nil
 }
 
-func (p *PackProcessor) resolveAllDeltas() error {
+func (p *PackProcessor) resolveAllDeltas(ctx context.Context) error {
 	var nonDeltas []*PackObject
 	var deltas []*PackObject
 
@@ -370,17 +468,39 @@ func (p *PackProcessor) resolveAllDeltas() error {
 			nonDeltas = append(nonDeltas, obj)
 		}
 	}
+	// in pack order, deltas sharing a base tend to be resolved together
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Offset < deltas[j].Offset })
 
 	for _, obj := range nonDeltas {
 		p.resolvedCache[obj.Hash] = obj
 	}
 
+	for _, delta := range deltas {
+		switch delta.PackType {
+		case OBJ_OFS_DELTA:
+			p.dependents[delta.DeltaOffset]++
+		case OBJ_REF_DELTA:
+			if base := p.resolvedCache[delta.DeltaBaseHash]; base != nil {
+				p.dependents[base.Offset]++
+			}
+		}
+	}
+	for _, obj := range nonDeltas {
+		if err := p.release(obj); err != nil {
+			return err
+		}
+	}
+
 	resolving := make(map[int64]bool)
 
-	for _, delta := range deltas {
+	for i, delta := range deltas {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err := p.resolveDeltaRecursive(delta, resolving); err != nil {
 			return fmt.Errorf("failed to resolve delta at offset %d: %w", delta.Offset, err)
 		}
+		p.progress.Update("Resolving deltas", i+1, len(deltas))
 	}
 
 	return nil
@@ -400,21 +520,55 @@ func (p *PackProcessor) resolveDeltaRecursive(delta *PackObject, resolving map[i
 	resolving[delta.Offset] = true
 	defer delete(resolving, delta.Offset)
 
+	baseObj, err := p.findBase(delta, resolving)
+	if err != nil {
+		return err
+	}
+	baseData, err := p.data(baseObj)
+	if err != nil {
+		return err
+	}
+
+	delta.Data, err = p.applyDelta(baseData, delta.RawData)
+	if err != nil {
+		return fmt.Errorf("failed to apply delta: %w", err)
+	}
+
+	delta.Type = baseObj.Type
+	delta.Size = int64(len(delta.Data))
+	delta.Hash = hash.ComputeObjectHash(delta.Type.String(), delta.Data)
+
+	p.resolvedCache[delta.Hash] = delta
+
+	if err := p.cache(delta); err != nil {
+		return err
+	}
+	if p.dependents[baseObj.Offset] > 0 && p.objectCache[baseObj.Offset] == baseObj {
+		p.dependents[baseObj.Offset]--
+		if err := p.release(baseObj); err != nil {
+			return err
+		}
+	}
+	return p.release(delta)
+}
+
+// findBase returns the object delta applies to, resolving it first if it is
+// itself a delta. A REF_DELTA base outside the pack is loaded from the
+// repository.
+func (p *PackProcessor) findBase(delta *PackObject, resolving map[int64]bool) (*PackObject, error) {
 	var baseObj *PackObject
-	var err error
 	if delta.PackType == OBJ_OFS_DELTA {
 		// find base object by offset
 		baseObj = p.objectCache[delta.DeltaOffset]
 		if baseObj == nil {
-			return fmt.Errorf("base object not found at offset %d", delta.DeltaOffset)
+			return nil, fmt.Errorf("base object not found at offset %d", delta.DeltaOffset)
 		}
 
 		// ff base is a delta, resolve it first
 		if baseObj.IsDelta {
 			if err := p.resolveDeltaRecursive(baseObj, resolving); err != nil {
-				return fmt.Errorf("failed to resolve base delta: %w", err)
+				return nil, fmt.Errorf("failed to resolve base delta: %w", err)
 			}
-			baseObj = p.resolvedCache[baseObj.Hash]
 		}
 
 	} else if delta.PackType == OBJ_REF_DELTA {
@@ -431,7 +585,7 @@ func (p *PackProcessor) resolveDeltaRecursive(delta *PackObject, resolving map[i
 			// ff base is a delta, resolve it first
 			if baseObj != nil && baseObj.IsDelta {
 				if err := p.resolveDeltaRecursive(baseObj, resolving); err != nil {
-					return fmt.Errorf("failed to resolve base delta: %w", err)
+					return nil, fmt.Errorf("failed to resolve base delta: %w", err)
 				}
 				baseObj = p.resolvedCache[baseObj.Hash]
 			}
@@ -439,7 +593,7 @@ func (p *PackProcessor) resolveDeltaRecursive(delta *PackObject, resolving map[i
 			if baseObj == nil {
 				obj, err := p.repo.LoadObject(delta.DeltaBaseHash)
 				if err != nil {
-					return fmt.Errorf("base object %s not found: %w", delta.DeltaBaseHash, err)
+					return nil, fmt.Errorf("base object %s not found: %w", delta.DeltaBaseHash, err)
 				}
 
 				baseObj = &PackObject{
@@ -453,20 +607,108 @@ func (p *PackProcessor) resolveDeltaRecursive(delta *PackObject, resolving map[i
 	}
 
 	if baseObj == nil {
-		return fmt.Errorf("could not find base object")
+		return nil, fmt.Errorf("could not find base object")
 	}
+	return baseObj, nil
+}
 
-	delta.Data, err = p.applyDelta(baseObj.Data, delta.RawData)
+// data returns obj's content, reinflating a non-delta from the pack or
+// reapplying a delta to its base when the content was evicted
+func (p *PackProcessor) data(obj *PackObject) ([]byte, error) {
+	if obj.Data != nil {
+		return obj.Data, nil
+	}
+
+	var err error
+	var data []byte
+	if obj.IsDelta {
+		var base *PackObject
+		if base, err = p.findBase(obj, make(map[int64]bool)); err != nil {
+			return nil, err
+		}
+		var baseData []byte
+		if baseData, err = p.data(base); err != nil {
+			return nil, err
+		}
+		data, err = p.applyDelta(baseData, obj.RawData)
+	} else {
+		data, err = p.inflate(obj.dataOffset, obj.Size)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to apply delta: %w", err)
+		return nil, fmt.Errorf("failed to reload object at offset %d: %w", obj.Offset, err)
 	}
 
-	delta.Type = baseObj.Type
-	delta.Size = int64(len(delta.Data))
-	delta.Hash = hash.ComputeObjectHash(delta.Type.String(), delta.Data)
+	obj.Data = data
+	if err := p.cache(obj); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
 
-	p.resolvedCache[delta.Hash] = delta
+// cache counts obj's data toward MaxCacheBytes, evicting the oldest cached
+// objects while over it
+func (p *PackProcessor) cache(obj *PackObject) error {
+	p.cacheGen++
+	obj.cacheGen = p.cacheGen
+	p.cached = append(p.cached, cacheEntry{obj: obj, gen: p.cacheGen})
+	p.cacheBytes += int64(len(obj.Data))
+	// the peak is what was held at once, before eviction brings it down
+	p.peakCacheBytes = max(p.peakCacheBytes, p.cacheBytes)
+
+	for p.MaxCacheBytes > 0 && p.cacheBytes > p.MaxCacheBytes && len(p.cached) > 0 {
+		oldest := p.cached[0]
+		p.cached = p.cached[1:]
+		if !oldest.live() {
+			p.staleEntries--
+			continue
+		}
+		if err := p.evict(oldest.obj); err != nil {
+			return err
+		}
+	}
+
+	p.dropStale()
+	return nil
+}
+
+// dropStale compacts the cache queue once half of it is stale entries
+func (p *PackProcessor) dropStale() {
+	if p.staleEntries > len(p.cached)/2 {
+		p.cached = slices.DeleteFunc(p.cached, func(e cacheEntry) bool { return !e.live() })
+		p.staleEntries = 0
+	}
+}
+
+// release evicts obj once no unresolved delta depends on it, leaving its
+// entry in the cache queue stale
+func (p *PackProcessor) release(obj *PackObject) error {
+	if p.MaxCacheBytes == 0 || p.dependents[obj.Offset] > 0 {
+		return nil
+	}
+	if obj.Data != nil {
+		p.staleEntries++
+	}
+	if err := p.evict(obj); err != nil {
+		return err
+	}
+	p.dropStale()
+	return nil
+}
 
+// evict writes obj to the repository, if it is not there yet, and drops
+// its data from memory
+func (p *PackProcessor) evict(obj *PackObject) error {
+	if obj.Data == nil {
+		return nil
+	}
+	if !obj.stored {
+		if err := p.storeObject(obj); err != nil {
+			return err
+		}
+		obj.stored = true
+	}
+	p.cacheBytes -= int64(len(obj.Data))
+	obj.Data = nil
 	return nil
 }
 
@@ -522,114 +764,14 @@ func (p *PackProcessor) resolveDelta(delta *PackObject) error {
 }
 
 func (p *PackProcessor) applyDelta(baseData, deltaData []byte) ([]byte, error) {
-	if len(deltaData) == 0 {
-		return nil, fmt.Errorf("empty delta data")
-	}
-
-	offset := 0
-
-	baseSize, offset := p.readDeltaSize(deltaData, offset)
-	if baseSize != int64(len(baseData)) {
-		return nil, fmt.Errorf("base size mismatch: expected %d, got %d", len(baseData), baseSize)
-	}
-
-	resultSize, offset := p.readDeltaSize(deltaData, offset)
-
-	result := make([]byte, 0, resultSize)
-	for offset < len(deltaData) {
-		instruction := deltaData[offset]
-		offset++
-
-		if instruction&0x80 != 0 {
-			// Copy instruction
-			copyOffset := int64(0)
-			copySize := int64(0)
-
-			// read copy offset
-			if instruction&0x01 != 0 {
-				copyOffset |= int64(deltaData[offset])
-				offset++
-			}
-			if instruction&0x02 != 0 {
-				copyOffset |= int64(deltaData[offset]) << 8
-				offset++
-			}
-			if instruction&0x04 != 0 {
-				copyOffset |= int64(deltaData[offset]) << 16
-				offset++
-			}
-			if instruction&0x08 != 0 {
-				copyOffset |= int64(deltaData[offset]) << 24
-				offset++
-			}
-
-			// Read copy size
-			if instruction&0x10 != 0 {
-				copySize |= int64(deltaData[offset])
-				offset++
-			}
-			if instruction&0x20 != 0 {
-				copySize |= int64(deltaData[offset]) << 8
-				offset++
-			}
-			if instruction&0x40 != 0 {
-				copySize |= int64(deltaData[offset]) << 16
-				offset++
-			}
-
-			if copySize == 0 {
-				copySize = 0x10000
-			}
-
-			if copyOffset < 0 || copySize < 0 ||
-				copyOffset >= int64(len(baseData)) ||
-				copyOffset+copySize > int64(len(baseData)) {
-				return nil, fmt.Errorf("invalid copy operation: offset=%d, size=%d, base_len=%d",
-					copyOffset, copySize, len(baseData))
-			}
-
-			result = append(result, baseData[copyOffset:copyOffset+copySize]...)
-
-		} else if instruction != 0 {
-			// insert instruction
-			insertSize := int(instruction)
-			if offset+insertSize > len(deltaData) {
-				return nil, fmt.Errorf("insert extends beyond delta data")
-			}
-
-			result = append(result, deltaData[offset:offset+insertSize]...)
-			offset += insertSize
-		} else {
-			return nil, fmt.Errorf("invalid delta instruction: 0")
-		}
-	}
-
-	if int64(len(result)) != resultSize {
-		return nil, fmt.Errorf("result size mismatch: expected %d, got %d", resultSize, len(result))
-	}
-
-	return result, nil
+	return delta.Apply(baseData, deltaData)
 }
 
 func (p *PackProcessor) readDeltaSize(data []byte, offset int) (int64, int) {
-	if offset >= len(data) {
-		return 0, offset
-	}
-
-	size := int64(data[offset] & 0x7f)
-	shift := 7
-	offset++
-
-	for offset < len(data) && data[offset-1]&0x80 != 0 {
-		size |= int64(data[offset]&0x7f) << shift
-		shift += 7
-		offset++
-	}
-
-	return size, offset
+	return delta.ReadSize(data, offset)
 }
 
-func (p *PackProcessor) storeAllObjects() error {
+func (p *PackProcessor) storeAllObjects(ctx context.Context) error {
 	// sort objects by dependency order (non-deltas first)
 	var objects []*PackObject
 	for _, obj := range p.resolvedCache {
@@ -640,16 +782,24 @@ func (p *PackProcessor) storeAllObjects() error {
 		return !objects[i].IsDelta && objects[j].IsDelta
 	})
 
-	for _, packObj := range objects {
+	for i, packObj := range objects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if packObj.stored {
+			p.progress.Update("Storing objects", i+1, len(objects))
+			continue
+		}
 		if err := p.storeObject(packObj); err != nil {
 			return fmt.Errorf("failed to store object %s: %w", packObj.Hash, err)
 		}
+		p.progress.Update("Storing objects", i+1, len(objects))
 	}
 
 	return nil
 }
 
-func (p *PackProcessor) packTypeToObjectType(packType int) objects.ObjectType {
+func packTypeToObjectType(packType int) objects.ObjectType {
 	switch packType {
 	case OBJ_COMMIT:
 		return objects.ObjectTypeCommit
@@ -664,60 +814,20 @@ func (p *PackProcessor) packTypeToObjectType(packType int) objects.ObjectType {
 	}
 }
 
+// storeObject writes an object from the pack, always verifying the write
+// since pack contents come from the network
 func (p *PackProcessor) storeObject(packObj *PackObject) error {
-	// verify hash matches
-	computedHash := hash.ComputeObjectHash(packObj.Type.String(), packObj.Data)
-	if computedHash != packObj.Hash {
-		packObj.Hash = computedHash
-	}
-
-	// store object directly using raw data to preserve exact Git format
-	if err := p.storeRawObject(packObj.Hash, packObj.Type, packObj.Data); err != nil {
-		return fmt.Errorf("failed to store object %s: %w", packObj.Hash, err)
-	}
-
-	_, err := p.repo.LoadObject(packObj.Hash)
+	storedHash, err := p.repo.StoreRawObject(packObj.Type, packObj.Data)
 	if err != nil {
-		return fmt.Errorf("failed to verify stored object %s: %w", packObj.Hash, err)
-	}
-
-	return nil
-}
-
-// stores object data directly to maintain hash integrity
-func (p *PackProcessor) storeRawObject(hash string, objType objects.ObjectType, data []byte) error {
-	objDir := filepath.Join(p.repo.GitDir, "objects", hash[:2])
-	if err := os.MkdirAll(objDir, 0755); err != nil {
-		return fmt.Errorf("failed to create object directory: %w", err)
-	}
-
-	objPath := filepath.Join(objDir, hash[2:])
-	if _, err := os.Stat(objPath); err == nil {
-		return nil // Object already exists
-	}
-
-	// create Git object format: "type size\data"
-	header := fmt.Sprintf("%s %d\000", objType.String(), len(data))
-	fullData := append([]byte(header), data...)
-
-	var compressed bytes.Buffer
-	writer := zlib.NewWriter(&compressed)
-	if _, err := writer.Write(fullData); err != nil {
-		writer.Close()
-		return fmt.Errorf("failed to compress object data: %w", err)
+		return fmt.Errorf("failed to store object %s: %w", packObj.Hash, err)
 	}
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to finalize compression: %w", err)
+	if storedHash != packObj.Hash {
+		return errors.NewObjectError(packObj.Hash, packObj.Type.String(),
+			fmt.Errorf("%w: content hashes to %s", errors.ErrHashMismatch, storedHash))
 	}
 
-	tempPath := objPath + ".tmp"
-	if err := os.WriteFile(tempPath, compressed.Bytes(), 0444); err != nil {
-		return fmt.Errorf("failed to write object file: %w", err)
-	}
-
-	if err := os.Rename(tempPath, objPath); err != nil {
-		os.Remove(tempPath) // Clean up on failure
-		return fmt.Errorf("failed to finalize object file: %w", err)
+	if err := p.repo.VerifyObject(packObj.Hash); err != nil {
+		return fmt.Errorf("failed to verify stored object %s: %w", packObj.Hash, err)
 	}
 
 	return nil
@@ -726,6 +836,9 @@ func (p *PackProcessor) storeRawObject(hash string, objType objects.ObjectType,
 type GitProtocolParser struct {
 	data   []byte
 	offset int
+	// messages receives sideband progress and errors; nil means stderr
+	messages     RemoteMessageHandler
+	remoteErrors []string
 }
 
 func (g *GitProtocolParser) ExtractPackData() ([]byte, error) {
@@ -779,6 +892,11 @@ func (g *GitProtocolParser) ExtractPackData() ([]byte, error) {
 		}
 	}
 
+	// a server that fails says why on channel 3 and then sends no pack
+	if len(g.remoteErrors) > 0 && !g.isPackDataStart(packData) {
+		return nil, fmt.Errorf("%w: %s", errors.ErrRemoteError, strings.Join(g.remoteErrors, "; "))
+	}
+
 	if len(packData) == 0 {
 		return nil, fmt.Errorf("no pack data found in protocol response")
 	}
@@ -881,17 +999,26 @@ func (g *GitProtocolParser) extractSidebandPackData(packet []byte) ([]byte, erro
 		return data, nil
 	case 2:
 		// Channel 2: progress messages
-		fmt.Printf("Remote: %s", string(data))
+		g.messageHandler().Progress(string(data))
 		return nil, nil
 	case 3:
 		// Channel 3: error messages
-		fmt.Printf("Remote error: %s", string(data))
+		message := strings.TrimSpace(string(data))
+		g.remoteErrors = append(g.remoteErrors, message)
+		g.messageHandler().Error(message)
 		return nil, nil
 	default:
 		return nil, fmt.Errorf("unknown sideband channel: %d", channel)
 	}
 }
 
+func (g *GitProtocolParser) messageHandler() RemoteMessageHandler {
+	if g.messages == nil {
+		return StderrRemoteMessages
+	}
+	return g.messages
+}
+
 func (g *GitProtocolParser) safeString(data []byte, maxLen int) string {
 	if len(data) > maxLen {
 		data = data[:maxLen]
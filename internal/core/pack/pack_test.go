@@ -2,13 +2,26 @@ package pack
 
 import (
 	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/packindex"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 func TestPackProcessor(t *testing.T) {
@@ -196,12 +209,6 @@ func TestDeltaInstructions(t *testing.T) {
 }
 
 func TestPackObjectTypes(t *testing.T) {
-	tempDir := t.TempDir()
-	repo := repository.New(tempDir)
-	require.NoError(t, repo.Init())
-
-	processor := NewPackProcessor(repo)
-
 	tests := []struct {
 		packType int
 		expected objects.ObjectType
@@ -214,13 +221,377 @@ func TestPackObjectTypes(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(string(tt.expected), func(t *testing.T) {
-			result := processor.packTypeToObjectType(tt.packType)
+			result := packTypeToObjectType(tt.packType)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 
 	t.Run("UnknownType", func(t *testing.T) {
-		result := processor.packTypeToObjectType(99)
+		result := packTypeToObjectType(99)
 		assert.Equal(t, objects.ObjectType(""), result)
 	})
 }
+
+func TestProcessPackProgress(t *testing.T) {
+	source := repository.New(t.TempDir())
+	require.NoError(t, source.Init())
+
+	var hashes []string
+	for i := 0; i < 3; i++ {
+		hash, err := source.StoreObject(objects.NewBlob([]byte(fmt.Sprintf("blob %d\n", i))))
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+	packData, _, err := BuildPack(source, hashes)
+	require.NoError(t, err)
+
+	target := repository.New(t.TempDir())
+	require.NoError(t, target.Init())
+
+	last := make(map[string][2]int)
+	var phases []string
+	progress := ProgressFunc(func(phase string, current, total int) {
+		if _, seen := last[phase]; !seen {
+			phases = append(phases, phase)
+		}
+		last[phase] = [2]int{current, total}
+	})
+
+	require.NoError(t, NewPackProcessor(target).WithProgress(progress).ProcessPack(bytes.NewReader(packData)))
+	assert.Equal(t, []string{"Unpacking objects", "Storing objects"}, phases)
+	assert.Equal(t, [2]int{3, 3}, last["Unpacking objects"])
+	assert.Equal(t, [2]int{3, 3}, last["Storing objects"])
+
+	// a nil progress falls back to discarding updates
+	require.NoError(t, NewPackProcessor(target).WithProgress(nil).ProcessPack(bytes.NewReader(packData)))
+}
+
+type recordedMessages struct {
+	progress []string
+	errors   []string
+}
+
+func (r *recordedMessages) Progress(message string) { r.progress = append(r.progress, message) }
+func (r *recordedMessages) Error(message string)    { r.errors = append(r.errors, message) }
+
+func TestRemoteMessages(t *testing.T) {
+	sideband := func(channel byte, data string) string {
+		return fmt.Sprintf("%04x", len(data)+5) + string([]byte{channel}) + data
+	}
+
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	messages := &recordedMessages{}
+	response := "0008NAK\n" + sideband(2, "Counting objects: 3\r") + sideband(3, "upload-pack: not our ref\n") + "0000"
+
+	err := NewPackProcessor(repo).WithRemoteMessages(messages).ProcessPack(bytes.NewReader([]byte(response)))
+	require.ErrorIs(t, err, errors.ErrRemoteError)
+	assert.Contains(t, err.Error(), "upload-pack: not our ref")
+	assert.Equal(t, []string{"Counting objects: 3\r"}, messages.progress)
+	assert.Equal(t, []string{"upload-pack: not our ref"}, messages.errors)
+}
+
+func TestProcessPackContextCancel(t *testing.T) {
+	source := repository.New(t.TempDir())
+	require.NoError(t, source.Init())
+
+	var hashes []string
+	for i := 0; i < 5; i++ {
+		hash, err := source.StoreObject(objects.NewBlob([]byte(fmt.Sprintf("blob %d\n", i))))
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+	packData, _, err := BuildPack(source, hashes)
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		phase  string
+		stored int
+	}{
+		{"Unpacking objects", 0},
+		{"Storing objects", 2},
+	} {
+		t.Run(tt.phase, func(t *testing.T) {
+			target := repository.New(t.TempDir())
+			require.NoError(t, target.Init())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			progress := ProgressFunc(func(phase string, current, total int) {
+				if phase == tt.phase && current == 2 {
+					cancel()
+				}
+			})
+
+			err := NewPackProcessor(target).WithProgress(progress).ProcessPackContext(ctx, bytes.NewReader(packData))
+			require.ErrorIs(t, err, context.Canceled)
+
+			stored := 0
+			require.NoError(t, target.ForEachObject(func(hash string, typ objects.ObjectType) error {
+				stored++
+				return nil
+			}))
+			assert.Equal(t, tt.stored, stored)
+
+			temps, err := filepath.Glob(filepath.Join(target.GitDir, "objects", "*", "tmp_obj_*"))
+			require.NoError(t, err)
+			assert.Empty(t, temps)
+		})
+	}
+}
+
+// writeDeltaPack writes a pack holding a blob, an OFS_DELTA against it and a
+// REF_DELTA against that delta, with its index, and returns the index path
+// and the three object hashes
+func writeDeltaPack(t *testing.T) (string, []string) {
+	base := []byte("hello world\n")
+	first := []byte("hello world!\n")
+	second := []byte("hello world!!\n")
+	hashes := []string{
+		hash.ComputeObjectHash("blob", base),
+		hash.ComputeObjectHash("blob", first),
+		hash.ComputeObjectHash("blob", second),
+	}
+
+	// copy all but the newline of the base, then insert "!\n"
+	delta := func(baseLen, resultLen int) []byte {
+		return []byte{byte(baseLen), byte(resultLen), 0x80 | 0x10, byte(baseLen - 1), 2, '!', '\n'}
+	}
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	var pack bytes.Buffer
+	pack.WriteString(packSignature)
+	binary.Write(&pack, binary.BigEndian, uint32(packVersion))
+	binary.Write(&pack, binary.BigEndian, uint32(3))
+
+	var entries []PackEntry
+	add := func(h string, raw []byte) {
+		entries = append(entries, PackEntry{Hash: h, Offset: int64(pack.Len()), CRC32: crc32.ChecksumIEEE(raw)})
+		pack.Write(raw)
+	}
+
+	add(hashes[0], append(createObjectHeader(OBJ_BLOB, int64(len(base))), compress(base)...))
+
+	ofsDelta := delta(len(base), len(first))
+	raw := createObjectHeader(OBJ_OFS_DELTA, int64(len(ofsDelta)))
+	raw = append(raw, byte(int64(pack.Len())-entries[0].Offset))
+	add(hashes[1], append(raw, compress(ofsDelta)...))
+
+	refDelta := delta(len(first), len(second))
+	raw = createObjectHeader(OBJ_REF_DELTA, int64(len(refDelta)))
+	baseHash, _ := hex.DecodeString(hashes[1])
+	raw = append(raw, baseHash...)
+	add(hashes[2], append(raw, compress(refDelta)...))
+
+	checksum := sha1.Sum(pack.Bytes())
+	pack.Write(checksum[:])
+	idx, err := BuildIndex(entries, checksum[:])
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pack-test.pack"), pack.Bytes(), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pack-test.idx"), idx, 0644))
+	return filepath.Join(dir, "pack-test.idx"), hashes
+}
+
+func TestVerify(t *testing.T) {
+	idxPath, hashes := writeDeltaPack(t)
+
+	entries, err := Verify(idxPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	for i, entry := range entries {
+		assert.Equal(t, hashes[i], entry.Hash, "entries come back in pack order")
+		assert.Equal(t, objects.ObjectTypeBlob, entry.Type)
+		assert.Equal(t, i, entry.Depth)
+	}
+	assert.Empty(t, entries[0].Base)
+	assert.Equal(t, int64(12), entries[0].Size)
+	assert.Equal(t, hashes[0], entries[1].Base)
+	assert.Equal(t, hashes[1], entries[2].Base)
+	assert.Equal(t, int64(7), entries[2].Size, "a delta reports the size of its delta data")
+	assert.Equal(t, entries[1].Offset-entries[0].Offset, entries[0].PackedSize)
+}
+
+func TestVerifyCorruption(t *testing.T) {
+	idxPath, _ := writeDeltaPack(t)
+	packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+	data, err := os.ReadFile(packPath)
+	require.NoError(t, err)
+
+	// damage the first entry, then fix up the checksums so only the CRC
+	// and the content catch it
+	data[14] ^= 0xff
+	checksum := sha1.Sum(data[:len(data)-sha1.Size])
+	copy(data[len(data)-sha1.Size:], checksum[:])
+	require.NoError(t, os.WriteFile(packPath, data, 0644))
+
+	idx, err := os.ReadFile(idxPath)
+	require.NoError(t, err)
+	trailer := len(idx) - 2*sha1.Size
+	copy(idx[trailer:], checksum[:])
+	require.NoError(t, os.WriteFile(idxPath, idx, 0644))
+
+	_, err = Verify(idxPath)
+	assert.ErrorIs(t, err, errors.ErrCorruptedRepository)
+}
+
+// writeLargeDeltaPack builds a pack of count random blobs of size bytes,
+// each followed by an OFS_DELTA against it, an OFS_DELTA against that delta
+// and a REF_DELTA against the blob, and returns the pack and every object's
+// content by hash
+func writeLargeDeltaPack(t *testing.T, count, size int) ([]byte, map[string][]byte) {
+	varint := func(n int) []byte {
+		var out []byte
+		for {
+			b := byte(n & 0x7f)
+			n >>= 7
+			if n == 0 {
+				return append(out, b)
+			}
+			out = append(out, b|0x80)
+		}
+	}
+	// copy the whole base, then insert suffix
+	appendDelta := func(baseLen int, suffix string) []byte {
+		out := append(varint(baseLen), varint(baseLen+len(suffix))...)
+		out = append(out, 0x80|0x10|0x20|0x40, byte(baseLen), byte(baseLen>>8), byte(baseLen>>16))
+		out = append(out, byte(len(suffix)))
+		return append(out, suffix...)
+	}
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+	ofsDistance := func(distance int64) []byte {
+		out := []byte{byte(distance & 0x7f)}
+		for distance >>= 7; distance > 0; distance >>= 7 {
+			distance--
+			out = append([]byte{0x80 | byte(distance&0x7f)}, out...)
+		}
+		return out
+	}
+
+	var pack bytes.Buffer
+	pack.WriteString(packSignature)
+	binary.Write(&pack, binary.BigEndian, uint32(packVersion))
+	binary.Write(&pack, binary.BigEndian, uint32(count*4))
+
+	contents := make(map[string][]byte)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < count; i++ {
+		base := make([]byte, size)
+		rng.Read(base)
+		baseHash := hash.ComputeObjectHash("blob", base)
+		contents[baseHash] = base
+		baseOffset := int64(pack.Len())
+		pack.Write(createObjectHeader(OBJ_BLOB, int64(size)))
+		pack.Write(compress(base))
+
+		first := append(append([]byte{}, base...), "first\n"...)
+		contents[hash.ComputeObjectHash("blob", first)] = first
+		firstOffset := int64(pack.Len())
+		d := appendDelta(len(base), "first\n")
+		pack.Write(createObjectHeader(OBJ_OFS_DELTA, int64(len(d))))
+		pack.Write(ofsDistance(firstOffset - baseOffset))
+		pack.Write(compress(d))
+
+		second := append(append([]byte{}, first...), "second\n"...)
+		contents[hash.ComputeObjectHash("blob", second)] = second
+		secondOffset := int64(pack.Len())
+		d = appendDelta(len(first), "second\n")
+		pack.Write(createObjectHeader(OBJ_OFS_DELTA, int64(len(d))))
+		pack.Write(ofsDistance(secondOffset - firstOffset))
+		pack.Write(compress(d))
+
+		third := append(append([]byte{}, base...), "third\n"...)
+		contents[hash.ComputeObjectHash("blob", third)] = third
+		d = appendDelta(len(base), "third\n")
+		pack.Write(createObjectHeader(OBJ_REF_DELTA, int64(len(d))))
+		rawHash, _ := hex.DecodeString(baseHash)
+		pack.Write(rawHash)
+		pack.Write(compress(d))
+	}
+
+	checksum := sha1.Sum(pack.Bytes())
+	pack.Write(checksum[:])
+	return pack.Bytes(), contents
+}
+
+func TestProcessPackMaxCacheBytes(t *testing.T) {
+	const blobSize = 40 << 10
+	packData, contents := writeLargeDeltaPack(t, 20, blobSize)
+
+	unbounded := NewPackProcessor(newTestRepo(t))
+	require.NoError(t, unbounded.ProcessPack(bytes.NewReader(packData)))
+
+	target := newTestRepo(t)
+	processor := NewPackProcessor(target)
+	processor.MaxCacheBytes = 4 * blobSize
+	require.NoError(t, processor.ProcessPack(bytes.NewReader(packData)))
+
+	// an object is counted before eviction makes room for it
+	largest := 0
+	for _, content := range contents {
+		largest = max(largest, len(content))
+	}
+	assert.LessOrEqual(t, processor.peakCacheBytes, processor.MaxCacheBytes+int64(largest))
+	assert.Greater(t, unbounded.peakCacheBytes, 10*processor.MaxCacheBytes)
+
+	// released objects do not pile up in the eviction queue
+	live := 0
+	for _, entry := range processor.cached {
+		if entry.live() {
+			live++
+		}
+	}
+	assert.LessOrEqual(t, len(processor.cached), 2*live)
+
+	for h, content := range contents {
+		blob, err := target.LoadBlob(h)
+		require.NoError(t, err, h)
+		assert.True(t, bytes.Equal(content, blob.Content()), h)
+	}
+}
+
+func newTestRepo(t *testing.T) *repository.Repository {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+	return repo
+}
+
+func TestBuildIndexLargeOffsets(t *testing.T) {
+	entries := []PackEntry{
+		{Hash: "4444444444444444444444444444444444444444", Offset: 5 << 32, CRC32: 4},
+		{Hash: "1111111111111111111111111111111111111111", Offset: 12, CRC32: 1},
+		{Hash: "3333333333333333333333333333333333333333", Offset: 0x7fffffff, CRC32: 3},
+		{Hash: "2222222222222222222222222222222222222222", Offset: 3 << 30, CRC32: 2},
+	}
+	checksum := bytes.Repeat([]byte{0xaa}, 20)
+
+	data, err := BuildIndex(entries, checksum)
+	require.NoError(t, err)
+
+	idx, err := packindex.Parse(data)
+	require.NoError(t, err)
+	require.Len(t, idx.Entries, 4)
+	assert.Equal(t, packindex.Entry{Hash: entries[1].Hash, Offset: 12, CRC32: 1}, idx.Entries[0])
+	assert.Equal(t, packindex.Entry{Hash: entries[3].Hash, Offset: 3 << 30, CRC32: 2}, idx.Entries[1])
+	assert.Equal(t, packindex.Entry{Hash: entries[2].Hash, Offset: 0x7fffffff, CRC32: 3}, idx.Entries[2])
+	assert.Equal(t, packindex.Entry{Hash: entries[0].Hash, Offset: 5 << 32, CRC32: 4}, idx.Entries[3])
+	assert.Equal(t, checksum, idx.PackChecksum)
+
+	// only the two offsets past 31 bits take 64-bit slots
+	assert.Len(t, data, 8+256*4+4*(20+4+4)+2*8+2*20)
+}
@@ -0,0 +1,53 @@
+package pack
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Progress receives updates as a transfer moves through its phases, such as
+// "Unpacking objects". total is 0 when the amount of work is not known.
+type Progress interface {
+	Update(phase string, current, total int)
+}
+
+// ProgressFunc adapts a function to the Progress interface
+type ProgressFunc func(phase string, current, total int)
+
+func (f ProgressFunc) Update(phase string, current, total int) {
+	f(phase, current, total)
+}
+
+// NoProgress discards every update
+var NoProgress Progress = ProgressFunc(func(string, int, int) {})
+
+// progressOrDefault returns progress, or NoProgress when it is nil
+func progressOrDefault(progress Progress) Progress {
+	if progress == nil {
+		return NoProgress
+	}
+	return progress
+}
+
+// RemoteMessageHandler receives the messages a server sends alongside a
+// pack: progress on sideband channel 2 and errors on channel 3
+type RemoteMessageHandler interface {
+	Progress(message string)
+	Error(message string)
+}
+
+// StderrRemoteMessages writes remote messages to stderr the way git does
+var StderrRemoteMessages RemoteMessageHandler = writerRemoteMessages{out: os.Stderr}
+
+type writerRemoteMessages struct {
+	out io.Writer
+}
+
+func (w writerRemoteMessages) Progress(message string) {
+	fmt.Fprintf(w.out, "remote: %s", message)
+}
+
+func (w writerRemoteMessages) Error(message string) {
+	fmt.Fprintf(w.out, "remote: error: %s\n", message)
+}
@@ -0,0 +1,213 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/packindex"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// Verify checks the pack beside the index at idxPath and returns its entries
+// in pack order. Both files' checksums must hold and the pack's must match
+// the one the index records, every entry must inflate to the size its header
+// gives and, with a v2 index, match its CRC32, and whole objects must hash to
+// their names.
+func Verify(idxPath string) ([]PackEntry, error) {
+	idx, err := packindex.ReadVerified(idxPath)
+	if err != nil {
+		return nil, errors.NewGitError("verify-pack", idxPath, err)
+	}
+
+	packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+	data, err := os.ReadFile(packPath)
+	if err != nil {
+		return nil, errors.NewGitError("verify-pack", packPath, err)
+	}
+	if err := verifyPackFile(data, idx); err != nil {
+		return nil, errors.NewGitError("verify-pack", packPath, err)
+	}
+
+	entries := make([]PackEntry, len(idx.Entries))
+	hashAt := make(map[int64]string, len(idx.Entries))
+	for i, e := range idx.Entries {
+		entries[i] = PackEntry{Hash: e.Hash, Offset: e.Offset, CRC32: e.CRC32}
+		hashAt[e.Offset] = e.Hash
+	}
+	slices.SortFunc(entries, func(a, b PackEntry) int { return int(a.Offset - b.Offset) })
+
+	end := int64(len(data) - sha1.Size)
+	for i := range entries {
+		next := end
+		if i+1 < len(entries) {
+			next = entries[i+1].Offset
+		}
+		if err := verifyEntry(data, &entries[i], next, idx.Version, hashAt); err != nil {
+			return nil, errors.NewObjectError(entries[i].Hash, string(entries[i].Type), err)
+		}
+	}
+
+	if err := resolveChains(entries); err != nil {
+		return nil, errors.NewGitError("verify-pack", packPath, err)
+	}
+	return entries, nil
+}
+
+func verifyPackFile(data []byte, idx *packindex.Index) error {
+	if len(data) < 12+sha1.Size || string(data[:4]) != packSignature {
+		return errors.ErrCorruptedRepository
+	}
+
+	trailer := data[len(data)-sha1.Size:]
+	if sum := sha1.Sum(data[:len(data)-sha1.Size]); !bytes.Equal(sum[:], trailer) {
+		return fmt.Errorf("%w: pack checksum mismatch", errors.ErrCorruptedRepository)
+	}
+	if !bytes.Equal(trailer, idx.PackChecksum) {
+		return fmt.Errorf("%w: index belongs to another pack", errors.ErrCorruptedRepository)
+	}
+	if count := binary.BigEndian.Uint32(data[8:12]); int(count) != len(idx.Entries) {
+		return fmt.Errorf("%w: pack holds %d objects, index lists %d", errors.ErrCorruptedRepository, count, len(idx.Entries))
+	}
+	return nil
+}
+
+// verifyEntry checks the entry running from entry.Offset to next and fills
+// in its size and, for whole objects, its type; a delta gets its base
+func verifyEntry(data []byte, entry *PackEntry, next int64, version int, hashAt map[int64]string) error {
+	if entry.Offset < 12 || entry.Offset >= next {
+		return fmt.Errorf("%w: bad offset %d", errors.ErrCorruptedRepository, entry.Offset)
+	}
+	raw := data[entry.Offset:next]
+	entry.PackedSize = int64(len(raw))
+
+	if version >= 2 && crc32.ChecksumIEEE(raw) != entry.CRC32 {
+		return fmt.Errorf("%w: CRC32 mismatch", errors.ErrCorruptedRepository)
+	}
+
+	packType, size, pos := readEntryHeader(raw)
+	if pos < 0 {
+		return errors.ErrCorruptedRepository
+	}
+	entry.Size = size
+
+	switch packType {
+	case OBJ_OFS_DELTA:
+		distance, n := readOfsDistance(raw[pos:])
+		if n < 0 || distance <= 0 || distance > entry.Offset {
+			return fmt.Errorf("%w: bad delta base offset", errors.ErrCorruptedRepository)
+		}
+		pos += n
+		base, ok := hashAt[entry.Offset-distance]
+		if !ok {
+			return fmt.Errorf("%w: no object at delta base offset %d", errors.ErrCorruptedRepository, entry.Offset-distance)
+		}
+		entry.Base = base
+	case OBJ_REF_DELTA:
+		if len(raw) < pos+sha1.Size {
+			return errors.ErrCorruptedRepository
+		}
+		entry.Base = hex.EncodeToString(raw[pos : pos+sha1.Size])
+		pos += sha1.Size
+	case OBJ_COMMIT, OBJ_TREE, OBJ_BLOB, OBJ_TAG:
+		entry.Type = packTypeToObjectType(packType)
+	default:
+		return fmt.Errorf("%w: unknown object type %d", errors.ErrCorruptedRepository, packType)
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(raw[pos:]))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) != size {
+		return fmt.Errorf("%w: inflated to %d bytes, header says %d", errors.ErrCorruptedRepository, len(content), size)
+	}
+
+	if entry.Base == "" {
+		if got := hash.ComputeObjectHash(entry.Type.String(), content); got != entry.Hash {
+			return fmt.Errorf("%w: content hashes to %s", errors.ErrHashMismatch, got)
+		}
+	}
+	return nil
+}
+
+// resolveChains follows every delta to the whole object at the end of its
+// chain, setting its depth and type. Bases must be in the same pack.
+func resolveChains(entries []PackEntry) error {
+	byHash := make(map[string]int, len(entries))
+	for i, e := range entries {
+		byHash[e.Hash] = i
+	}
+
+	for i := range entries {
+		current := i
+		for entries[current].Base != "" {
+			base, ok := byHash[entries[current].Base]
+			if !ok {
+				return fmt.Errorf("delta base %s of %s is not in the pack", entries[current].Base, entries[current].Hash)
+			}
+			current = base
+			entries[i].Depth++
+			if entries[i].Depth > len(entries) {
+				return fmt.Errorf("%w: delta cycle at %s", errors.ErrCorruptedRepository, entries[i].Hash)
+			}
+		}
+		entries[i].Type = entries[current].Type
+	}
+	return nil
+}
+
+// readEntryHeader decodes an entry's type and size, returning the number of
+// header bytes, or -1 when the header is truncated
+func readEntryHeader(raw []byte) (int, int64, int) {
+	if len(raw) == 0 {
+		return 0, 0, -1
+	}
+	b := raw[0]
+	packType := int(b>>typeBits) & 7
+	size := int64(b & sizeMask)
+	shift := typeBits
+	pos := 1
+	for b&continuationBit != 0 {
+		if pos >= len(raw) {
+			return 0, 0, -1
+		}
+		b = raw[pos]
+		size |= int64(b&sevenBitMask) << shift
+		shift += 7
+		pos++
+	}
+	return packType, size, pos
+}
+
+// readOfsDistance decodes the negative base offset of an OFS_DELTA entry,
+// returning the number of bytes read, or -1 when they run out
+func readOfsDistance(raw []byte) (int64, int) {
+	if len(raw) == 0 {
+		return 0, -1
+	}
+	distance := int64(raw[0] & sevenBitMask)
+	pos := 1
+	for raw[pos-1]&continuationBit != 0 {
+		if pos >= len(raw) {
+			return 0, -1
+		}
+		distance = ((distance + 1) << 7) | int64(raw[pos]&sevenBitMask)
+		pos++
+	}
+	return distance, pos
+}
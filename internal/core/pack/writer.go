@@ -0,0 +1,215 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"sort"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+const (
+	packSignature = "PACK"
+	packVersion   = 2
+
+	idxV2Magic   = "\377tOc"
+	idxV2Version = 2
+
+	// the largest offset the 32-bit offset table holds directly
+	maxSmallOffset  = 0x7fffffff
+	largeOffsetFlag = 0x80000000
+
+	sizeMask        = 0xF
+	typeBits        = 4
+	continuationBit = 0x80
+	sevenBitMask    = 0x7F
+)
+
+// PackEntry locates one object written by BuildPack or checked by Verify
+type PackEntry struct {
+	Hash   string
+	Offset int64
+	CRC32  uint32
+
+	// The fields below are filled in by Verify
+
+	// Type is the object's type; for a delta, the type at the end of its chain
+	Type objects.ObjectType
+	// Size is the inflated size of the entry's data, which for a delta is
+	// the size of the delta rather than of the object
+	Size int64
+	// PackedSize is how many bytes the entry takes up in the pack
+	PackedSize int64
+	// Depth is the length of the delta chain, zero for whole objects
+	Depth int
+	// Base is the hash of the delta base, empty for whole objects
+	Base string
+}
+
+// BuildPack writes the given objects, undeltified, into a version 2 pack and
+// returns the pack bytes together with the entries needed to index it
+func BuildPack(repo *repository.Repository, objectHashes []string) ([]byte, []PackEntry, error) {
+	return BuildPackWithProgress(repo, objectHashes, NoProgress)
+}
+
+// BuildPackWithProgress is BuildPack reporting each object written to
+// progress as "Writing objects"
+func BuildPackWithProgress(repo *repository.Repository, objectHashes []string, progress Progress) ([]byte, []PackEntry, error) {
+	progress = progressOrDefault(progress)
+	var packBuffer bytes.Buffer
+	// write pack header: "PACK" + version + object count
+	packBuffer.WriteString(packSignature)
+	binary.Write(&packBuffer, binary.BigEndian, uint32(packVersion))
+	binary.Write(&packBuffer, binary.BigEndian, uint32(len(objectHashes)))
+
+	entries := make([]PackEntry, 0, len(objectHashes))
+	for i, hash := range objectHashes {
+		objData, err := createPackObject(repo, hash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create pack object %s: %w", hash, err)
+		}
+
+		entries = append(entries, PackEntry{
+			Hash:   hash,
+			Offset: int64(packBuffer.Len()),
+			CRC32:  crc32.ChecksumIEEE(objData),
+		})
+		packBuffer.Write(objData)
+		progress.Update("Writing objects", i+1, len(objectHashes))
+	}
+
+	// calculate and append SHA-1 checksum of pack data
+	checksum := sha1.Sum(packBuffer.Bytes())
+	packBuffer.Write(checksum[:])
+
+	return packBuffer.Bytes(), entries, nil
+}
+
+// BuildIndex writes a version 2 pack index for entries of the pack whose
+// trailing checksum is packChecksum
+func BuildIndex(entries []PackEntry, packChecksum []byte) ([]byte, error) {
+	sorted := make([]PackEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Hash < sorted[j].Hash })
+
+	rawHashes := make([][]byte, len(sorted))
+	var fanout [256]uint32
+	for i, entry := range sorted {
+		raw, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(raw) != sha1.Size {
+			return nil, fmt.Errorf("invalid object hash %q", entry.Hash)
+		}
+		if entry.Offset < 0 {
+			return nil, fmt.Errorf("object %s has negative offset %d", entry.Hash, entry.Offset)
+		}
+		rawHashes[i] = raw
+		fanout[raw[0]]++
+	}
+
+	var idx bytes.Buffer
+	idx.WriteString(idxV2Magic)
+	binary.Write(&idx, binary.BigEndian, uint32(idxV2Version))
+
+	// fanout entry n counts the objects whose first byte is <= n
+	var cumulative uint32
+	for _, count := range fanout {
+		cumulative += count
+		binary.Write(&idx, binary.BigEndian, cumulative)
+	}
+
+	for _, raw := range rawHashes {
+		idx.Write(raw)
+	}
+	for _, entry := range sorted {
+		binary.Write(&idx, binary.BigEndian, entry.CRC32)
+	}
+	// offsets past 31 bits go in a table of 64-bit offsets, indexed from the
+	// 32-bit table with the high bit set
+	var largeOffsets []uint64
+	for _, entry := range sorted {
+		if entry.Offset <= maxSmallOffset {
+			binary.Write(&idx, binary.BigEndian, uint32(entry.Offset))
+			continue
+		}
+		binary.Write(&idx, binary.BigEndian, largeOffsetFlag|uint32(len(largeOffsets)))
+		largeOffsets = append(largeOffsets, uint64(entry.Offset))
+	}
+	binary.Write(&idx, binary.BigEndian, largeOffsets)
+
+	idx.Write(packChecksum)
+	checksum := sha1.Sum(idx.Bytes())
+	idx.Write(checksum[:])
+
+	return idx.Bytes(), nil
+}
+
+func createPackObject(repo *repository.Repository, hash string) ([]byte, error) {
+	objType, objData, err := repo.ReadObjectData(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load object %s: %w", hash, err)
+	}
+
+	var packType int
+	switch objType {
+	case objects.ObjectTypeBlob:
+		packType = OBJ_BLOB
+	case objects.ObjectTypeTree:
+		packType = OBJ_TREE
+	case objects.ObjectTypeCommit:
+		packType = OBJ_COMMIT
+	case objects.ObjectTypeTag:
+		packType = OBJ_TAG
+	default:
+		return nil, fmt.Errorf("unsupported object type for %s", hash)
+	}
+
+	header := createObjectHeader(packType, int64(len(objData)))
+
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	if _, err := writer.Write(objData); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to compress object data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize compression: %w", err)
+	}
+
+	var result bytes.Buffer
+	result.Write(header)
+	result.Write(compressed.Bytes())
+	return result.Bytes(), nil
+}
+
+func createObjectHeader(objType int, size int64) []byte {
+	var header []byte
+
+	// first byte: MSB=0, type (3 bits), size (4 bits)
+	firstByte := byte((objType << typeBits) | (int(size) & sizeMask))
+	size >>= typeBits
+
+	if size > 0 {
+		firstByte |= continuationBit // Set continuation bit
+	}
+
+	header = append(header, firstByte)
+
+	// additional bytes for larger sizes
+	for size > 0 {
+		nextByte := byte(size & sevenBitMask)
+		size >>= 7
+		if size > 0 {
+			nextByte |= continuationBit // Set continuation bit
+		}
+
+		header = append(header, nextByte)
+	}
+
+	return header
+}
@@ -0,0 +1,175 @@
+// Package packindex reads version 1 and 2 pack index (.idx) files. It is
+// kept apart from the pack package so that the repository package, which
+// pack depends on, can use it too.
+package packindex
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	v2Magic    = "\377tOc"
+	fanoutSize = 256 * 4
+	hashSize   = 20
+
+	// largeOffsetFlag marks a v2 offset that indexes the 64-bit offset table
+	largeOffsetFlag = 0x80000000
+)
+
+// Entry locates one object in a pack
+type Entry struct {
+	Hash   string
+	Offset int64
+	// CRC32 is the checksum of the object's packed bytes; v1 indexes do
+	// not record it
+	CRC32 uint32
+}
+
+// Index is a parsed pack index
+type Index struct {
+	Version int
+	// Entries are in hash order
+	Entries []Entry
+	// PackChecksum is the trailing checksum of the pack the index describes
+	PackChecksum []byte
+	// Checksum is the index's own trailing checksum, over everything before it
+	Checksum []byte
+}
+
+// Read parses the index file at path
+func Read(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// ReadVerified is Read that also checks the index's own checksum, so that a
+// corrupt index is reported rather than yielding wrong offsets
+func ReadVerified(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyChecksum(data); err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// VerifyChecksum checks that data ends with the SHA-1 of everything before
+// it, as an index does
+func VerifyChecksum(data []byte) error {
+	if len(data) < hashSize {
+		return errors.ErrCorruptedRepository
+	}
+	trailer := len(data) - hashSize
+	if sum := sha1.Sum(data[:trailer]); !bytes.Equal(sum[:], data[trailer:]) {
+		return fmt.Errorf("%w: index checksum mismatch", errors.ErrCorruptedRepository)
+	}
+	return nil
+}
+
+// Parse parses a v1 or v2 index. The layout is checked against the object
+// count the fanout table gives, but not the trailing checksum.
+func Parse(data []byte) (*Index, error) {
+	if len(data) >= 8 && string(data[:4]) == v2Magic {
+		return parseV2(data)
+	}
+	return parseV1(data)
+}
+
+func parseV1(data []byte) (*Index, error) {
+	if len(data) < fanoutSize {
+		return nil, errors.ErrCorruptedRepository
+	}
+
+	count, err := readFanout(data[:fanoutSize])
+	if err != nil {
+		return nil, err
+	}
+	end := fanoutSize + count*24
+	if len(data) != end+2*hashSize {
+		return nil, fmt.Errorf("%w: index size %d does not fit %d objects", errors.ErrCorruptedRepository, len(data), count)
+	}
+
+	entries := make([]Entry, count)
+	for i := range entries {
+		entry := data[fanoutSize+i*24 : fanoutSize+(i+1)*24]
+		entries[i] = Entry{
+			Hash:   hex.EncodeToString(entry[4:24]),
+			Offset: int64(binary.BigEndian.Uint32(entry[:4])),
+		}
+	}
+	return &Index{Version: 1, Entries: entries, PackChecksum: data[end : end+hashSize], Checksum: data[end+hashSize:]}, nil
+}
+
+func parseV2(data []byte) (*Index, error) {
+	const headerSize = 8 + fanoutSize
+	if len(data) < headerSize {
+		return nil, errors.ErrCorruptedRepository
+	}
+
+	count, err := readFanout(data[8:headerSize])
+	if err != nil {
+		return nil, err
+	}
+	hashTable := headerSize
+	crcTable := hashTable + count*hashSize
+	offsetTable := crcTable + count*4
+	largeTable := offsetTable + count*4
+
+	// the 64-bit table sits between the offsets and the trailer, with at
+	// most one slot for all but the first object
+	minSize := largeTable + 2*hashSize
+	maxSize := minSize
+	if count > 0 {
+		maxSize += (count - 1) * 8
+	}
+	if len(data) < minSize || len(data) > maxSize || (len(data)-minSize)%8 != 0 {
+		return nil, fmt.Errorf("%w: index size %d does not fit %d objects", errors.ErrCorruptedRepository, len(data), count)
+	}
+	largeCount := (len(data) - minSize) / 8
+
+	entries := make([]Entry, count)
+	for i := range entries {
+		offset := binary.BigEndian.Uint32(data[offsetTable+i*4:])
+		entries[i] = Entry{
+			Hash:   hex.EncodeToString(data[hashTable+i*hashSize : hashTable+(i+1)*hashSize]),
+			Offset: int64(offset),
+			CRC32:  binary.BigEndian.Uint32(data[crcTable+i*4:]),
+		}
+		if offset&largeOffsetFlag != 0 {
+			n := int(offset &^ largeOffsetFlag)
+			if n >= largeCount {
+				return nil, errors.ErrCorruptedRepository
+			}
+			entries[i].Offset = int64(binary.BigEndian.Uint64(data[largeTable+n*8:]))
+		}
+	}
+
+	trailer := len(data) - 2*hashSize
+	return &Index{Version: 2, Entries: entries, PackChecksum: data[trailer : trailer+hashSize], Checksum: data[trailer+hashSize:]}, nil
+}
+
+// readFanout returns the object count of a fanout table, whose cumulative
+// counts never decrease
+func readFanout(fanout []byte) (int, error) {
+	var prev uint32
+	for i := 0; i < fanoutSize; i += 4 {
+		n := binary.BigEndian.Uint32(fanout[i:])
+		if n < prev {
+			return 0, fmt.Errorf("%w: non-monotonic index fanout", errors.ErrCorruptedRepository)
+		}
+		prev = n
+	}
+	return int(prev), nil
+}
@@ -0,0 +1,160 @@
+package packindex
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// buildV2 writes a v2 index for hashes at offsets, putting offsets that do
+// not fit in 31 bits in the 64-bit table
+func buildV2(hashes []string, offsets []int64, crcs []uint32) []byte {
+	var fanout [256]uint32
+	for _, h := range hashes {
+		raw, _ := hex.DecodeString(h)
+		for i := int(raw[0]); i < 256; i++ {
+			fanout[i]++
+		}
+	}
+
+	var buf, large bytes.Buffer
+	buf.WriteString(v2Magic)
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+	binary.Write(&buf, binary.BigEndian, fanout)
+	for _, h := range hashes {
+		raw, _ := hex.DecodeString(h)
+		buf.Write(raw)
+	}
+	binary.Write(&buf, binary.BigEndian, crcs)
+	for _, offset := range offsets {
+		if offset < largeOffsetFlag {
+			binary.Write(&buf, binary.BigEndian, uint32(offset))
+			continue
+		}
+		binary.Write(&buf, binary.BigEndian, uint32(largeOffsetFlag|large.Len()/8))
+		binary.Write(&large, binary.BigEndian, uint64(offset))
+	}
+	buf.Write(large.Bytes())
+	buf.Write(bytes.Repeat([]byte{0xaa}, hashSize)) // pack checksum
+	buf.Write(bytes.Repeat([]byte{0xbb}, hashSize)) // index checksum
+	return buf.Bytes()
+}
+
+func TestParseV2(t *testing.T) {
+	hashes := []string{
+		"1111111111111111111111111111111111111111",
+		"2222222222222222222222222222222222222222",
+	}
+	data := buildV2(hashes, []int64{12, 5 << 30}, []uint32{7, 9})
+
+	idx, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if idx.Version != 2 || len(idx.Entries) != 2 {
+		t.Fatalf("Expected 2 v2 entries, got version %d with %d", idx.Version, len(idx.Entries))
+	}
+	if idx.Entries[0] != (Entry{Hash: hashes[0], Offset: 12, CRC32: 7}) {
+		t.Errorf("Unexpected first entry %+v", idx.Entries[0])
+	}
+	if idx.Entries[1] != (Entry{Hash: hashes[1], Offset: 5 << 30, CRC32: 9}) {
+		t.Errorf("Expected the 64-bit offset to be read, got %+v", idx.Entries[1])
+	}
+	if !bytes.Equal(idx.PackChecksum, bytes.Repeat([]byte{0xaa}, hashSize)) {
+		t.Errorf("Unexpected pack checksum %x", idx.PackChecksum)
+	}
+
+	if _, err := Parse(data[:len(data)-50]); err == nil {
+		t.Error("Expected an error for a truncated index")
+	}
+}
+
+func TestParseV1(t *testing.T) {
+	var buf bytes.Buffer
+	var fanout [256]uint32
+	for i := 0x33; i < 256; i++ {
+		fanout[i] = 1
+	}
+	binary.Write(&buf, binary.BigEndian, fanout)
+	binary.Write(&buf, binary.BigEndian, uint32(42))
+	raw, _ := hex.DecodeString("3333333333333333333333333333333333333333")
+	buf.Write(raw)
+	buf.Write(bytes.Repeat([]byte{0xaa}, 2*hashSize))
+
+	idx, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if idx.Version != 1 || len(idx.Entries) != 1 || idx.Entries[0].Offset != 42 {
+		t.Errorf("Unexpected v1 index %+v", idx)
+	}
+}
+
+// withChecksum replaces the trailing index checksum with the real one
+func withChecksum(data []byte) []byte {
+	trailer := len(data) - hashSize
+	sum := sha1.Sum(data[:trailer])
+	return append(data[:trailer:trailer], sum[:]...)
+}
+
+func TestReadVerified(t *testing.T) {
+	hashes := []string{
+		"1111111111111111111111111111111111111111",
+		"2222222222222222222222222222222222222222",
+	}
+	data := withChecksum(buildV2(hashes, []int64{12, 5 << 30}, []uint32{7, 9}))
+	dir := t.TempDir()
+
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	idx, err := ReadVerified(write("good.idx", data))
+	if err != nil {
+		t.Fatalf("ReadVerified failed: %v", err)
+	}
+	if idx.Entries[1].Offset != 5<<30 || !bytes.Equal(idx.Checksum, data[len(data)-hashSize:]) {
+		t.Errorf("Unexpected index %+v", idx)
+	}
+
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-2*hashSize-1] ^= 0xff // inside the 64-bit offset table
+	if _, err := Parse(corrupt); err != nil {
+		t.Fatalf("Expected a corrupt offset to parse without the checksum, got %v", err)
+	}
+	if _, err := ReadVerified(write("corrupt.idx", corrupt)); !stderrors.Is(err, errors.ErrCorruptedRepository) {
+		t.Errorf("Expected a checksum error for a corrupt index, got %v", err)
+	}
+
+	// dropping the 64-bit table leaves the flagged offset pointing nowhere
+	truncated := withChecksum(append(bytes.Clone(data[:len(data)-2*hashSize-8]), data[len(data)-2*hashSize:]...))
+	if err := VerifyChecksum(truncated); err != nil {
+		t.Fatalf("Expected the truncated index to carry a valid checksum, got %v", err)
+	}
+	if _, err := ReadVerified(write("truncated.idx", truncated)); !stderrors.Is(err, errors.ErrCorruptedRepository) {
+		t.Errorf("Expected a truncated index to be rejected, got %v", err)
+	}
+	if _, err := ReadVerified(write("short.idx", data[:len(data)-10])); !stderrors.Is(err, errors.ErrCorruptedRepository) {
+		t.Errorf("Expected a short index to be rejected, got %v", err)
+	}
+}
+
+func TestParseFanout(t *testing.T) {
+	data := buildV2([]string{"1111111111111111111111111111111111111111"}, []int64{12}, []uint32{7})
+	// claim an object under 0x00 that the later buckets then lose
+	binary.BigEndian.PutUint32(data[8:], 5)
+	if _, err := Parse(data); !stderrors.Is(err, errors.ErrCorruptedRepository) {
+		t.Errorf("Expected a decreasing fanout to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package reflog
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	logsDir   = "logs"
+	headRef   = "HEAD"
+	refsDir   = "refs/"
+	hashField = 40
+
+	logFileMode = 0644
+)
+
+type ReflogEntry struct {
+	OldHash   string
+	NewHash   string
+	Committer *objects.Signature
+	Message   string
+}
+
+// Read returns the reflog of ref, newest entry first, so that entry n is ref@{n}.
+// Short names are looked up as refs/heads/<ref> and then refs/remotes/<ref>.
+func Read(repo *repository.Repository, ref string) ([]ReflogEntry, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	logPath, err := findLog(repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return readLog(logPath, ref)
+}
+
+// All returns the reflog of every ref that has one, keyed by full ref name
+// (HEAD, refs/heads/main, ...), each newest entry first
+func All(repo *repository.Repository) (map[string][]ReflogEntry, error) {
+	if !repo.Exists() {
+		return nil, errors.ErrNotGitRepository
+	}
+
+	logs := make(map[string][]ReflogEntry)
+	root := filepath.Join(repo.GitDir, logsDir)
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		ref := filepath.ToSlash(rel)
+
+		entries, err := readLog(path, ref)
+		if err != nil {
+			return err
+		}
+		logs[ref] = entries
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.NewGitError("reflog", root, err)
+	}
+
+	return logs, nil
+}
+
+// Delete removes entry n (ref@{n}) from the reflog of ref and returns the
+// remaining entries, newest first
+func Delete(repo *repository.Repository, ref string, n int) ([]ReflogEntry, error) {
+	logPath, err := findLog(repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := readLog(logPath, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 0 || n >= len(entries) {
+		return nil, errors.NewGitError("reflog", fmt.Sprintf("%s@{%d}", ref, n), errors.ErrReferenceNotFound)
+	}
+
+	entries = append(entries[:n], entries[n+1:]...)
+
+	var buf strings.Builder
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		fmt.Fprintf(&buf, "%s %s %s\t%s\n", entry.OldHash, entry.NewHash, entry.Committer.String(), entry.Message)
+	}
+
+	if err := os.WriteFile(logPath, []byte(buf.String()), logFileMode); err != nil {
+		return nil, errors.NewGitError("reflog", ref, err)
+	}
+
+	return entries, nil
+}
+
+func readLog(logPath, ref string) ([]ReflogEntry, error) {
+	file, err := os.Open(logPath)
+	if err != nil {
+		return nil, errors.NewGitError("reflog", ref, err)
+	}
+	defer file.Close()
+
+	var entries []ReflogEntry
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		entry, err := parseEntry(line)
+		if err != nil {
+			return nil, errors.NewGitError("reflog", ref, fmt.Errorf("line %d: %w", lineNum, err))
+		}
+		entries = append(entries, *entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.NewGitError("reflog", ref, err)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+func findLog(repo *repository.Repository, ref string) (string, error) {
+	candidates := []string{ref}
+	if ref != headRef && !strings.HasPrefix(ref, refsDir) {
+		candidates = append(candidates, refsDir+"heads/"+ref, refsDir+"remotes/"+ref)
+	}
+
+	for _, candidate := range candidates {
+		path := filepath.Join(repo.GitDir, logsDir, filepath.FromSlash(candidate))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", errors.NewGitError("reflog", ref, errors.ErrReferenceNotFound)
+}
+
+// parseEntry parses "<old> <new> <name> <email> <timestamp> <tz>\t<message>"
+func parseEntry(line string) (*ReflogEntry, error) {
+	header, message, _ := strings.Cut(line, "\t")
+
+	if len(header) < 2*hashField+2 || header[hashField] != ' ' || header[2*hashField+1] != ' ' {
+		return nil, errors.ErrInvalidReference
+	}
+
+	committer, err := objects.ParseSignature(header[2*hashField+2:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReflogEntry{
+		OldHash:   header[:hashField],
+		NewHash:   header[hashField+1 : 2*hashField+1],
+		Committer: committer,
+		Message:   message,
+	}, nil
+}
@@ -0,0 +1,128 @@
+package reflog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+const (
+	firstHash  = "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	secondHash = "b94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	zeroHash   = "0000000000000000000000000000000000000000"
+)
+
+func setupTestRepo(t *testing.T) *repository.Repository {
+	t.Setenv("GIT_COMMITTER_NAME", "Test Committer")
+	t.Setenv("GIT_COMMITTER_EMAIL", "committer@example.com")
+
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	return repo
+}
+
+func TestRead_BranchAndHead(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := repo.UpdateRefWithMessage("refs/heads/main", firstHash, "commit (initial): first"); err != nil {
+		t.Fatalf("UpdateRefWithMessage failed: %v", err)
+	}
+	if err := repo.UpdateRefWithMessage("refs/heads/main", secondHash, "commit: second"); err != nil {
+		t.Fatalf("UpdateRefWithMessage failed: %v", err)
+	}
+
+	for _, ref := range []string{"main", "refs/heads/main", "HEAD"} {
+		entries, err := Read(repo, ref)
+		if err != nil {
+			t.Fatalf("Read(%q) failed: %v", ref, err)
+		}
+
+		if len(entries) != 2 {
+			t.Fatalf("Read(%q): expected 2 entries, got %d", ref, len(entries))
+		}
+
+		newest := entries[0]
+		if newest.OldHash != firstHash || newest.NewHash != secondHash || newest.Message != "commit: second" {
+			t.Errorf("Read(%q): unexpected newest entry %+v", ref, newest)
+		}
+
+		oldest := entries[1]
+		if oldest.OldHash != zeroHash || oldest.NewHash != firstHash {
+			t.Errorf("Read(%q): unexpected oldest entry %+v", ref, oldest)
+		}
+
+		if newest.Committer.Name != "Test Committer" || newest.Committer.Email != "committer@example.com" {
+			t.Errorf("Read(%q): unexpected committer %+v", ref, newest.Committer)
+		}
+	}
+}
+
+func TestRead_OtherBranchNotInHeadLog(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := repo.UpdateRefWithMessage("refs/heads/feature", firstHash, "branch: Created from HEAD"); err != nil {
+		t.Fatalf("UpdateRefWithMessage failed: %v", err)
+	}
+
+	if _, err := Read(repo, "HEAD"); err == nil {
+		t.Error("Expected no HEAD reflog for a non-current branch")
+	}
+
+	entries, err := Read(repo, "feature")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 entry, got %d", len(entries))
+	}
+}
+
+func TestRead_SetHeadDetached(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	if err := repo.UpdateRefWithMessage("refs/heads/main", firstHash, "commit (initial): first"); err != nil {
+		t.Fatalf("UpdateRefWithMessage failed: %v", err)
+	}
+	if err := repo.SetHead(secondHash, "checkout: moving from main to "+secondHash); err != nil {
+		t.Fatalf("SetHead failed: %v", err)
+	}
+
+	entries, err := Read(repo, "HEAD")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].OldHash != firstHash || entries[0].NewHash != secondHash {
+		t.Errorf("Unexpected checkout entry %+v", entries[0])
+	}
+
+	mainEntries, err := Read(repo, "main")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(mainEntries) != 1 {
+		t.Errorf("Expected branch log untouched by detaching HEAD, got %d entries", len(mainEntries))
+	}
+}
+
+func TestRead_Malformed(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	logPath := filepath.Join(repo.GitDir, "logs", "HEAD")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		t.Fatalf("Failed to create logs dir: %v", err)
+	}
+	if err := os.WriteFile(logPath, []byte("garbage\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	if _, err := Read(repo, "HEAD"); err == nil {
+		t.Error("Expected error for malformed reflog")
+	}
+}
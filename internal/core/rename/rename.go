@@ -0,0 +1,59 @@
+package rename
+
+import (
+	"sort"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+)
+
+// Detect pairs paths added in newFiles with paths deleted from oldFiles whose
+// blob is identical, returning new path -> old path. Each deleted path is
+// matched at most once; ties go to the lexically first candidate.
+func Detect(oldFiles, newFiles map[string]objects.TreeEntry) map[string]string {
+	deleted := make(map[string][]string)
+	for path, entry := range oldFiles {
+		if _, ok := newFiles[path]; !ok {
+			deleted[entry.Hash] = append(deleted[entry.Hash], path)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	for _, paths := range deleted {
+		sort.Strings(paths)
+	}
+
+	var added []string
+	for path := range newFiles {
+		if _, ok := oldFiles[path]; !ok {
+			added = append(added, path)
+		}
+	}
+	sort.Strings(added)
+
+	renames := make(map[string]string)
+	for _, path := range added {
+		candidates := deleted[newFiles[path].Hash]
+		if len(candidates) == 0 {
+			continue
+		}
+		renames[path] = candidates[0]
+		deleted[newFiles[path].Hash] = candidates[1:]
+	}
+
+	return renames
+}
+
+// Source returns the path that path was renamed from between oldFiles and
+// newFiles, if it was
+func Source(oldFiles, newFiles map[string]objects.TreeEntry, path string) (string, bool) {
+	if _, ok := newFiles[path]; !ok {
+		return "", false
+	}
+	if _, ok := oldFiles[path]; ok {
+		return "", false
+	}
+
+	source, ok := Detect(oldFiles, newFiles)[path]
+	return source, ok
+}
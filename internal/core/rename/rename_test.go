@@ -0,0 +1,50 @@
+package rename
+
+import (
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+)
+
+func files(pairs ...string) map[string]objects.TreeEntry {
+	m := make(map[string]objects.TreeEntry)
+	for i := 0; i < len(pairs); i += 2 {
+		m[pairs[i]] = objects.TreeEntry{Mode: objects.FileModeBlob, Name: pairs[i], Hash: pairs[i+1]}
+	}
+	return m
+}
+
+func TestDetect(t *testing.T) {
+	oldFiles := files("a.txt", "h1", "b.txt", "h2", "c.txt", "h3", "d.txt", "h3")
+	newFiles := files("a.txt", "h1", "moved/b.txt", "h2", "e.txt", "h3", "f.txt", "h4")
+
+	got := Detect(oldFiles, newFiles)
+	want := map[string]string{
+		"moved/b.txt": "b.txt",
+		"e.txt":       "c.txt",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Detect() = %v, want %v", got, want)
+	}
+	for newPath, oldPath := range want {
+		if got[newPath] != oldPath {
+			t.Errorf("Detect()[%q] = %q, want %q", newPath, got[newPath], oldPath)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	oldFiles := files("old.txt", "h1", "kept.txt", "h2")
+	newFiles := files("new.txt", "h1", "kept.txt", "h2")
+
+	if source, ok := Source(oldFiles, newFiles, "new.txt"); !ok || source != "old.txt" {
+		t.Errorf("Source(new.txt) = %q, %v, want old.txt, true", source, ok)
+	}
+	if _, ok := Source(oldFiles, newFiles, "kept.txt"); ok {
+		t.Error("Source(kept.txt) should not report a rename")
+	}
+	if _, ok := Source(oldFiles, newFiles, "missing.txt"); ok {
+		t.Error("Source(missing.txt) should not report a rename")
+	}
+}
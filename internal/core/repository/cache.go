@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+)
+
+// objectCache is an LRU of parsed objects bounded by their total content size
+type objectCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type cacheEntry struct {
+	hash string
+	obj  objects.Object
+	size int64
+}
+
+func newObjectCache(maxBytes int64) *objectCache {
+	return &objectCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *objectCache) get(hash string) (objects.Object, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[hash]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).obj, true
+}
+
+func (c *objectCache) add(hash string, obj objects.Object) {
+	size := obj.Size()
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[hash] = c.order.PushFront(&cacheEntry{hash: hash, obj: obj, size: size})
+	c.size += size
+
+	for c.size > c.maxBytes {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.hash)
+		c.size -= entry.size
+	}
+}
+
+func (c *objectCache) remove(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[hash]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, hash)
+		c.size -= elem.Value.(*cacheEntry).size
+	}
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/config"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	gitDirEnv      = "GIT_DIR"
+	gitWorkTreeEnv = "GIT_WORK_TREE"
+	gitFilePrefix  = "gitdir:"
+	commonDirFile  = "commondir"
+)
+
+// Discover finds the repository containing startDir by walking up to the
+// first directory with a .git entry, or that is itself a bare repository. A
+// .git file, as in a submodule, names the git directory to use instead.
+// GIT_DIR and GIT_WORK_TREE override what is found; with GIT_DIR alone,
+// startDir is the top of the work tree unless core.bare says there is none.
+func Discover(startDir string) (*Repository, error) {
+	startDir, err := filepath.Abs(startDir)
+	if err != nil {
+		return nil, errors.NewGitError("discover", startDir, err)
+	}
+
+	workDir, gitDir := startDir, os.Getenv(gitDirEnv)
+	if gitDir != "" {
+		if gitDir, err = filepath.Abs(gitDir); err != nil {
+			return nil, errors.NewGitError("discover", gitDir, err)
+		}
+		if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+			return nil, errors.NewGitError("discover", gitDir, errors.ErrNotGitRepository)
+		}
+	} else if workDir, gitDir, err = findGitDir(startDir); err != nil {
+		return nil, err
+	}
+
+	if env := os.Getenv(gitWorkTreeEnv); env != "" {
+		if workDir, err = filepath.Abs(env); err != nil {
+			return nil, errors.NewGitError("discover", env, err)
+		}
+	} else if bare, err := configuredBare(gitDir); err != nil {
+		return nil, err
+	} else if bare {
+		workDir = gitDir
+	}
+
+	// a linked worktree keeps objects and refs in a common directory that
+	// the rest of the repository does not know to look in
+	if _, err := os.Stat(filepath.Join(gitDir, commonDirFile)); err == nil {
+		return nil, errors.NewGitError("discover", gitDir, fmt.Errorf("linked worktrees are not supported"))
+	}
+
+	r := New(workDir)
+	r.GitDir = gitDir
+	return r, nil
+}
+
+// Open returns the repository whose work tree is exactly workDir, following
+// a .git file to its git directory. Unlike Discover it neither walks up nor
+// looks at the environment, so it suits nested repositories like submodules.
+func Open(workDir string) (*Repository, error) {
+	gitDir, ok, err := dotGit(workDir)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.NewGitError("open", workDir, errors.ErrNotGitRepository)
+	}
+	if bare, err := configuredBare(gitDir); err != nil {
+		return nil, err
+	} else if bare {
+		return NewBare(gitDir), nil
+	}
+
+	r := New(workDir)
+	r.GitDir = gitDir
+	return r, nil
+}
+
+// configuredBare reports whether core.bare in gitDir's config says the
+// repository has no work tree
+func configuredBare(gitDir string) (bool, error) {
+	cfg, err := config.Load(filepath.Join(gitDir, configFile))
+	if err != nil {
+		return false, errors.NewGitError("discover", gitDir, err)
+	}
+	value, _ := cfg.Get("core", "", "bare")
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	}
+	return false, nil
+}
+
+// findGitDir walks up from dir to the first directory with a .git entry or
+// laid out as a bare repository, returning that directory and the git
+// directory it leads to
+func findGitDir(dir string) (string, string, error) {
+	for {
+		if gitDir, ok, err := dotGit(dir); ok || err != nil {
+			return dir, gitDir, err
+		}
+		if isGitDir(dir) {
+			return dir, dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errors.ErrNotGitRepository
+		}
+		dir = parent
+	}
+}
+
+// dotGit returns the git directory dir's .git entry leads to, and whether
+// there is one
+func dotGit(dir string) (string, bool, error) {
+	candidate := filepath.Join(dir, gitDirName)
+	info, err := os.Stat(candidate)
+	if err != nil {
+		return "", false, nil
+	}
+	if info.IsDir() {
+		return candidate, true, nil
+	}
+	gitDir, err := readGitFile(candidate)
+	return gitDir, true, err
+}
+
+// isGitDir reports whether dir holds HEAD, objects and refs as a git
+// directory does
+func isGitDir(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, headFile)); err != nil || info.IsDir() {
+		return false
+	}
+	for _, sub := range []string{objectsDir, refsDir} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// readGitFile reads a "gitdir: <path>" file, resolving a relative path
+// against the file's directory
+func readGitFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.NewGitError("discover", path, err)
+	}
+
+	line, _, _ := strings.Cut(string(content), "\n")
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(line), gitFilePrefix)
+	if !ok {
+		return "", errors.NewGitError("discover", path, fmt.Errorf("invalid gitfile format"))
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return "", errors.NewGitError("discover", path, fmt.Errorf("gitdir %s: %w", gitDir, errors.ErrNotGitRepository))
+	}
+	return gitDir, nil
+}
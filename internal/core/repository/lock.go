@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"os"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const lockSuffix = ".lock"
+
+// fileLock is git's lockfile protocol for replacing a file: new content is
+// written to <path>.lock, created exclusively so that only one writer holds
+// it, and renamed over path once it is safely on disk
+type fileLock struct {
+	path string
+	file *os.File
+}
+
+// lockFile takes the lock on path, failing with ErrReferenceLocked when
+// another writer holds it
+func lockFile(path string) (*fileLock, error) {
+	file, err := os.OpenFile(path+lockSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, defaultFileMode)
+	if os.IsExist(err) {
+		return nil, errors.ErrReferenceLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fileLock{path: path, file: file}, nil
+}
+
+// commit writes content, syncs it and renames the lock over the target,
+// releasing the lock whether or not it succeeds
+func (l *fileLock) commit(content []byte) error {
+	if _, err := l.file.Write(content); err != nil {
+		l.release()
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		l.release()
+		return err
+	}
+	if err := l.file.Close(); err != nil {
+		os.Remove(l.path + lockSuffix)
+		return err
+	}
+	if err := os.Rename(l.path+lockSuffix, l.path); err != nil {
+		os.Remove(l.path + lockSuffix)
+		return err
+	}
+	return nil
+}
+
+// release gives the lock up, leaving the target unchanged
+func (l *fileLock) release() {
+	l.file.Close()
+	os.Remove(l.path + lockSuffix)
+}
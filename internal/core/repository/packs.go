@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/packindex"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// packIndexCache keeps the parsed index of each pack under objects/pack so
+// that looking an object up is an in-memory binary search. The list of packs
+// is reread whenever the pack directory's modification time changes, which
+// adding or removing a pack does; indexes of packs that remain are kept,
+// since a pack never changes under its name.
+type packIndexCache struct {
+	mu      sync.Mutex
+	listed  bool
+	modTime time.Time
+	paths   []string
+	indexes map[string]*packindex.Index
+}
+
+// refresh rereads the pack directory if it changed since the last call
+func (c *packIndexCache) refresh(packDir string) error {
+	info, err := os.Stat(packDir)
+	if os.IsNotExist(err) {
+		c.listed, c.paths, c.indexes = true, nil, nil
+		c.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if c.listed && info.ModTime().Equal(c.modTime) {
+		return nil
+	}
+
+	files, err := os.ReadDir(packDir)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	indexes := make(map[string]*packindex.Index)
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".idx") {
+			path := filepath.Join(packDir, file.Name())
+			paths = append(paths, path)
+			if idx, ok := c.indexes[path]; ok {
+				indexes[path] = idx
+			}
+		}
+	}
+
+	c.listed, c.modTime, c.paths, c.indexes = true, info.ModTime(), paths, indexes
+	return nil
+}
+
+// packIndexPaths lists the .idx files under objects/pack
+func (r *Repository) packIndexPaths() ([]string, error) {
+	c := &r.packs
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.refresh(filepath.Join(r.GitDir, objectsDir, packDirName)); err != nil {
+		return nil, err
+	}
+	return c.paths, nil
+}
+
+// packIndex returns the parsed index at idxPath, reading it on first use
+func (r *Repository) packIndex(idxPath string) (*packindex.Index, error) {
+	c := &r.packs
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if idx, ok := c.indexes[idxPath]; ok {
+		return idx, nil
+	}
+
+	idx, err := packindex.Read(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if c.indexes == nil {
+		c.indexes = make(map[string]*packindex.Index)
+	}
+	c.indexes[idxPath] = idx
+	return idx, nil
+}
+
+// findObjectInPackIndex returns the offset of hashStr in the pack indexed by
+// idxPath
+func (r *Repository) findObjectInPackIndex(hashStr, idxPath string) (int64, error) {
+	idx, err := r.packIndex(idxPath)
+	if err != nil {
+		return 0, err
+	}
+
+	entries := idx.Entries
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Hash >= hashStr })
+	if i < len(entries) && entries[i].Hash == hashStr {
+		return entries[i].Offset, nil
+	}
+	return 0, errors.ErrObjectNotFound
+}
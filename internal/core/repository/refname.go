@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const refsPrefix = "refs/"
+
+// CheckRefName applies git check-ref-format's rules to a full ref name,
+// which is either under refs/ or a top-level one such as HEAD or
+// ORIG_HEAD. Names that could step out of the git directory, such as
+// "refs/../config", fail with ErrInvalidReference.
+func CheckRefName(name string) error {
+	if !validRefName(name) {
+		return errors.NewGitError("check-ref-format", name, errors.ErrInvalidReference)
+	}
+	return nil
+}
+
+func validRefName(name string) bool {
+	if !strings.Contains(name, "/") {
+		return isPseudoRef(name)
+	}
+	if !strings.HasPrefix(name, refsPrefix) {
+		return false
+	}
+
+	if strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return false
+	}
+	if strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") {
+		return false
+	}
+
+	for _, c := range name {
+		if c <= ' ' || c == 0x7f || strings.ContainsRune("~^:?*[\\", c) {
+			return false
+		}
+	}
+
+	// an empty component also rules out "//"
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || strings.HasPrefix(part, ".") || strings.HasSuffix(part, lockSuffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPseudoRef reports whether name is an all-caps ref kept at the top of
+// the git directory, such as HEAD, ORIG_HEAD or FETCH_HEAD
+func isPseudoRef(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
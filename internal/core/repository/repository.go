@@ -1,17 +1,29 @@
 package repository
 
 import (
+	"bufio"
+	"bytes"
 	"compress/zlib"
-	"encoding/binary"
+	"encoding/hex"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/core/attributes"
+	"github.com/unkn0wn-root/git-go/internal/core/config"
+	"github.com/unkn0wn-root/git-go/internal/core/delta"
+	"github.com/unkn0wn-root/git-go/internal/core/eol"
 	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/ident"
 	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/packindex"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
@@ -22,14 +34,33 @@ const (
 	executableFileMode  = 0755
 	hashLength          = 40
 	hashPrefixLength    = 2
+	minPrefixLength     = 4
 	refPrefixLength     = 5
 	headRefPrefixLength = 16
 
-	objectsDir = "objects"
-	refsDir    = "refs"
-	headsDir   = "heads"
-	tagsDir    = "tags"
-	headFile   = "HEAD"
+	// tempObjectPattern names loose objects while they are being written
+	tempObjectPattern = "tmp_obj_*"
+
+	objectsDir  = "objects"
+	packDirName = "pack"
+	refsDir     = "refs"
+	headsDir    = "heads"
+	tagsDir     = "tags"
+	remotesDir  = "remotes"
+	headFile    = "HEAD"
+	configFile  = "config"
+
+	packedRefsFile = "packed-refs"
+	packIdxV2Magic = "\377tOc"
+	packFileMode   = 0444
+	sha1Size       = 20
+	packOfsDelta   = 6
+	packRefDelta   = 7
+	maxDeltaDepth  = 4096
+	logsDir        = "logs"
+	stashRef       = "refs/stash"
+	nullHash       = "0000000000000000000000000000000000000000"
+	maxSymrefDepth = 5
 
 	refPrefix   = "ref: "
 	headsPrefix = "ref: refs/heads/"
@@ -40,13 +71,75 @@ const (
 type Repository struct {
 	WorkDir string
 	GitDir  string
+
+	store ObjectStore
+	cache *objectCache
+	packs packIndexCache
+	// verifyOnWrite re-reads and re-hashes every stored object
+	verifyOnWrite bool
+
+	eolOnce  sync.Once
+	autoCRLF eol.AutoCRLF
+	eolErr   error
+
+	attrOnce sync.Once
+	attrs    *attributes.Matcher
+	attrErr  error
 }
 
+// New returns a repository rooted at workDir whose objects live on disk
+// under .git/objects
 func New(workDir string) *Repository {
-	return &Repository{
+	r := &Repository{
 		WorkDir: workDir,
 		GitDir:  filepath.Join(workDir, gitDirName),
 	}
+	r.store = &fileStore{repo: r}
+	return r
+}
+
+// NewBare returns a bare repository, one without a working tree whose
+// objects, refs and HEAD live directly in dir
+func NewBare(dir string) *Repository {
+	r := &Repository{
+		WorkDir: dir,
+		GitDir:  dir,
+	}
+	r.store = &fileStore{repo: r}
+	return r
+}
+
+// IsBare reports whether the repository has no working tree
+func (r *Repository) IsBare() bool {
+	return r.GitDir == r.WorkDir
+}
+
+// WithStore replaces the object store, e.g. with a MemoryStore in tests. Refs,
+// the index and reflogs stay on disk. It returns r for chaining.
+func (r *Repository) WithStore(store ObjectStore) *Repository {
+	r.store = store
+	if r.cache != nil {
+		r.cache = newObjectCache(r.cache.maxBytes)
+	}
+	return r
+}
+
+// WithCache enables an LRU cache of parsed objects for LoadObject, bounded by
+// the total content size of cached objects. It returns r for chaining.
+func (r *Repository) WithCache(maxBytes int64) *Repository {
+	if maxBytes > 0 {
+		r.cache = newObjectCache(maxBytes)
+	} else {
+		r.cache = nil
+	}
+	return r
+}
+
+// WithVerifyOnWrite makes every object write read the object back and check
+// it hashes to the name it was stored under. It returns r for chaining.
+func (r *Repository) WithVerifyOnWrite(enabled bool) *Repository {
+	r.verifyOnWrite = enabled
+	return r
 }
 
 func (r *Repository) Init() error {
@@ -74,41 +167,37 @@ func (r *Repository) Init() error {
 		return errors.NewGitError("init", headPath, err)
 	}
 
+	if r.IsBare() {
+		cfg, err := config.Load(filepath.Join(r.GitDir, configFile))
+		if err != nil {
+			return err
+		}
+		cfg.Set("core", "", "bare", "true")
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (r *Repository) Exists() bool {
-	_, err := os.Stat(r.GitDir)
+	// a bare repository's directory is there before it is initialized
+	path := r.GitDir
+	if r.IsBare() {
+		path = filepath.Join(r.GitDir, headFile)
+	}
+	_, err := os.Stat(path)
 	return !os.IsNotExist(err)
 }
 
 func (r *Repository) StoreObject(obj objects.Object) (string, error) {
-	if !r.Exists() {
-		return "", errors.ErrNotGitRepository
-	}
-
 	data := objects.SerializeObject(obj)
 	objHash := hash.ComputeSHA1(data)
-	objPath := r.objectPath(objHash)
-	objDir := filepath.Dir(objPath)
-	if err := os.MkdirAll(objDir, defaultDirMode); err != nil {
-		return "", errors.NewObjectError(objHash, obj.Type().String(), err)
-	}
-	if _, err := os.Stat(objPath); err == nil {
-		return objHash, nil
-	}
-
-	file, err := os.Create(objPath)
-	if err != nil {
-		return "", errors.NewObjectError(objHash, obj.Type().String(), err)
-	}
-	defer file.Close()
-
-	writer := zlib.NewWriter(file)
-	defer writer.Close()
+	content := data[bytes.IndexByte(data, 0)+1:]
 
-	if _, err := writer.Write(data); err != nil {
-		return "", errors.NewObjectError(objHash, obj.Type().String(), err)
+	if err := r.put(objHash, obj.Type(), content); err != nil {
+		return "", err
 	}
 
 	switch o := obj.(type) {
@@ -123,319 +212,534 @@ func (r *Repository) StoreObject(obj objects.Object) (string, error) {
 	return objHash, nil
 }
 
-func (r *Repository) LoadObject(hashStr string) (objects.Object, error) {
-	if !r.Exists() {
-		return nil, errors.ErrNotGitRepository
+// StoreRawObject stores content, without its header, as an object of type
+// typ and returns its hash
+func (r *Repository) StoreRawObject(typ objects.ObjectType, content []byte) (string, error) {
+	objHash := hash.ComputeObjectHash(typ.String(), content)
+	if err := r.put(objHash, typ, content); err != nil {
+		return "", err
 	}
+	return objHash, nil
+}
 
-	if !hash.ValidateHash(hashStr) {
-		return nil, errors.ErrInvalidHash
+func (r *Repository) put(objHash string, typ objects.ObjectType, content []byte) error {
+	if err := r.store.Put(objHash, typ, content); err != nil {
+		return err
 	}
+	if r.verifyOnWrite {
+		return r.VerifyObject(objHash)
+	}
+	return nil
+}
 
-	objPath := r.objectPath(hashStr)
-	file, err := os.Open(objPath)
-	if err == nil {
-		defer file.Close()
+// VerifyObject reads an object back from the store and checks that it
+// hashes to hashStr, failing with ErrHashMismatch when it does not
+func (r *Repository) VerifyObject(hashStr string) error {
+	typ, content, err := r.ReadObjectData(hashStr)
+	if err != nil {
+		return errors.NewObjectError(hashStr, "", err)
+	}
+	if got := hash.ComputeObjectHash(typ.String(), content); got != hashStr {
+		return errors.NewObjectError(hashStr, typ.String(), fmt.Errorf("%w: content hashes to %s", errors.ErrHashMismatch, got))
+	}
+	return nil
+}
 
-		reader, err := zlib.NewReader(file)
-		if err != nil {
-			return nil, errors.NewObjectError(hashStr, "unknown", err)
+func (r *Repository) LoadObject(hashStr string) (objects.Object, error) {
+	if r.cache != nil {
+		if obj, ok := r.cache.get(hashStr); ok {
+			return obj, nil
 		}
-		defer reader.Close()
+	}
 
-		data, err := io.ReadAll(reader)
-		if err != nil {
-			return nil, errors.NewObjectError(hashStr, "unknown", err)
-		}
+	obj, err := r.loadObject(hashStr)
+	if err != nil {
+		return nil, err
+	}
 
-		objType, _, content, err := objects.ParseObjectHeader(data)
-		if err != nil {
-			return nil, errors.NewObjectError(hashStr, "unknown", err)
-		}
+	if r.cache != nil {
+		r.cache.add(hashStr, obj)
+	}
+	return obj, nil
+}
 
-		obj, err := objects.ParseObject(objType, content)
-		if err != nil {
-			return nil, errors.NewObjectError(hashStr, objType.String(), err)
-		}
+func (r *Repository) loadObject(hashStr string) (objects.Object, error) {
+	if !hash.ValidateHash(hashStr) {
+		return nil, errors.ErrInvalidHash
+	}
 
-		switch o := obj.(type) {
-		case *objects.Blob:
-			o.SetHash(hashStr)
-		case *objects.Tree:
-			o.SetHash(hashStr)
-		case *objects.Commit:
-			o.SetHash(hashStr)
+	objType, content, err := r.store.Get(hashStr)
+	if err != nil {
+		if stderrors.Is(err, errors.ErrObjectNotFound) {
+			return nil, errors.ErrObjectNotFound
 		}
+		return nil, err
+	}
 
-		return obj, nil
+	obj, err := objects.ParseObject(objType, content)
+	if err != nil {
+		return nil, errors.NewObjectError(hashStr, objType.String(), err)
 	}
 
-	// if not found in loose objects, try pack files
-	if os.IsNotExist(err) {
-		obj, err := r.loadObjectFromPack(hashStr)
-		if err != nil {
-			return nil, errors.ErrObjectNotFound
-		}
-		return obj, nil
+	switch o := obj.(type) {
+	case *objects.Blob:
+		o.SetHash(hashStr)
+	case *objects.Tree:
+		o.SetHash(hashStr)
+	case *objects.Commit:
+		o.SetHash(hashStr)
 	}
 
-	return nil, errors.NewObjectError(hashStr, "unknown", err)
+	return obj, nil
 }
 
-func (r *Repository) objectPath(hash string) string {
-	return filepath.Join(r.GitDir, objectsDir, hash[:hashPrefixLength], hash[hashPrefixLength:])
+// LoadCommit loads hashStr and checks it is a commit, failing with
+// ErrNotACommit when it is another type
+func (r *Repository) LoadCommit(hashStr string) (*objects.Commit, error) {
+	obj, err := r.LoadObject(hashStr)
+	if err != nil {
+		return nil, err
+	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return nil, errors.NewObjectError(hashStr, obj.Type().String(), errors.ErrNotACommit)
+	}
+	return commit, nil
 }
 
-func (r *Repository) loadObjectFromPack(hashStr string) (objects.Object, error) {
-	packDir := filepath.Join(r.GitDir, objectsDir, "pack")
-	if _, err := os.Stat(packDir); os.IsNotExist(err) {
-		return nil, errors.ErrObjectNotFound
+// LoadTree loads hashStr and checks it is a tree, failing with ErrNotATree
+// when it is another type
+func (r *Repository) LoadTree(hashStr string) (*objects.Tree, error) {
+	obj, err := r.LoadObject(hashStr)
+	if err != nil {
+		return nil, err
 	}
+	tree, ok := obj.(*objects.Tree)
+	if !ok {
+		return nil, errors.NewObjectError(hashStr, obj.Type().String(), errors.ErrNotATree)
+	}
+	return tree, nil
+}
 
-	// Find all pack index files
-	files, err := os.ReadDir(packDir)
+// LoadBlob loads hashStr and checks it is a blob, failing with ErrNotABlob
+// when it is another type
+func (r *Repository) LoadBlob(hashStr string) (*objects.Blob, error) {
+	obj, err := r.LoadObject(hashStr)
 	if err != nil {
 		return nil, err
 	}
+	blob, ok := obj.(*objects.Blob)
+	if !ok {
+		return nil, errors.NewObjectError(hashStr, obj.Type().String(), errors.ErrNotABlob)
+	}
+	return blob, nil
+}
 
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), ".idx") {
-			idxPath := filepath.Join(packDir, file.Name())
-			packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+// OpenBlob streams the content of a blob and returns its size. Loose blobs
+// are inflated as they are read rather than loaded whole; the caller closes
+// the reader.
+func (r *Repository) OpenBlob(hashStr string) (io.ReadCloser, int64, error) {
+	if !hash.ValidateHash(hashStr) {
+		return nil, 0, errors.ErrInvalidHash
+	}
 
-			// Try to find object in this pack
-			if obj, err := r.loadObjectFromSpecificPack(hashStr, idxPath, packPath); err == nil {
-				return obj, nil
+	if fs, ok := r.store.(*fileStore); ok && r.Exists() {
+		objType, size, reader, err := fs.openLoose(hashStr)
+		if err == nil {
+			if objType != objects.ObjectTypeBlob {
+				reader.Close()
+				return nil, 0, errors.NewObjectError(hashStr, objType.String(), errors.ErrNotABlob)
 			}
+			return reader, size, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, 0, err
 		}
 	}
 
-	return nil, errors.ErrObjectNotFound
+	// packed and non-file objects are held in memory already
+	blob, err := r.LoadBlob(hashStr)
+	if err != nil {
+		return nil, 0, err
+	}
+	return io.NopCloser(bytes.NewReader(blob.Content())), blob.Size(), nil
 }
 
-func (r *Repository) loadObjectFromSpecificPack(hashStr, idxPath, packPath string) (objects.Object, error) {
-	// read pack index to find object offset
-	offset, err := r.findObjectInPackIndex(hashStr, idxPath)
-	if err != nil {
-		return nil, err
+// ObjectSize returns the size of an object's content from its loose header
+// or pack entry, without inflating the content. A deltified entry has only
+// its delta header inflated.
+func (r *Repository) ObjectSize(hashStr string) (int64, error) {
+	if !hash.ValidateHash(hashStr) {
+		return 0, errors.ErrInvalidHash
 	}
 
-	// read object from pack at the given offset
-	return r.readObjectFromPack(hashStr, packPath, offset)
-}
+	if fs, ok := r.store.(*fileStore); ok && r.Exists() {
+		_, size, reader, err := fs.openLoose(hashStr)
+		if err == nil {
+			reader.Close()
+			return size, nil
+		}
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
 
-func (r *Repository) findObjectInPackIndex(hashStr, idxPath string) (int64, error) {
-	idxFile, err := os.Open(idxPath)
+		idxPaths, err := r.packIndexPaths()
+		if err != nil {
+			return 0, errors.NewObjectError(hashStr, "unknown", err)
+		}
+		for _, idxPath := range idxPaths {
+			offset, err := r.findObjectInPackIndex(hashStr, idxPath)
+			if err != nil {
+				continue
+			}
+			size, err := r.packedObjectSize(strings.TrimSuffix(idxPath, ".idx")+".pack", offset)
+			if err != nil {
+				return 0, errors.NewObjectError(hashStr, "unknown", err)
+			}
+			return size, nil
+		}
+		return 0, errors.NewObjectError(hashStr, "unknown", errors.ErrObjectNotFound)
+	}
+
+	_, content, err := r.store.Get(hashStr)
 	if err != nil {
 		return 0, err
 	}
-	defer idxFile.Close()
+	return int64(len(content)), nil
+}
 
-	// read pack index format
-	magic := make([]byte, 4)
-	if _, err := idxFile.Read(magic); err != nil {
+// packedObjectSize reads the size of the pack entry at offset. A delta
+// records the size of the object it rebuilds after the size of its base.
+func (r *Repository) packedObjectSize(packPath string, offset int64) (int64, error) {
+	packFile, err := os.Open(packPath)
+	if err != nil {
 		return 0, err
 	}
+	defer packFile.Close()
 
-	// check for version 2 index (starts with 0xff744f63 magic)
-	if binary.BigEndian.Uint32(magic) == 0xff744f63 {
-		return r.findObjectInPackIndexV2(hashStr, idxFile)
-	} else {
-		idxFile.Seek(0, 0) // reset to beginning
-		return r.findObjectInPackIndexV1(hashStr, idxFile)
+	objType, size, dataOffset, err := r.readPackObjectHeader(packFile, offset)
+	if err != nil {
+		return 0, err
 	}
-}
 
-// V1 - We have to support both versions of pack index
-func (r *Repository) findObjectInPackIndexV1(hashStr string, idxFile *os.File) (int64, error) {
-	// skip fanout table (256 * 4 bytes)
-	if _, err := idxFile.Seek(256*4, 0); err != nil {
-		return 0, err
+	switch objType {
+	case packOfsDelta:
+		_, n, err := readOfsDeltaDistance(packFile, dataOffset)
+		if err != nil {
+			return 0, err
+		}
+		dataOffset += n
+	case packRefDelta:
+		dataOffset += sha1Size
+	default:
+		return size, nil
 	}
 
-	// read number of objects from last fanout entry
-	if _, err := idxFile.Seek(255*4, 0); err != nil {
+	if _, err := packFile.Seek(dataOffset, 0); err != nil {
 		return 0, err
 	}
-	numObjectsBytes := make([]byte, 4)
-	if _, err := idxFile.Read(numObjectsBytes); err != nil {
+	reader, err := zlib.NewReader(packFile)
+	if err != nil {
 		return 0, err
 	}
-	numObjects := binary.BigEndian.Uint32(numObjectsBytes)
+	defer reader.Close()
 
-	if _, err := idxFile.Seek(256*4, 0); err != nil {
+	// two varints of at most ten bytes each
+	header := make([]byte, 20)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
 		return 0, err
 	}
+	_, next := delta.ReadSize(header[:n], 0)
+	if next >= n {
+		return 0, errors.ErrCorruptedRepository
+	}
+	resultSize, _ := delta.ReadSize(header[:n], next)
+	return resultSize, nil
+}
 
-	// each entry is 24 bytes: 4-byte offset + 20-byte hash
-	for i := uint32(0); i < numObjects; i++ {
-		entry := make([]byte, 24)
-		if _, err := idxFile.Read(entry); err != nil {
-			return 0, err
-		}
+// AutoCRLF returns the core.autocrlf setting, read once per repository
+func (r *Repository) AutoCRLF() (eol.AutoCRLF, error) {
+	r.eolOnce.Do(func() {
+		r.autoCRLF, r.eolErr = eol.Load(r.GitDir)
+	})
+	return r.autoCRLF, r.eolErr
+}
+
+// Attributes returns the .gitattributes matcher for the work tree, loaded
+// once per repository
+func (r *Repository) Attributes() (*attributes.Matcher, error) {
+	r.attrOnce.Do(func() {
+		r.attrs, r.attrErr = attributes.Load(r.WorkDir, r.GitDir)
+	})
+	return r.attrs, r.attrErr
+}
 
-		offset := binary.BigEndian.Uint32(entry[:4])
-		hash := fmt.Sprintf("%x", entry[4:24])
+// AutoCRLFFor returns the line ending conversion for a work tree path: the
+// core.autocrlf setting adjusted by the path's text attribute
+func (r *Repository) AutoCRLFFor(gitPath string) (eol.AutoCRLF, error) {
+	autoCRLF, err := r.AutoCRLF()
+	if err != nil {
+		return eol.AutoCRLFFalse, err
+	}
+	attrs, err := r.Attributes()
+	if err != nil {
+		return eol.AutoCRLFFalse, err
+	}
+	return autoCRLF.ForAttributes(attrs.Resolve(gitPath)), nil
+}
 
-		if hash == hashStr {
-			return int64(offset), nil
+// WriteBlobFile streams a blob into the file at path, creating or
+// truncating it with mode. Line endings are converted as core.autocrlf and
+// the path's attributes ask.
+func (r *Repository) WriteBlobFile(hashStr, path string, mode os.FileMode) error {
+	autoCRLF, err := r.AutoCRLF()
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(r.WorkDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		if autoCRLF, err = r.AutoCRLFFor(filepath.ToSlash(rel)); err != nil {
+			return err
 		}
 	}
 
-	return 0, errors.ErrObjectNotFound
+	reader, _, err := r.OpenBlob(hashStr)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := autoCRLF.CopyToWorkTree(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
 
-func (r *Repository) findObjectInPackIndexV2(hashStr string, idxFile *os.File) (int64, error) {
-	// skip magic (4 bytes) and version (4 bytes)
-	if _, err := idxFile.Seek(8, 0); err != nil {
-		return 0, err
+// WriteEntryFile writes the blob of a tree entry to path as its mode asks:
+// a symlink to the path the blob holds, or a file that is executable or
+// not. A symlink already at path is replaced rather than written through.
+func (r *Repository) WriteEntryFile(hashStr, path string, mode objects.FileMode) error {
+	if info, err := os.Lstat(path); err == nil && (mode == objects.FileModeSymlink || info.Mode()&os.ModeSymlink != 0) {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
 	}
 
-	// read fanout table to get number of objects
-	fanout := make([]byte, 256*4)
-	if _, err := idxFile.Read(fanout); err != nil {
-		return 0, err
+	if mode == objects.FileModeSymlink {
+		blob, err := r.LoadBlob(hashStr)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(string(blob.Content()), path)
 	}
-	numObjects := binary.BigEndian.Uint32(fanout[255*4 : 256*4])
 
-	// calculate the first byte of the hash to use as fanout index
-	// convert first hex character to its numeric value
-	firstChar := hashStr[0]
-	var fanoutIdx int
-	if firstChar >= '0' && firstChar <= '9' {
-		fanoutIdx = int(firstChar - '0')
-	} else if firstChar >= 'a' && firstChar <= 'f' {
-		fanoutIdx = int(firstChar-'a') + 10
-	} else {
-		return 0, errors.ErrInvalidHash
+	perm := os.FileMode(defaultFileMode)
+	if mode == objects.FileModeExecutable {
+		perm = executableFileMode
 	}
+	if err := r.WriteBlobFile(hashStr, path, perm); err != nil {
+		return err
+	}
+	// a truncated file keeps its old mode, so apply it explicitly
+	return os.Chmod(path, perm)
+}
 
-	// convert second hex character and combine for full byte value
-	secondChar := hashStr[1]
-	var secondNibble int
-	if secondChar >= '0' && secondChar <= '9' {
-		secondNibble = int(secondChar - '0')
-	} else if secondChar >= 'a' && secondChar <= 'f' {
-		secondNibble = int(secondChar-'a') + 10
-	} else {
-		return 0, errors.ErrInvalidHash
+// ReadObjectData returns the type and raw content of an object without
+// parsing it
+func (r *Repository) ReadObjectData(hashStr string) (objects.ObjectType, []byte, error) {
+	if !hash.ValidateHash(hashStr) {
+		return "", nil, errors.ErrInvalidHash
 	}
+	return r.store.Get(hashStr)
+}
+
+// HasObject reports whether the object store holds hashStr
+func (r *Repository) HasObject(hashStr string) bool {
+	return hash.ValidateHash(hashStr) && r.store.Has(hashStr)
+}
 
-	fanoutIdx = fanoutIdx*16 + secondNibble
+// ForEachObject calls fn once for every object in the object store. Only
+// object headers are read from disk, so no object content is inflated.
+// Objects whose header cannot be read are passed with an empty type.
+func (r *Repository) ForEachObject(fn func(hash string, typ objects.ObjectType) error) error {
+	return r.store.ForEach(fn)
+}
 
-	// get start and end positions for bin search
-	startCount := uint32(0)
-	if fanoutIdx > 0 {
-		startCount = binary.BigEndian.Uint32(fanout[(fanoutIdx-1)*4 : fanoutIdx*4])
+// LooseObjectInfo stats the loose file of an object; it fails with an
+// os.IsNotExist error when the object is only packed or absent
+func (r *Repository) LooseObjectInfo(hashStr string) (os.FileInfo, error) {
+	if !hash.ValidateHash(hashStr) {
+		return nil, errors.ErrInvalidHash
 	}
-	endCount := binary.BigEndian.Uint32(fanout[fanoutIdx*4 : (fanoutIdx+1)*4])
+	return os.Stat(r.objectPath(hashStr))
+}
 
-	// search in hash table
-	hashTableOffset := int64(8 + 256*4) // after header and fanout
-	for i := startCount; i < endCount; i++ {
-		hashOffset := hashTableOffset + int64(i)*20
-		if _, err := idxFile.Seek(hashOffset, 0); err != nil {
-			return 0, err
-		}
+// RemoveLooseObject deletes the loose file of an object and its fan-out
+// directory once empty
+func (r *Repository) RemoveLooseObject(hashStr string) error {
+	if !hash.ValidateHash(hashStr) {
+		return errors.ErrInvalidHash
+	}
 
-		hashBytes := make([]byte, 20)
-		if _, err := idxFile.Read(hashBytes); err != nil {
-			return 0, err
-		}
+	path := r.objectPath(hashStr)
+	if err := os.Remove(path); err != nil {
+		return errors.NewObjectError(hashStr, "unknown", err)
+	}
 
-		hash := fmt.Sprintf("%x", hashBytes)
-		if hash == hashStr {
-			// get the offset from offset table
-			offsetTableOffset := int64(8 + 256*4 + int(numObjects)*20 + int(numObjects)*4) // After header, fanout, hashes, and CRCs
-			offsetPos := offsetTableOffset + int64(i)*4
+	if r.cache != nil {
+		r.cache.remove(hashStr)
+	}
 
-			if _, err := idxFile.Seek(offsetPos, 0); err != nil {
-				return 0, err
-			}
+	// fails harmlessly while other objects share the directory
+	os.Remove(filepath.Dir(path))
+	return nil
+}
 
-			offsetBytes := make([]byte, 4)
-			if _, err := idxFile.Read(offsetBytes); err != nil {
-				return 0, err
-			}
+// StorePack installs a pack and its index under objects/pack, named after the
+// pack checksum. The index is written last so readers never see an index
+// without its pack.
+func (r *Repository) StorePack(packData, idxData []byte) (string, error) {
+	if len(packData) < sha1Size {
+		return "", errors.NewGitError("store-pack", "", errors.ErrCorruptedRepository)
+	}
+
+	packDir := filepath.Join(r.GitDir, objectsDir, packDirName)
+	if err := os.MkdirAll(packDir, defaultDirMode); err != nil {
+		return "", errors.NewGitError("store-pack", packDir, err)
+	}
 
-			offset := binary.BigEndian.Uint32(offsetBytes)
-			return int64(offset), nil
+	name := "pack-" + hex.EncodeToString(packData[len(packData)-sha1Size:])
+	packPath := filepath.Join(packDir, name+".pack")
+
+	for _, file := range []struct {
+		path string
+		data []byte
+	}{
+		{packPath, packData},
+		{filepath.Join(packDir, name+".idx"), idxData},
+	} {
+		tmpPath := file.path + ".tmp"
+		if err := os.WriteFile(tmpPath, file.data, packFileMode); err != nil {
+			return "", errors.NewGitError("store-pack", tmpPath, err)
+		}
+		if err := os.Rename(tmpPath, file.path); err != nil {
+			os.Remove(tmpPath)
+			return "", errors.NewGitError("store-pack", file.path, err)
 		}
 	}
 
-	return 0, errors.ErrObjectNotFound
+	return packPath, nil
+}
+
+func (r *Repository) objectPath(hash string) string {
+	return filepath.Join(r.GitDir, objectsDir, hash[:hashPrefixLength], hash[hashPrefixLength:])
 }
 
-func (r *Repository) readObjectFromPack(hashStr, packPath string, offset int64) (objects.Object, error) {
+// readRawObjectFromPack inflates the object stored at offset, resolving
+// OFS_DELTA entries against earlier entries of the same pack and REF_DELTA
+// entries against this pack first and then any object the repository holds
+func (r *Repository) readRawObjectFromPack(packPath string, offset int64) (objects.ObjectType, []byte, error) {
 	packFile, err := os.Open(packPath)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 	defer packFile.Close()
 
-	// seek to object offset
-	if _, err := packFile.Seek(offset, 0); err != nil {
-		return nil, err
+	idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+	return r.readPackedObject(packFile, idxPath, offset, 0)
+}
+
+func (r *Repository) readPackedObject(packFile *os.File, idxPath string, offset int64, depth int) (objects.ObjectType, []byte, error) {
+	if depth > maxDeltaDepth {
+		return "", nil, fmt.Errorf("delta chain deeper than %d", maxDeltaDepth)
 	}
 
 	// read object header to get type and size
 	objType, size, dataOffset, err := r.readPackObjectHeader(packFile, offset)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	// only handle simple objects (not deltas for now)
-	if objType < 1 || objType > 4 {
-		return nil, fmt.Errorf("unsupported pack object type: %d", objType)
+	var baseType objects.ObjectType
+	var baseData []byte
+	switch objType {
+	case packOfsDelta:
+		distance, n, err := readOfsDeltaDistance(packFile, dataOffset)
+		if err != nil {
+			return "", nil, err
+		}
+		if distance <= 0 || distance > offset {
+			return "", nil, errors.ErrCorruptedRepository
+		}
+		dataOffset += n
+
+		baseType, baseData, err = r.readPackedObject(packFile, idxPath, offset-distance, depth+1)
+		if err != nil {
+			return "", nil, err
+		}
+	case packRefDelta:
+		raw := make([]byte, sha1Size)
+		if _, err := packFile.ReadAt(raw, dataOffset); err != nil {
+			return "", nil, err
+		}
+		dataOffset += sha1Size
+
+		baseHash := hex.EncodeToString(raw)
+		if baseOffset, err := r.findObjectInPackIndex(baseHash, idxPath); err == nil {
+			baseType, baseData, err = r.readPackedObject(packFile, idxPath, baseOffset, depth+1)
+			if err != nil {
+				return "", nil, err
+			}
+		} else if baseType, baseData, err = r.store.Get(baseHash); err != nil {
+			return "", nil, fmt.Errorf("delta base %s: %w", baseHash, err)
+		}
 	}
 
 	// seek to compressed data
 	if _, err := packFile.Seek(dataOffset, 0); err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
 	// read and decompress object data
 	reader, err := zlib.NewReader(packFile)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 	defer reader.Close()
 
-	data := make([]byte, size)
-	if _, err := io.ReadFull(reader, data); err != nil {
-		return nil, err
+	// the size is only what the pack claims, so the buffer grows with what
+	// actually inflates instead of being allocated from it up front
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(reader, size+1))
+	if err != nil {
+		return "", nil, err
 	}
-
-	// convert pack object type to Git object type
-	var gitObjType objects.ObjectType
-	switch objType {
-	case 1: // OBJ_COMMIT
-		gitObjType = objects.ObjectTypeCommit
-	case 2: // OBJ_TREE
-		gitObjType = objects.ObjectTypeTree
-	case 3: // OBJ_BLOB
-		gitObjType = objects.ObjectTypeBlob
-	case 4: // OBJ_TAG
-		gitObjType = objects.ObjectTypeTag
-	default:
-		return nil, fmt.Errorf("unknown object type: %d", objType)
+	if n != size {
+		return "", nil, fmt.Errorf("%w: object at offset %d inflates to %d bytes, expected %d",
+			errors.ErrCorruptedRepository, offset, n, size)
 	}
+	data := buf.Bytes()
 
-	obj, err := objects.ParseObject(gitObjType, data)
-	if err != nil {
-		return nil, err
+	if baseData != nil {
+		content, err := delta.Apply(baseData, data)
+		if err != nil {
+			return "", nil, err
+		}
+		return baseType, content, nil
 	}
 
-	switch o := obj.(type) {
-	case *objects.Blob:
-		o.SetHash(hashStr)
-	case *objects.Tree:
-		o.SetHash(hashStr)
-	case *objects.Commit:
-		o.SetHash(hashStr)
+	// convert pack object type to Git object type
+	gitObjType, err := packObjectType(objType)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return obj, nil
+	return gitObjType, data, nil
 }
 
 func (r *Repository) readPackObjectHeader(packFile *os.File, offset int64) (int, int64, int64, error) {
@@ -469,45 +773,628 @@ func (r *Repository) readPackObjectHeader(packFile *os.File, offset int64) (int,
 		currentOffset++
 	}
 
+	if size < 0 {
+		return 0, 0, 0, errors.ErrCorruptedRepository
+	}
+
 	return objType, size, currentOffset, nil
 }
 
+// ExpandPrefix resolves an abbreviated object hash by searching loose objects
+// and the sorted hash tables of every pack index
+func (r *Repository) ExpandPrefix(prefix string) (string, error) {
+	if !r.Exists() {
+		return "", errors.ErrNotGitRepository
+	}
+
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < minPrefixLength || len(prefix) > hashLength || !isHex(prefix) {
+		return "", errors.NewGitError("expand-prefix", prefix, errors.ErrInvalidHash)
+	}
+
+	matches := make(map[string]struct{})
+
+	looseDir := filepath.Join(r.GitDir, objectsDir, prefix[:hashPrefixLength])
+	entries, err := os.ReadDir(looseDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", errors.NewGitError("expand-prefix", looseDir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && len(name) == hashLength-hashPrefixLength && strings.HasPrefix(name, prefix[hashPrefixLength:]) {
+			matches[prefix[:hashPrefixLength]+name] = struct{}{}
+		}
+	}
+
+	idxPaths, err := r.packIndexPaths()
+	if err != nil {
+		return "", errors.NewGitError("expand-prefix", prefix, err)
+	}
+	for _, idxPath := range idxPaths {
+		packIdx, err := r.packIndex(idxPath)
+		if err != nil {
+			return "", errors.NewGitError("expand-prefix", idxPath, err)
+		}
+		packEntries := packIdx.Entries
+
+		// hashes are sorted, so the matches form one contiguous run
+		start := sort.Search(len(packEntries), func(i int) bool {
+			return packEntries[i].Hash >= prefix
+		})
+		for i := start; i < len(packEntries) && strings.HasPrefix(packEntries[i].Hash, prefix); i++ {
+			matches[packEntries[i].Hash] = struct{}{}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", errors.NewGitError("expand-prefix", prefix, errors.ErrObjectNotFound)
+	case 1:
+		for match := range matches {
+			return match, nil
+		}
+	}
+
+	return "", errors.NewGitError("expand-prefix", prefix, fmt.Errorf("%w (%d candidates)", errors.ErrAmbiguousHash, len(matches)))
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Repository) forEachPackedObject(idxPath string, entries []packindex.Entry, seen map[string]struct{}, fn func(string, objects.ObjectType) error) error {
+	packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+	packFile, err := os.Open(packPath)
+	if err != nil {
+		return errors.NewGitError("for-each-object", packPath, err)
+	}
+	defer packFile.Close()
+
+	offsets := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		offsets[entry.Hash] = entry.Offset
+	}
+
+	for _, entry := range entries {
+		if _, ok := seen[entry.Hash]; ok {
+			continue
+		}
+
+		typ, _ := r.packedObjectType(packFile, entry.Offset, offsets)
+
+		seen[entry.Hash] = struct{}{}
+		if err := fn(entry.Hash, typ); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// looseObjectType inflates just enough of a loose object to read its header
+func (r *Repository) looseObjectType(objHash string) (objects.ObjectType, error) {
+	file, err := os.Open(r.objectPath(objHash))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	reader, err := zlib.NewReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	header, err := bufio.NewReader(reader).ReadString(0)
+	if err != nil {
+		return "", errors.ErrInvalidObjectFormat
+	}
+
+	typ, _, ok := strings.Cut(header, " ")
+	if !ok {
+		return "", errors.ErrInvalidObjectFormat
+	}
+
+	return objects.ParseObjectType(typ)
+}
+
+// packedObjectType reads the pack entry header at offset, following delta
+// bases within the same pack until a base object type is found
+func (r *Repository) packedObjectType(packFile *os.File, offset int64, offsets map[string]int64) (objects.ObjectType, error) {
+	for depth := 0; depth < maxDeltaDepth; depth++ {
+		packType, _, dataOffset, err := r.readPackObjectHeader(packFile, offset)
+		if err != nil {
+			return "", err
+		}
+
+		switch packType {
+		case packOfsDelta:
+			distance, _, err := readOfsDeltaDistance(packFile, dataOffset)
+			if err != nil {
+				return "", err
+			}
+			if distance <= 0 || distance > offset {
+				return "", errors.ErrCorruptedRepository
+			}
+			offset -= distance
+		case packRefDelta:
+			base := make([]byte, 20)
+			if _, err := packFile.ReadAt(base, dataOffset); err != nil {
+				return "", err
+			}
+			baseOffset, ok := offsets[hex.EncodeToString(base)]
+			if !ok {
+				return "", fmt.Errorf("delta base %x not in pack", base)
+			}
+			offset = baseOffset
+		default:
+			return packObjectType(packType)
+		}
+	}
+
+	return "", fmt.Errorf("delta chain deeper than %d", maxDeltaDepth)
+}
+
+// readOfsDeltaDistance decodes the negative base offset of an OFS_DELTA
+// entry, also returning how many bytes it takes
+func readOfsDeltaDistance(packFile *os.File, offset int64) (int64, int64, error) {
+	b := make([]byte, 1)
+	if _, err := packFile.ReadAt(b, offset); err != nil {
+		return 0, 0, err
+	}
+
+	distance := int64(b[0] & 0x7f)
+	n := int64(1)
+	for b[0]&0x80 != 0 {
+		if _, err := packFile.ReadAt(b, offset+n); err != nil {
+			return 0, 0, err
+		}
+		distance = ((distance + 1) << 7) | int64(b[0]&0x7f)
+		n++
+	}
+
+	return distance, n, nil
+}
+
+func packObjectType(packType int) (objects.ObjectType, error) {
+	switch packType {
+	case 1: // OBJ_COMMIT
+		return objects.ObjectTypeCommit, nil
+	case 2: // OBJ_TREE
+		return objects.ObjectTypeTree, nil
+	case 3: // OBJ_BLOB
+		return objects.ObjectTypeBlob, nil
+	case 4: // OBJ_TAG
+		return objects.ObjectTypeTag, nil
+	default:
+		return "", fmt.Errorf("unknown object type: %d", packType)
+	}
+}
+
+// GetHead returns the commit HEAD points at, or "" on an unborn branch
 func (r *Repository) GetHead() (string, error) {
+	_, _, headHash, err := r.HeadRef()
+	return headHash, err
+}
+
+// HeadRef reads HEAD. A symbolic HEAD returns the ref it points at and that
+// ref's commit, "" on an unborn branch; a detached HEAD returns detached and
+// the commit it holds.
+func (r *Repository) HeadRef() (string, bool, string, error) {
 	headPath := filepath.Join(r.GitDir, headFile)
 	content, err := os.ReadFile(headPath)
 	if err != nil {
-		return "", errors.NewGitError("head", headPath, err)
+		return "", false, "", errors.NewGitError("head", headPath, err)
 	}
 
 	headContent := strings.TrimSpace(string(content))
-	if len(headContent) > refPrefixLength && headContent[:refPrefixLength] == refPrefix {
-		refPath := headContent[refPrefixLength:]
-		refFullPath := filepath.Join(r.GitDir, refPath)
-
-		refContent, err := os.ReadFile(refFullPath)
+	if strings.HasPrefix(headContent, refPrefix) {
+		ref := strings.TrimSpace(headContent[refPrefixLength:])
+		headHash, err := r.ReadRef(ref)
+		if stderrors.Is(err, errors.ErrReferenceNotFound) {
+			return ref, false, "", nil
+		}
 		if err != nil {
-			if os.IsNotExist(err) {
-				return "", nil
-			}
-			return "", errors.NewGitError("head", refFullPath, err)
+			return ref, false, "", err
 		}
-
-		return strings.TrimSpace(string(refContent)), nil
+		return ref, false, headHash, nil
 	}
 
-	return strings.TrimSpace(headContent), nil
+	if !hash.ValidateHash(headContent) {
+		return "", false, "", errors.NewGitError("head", headPath, errors.ErrInvalidReference)
+	}
+	return "", true, headContent, nil
 }
 
 func (r *Repository) UpdateRef(refName, hash string) error {
+	return r.UpdateRefWithMessage(refName, hash, "")
+}
+
+// UpdateRefWithMessage points refName at hash and appends a reflog entry for
+// the ref, and for HEAD as well when HEAD is a symbolic ref to refName
+func (r *Repository) UpdateRefWithMessage(refName, hash, message string) error {
+	return r.updateRef(refName, hash, message, nil)
+}
+
+// UpdateRefCAS points refName at newHash only if it still points at oldHash,
+// an empty oldHash meaning the ref must not exist yet. A ref that changed
+// fails with ErrReferenceChanged.
+func (r *Repository) UpdateRefCAS(refName, oldHash, newHash string) error {
+	return r.updateRef(refName, newHash, "", &oldHash)
+}
+
+// updateRef writes refName under its lock. A non-nil expected is compared
+// with the ref's value once the lock is held.
+func (r *Repository) updateRef(refName, hash, message string, expected *string) error {
+	if err := CheckRefName(refName); err != nil {
+		return err
+	}
+
 	refPath := filepath.Join(r.GitDir, refName)
-	refDir := filepath.Dir(refPath)
+	if err := os.MkdirAll(filepath.Dir(refPath), defaultDirMode); err != nil {
+		return errors.NewGitError("update-ref", refName, err)
+	}
 
-	if err := os.MkdirAll(refDir, defaultDirMode); err != nil {
+	lock, err := lockFile(refPath)
+	if err != nil {
 		return errors.NewGitError("update-ref", refName, err)
 	}
 
-	content := hash + "\n"
-	return os.WriteFile(refPath, []byte(content), defaultFileMode)
+	// an unreadable ref is simply replaced, unless its value is to be checked
+	oldHash, err := r.ReadRef(refName)
+	if err != nil && expected != nil && !stderrors.Is(err, errors.ErrReferenceNotFound) {
+		lock.release()
+		return err
+	}
+	if expected != nil && oldHash != *expected {
+		lock.release()
+		return errors.NewGitError("update-ref", refName,
+			fmt.Errorf("%w: expected %q, found %q", errors.ErrReferenceChanged, *expected, oldHash))
+	}
+
+	if err := lock.commit([]byte(hash + "\n")); err != nil {
+		return errors.NewGitError("update-ref", refName, err)
+	}
+
+	if r.shouldLogRef(refName) {
+		if err := r.appendReflog(refName, oldHash, hash, message); err != nil {
+			return err
+		}
+	}
+
+	if refName != headFile && r.headTarget() == refName {
+		if err := r.appendReflog(headFile, oldHash, hash, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteRef removes refName, loose and packed, along with its reflog, only if
+// it still points at oldHash. A ref that changed fails with ErrReferenceChanged.
+func (r *Repository) DeleteRef(refName, oldHash string) error {
+	if err := CheckRefName(refName); err != nil {
+		return err
+	}
+
+	refPath := filepath.Join(r.GitDir, refName)
+	lock, err := lockFile(refPath)
+	if err != nil {
+		return errors.NewGitError("delete-ref", refName, err)
+	}
+	defer lock.release()
+
+	current, err := r.ReadRef(refName)
+	if err != nil {
+		return err
+	}
+	if current != oldHash {
+		return errors.NewGitError("delete-ref", refName,
+			fmt.Errorf("%w: expected %q, found %q", errors.ErrReferenceChanged, oldHash, current))
+	}
+
+	if err := os.Remove(refPath); err != nil && !os.IsNotExist(err) {
+		return errors.NewGitError("delete-ref", refName, err)
+	}
+	if err := r.removePackedRef(refName); err != nil {
+		return errors.NewGitError("delete-ref", packedRefsFile, err)
+	}
+	if err := os.Remove(filepath.Join(r.GitDir, logsDir, refName)); err != nil && !os.IsNotExist(err) {
+		return errors.NewGitError("delete-ref", refName, err)
+	}
+	return nil
+}
+
+// removePackedRef rewrites packed-refs under its lock without refName and
+// the peeled line that follows it
+func (r *Repository) removePackedRef(refName string) error {
+	packedPath := filepath.Join(r.GitDir, packedRefsFile)
+	lock, err := lockFile(packedPath)
+	if err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(packedPath)
+	if err != nil {
+		lock.release()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var kept []string
+	removed, skipPeeled := false, false
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		if skipPeeled && strings.HasPrefix(line, "^") {
+			continue
+		}
+		skipPeeled = false
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) == 2 && parts[1] == refName {
+			removed, skipPeeled = true, true
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	if !removed {
+		lock.release()
+		return nil
+	}
+	return lock.commit([]byte(strings.Join(kept, "\n") + "\n"))
+}
+
+// SetHead points HEAD at target, which is either a ref name under refs/
+// (symbolic HEAD) or a commit hash (detached HEAD), and logs the move
+func (r *Repository) SetHead(target, message string) error {
+	oldHash, err := r.GetHead()
+	if err != nil {
+		return err
+	}
+
+	var content, newHash string
+	if strings.HasPrefix(target, refsDir+"/") {
+		content = refPrefix + target + "\n"
+		newHash, _ = r.ReadRef(target)
+	} else {
+		if !hash.ValidateHash(target) {
+			return errors.NewGitError("set-head", target, errors.ErrInvalidReference)
+		}
+		content = target + "\n"
+		newHash = target
+	}
+
+	headPath := filepath.Join(r.GitDir, headFile)
+	lock, err := lockFile(headPath)
+	if err != nil {
+		return errors.NewGitError("set-head", headPath, err)
+	}
+	if err := lock.commit([]byte(content)); err != nil {
+		return errors.NewGitError("set-head", headPath, err)
+	}
+
+	if oldHash == "" && newHash == "" {
+		return nil
+	}
+
+	return r.appendReflog(headFile, oldHash, newHash, message)
+}
+
+// headTarget returns the ref HEAD points to, or "" when HEAD is detached
+func (r *Repository) headTarget() string {
+	content, err := os.ReadFile(filepath.Join(r.GitDir, headFile))
+	if err != nil {
+		return ""
+	}
+
+	headContent := strings.TrimSpace(string(content))
+	if strings.HasPrefix(headContent, refPrefix) {
+		return strings.TrimSpace(headContent[refPrefixLength:])
+	}
+	return ""
+}
+
+// shouldLogRef mirrors core.logAllRefUpdates=true: branches, remote-tracking
+// refs, HEAD and stash are logged, as is any ref whose log already exists
+func (r *Repository) shouldLogRef(refName string) bool {
+	if refName == headFile || refName == stashRef ||
+		strings.HasPrefix(refName, refsDir+"/"+headsDir+"/") ||
+		strings.HasPrefix(refName, refsDir+"/"+remotesDir+"/") {
+		return true
+	}
+
+	_, err := os.Stat(filepath.Join(r.GitDir, logsDir, refName))
+	return err == nil
+}
+
+func (r *Repository) appendReflog(refName, oldHash, newHash, message string) error {
+	if oldHash == "" {
+		oldHash = nullHash
+	}
+	if newHash == "" {
+		newHash = nullHash
+	}
+
+	// entries are signed by the committer, as configured for commits
+	id, err := ident.Resolve(r.GitDir)
+	if err != nil {
+		return errors.NewGitError("reflog", refName, err)
+	}
+
+	logPath := filepath.Join(r.GitDir, logsDir, refName)
+	if err := os.MkdirAll(filepath.Dir(logPath), defaultDirMode); err != nil {
+		return errors.NewGitError("reflog", refName, err)
+	}
+
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, defaultFileMode)
+	if err != nil {
+		return errors.NewGitError("reflog", refName, err)
+	}
+	defer file.Close()
+
+	// reflog messages are single-line
+	message = strings.TrimSpace(strings.ReplaceAll(message, "\n", " "))
+	line := fmt.Sprintf("%s %s %s\t%s\n", oldHash, newHash, id.Committer.Signature(time.Now()).String(), message)
+	if _, err := file.WriteString(line); err != nil {
+		return errors.NewGitError("reflog", refName, err)
+	}
+
+	return nil
+}
+
+// ReadRef returns the hash a ref points to, following symbolic refs and
+// falling back to packed-refs when no loose ref file exists
+func (r *Repository) ReadRef(refName string) (string, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		content, err := os.ReadFile(filepath.Join(r.GitDir, refName))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return "", errors.NewGitError("read-ref", refName, err)
+			}
+			return r.readPackedRef(refName)
+		}
+
+		value := strings.TrimSpace(string(content))
+		if strings.HasPrefix(value, refPrefix) {
+			refName = strings.TrimSpace(value[refPrefixLength:])
+			continue
+		}
+
+		if !hash.ValidateHash(value) {
+			return "", errors.NewGitError("read-ref", refName, errors.ErrInvalidReference)
+		}
+		return value, nil
+	}
+
+	return "", errors.NewGitError("read-ref", refName, fmt.Errorf("symbolic ref nesting too deep"))
+}
+
+// ListRefs returns every loose and packed ref under refs/ mapped to the hash it
+// points at. Loose refs take precedence over packed entries of the same name.
+func (r *Repository) ListRefs() (map[string]string, error) {
+	refs := make(map[string]string)
+
+	content, err := os.ReadFile(filepath.Join(r.GitDir, packedRefsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.NewGitError("list-refs", packedRefsFile, err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) == 2 && hash.ValidateHash(parts[0]) {
+			refs[parts[1]] = parts[0]
+		}
+	}
+
+	refsPath := filepath.Join(r.GitDir, refsDir)
+	err = filepath.WalkDir(refsPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// a lock holds a ref's next value, not a ref of its own
+		if d.IsDir() || strings.HasSuffix(d.Name(), lockSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.GitDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		value, err := r.ReadRef(name)
+		if err != nil {
+			// symbolic refs to unborn branches have nothing to list
+			return nil
+		}
+		refs[name] = value
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.NewGitError("list-refs", refsPath, err)
+	}
+
+	return refs, nil
+}
+
+func (r *Repository) readPackedRef(refName string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(r.GitDir, packedRefsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.NewGitError("read-ref", refName, errors.ErrReferenceNotFound)
+		}
+		return "", errors.NewGitError("read-ref", packedRefsFile, err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) == 2 && parts[1] == refName {
+			return parts[0], nil
+		}
+	}
+
+	return "", errors.NewGitError("read-ref", refName, errors.ErrReferenceNotFound)
+}
+
+// ResolveRef resolves a full hash, HEAD, or a full or short ref name to a hash.
+// Short names are tried in git's order: refs/, tags, heads, remotes.
+func (r *Repository) ResolveRef(name string) (string, error) {
+	if name == "" {
+		return "", errors.NewGitError("resolve-ref", name, errors.ErrInvalidReference)
+	}
+
+	if len(name) == hashLength && hash.ValidateHash(name) {
+		return name, nil
+	}
+
+	if name == headFile {
+		head, err := r.GetHead()
+		if err != nil {
+			return "", err
+		}
+		if head == "" {
+			return "", errors.NewGitError("resolve-ref", name, errors.ErrReferenceNotFound)
+		}
+		return head, nil
+	}
+
+	candidates := []string{
+		name,
+		filepath.ToSlash(filepath.Join(refsDir, name)),
+		filepath.ToSlash(filepath.Join(refsDir, tagsDir, name)),
+		filepath.ToSlash(filepath.Join(refsDir, headsDir, name)),
+		filepath.ToSlash(filepath.Join(refsDir, remotesDir, name)),
+		filepath.ToSlash(filepath.Join(refsDir, remotesDir, name, headFile)),
+	}
+
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate, refsDir+"/") {
+			continue
+		}
+		if h, err := r.ReadRef(candidate); err == nil {
+			return h, nil
+		}
+	}
+
+	return "", errors.NewGitError("resolve-ref", name, errors.ErrReferenceNotFound)
 }
 
 func (r *Repository) GetCurrentBranch() (string, error) {
@@ -532,53 +1419,49 @@ func (r *Repository) CheckoutTreeWithIndex(tree *objects.Tree, idx *index.Index,
 		relativePath := filepath.Join(prefix, entry.Name)
 		gitPath := filepath.ToSlash(relativePath)
 
-		switch entry.Mode {
-		case objects.FileModeTree:
+		switch {
+		case entry.IsDir():
 			if err := os.MkdirAll(fullPath, defaultDirMode); err != nil {
 				return nil, fmt.Errorf("failed to create directory %s: %w", fullPath, err)
 			}
 
-			subTreeObj, err := r.LoadObject(entry.Hash)
+			subTree, err := r.LoadTree(entry.Hash)
 			if err != nil {
 				return nil, fmt.Errorf("failed to load subtree %s for directory %s: %w", entry.Hash, gitPath, err)
 			}
 
-			subTree, ok := subTreeObj.(*objects.Tree)
-			if !ok {
-				return nil, fmt.Errorf("subtree object is not a tree")
-			}
-
 			subUpdated, err := r.CheckoutTreeWithIndex(subTree, idx, relativePath)
 			if err != nil {
 				return nil, err
 			}
 			updatedFiles = append(updatedFiles, subUpdated...)
 
-		case objects.FileModeBlob, objects.FileModeExecutable:
-			blobObj, err := r.LoadObject(entry.Hash)
+		case entry.IsSubmodule():
+			// the submodule is cloned separately; its directory holds the
+			// place and the index records the commit it should be at
+			if err := os.MkdirAll(fullPath, defaultDirMode); err != nil {
+				return nil, fmt.Errorf("failed to create submodule directory %s: %w", fullPath, err)
+			}
+
+			stat, err := os.Stat(fullPath)
 			if err != nil {
-				return nil, fmt.Errorf("failed to load blob %s for file %s: %w", entry.Hash, gitPath, err)
+				return nil, fmt.Errorf("failed to stat submodule %s: %w", fullPath, err)
 			}
 
-			blob, ok := blobObj.(*objects.Blob)
-			if !ok {
-				return nil, fmt.Errorf("blob object is not a blob")
+			if err := idx.AddWithFileInfo(gitPath, entry.Hash, uint32(entry.Mode), stat); err != nil {
+				return nil, fmt.Errorf("failed to add %s to index: %w", gitPath, err)
 			}
 
+		case entry.Mode == objects.FileModeBlob || entry.Mode == objects.FileModeExecutable || entry.Mode == objects.FileModeSymlink:
 			if err := os.MkdirAll(filepath.Dir(fullPath), defaultDirMode); err != nil {
 				return nil, fmt.Errorf("failed to create directory for %s: %w", fullPath, err)
 			}
 
-			mode := os.FileMode(defaultFileMode)
-			if entry.Mode == objects.FileModeExecutable {
-				mode = os.FileMode(executableFileMode)
+			if err := r.WriteEntryFile(entry.Hash, fullPath, entry.Mode); err != nil {
+				return nil, fmt.Errorf("failed to write blob %s to %s: %w", entry.Hash, gitPath, err)
 			}
 
-			if err := os.WriteFile(fullPath, blob.Content(), mode); err != nil {
-				return nil, fmt.Errorf("failed to write file %s: %w", fullPath, err)
-			}
-
-			stat, err := os.Stat(fullPath)
+			stat, err := os.Lstat(fullPath)
 			if err != nil {
 				return nil, fmt.Errorf("failed to stat file %s: %w", fullPath, err)
 			}
@@ -1,11 +1,23 @@
 package repository
 
 import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
@@ -271,6 +283,52 @@ func TestRepository_LoadObject_Errors(t *testing.T) {
 	}
 }
 
+func TestRepository_TypedLoaders(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("content")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "file.txt", Hash: blobHash},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, nil, sig, sig, "message"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+
+	if commit, err := repo.LoadCommit(commitHash); err != nil || commit.Tree() != treeHash {
+		t.Errorf("LoadCommit(commit) = %v, %v", commit, err)
+	}
+	if tree, err := repo.LoadTree(treeHash); err != nil || len(tree.Entries()) != 1 {
+		t.Errorf("LoadTree(tree) = %v, %v", tree, err)
+	}
+	if blob, err := repo.LoadBlob(blobHash); err != nil || string(blob.Content()) != "content" {
+		t.Errorf("LoadBlob(blob) = %v, %v", blob, err)
+	}
+
+	if _, err := repo.LoadCommit(treeHash); !stderrors.Is(err, errors.ErrNotACommit) {
+		t.Errorf("LoadCommit(tree): expected ErrNotACommit, got %v", err)
+	}
+	if _, err := repo.LoadTree(blobHash); !stderrors.Is(err, errors.ErrNotATree) {
+		t.Errorf("LoadTree(blob): expected ErrNotATree, got %v", err)
+	}
+	if _, err := repo.LoadBlob(commitHash); !stderrors.Is(err, errors.ErrNotABlob) {
+		t.Errorf("LoadBlob(commit): expected ErrNotABlob, got %v", err)
+	}
+	if _, err := repo.LoadCommit("a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"); !stderrors.Is(err, errors.ErrObjectNotFound) {
+		t.Errorf("LoadCommit(missing): expected ErrObjectNotFound, got %v", err)
+	}
+}
+
 func TestRepository_StoreObject_DuplicateStorage(t *testing.T) {
 	tempDir := t.TempDir()
 	repo := New(tempDir)
@@ -434,6 +492,355 @@ func TestRepository_GetCurrentBranch_DetachedHead(t *testing.T) {
 	}
 }
 
+func TestRepository_HeadRef(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	ref, detached, head, err := repo.HeadRef()
+	if err != nil {
+		t.Fatalf("HeadRef failed: %v", err)
+	}
+	if ref != "refs/heads/main" || detached || head != "" {
+		t.Errorf("Expected unborn refs/heads/main, got ref %q detached %v hash %q", ref, detached, head)
+	}
+
+	testHash := "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	packed := "# pack-refs with: peeled fully-peeled sorted\n" + testHash + " refs/heads/main\n"
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "packed-refs"), []byte(packed), 0644); err != nil {
+		t.Fatalf("Failed to write packed-refs: %v", err)
+	}
+	ref, detached, head, err = repo.HeadRef()
+	if err != nil {
+		t.Fatalf("HeadRef failed: %v", err)
+	}
+	if ref != "refs/heads/main" || detached || head != testHash {
+		t.Errorf("Expected packed refs/heads/main at %s, got ref %q detached %v hash %q", testHash, ref, detached, head)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "HEAD"), []byte(testHash+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write HEAD file: %v", err)
+	}
+	ref, detached, head, err = repo.HeadRef()
+	if err != nil {
+		t.Fatalf("HeadRef failed: %v", err)
+	}
+	if ref != "" || !detached || head != testHash {
+		t.Errorf("Expected HEAD detached at %s, got ref %q detached %v hash %q", testHash, ref, detached, head)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "HEAD"), []byte("garbage\n"), 0644); err != nil {
+		t.Fatalf("Failed to write HEAD file: %v", err)
+	}
+	if _, _, _, err := repo.HeadRef(); !stderrors.Is(err, errors.ErrInvalidReference) {
+		t.Errorf("Expected ErrInvalidReference for a malformed HEAD, got %v", err)
+	}
+}
+
+func TestRepository_UpdateRefCAS(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	first := "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	second := "b94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	third := "c94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+
+	if err := repo.UpdateRefCAS("refs/heads/main", "", first); err != nil {
+		t.Fatalf("Expected creating a new ref to succeed: %v", err)
+	}
+	if err := repo.UpdateRefCAS("refs/heads/main", "", second); !stderrors.Is(err, errors.ErrReferenceChanged) {
+		t.Errorf("Expected ErrReferenceChanged when creating an existing ref, got %v", err)
+	}
+	if err := repo.UpdateRefCAS("refs/heads/main", third, second); !stderrors.Is(err, errors.ErrReferenceChanged) {
+		t.Errorf("Expected ErrReferenceChanged for a stale old value, got %v", err)
+	}
+
+	value, err := repo.ReadRef("refs/heads/main")
+	if err != nil {
+		t.Fatalf("ReadRef failed: %v", err)
+	}
+	if value != first {
+		t.Errorf("Expected rejected updates to leave %s, got %s", first, value)
+	}
+	if _, err := os.Stat(filepath.Join(repo.GitDir, "refs", "heads", "main.lock")); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock to be released after a rejected update, got %v", err)
+	}
+
+	if err := repo.UpdateRefCAS("refs/heads/main", first, second); err != nil {
+		t.Fatalf("Expected a matching old value to succeed: %v", err)
+	}
+	if value, _ := repo.ReadRef("refs/heads/main"); value != second {
+		t.Errorf("Expected %s after the update, got %s", second, value)
+	}
+}
+
+func TestRepository_ReflogIdentity(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "")
+	}
+
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	config := "[user]\n\tname = Config Name\n\temail = config@example.com\n"
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	commit := "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	if err := repo.UpdateRefWithMessage("refs/heads/main", commit, "commit (initial): first"); err != nil {
+		t.Fatalf("UpdateRefWithMessage failed: %v", err)
+	}
+
+	// the entry is signed with user.name and user.email, as a commit would be
+	content, err := os.ReadFile(filepath.Join(repo.GitDir, "logs", "refs", "heads", "main"))
+	if err != nil {
+		t.Fatalf("Failed to read reflog: %v", err)
+	}
+	prefix := strings.Repeat("0", 40) + " " + commit + " Config Name <config@example.com> "
+	if !strings.HasPrefix(string(content), prefix) {
+		t.Errorf("Expected reflog entry to start with %q, got %q", prefix, content)
+	}
+}
+
+func TestRepository_UpdateRefLocked(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	testHash := "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	if err := repo.UpdateRef("refs/heads/main", testHash); err != nil {
+		t.Fatalf("UpdateRef failed: %v", err)
+	}
+
+	lockPath := filepath.Join(repo.GitDir, "refs", "heads", "main.lock")
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to create lock: %v", err)
+	}
+
+	other := "b94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	if err := repo.UpdateRef("refs/heads/main", other); !stderrors.Is(err, errors.ErrReferenceLocked) {
+		t.Errorf("Expected ErrReferenceLocked, got %v", err)
+	}
+	if value, _ := repo.ReadRef("refs/heads/main"); value != testHash {
+		t.Errorf("Expected a locked ref to keep %s, got %s", testHash, value)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Errorf("Expected another writer's lock to be left alone: %v", err)
+	}
+
+	refs, err := repo.ListRefs()
+	if err != nil {
+		t.Fatalf("ListRefs failed: %v", err)
+	}
+	if _, ok := refs["refs/heads/main.lock"]; ok {
+		t.Error("Expected lock files not to be listed as refs")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	t.Setenv("GIT_DIR", "")
+	t.Setenv("GIT_WORK_TREE", "")
+
+	workDir := t.TempDir()
+	if err := New(workDir).Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	subDir := filepath.Join(workDir, "a", "b")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	repo, err := Discover(subDir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if repo.WorkDir != workDir {
+		t.Errorf("Expected WorkDir %q, got %q", workDir, repo.WorkDir)
+	}
+	if repo.GitDir != filepath.Join(workDir, ".git") {
+		t.Errorf("Expected GitDir %q, got %q", filepath.Join(workDir, ".git"), repo.GitDir)
+	}
+
+	if _, err := Discover(t.TempDir()); !stderrors.Is(err, errors.ErrNotGitRepository) {
+		t.Errorf("Expected ErrNotGitRepository outside a repository, got %v", err)
+	}
+}
+
+func TestDiscover_GitFile(t *testing.T) {
+	t.Setenv("GIT_DIR", "")
+	t.Setenv("GIT_WORK_TREE", "")
+
+	root := t.TempDir()
+	gitDir := filepath.Join(root, "modules", "sub")
+	if err := New(filepath.Join(root, "modules")).Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.Rename(filepath.Join(root, "modules", ".git"), gitDir); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	workDir := filepath.Join(root, "sub")
+	if err := os.MkdirAll(filepath.Join(workDir, "dir"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, ".git"), []byte("gitdir: ../modules/sub\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	repo, err := Discover(filepath.Join(workDir, "dir"))
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if repo.WorkDir != workDir {
+		t.Errorf("Expected WorkDir %q, got %q", workDir, repo.WorkDir)
+	}
+	if repo.GitDir != gitDir {
+		t.Errorf("Expected GitDir %q, got %q", gitDir, repo.GitDir)
+	}
+
+	if err := os.WriteFile(filepath.Join(workDir, ".git"), []byte("gitdir: ../missing\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := Discover(workDir); !stderrors.Is(err, errors.ErrNotGitRepository) {
+		t.Errorf("Expected ErrNotGitRepository for a dangling gitfile, got %v", err)
+	}
+}
+
+func TestDiscover_Environment(t *testing.T) {
+	repoDir := t.TempDir()
+	if err := New(repoDir).Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	gitDir := filepath.Join(repoDir, ".git")
+	startDir := t.TempDir()
+
+	t.Setenv("GIT_DIR", gitDir)
+	t.Setenv("GIT_WORK_TREE", "")
+	repo, err := Discover(startDir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if repo.GitDir != gitDir || repo.WorkDir != startDir {
+		t.Errorf("Expected %q and %q, got %q and %q", gitDir, startDir, repo.GitDir, repo.WorkDir)
+	}
+
+	workTree := t.TempDir()
+	t.Setenv("GIT_WORK_TREE", workTree)
+	repo, err = Discover(startDir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if repo.GitDir != gitDir || repo.WorkDir != workTree {
+		t.Errorf("Expected %q and %q, got %q and %q", gitDir, workTree, repo.GitDir, repo.WorkDir)
+	}
+
+	t.Setenv("GIT_DIR", filepath.Join(startDir, "missing"))
+	if _, err := Discover(startDir); !stderrors.Is(err, errors.ErrNotGitRepository) {
+		t.Errorf("Expected ErrNotGitRepository for a missing GIT_DIR, got %v", err)
+	}
+}
+
+func TestDiscover_Bare(t *testing.T) {
+	t.Setenv("GIT_DIR", "")
+	t.Setenv("GIT_WORK_TREE", "")
+
+	dir := t.TempDir()
+	bare := NewBare(dir)
+	if bare.Exists() {
+		t.Error("Expected an empty directory not to be a bare repository")
+	}
+	if err := bare.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		t.Errorf("Expected HEAD in the repository directory: %v", err)
+	}
+	if cfg, err := os.ReadFile(filepath.Join(dir, "config")); err != nil || !strings.Contains(string(cfg), "bare = true") {
+		t.Errorf("Expected core.bare = true in config, got %q (%v)", cfg, err)
+	}
+
+	repo, err := Discover(filepath.Join(dir, "refs", "heads"))
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if !repo.IsBare() || repo.GitDir != dir {
+		t.Errorf("Expected bare repository at %q, got GitDir %q WorkDir %q", dir, repo.GitDir, repo.WorkDir)
+	}
+
+	// found through GIT_DIR, core.bare still rules out a work tree
+	t.Setenv("GIT_DIR", dir)
+	repo, err = Discover(t.TempDir())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if !repo.IsBare() || repo.GitDir != dir {
+		t.Errorf("Expected bare repository at %q through GIT_DIR, got GitDir %q WorkDir %q", dir, repo.GitDir, repo.WorkDir)
+	}
+}
+
+func TestDiscover_LinkedWorktree(t *testing.T) {
+	t.Setenv("GIT_DIR", "")
+	t.Setenv("GIT_WORK_TREE", "")
+
+	workDir := t.TempDir()
+	if err := New(workDir).Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, ".git", "commondir"), []byte("../..\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := Discover(workDir); err == nil || !strings.Contains(err.Error(), "linked worktrees") {
+		t.Errorf("Expected linked worktrees to be refused, got %v", err)
+	}
+}
+
+func TestRepository_CheckoutTreeWithIndex_Submodule(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("hello\n")))
+	if err != nil {
+		t.Fatalf("StoreObject failed: %v", err)
+	}
+	// the gitlink's commit is not in this repository
+	gitlink := "2222222222222222222222222222222222222222"
+	tree := objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "README", Hash: blobHash},
+		{Mode: objects.FileModeCommit, Name: "vendor", Hash: gitlink},
+	})
+
+	idx := index.New(repo.GitDir)
+	updated, err := repo.CheckoutTreeWithIndex(tree, idx, "")
+	if err != nil {
+		t.Fatalf("CheckoutTreeWithIndex failed: %v", err)
+	}
+	if len(updated) != 1 || updated[0] != "README" {
+		t.Errorf("Expected only README to be written, got %v", updated)
+	}
+
+	info, err := os.Stat(filepath.Join(repo.WorkDir, "vendor"))
+	if err != nil || !info.IsDir() {
+		t.Fatalf("Expected an empty directory for the submodule, got %v", err)
+	}
+	entry, ok := idx.Get("vendor")
+	if !ok {
+		t.Fatal("Expected the submodule in the index")
+	}
+	if entry.Hash != gitlink || objects.FileMode(entry.Mode) != objects.FileModeCommit {
+		t.Errorf("Expected gitlink %s, got %s with mode %o", gitlink, entry.Hash, entry.Mode)
+	}
+}
+
 func TestRepository_ObjectPath(t *testing.T) {
 	tempDir := t.TempDir()
 	repo := New(tempDir)
@@ -446,3 +853,808 @@ func TestRepository_ObjectPath(t *testing.T) {
 		t.Errorf("Expected object path %q, got %q", expectedPath, actualPath)
 	}
 }
+
+func TestRepository_ResolveRef(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := New(tempDir)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	branchHash := "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	tagHash := "b94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	remoteHash := "c94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	packedHash := "d94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+
+	if err := repo.UpdateRef("refs/heads/main", branchHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	if err := repo.UpdateRef("refs/tags/v1", tagHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	if err := repo.UpdateRef("refs/remotes/origin/main", remoteHash); err != nil {
+		t.Fatalf("Failed to update ref: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "refs", "remotes", "origin", "HEAD"), []byte("ref: refs/remotes/origin/main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write symbolic ref: %v", err)
+	}
+	packed := "# pack-refs with: peeled\n" + packedHash + " refs/heads/packed\n"
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "packed-refs"), []byte(packed), 0644); err != nil {
+		t.Fatalf("Failed to write packed-refs: %v", err)
+	}
+
+	tests := map[string]string{
+		"HEAD":            branchHash,
+		"main":            branchHash,
+		"refs/heads/main": branchHash,
+		"heads/main":      branchHash,
+		"v1":              tagHash,
+		"origin/main":     remoteHash,
+		"origin":          remoteHash,
+		"packed":          packedHash,
+		"e94a8fe5ccb19ba61c4c0873d391e987982fbbd3": "e94a8fe5ccb19ba61c4c0873d391e987982fbbd3",
+	}
+
+	for name, expected := range tests {
+		got, err := repo.ResolveRef(name)
+		if err != nil {
+			t.Errorf("ResolveRef(%q) failed: %v", name, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("ResolveRef(%q): expected %s, got %s", name, expected, got)
+		}
+	}
+
+	if _, err := repo.ResolveRef("missing"); err == nil {
+		t.Error("Expected error for unknown ref")
+	}
+}
+
+// rawPackEntry is one pack entry; extra holds the delta base reference that
+// follows the header of OFS_DELTA and REF_DELTA entries, and size, when set,
+// is written to the header instead of the length of data
+type rawPackEntry struct {
+	hash     string
+	packType int
+	extra    func(offsets map[string]uint32, offset uint32) []byte
+	data     []byte
+	size     int
+}
+
+// writeTestPack stores blobs in an undeltified pack with a v2 index and
+// returns their hashes
+func writeTestPack(t testing.TB, repo *Repository, contents ...string) []string {
+	t.Helper()
+
+	var entries []rawPackEntry
+	var hashes []string
+	for _, content := range contents {
+		objHash := hash.ComputeObjectHash(string(objects.ObjectTypeBlob), []byte(content))
+		hashes = append(hashes, objHash)
+		entries = append(entries, rawPackEntry{hash: objHash, packType: 3, data: []byte(content)})
+	}
+
+	writeRawPack(t, repo, entries)
+	return hashes
+}
+
+func writeRawPack(t testing.TB, repo *Repository, entries []rawPackEntry) {
+	t.Helper()
+
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+	binary.Write(&pack, binary.BigEndian, uint32(len(entries)))
+
+	offsets := make(map[string]uint32)
+	for _, entry := range entries {
+		offset := uint32(pack.Len())
+		offsets[entry.hash] = offset
+
+		// type with variable-length size
+		size := len(entry.data)
+		if entry.size != 0 {
+			size = entry.size
+		}
+		header := byte(entry.packType<<4) | byte(size&0x0f)
+		size >>= 4
+		for size > 0 {
+			pack.WriteByte(header | 0x80)
+			header = byte(size & 0x7f)
+			size >>= 7
+		}
+		pack.WriteByte(header)
+
+		if entry.extra != nil {
+			pack.Write(entry.extra(offsets, offset))
+		}
+
+		zw := zlib.NewWriter(&pack)
+		zw.Write(entry.data)
+		zw.Close()
+	}
+	packSum := sha1.Sum(pack.Bytes())
+	pack.Write(packSum[:])
+
+	hashes := make([]string, 0, len(offsets))
+	for h := range offsets {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	var idx bytes.Buffer
+	idx.WriteString(packIdxV2Magic)
+	binary.Write(&idx, binary.BigEndian, uint32(2))
+	for b := 0; b < 256; b++ {
+		count := 0
+		for _, h := range hashes {
+			first, _ := hex.DecodeString(h[:2])
+			if int(first[0]) <= b {
+				count++
+			}
+		}
+		binary.Write(&idx, binary.BigEndian, uint32(count))
+	}
+	for _, h := range hashes {
+		raw, _ := hex.DecodeString(h)
+		idx.Write(raw)
+	}
+	for range hashes {
+		binary.Write(&idx, binary.BigEndian, uint32(0))
+	}
+	for _, h := range hashes {
+		binary.Write(&idx, binary.BigEndian, offsets[h])
+	}
+	idx.Write(packSum[:])
+	idxSum := sha1.Sum(idx.Bytes())
+	idx.Write(idxSum[:])
+
+	packDir := filepath.Join(repo.GitDir, "objects", "pack")
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		t.Fatalf("Failed to create pack dir: %v", err)
+	}
+	name := "pack-" + hex.EncodeToString(packSum[:])
+	if err := os.WriteFile(filepath.Join(packDir, name+".pack"), pack.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write pack: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(packDir, name+".idx"), idx.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write pack index: %v", err)
+	}
+}
+
+func TestRepository_ExpandPrefix(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	looseHash, err := repo.StoreObject(objects.NewBlob([]byte("loose content")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	packedHash := writeTestPack(t, repo, "packed content")[0]
+
+	for _, full := range []string{looseHash, packedHash} {
+		got, err := repo.ExpandPrefix(full[:7])
+		if err != nil {
+			t.Errorf("ExpandPrefix(%q) failed: %v", full[:7], err)
+			continue
+		}
+		if got != full {
+			t.Errorf("ExpandPrefix(%q): expected %s, got %s", full[:7], full, got)
+		}
+	}
+
+	obj, err := repo.LoadObject(packedHash)
+	if err != nil {
+		t.Fatalf("Failed to load packed object: %v", err)
+	}
+	if blob, ok := obj.(*objects.Blob); !ok || string(blob.Content()) != "packed content" {
+		t.Errorf("Unexpected packed object %+v", obj)
+	}
+
+	for _, prefix := range []string{"abc", "zzzzzz", "0000000"} {
+		if _, err := repo.ExpandPrefix(prefix); err == nil {
+			t.Errorf("ExpandPrefix(%q): expected error", prefix)
+		}
+	}
+}
+
+func TestRepository_ExpandPrefix_Ambiguous(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// find two blobs whose hashes share a four character prefix
+	seen := make(map[string]string)
+	var looseContent, packedContent string
+	for i := 0; looseContent == ""; i++ {
+		content := fmt.Sprintf("blob %d", i)
+		prefix := hash.ComputeObjectHash(string(objects.ObjectTypeBlob), []byte(content))[:4]
+		if other, ok := seen[prefix]; ok {
+			looseContent, packedContent = other, content
+		}
+		seen[prefix] = content
+	}
+
+	looseHash, err := repo.StoreObject(objects.NewBlob([]byte(looseContent)))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	packedHash := writeTestPack(t, repo, packedContent)[0]
+
+	_, err = repo.ExpandPrefix(looseHash[:4])
+	if !stderrors.Is(err, errors.ErrAmbiguousHash) {
+		t.Fatalf("Expected ambiguous hash error, got %v", err)
+	}
+
+	for _, full := range []string{looseHash, packedHash} {
+		got, err := repo.ExpandPrefix(full)
+		if err != nil || got != full {
+			t.Errorf("ExpandPrefix(%q) = %q, %v", full, got, err)
+		}
+	}
+}
+
+func TestRepository_ForEachObject(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("loose blob")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "file.txt", Hash: blobHash},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(0, 0).UTC()}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, nil, sig, sig, "initial"))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+
+	baseHash := hash.ComputeObjectHash("blob", []byte("packed base"))
+	ofsHash := "1111111111111111111111111111111111111111"
+	refHash := "2222222222222222222222222222222222222222"
+	writeRawPack(t, repo, []rawPackEntry{
+		{hash: baseHash, packType: 3, data: []byte("packed base")},
+		{hash: ofsHash, packType: packOfsDelta, data: []byte("delta"), extra: func(offsets map[string]uint32, offset uint32) []byte {
+			// distances below 128 fit in a single byte
+			return []byte{byte(offset - offsets[baseHash])}
+		}},
+		{hash: refHash, packType: packRefDelta, data: []byte("delta"), extra: func(map[string]uint32, uint32) []byte {
+			raw, _ := hex.DecodeString(ofsHash)
+			return raw
+		}},
+		// also stored loose; must only be reported once
+		{hash: blobHash, packType: 3, data: []byte("loose blob")},
+	})
+
+	got := make(map[string]objects.ObjectType)
+	err = repo.ForEachObject(func(h string, typ objects.ObjectType) error {
+		if _, dup := got[h]; dup {
+			t.Errorf("Object %s reported twice", h)
+		}
+		got[h] = typ
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachObject failed: %v", err)
+	}
+
+	expected := map[string]objects.ObjectType{
+		blobHash:   objects.ObjectTypeBlob,
+		treeHash:   objects.ObjectTypeTree,
+		commitHash: objects.ObjectTypeCommit,
+		baseHash:   objects.ObjectTypeBlob,
+		ofsHash:    objects.ObjectTypeBlob,
+		refHash:    objects.ObjectTypeBlob,
+	}
+	if len(got) != len(expected) {
+		t.Errorf("Expected %d objects, got %d", len(expected), len(got))
+	}
+	for h, typ := range expected {
+		if got[h] != typ {
+			t.Errorf("Object %s: expected type %s, got %q", h, typ, got[h])
+		}
+	}
+
+	stop := stderrors.New("stop")
+	calls := 0
+	err = repo.ForEachObject(func(string, objects.ObjectType) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected callback error to stop iteration, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRepository_WithCache(t *testing.T) {
+	repo := New(t.TempDir()).WithCache(16)
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	small, err := repo.StoreObject(objects.NewBlob([]byte("0123456789")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+	other, err := repo.StoreObject(objects.NewBlob([]byte("abcdefghij")))
+	if err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+
+	first, err := repo.LoadObject(small)
+	if err != nil {
+		t.Fatalf("Failed to load object: %v", err)
+	}
+
+	// served from the cache once the loose file is gone
+	if err := os.Remove(repo.objectPath(small)); err != nil {
+		t.Fatalf("Failed to remove object: %v", err)
+	}
+	second, err := repo.LoadObject(small)
+	if err != nil {
+		t.Fatalf("Expected cached object, got error: %v", err)
+	}
+	if first != second {
+		t.Error("Expected the same parsed object from the cache")
+	}
+
+	// loading another 10 byte object exceeds the 16 byte budget and evicts it
+	if _, err := repo.LoadObject(other); err != nil {
+		t.Fatalf("Failed to load object: %v", err)
+	}
+	if _, err := repo.LoadObject(small); err == nil {
+		t.Error("Expected evicted object to be reloaded from disk")
+	}
+
+	if err := repo.RemoveLooseObject(other); err != nil {
+		t.Fatalf("Failed to remove object: %v", err)
+	}
+	if _, err := repo.LoadObject(other); err == nil {
+		t.Error("Expected removed object to be evicted from the cache")
+	}
+}
+
+func benchmarkHistoryWalk(b *testing.B, cacheBytes int64) {
+	repo := New(b.TempDir())
+	if err := repo.Init(); err != nil {
+		b.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	var entries []objects.TreeEntry
+	for i := 0; i < 50; i++ {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(fmt.Sprintf("file %d\n", i))))
+		if err != nil {
+			b.Fatalf("Failed to store blob: %v", err)
+		}
+		entries = append(entries, objects.TreeEntry{Mode: objects.FileModeBlob, Name: fmt.Sprintf("file%02d.txt", i), Hash: blobHash})
+	}
+	treeHash, err := repo.StoreObject(objects.NewTree(entries))
+	if err != nil {
+		b.Fatalf("Failed to store tree: %v", err)
+	}
+
+	sig := &objects.Signature{Name: "Bench", Email: "bench@example.com", When: time.Unix(0, 0).UTC()}
+	var head string
+	for i := 0; i < 100; i++ {
+		var parents []string
+		if head != "" {
+			parents = []string{head}
+		}
+		head, err = repo.StoreObject(objects.NewCommit(treeHash, parents, sig, sig, fmt.Sprintf("commit %d", i)))
+		if err != nil {
+			b.Fatalf("Failed to store commit: %v", err)
+		}
+	}
+
+	repo.WithCache(cacheBytes)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// walk every commit and its tree, as ancestor walks and blame do
+		for current := head; current != ""; {
+			obj, err := repo.LoadObject(current)
+			if err != nil {
+				b.Fatalf("Failed to load commit: %v", err)
+			}
+			commit := obj.(*objects.Commit)
+			if _, err := repo.LoadObject(commit.Tree()); err != nil {
+				b.Fatalf("Failed to load tree: %v", err)
+			}
+
+			current = ""
+			if parents := commit.Parents(); len(parents) > 0 {
+				current = parents[0]
+			}
+		}
+	}
+}
+
+func BenchmarkHistoryWalk_NoCache(b *testing.B) { benchmarkHistoryWalk(b, 0) }
+func BenchmarkHistoryWalk_Cache(b *testing.B)   { benchmarkHistoryWalk(b, 8<<20) }
+
+func TestRepository_MemoryStore(t *testing.T) {
+	// the work dir is never created; objects only live in memory
+	repo := New(filepath.Join(t.TempDir(), "missing")).WithStore(NewMemoryStore())
+
+	blob := objects.NewBlob([]byte("in memory"))
+	blobHash, err := repo.StoreObject(blob)
+	if err != nil {
+		t.Fatalf("StoreObject failed: %v", err)
+	}
+
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+	tree := objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "a.txt", Hash: blobHash}})
+	treeHash, err := repo.StoreObject(tree)
+	if err != nil {
+		t.Fatalf("StoreObject failed: %v", err)
+	}
+	commitHash, err := repo.StoreObject(objects.NewCommit(treeHash, nil, sig, sig, "memory"))
+	if err != nil {
+		t.Fatalf("StoreObject failed: %v", err)
+	}
+
+	fileRepo := New(t.TempDir())
+	if err := fileRepo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	if fileHash, err := fileRepo.StoreObject(objects.NewBlob([]byte("in memory"))); err != nil || fileHash != blobHash {
+		t.Errorf("Expected the same hash from both stores, got %s and %s (%v)", blobHash, fileHash, err)
+	}
+
+	obj, err := repo.LoadObject(commitHash)
+	if err != nil {
+		t.Fatalf("LoadObject failed: %v", err)
+	}
+	if commit, ok := obj.(*objects.Commit); !ok || commit.Tree() != treeHash {
+		t.Errorf("Expected commit with tree %s, got %#v", treeHash, obj)
+	}
+
+	typ, content, err := repo.ReadObjectData(blobHash)
+	if err != nil || typ != objects.ObjectTypeBlob || string(content) != "in memory" {
+		t.Errorf("Unexpected ReadObjectData result %s %q (%v)", typ, content, err)
+	}
+
+	if !repo.HasObject(treeHash) || repo.HasObject(strings.Repeat("0", 40)) {
+		t.Error("HasObject reported the wrong presence")
+	}
+
+	if _, err := repo.LoadObject(strings.Repeat("0", 40)); err != errors.ErrObjectNotFound {
+		t.Errorf("Expected ErrObjectNotFound, got %v", err)
+	}
+
+	var visited []string
+	if err := repo.ForEachObject(func(hash string, typ objects.ObjectType) error {
+		visited = append(visited, hash)
+		return nil
+	}); err != nil {
+		t.Fatalf("ForEachObject failed: %v", err)
+	}
+	if len(visited) != 3 || !sort.StringsAreSorted(visited) {
+		t.Errorf("Expected 3 objects in hash order, got %v", visited)
+	}
+}
+
+// corruptingStore flips the last byte of everything written through it
+type corruptingStore struct {
+	*MemoryStore
+}
+
+func (s corruptingStore) Put(hash string, typ objects.ObjectType, content []byte) error {
+	corrupted := append([]byte(nil), content...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	return s.MemoryStore.Put(hash, typ, corrupted)
+}
+
+func TestRepository_VerifyOnWrite(t *testing.T) {
+	repo := New(t.TempDir()).WithStore(corruptingStore{NewMemoryStore()})
+
+	if _, err := repo.StoreObject(objects.NewBlob([]byte("content"))); err != nil {
+		t.Fatalf("Expected unverified write to succeed, got %v", err)
+	}
+
+	repo.WithVerifyOnWrite(true)
+	if _, err := repo.StoreObject(objects.NewBlob([]byte("other content"))); !stderrors.Is(err, errors.ErrHashMismatch) {
+		t.Errorf("Expected ErrHashMismatch, got %v", err)
+	}
+	if _, err := repo.StoreRawObject(objects.ObjectTypeBlob, []byte("raw content")); !stderrors.Is(err, errors.ErrHashMismatch) {
+		t.Errorf("Expected ErrHashMismatch from StoreRawObject, got %v", err)
+	}
+
+	clean := New(t.TempDir()).WithVerifyOnWrite(true)
+	if err := clean.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	blobHash, err := clean.StoreObject(objects.NewBlob([]byte("content")))
+	if err != nil {
+		t.Fatalf("Expected verified write to succeed, got %v", err)
+	}
+	if err := clean.VerifyObject(blobHash); err != nil {
+		t.Errorf("VerifyObject failed: %v", err)
+	}
+}
+
+func TestRepository_OpenBlob(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	content := bytes.Repeat([]byte("streamed line\n"), 1000)
+	blobHash, err := repo.StoreObject(objects.NewBlob(content))
+	if err != nil {
+		t.Fatalf("StoreObject failed: %v", err)
+	}
+
+	reader, size, err := repo.OpenBlob(blobHash)
+	if err != nil {
+		t.Fatalf("OpenBlob failed: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || size != int64(len(content)) || !bytes.Equal(got, content) {
+		t.Errorf("OpenBlob returned size %d and %d bytes (%v), want %d", size, len(got), err, len(content))
+	}
+	if size, err := repo.ObjectSize(blobHash); err != nil || size != int64(len(content)) {
+		t.Errorf("ObjectSize returned %d (%v), want %d", size, err, len(content))
+	}
+
+	treeHash, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "a", Hash: blobHash}}))
+	if err != nil {
+		t.Fatalf("StoreObject failed: %v", err)
+	}
+	if _, _, err := repo.OpenBlob(treeHash); !stderrors.Is(err, errors.ErrNotABlob) {
+		t.Errorf("Expected ErrNotABlob, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(path, []byte("stale content that is longer than nothing"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := repo.WriteBlobFile(blobHash, path, 0644); err != nil {
+		t.Fatalf("WriteBlobFile failed: %v", err)
+	}
+	if written, err := os.ReadFile(path); err != nil || !bytes.Equal(written, content) {
+		t.Errorf("WriteBlobFile wrote %d bytes (%v), want %d", len(written), err, len(content))
+	}
+
+	memRepo := New(t.TempDir()).WithStore(NewMemoryStore())
+	memHash, err := memRepo.StoreObject(objects.NewBlob([]byte("in memory")))
+	if err != nil {
+		t.Fatalf("StoreObject failed: %v", err)
+	}
+	reader, size, err = memRepo.OpenBlob(memHash)
+	if err != nil {
+		t.Fatalf("OpenBlob failed: %v", err)
+	}
+	got, _ = io.ReadAll(reader)
+	reader.Close()
+	if size != 9 || string(got) != "in memory" {
+		t.Errorf("OpenBlob from memory returned %d %q", size, got)
+	}
+}
+
+func TestRepository_WriteBlobFileAutoCRLF(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte("[core]\n\tautocrlf = true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	blobHash, err := repo.StoreObject(objects.NewBlob([]byte("one\ntwo\n")))
+	if err != nil {
+		t.Fatalf("StoreObject failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	if err := repo.WriteBlobFile(blobHash, path, 0644); err != nil {
+		t.Fatalf("WriteBlobFile failed: %v", err)
+	}
+	if written, _ := os.ReadFile(path); string(written) != "one\r\ntwo\r\n" {
+		t.Errorf("Expected CRLF line endings, got %q", written)
+	}
+}
+
+// appendDelta returns a delta that rebuilds base with its last byte replaced
+// by suffix; sizes must stay below 128
+func appendDelta(base, suffix string) []byte {
+	result := len(base) - 1 + len(suffix)
+	d := []byte{byte(len(base)), byte(result), 0x80 | 0x10, byte(len(base) - 1), byte(len(suffix))}
+	return append(d, suffix...)
+}
+
+func TestRepository_ReadDeltaObjects(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	blobHash := func(content string) string {
+		return hash.ComputeObjectHash(string(objects.ObjectTypeBlob), []byte(content))
+	}
+	base, ofs, ref := "base content\n", "base content!\n", "base content!!\n"
+	baseHash, ofsHash, refHash := blobHash(base), blobHash(ofs), blobHash(ref)
+
+	writeRawPack(t, repo, []rawPackEntry{
+		{hash: baseHash, packType: 3, data: []byte(base)},
+		{hash: ofsHash, packType: packOfsDelta, data: appendDelta(base, "!\n"), extra: func(offsets map[string]uint32, offset uint32) []byte {
+			return []byte{byte(offset - offsets[baseHash])}
+		}},
+		{hash: refHash, packType: packRefDelta, data: appendDelta(ofs, "!\n"), extra: func(map[string]uint32, uint32) []byte {
+			raw, _ := hex.DecodeString(ofsHash)
+			return raw
+		}},
+	})
+
+	// a second pack whose only entry is a delta against the first pack
+	crossPack := "base content?\n"
+	crossHash := blobHash(crossPack)
+	writeRawPack(t, repo, []rawPackEntry{
+		{hash: crossHash, packType: packRefDelta, data: appendDelta(base, "?\n"), extra: func(map[string]uint32, uint32) []byte {
+			raw, _ := hex.DecodeString(baseHash)
+			return raw
+		}},
+	})
+
+	for h, expected := range map[string]string{baseHash: base, ofsHash: ofs, refHash: ref, crossHash: crossPack} {
+		obj, err := repo.LoadObject(h)
+		if err != nil {
+			t.Errorf("Failed to load %s: %v", h, err)
+			continue
+		}
+		if blob, ok := obj.(*objects.Blob); !ok || string(blob.Content()) != expected {
+			t.Errorf("Expected blob %q for %s, got %+v", expected, h, obj)
+		}
+		if err := repo.VerifyObject(h); err != nil {
+			t.Errorf("Expected %s to verify: %v", h, err)
+		}
+		if size, err := repo.ObjectSize(h); err != nil || size != int64(len(expected)) {
+			t.Errorf("Expected size %d for %s, got %d (%v)", len(expected), h, size, err)
+		}
+	}
+}
+
+func TestRepository_ReadPackedObjectBadSize(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// headers claiming far more, or less, than the entry inflates to
+	huge := strings.Repeat("1", 40)
+	short := strings.Repeat("2", 40)
+	writeRawPack(t, repo, []rawPackEntry{
+		{hash: huge, packType: 3, data: []byte("tiny"), size: 1 << 40},
+		{hash: short, packType: 3, data: []byte("longer than claimed"), size: 4},
+	})
+
+	for _, h := range []string{huge, short} {
+		if _, _, err := repo.ReadObjectData(h); !stderrors.Is(err, errors.ErrCorruptedRepository) {
+			t.Errorf("Expected ErrCorruptedRepository for %s, got %v", h, err)
+		}
+	}
+}
+
+func TestRepository_PackIndexCache(t *testing.T) {
+	repo := New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	first := writeTestPack(t, repo, "first pack")[0]
+	if !repo.HasObject(first) {
+		t.Fatal("Expected the first pack's object")
+	}
+
+	// a pack added later is picked up once the directory changes; push the
+	// modification time forward in case both writes land in the same tick
+	second := writeTestPack(t, repo, "second pack")[0]
+	packDir := filepath.Join(repo.GitDir, "objects", "pack")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(packDir, later, later); err != nil {
+		t.Fatalf("Failed to touch pack dir: %v", err)
+	}
+	if !repo.HasObject(second) {
+		t.Error("Expected the new pack to be found")
+	}
+
+	// removing a pack drops it too
+	idxPaths, err := repo.packIndexPaths()
+	if err != nil || len(idxPaths) != 2 {
+		t.Fatalf("Expected two packs, got %v (%v)", idxPaths, err)
+	}
+	for _, idxPath := range idxPaths {
+		if _, err := repo.findObjectInPackIndex(first, idxPath); err == nil {
+			os.Remove(idxPath)
+			os.Remove(strings.TrimSuffix(idxPath, ".idx") + ".pack")
+		}
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(packDir, later, later); err != nil {
+		t.Fatalf("Failed to touch pack dir: %v", err)
+	}
+	if repo.HasObject(first) {
+		t.Error("Expected the removed pack to be forgotten")
+	}
+	if !repo.HasObject(second) {
+		t.Error("Expected the remaining pack to stay cached")
+	}
+}
+
+func BenchmarkLoadPackedObjects(b *testing.B) {
+	repo := New(b.TempDir())
+	if err := repo.Init(); err != nil {
+		b.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	contents := make([]string, 2000)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("packed object %d\n", i)
+	}
+	hashes := writeTestPack(b, repo, contents...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, h := range hashes {
+			if _, err := repo.LoadObject(h); err != nil {
+				b.Fatalf("Failed to load %s: %v", h, err)
+			}
+		}
+	}
+}
+
+func TestCheckRefName(t *testing.T) {
+	valid := []string{"HEAD", "ORIG_HEAD", "refs/heads/main", "refs/heads/feature/x", "refs/tags/v1.0", "refs/remotes/origin/main"}
+	for _, name := range valid {
+		if err := CheckRefName(name); err != nil {
+			t.Errorf("CheckRefName(%q) = %v, want nil", name, err)
+		}
+	}
+
+	invalid := []string{
+		"", "config", "heads/main", "/refs/heads/main", "refs/../config", "refs/heads/../../outside",
+		"refs//heads", "refs/heads/", "refs/heads/main.", "refs/heads/.hidden", "refs/heads/main.lock",
+		"refs/heads/a b", "refs/heads/a\x01", "refs/heads/a~1", "refs/heads/a^", "refs/heads/a:b",
+		"refs/heads/a?", "refs/heads/a*", "refs/heads/a[", "refs/heads/a\\b", "refs/heads/a@{1}",
+	}
+	for _, name := range invalid {
+		if err := CheckRefName(name); !stderrors.Is(err, errors.ErrInvalidReference) {
+			t.Errorf("CheckRefName(%q) = %v, want ErrInvalidReference", name, err)
+		}
+	}
+}
+
+func TestRepository_UpdateRefRejectsEscapingNames(t *testing.T) {
+	dir := t.TempDir()
+	repo := New(filepath.Join(dir, "work"))
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	commit := strings.Repeat("a", 40)
+
+	if err := repo.UpdateRef("refs/../../outside", commit); !stderrors.Is(err, errors.ErrInvalidReference) {
+		t.Errorf("Expected ErrInvalidReference from UpdateRef, got %v", err)
+	}
+	if err := repo.UpdateRefCAS("refs/heads/../../../outside", "", commit); !stderrors.Is(err, errors.ErrInvalidReference) {
+		t.Errorf("Expected ErrInvalidReference from UpdateRefCAS, got %v", err)
+	}
+	if err := repo.DeleteRef("refs/../config", commit); !stderrors.Is(err, errors.ErrInvalidReference) {
+		t.Errorf("Expected ErrInvalidReference from DeleteRef, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing written outside the repository, got %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"bufio"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// ObjectStore holds object contents keyed by their hash. Content excludes
+// the "<type> <size>\x00" header.
+type ObjectStore interface {
+	Put(hash string, typ objects.ObjectType, content []byte) error
+	Get(hash string) (objects.ObjectType, []byte, error)
+	Has(hash string) bool
+	ForEach(fn func(hash string, typ objects.ObjectType) error) error
+}
+
+// fileStore writes loose objects under .git/objects and reads both loose
+// objects and packs
+type fileStore struct {
+	repo *Repository
+}
+
+func (s *fileStore) Put(objHash string, typ objects.ObjectType, content []byte) error {
+	r := s.repo
+	if !r.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	objPath := r.objectPath(objHash)
+	if err := os.MkdirAll(filepath.Dir(objPath), defaultDirMode); err != nil {
+		return errors.NewObjectError(objHash, typ.String(), err)
+	}
+	if _, err := os.Stat(objPath); err == nil {
+		return nil
+	}
+
+	// the object is written under a temporary name and renamed once
+	// complete, so an interrupted write never leaves a truncated object
+	file, err := os.CreateTemp(filepath.Dir(objPath), tempObjectPattern)
+	if err != nil {
+		return errors.NewObjectError(objHash, typ.String(), err)
+	}
+	tempPath := file.Name()
+
+	if err := writeLooseObject(file, typ, content); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return errors.NewObjectError(objHash, typ.String(), err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return errors.NewObjectError(objHash, typ.String(), err)
+	}
+	if err := os.Chmod(tempPath, defaultFileMode); err != nil {
+		os.Remove(tempPath)
+		return errors.NewObjectError(objHash, typ.String(), err)
+	}
+	if err := os.Rename(tempPath, objPath); err != nil {
+		os.Remove(tempPath)
+		return errors.NewObjectError(objHash, typ.String(), err)
+	}
+
+	return nil
+}
+
+func writeLooseObject(w io.Writer, typ objects.ObjectType, content []byte) error {
+	writer := zlib.NewWriter(w)
+	if _, err := fmt.Fprintf(writer, "%s %d\x00", typ, len(content)); err != nil {
+		return err
+	}
+	if _, err := writer.Write(content); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func (s *fileStore) Get(hashStr string) (objects.ObjectType, []byte, error) {
+	r := s.repo
+	if !r.Exists() {
+		return "", nil, errors.ErrNotGitRepository
+	}
+
+	file, err := os.Open(r.objectPath(hashStr))
+	if err == nil {
+		defer file.Close()
+
+		reader, err := zlib.NewReader(file)
+		if err != nil {
+			return "", nil, errors.NewObjectError(hashStr, "unknown", err)
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return "", nil, errors.NewObjectError(hashStr, "unknown", err)
+		}
+
+		objType, _, content, err := objects.ParseObjectHeader(data)
+		if err != nil {
+			return "", nil, errors.NewObjectError(hashStr, "unknown", err)
+		}
+		return objType, content, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", nil, errors.NewObjectError(hashStr, "unknown", err)
+	}
+
+	idxPaths, err := r.packIndexPaths()
+	if err != nil {
+		return "", nil, errors.NewObjectError(hashStr, "unknown", err)
+	}
+
+	for _, idxPath := range idxPaths {
+		offset, err := r.findObjectInPackIndex(hashStr, idxPath)
+		if err != nil {
+			continue
+		}
+
+		objType, data, err := r.readRawObjectFromPack(strings.TrimSuffix(idxPath, ".idx")+".pack", offset)
+		if err != nil {
+			return "", nil, errors.NewObjectError(hashStr, "unknown", err)
+		}
+		return objType, data, nil
+	}
+
+	return "", nil, errors.NewObjectError(hashStr, "unknown", errors.ErrObjectNotFound)
+}
+
+// looseReader streams the content of a loose object
+type looseReader struct {
+	io.Reader
+	inflate io.ReadCloser
+	file    *os.File
+}
+
+func (lr *looseReader) Close() error {
+	err := lr.inflate.Close()
+	if ferr := lr.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// openLoose streams a loose object, inflating it as it is read. It fails
+// with an os.ErrNotExist error when the object is not loose.
+func (s *fileStore) openLoose(hashStr string) (objects.ObjectType, int64, io.ReadCloser, error) {
+	file, err := os.Open(s.repo.objectPath(hashStr))
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	inflate, err := zlib.NewReader(file)
+	if err != nil {
+		file.Close()
+		return "", 0, nil, errors.NewObjectError(hashStr, "unknown", err)
+	}
+
+	buffered := bufio.NewReader(inflate)
+	header, err := buffered.ReadString(0)
+	if err != nil {
+		inflate.Close()
+		file.Close()
+		return "", 0, nil, errors.NewObjectError(hashStr, "unknown", errors.ErrInvalidObjectFormat)
+	}
+
+	typeName, sizeField, _ := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	objType, err := objects.ParseObjectType(typeName)
+	if err == nil {
+		var size int64
+		if size, err = strconv.ParseInt(sizeField, 10, 64); err == nil {
+			return objType, size, &looseReader{Reader: io.LimitReader(buffered, size), inflate: inflate, file: file}, nil
+		}
+	}
+
+	inflate.Close()
+	file.Close()
+	return "", 0, nil, errors.NewObjectError(hashStr, "unknown", errors.ErrInvalidObjectFormat)
+}
+
+func (s *fileStore) Has(hashStr string) bool {
+	r := s.repo
+	if _, err := os.Stat(r.objectPath(hashStr)); err == nil {
+		return true
+	}
+
+	idxPaths, err := r.packIndexPaths()
+	if err != nil {
+		return false
+	}
+	for _, idxPath := range idxPaths {
+		if _, err := r.findObjectInPackIndex(hashStr, idxPath); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fileStore) ForEach(fn func(hash string, typ objects.ObjectType) error) error {
+	r := s.repo
+	if !r.Exists() {
+		return errors.ErrNotGitRepository
+	}
+
+	seen := make(map[string]struct{})
+
+	objectsPath := filepath.Join(r.GitDir, objectsDir)
+	dirs, err := os.ReadDir(objectsPath)
+	if err != nil {
+		return errors.NewGitError("for-each-object", objectsPath, err)
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != hashPrefixLength || !isHex(dir.Name()) {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(objectsPath, dir.Name()))
+		if err != nil {
+			return errors.NewGitError("for-each-object", dir.Name(), err)
+		}
+
+		for _, file := range files {
+			objHash := dir.Name() + file.Name()
+			if file.IsDir() || len(objHash) != hashLength || !isHex(file.Name()) {
+				continue
+			}
+
+			// unreadable headers are reported with an empty type
+			typ, _ := r.looseObjectType(objHash)
+
+			seen[objHash] = struct{}{}
+			if err := fn(objHash, typ); err != nil {
+				return err
+			}
+		}
+	}
+
+	idxPaths, err := r.packIndexPaths()
+	if err != nil {
+		return errors.NewGitError("for-each-object", objectsPath, err)
+	}
+
+	for _, idxPath := range idxPaths {
+		packIdx, err := r.packIndex(idxPath)
+		if err != nil {
+			return errors.NewGitError("for-each-object", idxPath, err)
+		}
+
+		if err := r.forEachPackedObject(idxPath, packIdx.Entries, seen, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MemoryStore keeps objects in a map, for tests and for repositories that
+// never touch disk
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	typ     objects.ObjectType
+	content []byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject)}
+}
+
+func (m *MemoryStore) Put(hash string, typ objects.ObjectType, content []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.objects[hash]; !ok {
+		m.objects[hash] = memoryObject{typ: typ, content: append([]byte(nil), content...)}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Get(hash string) (objects.ObjectType, []byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[hash]
+	if !ok {
+		return "", nil, errors.NewObjectError(hash, "unknown", errors.ErrObjectNotFound)
+	}
+	return obj.typ, append([]byte(nil), obj.content...), nil
+}
+
+func (m *MemoryStore) Has(hash string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.objects[hash]
+	return ok
+}
+
+// ForEach visits objects in hash order. fn may call back into the store.
+func (m *MemoryStore) ForEach(fn func(hash string, typ objects.ObjectType) error) error {
+	m.mu.RLock()
+	hashes := make([]string, 0, len(m.objects))
+	types := make(map[string]objects.ObjectType, len(m.objects))
+	for hash, obj := range m.objects {
+		hashes = append(hashes, hash)
+		types[hash] = obj.typ
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if err := fn(hash, types[hash]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
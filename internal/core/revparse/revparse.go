@@ -0,0 +1,275 @@
+package revparse
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/reflog"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	headRef         = "HEAD"
+	hashLength      = 40
+	minAbbrevLength = 4
+	// maxTagDepth bounds a chain of tags pointing at tags
+	maxTagDepth = 64
+)
+
+// Resolve turns a revision spec into an object hash. Supported forms:
+//
+//	<ref>, <hash>, @        branch, tag, remote or HEAD names and full or short hashes
+//	<ref>@{n}, @{n}         the nth prior value of ref from its reflog
+//	<rev>^, <rev>^N         the first or Nth parent of rev (^0 is rev itself)
+//	<rev>~, <rev>~N         the Nth first-parent ancestor of rev
+//	<rev>^{commit|tree}     peel rev to a commit or its tree
+//	<rev>^{}, <rev>^{tag}   peel rev to the object its tags point at, or check it is a tag
+//
+// Annotated tags are followed to the commit they point at before ^ or ~
+// apply. Suffixes can be chained, e.g. main@{1}~2^2.
+func Resolve(repo *repository.Repository, spec string) (string, error) {
+	if spec == "" {
+		return "", errors.NewGitError("rev-parse", spec, errors.ErrInvalidReference)
+	}
+
+	base, ops := splitSpec(spec)
+
+	current, err := resolveBase(repo, base)
+	if err != nil {
+		return "", errors.NewGitError("rev-parse", spec, err)
+	}
+
+	for len(ops) > 0 {
+		op := ops[0]
+		ops = ops[1:]
+
+		if op == '^' && strings.HasPrefix(ops, "{") {
+			end := strings.IndexByte(ops, '}')
+			if end < 0 {
+				return "", errors.NewGitError("rev-parse", spec, fmt.Errorf("unterminated peel suffix"))
+			}
+			current, err = peel(repo, current, ops[1:end])
+			if err != nil {
+				return "", errors.NewGitError("rev-parse", spec, err)
+			}
+			ops = ops[end+1:]
+			continue
+		}
+
+		digits := 0
+		for digits < len(ops) && ops[digits] >= '0' && ops[digits] <= '9' {
+			digits++
+		}
+
+		n := 1
+		if digits > 0 {
+			n, err = strconv.Atoi(ops[:digits])
+			if err != nil {
+				return "", errors.NewGitError("rev-parse", spec, err)
+			}
+		}
+		ops = ops[digits:]
+
+		switch op {
+		case '^':
+			current, err = nthParent(repo, current, n)
+		case '~':
+			current, err = nthAncestor(repo, current, n)
+		default:
+			err = fmt.Errorf("unexpected '%c'", op)
+		}
+		if err != nil {
+			return "", errors.NewGitError("rev-parse", spec, err)
+		}
+	}
+
+	return current, nil
+}
+
+// splitSpec separates the base name from its ^/~ suffixes, ignoring any
+// characters inside an @{...} reflog selector
+func splitSpec(spec string) (string, string) {
+	depth := 0
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '^', '~':
+			if depth == 0 {
+				return spec[:i], spec[i:]
+			}
+		}
+	}
+	return spec, ""
+}
+
+// ResolveCommit is Resolve for specs that must name a commit, following
+// annotated tags to the commit they point at
+func ResolveCommit(repo *repository.Repository, spec string) (string, error) {
+	if spec == "" {
+		return "", errors.NewGitError("rev-parse", spec, errors.ErrInvalidReference)
+	}
+	return Resolve(repo, spec+"^{commit}")
+}
+
+func resolveBase(repo *repository.Repository, base string) (string, error) {
+	if base == "" || base == "@" {
+		base = headRef
+	}
+
+	if start := strings.Index(base, "@{"); start >= 0 && strings.HasSuffix(base, "}") {
+		ref := base[:start]
+		if ref == "" {
+			ref = headRef
+		}
+
+		selector := base[start+2 : len(base)-1]
+		n, err := strconv.Atoi(selector)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("unsupported reflog selector '@{%s}'", selector)
+		}
+
+		entries, err := reflog.Read(repo, ref)
+		if err != nil {
+			return "", err
+		}
+		if n >= len(entries) {
+			return "", fmt.Errorf("log for '%s' only has %d entries", ref, len(entries))
+		}
+
+		return entries[n].NewHash, nil
+	}
+
+	hash, err := repo.ResolveRef(base)
+	if err == nil {
+		return hash, nil
+	}
+
+	// fall back to an abbreviated object hash
+	if len(base) >= minAbbrevLength && len(base) < hashLength {
+		if expanded, expandErr := repo.ExpandPrefix(base); expandErr == nil {
+			return expanded, nil
+		} else if stderrors.Is(expandErr, errors.ErrAmbiguousHash) {
+			return "", expandErr
+		}
+	}
+
+	return "", err
+}
+
+// peelTags follows annotated tags from hash to the first object that is not
+// a tag
+func peelTags(repo *repository.Repository, hash string) (string, error) {
+	for depth := 0; depth < maxTagDepth; depth++ {
+		objType, data, err := repo.ReadObjectData(hash)
+		if err != nil {
+			return "", errors.NewObjectError(hash, "unknown", err)
+		}
+		if objType != objects.ObjectTypeTag {
+			return hash, nil
+		}
+		if hash, err = objects.ParseTagTarget(data); err != nil {
+			return "", errors.NewObjectError(hash, "tag", err)
+		}
+	}
+	return "", fmt.Errorf("tag %s nests deeper than %d tags", hash, maxTagDepth)
+}
+
+// loadCommit peels hash to a commit, returning the commit's own hash
+func loadCommit(repo *repository.Repository, hash string) (string, *objects.Commit, error) {
+	hash, err := peelTags(repo, hash)
+	if err != nil {
+		return "", nil, err
+	}
+
+	obj, err := repo.LoadObject(hash)
+	if err != nil {
+		return "", nil, errors.NewObjectError(hash, "commit", err)
+	}
+
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return "", nil, errors.NewObjectError(hash, string(obj.Type()), errors.ErrInvalidCommit)
+	}
+
+	return hash, commit, nil
+}
+
+func nthParent(repo *repository.Repository, hash string, n int) (string, error) {
+	hash, commit, err := loadCommit(repo, hash)
+	if err != nil {
+		return "", err
+	}
+
+	if n == 0 {
+		return hash, nil
+	}
+
+	parents := commit.Parents()
+	if n > len(parents) {
+		return "", fmt.Errorf("commit %s has no parent %d", hash, n)
+	}
+
+	return parents[n-1], nil
+}
+
+func nthAncestor(repo *repository.Repository, hash string, n int) (string, error) {
+	current, _, err := loadCommit(repo, hash)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 0; i < n; i++ {
+		parent, err := nthParent(repo, current, 1)
+		if err != nil {
+			return "", err
+		}
+		current = parent
+	}
+
+	return current, nil
+}
+
+func peel(repo *repository.Repository, hash, target string) (string, error) {
+	switch target {
+	case "":
+		return peelTags(repo, hash)
+	case "tag":
+		objType, _, err := repo.ReadObjectData(hash)
+		if err != nil {
+			return "", errors.NewObjectError(hash, "tag", err)
+		}
+		if objType != objects.ObjectTypeTag {
+			return "", errors.NewObjectError(hash, string(objType), errors.ErrInvalidObjectType)
+		}
+		return hash, nil
+	case "commit":
+		hash, _, err := loadCommit(repo, hash)
+		return hash, err
+	case "tree":
+		hash, err := peelTags(repo, hash)
+		if err != nil {
+			return "", err
+		}
+		obj, err := repo.LoadObject(hash)
+		if err != nil {
+			return "", errors.NewObjectError(hash, "tree", err)
+		}
+		switch o := obj.(type) {
+		case *objects.Tree:
+			return hash, nil
+		case *objects.Commit:
+			return o.Tree(), nil
+		default:
+			return "", errors.NewObjectError(hash, string(obj.Type()), errors.ErrInvalidTree)
+		}
+	default:
+		return "", fmt.Errorf("unsupported peel target '%s'", target)
+	}
+}
@@ -0,0 +1,186 @@
+package revparse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+// history builds:
+//
+//	A - B - D (main)
+//	     \ /
+//	      C (side)
+//
+// where D is a merge of B and C.
+type history struct {
+	repo       *repository.Repository
+	a, b, c, d string
+	tree       string
+}
+
+func setupHistory(t *testing.T) *history {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	treeHash, err := repo.StoreObject(objects.NewTree(nil))
+	if err != nil {
+		t.Fatalf("Failed to store tree: %v", err)
+	}
+
+	h := &history{repo: repo, tree: treeHash}
+	h.a = storeCommit(t, repo, treeHash, "A")
+	h.b = storeCommit(t, repo, treeHash, "B", h.a)
+	h.c = storeCommit(t, repo, treeHash, "C", h.a)
+	h.d = storeCommit(t, repo, treeHash, "D", h.b, h.c)
+
+	for _, update := range []struct{ ref, hash, msg string }{
+		{"refs/heads/main", h.a, "commit (initial): A"},
+		{"refs/heads/main", h.b, "commit: B"},
+		{"refs/heads/main", h.d, "merge side"},
+		{"refs/heads/side", h.c, "branch: Created"},
+	} {
+		if err := repo.UpdateRefWithMessage(update.ref, update.hash, update.msg); err != nil {
+			t.Fatalf("Failed to update %s: %v", update.ref, err)
+		}
+	}
+
+	return h
+}
+
+func storeCommit(t *testing.T, repo *repository.Repository, treeHash, message string, parents ...string) string {
+	author := &objects.Signature{
+		Name:  "Test Author",
+		Email: "test@example.com",
+		When:  time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	hash, err := repo.StoreObject(objects.NewCommit(treeHash, parents, author, author, message))
+	if err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+	return hash
+}
+
+func TestResolve(t *testing.T) {
+	h := setupHistory(t)
+
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"HEAD", h.d},
+		{"@", h.d},
+		{"main", h.d},
+		{"side", h.c},
+		{h.b, h.b},
+		{h.b[:7], h.b},
+		{h.d[:8] + "^2", h.c},
+		{"HEAD^", h.b},
+		{"HEAD^1", h.b},
+		{"HEAD^2", h.c},
+		{"HEAD^0", h.d},
+		{"HEAD~", h.b},
+		{"HEAD~2", h.a},
+		{"HEAD~0", h.d},
+		{"main^2~1", h.a},
+		{"HEAD@{0}", h.d},
+		{"HEAD@{1}", h.b},
+		{"@{2}", h.a},
+		{"main@{1}", h.b},
+		{"main@{1}~1", h.a},
+		{"HEAD^{tree}", h.tree},
+		{"side^{commit}", h.c},
+	}
+
+	for _, tt := range tests {
+		got, err := Resolve(h.repo, tt.spec)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Resolve(%q): expected %s, got %s", tt.spec, tt.expected, got)
+		}
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	h := setupHistory(t)
+
+	for _, spec := range []string{
+		"",
+		"missing",
+		"HEAD~5",
+		"HEAD^3",
+		"HEAD@{10}",
+		"HEAD@{yesterday}",
+		"side@{5}",
+		"HEAD^{blob}",
+		"HEAD^{tree",
+	} {
+		if _, err := Resolve(h.repo, spec); err == nil {
+			t.Errorf("Resolve(%q): expected error", spec)
+		}
+	}
+}
+
+func storeTag(t *testing.T, repo *repository.Repository, target, targetType, name string) string {
+	content := fmt.Sprintf("object %s\ntype %s\ntag %s\ntagger Test Author <test@example.com> 1672574400 +0000\n\nrelease\n", target, targetType, name)
+	hash, err := repo.StoreRawObject(objects.ObjectTypeTag, []byte(content))
+	if err != nil {
+		t.Fatalf("Failed to store tag: %v", err)
+	}
+	return hash
+}
+
+func TestResolve_AnnotatedTag(t *testing.T) {
+	h := setupHistory(t)
+
+	tag := storeTag(t, h.repo, h.d, "commit", "v1")
+	nested := storeTag(t, h.repo, tag, "tag", "v1-signed")
+	if err := h.repo.UpdateRef("refs/tags/v1", tag); err != nil {
+		t.Fatalf("Failed to update tag ref: %v", err)
+	}
+	if err := h.repo.UpdateRef("refs/tags/v1-signed", nested); err != nil {
+		t.Fatalf("Failed to update tag ref: %v", err)
+	}
+
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"v1", tag},
+		{"v1^{tag}", tag},
+		{"v1^{}", h.d},
+		{"v1^{commit}", h.d},
+		{"v1^{tree}", h.tree},
+		{"v1^0", h.d},
+		{"v1~0", h.d},
+		{"v1~1", h.b},
+		{"v1^2", h.c},
+		{"v1-signed^{}", h.d},
+		{"v1-signed~2", h.a},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(h.repo, tt.spec)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Resolve(%q): expected %s, got %s", tt.spec, tt.expected, got)
+		}
+	}
+
+	if got, err := ResolveCommit(h.repo, "v1"); err != nil || got != h.d {
+		t.Errorf("ResolveCommit(v1): expected %s, got %s (%v)", h.d, got, err)
+	}
+	if _, err := Resolve(h.repo, "main^{tag}"); err == nil {
+		t.Error("Resolve(main^{tag}): expected error for a commit")
+	}
+}
@@ -0,0 +1,96 @@
+package submodule
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/unkn0wn-root/git-go/internal/core/config"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	gitmodulesFile = ".gitmodules"
+	sectionName    = "submodule"
+)
+
+// Submodule is a repository nested in the work tree at Path, recorded in the
+// superproject as a gitlink to Commit
+type Submodule struct {
+	Name   string
+	Path   string
+	URL    string
+	Commit string
+}
+
+// ParseGitmodules reads the .gitmodules file at the top of workDir and returns
+// its submodules keyed by path. A missing file yields none.
+func ParseGitmodules(workDir string) (map[string]Submodule, error) {
+	cfg, err := config.Load(filepath.Join(workDir, gitmodulesFile))
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make(map[string]Submodule)
+	for _, name := range cfg.Subsections(sectionName) {
+		path, ok := cfg.Get(sectionName, name, "path")
+		if !ok {
+			continue
+		}
+		url, _ := cfg.Get(sectionName, name, "url")
+		modules[path] = Submodule{Name: name, Path: path, URL: url}
+	}
+	return modules, nil
+}
+
+// List returns the submodules recorded in the index, sorted by path, with
+// their name and URL taken from .gitmodules. A gitlink .gitmodules does not
+// describe is named after its path and has no URL.
+func List(repo *repository.Repository) ([]Submodule, error) {
+	modules, err := ParseGitmodules(repo.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		return nil, errors.NewIndexError("", fmt.Errorf("load index: %w", err))
+	}
+
+	var submodules []Submodule
+	for path, entry := range idx.GetAllEntries() {
+		if objects.FileMode(entry.Mode) != objects.FileModeCommit {
+			continue
+		}
+
+		module, ok := modules[path]
+		if !ok {
+			module = Submodule{Name: path, Path: path}
+		}
+		module.Commit = entry.Hash
+		submodules = append(submodules, module)
+	}
+
+	sort.Slice(submodules, func(i, j int) bool {
+		return submodules[i].Path < submodules[j].Path
+	})
+	return submodules, nil
+}
+
+// Head returns the commit the submodule at path is checked out at, and
+// false when it has not been cloned into the work tree
+func Head(repo *repository.Repository, path string) (string, bool) {
+	sub, err := repository.Open(filepath.Join(repo.WorkDir, filepath.FromSlash(path)))
+	if err != nil {
+		return "", false
+	}
+
+	head, err := sub.GetHead()
+	if err != nil || head == "" {
+		return "", false
+	}
+	return head, true
+}
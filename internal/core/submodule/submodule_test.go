@@ -0,0 +1,79 @@
+package submodule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+const (
+	libCommit    = "1111111111111111111111111111111111111111"
+	vendorCommit = "2222222222222222222222222222222222222222"
+)
+
+func setupSuperproject(t *testing.T) *repository.Repository {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.WorkDir, ".gitmodules"), []byte(
+		"[submodule \"library\"]\n\tpath = lib\n\turl = https://example.com/lib.git\n"+
+			"[submodule \"unused\"]\n\tpath = gone\n\turl = https://example.com/gone.git\n"), 0644))
+
+	idx := index.New(repo.GitDir)
+	require.NoError(t, idx.Add("lib", libCommit, uint32(objects.FileModeCommit), 0, time.Now()))
+	require.NoError(t, idx.Add("third_party/vendor", vendorCommit, uint32(objects.FileModeCommit), 0, time.Now()))
+	require.NoError(t, idx.Add("README", "ce013625030ba8dba906f756967f9e9ca394464a", uint32(objects.FileModeBlob), 6, time.Now()))
+	require.NoError(t, idx.Save())
+	return repo
+}
+
+func TestParseGitmodules(t *testing.T) {
+	repo := setupSuperproject(t)
+
+	modules, err := ParseGitmodules(repo.WorkDir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Submodule{
+		"lib":  {Name: "library", Path: "lib", URL: "https://example.com/lib.git"},
+		"gone": {Name: "unused", Path: "gone", URL: "https://example.com/gone.git"},
+	}, modules)
+
+	modules, err = ParseGitmodules(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, modules)
+}
+
+func TestList(t *testing.T) {
+	repo := setupSuperproject(t)
+
+	submodules, err := List(repo)
+	require.NoError(t, err)
+	assert.Equal(t, []Submodule{
+		{Name: "library", Path: "lib", URL: "https://example.com/lib.git", Commit: libCommit},
+		{Name: "third_party/vendor", Path: "third_party/vendor", Commit: vendorCommit},
+	}, submodules)
+}
+
+func TestHead(t *testing.T) {
+	repo := setupSuperproject(t)
+
+	_, ok := Head(repo, "lib")
+	assert.False(t, ok, "a submodule that is not cloned has no head")
+
+	sub := repository.New(filepath.Join(repo.WorkDir, "lib"))
+	require.NoError(t, os.MkdirAll(sub.WorkDir, 0755))
+	require.NoError(t, sub.Init())
+	_, ok = Head(repo, "lib")
+	assert.False(t, ok, "a submodule without commits has no head")
+
+	require.NoError(t, sub.UpdateRef("refs/heads/main", libCommit))
+	head, ok := Head(repo, "lib")
+	assert.True(t, ok)
+	assert.Equal(t, libCommit, head)
+}
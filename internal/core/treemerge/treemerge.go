@@ -0,0 +1,293 @@
+package treemerge
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// maxMergeCells bounds the LCS table of a text merge; larger files only merge
+// when one side left them untouched
+const maxMergeCells = 16 << 20
+
+// Result is the outcome of a three-way merge of flattened trees
+type Result struct {
+	// Files is the merged path -> entry map, valid when Conflicts is empty
+	Files     map[string]objects.TreeEntry
+	Conflicts []string
+}
+
+// Merge combines the changes from base to ours and from base to theirs. Paths
+// changed on one side take that side's entry; paths changed on both are
+// merged line by line, and reported as conflicts when the edits overlap or
+// one side deleted what the other modified. Merged blobs are stored in repo.
+func Merge(repo *repository.Repository, base, ours, theirs map[string]objects.TreeEntry) (*Result, error) {
+	result := &Result{Files: make(map[string]objects.TreeEntry)}
+
+	for _, path := range unionPaths(base, ours, theirs) {
+		b, inBase := base[path]
+		o, inOurs := ours[path]
+		t, inTheirs := theirs[path]
+
+		switch {
+		case sameEntry(o, inOurs, t, inTheirs), sameEntry(b, inBase, t, inTheirs):
+			if inOurs {
+				result.Files[path] = o
+			}
+			continue
+		case sameEntry(b, inBase, o, inOurs):
+			if inTheirs {
+				result.Files[path] = t
+			}
+			continue
+		}
+
+		// both sides changed the path in different ways
+		if !inOurs || !inTheirs {
+			result.Conflicts = append(result.Conflicts, path)
+			continue
+		}
+
+		mode, ok := mergeMode(b, inBase, o, t)
+		if !ok {
+			result.Conflicts = append(result.Conflicts, path)
+			continue
+		}
+
+		var baseContent []byte
+		if inBase {
+			content, err := blobContent(repo, b.Hash)
+			if err != nil {
+				return nil, err
+			}
+			baseContent = content
+		}
+
+		ourContent, err := blobContent(repo, o.Hash)
+		if err != nil {
+			return nil, err
+		}
+		theirContent, err := blobContent(repo, t.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		merged, ok := MergeText(baseContent, ourContent, theirContent)
+		if !ok {
+			result.Conflicts = append(result.Conflicts, path)
+			continue
+		}
+
+		blobHash, err := repo.StoreObject(objects.NewBlob(merged))
+		if err != nil {
+			return nil, err
+		}
+		result.Files[path] = objects.TreeEntry{Mode: mode, Name: o.Name, Hash: blobHash}
+	}
+
+	return result, nil
+}
+
+// MergeText performs a line-based three-way merge. It reports false when
+// ours and theirs change the same or adjacent base lines differently.
+func MergeText(base, ours, theirs []byte) ([]byte, bool) {
+	baseLines := splitLines(base)
+	ourHunks, ok := diffHunks(baseLines, splitLines(ours))
+	if !ok {
+		return nil, false
+	}
+	theirHunks, ok := diffHunks(baseLines, splitLines(theirs))
+	if !ok {
+		return nil, false
+	}
+
+	var out bytes.Buffer
+	pos := 0
+	emit := func(h hunk) {
+		for _, line := range baseLines[pos:h.start] {
+			out.WriteString(line)
+		}
+		for _, line := range h.lines {
+			out.WriteString(line)
+		}
+		pos = h.end
+	}
+
+	i, j := 0, 0
+	for i < len(ourHunks) || j < len(theirHunks) {
+		switch {
+		case j == len(theirHunks):
+			emit(ourHunks[i])
+			i++
+		case i == len(ourHunks):
+			emit(theirHunks[j])
+			j++
+		case ourHunks[i].overlaps(theirHunks[j]):
+			if !ourHunks[i].equal(theirHunks[j]) {
+				return nil, false
+			}
+			emit(ourHunks[i])
+			i++
+			j++
+		case ourHunks[i].start < theirHunks[j].start:
+			emit(ourHunks[i])
+			i++
+		default:
+			emit(theirHunks[j])
+			j++
+		}
+	}
+
+	for _, line := range baseLines[pos:] {
+		out.WriteString(line)
+	}
+
+	return out.Bytes(), true
+}
+
+// hunk replaces base lines [start, end) with lines
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+// overlaps treats touching hunks as overlapping, as Git does
+func (h hunk) overlaps(other hunk) bool {
+	return h.start <= other.end && other.start <= h.end
+}
+
+func (h hunk) equal(other hunk) bool {
+	if h.start != other.start || h.end != other.end || len(h.lines) != len(other.lines) {
+		return false
+	}
+	for i := range h.lines {
+		if h.lines[i] != other.lines[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffHunks returns the changes turning base into side, ordered by position
+func diffHunks(base, side []string) ([]hunk, bool) {
+	m, n := len(base), len(side)
+	if (m+1)*(n+1) > maxMergeCells {
+		return nil, false
+	}
+
+	// lcs[i][j] is the LCS length of base[i:] and side[j:]
+	lcs := make([][]int, m+1)
+	for i := range lcs {
+		lcs[i] = make([]int, n+1)
+	}
+	for i := m - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if base[i] == side[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var hunks []hunk
+	var current *hunk
+	flush := func() {
+		if current != nil {
+			hunks = append(hunks, *current)
+			current = nil
+		}
+	}
+
+	i, j := 0, 0
+	for i < m || j < n {
+		if i < m && j < n && base[i] == side[j] {
+			flush()
+			i++
+			j++
+			continue
+		}
+
+		if current == nil {
+			current = &hunk{start: i, end: i}
+		}
+		if j < n && (i == m || lcs[i][j+1] >= lcs[i+1][j]) {
+			current.lines = append(current.lines, side[j])
+			j++
+		} else {
+			i++
+			current.end = i
+		}
+	}
+	flush()
+
+	return hunks, true
+}
+
+// splitLines splits content after each newline, keeping the terminators so
+// the merged output reproduces the inputs byte for byte
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		end := bytes.IndexByte(content, '\n')
+		if end == -1 {
+			end = len(content) - 1
+		}
+		lines = append(lines, string(content[:end+1]))
+		content = content[end+1:]
+	}
+	return lines
+}
+
+// mergeMode picks the file mode of a content-merged path, failing when both
+// sides changed the mode differently
+func mergeMode(base objects.TreeEntry, inBase bool, ours, theirs objects.TreeEntry) (objects.FileMode, bool) {
+	switch {
+	case ours.Mode == theirs.Mode:
+		return ours.Mode, true
+	case inBase && ours.Mode == base.Mode:
+		return theirs.Mode, true
+	case inBase && theirs.Mode == base.Mode:
+		return ours.Mode, true
+	}
+	return 0, false
+}
+
+func blobContent(repo *repository.Repository, blobHash string) ([]byte, error) {
+	obj, err := repo.LoadObject(blobHash)
+	if err != nil {
+		return nil, errors.NewObjectError(blobHash, "blob", err)
+	}
+
+	blob, ok := obj.(*objects.Blob)
+	if !ok {
+		return nil, errors.NewObjectError(blobHash, "blob", errors.ErrInvalidBlob)
+	}
+
+	return blob.Content(), nil
+}
+
+func sameEntry(a objects.TreeEntry, inA bool, b objects.TreeEntry, inB bool) bool {
+	if inA != inB {
+		return false
+	}
+	return !inA || (a.Hash == b.Hash && a.Mode == b.Mode)
+}
+
+func unionPaths(sets ...map[string]objects.TreeEntry) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, set := range sets {
+		for path := range set {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,146 @@
+package treemerge
+
+import (
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+func TestMergeText(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\nfive\n"
+
+	tests := []struct {
+		name   string
+		ours   string
+		theirs string
+		want   string
+		ok     bool
+	}{
+		{
+			name:   "separate edits",
+			ours:   "ONE\ntwo\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nthree\nfour\nFIVE\n",
+			want:   "ONE\ntwo\nthree\nfour\nFIVE\n",
+			ok:     true,
+		},
+		{
+			name:   "insert and delete",
+			ours:   "one\ntwo\nthree\nthree and a half\nfour\nfive\n",
+			theirs: "two\nthree\nfour\nfive\n",
+			want:   "two\nthree\nthree and a half\nfour\nfive\n",
+			ok:     true,
+		},
+		{
+			name:   "identical edits",
+			ours:   "one\nTWO\nthree\nfour\nfive\n",
+			theirs: "one\nTWO\nthree\nfour\nfive\n",
+			want:   "one\nTWO\nthree\nfour\nfive\n",
+			ok:     true,
+		},
+		{
+			name:   "same line",
+			ours:   "one\ntwo\n3\nfour\nfive\n",
+			theirs: "one\ntwo\nIII\nfour\nfive\n",
+			ok:     false,
+		},
+		{
+			name:   "adjacent lines",
+			ours:   "one\nTWO\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nTHREE\nfour\nfive\n",
+			ok:     false,
+		},
+		{
+			name:   "missing final newline",
+			ours:   "ONE\ntwo\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nthree\nfour\nfive",
+			want:   "ONE\ntwo\nthree\nfour\nfive",
+			ok:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := MergeText([]byte(base), []byte(tt.ours), []byte(tt.theirs))
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v (%q)", tt.ok, ok, got)
+			}
+			if ok && string(got) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	if err := repo.Init(); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+
+	entry := func(content string) objects.TreeEntry {
+		hash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		return objects.TreeEntry{Mode: objects.FileModeBlob, Name: "f", Hash: hash}
+	}
+
+	base := map[string]objects.TreeEntry{
+		"same.txt":    entry("same\n"),
+		"ours.txt":    entry("base\n"),
+		"theirs.txt":  entry("base\n"),
+		"both.txt":    entry("a\nb\nc\nd\n"),
+		"deleted.txt": entry("gone\n"),
+		"clash.txt":   entry("base\n"),
+		"modrm.txt":   entry("base\n"),
+	}
+	ours := map[string]objects.TreeEntry{
+		"same.txt":   base["same.txt"],
+		"ours.txt":   entry("ours\n"),
+		"theirs.txt": base["theirs.txt"],
+		"both.txt":   entry("A\nb\nc\nd\n"),
+		"clash.txt":  entry("ours\n"),
+		"modrm.txt":  entry("modified\n"),
+		"added.txt":  entry("new\n"),
+	}
+	theirs := map[string]objects.TreeEntry{
+		"same.txt":    base["same.txt"],
+		"ours.txt":    base["ours.txt"],
+		"theirs.txt":  entry("theirs\n"),
+		"both.txt":    entry("a\nb\nc\nD\n"),
+		"deleted.txt": base["deleted.txt"],
+		"clash.txt":   entry("theirs\n"),
+	}
+
+	result, err := Merge(repo, base, ours, theirs)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	if len(result.Conflicts) != 2 || result.Conflicts[0] != "clash.txt" || result.Conflicts[1] != "modrm.txt" {
+		t.Errorf("Expected conflicts on clash.txt and modrm.txt, got %v", result.Conflicts)
+	}
+
+	expected := map[string]string{
+		"same.txt":   "same\n",
+		"ours.txt":   "ours\n",
+		"theirs.txt": "theirs\n",
+		"both.txt":   "A\nb\nc\nD\n",
+		"added.txt":  "new\n",
+	}
+	for path, content := range expected {
+		got, ok := result.Files[path]
+		if !ok {
+			t.Errorf("Expected %s in merged files", path)
+			continue
+		}
+		if got.Hash != entry(content).Hash {
+			t.Errorf("Unexpected content for %s", path)
+		}
+	}
+
+	if _, ok := result.Files["deleted.txt"]; ok {
+		t.Error("Expected deleted.txt to stay deleted")
+	}
+}
@@ -0,0 +1,60 @@
+// Package testutil holds helpers shared by the tests of several packages
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/internal/commands/commit"
+	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+// CommitFiles writes and stages files, then commits them on the current
+// branch, returning the new commit's hash
+func CommitFiles(t testing.TB, repo *repository.Repository, message string, files map[string]string) string {
+	t.Helper()
+
+	idx := index.New(repo.GitDir)
+	if err := idx.Load(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+
+	for path, content := range files {
+		fullPath := filepath.Join(repo.WorkDir, path)
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+
+		if err := idx.AddWithFileInfo(path, blobHash, uint32(objects.FileModeBlob), info); err != nil {
+			t.Fatalf("Failed to stage %s: %v", path, err)
+		}
+	}
+
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	result, err := commit.CreateCommit(repo, commit.CommitOptions{
+		Message:     message,
+		AuthorName:  "Test Author",
+		AuthorEmail: "test@example.com",
+		SkipHooks:   true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	return result.Hash
+}
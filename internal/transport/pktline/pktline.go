@@ -0,0 +1,65 @@
+package pktline
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	HeaderSize = 4
+	Flush      = "0000"
+
+	// MaxSidebandData is what a side-band-64k packet carries after its
+	// header and band byte
+	MaxSidebandData = 65515
+)
+
+// sideband channels
+const (
+	BandData     = 1
+	BandProgress = 2
+	BandError    = 3
+)
+
+// Read reads one pkt-line, returning nil for a flush packet
+func Read(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid packet length %q", header)
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	if length < HeaderSize {
+		return nil, fmt.Errorf("invalid packet length %d", length)
+	}
+
+	payload := make([]byte, length-HeaderSize)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Write frames payload as a pkt-line
+func Write(buf *bytes.Buffer, payload string) {
+	fmt.Fprintf(buf, "%04x%s", len(payload)+HeaderSize, payload)
+}
+
+// WriteBand writes data to a sideband channel in as many packets as it takes
+func WriteBand(buf *bytes.Buffer, band byte, data []byte) {
+	for len(data) > 0 {
+		n := min(len(data), MaxSidebandData)
+		fmt.Fprintf(buf, "%04x%c", n+1+HeaderSize, band)
+		buf.Write(data[:n])
+		data = data[n:]
+	}
+}
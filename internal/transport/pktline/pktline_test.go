@@ -0,0 +1,52 @@
+package pktline
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	var buf bytes.Buffer
+	Write(&buf, "want abc\n")
+	buf.WriteString(Flush)
+	assert.Equal(t, "000dwant abc\n0000", buf.String())
+
+	r := bufio.NewReader(&buf)
+	packet, err := Read(r)
+	require.NoError(t, err)
+	assert.Equal(t, "want abc\n", string(packet))
+	packet, err = Read(r)
+	require.NoError(t, err)
+	assert.Nil(t, packet)
+
+	for _, bad := range []string{"zzzz", "0003", "0010short"} {
+		_, err := Read(bufio.NewReader(strings.NewReader(bad)))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestWriteBand(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), MaxSidebandData+10)
+	var buf bytes.Buffer
+	WriteBand(&buf, BandData, data)
+
+	r := bufio.NewReader(&buf)
+	var got []byte
+	var sizes []int
+	for {
+		packet, err := Read(r)
+		if err != nil {
+			break
+		}
+		require.Equal(t, byte(BandData), packet[0])
+		sizes = append(sizes, len(packet)-1)
+		got = append(got, packet[1:]...)
+	}
+	assert.Equal(t, []int{MaxSidebandData, 10}, sizes)
+	assert.Equal(t, data, got)
+}
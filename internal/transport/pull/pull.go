@@ -4,12 +4,15 @@ import (
 	"context"
 	stderrors "errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/unkn0wn-root/git-go/internal/core/index"
+	"github.com/unkn0wn-root/git-go/internal/core/mergebase"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/internal/core/pack"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
@@ -18,11 +21,14 @@ import (
 )
 
 const (
-	defaultRemote   = "origin"
-	defaultTimeout  = 5 * time.Minute
-	defaultDirMode  = 0755
-	defaultFileMode = 0644
-	executableMode  = 0755
+	defaultRemote  = "origin"
+	defaultTimeout = 5 * time.Minute
+	defaultDirMode = 0755
+
+	tagsPrefix   = "refs/tags/"
+	peeledSuffix = "^{}"
+
+	reasonFunnyRefname = "funny refname"
 )
 
 type PullStrategy int
@@ -41,14 +47,21 @@ type PullOptions struct {
 	Force          bool
 	Prune          bool
 	Depth          int
-	Timeout        time.Duration
+	// Tags selects the tags fetched along with the branches
+	Tags remote.TagMode
+	// DryRun lists the remote's refs and plans the fetch without fetching
+	// or changing anything
+	DryRun  bool
+	Timeout time.Duration
 }
 
 type PullResult struct {
-	Strategy      PullStrategy
-	OldCommit     string
-	NewCommit     string
-	UpdatedRefs   map[string]string
+	Strategy    PullStrategy
+	OldCommit   string
+	NewCommit   string
+	UpdatedRefs map[string]string
+	// RejectedRefs holds the fetched refs left alone, with the reason why
+	RejectedRefs  map[string]string
 	ConflictFiles []string
 	FastForward   bool
 	CommitsAhead  int
@@ -57,6 +70,8 @@ type PullResult struct {
 	UpdatedFiles  []string
 	DeletedFiles  []string
 	AddedFiles    []string
+	// Plan is what a dry run would have fetched
+	Plan *FetchPlan
 }
 
 type Puller struct {
@@ -64,15 +79,26 @@ type Puller struct {
 	transport remote.Transport
 	auth      *remote.AuthConfig
 	index     *index.Index
+	progress  pack.Progress
 }
 
 func NewPuller(repo *repository.Repository) *Puller {
-	auth, _ := remote.LoadAuthConfig()
+	auth, _ := remote.LoadRepoAuthConfig(repo.GitDir)
 	return &Puller{
-		repo:  repo,
-		auth:  auth,
-		index: index.New(repo.GitDir),
+		repo:     repo,
+		auth:     auth,
+		index:    index.New(repo.GitDir),
+		progress: pack.NoProgress,
+	}
+}
+
+// WithProgress reports the fetch to progress. It returns p for chaining.
+func (p *Puller) WithProgress(progress pack.Progress) *Puller {
+	if progress == nil {
+		progress = pack.NoProgress
 	}
+	p.progress = progress
+	return p
 }
 
 func (p *Puller) Pull(ctx context.Context, options PullOptions) (*PullResult, error) {
@@ -97,25 +123,41 @@ func (p *Puller) Pull(ctx context.Context, options PullOptions) (*PullResult, er
 		return nil, fmt.Errorf("remote '%s' not found: %w", options.Remote, err)
 	}
 
-	transport, err := remote.CreateTransport(remoteConfig.FetchURL, p.auth)
+	refspecs, err := remoteConfig.FetchRefspecs()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := remote.CreateTransport(remoteConfig.FetchURL, p.auth.ForRemote(remoteConfig))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transport: %w", err)
 	}
 	defer transport.Close()
 
+	return p.pull(ctx, transport, remoteConfig, refspecs, options)
+}
+
+func (p *Puller) pull(ctx context.Context, transport remote.Transport, remoteConfig *remote.Remote, refspecs []remote.Refspec, options PullOptions) (*PullResult, error) {
 	p.transport = transport
 
 	if err := transport.Connect(ctx, remoteConfig.FetchURL); err != nil {
 		return nil, fmt.Errorf("failed to connect to remote: %w", err)
 	}
 
-	currentBranch, err := p.repo.GetCurrentBranch()
+	// a detached HEAD is moved by itself
+	localRef, detached, _, err := p.repo.HeadRef()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current branch: %w", err)
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if detached {
+		localRef = "HEAD"
 	}
 
 	if options.Branch == "" {
-		options.Branch = currentBranch
+		if detached {
+			return nil, fmt.Errorf("not currently on a branch; name the branch to pull")
+		}
+		options.Branch = strings.TrimPrefix(localRef, "refs/heads/")
 	}
 
 	remoteRefs, err := transport.ListRefs(ctx)
@@ -134,40 +176,76 @@ func (p *Puller) Pull(ctx context.Context, options PullOptions) (*PullResult, er
 		return nil, fmt.Errorf("failed to get local HEAD: %w", err)
 	}
 
-	if localCommit == remoteCommit {
-		return &PullResult{
-			Strategy:      options.Strategy,
-			OldCommit:     localCommit,
-			NewCommit:     remoteCommit,
-			UpdatedRefs:   make(map[string]string),
-			FastForward:   true,
-			CommitsAhead:  0,
-			CommitsBehind: 0,
-		}, nil
+	updates, err := p.mapRefs(remoteRefs, refspecs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map remote refs: %w", err)
 	}
 
 	result := &PullResult{
-		Strategy:    options.Strategy,
-		OldCommit:   localCommit,
-		NewCommit:   remoteCommit,
-		UpdatedRefs: make(map[string]string),
+		Strategy:     options.Strategy,
+		OldCommit:    localCommit,
+		NewCommit:    remoteCommit,
+		UpdatedRefs:  make(map[string]string),
+		RejectedRefs: make(map[string]string),
+	}
+
+	if options.DryRun {
+		// followed tags can only be those on history that is here already
+		tagUpdates, err := p.tagUpdates(remoteRefs, options.Tags, updates)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map remote tags: %w", err)
+		}
+		result.Plan, err = p.planFetch(options.Remote, append(updates, tagUpdates...), localRef, options.Force)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan fetch: %w", err)
+		}
+		return result, nil
 	}
 
-	if err := p.fetchCommits(ctx, []string{remoteCommit}, []string{localCommit}); err != nil {
-		return nil, fmt.Errorf("failed to fetch commits: %w", err)
+	tips := []string{remoteCommit}
+	for _, update := range updates {
+		tips = append(tips, update.newHash)
+	}
+	if options.Tags == remote.TagAll {
+		for _, hash := range remote.AdvertisedTags(remoteRefs) {
+			tips = append(tips, hash)
+		}
 	}
 
-	if err := p.updateRemoteRefs(remoteRefs, options.Remote); err != nil {
+	if wants := p.missingObjects(tips); len(wants) > 0 {
+		var haves []string
+		if localCommit != "" {
+			haves = append(haves, localCommit)
+		}
+		if err := p.fetchCommits(ctx, wants, haves); err != nil {
+			return nil, fmt.Errorf("failed to fetch commits: %w", err)
+		}
+	}
+
+	tagUpdates, err := p.tagUpdates(remoteRefs, options.Tags, updates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map remote tags: %w", err)
+	}
+	if err := p.fetchTags(ctx, tagUpdates, remoteRefs); err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	updates = append(updates, tagUpdates...)
+
+	if err := p.updateRemoteRefs(updates, localRef, options.Force, result); err != nil {
 		return nil, fmt.Errorf("failed to update remote refs: %w", err)
 	}
 
+	if localCommit == remoteCommit {
+		result.FastForward = true
+		return result, nil
+	}
+
 	if localCommit == "" {
 		result.FastForward = true
-		branchRef := fmt.Sprintf("refs/heads/%s", currentBranch)
-		if err := p.repo.UpdateRef(branchRef, remoteCommit); err != nil {
+		if err := p.repo.UpdateRefWithMessage(localRef, remoteCommit, "pull: Fast-forward"); err != nil {
 			return nil, fmt.Errorf("failed to update branch ref: %w", err)
 		}
-		result.UpdatedRefs[branchRef] = remoteCommit
+		result.UpdatedRefs[localRef] = remoteCommit
 		return result, nil
 	}
 
@@ -178,7 +256,7 @@ func (p *Puller) Pull(ctx context.Context, options PullOptions) (*PullResult, er
 
 	if mergeBase == localCommit {
 		result.FastForward = true
-		if err := p.fastForward(currentBranch, remoteCommit, result); err != nil {
+		if err := p.fastForward(localRef, remoteCommit, result); err != nil {
 			return nil, fmt.Errorf("fast-forward failed: %w", err)
 		}
 		return result, nil
@@ -192,11 +270,11 @@ func (p *Puller) Pull(ctx context.Context, options PullOptions) (*PullResult, er
 
 	switch options.Strategy {
 	case PullMerge:
-		if err := p.performMerge(currentBranch, remoteCommit, result); err != nil {
+		if err := p.performMerge(localRef, options.Branch, remoteCommit, result); err != nil {
 			return nil, fmt.Errorf("merge failed: %w", err)
 		}
 	case PullRebase:
-		if err := p.performRebase(currentBranch, remoteCommit, result); err != nil {
+		if err := p.performRebase(localRef, remoteCommit, result); err != nil {
 			return nil, fmt.Errorf("rebase failed: %w", err)
 		}
 	case PullFastForward:
@@ -215,99 +293,295 @@ func (p *Puller) fetchCommits(ctx context.Context, wants, haves []string) error
 	}
 	defer packReader.Close()
 
-	return p.processPack(packReader)
+	return p.processPack(ctx, packReader)
 }
 
-func (p *Puller) processPack(reader remote.PackReader) error {
-	processor := pack.NewPackProcessor(p.repo)
-	return processor.ProcessPack(reader)
+func (p *Puller) processPack(ctx context.Context, reader remote.PackReader) error {
+	processor := pack.NewPackProcessor(p.repo).WithProgress(p.progress)
+	return processor.ProcessPackContext(ctx, reader)
 }
 
-func (p *Puller) updateRemoteRefs(remoteRefs map[string]string, remoteName string) error {
-	remoteRefsDir := filepath.Join(p.repo.GitDir, "refs", "remotes", remoteName)
-	if err := os.MkdirAll(remoteRefsDir, defaultDirMode); err != nil {
-		return fmt.Errorf("failed to create remote refs directory: %w", err)
+// FetchPlan is what a fetch would do to the local refs, worked out from
+// the refs the remote advertises without fetching anything
+type FetchPlan struct {
+	Remote string
+	Refs   []RefPlan
+}
+
+// RefPlan is a local ref a fetch would change
+type RefPlan struct {
+	Ref       string
+	RemoteRef string
+	// OldHash is empty for a ref the fetch would create
+	OldHash string
+	NewHash string
+	// Rejected holds why the update would be refused
+	Rejected string
+	// Ahead and Behind count the commits NewHash has that OldHash lacks
+	// and the other way around. They are only Counted when NewHash is a
+	// commit that is in the repository already, as when another remote
+	// brought it in.
+	Ahead   int
+	Behind  int
+	Counted bool
+}
+
+// refUpdate is a local ref that a fetch refspec maps a remote ref onto
+type refUpdate struct {
+	ref       string
+	remoteRef string
+	oldHash   string
+	newHash   string
+	force     bool
+}
+
+// mapRefs applies the fetch refspecs to the refs the remote advertises,
+// returning the local refs that would change. A remote ref matched by
+// several refspecs is stored under each of their destinations.
+func (p *Puller) mapRefs(remoteRefs map[string]string, refspecs []remote.Refspec) ([]refUpdate, error) {
+	names := make([]string, 0, len(remoteRefs))
+	for name := range remoteRefs {
+		// peeled tags only tell what a tag points at
+		if !strings.HasSuffix(name, peeledSuffix) {
+			names = append(names, name)
+		}
 	}
+	slices.Sort(names)
 
-	for refName, hash := range remoteRefs {
-		if strings.HasPrefix(refName, "refs/heads/") {
-			branchName := strings.TrimPrefix(refName, "refs/heads/")
-			remoteRefPath := filepath.Join(remoteRefsDir, branchName)
+	var updates []refUpdate
+	for _, name := range names {
+		for _, refspec := range refspecs {
+			localRef, ok := refspec.Map(name)
+			if !ok || localRef == "" {
+				continue
+			}
 
-			if err := os.WriteFile(remoteRefPath, []byte(hash+"\n"), defaultFileMode); err != nil {
-				return fmt.Errorf("failed to update remote ref %s: %w", refName, err)
+			// the name comes from the remote, so it is not read until it is
+			// known to stay under refs/; rejection refuses it otherwise
+			var oldHash string
+			if repository.CheckRefName(localRef) == nil {
+				var err error
+				oldHash, err = p.repo.ReadRef(localRef)
+				if err != nil && !stderrors.Is(err, errors.ErrReferenceNotFound) {
+					return nil, err
+				}
+				if oldHash == remoteRefs[name] {
+					continue
+				}
 			}
+
+			updates = append(updates, refUpdate{
+				ref:       localRef,
+				remoteRef: name,
+				oldHash:   oldHash,
+				newHash:   remoteRefs[name],
+				force:     refspec.Force,
+			})
 		}
 	}
+	return updates, nil
+}
 
-	return nil
+// missingObjects returns the tips that are not in the repository yet
+func (p *Puller) missingObjects(tips []string) []string {
+	seen := make(map[string]bool)
+	var wants []string
+	for _, hash := range tips {
+		if seen[hash] || p.repo.HasObject(hash) {
+			continue
+		}
+		seen[hash] = true
+		wants = append(wants, hash)
+	}
+	return wants
 }
 
-func (p *Puller) findMergeBase(commit1, commit2 string) (string, error) {
-	if commit1 == commit2 {
-		return commit1, nil
+// tagUpdates returns the tags that mode fetches and that no refspec maps
+// already
+func (p *Puller) tagUpdates(remoteRefs map[string]string, mode remote.TagMode, updates []refUpdate) ([]refUpdate, error) {
+	mapped := make(map[string]bool)
+	for _, update := range updates {
+		mapped[update.ref] = true
 	}
 
-	ancestors1, err := p.getAncestors(commit1)
-	if err != nil {
-		return "", fmt.Errorf("failed to get ancestors of %s: %w", commit1, err)
+	tags := remote.FollowTags(remoteRefs, mode, p.repo.HasObject)
+	var tagUpdates []refUpdate
+	for _, name := range slices.Sorted(maps.Keys(tags)) {
+		hash := tags[name]
+		if mapped[name] {
+			continue
+		}
+
+		oldHash, err := p.repo.ReadRef(name)
+		if err != nil && !stderrors.Is(err, errors.ErrReferenceNotFound) {
+			return nil, err
+		}
+		if oldHash != hash {
+			tagUpdates = append(tagUpdates, refUpdate{ref: name, remoteRef: name, oldHash: oldHash, newHash: hash})
+		}
 	}
+	return tagUpdates, nil
+}
 
-	ancestors2, err := p.getAncestors(commit2)
+// fetchTags fetches the annotated tag objects that did not come with the
+// fetched history
+func (p *Puller) fetchTags(ctx context.Context, tagUpdates []refUpdate, remoteRefs map[string]string) error {
+	var wants, haves []string
+	for _, update := range tagUpdates {
+		if p.repo.HasObject(update.newHash) {
+			continue
+		}
+		wants = append(wants, update.newHash)
+		// the tagged object is here already, which spares sending it
+		if target, ok := remoteRefs[update.remoteRef+peeledSuffix]; ok {
+			haves = append(haves, target)
+		}
+	}
+	if len(wants) == 0 {
+		return nil
+	}
+	return p.fetchCommits(ctx, wants, haves)
+}
+
+// rejection returns why update is refused, or "" when it may go ahead.
+// Updates that are not fast-forwards, or that would move an existing tag,
+// are refused unless their refspec or force allows them, and the checked-out
+// branch, localRef, is left for the pull itself to move. Whether an update
+// is a fast-forward is only known once its new commit is in the repository.
+func (p *Puller) rejection(update refUpdate, localRef string, force bool) (string, error) {
+	if repository.CheckRefName(update.ref) != nil {
+		return reasonFunnyRefname, nil
+	}
+	if update.ref == localRef {
+		return "refusing to fetch into current branch", nil
+	}
+	if force || update.force || update.oldHash == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(update.ref, tagsPrefix) {
+		return "would clobber existing tag", nil
+	}
+	if !p.repo.HasObject(update.newHash) {
+		return "", nil
+	}
+
+	ancestors, err := mergebase.Ancestors(p.repo, update.newHash)
 	if err != nil {
-		return "", fmt.Errorf("failed to get ancestors of %s: %w", commit2, err)
+		return "", fmt.Errorf("failed to walk history of %s: %w", update.remoteRef, err)
 	}
+	if !ancestors[update.oldHash] {
+		return "non-fast-forward", nil
+	}
+	return "", nil
+}
 
-	for _, ancestor := range ancestors1 {
-		for _, other := range ancestors2 {
-			if ancestor == other {
-				return ancestor, nil
-			}
+// updateRemoteRefs stores the fetched refs that are not rejected
+func (p *Puller) updateRemoteRefs(updates []refUpdate, localRef string, force bool, result *PullResult) error {
+	for _, update := range updates {
+		reason, err := p.rejection(update, localRef, force)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			result.RejectedRefs[update.ref] = reason
+			continue
 		}
+
+		if err := p.repo.UpdateRefWithMessage(update.ref, update.newHash, "pull: storing head"); err != nil {
+			return fmt.Errorf("failed to update remote ref %s: %w", update.remoteRef, err)
+		}
+		result.UpdatedRefs[update.ref] = update.newHash
 	}
 
-	return "", fmt.Errorf("no common ancestor found")
+	return nil
 }
 
-func (p *Puller) getAncestors(commitHash string) ([]string, error) {
-	var ancestors []string
-	visited := make(map[string]bool)
-	queue := []string{commitHash}
-
-	for len(queue) > 0 {
-		current := queue[0]
-		queue = queue[1:]
+// planFetch works out what fetching updates would do to the local refs,
+// without fetching or writing anything
+func (p *Puller) planFetch(remoteName string, updates []refUpdate, localRef string, force bool) (*FetchPlan, error) {
+	plan := &FetchPlan{Remote: remoteName}
+	for _, update := range updates {
+		ref := RefPlan{
+			Ref:       update.ref,
+			RemoteRef: update.remoteRef,
+			OldHash:   update.oldHash,
+			NewHash:   update.newHash,
+		}
 
-		if visited[current] {
-			continue
+		var err error
+		if ref.Rejected, err = p.rejection(update, localRef, force); err != nil {
+			return nil, err
 		}
-		visited[current] = true
-		ancestors = append(ancestors, current)
+		if ref.Ahead, ref.Behind, ref.Counted, err = p.divergence(update.oldHash, update.newHash); err != nil {
+			return nil, err
+		}
+		plan.Refs = append(plan.Refs, ref)
+	}
+	return plan, nil
+}
 
-		obj, err := p.repo.LoadObject(current)
-		if err != nil {
-			continue
+// divergence counts the commits newHash has that oldHash lacks and those
+// oldHash has that newHash lacks. Nothing is counted unless both are
+// commits in the repository, oldHash being empty for a new ref.
+func (p *Puller) divergence(oldHash, newHash string) (int, int, bool, error) {
+	if !p.isCommit(newHash) || (oldHash != "" && !p.isCommit(oldHash)) {
+		return 0, 0, false, nil
+	}
+
+	newAncestors, err := mergebase.Ancestors(p.repo, newHash)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	oldAncestors := map[string]bool{}
+	if oldHash != "" {
+		if oldAncestors, err = mergebase.Ancestors(p.repo, oldHash); err != nil {
+			return 0, 0, false, err
 		}
+	}
 
-		if commit, ok := obj.(*objects.Commit); ok {
-			for _, parent := range commit.Parents() {
-				if !visited[parent] {
-					queue = append(queue, parent)
-				}
-			}
+	ahead, behind := 0, 0
+	for hash := range newAncestors {
+		if !oldAncestors[hash] {
+			ahead++
+		}
+	}
+	for hash := range oldAncestors {
+		if !newAncestors[hash] {
+			behind++
 		}
 	}
+	return ahead, behind, true, nil
+}
 
-	return ancestors, nil
+func (p *Puller) isCommit(hash string) bool {
+	if !p.repo.HasObject(hash) {
+		return false
+	}
+	_, err := p.repo.LoadCommit(hash)
+	return err == nil
 }
 
-func (p *Puller) fastForward(branch, targetCommit string, result *PullResult) error {
-	branchRef := fmt.Sprintf("refs/heads/%s", branch)
-	if err := p.repo.UpdateRef(branchRef, targetCommit); err != nil {
+// findMergeBase returns the single best common ancestor of two commits
+func (p *Puller) findMergeBase(commit1, commit2 string) (string, error) {
+	bases, err := mergebase.Find(p.repo, commit1, commit2)
+	if err != nil {
+		return "", err
+	}
+
+	if len(bases) == 0 {
+		return "", fmt.Errorf("no common ancestor found")
+	}
+
+	return bases[0], nil
+}
+
+// fastForward moves localRef, a branch ref or HEAD, to targetCommit
+func (p *Puller) fastForward(localRef, targetCommit string, result *PullResult) error {
+	if err := p.repo.UpdateRefWithMessage(localRef, targetCommit, "pull: Fast-forward"); err != nil {
 		return fmt.Errorf("failed to update branch ref: %w", err)
 	}
 
-	result.UpdatedRefs[branchRef] = targetCommit
+	result.UpdatedRefs[localRef] = targetCommit
 
 	if err := p.updateWorkingDirectory(targetCommit, result); err != nil {
 		return fmt.Errorf("failed to update working directory: %w", err)
@@ -316,9 +590,14 @@ func (p *Puller) fastForward(branch, targetCommit string, result *PullResult) er
 	return nil
 }
 
-func (p *Puller) performMerge(branch, remoteCommit string, result *PullResult) error {
+// performMerge records a merge of remoteBranch's remoteCommit on localRef, a
+// branch ref or HEAD
+func (p *Puller) performMerge(localRef, remoteBranch, remoteCommit string, result *PullResult) error {
 	localCommit := result.OldCommit
-	mergeMessage := fmt.Sprintf("Merge remote-tracking branch 'origin/%s' into %s", branch, branch)
+	mergeMessage := fmt.Sprintf("Merge remote-tracking branch 'origin/%s'", remoteBranch)
+	if branch, ok := strings.CutPrefix(localRef, "refs/heads/"); ok {
+		mergeMessage += " into " + branch
+	}
 
 	now := time.Now()
 	author := &objects.Signature{
@@ -345,13 +624,12 @@ func (p *Puller) performMerge(branch, remoteCommit string, result *PullResult) e
 		return fmt.Errorf("failed to store merge commit: %w", err)
 	}
 
-	branchRef := fmt.Sprintf("refs/heads/%s", branch)
-	if err := p.repo.UpdateRef(branchRef, mergeCommitHash); err != nil {
+	if err := p.repo.UpdateRefWithMessage(localRef, mergeCommitHash, "pull: Merge made by the 'recursive' strategy."); err != nil {
 		return fmt.Errorf("failed to update branch ref: %w", err)
 	}
 
 	result.MergeCommit = mergeCommitHash
-	result.UpdatedRefs[branchRef] = mergeCommitHash
+	result.UpdatedRefs[localRef] = mergeCommitHash
 
 	if err := p.updateWorkingDirectory(mergeCommitHash, result); err != nil {
 		return fmt.Errorf("failed to update working directory: %w", err)
@@ -371,22 +649,17 @@ func (p *Puller) ensureIndexLoaded() error {
 	return nil
 }
 
-func (p *Puller) performRebase(branch, remoteCommit string, result *PullResult) error {
+func (p *Puller) performRebase(localRef, remoteCommit string, result *PullResult) error {
 	return fmt.Errorf("rebase strategy not implemented yet")
 }
 
 func (p *Puller) createMergeTree(localCommit, remoteCommit string) (string, error) {
-	localObj, err := p.repo.LoadObject(localCommit)
+	commit, err := p.repo.LoadCommit(localCommit)
 	if err != nil {
 		return "", fmt.Errorf("failed to load local commit: %w", err)
 	}
 
-	localCommitObj, ok := localObj.(*objects.Commit)
-	if !ok {
-		return "", fmt.Errorf("local commit is not a commit object")
-	}
-
-	return localCommitObj.Tree(), nil
+	return commit.Tree(), nil
 }
 
 func (p *Puller) updateWorkingDirectory(commitHash string, result *PullResult) error {
@@ -395,26 +668,16 @@ func (p *Puller) updateWorkingDirectory(commitHash string, result *PullResult) e
 		return fmt.Errorf("failed to load index: %w", err)
 	}
 
-	obj, err := p.repo.LoadObject(commitHash)
+	commit, err := p.repo.LoadCommit(commitHash)
 	if err != nil {
 		return fmt.Errorf("failed to load commit: %w", err)
 	}
 
-	commit, ok := obj.(*objects.Commit)
-	if !ok {
-		return fmt.Errorf("object is not a commit")
-	}
-
-	treeObj, err := p.repo.LoadObject(commit.Tree())
+	tree, err := p.repo.LoadTree(commit.Tree())
 	if err != nil {
 		return fmt.Errorf("failed to load tree: %w", err)
 	}
 
-	tree, ok := treeObj.(*objects.Tree)
-	if !ok {
-		return fmt.Errorf("object is not a tree")
-	}
-
 	p.index.Clear()
 
 	updatedFiles, err := p.repo.CheckoutTreeWithIndex(tree, p.index, "")
@@ -429,63 +692,41 @@ func (p *Puller) updateWorkingDirectory(commitHash string, result *PullResult) e
 	return nil
 }
 
-func (p *Puller) checkoutTree(tree *objects.Tree, prefix string, result *PullResult) error {
-	for _, entry := range tree.Entries() {
-		fullPath := filepath.Join(p.repo.WorkDir, prefix, entry.Name)
+func (p *Puller) checkoutTree(treeHash string, result *PullResult) error {
+	return objects.WalkTree(p.repo, treeHash, func(path string, entry objects.TreeEntry) error {
+		fullPath := filepath.Join(p.repo.WorkDir, filepath.FromSlash(path))
+
+		switch {
+		case entry.IsDir():
+			// if subtree object not found, it may not have been included in this pack
+			// This can happen when the subtree hasn't changed and only files were updated
+			// skip this subtree since the working directory files are already correct
+			if !p.repo.HasObject(entry.Hash) {
+				return objects.SkipTree
+			}
 
-		switch entry.Mode {
-		case objects.FileModeTree:
 			if err := os.MkdirAll(fullPath, defaultDirMode); err != nil {
 				return fmt.Errorf("failed to create directory %s: %w", fullPath, err)
 			}
 
-			subTreeObj, err := p.repo.LoadObject(entry.Hash)
-			if err != nil {
-				// if subtree object not found, it may not have been included in this pack
-				// This can happen when the subtree hasn't changed and only files were updated
-				// skip this subtree since the working directory files are already correct
-				continue
-			}
-
-			subTree, ok := subTreeObj.(*objects.Tree)
-			if !ok {
-				return fmt.Errorf("subtree object is not a tree")
-			}
-
-			if err := p.checkoutTree(subTree, filepath.Join(prefix, entry.Name), result); err != nil {
-				return err
-			}
-
-		case objects.FileModeBlob, objects.FileModeExecutable:
-			blobObj, err := p.repo.LoadObject(entry.Hash)
-			if err != nil {
-				// skip files whose blobs can't be loaded (they may not be in the pack)
-				continue
-			}
-
-			blob, ok := blobObj.(*objects.Blob)
-			if !ok {
-				return fmt.Errorf("blob object is not a blob")
+		case entry.Mode == objects.FileModeBlob || entry.Mode == objects.FileModeExecutable || entry.Mode == objects.FileModeSymlink:
+			if !p.repo.HasObject(entry.Hash) {
+				// skip files whose blobs are not in the pack
+				return nil
 			}
 
 			if err := os.MkdirAll(filepath.Dir(fullPath), defaultDirMode); err != nil {
 				return fmt.Errorf("failed to create directory for %s: %w", fullPath, err)
 			}
 
-			mode := os.FileMode(defaultFileMode)
-			if entry.Mode == objects.FileModeExecutable {
-				mode = os.FileMode(executableMode)
-			}
-
-			if err := os.WriteFile(fullPath, blob.Content(), mode); err != nil {
+			if err := p.repo.WriteEntryFile(entry.Hash, fullPath, entry.Mode); err != nil {
 				return fmt.Errorf("failed to write file %s: %w", fullPath, err)
 			}
 
-			result.UpdatedFiles = append(result.UpdatedFiles, filepath.Join(prefix, entry.Name))
+			result.UpdatedFiles = append(result.UpdatedFiles, filepath.FromSlash(path))
 		}
-	}
-
-	return nil
+		return nil
+	})
 }
 
 func DefaultPullOptions() PullOptions {
@@ -496,6 +737,8 @@ func DefaultPullOptions() PullOptions {
 		Force:          false,
 		Prune:          false,
 		Depth:          0,
+		Tags:           remote.TagFollow,
+		DryRun:         false,
 		Timeout:        defaultTimeout,
 	}
 }
@@ -2,14 +2,22 @@ package pull
 
 import (
 	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/remote"
+	gitserver "github.com/unkn0wn-root/git-go/internal/transport/server"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 func TestPullOptions(t *testing.T) {
@@ -37,6 +45,11 @@ func TestPuller(t *testing.T) {
 		assert.NotNil(t, puller.auth)
 	})
 
+	t.Run("WithNilProgress", func(t *testing.T) {
+		puller := NewPuller(repository.New(tempDir)).WithProgress(nil)
+		assert.NotNil(t, puller.progress)
+	})
+
 	t.Run("PullWithoutRepository", func(t *testing.T) {
 		repo := repository.New(tempDir)
 		puller := NewPuller(repo)
@@ -137,3 +150,289 @@ func TestPullerIntegration(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+// storeCommit stores a commit of a single file holding message
+func storeCommit(t *testing.T, repo *repository.Repository, message string, parents ...string) string {
+	t.Helper()
+	blob, err := repo.StoreObject(objects.NewBlob([]byte(message + "\n")))
+	require.NoError(t, err)
+	tree, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "file.txt", Hash: blob}}))
+	require.NoError(t, err)
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+	hash, err := repo.StoreObject(objects.NewCommit(tree, parents, sig, sig, message+"\n"))
+	require.NoError(t, err)
+	return hash
+}
+
+// newUpstream serves a repository over smart HTTP, returning it along with
+// its URL and a function committing onto it
+func newUpstream(t *testing.T) (*repository.Repository, string, func(message string, parents ...string) string) {
+	t.Helper()
+	upstream := repository.New(t.TempDir())
+	require.NoError(t, upstream.Init())
+	commit := func(message string, parents ...string) string {
+		t.Helper()
+		return storeCommit(t, upstream, message, parents...)
+	}
+
+	server := httptest.NewServer(http.StripPrefix("/repo.git", gitserver.UploadPackHandler(upstream)))
+	t.Cleanup(server.Close)
+	return upstream, server.URL + "/repo.git", commit
+}
+
+func TestPullFetchRefspecs(t *testing.T) {
+	upstream, url, commit := newUpstream(t)
+	main := commit("main")
+	require.NoError(t, upstream.UpdateRef("refs/heads/main", main))
+	feature := commit("feature", main)
+	require.NoError(t, upstream.UpdateRef("refs/heads/feature", feature))
+
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+	config := `[remote "origin"]
+	url = ` + url + `
+	fetch = +refs/heads/*:refs/upstream/origin/*
+	fetch = refs/heads/feature:refs/mirror/feature
+`
+	require.NoError(t, os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte(config), 0644))
+
+	opts := DefaultPullOptions()
+	opts.Branch = "main"
+	result, err := NewPuller(repo).Pull(context.Background(), opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"refs/upstream/origin/main":    main,
+		"refs/upstream/origin/feature": feature,
+		"refs/mirror/feature":          feature,
+		"refs/heads/main":              main,
+	}, result.UpdatedRefs)
+	assert.Empty(t, result.RejectedRefs)
+	// the branches not pulled are fetched along with the one that is
+	assert.True(t, repo.HasObject(feature))
+	_, err = repo.ReadRef("refs/remotes/origin/main")
+	assert.ErrorIs(t, err, errors.ErrReferenceNotFound)
+
+	// rewriting feature upstream is taken by the forced refspec only
+	rewritten := commit("rewritten", main)
+	require.NoError(t, upstream.UpdateRef("refs/heads/feature", rewritten))
+
+	result, err = NewPuller(repo).Pull(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"refs/upstream/origin/feature": rewritten}, result.UpdatedRefs)
+	assert.Equal(t, map[string]string{"refs/mirror/feature": "non-fast-forward"}, result.RejectedRefs)
+	mirrored, err := repo.ReadRef("refs/mirror/feature")
+	require.NoError(t, err)
+	assert.Equal(t, feature, mirrored)
+
+	// force overrides a refspec without "+"
+	opts.Force = true
+	result, err = NewPuller(repo).Pull(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"refs/mirror/feature": rewritten}, result.UpdatedRefs)
+}
+
+func TestPullInvalidRefspec(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+	_, url, _ := newUpstream(t)
+	config := "[remote \"origin\"]\n\turl = " + url + "\n\tfetch = refs/heads/*:refs/remotes/origin/main\n"
+	require.NoError(t, os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte(config), 0644))
+
+	opts := DefaultPullOptions()
+	opts.Branch = "main"
+	_, err := NewPuller(repo).Pull(context.Background(), opts)
+	assert.ErrorIs(t, err, errors.ErrInvalidRefspec)
+}
+
+func TestPullTags(t *testing.T) {
+	upstream, url, commit := newUpstream(t)
+	first := commit("first")
+	second := commit("second", first)
+	// a commit no branch leads to
+	stray := commit("stray", first)
+	require.NoError(t, upstream.UpdateRef("refs/heads/main", second))
+
+	annotate := func(name, target string) string {
+		tag, err := upstream.StoreRawObject(objects.ObjectTypeTag, []byte(
+			"object "+target+"\ntype commit\ntag "+name+"\ntagger Test <test@example.com> 1700000000 +0000\n\n"+name+"\n"))
+		require.NoError(t, err)
+		require.NoError(t, upstream.UpdateRef("refs/tags/"+name, tag))
+		return tag
+	}
+	v1 := annotate("v1", first)
+	strayTag := annotate("stray-annotated", stray)
+	require.NoError(t, upstream.UpdateRef("refs/tags/v2", second))
+	require.NoError(t, upstream.UpdateRef("refs/tags/stray-light", stray))
+
+	pull := func(mode remote.TagMode) (*repository.Repository, *PullResult) {
+		t.Helper()
+		repo := repository.New(t.TempDir())
+		require.NoError(t, repo.Init())
+		config := "[remote \"origin\"]\n\turl = " + url + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(repo.GitDir, "config"), []byte(config), 0644))
+
+		opts := DefaultPullOptions()
+		opts.Branch = "main"
+		opts.Tags = mode
+		result, err := NewPuller(repo).Pull(context.Background(), opts)
+		require.NoError(t, err)
+		return repo, result
+	}
+	tagRefs := func(result *PullResult) map[string]string {
+		tags := make(map[string]string)
+		for ref, hash := range result.UpdatedRefs {
+			if strings.HasPrefix(ref, "refs/tags/") {
+				tags[ref] = hash
+			}
+		}
+		return tags
+	}
+
+	// only the tags on the pulled history are followed
+	repo, result := pull(remote.TagFollow)
+	assert.Equal(t, map[string]string{"refs/tags/v1": v1, "refs/tags/v2": second}, tagRefs(result))
+	stored, err := repo.ReadRef("refs/tags/v1")
+	require.NoError(t, err)
+	assert.Equal(t, v1, stored)
+	assert.True(t, repo.HasObject(v1))
+	assert.False(t, repo.HasObject(stray))
+
+	repo, result = pull(remote.TagAll)
+	assert.Equal(t, map[string]string{
+		"refs/tags/v1":              v1,
+		"refs/tags/v2":              second,
+		"refs/tags/stray-annotated": strayTag,
+		"refs/tags/stray-light":     stray,
+	}, tagRefs(result))
+	assert.True(t, repo.HasObject(strayTag))
+	assert.True(t, repo.HasObject(stray))
+
+	_, result = pull(remote.TagNone)
+	assert.Empty(t, tagRefs(result))
+}
+
+// mockTransport advertises refs and fails any attempt to fetch from or push
+// to it
+type mockTransport struct {
+	refs    map[string]string
+	fetched bool
+}
+
+func (m *mockTransport) Connect(ctx context.Context, url string) error { return nil }
+func (m *mockTransport) Disconnect() error                             { return nil }
+func (m *mockTransport) Close() error                                  { return nil }
+
+func (m *mockTransport) ListRefs(ctx context.Context) (map[string]string, error) {
+	return m.refs, nil
+}
+
+func (m *mockTransport) FetchPack(ctx context.Context, wants, haves []string) (remote.PackReader, error) {
+	m.fetched = true
+	return nil, stderrors.New("unexpected fetch")
+}
+
+func (m *mockTransport) SendPack(ctx context.Context, refs map[string]remote.RefUpdate, packData []byte) (*remote.PushReport, error) {
+	return nil, stderrors.New("unexpected push")
+}
+
+func TestPullDryRun(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+	base := storeCommit(t, repo, "base")
+	// known came in from elsewhere; side has diverged from it
+	known := storeCommit(t, repo, "known", base)
+	side := storeCommit(t, repo, "side", base)
+	require.NoError(t, repo.UpdateRef("refs/remotes/origin/main", base))
+	require.NoError(t, repo.UpdateRef("refs/remotes/origin/known", base))
+	require.NoError(t, repo.UpdateRef("refs/mirror/known", side))
+
+	unknown := strings.Repeat("1", 40)
+	created := strings.Repeat("2", 40)
+	transport := &mockTransport{refs: map[string]string{
+		"HEAD":               unknown,
+		"refs/heads/main":    unknown,
+		"refs/heads/known":   known,
+		"refs/heads/new":     created,
+		"refs/tags/v1":       known,
+		"refs/tags/unknown":  strings.Repeat("3", 40),
+		"refs/tags/v1-light": base,
+	}}
+	require.NoError(t, repo.UpdateRef("refs/tags/v1-light", base))
+
+	countObjects := func() int {
+		count := 0
+		require.NoError(t, repo.ForEachObject(func(string, objects.ObjectType) error {
+			count++
+			return nil
+		}))
+		return count
+	}
+	objectsBefore := countObjects()
+	refsBefore, err := repo.ListRefs()
+	require.NoError(t, err)
+
+	remoteConfig := &remote.Remote{Name: "origin", Fetch: []string{
+		"+refs/heads/*:refs/remotes/origin/*",
+		"refs/heads/known:refs/mirror/known",
+	}}
+	refspecs, err := remoteConfig.FetchRefspecs()
+	require.NoError(t, err)
+	opts := DefaultPullOptions()
+	opts.Branch = "main"
+	opts.DryRun = true
+	result, err := NewPuller(repo).pull(context.Background(), transport, remoteConfig, refspecs, opts)
+	require.NoError(t, err)
+
+	require.NotNil(t, result.Plan)
+	assert.Equal(t, "origin", result.Plan.Remote)
+	assert.Equal(t, []RefPlan{
+		{Ref: "refs/remotes/origin/known", RemoteRef: "refs/heads/known", OldHash: base, NewHash: known, Ahead: 1, Counted: true},
+		{Ref: "refs/mirror/known", RemoteRef: "refs/heads/known", OldHash: side, NewHash: known, Rejected: "non-fast-forward", Ahead: 1, Behind: 1, Counted: true},
+		{Ref: "refs/remotes/origin/main", RemoteRef: "refs/heads/main", OldHash: base, NewHash: unknown},
+		{Ref: "refs/remotes/origin/new", RemoteRef: "refs/heads/new", NewHash: created},
+		// only the tag on history that is here already can be followed
+		{Ref: "refs/tags/v1", RemoteRef: "refs/tags/v1", NewHash: known, Ahead: 2, Counted: true},
+	}, result.Plan.Refs)
+
+	assert.False(t, transport.fetched)
+	assert.Equal(t, objectsBefore, countObjects())
+	refsAfter, err := repo.ListRefs()
+	require.NoError(t, err)
+	assert.Equal(t, refsBefore, refsAfter)
+	assert.Empty(t, result.UpdatedRefs)
+}
+
+func TestPullRejectsFunnyRefnames(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+	commit := storeCommit(t, repo, "base")
+	require.NoError(t, repo.UpdateRef("refs/heads/main", commit))
+
+	transport := &mockTransport{refs: map[string]string{
+		"refs/heads/main":           commit,
+		"refs/heads/../../../../x":  commit,
+		"refs/heads/feature/.hide":  commit,
+		"refs/heads/feature/branch": commit,
+	}}
+	remoteConfig := &remote.Remote{Name: "origin"}
+	refspecs, err := remoteConfig.FetchRefspecs()
+	require.NoError(t, err)
+
+	opts := DefaultPullOptions()
+	opts.Branch = "main"
+	result, err := NewPuller(repo).pull(context.Background(), transport, remoteConfig, refspecs, opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"refs/remotes/origin/../../../../x": "funny refname",
+		"refs/remotes/origin/feature/.hide": "funny refname",
+	}, result.RejectedRefs)
+	assert.Equal(t, map[string]string{
+		"refs/remotes/origin/main":           commit,
+		"refs/remotes/origin/feature/branch": commit,
+	}, result.UpdatedRefs)
+	assert.NoFileExists(t, filepath.Join(repo.GitDir, "..", "x"))
+	assert.NoFileExists(t, filepath.Join(repo.GitDir, "x"))
+	assert.False(t, transport.fetched)
+}
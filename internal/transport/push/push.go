@@ -1,46 +1,43 @@
 package push
 
 import (
-	"bytes"
-	"compress/zlib"
 	"context"
-	"crypto/sha1"
-	"encoding/binary"
+	stderrors "errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/commands/branch"
+	"github.com/unkn0wn-root/git-go/internal/core/config"
+	"github.com/unkn0wn-root/git-go/internal/core/hooks"
+	"github.com/unkn0wn-root/git-go/internal/core/mergebase"
 	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
 	"github.com/unkn0wn-root/git-go/internal/transport/remote"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 const (
 	defaultRemote   = "origin"
 	defaultTimeout  = 2 * time.Minute
-	defaultFileMode = 0644
 	shortHashLength = 7
+	maxTreeWorkers  = 8
 
-	// Pack file
-	packSignature = "PACK"
-	packVersion   = 2
-
-	// Git object types for pack format
-	OBJ_COMMIT = 1
-	OBJ_TREE   = 2
-	OBJ_BLOB   = 3
-
-	sizeMask        = 0xF
-	typeBits        = 4
-	continuationBit = 0x80
-	sevenBitMask    = 0x7F
-
-	headsPrefix = "refs/heads/"
-	tagsPrefix  = "refs/tags/"
+	headsPrefix   = "refs/heads/"
+	tagsPrefix    = "refs/tags/"
+	remotesPrefix = "refs/remotes/"
 )
 
+// StaleInfo is the rejection reason when a lease no longer holds
+const StaleInfo = "stale info"
+
 type RefUpdateStatus int
 
 const (
@@ -50,16 +47,26 @@ const (
 	RefUpdateUpToDate
 	RefUpdateFastForward
 	RefUpdateForced
+	RefUpdateDeleted
 )
 
 type PushOptions struct {
-	Remote         string
-	Branch         string
-	Force          bool
+	Remote string
+	Branch string
+	Force  bool
+	// Delete removes Branch from the remote instead of updating it
+	Delete bool
+	// ForceWithLease forces the push only while the remote branch is where
+	// we expect: at LeaseHash when it is set, otherwise where the
+	// remote-tracking ref says it was last seen
+	ForceWithLease bool
+	LeaseHash      string
 	SetUpstream    bool
 	PushAll        bool
 	PushTags       bool
 	DryRun         bool
+	// SkipHooks pushes without running the pre-push hook
+	SkipHooks      bool
 	Timeout        time.Duration
 	ProgressWriter *os.File
 }
@@ -77,6 +84,8 @@ type PushResult struct {
 	UpstreamSet   bool
 	PushedObjects int
 	PushedSize    int64
+	// HookOutput is what the pre-push hook printed
+	HookOutput string
 }
 
 type RefUpdateResult struct {
@@ -101,75 +110,103 @@ func (s RefUpdateStatus) String() string {
 		return "fast-forward"
 	case RefUpdateForced:
 		return "forced"
+	case RefUpdateDeleted:
+		return "deleted"
 	default:
 		return "unknown"
 	}
 }
 
 type Pusher struct {
-	repo      *repository.Repository
-	transport remote.Transport
-	auth      *remote.AuthConfig
+	repo        *repository.Repository
+	transport   remote.Transport
+	pushURL     string
+	auth        *remote.AuthConfig
+	treeWorkers int
+	progress    pack.Progress
 }
 
 func NewPusher(repo *repository.Repository) *Pusher {
-	auth, _ := remote.LoadAuthConfig()
+	auth, _ := remote.LoadRepoAuthConfig(repo.GitDir)
 	return &Pusher{
-		repo: repo,
-		auth: auth,
+		repo:        repo,
+		auth:        auth,
+		treeWorkers: maxTreeWorkers,
+		progress:    pack.NoProgress,
 	}
 }
 
-func (p *Pusher) Push(ctx context.Context, options PushOptions) (*PushResult, error) {
-	if options.Remote == "" {
-		options.Remote = defaultRemote
-	}
-
-	if options.Timeout == 0 {
-		options.Timeout = defaultTimeout
+// WithProgress reports the push to progress. It returns p for chaining.
+func (p *Pusher) WithProgress(progress pack.Progress) *Pusher {
+	if progress == nil {
+		progress = pack.NoProgress
 	}
+	p.progress = progress
+	return p
+}
 
-	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
-	defer cancel()
-
+// connect opens a transport to the named remote's push URL
+func (p *Pusher) connect(ctx context.Context, remoteName string) (remote.Transport, error) {
 	rc := remote.NewRemoteConfig(p.repo.GitDir)
 	if err := rc.Load(); err != nil {
 		return nil, fmt.Errorf("failed to load remote config: %w", err)
 	}
 
-	remoteConfig, err := rc.GetRemote(options.Remote)
+	remoteConfig, err := rc.GetRemote(remoteName)
 	if err != nil {
-		return nil, fmt.Errorf("remote '%s' not found: %w", options.Remote, err)
+		return nil, fmt.Errorf("remote '%s' not found: %w", remoteName, err)
 	}
 
-	transport, err := remote.CreateTransport(remoteConfig.PushURL, p.auth)
+	transport, err := remote.CreateTransport(remoteConfig.PushURL, p.auth.ForRemote(remoteConfig))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transport: %w", err)
 	}
-	defer transport.Close()
-
-	p.transport = transport
 
 	if err := transport.Connect(ctx, remoteConfig.PushURL); err != nil {
+		transport.Close()
 		return nil, fmt.Errorf("failed to connect to remote: %w", err)
 	}
 
-	currentBranch, err := p.repo.GetCurrentBranch()
+	p.transport = transport
+	p.pushURL = remoteConfig.PushURL
+	return transport, nil
+}
+
+func (p *Pusher) Push(ctx context.Context, options PushOptions) (*PushResult, error) {
+	if options.Remote == "" {
+		options.Remote = defaultRemote
+	}
+
+	if options.Timeout == 0 {
+		options.Timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	transport, err := p.connect(ctx, options.Remote)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current branch: %w", err)
+		return nil, err
+	}
+	defer transport.Close()
+
+	if options.Delete {
+		return p.deleteBranch(ctx, transport, options)
 	}
 
 	if options.Branch == "" {
+		// a detached HEAD has no branch to push to by default
+		currentBranch, err := p.repo.GetCurrentBranch()
+		if err != nil {
+			return nil, fmt.Errorf("not currently on a branch; name the branch to push")
+		}
 		options.Branch = currentBranch
 	}
 
-	localCommit, err := p.repo.GetHead()
+	// the named branch is pushed, whichever branch HEAD is on
+	localCommit, err := p.repo.ReadRef(headsPrefix + options.Branch)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get local HEAD: %w", err)
-	}
-
-	if localCommit == "" {
-		return nil, fmt.Errorf("no commits to push")
+		return nil, fmt.Errorf("src refspec %s does not match any: %w", options.Branch, err)
 	}
 
 	result := &PushResult{
@@ -206,6 +243,19 @@ func (p *Pusher) Push(ctx context.Context, options PushOptions) (*PushResult, er
 		}
 	}
 
+	if options.ForceWithLease {
+		expected, err := p.leaseHash(options)
+		if err != nil {
+			return nil, err
+		}
+		if remoteCommit != expected {
+			result.RejectedRefs[remoteBranchRef] = StaleInfo
+			return result, fmt.Errorf("%w: %s has moved: expected %s, remote has %s",
+				errors.ErrPushRejected, remoteBranchRef, describeHash(expected), describeHash(remoteCommit))
+		}
+		options.Force = true
+	}
+
 	if !options.Force && exists {
 		canFastForward, err := p.canFastForward(remoteCommit, localCommit)
 		if err != nil {
@@ -224,14 +274,22 @@ func (p *Pusher) Push(ctx context.Context, options PushOptions) (*PushResult, er
 		result.Forced = true
 	}
 
+	hookLine := prePushLine(headsPrefix+options.Branch, localCommit, remoteBranchRef, result.OldCommit)
+	if err := p.runPrePush(options, result, []string{hookLine}); err != nil {
+		return result, err
+	}
+
 	if options.DryRun {
 		return result, nil
 	}
 
-	objectsToSend, err := p.getObjectsToSend(localCommit, remoteCommit)
+	// every advertised tip is something the remote has, not just this branch
+	remotes := slices.Compact(slices.Sorted(maps.Values(remoteRefs)))
+	objectsToSend, err := p.objectsToSend([]string{localCommit}, remotes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get objects to send: %w", err)
 	}
+	p.progress.Update("Counting objects", len(objectsToSend), len(objectsToSend))
 
 	refUpdates := map[string]remote.RefUpdate{
 		remoteBranchRef: {
@@ -241,28 +299,37 @@ func (p *Pusher) Push(ctx context.Context, options PushOptions) (*PushResult, er
 		},
 	}
 
+	var report *remote.PushReport
 	if len(objectsToSend) > 0 {
 		result.PushedObjects = len(objectsToSend)
 
 		// Create pack data and send with refs
-		packData, err := p.createPackFile(objectsToSend)
+		packData, _, err := pack.BuildPackWithProgress(p.repo, objectsToSend, p.progress)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create pack file: %w", err)
 		}
 
 		result.PushedSize = int64(len(packData))
-		fmt.Printf("Pushing %d objects (%d bytes)\n", len(objectsToSend), len(packData))
 
-		if err := transport.SendPack(ctx, refUpdates, packData); err != nil {
+		if report, err = transport.SendPack(ctx, refUpdates, packData); err != nil {
 			return nil, fmt.Errorf("failed to send pack with data: %w", err)
 		}
 	} else {
 		// No objects to send, just update refs
-		if err := transport.SendPack(ctx, refUpdates, nil); err != nil {
+		if report, err = transport.SendPack(ctx, refUpdates, nil); err != nil {
 			return nil, fmt.Errorf("failed to send pack: %w", err)
 		}
 	}
 
+	// the server has the final say, whatever was checked locally
+	if report.UnpackError != "" {
+		return nil, fmt.Errorf("%w: remote failed to unpack objects: %s", errors.ErrPushRejected, report.UnpackError)
+	}
+	if reason, rejected := report.Rejected[remoteBranchRef]; rejected {
+		result.RejectedRefs[remoteBranchRef] = reason
+		return result, fmt.Errorf("%w: %s (%s)", errors.ErrPushRejected, remoteBranchRef, reason)
+	}
+
 	status := RefUpdateOK
 	if result.FastForward {
 		status = RefUpdateFastForward
@@ -278,6 +345,13 @@ func (p *Pusher) Push(ctx context.Context, options PushOptions) (*PushResult, er
 		Message: p.getUpdateMessage(result),
 	}
 
+	// the remote-tracking ref records what the remote now has, which is
+	// also what the next lease expects
+	trackingRef := fmt.Sprintf("%s%s/%s", remotesPrefix, options.Remote, options.Branch)
+	if err := p.repo.UpdateRef(trackingRef, localCommit); err != nil {
+		return nil, fmt.Errorf("failed to update %s: %w", trackingRef, err)
+	}
+
 	if options.SetUpstream {
 		if err := p.setUpstream(options.Branch, options.Remote); err != nil {
 			return nil, fmt.Errorf("failed to set upstream: %w", err)
@@ -288,6 +362,129 @@ func (p *Pusher) Push(ctx context.Context, options PushOptions) (*PushResult, er
 	return result, nil
 }
 
+// deleteBranch removes options.Branch from the remote. There is nothing
+// to fast-forward, so only the server's report decides whether it went.
+func (p *Pusher) deleteBranch(ctx context.Context, transport remote.Transport, options PushOptions) (*PushResult, error) {
+	if options.Branch == "" {
+		return nil, fmt.Errorf("no branch given to delete")
+	}
+
+	result := &PushResult{
+		Remote:       options.Remote,
+		Branch:       options.Branch,
+		UpdatedRefs:  make(map[string]RefUpdateResult),
+		RejectedRefs: make(map[string]string),
+	}
+
+	remoteRefs, err := transport.ListRefs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote refs: %w", err)
+	}
+
+	remoteBranchRef := headsPrefix + options.Branch
+	oldHash, exists := remoteRefs[remoteBranchRef]
+	if !exists {
+		return nil, errors.NewGitError("push", remoteBranchRef, errors.ErrReferenceNotFound)
+	}
+	result.OldCommit = oldHash
+
+	hookLine := prePushLine("(delete)", remote.ZeroHash, remoteBranchRef, oldHash)
+	if err := p.runPrePush(options, result, []string{hookLine}); err != nil {
+		return result, err
+	}
+
+	if options.DryRun {
+		return result, nil
+	}
+
+	report, err := transport.SendPack(ctx, map[string]remote.RefUpdate{
+		remoteBranchRef: {RefName: remoteBranchRef, OldHash: oldHash, NewHash: remote.ZeroHash},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send ref deletion: %w", err)
+	}
+	if reason, rejected := report.Rejected[remoteBranchRef]; rejected {
+		result.RejectedRefs[remoteBranchRef] = reason
+		return result, fmt.Errorf("%w: %s (%s)", errors.ErrPushRejected, remoteBranchRef, reason)
+	}
+	if !slices.Contains(report.Updated, remoteBranchRef) {
+		return nil, fmt.Errorf("remote did not confirm deleting %s", remoteBranchRef)
+	}
+
+	result.UpdatedRefs[remoteBranchRef] = RefUpdateResult{
+		RefName: remoteBranchRef,
+		OldHash: oldHash,
+		Status:  RefUpdateDeleted,
+		Message: "deleted",
+	}
+
+	// DeleteRef also drops a packed tracking ref and its reflog
+	trackingRef := fmt.Sprintf("%s%s/%s", remotesPrefix, options.Remote, options.Branch)
+	trackingHash, err := p.repo.ReadRef(trackingRef)
+	if stderrors.Is(err, errors.ErrReferenceNotFound) {
+		return result, nil
+	}
+	if err == nil {
+		err = p.repo.DeleteRef(trackingRef, trackingHash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove remote-tracking ref: %w", err)
+	}
+
+	return result, nil
+}
+
+// leaseHash returns where the remote branch is expected to be for a push
+// with a lease; empty means it is expected not to exist
+func (p *Pusher) leaseHash(options PushOptions) (string, error) {
+	if options.LeaseHash != "" {
+		return p.repo.ResolveRef(options.LeaseHash)
+	}
+
+	trackingRef := fmt.Sprintf("%s%s/%s", remotesPrefix, options.Remote, options.Branch)
+	expected, err := p.repo.ReadRef(trackingRef)
+	if stderrors.Is(err, errors.ErrReferenceNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", trackingRef, err)
+	}
+	return expected, nil
+}
+
+// runPrePush runs the pre-push hook with the remote's name and URL as
+// arguments and lines on stdin, keeping what it prints in result. A hook
+// that fails stops the push before anything is sent.
+func (p *Pusher) runPrePush(options PushOptions, result *PushResult, lines []string) error {
+	if options.SkipHooks || len(lines) == 0 {
+		return nil
+	}
+
+	output, err := hooks.Run(p.repo, hooks.PrePush, strings.NewReader(strings.Join(lines, "")), options.Remote, p.pushURL)
+	result.HookOutput = output
+	if err != nil {
+		return errors.NewGitError("push", "", err)
+	}
+	return nil
+}
+
+// prePushLine is the pre-push hook's input line for one ref update, with a
+// missing ref on either side given as the zero hash
+func prePushLine(localRef, localHash, remoteRef, remoteHash string) string {
+	if remoteHash == "" {
+		remoteHash = remote.ZeroHash
+	}
+	return fmt.Sprintf("%s %s %s %s\n", localRef, localHash, remoteRef, remoteHash)
+}
+
+// describeHash shortens a hash for messages, naming a missing ref
+func describeHash(hash string) string {
+	if hash == "" {
+		return "no branch"
+	}
+	return hash[:min(len(hash), shortHashLength)]
+}
+
 func (p *Pusher) canFastForward(remoteCommit, localCommit string) (bool, error) {
 	if remoteCommit == "" {
 		return true, nil
@@ -301,30 +498,18 @@ func (p *Pusher) canFastForward(remoteCommit, localCommit string) (bool, error)
 	return mergeBase == remoteCommit, nil
 }
 
+// findMergeBase returns the single best common ancestor of two commits
 func (p *Pusher) findMergeBase(commit1, commit2 string) (string, error) {
-	if commit1 == commit2 {
-		return commit1, nil
-	}
-
-	ancestors1, err := p.getAncestors(commit1)
+	bases, err := mergebase.Find(p.repo, commit1, commit2)
 	if err != nil {
-		return "", fmt.Errorf("failed to get ancestors of %s: %w", commit1, err)
+		return "", err
 	}
 
-	ancestors2, err := p.getAncestors(commit2)
-	if err != nil {
-		return "", fmt.Errorf("failed to get ancestors of %s: %w", commit2, err)
+	if len(bases) == 0 {
+		return "", fmt.Errorf("no common ancestor found")
 	}
 
-	for _, ancestor := range ancestors1 {
-		for _, other := range ancestors2 {
-			if ancestor == other {
-				return ancestor, nil
-			}
-		}
-	}
-
-	return "", fmt.Errorf("no common ancestor found")
+	return bases[0], nil
 }
 
 func (p *Pusher) getAncestors(commitHash string) ([]string, error) {
@@ -342,16 +527,14 @@ func (p *Pusher) getAncestors(commitHash string) ([]string, error) {
 		visited[current] = true
 		ancestors = append(ancestors, current)
 
-		obj, err := p.repo.LoadObject(current)
+		commit, err := p.repo.LoadCommit(current)
 		if err != nil {
 			continue
 		}
 
-		if commit, ok := obj.(*objects.Commit); ok {
-			for _, parent := range commit.Parents() {
-				if !visited[parent] {
-					queue = append(queue, parent)
-				}
+		for _, parent := range commit.Parents() {
+			if !visited[parent] {
+				queue = append(queue, parent)
 			}
 		}
 	}
@@ -359,11 +542,29 @@ func (p *Pusher) getAncestors(commitHash string) ([]string, error) {
 	return ancestors, nil
 }
 
+// getObjectsToSend returns the commits reachable from localCommit but not from
+// remoteCommit, in traversal order, followed by the trees and blobs under
+// those commits that remoteCommit lacks, in sorted order. Trees are loaded
+// concurrently.
 func (p *Pusher) getObjectsToSend(localCommit, remoteCommit string) ([]string, error) {
-	var objectsToSend []string
+	var remotes []string
+	if remoteCommit != "" {
+		remotes = []string{remoteCommit}
+	}
+	return p.objectsToSend([]string{localCommit}, remotes)
+}
+
+// objectsToSend is getObjectsToSend for several local commits and every
+// commit the remote is known to have. Each object appears once however many
+// local commits reach it, and trees and blobs the remote already holds
+// under its tips, or under the commits where new history meets its own, are
+// left out.
+func (p *Pusher) objectsToSend(localCommits, remoteCommits []string) ([]string, error) {
+	var commits, rootTrees, haveTrees []string
 	visited := make(map[string]bool)
+	remoteHas := make(map[string]bool)
 
-	if remoteCommit != "" {
+	for _, remoteCommit := range remoteCommits {
 		remoteObjects, err := p.getAncestors(remoteCommit)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get remote ancestors: %w", err)
@@ -371,10 +572,13 @@ func (p *Pusher) getObjectsToSend(localCommit, remoteCommit string) ([]string, e
 
 		for _, obj := range remoteObjects {
 			visited[obj] = true
+			remoteHas[obj] = true
 		}
 	}
 
-	queue := []string{localCommit}
+	// the remote's tips and the boundary commits it shares with us
+	boundary := slices.Clone(remoteCommits)
+	queue := slices.Clone(localCommits)
 
 	for len(queue) > 0 {
 		current := queue[0]
@@ -384,162 +588,124 @@ func (p *Pusher) getObjectsToSend(localCommit, remoteCommit string) ([]string, e
 			continue
 		}
 		visited[current] = true
-		objectsToSend = append(objectsToSend, current)
+		commits = append(commits, current)
+		// how many objects there are is only known once the trees are walked
+		p.progress.Update("Counting objects", len(commits), 0)
 
-		obj, err := p.repo.LoadObject(current)
+		commit, err := p.repo.LoadCommit(current)
 		if err != nil {
 			continue
 		}
 
-		switch o := obj.(type) {
-		case *objects.Commit:
-			objectsToSend = append(objectsToSend, o.Tree())
-
-			treeObj, err := p.repo.LoadObject(o.Tree())
-			if err == nil {
-				if tree, ok := treeObj.(*objects.Tree); ok {
-					p.collectTreeObjects(tree, &objectsToSend, visited)
-				}
-			}
-
-			for _, parent := range o.Parents() {
-				if !visited[parent] {
-					queue = append(queue, parent)
-				}
+		rootTrees = append(rootTrees, commit.Tree())
+		for _, parent := range commit.Parents() {
+			if remoteHas[parent] {
+				boundary = append(boundary, parent)
+			} else if !visited[parent] {
+				queue = append(queue, parent)
 			}
 		}
 	}
 
-	return objectsToSend, nil
-}
-
-func (p *Pusher) collectTreeObjects(tree *objects.Tree, objectsToSend *[]string, visited map[string]bool) {
-	for _, entry := range tree.Entries() {
-		if visited[entry.Hash] {
-			continue
-		}
-		visited[entry.Hash] = true
-		*objectsToSend = append(*objectsToSend, entry.Hash)
-
-		if entry.Mode == objects.FileModeTree {
-			subTreeObj, err := p.repo.LoadObject(entry.Hash)
-			if err == nil {
-				if subTree, ok := subTreeObj.(*objects.Tree); ok {
-					p.collectTreeObjects(subTree, objectsToSend, visited)
-				}
-			}
+	for _, hash := range boundary {
+		if commit, err := p.repo.LoadCommit(hash); err == nil {
+			haveTrees = append(haveTrees, commit.Tree())
 		}
 	}
-}
-
-func (p *Pusher) createPackFile(objectHashes []string) ([]byte, error) {
-	var packBuffer bytes.Buffer
-	// write pack header: "PACK" + version + object count
-	packBuffer.WriteString(packSignature)
-	binary.Write(&packBuffer, binary.BigEndian, uint32(packVersion))
-	binary.Write(&packBuffer, binary.BigEndian, uint32(len(objectHashes)))
-
-	for _, hash := range objectHashes {
-		objData, err := p.createPackObject(hash)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create pack object %s: %w", hash, err)
-		}
-		packBuffer.Write(objData)
+	have := make(map[string]bool)
+	for _, hash := range p.collectTreeObjects(haveTrees) {
+		have[hash] = true
 	}
 
-	// calculate and append SHA-1 checksum of pack data
-	h := sha1.New()
-	h.Write(packBuffer.Bytes())
-	checksum := h.Sum(nil)
-	packBuffer.Write(checksum)
+	treeObjects := slices.DeleteFunc(p.collectTreeObjects(rootTrees), func(hash string) bool { return have[hash] })
+	sort.Strings(treeObjects)
 
-	return packBuffer.Bytes(), nil
+	return append(commits, treeObjects...), nil
 }
 
-func (p *Pusher) createPackObject(hash string) ([]byte, error) {
-	obj, err := p.repo.LoadObject(hash)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load object %s: %w", hash, err)
-	}
-
-	var objType int
-	var objData []byte
-
-	// determine object type and get raw data
-	switch o := obj.(type) {
-	case *objects.Blob:
-		objType = OBJ_BLOB
-		objData = o.Content()
-	case *objects.Tree:
-		objType = OBJ_TREE
-		objData = o.Data()
-	case *objects.Commit:
-		objType = OBJ_COMMIT
-		objData = o.Data()
-	default:
-		return nil, fmt.Errorf("unsupported object type for %s", hash)
-	}
+// treeWalker collects every object under a set of trees, loading subtrees
+// from a bounded number of goroutines at a time
+type treeWalker struct {
+	repo    *repository.Repository
+	sem     chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	visited map[string]bool
+	found   []string
+}
 
-	header := p.createObjectHeader(objType, int64(len(objData)))
+// collectTreeObjects returns the given trees and every tree and blob beneath them
+func (p *Pusher) collectTreeObjects(rootTrees []string) []string {
+	workers := p.treeWorkers
+	if workers < 1 {
+		workers = 1
+	}
 
-	var compressed bytes.Buffer
-	writer := zlib.NewWriter(&compressed)
-	if _, err := writer.Write(objData); err != nil {
-		writer.Close()
-		return nil, fmt.Errorf("failed to compress object data: %w", err)
+	w := &treeWalker{
+		repo:    p.repo,
+		sem:     make(chan struct{}, workers),
+		visited: make(map[string]bool),
 	}
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to finalize compression: %w", err)
+
+	for _, tree := range rootTrees {
+		if w.mark(tree) {
+			w.wg.Add(1)
+			go w.walk(tree)
+		}
 	}
+	w.wg.Wait()
 
-	var result bytes.Buffer
-	result.Write(header)
-	result.Write(compressed.Bytes())
-	return result.Bytes(), nil
+	return w.found
 }
 
-func (p *Pusher) createObjectHeader(objType int, size int64) []byte {
-	var header []byte
-
-	// first byte: MSB=0, type (3 bits), size (4 bits)
-	firstByte := byte((objType << typeBits) | (int(size) & sizeMask))
-	size >>= typeBits
+// mark records hash as found and reports whether it was new
+func (w *treeWalker) mark(hash string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	if size > 0 {
-		firstByte |= continuationBit // Set continuation bit
+	if w.visited[hash] {
+		return false
 	}
+	w.visited[hash] = true
+	w.found = append(w.found, hash)
+	return true
+}
 
-	header = append(header, firstByte)
+// walk visits one tree's entries, handing each new subtree to its own
+// goroutine rather than descending into it
+func (w *treeWalker) walk(treeHash string) {
+	defer w.wg.Done()
 
-	// additional bytes for larger sizes
-	for size > 0 {
-		nextByte := byte(size & sevenBitMask)
-		size >>= 7
-		if size > 0 {
-			nextByte |= continuationBit // Set continuation bit
-		}
+	// subtrees are never waited on, so holding a slot cannot starve them
+	w.sem <- struct{}{}
+	defer func() { <-w.sem }()
 
-		header = append(header, nextByte)
-	}
+	// trees that fail to load are left out
+	_ = objects.WalkTree(w.repo, treeHash, func(_ string, entry objects.TreeEntry) error {
+		// a submodule's commit is pushed from its own repository
+		if entry.IsSubmodule() || !w.mark(entry.Hash) {
+			return objects.SkipTree
+		}
 
-	return header
+		if entry.IsDir() {
+			w.wg.Add(1)
+			go w.walk(entry.Hash)
+			return objects.SkipTree
+		}
+		return nil
+	})
 }
 
 func (p *Pusher) setUpstream(branch, remote string) error {
-	configPath := filepath.Join(p.repo.GitDir, "config")
-
-	config := fmt.Sprintf(`[branch "%s"]
-	remote = %s
-	merge = refs/heads/%s
-`, branch, remote, branch)
-
-	file, err := os.OpenFile(configPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, defaultFileMode)
+	cfg, err := config.Load(filepath.Join(p.repo.GitDir, "config"))
 	if err != nil {
-		return fmt.Errorf("failed to open config file: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	if _, err := file.WriteString(config); err != nil {
+	cfg.Set("branch", branch, "remote", remote)
+	cfg.Set("branch", branch, "merge", headsPrefix+branch)
+
+	if err := cfg.Save(); err != nil {
 		return fmt.Errorf("failed to write upstream config: %w", err)
 	}
 
@@ -562,57 +728,176 @@ func (p *Pusher) getUpdateMessage(result *PushResult) string {
 	return fmt.Sprintf("updated %s..%s", result.OldCommit[:shortHashLength], result.NewCommit[:shortHashLength])
 }
 
+// PushAll pushes every local branch over one connection: the remote's refs
+// are listed once and the objects all branches need go in a single pack.
+// Branches that cannot be pushed are reported in RejectedRefs.
 func (p *Pusher) PushAll(ctx context.Context, options PushOptions) (*PushResult, error) {
-	branches, err := p.getAllBranches()
+	infos, err := branch.List(p.repo)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all branches: %w", err)
 	}
+	branches := make([]string, len(infos))
+	for i, info := range infos {
+		branches[i] = info.Name
+	}
+
+	if options.Remote == "" {
+		options.Remote = defaultRemote
+	}
+	if options.Timeout == 0 {
+		options.Timeout = defaultTimeout
+	}
 
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	transport, err := p.connect(ctx, options.Remote)
+	if err != nil {
+		return nil, err
+	}
+	defer transport.Close()
+
+	return p.pushBranches(ctx, transport, options, branches)
+}
+
+// pushBranches sends the given local branches to their namesakes on the
+// remote in one SendPack call
+func (p *Pusher) pushBranches(ctx context.Context, transport remote.Transport, options PushOptions, branches []string) (*PushResult, error) {
 	result := &PushResult{
 		Remote:       options.Remote,
 		UpdatedRefs:  make(map[string]RefUpdateResult),
 		RejectedRefs: make(map[string]string),
 	}
 
-	for _, branch := range branches {
-		branchOptions := options
-		branchOptions.Branch = branch
-		branchOptions.PushAll = false
+	remoteRefs, err := transport.ListRefs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote refs: %w", err)
+	}
 
-		branchResult, err := p.Push(ctx, branchOptions)
+	updates := make(map[string]remote.RefUpdate)
+	pending := make(map[string]RefUpdateResult)
+	var locals []string
+
+	for _, branch := range branches {
+		branchRef := headsPrefix + branch
+		localCommit, err := p.repo.ReadRef(branchRef)
 		if err != nil {
-			result.RejectedRefs[fmt.Sprintf("%s%s", headsPrefix, branch)] = err.Error()
+			result.RejectedRefs[branchRef] = err.Error()
+			continue
+		}
+		remoteCommit, exists := remoteRefs[branchRef]
+
+		update := RefUpdateResult{RefName: branchRef, OldHash: remoteCommit, NewHash: localCommit, Status: RefUpdateOK}
+		switch {
+		case remoteCommit == localCommit:
+			update.Status = RefUpdateUpToDate
+			update.Message = "Everything up-to-date"
+			result.UpdatedRefs[branchRef] = update
 			continue
+		case !exists:
+			update.Message = fmt.Sprintf("new branch '%s'", branch)
+		default:
+			status, reason, err := p.checkUpdate(options, branch, remoteCommit, localCommit)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				result.RejectedRefs[branchRef] = reason
+				continue
+			}
+			update.Status = status
+			update.Message = fmt.Sprintf("%s %s..%s", status, describeHash(remoteCommit), describeHash(localCommit))
+		}
+
+		pending[branchRef] = update
+		updates[branchRef] = remote.RefUpdate{RefName: branchRef, OldHash: remoteCommit, NewHash: localCommit}
+		locals = append(locals, localCommit)
+	}
+
+	var hookLines []string
+	for _, branchRef := range slices.Sorted(maps.Keys(pending)) {
+		update := pending[branchRef]
+		hookLines = append(hookLines, prePushLine(branchRef, update.NewHash, branchRef, update.OldHash))
+	}
+	if err := p.runPrePush(options, result, hookLines); err != nil {
+		return result, err
+	}
+
+	if len(updates) == 0 || options.DryRun {
+		maps.Copy(result.UpdatedRefs, pending)
+		return result, nil
+	}
+
+	// whatever any remote ref reaches, the remote already has
+	remotes := slices.Compact(slices.Sorted(maps.Values(remoteRefs)))
+	objectsToSend, err := p.objectsToSend(locals, remotes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get objects to send: %w", err)
+	}
+	p.progress.Update("Counting objects", len(objectsToSend), len(objectsToSend))
+
+	var packData []byte
+	if len(objectsToSend) > 0 {
+		if packData, _, err = pack.BuildPackWithProgress(p.repo, objectsToSend, p.progress); err != nil {
+			return nil, fmt.Errorf("failed to create pack file: %w", err)
 		}
+		result.PushedObjects = len(objectsToSend)
+		result.PushedSize = int64(len(packData))
+	}
 
-		for ref, update := range branchResult.UpdatedRefs {
-			result.UpdatedRefs[ref] = update
+	report, err := transport.SendPack(ctx, updates, packData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send pack: %w", err)
+	}
+	if report.UnpackError != "" {
+		return nil, fmt.Errorf("%w: remote failed to unpack objects: %s", errors.ErrPushRejected, report.UnpackError)
+	}
+
+	for branchRef, update := range pending {
+		if reason, rejected := report.Rejected[branchRef]; rejected {
+			result.RejectedRefs[branchRef] = reason
+			continue
 		}
+		result.UpdatedRefs[branchRef] = update
 
-		for ref, reason := range branchResult.RejectedRefs {
-			result.RejectedRefs[ref] = reason
+		trackingRef := remotesPrefix + options.Remote + "/" + strings.TrimPrefix(branchRef, headsPrefix)
+		if err := p.repo.UpdateRef(trackingRef, update.NewHash); err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", trackingRef, err)
 		}
 	}
 
 	return result, nil
 }
 
-func (p *Pusher) getAllBranches() ([]string, error) {
-	refsDir := filepath.Join(p.repo.GitDir, "refs", "heads")
+// checkUpdate decides how a branch that exists on the remote may be moved
+// from remoteCommit to localCommit under options, returning a reason when
+// it may not
+func (p *Pusher) checkUpdate(options PushOptions, branch, remoteCommit, localCommit string) (RefUpdateStatus, string, error) {
+	if options.ForceWithLease {
+		leaseOptions := options
+		leaseOptions.Branch = branch
+		expected, err := p.leaseHash(leaseOptions)
+		if err != nil {
+			return 0, "", err
+		}
+		if remoteCommit != expected {
+			return 0, StaleInfo, nil
+		}
+		return RefUpdateForced, "", nil
+	}
 
-	entries, err := os.ReadDir(refsDir)
+	canFastForward, err := p.canFastForward(remoteCommit, localCommit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read refs directory: %w", err)
+		return 0, "", fmt.Errorf("failed to check fast-forward: %w", err)
 	}
-
-	var branches []string
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			branches = append(branches, entry.Name())
-		}
+	switch {
+	case canFastForward:
+		return RefUpdateFastForward, "", nil
+	case options.Force:
+		return RefUpdateForced, "", nil
+	default:
+		return 0, "non-fast-forward", nil
 	}
-
-	return branches, nil
 }
 
 func (p *Pusher) PushTags(ctx context.Context, options PushOptions) (*PushResult, error) {
@@ -1,15 +1,29 @@
 package push
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/hooks"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/remote"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 func TestPushOptions(t *testing.T) {
@@ -150,26 +164,20 @@ func TestPusherIntegration(t *testing.T) {
 	})
 }
 
-func TestGetAllBranches(t *testing.T) {
-	tempDir := t.TempDir()
-	repo := repository.New(tempDir)
+func TestSetUpstream(t *testing.T) {
+	repo := repository.New(t.TempDir())
 	require.NoError(t, repo.Init())
 
-	pusher := NewPusher(repo)
-
-	branches, err := pusher.getAllBranches()
-	assert.NoError(t, err)
-	assert.Empty(t, branches)
+	configPath := filepath.Join(repo.GitDir, "config")
+	require.NoError(t, os.WriteFile(configPath, []byte("[core]\n\tbare = false\n"), 0644))
 
-	refsDir := filepath.Join(repo.GitDir, "refs", "heads")
-	require.NoError(t, os.WriteFile(filepath.Join(refsDir, "main"), []byte("hash1\n"), 0644))
-	require.NoError(t, os.WriteFile(filepath.Join(refsDir, "develop"), []byte("hash2\n"), 0644))
+	pusher := NewPusher(repo)
+	require.NoError(t, pusher.setUpstream("main", "origin"))
+	require.NoError(t, pusher.setUpstream("main", "upstream"))
 
-	branches, err = pusher.getAllBranches()
-	assert.NoError(t, err)
-	assert.Len(t, branches, 2)
-	assert.Contains(t, branches, "main")
-	assert.Contains(t, branches, "develop")
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "[core]\n\tbare = false\n[branch \"main\"]\n\tremote = upstream\n\tmerge = refs/heads/main\n", string(data))
 }
 
 func TestGetAllTags(t *testing.T) {
@@ -194,3 +202,540 @@ func TestGetAllTags(t *testing.T) {
 	assert.Contains(t, tags, "v1.0.0")
 	assert.Contains(t, tags, "v2.0.0")
 }
+
+// storeWideTree stores a tree of the given depth where every level has width
+// subtrees and width blobs, returning the root and every object hash under it
+func storeWideTree(t testing.TB, repo *repository.Repository, depth, width int, seed string) (string, []string) {
+	var entries []objects.TreeEntry
+	var all []string
+
+	for i := 0; i < width; i++ {
+		blobHash, err := repo.StoreObject(objects.NewBlob([]byte(fmt.Sprintf("%s/%d/%d", seed, depth, i))))
+		require.NoError(t, err)
+		entries = append(entries, objects.TreeEntry{Mode: objects.FileModeBlob, Name: fmt.Sprintf("file%d", i), Hash: blobHash})
+		all = append(all, blobHash)
+
+		if depth > 1 {
+			subHash, subObjects := storeWideTree(t, repo, depth-1, width, fmt.Sprintf("%s/%d", seed, i))
+			entries = append(entries, objects.TreeEntry{Mode: objects.FileModeTree, Name: fmt.Sprintf("dir%d", i), Hash: subHash})
+			all = append(all, subHash)
+			all = append(all, subObjects...)
+		}
+	}
+
+	treeHash, err := repo.StoreObject(objects.NewTree(entries))
+	require.NoError(t, err)
+	return treeHash, all
+}
+
+func storePushCommit(t testing.TB, repo *repository.Repository, tree string, parents ...string) string {
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(0, 0).UTC()}
+	commitHash, err := repo.StoreObject(objects.NewCommit(tree, parents, sig, sig, "commit"))
+	require.NoError(t, err)
+	return commitHash
+}
+
+func TestGetObjectsToSend(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	firstTree, firstObjects := storeWideTree(t, repo, 3, 3, "first")
+	first := storePushCommit(t, repo, firstTree)
+
+	// the second tree reuses the whole first tree as a subdirectory
+	secondTree, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeTree, Name: "old", Hash: firstTree},
+	}))
+	require.NoError(t, err)
+	second := storePushCommit(t, repo, secondTree, first)
+
+	expectedAll := append([]string{first, firstTree, second, secondTree}, firstObjects...)
+
+	for _, workers := range []int{1, maxTreeWorkers} {
+		pusher := NewPusher(repo)
+		pusher.treeWorkers = workers
+
+		got, err := pusher.getObjectsToSend(second, "")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, expectedAll, got, "workers=%d", workers)
+		assert.Equal(t, []string{second, first}, got[:2], "commits come first in traversal order")
+
+		// the remote already has the first tree, which the second reuses
+		got, err = pusher.getObjectsToSend(second, first)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{second, secondTree}, got, "workers=%d", workers)
+	}
+}
+
+func benchmarkGetObjectsToSend(b *testing.B, workers int) {
+	repo := repository.New(b.TempDir())
+	require.NoError(b, repo.Init())
+
+	tree, _ := storeWideTree(b, repo, 4, 6, "bench")
+	head := storePushCommit(b, repo, tree)
+
+	pusher := NewPusher(repo)
+	pusher.treeWorkers = workers
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pusher.getObjectsToSend(head, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetObjectsToSend_Serial(b *testing.B)   { benchmarkGetObjectsToSend(b, 1) }
+func BenchmarkGetObjectsToSend_Parallel(b *testing.B) { benchmarkGetObjectsToSend(b, maxTreeWorkers) }
+
+// pushServer is a smart HTTP remote advertising refs and answering every
+// push with the report-status lines report returns
+type pushServer struct {
+	*httptest.Server
+	refs   map[string]string
+	report func(ref string) string
+	pushes int
+	// pack is the pack data of the last push
+	pack []byte
+}
+
+func newPushServer(t *testing.T, refs map[string]string, report func(ref string) string) *pushServer {
+	pkt := func(line string) string { return fmt.Sprintf("%04x%s", len(line)+4, line) }
+	s := &pushServer{refs: refs, report: report}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repo.git/info/refs":
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+			advertisement := pkt("# service=git-upload-pack\n") + "0000"
+			for ref, hash := range s.refs {
+				advertisement += pkt(hash + " " + ref + "\n")
+			}
+			fmt.Fprint(w, advertisement+"0000")
+		case "/repo.git/git-receive-pack":
+			s.pushes++
+			body, _ := io.ReadAll(r.Body)
+			status := pkt("unpack ok\n")
+			for len(body) >= 4 && string(body[:4]) != "0000" {
+				length, _ := strconv.ParseInt(string(body[:4]), 16, 32)
+				command, _, _ := strings.Cut(string(body[4:length]), "\x00")
+				status += pkt(s.report(strings.Fields(command)[2]) + "\n")
+				body = body[length:]
+			}
+			s.pack = body[min(len(body), 4):]
+			fmt.Fprint(w, pkt("\x01"+status+"0000")+"0000")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// setupPushRepo makes a repository with one commit on its current branch
+// and origin pointing at server
+func setupPushRepo(t *testing.T, server *pushServer) (*repository.Repository, string, string) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	blob, err := repo.StoreObject(objects.NewBlob([]byte("hello\n")))
+	require.NoError(t, err)
+	tree, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "a.txt", Hash: blob}}))
+	require.NoError(t, err)
+	commit := storePushCommit(t, repo, tree)
+	branch, err := repo.GetCurrentBranch()
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateRef("refs/heads/"+branch, commit))
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.GitDir, "config"),
+		[]byte("[remote \"origin\"]\n\turl = "+server.URL+"/repo.git\n"), 0644))
+	return repo, branch, commit
+}
+
+func TestPushServerRejection(t *testing.T) {
+	server := newPushServer(t, nil, func(ref string) string { return "ng " + ref + " pre-receive hook declined" })
+	repo, branch, _ := setupPushRepo(t, server)
+
+	opts := DefaultPushOptions()
+	opts.Branch = branch
+	result, err := NewPusher(repo).Push(context.Background(), opts)
+	require.ErrorIs(t, err, errors.ErrPushRejected)
+	assert.Equal(t, 1, server.pushes)
+	require.NotNil(t, result)
+	assert.Equal(t, map[string]string{"refs/heads/" + branch: "pre-receive hook declined"}, result.RejectedRefs)
+	assert.Empty(t, result.UpdatedRefs)
+}
+
+func TestPushProgress(t *testing.T) {
+	server := newPushServer(t, nil, func(ref string) string { return "ok " + ref })
+	repo, branch, _ := setupPushRepo(t, server)
+
+	type update struct {
+		phase          string
+		current, total int
+	}
+	var updates []update
+	progress := pack.ProgressFunc(func(phase string, current, total int) {
+		updates = append(updates, update{phase, current, total})
+	})
+
+	opts := DefaultPushOptions()
+	opts.Branch = branch
+	_, err := NewPusher(repo).WithProgress(progress).Push(context.Background(), opts)
+	require.NoError(t, err)
+
+	// the commit, its tree and its blob are counted, then written one by one
+	assert.Equal(t, []update{
+		{"Counting objects", 1, 0},
+		{"Counting objects", 3, 3},
+		{"Writing objects", 1, 3},
+		{"Writing objects", 2, 3},
+		{"Writing objects", 3, 3},
+	}, updates)
+
+	// a nil progress is the same as none
+	assert.NotPanics(t, func() { NewPusher(repo).WithProgress(nil) })
+}
+
+func TestPushForceWithLease(t *testing.T) {
+	seen := strings.Repeat("1", 40)
+	moved := strings.Repeat("2", 40)
+	server := newPushServer(t, nil, func(ref string) string { return "ok " + ref })
+	repo, branch, commit := setupPushRepo(t, server)
+	branchRef := "refs/heads/" + branch
+	trackingRef := "refs/remotes/origin/" + branch
+
+	push := func(lease string) (*PushResult, error) {
+		opts := DefaultPushOptions()
+		opts.Branch = branch
+		opts.ForceWithLease = true
+		opts.LeaseHash = lease
+		return NewPusher(repo).Push(context.Background(), opts)
+	}
+
+	// someone else pushed after we last fetched
+	server.refs = map[string]string{branchRef: moved}
+	require.NoError(t, repo.UpdateRef(trackingRef, seen))
+
+	result, err := push("")
+	require.ErrorIs(t, err, errors.ErrPushRejected)
+	assert.Contains(t, err.Error(), "has moved")
+	assert.Equal(t, StaleInfo, result.RejectedRefs[branchRef])
+	assert.Equal(t, 0, server.pushes)
+
+	result, err = push(seen)
+	require.ErrorIs(t, err, errors.ErrPushRejected)
+	assert.Equal(t, StaleInfo, result.RejectedRefs[branchRef])
+	assert.Equal(t, 0, server.pushes)
+
+	// an explicit lease that matches the remote overrides the tracking ref
+	result, err = push(moved)
+	require.NoError(t, err)
+	assert.True(t, result.Forced)
+	assert.Equal(t, 1, server.pushes)
+
+	tracked, err := repo.ReadRef(trackingRef)
+	require.NoError(t, err)
+	assert.Equal(t, commit, tracked)
+
+	// once the tracking ref has caught up with the remote the lease holds
+	require.NoError(t, repo.UpdateRef(trackingRef, moved))
+	_, err = push("")
+	require.NoError(t, err)
+	assert.Equal(t, 2, server.pushes)
+}
+
+func TestPushForceWithLeaseNewBranch(t *testing.T) {
+	server := newPushServer(t, nil, func(ref string) string { return "ok " + ref })
+	repo, branch, _ := setupPushRepo(t, server)
+
+	opts := DefaultPushOptions()
+	opts.Branch = branch
+	opts.ForceWithLease = true
+
+	// no tracking ref means the branch is expected not to exist yet
+	_, err := NewPusher(repo).Push(context.Background(), opts)
+	require.NoError(t, err)
+
+	server.refs = map[string]string{"refs/heads/" + branch: strings.Repeat("3", 40)}
+	require.NoError(t, os.Remove(filepath.Join(repo.GitDir, "refs", "remotes", "origin", branch)))
+	_, err = NewPusher(repo).Push(context.Background(), opts)
+	require.ErrorIs(t, err, errors.ErrPushRejected)
+}
+
+// mockTransport records the updates sent to it and replies with report
+type mockTransport struct {
+	refs     map[string]string
+	report   *remote.PushReport
+	updates  map[string]remote.RefUpdate
+	packData []byte
+}
+
+func (m *mockTransport) Connect(ctx context.Context, url string) error { return nil }
+func (m *mockTransport) Disconnect() error                             { return nil }
+func (m *mockTransport) Close() error                                  { return nil }
+
+func (m *mockTransport) ListRefs(ctx context.Context) (map[string]string, error) {
+	return m.refs, nil
+}
+
+func (m *mockTransport) FetchPack(ctx context.Context, wants, haves []string) (remote.PackReader, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (m *mockTransport) SendPack(ctx context.Context, refs map[string]remote.RefUpdate, packData []byte) (*remote.PushReport, error) {
+	m.updates = refs
+	m.packData = packData
+	return m.report, nil
+}
+
+func TestDeleteBranch(t *testing.T) {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+	old := strings.Repeat("a", 40)
+	require.NoError(t, repo.UpdateRefWithMessage("refs/remotes/origin/topic", old, "fetch"))
+	// the tracking ref is packed as well as loose, as after a gc and a fetch
+	packedRefs := filepath.Join(repo.GitDir, "packed-refs")
+	require.NoError(t, os.WriteFile(packedRefs, []byte(old+" refs/remotes/origin/topic\n"), 0644))
+
+	opts := DefaultPushOptions()
+	opts.Branch = "topic"
+	opts.Delete = true
+
+	t.Run("Confirmed", func(t *testing.T) {
+		transport := &mockTransport{
+			refs:   map[string]string{"refs/heads/topic": old},
+			report: &remote.PushReport{Updated: []string{"refs/heads/topic"}, Rejected: map[string]string{}},
+		}
+
+		result, err := NewPusher(repo).deleteBranch(context.Background(), transport, opts)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]remote.RefUpdate{
+			"refs/heads/topic": {RefName: "refs/heads/topic", OldHash: old, NewHash: remote.ZeroHash},
+		}, transport.updates)
+		assert.Nil(t, transport.packData)
+		assert.Equal(t, RefUpdateDeleted, result.UpdatedRefs["refs/heads/topic"].Status)
+
+		_, err = repo.ReadRef("refs/remotes/origin/topic")
+		assert.ErrorIs(t, err, errors.ErrReferenceNotFound)
+		packed, err := os.ReadFile(packedRefs)
+		require.NoError(t, err)
+		assert.NotContains(t, string(packed), "refs/remotes/origin/topic")
+		assert.NoFileExists(t, filepath.Join(repo.GitDir, "logs", "refs", "remotes", "origin", "topic"))
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		transport := &mockTransport{
+			refs:   map[string]string{"refs/heads/topic": old},
+			report: &remote.PushReport{Rejected: map[string]string{"refs/heads/topic": "deletion prohibited"}},
+		}
+
+		result, err := NewPusher(repo).deleteBranch(context.Background(), transport, opts)
+		require.ErrorIs(t, err, errors.ErrPushRejected)
+		assert.Equal(t, "deletion prohibited", result.RejectedRefs["refs/heads/topic"])
+	})
+
+	t.Run("Unconfirmed", func(t *testing.T) {
+		transport := &mockTransport{
+			refs:   map[string]string{"refs/heads/topic": old},
+			report: &remote.PushReport{Rejected: map[string]string{}},
+		}
+
+		_, err := NewPusher(repo).deleteBranch(context.Background(), transport, opts)
+		assert.ErrorContains(t, err, "did not confirm")
+	})
+
+	t.Run("MissingOnRemote", func(t *testing.T) {
+		transport := &mockTransport{refs: map[string]string{}}
+
+		_, err := NewPusher(repo).deleteBranch(context.Background(), transport, opts)
+		require.ErrorIs(t, err, errors.ErrReferenceNotFound)
+		assert.Nil(t, transport.updates)
+	})
+}
+
+func TestPushAllSinglePack(t *testing.T) {
+	server := newPushServer(t, nil, func(ref string) string { return "ok " + ref })
+	repo, branch, base := setupPushRepo(t, server)
+
+	baseCommit, err := repo.LoadCommit(base)
+	require.NoError(t, err)
+	shared, err := repo.LoadTree(baseCommit.Tree())
+	require.NoError(t, err)
+	branchOff := func(name, file string) string {
+		blob, err := repo.StoreObject(objects.NewBlob([]byte(file + "\n")))
+		require.NoError(t, err)
+		entries := append(slices.Clone(shared.Entries()), objects.TreeEntry{Mode: objects.FileModeBlob, Name: file, Hash: blob})
+		tree, err := repo.StoreObject(objects.NewTree(entries))
+		require.NoError(t, err)
+		commit := storePushCommit(t, repo, tree, base)
+		require.NoError(t, repo.UpdateRef("refs/heads/"+name, commit))
+		return commit
+	}
+	branches := map[string]string{
+		branch: base,
+		"one":  branchOff("one", "one.txt"),
+		"two":  branchOff("two", "two.txt"),
+	}
+
+	opts := DefaultPushOptions()
+	result, err := NewPusher(repo).PushAll(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Empty(t, result.RejectedRefs)
+
+	assert.Equal(t, 1, server.pushes)
+	require.True(t, bytes.HasPrefix(server.pack, []byte("PACK")))
+	// three commits, three trees and three blobs, the shared blob sent once
+	assert.Equal(t, uint32(9), binary.BigEndian.Uint32(server.pack[8:12]))
+	assert.Equal(t, 9, result.PushedObjects)
+
+	require.Len(t, result.UpdatedRefs, 3)
+	for name, commit := range branches {
+		assert.Equal(t, commit, result.UpdatedRefs["refs/heads/"+name].NewHash)
+		tracked, err := repo.ReadRef("refs/remotes/origin/" + name)
+		require.NoError(t, err)
+		assert.Equal(t, commit, tracked)
+	}
+}
+
+func TestPushAllNestedAndPackedBranches(t *testing.T) {
+	server := newPushServer(t, nil, func(ref string) string { return "ok " + ref })
+	repo, branch, base := setupPushRepo(t, server)
+
+	require.NoError(t, repo.UpdateRef("refs/heads/feature/login", base))
+	packed := "# pack-refs with: peeled fully-peeled sorted \n" + base + " refs/heads/packed\n"
+	require.NoError(t, os.WriteFile(filepath.Join(repo.GitDir, "packed-refs"), []byte(packed), 0644))
+
+	result, err := NewPusher(repo).PushAll(context.Background(), DefaultPushOptions())
+	require.NoError(t, err)
+	assert.Empty(t, result.RejectedRefs)
+
+	// branches in subdirectories and in packed-refs are pushed too
+	require.Len(t, result.UpdatedRefs, 3)
+	for _, name := range []string{branch, "feature/login", "packed"} {
+		assert.Equal(t, base, result.UpdatedRefs["refs/heads/"+name].NewHash, name)
+	}
+}
+
+func TestPushSkipsObjectsOnOtherRemoteBranches(t *testing.T) {
+	server := newPushServer(t, nil, func(ref string) string { return "ok " + ref })
+	repo, branch, base := setupPushRepo(t, server)
+
+	storeBlob := func(content string) string {
+		hash, err := repo.StoreObject(objects.NewBlob([]byte(content)))
+		require.NoError(t, err)
+		return hash
+	}
+	storeCommit := func(files map[string]string) (string, string) {
+		var entries []objects.TreeEntry
+		for name, blob := range files {
+			entries = append(entries, objects.TreeEntry{Mode: objects.FileModeBlob, Name: name, Hash: blob})
+		}
+		tree, err := repo.StoreObject(objects.NewTree(entries))
+		require.NoError(t, err)
+		return storePushCommit(t, repo, tree, base), tree
+	}
+
+	// another branch, already on the remote, added a large file...
+	large := storeBlob(strings.Repeat("large\n", 1000))
+	other, _ := storeCommit(map[string]string{"large.bin": large})
+
+	// ...which our branch also has, next to a file of its own
+	own := storeBlob("own\n")
+	local, localTree := storeCommit(map[string]string{"large.bin": large, "own.txt": own})
+	require.NoError(t, repo.UpdateRef("refs/heads/"+branch, local))
+
+	server.refs = map[string]string{"refs/heads/other": other}
+
+	opts := DefaultPushOptions()
+	opts.Branch = branch
+	result, err := NewPusher(repo).Push(context.Background(), opts)
+	require.NoError(t, err)
+
+	// the base commit and the large blob are reachable from the other branch
+	assert.Equal(t, 3, result.PushedObjects, "expected only %s, %s and %s", local, localTree, own)
+	assert.Equal(t, uint32(3), binary.BigEndian.Uint32(server.pack[8:12]))
+}
+
+func TestPushPrePushHook(t *testing.T) {
+	server := newPushServer(t, nil, func(ref string) string { return "ok " + ref })
+	repo, branch, commit := setupPushRepo(t, server)
+
+	hook := hooks.Path(repo, hooks.PrePush)
+	require.NoError(t, os.MkdirAll(filepath.Dir(hook), 0755))
+	require.NoError(t, os.WriteFile(hook, []byte(`#!/bin/sh
+while read local_ref local_sha remote_ref remote_sha; do
+	if [ "$remote_ref" = refs/heads/protected ]; then
+		echo "refusing to push to $remote_ref on $1"
+		exit 1
+	fi
+	echo "$2: $local_ref $local_sha $remote_ref $remote_sha"
+done
+`), 0755))
+
+	require.NoError(t, repo.UpdateRef("refs/heads/protected", commit))
+	require.NoError(t, repo.UpdateRef("refs/heads/feature", commit))
+
+	push := func(target string, skipHooks bool) (*PushResult, error) {
+		opts := DefaultPushOptions()
+		opts.Branch = target
+		opts.SkipHooks = skipHooks
+		return NewPusher(repo).Push(context.Background(), opts)
+	}
+
+	result, err := push("protected", false)
+	require.ErrorIs(t, err, errors.ErrHookFailed)
+	assert.Contains(t, err.Error(), "refusing to push to refs/heads/protected on origin")
+	assert.Contains(t, result.HookOutput, "refusing to push")
+	assert.Equal(t, 0, server.pushes)
+
+	result, err = push(branch, false)
+	require.NoError(t, err)
+	branchRef := "refs/heads/" + branch
+	assert.Equal(t, fmt.Sprintf("%s/repo.git: %s %s %s %s\n", server.URL, branchRef, commit, branchRef, remote.ZeroHash), result.HookOutput)
+	assert.Equal(t, 1, server.pushes)
+
+	result, err = push("protected", true)
+	require.NoError(t, err)
+	assert.Empty(t, result.HookOutput)
+	assert.Equal(t, 2, server.pushes)
+
+	// on a detached HEAD the hook still names the branch being pushed
+	require.NoError(t, os.WriteFile(filepath.Join(repo.GitDir, "HEAD"), []byte(commit+"\n"), 0644))
+	result, err = push("feature", false)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%s/repo.git: refs/heads/feature %s refs/heads/feature %s\n", server.URL, commit, remote.ZeroHash), result.HookOutput)
+}
+
+func TestPushBranchNotCheckedOut(t *testing.T) {
+	server := newPushServer(t, nil, func(ref string) string { return "ok " + ref })
+	repo, _, base := setupPushRepo(t, server)
+
+	blob, err := repo.StoreObject(objects.NewBlob([]byte("feature\n")))
+	require.NoError(t, err)
+	tree, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "feature.txt", Hash: blob}}))
+	require.NoError(t, err)
+	feature := storePushCommit(t, repo, tree, base)
+	require.NoError(t, repo.UpdateRef("refs/heads/feature", feature))
+
+	hook := hooks.Path(repo, hooks.PrePush)
+	require.NoError(t, os.MkdirAll(filepath.Dir(hook), 0755))
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\ncat\n"), 0755))
+
+	opts := DefaultPushOptions()
+	opts.Branch = "feature"
+	result, err := NewPusher(repo).Push(context.Background(), opts)
+	require.NoError(t, err)
+
+	// HEAD stays on its own branch; everything names the feature tip
+	branchRef := "refs/heads/feature"
+	assert.Equal(t, feature, result.NewCommit)
+	assert.Equal(t, feature, result.UpdatedRefs[branchRef].NewHash)
+	assert.Equal(t, fmt.Sprintf("%s %s %s %s\n", branchRef, feature, branchRef, remote.ZeroHash), result.HookOutput)
+	tracked, err := repo.ReadRef("refs/remotes/origin/feature")
+	require.NoError(t, err)
+	assert.Equal(t, feature, tracked)
+
+	opts.Branch = "missing"
+	_, err = NewPusher(repo).Push(context.Background(), opts)
+	require.ErrorIs(t, err, errors.ErrReferenceNotFound)
+}
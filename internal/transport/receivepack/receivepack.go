@@ -0,0 +1,395 @@
+package receivepack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/hooks"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/pktline"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	// zeroHash as an old value creates a ref, as a new value deletes it
+	zeroHash = "0000000000000000000000000000000000000000"
+
+	capabilities = "report-status delete-refs side-band-64k ofs-delta"
+	sideband64k  = "side-band-64k"
+
+	refsPrefix = "refs/"
+
+	packHeaderSize = 12
+	packSignature  = "PACK"
+	hashSize       = 20
+	packOfsDelta   = 6
+	packRefDelta   = 7
+)
+
+// reasons sent back for refused refs, as git words them
+const (
+	reasonUnpackFailed   = "unpacker error"
+	reasonPreReceive     = "pre-receive hook declined"
+	reasonUpdateHook     = "hook declined"
+	reasonMissingObjects = "missing necessary objects"
+	reasonFunnyRefname   = "funny refname"
+	reasonStaleInfo      = "stale info"
+	reasonCheckedOut     = "branch is currently checked out"
+	reasonDeleteCurrent  = "deletion of the current branch prohibited"
+	reasonUpdateFailed   = "failed to update ref"
+)
+
+// Command is one ref update a client asks for: Old is zeroHash for a ref to
+// create, New is zeroHash for a ref to delete
+type Command struct {
+	Old string
+	New string
+	Ref string
+}
+
+func (c Command) isDelete() bool { return c.New == zeroHash }
+
+// Report is the outcome of a push, as sent back to the client
+type Report struct {
+	// UnpackError is why the pack could not be unpacked; empty when it could
+	UnpackError string
+	// Updated lists the refs that were changed, in request order
+	Updated []string
+	// Rejected maps each refused ref to its reason
+	Rejected map[string]string
+	// HookOutput is everything the hooks printed, relayed to the client on
+	// the progress channel when it asked for sideband
+	HookOutput string
+}
+
+// Handler serves the receiving end of a push into repo: the counterpart of
+// a transport's SendPack
+type Handler struct {
+	repo *repository.Repository
+}
+
+func New(repo *repository.Repository) *Handler {
+	return &Handler{repo: repo}
+}
+
+// AdvertiseRefs writes the refs a push starts from, with the capabilities
+// attached to the first, followed by a flush packet
+func (h *Handler) AdvertiseRefs(w io.Writer) error {
+	refs, err := h.repo.ListRefs()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	if len(names) == 0 {
+		// an empty repository still has to announce its capabilities
+		pktline.Write(&buf, fmt.Sprintf("%s capabilities^{}\x00%s\n", zeroHash, capabilities))
+	}
+	for i, name := range names {
+		line := refs[name] + " " + name
+		if i == 0 {
+			line += "\x00" + capabilities
+		}
+		pktline.Write(&buf, line+"\n")
+	}
+	buf.WriteString(pktline.Flush)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Serve reads a push request from r, the ref commands and then the pack,
+// applies it and writes the report-status to w. pre-receive may refuse the
+// whole push and update each ref on its own; post-receive then hears about
+// the refs that were changed. Refused refs are reported to the client, not
+// returned as an error.
+func (h *Handler) Serve(r io.Reader, w io.Writer) (*Report, error) {
+	reader := bufio.NewReader(r)
+	commands, caps, err := readCommands(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Rejected: make(map[string]string)}
+	// a client with nothing to update expects no reply
+	if len(commands) == 0 {
+		return report, nil
+	}
+
+	h.receive(reader, commands, report)
+	return report, writeReport(w, commands, report, slices.Contains(caps, sideband64k))
+}
+
+func (h *Handler) receive(r *bufio.Reader, commands []Command, report *Report) {
+	rejectAll := func(reason string) {
+		for _, cmd := range commands {
+			report.Rejected[cmd.Ref] = reason
+		}
+	}
+
+	// a push that only deletes sends no pack
+	if slices.ContainsFunc(commands, func(cmd Command) bool { return !cmd.isDelete() }) {
+		if err := h.unpack(r); err != nil {
+			report.UnpackError = strings.ReplaceAll(err.Error(), "\n", " ")
+			rejectAll(reasonUnpackFailed)
+			return
+		}
+	}
+
+	var output strings.Builder
+	defer func() { report.HookOutput = output.String() }()
+
+	out, err := hooks.Run(h.repo, hooks.PreReceive, strings.NewReader(hookInput(commands)))
+	output.WriteString(out)
+	if err != nil {
+		rejectAll(reasonPreReceive)
+		return
+	}
+
+	var applied []Command
+	for _, cmd := range commands {
+		if reason := h.apply(cmd, &output); reason != "" {
+			report.Rejected[cmd.Ref] = reason
+			continue
+		}
+		report.Updated = append(report.Updated, cmd.Ref)
+		applied = append(applied, cmd)
+	}
+
+	// post-receive cannot undo anything, so its exit status is ignored
+	if len(applied) > 0 {
+		out, _ := hooks.Run(h.repo, hooks.PostReceive, strings.NewReader(hookInput(applied)))
+		output.WriteString(out)
+	}
+}
+
+// unpack stores the objects of the pack that follows the commands
+func (h *Handler) unpack(r *bufio.Reader) error {
+	data, err := readPack(r)
+	if err != nil {
+		return err
+	}
+	return pack.NewPackProcessor(h.repo).ProcessPack(bytes.NewReader(data))
+}
+
+// apply runs the update hook for cmd and then moves its ref, returning why
+// the ref was refused, or "" once it is updated
+func (h *Handler) apply(cmd Command, output *strings.Builder) string {
+	// a name such as "refs/../config" would be written outside refs/
+	if !strings.HasPrefix(cmd.Ref, refsPrefix) || repository.CheckRefName(cmd.Ref) != nil {
+		return reasonFunnyRefname
+	}
+	if !cmd.isDelete() && !h.repo.HasObject(cmd.New) {
+		return reasonMissingObjects
+	}
+	if current, err := h.repo.GetCurrentBranch(); err == nil && cmd.Ref == "refs/heads/"+current {
+		if cmd.isDelete() {
+			return reasonDeleteCurrent
+		}
+		// moving the branch would leave the work tree behind it
+		if !h.repo.IsBare() {
+			return reasonCheckedOut
+		}
+	}
+
+	out, err := hooks.Run(h.repo, hooks.Update, nil, cmd.Ref, cmd.Old, cmd.New)
+	output.WriteString(out)
+	if err != nil {
+		return reasonUpdateHook
+	}
+
+	if cmd.isDelete() {
+		err = h.repo.DeleteRef(cmd.Ref, cmd.Old)
+	} else {
+		old := cmd.Old
+		if old == zeroHash {
+			old = ""
+		}
+		err = h.repo.UpdateRefCAS(cmd.Ref, old, cmd.New)
+	}
+	switch {
+	case err == nil:
+		return ""
+	case stderrors.Is(err, errors.ErrReferenceChanged), stderrors.Is(err, errors.ErrReferenceNotFound):
+		return reasonStaleInfo
+	default:
+		return reasonUpdateFailed
+	}
+}
+
+// hookInput is the "<old> <new> <ref>" lines pre- and post-receive read
+func hookInput(commands []Command) string {
+	var b strings.Builder
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "%s %s %s\n", cmd.Old, cmd.New, cmd.Ref)
+	}
+	return b.String()
+}
+
+// readCommands reads the "<old> <new> <ref>" pkt-lines up to the flush,
+// along with the capabilities the client put after the first one
+func readCommands(r *bufio.Reader) ([]Command, []string, error) {
+	var commands []Command
+	var caps []string
+
+	for {
+		packet, err := pktline.Read(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read push commands: %w", err)
+		}
+		if packet == nil {
+			return commands, caps, nil
+		}
+
+		line := strings.TrimSuffix(string(packet), "\n")
+		if len(commands) == 0 {
+			var capList string
+			line, capList, _ = strings.Cut(line, "\x00")
+			caps = strings.Fields(capList)
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !hash.ValidateHash(fields[0]) || !hash.ValidateHash(fields[1]) {
+			return nil, nil, fmt.Errorf("invalid push command %q", line)
+		}
+		commands = append(commands, Command{Old: fields[0], New: fields[1], Ref: fields[2]})
+	}
+}
+
+// writeReport writes the report-status for commands, wrapped in sideband
+// channel 1 after the hook output on channel 2 when sideband was asked for
+func writeReport(w io.Writer, commands []Command, report *Report, sideband bool) error {
+	var status bytes.Buffer
+	if report.UnpackError == "" {
+		pktline.Write(&status, "unpack ok\n")
+	} else {
+		pktline.Write(&status, "unpack "+report.UnpackError+"\n")
+	}
+	for _, cmd := range commands {
+		if reason, ok := report.Rejected[cmd.Ref]; ok {
+			pktline.Write(&status, fmt.Sprintf("ng %s %s\n", cmd.Ref, reason))
+		} else {
+			pktline.Write(&status, fmt.Sprintf("ok %s\n", cmd.Ref))
+		}
+	}
+	status.WriteString(pktline.Flush)
+
+	if !sideband {
+		_, err := w.Write(status.Bytes())
+		return err
+	}
+
+	var reply bytes.Buffer
+	pktline.WriteBand(&reply, pktline.BandProgress, []byte(report.HookOutput))
+	pktline.WriteBand(&reply, pktline.BandData, status.Bytes())
+	reply.WriteString(pktline.Flush)
+	_, err := w.Write(reply.Bytes())
+	return err
+}
+
+// readPack reads exactly one pack from r and checks its trailing checksum.
+// The client keeps the connection open for the report, so the pack's end is
+// found by walking its objects rather than by reading to EOF.
+func readPack(r *bufio.Reader) ([]byte, error) {
+	pr := &packReader{r: r}
+
+	header := make([]byte, packHeaderSize)
+	if _, err := io.ReadFull(pr, header); err != nil {
+		return nil, fmt.Errorf("failed to read pack header: %w", err)
+	}
+	if string(header[:4]) != packSignature {
+		return nil, fmt.Errorf("invalid pack signature %q", header[:4])
+	}
+
+	count := binary.BigEndian.Uint32(header[8:])
+	for i := range count {
+		if err := pr.skipObject(); err != nil {
+			return nil, fmt.Errorf("failed to read pack object %d: %w", i, err)
+		}
+	}
+
+	sum := sha1.Sum(pr.data.Bytes())
+	trailer := make([]byte, hashSize)
+	if _, err := io.ReadFull(pr, trailer); err != nil {
+		return nil, fmt.Errorf("failed to read pack checksum: %w", err)
+	}
+	if !bytes.Equal(trailer, sum[:]) {
+		return nil, fmt.Errorf("pack checksum mismatch")
+	}
+	return pr.data.Bytes(), nil
+}
+
+// packReader keeps every byte read through it. It is an io.ByteReader so
+// that zlib reads no further than the end of each object's data.
+type packReader struct {
+	r    *bufio.Reader
+	data bytes.Buffer
+}
+
+func (p *packReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.data.Write(buf[:n])
+	return n, err
+}
+
+func (p *packReader) ReadByte() (byte, error) {
+	c, err := p.r.ReadByte()
+	if err == nil {
+		p.data.WriteByte(c)
+	}
+	return c, err
+}
+
+// skipObject reads past one object: its header, its delta base and its
+// compressed data
+func (p *packReader) skipObject() error {
+	c, err := p.ReadByte()
+	if err != nil {
+		return err
+	}
+	objType := (c >> 4) & 0x7
+	for c&0x80 != 0 {
+		if c, err = p.ReadByte(); err != nil {
+			return err
+		}
+	}
+
+	switch objType {
+	case packOfsDelta:
+		for c = 0x80; c&0x80 != 0; {
+			if c, err = p.ReadByte(); err != nil {
+				return err
+			}
+		}
+	case packRefDelta:
+		if _, err := io.ReadFull(p, make([]byte, hashSize)); err != nil {
+			return err
+		}
+	}
+
+	z, err := zlib.NewReader(p)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, z); err != nil {
+		return err
+	}
+	return z.Close()
+}
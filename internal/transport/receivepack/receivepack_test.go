@@ -0,0 +1,293 @@
+package receivepack
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/hooks"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/pktline"
+)
+
+// pushed is a commit stored in a client repository along with the pack
+// carrying it
+type pushed struct {
+	commit string
+	pack   []byte
+}
+
+func setupServer(t *testing.T) *repository.Repository {
+	t.Helper()
+	repo := repository.NewBare(t.TempDir())
+	require.NoError(t, repo.Init())
+	return repo
+}
+
+// newCommit stores a one-file commit in a fresh client repository and packs it
+func newCommit(t *testing.T, content string) pushed {
+	t.Helper()
+	client := repository.New(t.TempDir())
+	require.NoError(t, client.Init())
+
+	blob, err := client.StoreObject(objects.NewBlob([]byte(content)))
+	require.NoError(t, err)
+	tree, err := client.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "file.txt", Hash: blob}}))
+	require.NoError(t, err)
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+	commit, err := client.StoreObject(objects.NewCommit(tree, nil, sig, sig, content))
+	require.NoError(t, err)
+
+	data, _, err := pack.BuildPack(client, []string{commit, tree, blob})
+	require.NoError(t, err)
+	return pushed{commit: commit, pack: data}
+}
+
+func writeHook(t *testing.T, repo *repository.Repository, name, script string) {
+	t.Helper()
+	path := hooks.Path(repo, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+}
+
+// request builds a push request the way a client sends it
+func request(packData []byte, commands ...Command) []byte {
+	return requestWithCaps("report-status side-band-64k", packData, commands...)
+}
+
+func requestWithCaps(caps string, packData []byte, commands ...Command) []byte {
+	var buf bytes.Buffer
+	for i, cmd := range commands {
+		line := fmt.Sprintf("%s %s %s", cmd.Old, cmd.New, cmd.Ref)
+		if i == 0 {
+			line += "\x00" + caps
+		}
+		pktline.Write(&buf, line+"\n")
+	}
+	buf.WriteString(pktline.Flush)
+	buf.Write(packData)
+	return buf.Bytes()
+}
+
+// readReply splits a sideband reply into its report-status lines and the
+// progress channel
+func readReply(t *testing.T, reply []byte) ([]string, string) {
+	t.Helper()
+	var status bytes.Buffer
+	var progress strings.Builder
+
+	r := bufio.NewReader(bytes.NewReader(reply))
+	for {
+		packet, err := pktline.Read(r)
+		require.NoError(t, err)
+		if packet == nil {
+			break
+		}
+		switch packet[0] {
+		case pktline.BandData:
+			status.Write(packet[1:])
+		case pktline.BandProgress:
+			progress.Write(packet[1:])
+		}
+	}
+
+	var lines []string
+	sr := bufio.NewReader(&status)
+	for {
+		packet, err := pktline.Read(sr)
+		require.NoError(t, err)
+		if packet == nil {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(string(packet), "\n"))
+	}
+	return lines, progress.String()
+}
+
+func serve(t *testing.T, repo *repository.Repository, req []byte) (*Report, []string, string) {
+	t.Helper()
+	var reply bytes.Buffer
+	report, err := New(repo).Serve(bytes.NewReader(req), &reply)
+	require.NoError(t, err)
+	lines, progress := readReply(t, reply.Bytes())
+	return report, lines, progress
+}
+
+func TestAdvertiseRefs(t *testing.T) {
+	repo := setupServer(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, New(repo).AdvertiseRefs(&buf))
+	var want bytes.Buffer
+	pktline.Write(&want, zeroHash+" capabilities^{}\x00"+capabilities+"\n")
+	want.WriteString(pktline.Flush)
+	assert.Equal(t, want.String(), buf.String())
+
+	c := newCommit(t, "one")
+	require.NoError(t, pack.NewPackProcessor(repo).ProcessPack(bytes.NewReader(c.pack)))
+	require.NoError(t, repo.UpdateRef("refs/heads/main", c.commit))
+	require.NoError(t, repo.UpdateRef("refs/tags/v1", c.commit))
+
+	buf.Reset()
+	want.Reset()
+	require.NoError(t, New(repo).AdvertiseRefs(&buf))
+	pktline.Write(&want, c.commit+" refs/heads/main\x00"+capabilities+"\n")
+	pktline.Write(&want, c.commit+" refs/tags/v1\n")
+	want.WriteString(pktline.Flush)
+	assert.Equal(t, want.String(), buf.String())
+}
+
+func TestServe_CreateUpdateDelete(t *testing.T) {
+	repo := setupServer(t)
+	first := newCommit(t, "one")
+
+	report, lines, _ := serve(t, repo, request(first.pack,
+		Command{Old: zeroHash, New: first.commit, Ref: "refs/heads/main"},
+		Command{Old: zeroHash, New: first.commit, Ref: "refs/tags/v1"},
+	))
+	assert.Equal(t, []string{"unpack ok", "ok refs/heads/main", "ok refs/tags/v1"}, lines)
+	assert.Equal(t, []string{"refs/heads/main", "refs/tags/v1"}, report.Updated)
+	head, err := repo.ReadRef("refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, first.commit, head)
+	assert.True(t, repo.HasObject(first.commit))
+
+	second := newCommit(t, "two")
+	_, lines, _ = serve(t, repo, request(second.pack,
+		Command{Old: first.commit, New: second.commit, Ref: "refs/heads/main"},
+		// the client's view of the tag is out of date
+		Command{Old: second.commit, New: zeroHash, Ref: "refs/tags/v1"},
+	))
+	assert.Equal(t, []string{"unpack ok", "ok refs/heads/main", "ng refs/tags/v1 stale info"}, lines)
+
+	_, lines, _ = serve(t, repo, request(nil, Command{Old: first.commit, New: zeroHash, Ref: "refs/tags/v1"}))
+	assert.Equal(t, []string{"unpack ok", "ok refs/tags/v1"}, lines)
+	_, err = repo.ReadRef("refs/tags/v1")
+	assert.Error(t, err)
+
+	_, lines, _ = serve(t, repo, request(nil, Command{Old: second.commit, New: zeroHash, Ref: "refs/heads/main"}))
+	assert.Equal(t, []string{"unpack ok", "ng refs/heads/main deletion of the current branch prohibited"}, lines)
+}
+
+func TestServe_FunnyRefname(t *testing.T) {
+	repo := setupServer(t)
+	c := newCommit(t, "one")
+
+	report, lines, _ := serve(t, repo, request(c.pack,
+		Command{Old: zeroHash, New: c.commit, Ref: "refs/../../outside"},
+		Command{Old: zeroHash, New: c.commit, Ref: "refs/heads/main.lock"},
+		Command{Old: zeroHash, New: c.commit, Ref: "refs/heads/main"},
+	))
+	assert.Equal(t, []string{
+		"unpack ok",
+		"ng refs/../../outside funny refname",
+		"ng refs/heads/main.lock funny refname",
+		"ok refs/heads/main",
+	}, lines)
+	assert.Equal(t, []string{"refs/heads/main"}, report.Updated)
+	assert.NoFileExists(t, filepath.Join(repo.GitDir, "..", "outside"))
+	assert.NoFileExists(t, filepath.Join(repo.GitDir, "..", "..", "outside"))
+}
+
+func TestServe_BadPack(t *testing.T) {
+	repo := setupServer(t)
+	c := newCommit(t, "one")
+	broken := bytes.Clone(c.pack)
+	broken[len(broken)-1] ^= 0xff
+
+	report, lines, _ := serve(t, repo, request(broken, Command{Old: zeroHash, New: c.commit, Ref: "refs/heads/main"}))
+	require.NotEmpty(t, report.UnpackError)
+	assert.Equal(t, []string{"unpack " + report.UnpackError, "ng refs/heads/main unpacker error"}, lines)
+	_, err := repo.ReadRef("refs/heads/main")
+	assert.Error(t, err)
+}
+
+func TestServe_PreReceiveDeclines(t *testing.T) {
+	repo := setupServer(t)
+	writeHook(t, repo, hooks.PreReceive, "while read old new ref; do echo \"refusing $ref\"; done\nexit 1\n")
+	c := newCommit(t, "one")
+
+	report, lines, progress := serve(t, repo, request(c.pack,
+		Command{Old: zeroHash, New: c.commit, Ref: "refs/heads/main"},
+		Command{Old: zeroHash, New: c.commit, Ref: "refs/heads/topic"},
+	))
+	assert.Equal(t, []string{
+		"unpack ok",
+		"ng refs/heads/main pre-receive hook declined",
+		"ng refs/heads/topic pre-receive hook declined",
+	}, lines)
+	assert.Empty(t, report.Updated)
+	assert.Equal(t, "refusing refs/heads/main\nrefusing refs/heads/topic\n", progress)
+
+	refs, err := repo.ListRefs()
+	require.NoError(t, err)
+	assert.Empty(t, refs)
+}
+
+func TestServe_UpdateHookRejectsOneRef(t *testing.T) {
+	repo := setupServer(t)
+	writeHook(t, repo, hooks.Update, `if [ "$1" = refs/heads/locked ]; then
+	echo "$1 is locked"
+	exit 1
+fi
+`)
+	writeHook(t, repo, hooks.PostReceive, "cat > received\necho done\n")
+	c := newCommit(t, "one")
+
+	report, lines, progress := serve(t, repo, request(c.pack,
+		Command{Old: zeroHash, New: c.commit, Ref: "refs/heads/locked"},
+		Command{Old: zeroHash, New: c.commit, Ref: "refs/heads/main"},
+	))
+	assert.Equal(t, []string{"unpack ok", "ng refs/heads/locked hook declined", "ok refs/heads/main"}, lines)
+	assert.Equal(t, map[string]string{"refs/heads/locked": "hook declined"}, report.Rejected)
+	assert.Equal(t, "refs/heads/locked is locked\ndone\n", progress)
+
+	received, err := os.ReadFile(filepath.Join(repo.WorkDir, "received"))
+	require.NoError(t, err)
+	assert.Equal(t, zeroHash+" "+c.commit+" refs/heads/main\n", string(received))
+
+	_, err = repo.ReadRef("refs/heads/locked")
+	assert.Error(t, err)
+}
+
+func TestServe_WithoutSideband(t *testing.T) {
+	repo := setupServer(t)
+	writeHook(t, repo, hooks.Update, "echo not relayed\n")
+	c := newCommit(t, "one")
+
+	req := requestWithCaps("report-status", c.pack, Command{Old: zeroHash, New: c.commit, Ref: "refs/heads/main"})
+
+	var reply bytes.Buffer
+	report, err := New(repo).Serve(bytes.NewReader(req), &reply)
+	require.NoError(t, err)
+	assert.Equal(t, "not relayed\n", report.HookOutput)
+
+	var want bytes.Buffer
+	pktline.Write(&want, "unpack ok\n")
+	pktline.Write(&want, "ok refs/heads/main\n")
+	want.WriteString(pktline.Flush)
+	assert.Equal(t, want.String(), reply.String())
+}
+
+func TestReadPack_StopsAtTrailer(t *testing.T) {
+	c := newCommit(t, "one")
+	r := bufio.NewReader(io.MultiReader(bytes.NewReader(c.pack), strings.NewReader("rest")))
+
+	data, err := readPack(r)
+	require.NoError(t, err)
+	assert.Equal(t, c.pack, data)
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "rest", string(rest))
+}
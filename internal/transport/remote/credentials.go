@@ -0,0 +1,218 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/config"
+)
+
+const (
+	credentialSection = "credential"
+	coreSection       = "core"
+	globalConfigFile  = ".gitconfig"
+	netrcFile         = ".netrc"
+	netrcEnv          = "NETRC"
+	helperPrefix      = "git-credential-"
+)
+
+// Credentials are the username and password, or token, for one host
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+func (c Credentials) complete() bool {
+	return c.Token != "" || (c.Username != "" && c.Password != "")
+}
+
+// ForURL returns the credentials to use for remoteURL. Those already set
+// on a, from the environment, come first; then the credential helper's
+// answer for the URL's host; then the host's netrc entry.
+func (a *AuthConfig) ForURL(remoteURL string) *AuthConfig {
+	if a == nil {
+		return nil
+	}
+	resolved := *a
+	if (Credentials{Username: a.Username, Password: a.Password, Token: a.Token}).complete() {
+		return &resolved
+	}
+
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Host == "" {
+		return &resolved
+	}
+
+	creds, ok := runCredentialHelper(a.Helper, u)
+	if !ok {
+		if creds, ok = a.Hosts[u.Hostname()]; !ok {
+			creds = a.Hosts[""]
+		}
+	}
+	if creds.complete() {
+		resolved.Username, resolved.Password, resolved.Token = creds.Username, creds.Password, creds.Token
+	}
+	return &resolved
+}
+
+// ForRemote returns a with the remote's own SSH key, if it has one
+func (a *AuthConfig) ForRemote(r *Remote) *AuthConfig {
+	if a == nil || r == nil || r.SSHKey == "" {
+		return a
+	}
+	resolved := *a
+	resolved.SSHKey = r.SSHKey
+	return &resolved
+}
+
+// configValue reads section.key from the global config and then the
+// repository's config in gitDir, the later one winning. Unreadable config
+// files are skipped.
+func configValue(homeDir, gitDir, section, key string) string {
+	paths := []string{filepath.Join(homeDir, globalConfigFile)}
+	if gitDir != "" {
+		paths = append(paths, filepath.Join(gitDir, "config"))
+	}
+
+	var value string
+	for _, path := range paths {
+		cfg, err := config.Load(path)
+		if err != nil {
+			continue
+		}
+		if v, ok := cfg.Get(section, "", key); ok {
+			value = v
+		}
+	}
+	return value
+}
+
+// runCredentialHelper asks helper for the credentials of u with the
+// "git credential fill" protocol. A helper that fails or answers without a
+// password is treated as having no credentials.
+func runCredentialHelper(helper string, u *url.URL) (Credentials, bool) {
+	if helper == "" {
+		return Credentials{}, false
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case strings.HasPrefix(helper, "!"):
+		cmd = exec.Command("sh", "-c", helper[1:]+" get")
+	case filepath.IsAbs(helper):
+		cmd = exec.Command("sh", "-c", helper+" get")
+	default:
+		cmd = exec.Command("sh", "-c", helperPrefix+helper+" get")
+	}
+
+	var request strings.Builder
+	request.WriteString("protocol=" + u.Scheme + "\n")
+	request.WriteString("host=" + u.Host + "\n")
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		request.WriteString("path=" + path + "\n")
+	}
+	if u.User != nil && u.User.Username() != "" {
+		request.WriteString("username=" + u.User.Username() + "\n")
+	}
+	request.WriteString("\n")
+	cmd.Stdin = strings.NewReader(request.String())
+
+	output, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, false
+	}
+
+	var creds Credentials
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "username":
+			creds.Username = value
+		case "password":
+			creds.Password = value
+		}
+	}
+	return creds, creds.complete()
+}
+
+// loadNetrc reads the netrc file named by $NETRC, or ~/.netrc. A missing or
+// unreadable file has no entries.
+func loadNetrc(homeDir string) map[string]Credentials {
+	path := os.Getenv(netrcEnv)
+	if path == "" {
+		path = filepath.Join(homeDir, netrcFile)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseNetrc(data)
+}
+
+// parseNetrc returns the login and password of each machine entry, with
+// the default entry under the empty host name. Macro definitions are
+// skipped.
+func parseNetrc(data []byte) map[string]Credentials {
+	hosts := make(map[string]Credentials)
+
+	var host string
+	var creds Credentials
+	inEntry := false
+	flush := func() {
+		if inEntry {
+			if _, seen := hosts[host]; !seen {
+				hosts[host] = creds
+			}
+		}
+		creds = Credentials{}
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			next := func() string {
+				if j+1 < len(fields) {
+					j++
+					return fields[j]
+				}
+				return ""
+			}
+
+			switch fields[j] {
+			case "machine":
+				flush()
+				host, inEntry = next(), true
+			case "default":
+				flush()
+				host, inEntry = "", true
+			case "login":
+				creds.Username = next()
+			case "password":
+				creds.Password = next()
+			case "account":
+				next()
+			case "macdef":
+				// a macro runs until the next blank line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	flush()
+
+	return hosts
+}
@@ -0,0 +1,175 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	gitScheme       = "git"
+	gitDaemonPort   = "9418"
+	errPacketPrefix = "ERR "
+
+	emptyCapabilitiesRef = "capabilities^{}"
+)
+
+// GitTransport speaks git daemon's anonymous git:// protocol. Every request
+// is a connection of its own, opened with a "<service> <path>\0host=<host>\0"
+// packet and answered with the ref advertisement.
+type GitTransport struct {
+	// addr is the host and port dialed, host the one named in the URL
+	addr   string
+	host   string
+	path   string
+	dialer net.Dialer
+}
+
+// gitConn is a daemon connection read through the buffer its ref
+// advertisement was read with
+type gitConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *gitConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func NewGitTransport(remoteURL string) (*GitTransport, error) {
+	parsedURL, err := url.Parse(remoteURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if parsedURL.Scheme != gitScheme || parsedURL.Hostname() == "" || parsedURL.Path == "" {
+		return nil, fmt.Errorf("%w: %s", errors.ErrInvalidURL, remoteURL)
+	}
+
+	port := parsedURL.Port()
+	if port == "" {
+		port = gitDaemonPort
+	}
+
+	return &GitTransport{
+		addr:   net.JoinHostPort(parsedURL.Hostname(), port),
+		host:   parsedURL.Host,
+		path:   parsedURL.Path,
+		dialer: net.Dialer{Timeout: dialTimeout, KeepAlive: keepAliveTimeout},
+	}, nil
+}
+
+// Connect checks that the daemon serves the repository
+func (t *GitTransport) Connect(ctx context.Context, url string) error {
+	_, err := t.ListRefs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	return nil
+}
+
+func (t *GitTransport) Disconnect() error {
+	return nil
+}
+
+func (t *GitTransport) ListRefs(ctx context.Context) (map[string]string, error) {
+	conn, refs, err := t.open(ctx, gitUploadPack)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// a flush instead of wants ends the session
+	conn.Write([]byte(flushPacket))
+	return refs, nil
+}
+
+func (t *GitTransport) FetchPack(ctx context.Context, wants, haves []string) (PackReader, error) {
+	conn, _, err := t.open(ctx, gitUploadPack)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(buildPackRequest(wants, haves)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send pack request: %w", err)
+	}
+	return conn, nil
+}
+
+// SendPack pushes to a daemon that has receive-pack enabled, which git
+// daemon does not by default
+func (t *GitTransport) SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) (*PushReport, error) {
+	conn, _, err := t.open(ctx, gitReceivePack)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request := buildPushRequest(refs)
+	if packData != nil {
+		request = append(request, packData...)
+	}
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to send pack: %w", err)
+	}
+
+	return readPushReport(conn.reader)
+}
+
+func (t *GitTransport) Close() error {
+	return t.Disconnect()
+}
+
+// open connects to the daemon, asks for service on the repository and
+// reads the refs it advertises
+func (t *GitTransport) open(ctx context.Context, service string) (*gitConn, map[string]string, error) {
+	netConn, err := t.dialer.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to %s: %w", t.addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		netConn.SetDeadline(deadline)
+	}
+	conn := &gitConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+
+	line := fmt.Sprintf("%s %s\x00host=%s\x00", service, t.path, t.host)
+	if _, err := fmt.Fprintf(conn, "%04x%s", len(line)+packetHeaderSize, line); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to send %s request: %w", service, err)
+	}
+
+	refs, err := readAdvertisement(conn.reader)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, refs, nil
+}
+
+// readAdvertisement reads a ref advertisement up to its flush packet. A
+// daemon refusing the request answers with an "ERR" packet instead.
+func readAdvertisement(r *bufio.Reader) (map[string]string, error) {
+	var advertisement bytes.Buffer
+	for {
+		packet, err := readPacket(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ref advertisement: %w", err)
+		}
+		if packet == nil {
+			refs, err := parseGitRefs(&advertisement)
+			// an empty repository advertises its capabilities on a dummy ref
+			delete(refs, emptyCapabilitiesRef)
+			return refs, err
+		}
+		if message, ok := strings.CutPrefix(string(packet), errPacketPrefix); ok {
+			return nil, fmt.Errorf("%w: %s", errors.ErrRemoteError, strings.TrimSpace(message))
+		}
+		fmt.Fprintf(&advertisement, "%04x%s", len(packet)+packetHeaderSize, packet)
+	}
+}
@@ -0,0 +1,57 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LsRemote lists the refs advertised by the repository at url. With
+// patterns, only refs matching at least one of them are kept.
+func LsRemote(ctx context.Context, url string, auth *AuthConfig, patterns ...string) (map[string]string, error) {
+	transport, err := CreateTransport(url, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer transport.Close()
+
+	return lsRemote(ctx, transport, url, patterns)
+}
+
+func lsRemote(ctx context.Context, transport Transport, url string, patterns []string) (map[string]string, error) {
+	if err := transport.Connect(ctx, url); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", url, err)
+	}
+
+	refs, err := transport.ListRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 0 {
+		return refs, nil
+	}
+	matched := make(map[string]string)
+	for ref, hash := range refs {
+		if MatchRefPattern(ref, patterns) {
+			matched[ref] = hash
+		}
+	}
+	return matched, nil
+}
+
+// MatchRefPattern reports whether ref matches any of patterns. Patterns are
+// matched against the ref's trailing components, so "main" matches
+// refs/heads/main, and "*" may match across slashes, so "refs/heads/*"
+// matches every branch.
+func MatchRefPattern(ref string, patterns []string) bool {
+	for _, pattern := range patterns {
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+		if ok, _ := regexp.MatchString("(^|/)"+expr+"$", ref); ok {
+			return true
+		}
+	}
+	return false
+}
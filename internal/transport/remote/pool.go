@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	maxIdleConns        = 64
+	maxIdleConnsPerHost = 8
+	idleConnTimeout     = 90 * time.Second
+	tlsHandshakeTimeout = 10 * time.Second
+
+	// maxDrainBytes bounds how much of an unread response body is read on
+	// Close to keep its connection; anything longer is cheaper to drop
+	maxDrainBytes = 256 << 10
+)
+
+// sharedRoundTripper pools connections for every HTTPTransport, so the
+// requests of one clone or push, and transports opened one after another
+// against the same host, reuse connections instead of handshaking again
+var sharedRoundTripper = newRoundTripper(&tls.Config{})
+
+// newRoundTripper builds a pooling transport that speaks HTTP/2 where the
+// server offers it. Setting TLSClientConfig or DialContext turns off
+// net/http's automatic HTTP/2, hence ForceAttemptHTTP2.
+func newRoundTripper(tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: keepAliveTimeout,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+		TLSHandshakeTimeout: tlsHandshakeTimeout,
+	}
+}
+
+// WithHTTPClient makes t send its requests through client, for callers
+// that manage their own connection pool. It returns t for chaining.
+func (t *HTTPTransport) WithHTTPClient(client *http.Client) *HTTPTransport {
+	t.client = client
+	return t
+}
+
+// drainingBody reads what is left of a response body, up to maxDrainBytes,
+// when it is closed. An HTTP/1.1 connection only goes back to the pool once
+// its response has been read to the end, which older net/http releases do
+// not do for a body closed early, and callers often stop early: at the
+// flush packet ending a pack, or on an error status.
+type drainingBody struct {
+	io.ReadCloser
+}
+
+func (b drainingBody) Close() error {
+	io.CopyN(io.Discard, b.ReadCloser, maxDrainBytes)
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// Refspec maps the refs of a remote onto local refs, as in
+// "+refs/heads/*:refs/remotes/origin/*". A "*" in the source matches any
+// part of a ref name, slashes included, and is put in place of the "*" in
+// the destination.
+type Refspec struct {
+	Src string
+	// Dst is empty for a refspec that fetches refs without storing them
+	Dst string
+	// Force updates Dst even when the update is not a fast-forward
+	Force bool
+}
+
+// ParseRefspec parses a refspec such as "+refs/heads/*:refs/remotes/origin/*"
+// or "main:refs/mirror/main". Short destinations name branches.
+func ParseRefspec(spec string) (Refspec, error) {
+	var r Refspec
+	rest, force := strings.CutPrefix(spec, "+")
+	r.Force = force
+	r.Src, r.Dst, _ = strings.Cut(rest, ":")
+
+	// negative refspecs are not supported
+	if r.Src == "" || strings.HasPrefix(r.Src, "^") {
+		return Refspec{}, fmt.Errorf("%w: %q", errors.ErrInvalidRefspec, spec)
+	}
+
+	srcWildcards, dstWildcards := strings.Count(r.Src, "*"), strings.Count(r.Dst, "*")
+	if srcWildcards > 1 || dstWildcards > 1 || (r.Dst != "" && srcWildcards != dstWildcards) {
+		return Refspec{}, fmt.Errorf("%w: %q: patterns must both have a single '*'", errors.ErrInvalidRefspec, spec)
+	}
+
+	if r.Dst != "" && r.Dst != "HEAD" && !strings.HasPrefix(r.Dst, refsPrefix) {
+		r.Dst = headsPrefix + r.Dst
+	}
+	return r, nil
+}
+
+// DefaultFetchRefspec is the refspec a remote without remote.<name>.fetch
+// settings fetches with
+func DefaultFetchRefspec(remoteName string) Refspec {
+	return Refspec{
+		Src:   headsPrefix + "*",
+		Dst:   remotesPrefix + remoteName + "/*",
+		Force: true,
+	}
+}
+
+func (r Refspec) String() string {
+	spec := r.Src
+	if r.Dst != "" {
+		spec += ":" + r.Dst
+	}
+	if r.Force {
+		spec = "+" + spec
+	}
+	return spec
+}
+
+// IsWildcard reports whether r maps a whole namespace of refs
+func (r Refspec) IsWildcard() bool {
+	return strings.Contains(r.Src, "*")
+}
+
+// Map returns the local ref the remote ref is stored under, and whether r
+// matches it at all. A source without a "refs/" prefix names a branch or a
+// tag. A match of a refspec without a destination is returned as "".
+func (r Refspec) Map(ref string) (string, bool) {
+	prefix, suffix, wildcard := strings.Cut(r.Src, "*")
+	if !wildcard {
+		if ref != r.Src && ref != headsPrefix+r.Src && ref != tagsPrefix+r.Src {
+			return "", false
+		}
+		return r.Dst, true
+	}
+
+	if len(ref) < len(prefix)+len(suffix) || !strings.HasPrefix(ref, prefix) || !strings.HasSuffix(ref, suffix) {
+		return "", false
+	}
+	match := ref[len(prefix) : len(ref)-len(suffix)]
+	return strings.Replace(r.Dst, "*", match, 1), true
+}
+
+// FetchRefspecs parses the remote's fetch refspecs, falling back to the
+// default one when it has none
+func (r *Remote) FetchRefspecs() ([]Refspec, error) {
+	if len(r.Fetch) == 0 {
+		return []Refspec{DefaultFetchRefspec(r.Name)}, nil
+	}
+
+	refspecs := make([]Refspec, 0, len(r.Fetch))
+	for _, spec := range r.Fetch {
+		refspec, err := ParseRefspec(spec)
+		if err != nil {
+			return nil, errors.NewGitError("remote", r.Name, err)
+		}
+		refspecs = append(refspecs, refspec)
+	}
+	return refspecs, nil
+}
@@ -4,23 +4,25 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
-	"net"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/unkn0wn-root/git-go/internal/core/config"
 	"github.com/unkn0wn-root/git-go/internal/core/hash"
 	"github.com/unkn0wn-root/git-go/internal/core/repository"
 	"github.com/unkn0wn-root/git-go/internal/transport/ssh"
 	"github.com/unkn0wn-root/git-go/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 const (
@@ -29,9 +31,6 @@ const (
 	keepAliveTimeout = 30 * time.Second
 	packetHeaderSize = 4
 
-	// This here is for git(hub|lab) when you try to push to remote repo without any files (plain)
-	nullHash = "0000000000000000000000000000000000000000"
-
 	// Git protocol
 	servicePrefix  = "# service="
 	gitUploadPack  = "git-upload-pack"
@@ -41,12 +40,26 @@ const (
 	doneCommand    = "0009done\n"
 
 	// Content types
-	uploadPackType  = "application/x-git-upload-pack-request"
-	receivePackType = "application/x-git-receive-pack-request"
+	uploadPackType          = "application/x-git-upload-pack-request"
+	receivePackType         = "application/x-git-receive-pack-request"
+	uploadPackAdvertisement = "application/x-git-upload-pack-advertisement"
+
+	// infoRefsPath is what a ref advertisement URL adds to the repository URL
+	infoRefsPath = "/info/refs"
+	// maxFirstLine bounds how much of a bad response is quoted in errors
+	maxFirstLine = 80
 
 	// Default capabilities
 	defaultCapabilities = "multi_ack_detailed no-done side-band-64k thin-pack ofs-delta"
 	pushCapabilities    = "report-status side-band-64k"
+
+	remoteSection  = "remote"
+	branchSection  = "branch"
+	refsPrefix     = "refs/"
+	headsPrefix    = "refs/heads/"
+	tagsPrefix     = "refs/tags/"
+	remotesPrefix  = "refs/remotes/"
+	packedRefsFile = "packed-refs"
 )
 
 type Protocol int
@@ -63,7 +76,9 @@ type Transport interface {
 	Disconnect() error
 	ListRefs(ctx context.Context) (map[string]string, error)
 	FetchPack(ctx context.Context, wants, haves []string) (PackReader, error)
-	SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) error
+	// SendPack pushes the ref updates and pack, returning the server's
+	// report of which refs it accepted
+	SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) (*PushReport, error)
 	Close() error
 }
 
@@ -72,12 +87,37 @@ type PackReader interface {
 	Close() error
 }
 
+// ResumableTransport is a Transport that can resume an interrupted fetch,
+// asking for the response to the same request from a byte offset on
+type ResumableTransport interface {
+	Transport
+	// FetchPackFrom returns the fetch response from offset on, or from the
+	// start when the server cannot serve part of it. The second result is
+	// the offset the returned reader starts at.
+	FetchPackFrom(ctx context.Context, wants, haves []string, offset int64) (PackReader, int64, error)
+}
+
 type AuthConfig struct {
 	Username string
 	Password string
 	Token    string
-	SSHKey   string
-}
+	// SSHKey is a private key tried before ssh-agent and the usual key
+	// files in ~/.ssh
+	SSHKey string
+	// SSHCommand replaces the ssh program, from $GIT_SSH_COMMAND or
+	// core.sshCommand
+	SSHCommand string
+	// Helper is the credential.helper command asked for the credentials of
+	// hosts that none of the fields above cover
+	Helper string
+	// Hosts holds credentials by host name, as read from ~/.netrc; the
+	// netrc "default" entry is kept under the empty name
+	Hosts map[string]Credentials
+}
+
+// ZeroHash stands for a ref that does not exist: as the old hash of an
+// update it creates the ref, and as the new hash it deletes it
+const ZeroHash = "0000000000000000000000000000000000000000"
 
 type RefUpdate struct {
 	RefName string
@@ -90,11 +130,17 @@ type Remote struct {
 	URL      string
 	FetchURL string
 	PushURL  string
+	// Fetch holds the remote's fetch refspecs
+	Fetch []string
+	// SSHKey is the private key to use for this remote, from
+	// remote.<name>.sshKey
+	SSHKey string
 }
 
 type RemoteConfig struct {
 	remotes map[string]*Remote
 	gitDir  string
+	config  *config.Config
 }
 
 func NewRemoteConfig(gitDir string) *RemoteConfig {
@@ -105,76 +151,72 @@ func NewRemoteConfig(gitDir string) *RemoteConfig {
 }
 
 func (rc *RemoteConfig) Load() error {
-	configPath := filepath.Join(rc.gitDir, "config")
-
-	file, err := os.Open(configPath)
+	cfg, err := rc.file()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return errors.NewGitError("config", configPath, err)
+		return err
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var currentRemote *Remote
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	for _, name := range cfg.Subsections(remoteSection) {
+		url, _ := cfg.Get(remoteSection, name, "url")
+		remote := &Remote{
+			Name:     name,
+			URL:      url,
+			FetchURL: url,
+			PushURL:  url,
+			Fetch:    cfg.GetAll(remoteSection, name, "fetch"),
 		}
-
-		if strings.HasPrefix(line, "[remote \"") && strings.HasSuffix(line, "\"]") {
-			remoteName := line[9 : len(line)-2]
-			currentRemote = &Remote{Name: remoteName}
-			rc.remotes[remoteName] = currentRemote
-		} else if currentRemote != nil {
-			parts := strings.SplitN(line, "=", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-
-				switch key {
-				case "url":
-					currentRemote.URL = value
-					currentRemote.FetchURL = value
-					currentRemote.PushURL = value
-				case "fetch":
-					// do not proccess refspec
-				case "pushurl":
-					currentRemote.PushURL = value
-				}
-			}
+		if pushURL, ok := cfg.Get(remoteSection, name, "pushurl"); ok {
+			remote.PushURL = pushURL
 		}
+		remote.SSHKey, _ = cfg.Get(remoteSection, name, "sshkey")
+		rc.remotes[name] = remote
 	}
 
-	return scanner.Err()
+	return nil
 }
 
+// Save writes the remotes into the repository config, leaving every other
+// section of the file untouched
 func (rc *RemoteConfig) Save() error {
-	configPath := filepath.Join(rc.gitDir, "config")
-
-	file, err := os.Create(configPath)
+	cfg, err := rc.file()
 	if err != nil {
-		return errors.NewGitError("config", configPath, err)
+		return err
 	}
-	defer file.Close()
 
-	for _, remote := range rc.remotes {
-		fmt.Fprintf(file, "[remote \"%s\"]\n", remote.Name)
-		fmt.Fprintf(file, "\turl = %s\n", remote.URL)
-		if remote.FetchURL != remote.URL {
-			fmt.Fprintf(file, "\tfetch = %s\n", remote.FetchURL)
+	for _, remote := range rc.ListRemotes() {
+		cfg.Set(remoteSection, remote.Name, "url", remote.URL)
+
+		if remote.PushURL != "" && remote.PushURL != remote.URL {
+			cfg.Set(remoteSection, remote.Name, "pushurl", remote.PushURL)
+		} else {
+			cfg.Unset(remoteSection, remote.Name, "pushurl")
 		}
-		if remote.PushURL != remote.URL {
-			fmt.Fprintf(file, "\tpushurl = %s\n", remote.PushURL)
+
+		fetch := remote.Fetch
+		if len(fetch) == 0 {
+			fetch = []string{DefaultFetchRefspec(remote.Name).String()}
+		}
+		if !slices.Equal(cfg.GetAll(remoteSection, remote.Name, "fetch"), fetch) {
+			cfg.Unset(remoteSection, remote.Name, "fetch")
+			for _, refspec := range fetch {
+				cfg.Add(remoteSection, remote.Name, "fetch", refspec)
+			}
 		}
-		fmt.Fprintf(file, "\tfetch = +refs/heads/*:refs/remotes/%s/*\n", remote.Name)
-		fmt.Fprintln(file)
 	}
 
-	return nil
+	return cfg.Save()
+}
+
+// file returns the repository config, read on first use
+func (rc *RemoteConfig) file() (*config.Config, error) {
+	if rc.config == nil {
+		cfg, err := config.Load(filepath.Join(rc.gitDir, "config"))
+		if err != nil {
+			return nil, err
+		}
+		rc.config = cfg
+	}
+	return rc.config, nil
 }
 
 func (rc *RemoteConfig) AddRemote(name, url string) error {
@@ -197,7 +239,77 @@ func (rc *RemoteConfig) RemoveRemote(name string) error {
 		return errors.NewGitError("remote", name, fmt.Errorf("remote not found"))
 	}
 
+	cfg, err := rc.file()
+	if err != nil {
+		return err
+	}
+
 	delete(rc.remotes, name)
+	cfg.RemoveSection(remoteSection, name)
+	return rc.Save()
+}
+
+// Rename renames a remote along with its fetch refspecs, remote-tracking
+// refs and reflogs, and any branch.<name>.remote settings pointing at it
+func (rc *RemoteConfig) Rename(oldName, newName string) error {
+	remote, exists := rc.remotes[oldName]
+	if !exists {
+		return errors.NewGitError("remote", oldName, errors.ErrRemoteNotFound)
+	}
+	if newName == "" || strings.ContainsAny(newName, " \t/\\") {
+		return errors.NewGitError("remote", newName, fmt.Errorf("invalid remote name"))
+	}
+	if _, exists := rc.remotes[newName]; exists {
+		return errors.NewGitError("remote", newName, errors.ErrRemoteAlreadyExists)
+	}
+
+	cfg, err := rc.file()
+	if err != nil {
+		return err
+	}
+
+	if err := renameTrackingRefs(rc.gitDir, oldName, newName); err != nil {
+		return errors.NewGitError("remote", oldName, err)
+	}
+
+	oldPrefix := remotesPrefix + oldName + "/"
+	newPrefix := remotesPrefix + newName + "/"
+	for i, refspec := range remote.Fetch {
+		remote.Fetch[i] = strings.ReplaceAll(refspec, oldPrefix, newPrefix)
+	}
+
+	cfg.RenameSection(remoteSection, oldName, newName)
+	for _, branch := range cfg.Subsections(branchSection) {
+		if value, _ := cfg.Get(branchSection, branch, "remote"); value == oldName {
+			cfg.Set(branchSection, branch, "remote", newName)
+		}
+	}
+
+	delete(rc.remotes, oldName)
+	remote.Name = newName
+	rc.remotes[newName] = remote
+
+	return rc.Save()
+}
+
+// SetURL changes the URL of a remote, or only its push URL when push is set
+func (rc *RemoteConfig) SetURL(name, url string, push bool) error {
+	remote, exists := rc.remotes[name]
+	if !exists {
+		return errors.NewGitError("remote", name, errors.ErrRemoteNotFound)
+	}
+
+	if push {
+		remote.PushURL = url
+	} else {
+		// a push URL that merely followed the URL keeps following it
+		if remote.PushURL == remote.URL {
+			remote.PushURL = url
+		}
+		remote.URL = url
+		remote.FetchURL = url
+	}
+
 	return rc.Save()
 }
 
@@ -222,6 +334,71 @@ func (rc *RemoteConfig) ListRemotes() []*Remote {
 	return remotes
 }
 
+// renameTrackingRefs moves refs/remotes/<old> to refs/remotes/<new> in the
+// loose refs, their reflogs and packed-refs, repointing symbolic refs such as
+// refs/remotes/<old>/HEAD along the way
+func renameTrackingRefs(gitDir, oldName, newName string) error {
+	oldPrefix := remotesPrefix + oldName + "/"
+	newPrefix := remotesPrefix + newName + "/"
+
+	for _, base := range []string{gitDir, filepath.Join(gitDir, "logs")} {
+		oldDir := filepath.Join(base, filepath.FromSlash(remotesPrefix+oldName))
+		newDir := filepath.Join(base, filepath.FromSlash(remotesPrefix+newName))
+
+		if _, err := os.Stat(oldDir); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := os.Stat(newDir); err == nil {
+			return fmt.Errorf("%s already exists", newDir)
+		}
+		if err := os.Rename(oldDir, newDir); err != nil {
+			return err
+		}
+	}
+
+	refsDir := filepath.Join(gitDir, filepath.FromSlash(remotesPrefix+newName))
+	err := filepath.WalkDir(refsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "ref: "+oldPrefix); ok {
+			return os.WriteFile(path, []byte("ref: "+newPrefix+target+"\n"), 0644)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	packedPath := filepath.Join(gitDir, packedRefsFile)
+	content, err := os.ReadFile(packedPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	changed := false
+	for i, line := range lines {
+		hash, ref, ok := strings.Cut(line, " ")
+		if ok && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "^") && strings.HasPrefix(ref, oldPrefix) {
+			lines[i] = hash + " " + newPrefix + strings.TrimPrefix(ref, oldPrefix)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	return os.WriteFile(packedPath, []byte(strings.Join(lines, "\n")), 0644)
+}
+
 func DetectProtocol(url string) Protocol {
 	switch {
 	case strings.HasPrefix(url, "https://"):
@@ -242,9 +419,11 @@ func CreateTransport(remoteURL string, auth *AuthConfig) (Transport, error) {
 
 	switch protocol {
 	case ProtocolHTTP, ProtocolHTTPS:
-		return NewHTTPTransport(remoteURL, auth)
+		return NewHTTPTransport(remoteURL, auth.ForURL(remoteURL))
 	case ProtocolSSH:
 		return NewSSHTransport(remoteURL, auth)
+	case ProtocolGit:
+		return NewGitTransport(remoteURL)
 	default:
 		return nil, fmt.Errorf("unsupported protocol for URL: %s", remoteURL)
 	}
@@ -255,6 +434,7 @@ type HTTPTransport struct {
 	baseURL  *url.URL
 	username string
 	password string
+	retry    RetryPolicy
 }
 
 func NewHTTPTransport(remoteURL string, auth *AuthConfig) (*HTTPTransport, error) {
@@ -264,21 +444,14 @@ func NewHTTPTransport(remoteURL string, auth *AuthConfig) (*HTTPTransport, error
 	}
 
 	client := &http.Client{
-		Timeout: httpTimeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: false,
-			},
-			DialContext: (&net.Dialer{
-				Timeout:   dialTimeout,
-				KeepAlive: keepAliveTimeout,
-			}).DialContext,
-		},
+		Timeout:   httpTimeout,
+		Transport: sharedRoundTripper,
 	}
 
 	transport := &HTTPTransport{
 		client:  client,
 		baseURL: parsedURL,
+		retry:   DefaultRetryPolicy,
 	}
 
 	if auth != nil {
@@ -294,17 +467,15 @@ func NewHTTPTransport(remoteURL string, auth *AuthConfig) (*HTTPTransport, error
 	return transport, nil
 }
 
-func (t *HTTPTransport) Connect(ctx context.Context, url string) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", url+"/info/refs?service="+gitUploadPack, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	if t.username != "" && t.password != "" {
-		req.SetBasicAuth(t.username, t.password)
-	}
+// WithRetryPolicy sets how requests are retried after transient failures.
+// It returns t for chaining.
+func (t *HTTPTransport) WithRetryPolicy(policy RetryPolicy) *HTTPTransport {
+	t.retry = policy
+	return t
+}
 
-	resp, err := t.client.Do(req)
+func (t *HTTPTransport) Connect(ctx context.Context, url string) error {
+	resp, err := t.do(ctx, http.MethodGet, url+"/info/refs?service="+gitUploadPack, "", nil, true)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -321,45 +492,88 @@ func (t *HTTPTransport) Disconnect() error {
 	return nil
 }
 
+// ListRefs fetches the smart HTTP ref advertisement. When the server
+// redirects it, later requests go to the repository URL it redirected to.
 func (t *HTTPTransport) ListRefs(ctx context.Context) (map[string]string, error) {
-	url := fmt.Sprintf("%s/info/refs?service=%s", t.baseURL.String(), gitUploadPack)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	url := fmt.Sprintf("%s%s?service=%s", t.baseURL.String(), infoRefsPath, gitUploadPack)
+	resp, err := t.do(ctx, http.MethodGet, url, "", nil, true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	if t.username != "" && t.password != "" {
-		req.SetBasicAuth(t.username, t.password)
+		return nil, fmt.Errorf("failed to list refs: %w", err)
 	}
+	defer resp.Body.Close()
 
-	resp, err := t.client.Do(req)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list refs: %w", err)
+		return nil, fmt.Errorf("failed to read refs data: %w", err)
 	}
-	defer resp.Body.Close()
 
+	finalURL := resp.Request.URL.Redacted()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+		return nil, fmt.Errorf("failed to list refs from %s: %s: %q", finalURL, resp.Status, firstLine(body))
+	}
+	if err := validateAdvertisement(resp.Header.Get("Content-Type"), body); err != nil {
+		return nil, fmt.Errorf("failed to list refs from %s: %w", finalURL, err)
 	}
 
-	return parseGitRefs(resp.Body)
+	t.followRedirect(resp.Request.URL)
+	return parseGitRefs(bytes.NewReader(body))
 }
 
-func (t *HTTPTransport) FetchPack(ctx context.Context, wants, haves []string) (PackReader, error) {
-	url := fmt.Sprintf("%s/%s", t.baseURL.String(), gitUploadPack)
+// followRedirect points t at the repository a redirected ref advertisement
+// was served from
+func (t *HTTPTransport) followRedirect(final *url.URL) {
+	repoPath, ok := strings.CutSuffix(final.Path, infoRefsPath)
+	if !ok {
+		return
+	}
+	rebased := *final
+	rebased.Path = repoPath
+	rebased.RawPath = ""
+	rebased.RawQuery = ""
+	t.baseURL = &rebased
+}
 
-	packRequest := buildPackRequest(wants, haves)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(packRequest))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create pack request: %w", err)
+// validateAdvertisement checks that body is a smart HTTP upload-pack ref
+// advertisement: served with its content type and opening with a
+// "# service=" packet
+func validateAdvertisement(contentType string, body []byte) error {
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != uploadPackAdvertisement {
+		return fmt.Errorf("%w: content type %q, expected %q (not a smart HTTP git server?): %q",
+			errors.ErrInvalidAdvertisement, contentType, uploadPackAdvertisement, firstLine(body))
 	}
 
-	req.Header.Set("Content-Type", uploadPackType)
-	if t.username != "" && t.password != "" {
-		req.SetBasicAuth(t.username, t.password)
+	if len(body) < packetHeaderSize {
+		return fmt.Errorf("%w: response too short: %q", errors.ErrInvalidAdvertisement, firstLine(body))
+	}
+	length, err := strconv.ParseUint(string(body[:packetHeaderSize]), 16, 16)
+	if err != nil || length < packetHeaderSize || int(length) > len(body) {
+		return fmt.Errorf("%w: malformed first packet: %q", errors.ErrInvalidAdvertisement, firstLine(body))
+	}
+	banner := strings.TrimSuffix(string(body[packetHeaderSize:length]), "\n")
+	if want := servicePrefix + gitUploadPack; banner != want {
+		return fmt.Errorf("%w: expected %q, got %q", errors.ErrInvalidAdvertisement, want, banner)
 	}
 
-	resp, err := t.client.Do(req)
+	return nil
+}
+
+// firstLine returns the first line of a response body, shortened for use
+// in an error message
+func firstLine(body []byte) string {
+	line, _, _ := bytes.Cut(body, []byte("\n"))
+	line = bytes.TrimSpace(line)
+	if len(line) > maxFirstLine {
+		return string(line[:maxFirstLine]) + "..."
+	}
+	return string(line)
+}
+
+func (t *HTTPTransport) FetchPack(ctx context.Context, wants, haves []string) (PackReader, error) {
+	url := fmt.Sprintf("%s/%s", t.baseURL.String(), gitUploadPack)
+
+	// fetching only reads from the remote, so it is safe to repeat
+	packRequest := buildPackRequest(wants, haves)
+	resp, err := t.do(ctx, http.MethodPost, url, uploadPackType, packRequest, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch pack: %w", err)
 	}
@@ -372,7 +586,57 @@ func (t *HTTPTransport) FetchPack(ctx context.Context, wants, haves []string) (P
 	return resp.Body, nil
 }
 
-func (t *HTTPTransport) SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) error {
+// FetchPackFrom asks for the fetch response from offset on with a Range
+// request. Upload-pack generates its response on each request, so only
+// servers or proxies that cache it byte for byte honour the range; any
+// other answer is read from the start.
+func (t *HTTPTransport) FetchPackFrom(ctx context.Context, wants, haves []string, offset int64) (PackReader, int64, error) {
+	if offset <= 0 {
+		reader, err := t.FetchPack(ctx, wants, haves)
+		return reader, 0, err
+	}
+
+	url := fmt.Sprintf("%s/%s", t.baseURL.String(), gitUploadPack)
+	header := make(http.Header)
+	header.Set("Content-Type", uploadPackType)
+	header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	resp, err := t.doWithHeader(ctx, http.MethodPost, url, header, buildPackRequest(wants, haves), true)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch pack: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) == offset:
+		return resp.Body, offset, nil
+	case resp.StatusCode == http.StatusOK:
+		return resp.Body, 0, nil
+	}
+	resp.Body.Close()
+
+	// a 416 or a range other than the one asked for: start over
+	reader, err := t.FetchPack(ctx, wants, haves)
+	return reader, 0, err
+}
+
+// contentRangeStart returns the first byte a "bytes first-last/total"
+// Content-Range covers, or -1 when it cannot be parsed
+func contentRangeStart(value string) int64 {
+	spec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+func (t *HTTPTransport) SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) (*PushReport, error) {
 	url := fmt.Sprintf("%s/%s", t.baseURL.String(), gitReceivePack)
 
 	// Build complete request with refs and pack data
@@ -387,27 +651,17 @@ func (t *HTTPTransport) SendPack(ctx context.Context, refs map[string]RefUpdate,
 		requestData.Write(packData)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, &requestData)
-	if err != nil {
-		return fmt.Errorf("failed to create push request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", receivePackType)
-	if t.username != "" && t.password != "" {
-		req.SetBasicAuth(t.username, t.password)
-	}
-
-	resp, err := t.client.Do(req)
+	resp, err := t.do(ctx, http.MethodPost, url, receivePackType, requestData.Bytes(), false)
 	if err != nil {
-		return fmt.Errorf("failed to send pack: %w", err)
+		return nil, fmt.Errorf("failed to send pack: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP error: %s", resp.Status)
+		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
 	}
 
-	return nil
+	return readPushReport(bufio.NewReader(resp.Body))
 }
 
 func (t *HTTPTransport) Close() error {
@@ -430,12 +684,14 @@ func NewSSHTransport(remoteURL string, auth *AuthConfig) (*SSHTransport, error)
 		return nil, fmt.Errorf("invalid SSH URL format: %w", err)
 	}
 
-	keyPath := ""
-	if auth != nil && auth.SSHKey != "" {
-		keyPath = auth.SSHKey
+	opts := ssh.ClientOptions{Host: host, Port: port, User: user}
+	if auth != nil {
+		opts.KeyPath = auth.SSHKey
+		opts.Command = auth.SSHCommand
 	}
+	keyPath := opts.KeyPath
 
-	sshClient := ssh.NewSSHClient(host, port, user, keyPath)
+	sshClient := ssh.NewSSHClient(opts)
 
 	transport := &SSHTransport{
 		sshClient: sshClient,
@@ -449,6 +705,14 @@ func NewSSHTransport(remoteURL string, auth *AuthConfig) (*SSHTransport, error)
 	return transport, nil
 }
 
+// WithHostKeyPolicy sets how hosts missing from known_hosts are treated;
+// confirm is asked about them under ssh.HostKeyAsk. It returns t for
+// chaining.
+func (t *SSHTransport) WithHostKeyPolicy(policy ssh.HostKeyPolicy, confirm func(host string, key gossh.PublicKey) bool) *SSHTransport {
+	t.sshClient.WithHostKeyPolicy(policy, confirm)
+	return t
+}
+
 func (t *SSHTransport) Connect(ctx context.Context, url string) error {
 	conn, err := t.sshClient.Connect(ctx)
 	if err != nil {
@@ -468,7 +732,7 @@ func (t *SSHTransport) Disconnect() error {
 }
 
 func (t *SSHTransport) ListRefs(ctx context.Context) (map[string]string, error) {
-	conn, err := ssh.ExecuteSSHCommand(ctx, t.host, t.port, t.user, gitUploadPack, []string{t.repo})
+	conn, err := t.sshClient.Execute(ctx, gitUploadPack, []string{t.repo})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute %s: %w", gitUploadPack, err)
 	}
@@ -484,7 +748,7 @@ func (t *SSHTransport) ListRefs(ctx context.Context) (map[string]string, error)
 }
 
 func (t *SSHTransport) FetchPack(ctx context.Context, wants, haves []string) (PackReader, error) {
-	conn, err := ssh.ExecuteSSHCommand(ctx, t.host, t.port, t.user, gitUploadPack, []string{t.repo})
+	conn, err := t.sshClient.Execute(ctx, gitUploadPack, []string{t.repo})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute %s: %w", gitUploadPack, err)
 	}
@@ -499,10 +763,10 @@ func (t *SSHTransport) FetchPack(ctx context.Context, wants, haves []string) (Pa
 	return conn, nil
 }
 
-func (t *SSHTransport) SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) error {
-	conn, err := ssh.ExecuteSSHCommand(ctx, t.host, t.port, t.user, gitReceivePack, []string{t.repo})
+func (t *SSHTransport) SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) (*PushReport, error) {
+	conn, err := t.sshClient.Execute(ctx, gitReceivePack, []string{t.repo})
 	if err != nil {
-		return fmt.Errorf("failed to execute %s: %w", gitReceivePack, err)
+		return nil, fmt.Errorf("failed to execute %s: %w", gitReceivePack, err)
 	}
 	defer conn.Close()
 
@@ -510,21 +774,27 @@ func (t *SSHTransport) SendPack(ctx context.Context, refs map[string]RefUpdate,
 	refData := buildPushRequest(refs)
 	_, err = conn.Write(refData)
 	if err != nil {
-		return fmt.Errorf("failed to send ref updates: %w", err)
+		return nil, fmt.Errorf("failed to send ref updates: %w", err)
 	}
 
 	// Send pack data if provided
 	if packData != nil {
 		_, err = conn.Write(packData)
 		if err != nil {
-			return fmt.Errorf("failed to send pack data: %w", err)
+			return nil, fmt.Errorf("failed to send pack data: %w", err)
 		}
 	}
 
-	return nil
+	// the server advertised its refs before reading the request
+	reader := bufio.NewReader(conn)
+	if err := skipAdvertisement(reader); err != nil {
+		return nil, err
+	}
+	return readPushReport(reader)
 }
 
 func (t *SSHTransport) Close() error {
+	t.sshClient.Close()
 	return t.Disconnect()
 }
 
@@ -631,20 +901,24 @@ func buildPushRequest(updates map[string]RefUpdate) []byte {
 
 	first := true
 	for _, update := range updates {
-		// use zero hash for new branches
+		// use zero hash for new branches, and for deleted ones
 		oldHash := update.OldHash
 		if oldHash == "" {
-			oldHash = nullHash
+			oldHash = ZeroHash
+		}
+		newHash := update.NewHash
+		if newHash == "" {
+			newHash = ZeroHash
 		}
 
 		var line string
 		if first {
 			line = fmt.Sprintf("%s %s %s\x00%s\n",
-				oldHash, update.NewHash, update.RefName, pushCapabilities)
+				oldHash, newHash, update.RefName, pushCapabilities)
 			first = false
 		} else {
 			line = fmt.Sprintf("%s %s %s\n",
-				oldHash, update.NewHash, update.RefName)
+				oldHash, newHash, update.RefName)
 		}
 
 		pktLine := fmt.Sprintf("%04x%s", len(line)+packetHeaderSize, line)
@@ -679,7 +953,15 @@ func GetDefaultRemote(repo *repository.Repository) (*Remote, error) {
 	return remotes[0], nil
 }
 
+// LoadAuthConfig reads credentials from the environment, the global
+// credential.helper and ~/.netrc
 func LoadAuthConfig() (*AuthConfig, error) {
+	return LoadRepoAuthConfig("")
+}
+
+// LoadRepoAuthConfig is LoadAuthConfig with the credential.helper of the
+// repository at gitDir overriding the global one
+func LoadRepoAuthConfig(gitDir string) (*AuthConfig, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, err
@@ -702,10 +984,13 @@ func LoadAuthConfig() (*AuthConfig, error) {
 		auth.Password = password
 	}
 
-	// ssh
-	sshKeyPath := filepath.Join(homeDir, ".ssh", "id_rsa")
-	if _, err := os.Stat(sshKeyPath); err == nil {
-		auth.SSHKey = sshKeyPath
+	auth.Helper = configValue(homeDir, gitDir, credentialSection, "helper")
+	auth.Hosts = loadNetrc(homeDir)
+
+	// ssh; keys themselves are found by the ssh client
+	auth.SSHCommand = os.Getenv("GIT_SSH_COMMAND")
+	if auth.SSHCommand == "" {
+		auth.SSHCommand = configValue(homeDir, gitDir, coreSection, "sshcommand")
 	}
 
 	return auth, nil
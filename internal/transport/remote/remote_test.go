@@ -1,14 +1,27 @@
 package remote
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
 )
 
 func TestRemoteConfig(t *testing.T) {
@@ -210,6 +223,34 @@ func TestNewSSHTransport(t *testing.T) {
 	})
 }
 
+func TestNewGitTransport(t *testing.T) {
+	t.Run("DefaultPort", func(t *testing.T) {
+		transport, err := NewGitTransport("git://example.com/user/repo.git")
+		require.NoError(t, err)
+		assert.Equal(t, "example.com:9418", transport.addr)
+		assert.Equal(t, "example.com", transport.host)
+		assert.Equal(t, "/user/repo.git", transport.path)
+	})
+
+	t.Run("WithPort", func(t *testing.T) {
+		transport, err := NewGitTransport("git://example.com:1234/repo.git")
+		require.NoError(t, err)
+		assert.Equal(t, "example.com:1234", transport.addr)
+		assert.Equal(t, "example.com:1234", transport.host)
+	})
+
+	t.Run("CreateTransport", func(t *testing.T) {
+		transport, err := CreateTransport("git://example.com/repo.git", nil)
+		require.NoError(t, err)
+		assert.IsType(t, &GitTransport{}, transport)
+	})
+
+	for _, bad := range []string{"git://example.com", "git:///repo.git", "https://example.com/repo.git"} {
+		_, err := NewGitTransport(bad)
+		assert.ErrorIs(t, err, errors.ErrInvalidURL, bad)
+	}
+}
+
 // MockTransport implements the Transport interface for testing
 type MockTransport struct {
 	connectError   error
@@ -243,11 +284,14 @@ func (m *MockTransport) FetchPack(ctx context.Context, wants, haves []string) (P
 	return &MockPackReader{data: m.packData}, nil
 }
 
-func (m *MockTransport) SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) error {
+func (m *MockTransport) SendPack(ctx context.Context, refs map[string]RefUpdate, packData []byte) (*PushReport, error) {
 	m.sendPackCalled = true
 	m.lastRefs = refs
 	m.lastPackData = packData
-	return m.sendPackError
+	if m.sendPackError != nil {
+		return nil, m.sendPackError
+	}
+	return &PushReport{Rejected: make(map[string]string)}, nil
 }
 
 func (m *MockTransport) Close() error {
@@ -318,7 +362,7 @@ func TestMockTransport(t *testing.T) {
 		}
 		packData := []byte("pack-data-to-send")
 
-		err = mock.SendPack(ctx, refUpdates, packData)
+		_, err = mock.SendPack(ctx, refUpdates, packData)
 		assert.NoError(t, err)
 		assert.True(t, mock.sendPackCalled)
 		assert.Equal(t, refUpdates, mock.lastRefs)
@@ -337,7 +381,694 @@ func TestMockTransport(t *testing.T) {
 		assert.Error(t, err)
 
 		// Test SendPack error
-		err = mock.SendPack(ctx, map[string]RefUpdate{}, nil)
+		_, err = mock.SendPack(ctx, map[string]RefUpdate{}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestRemoteConfigPreservesOtherSections(t *testing.T) {
+	gitDir := t.TempDir()
+	configPath := filepath.Join(gitDir, "config")
+	original := "[core]\n\tbare = false\n[remote \"origin\"]\n\turl = https://example.com/a.git\n" +
+		"\tfetch = +refs/heads/*:refs/remotes/origin/*\n\tfetch = +refs/tags/*:refs/tags/*\n[branch \"main\"]\n\tremote = origin\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(original), 0644))
+
+	rc := NewRemoteConfig(gitDir)
+	require.NoError(t, rc.Load())
+
+	origin, err := rc.GetRemote("origin")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"}, origin.Fetch)
+
+	require.NoError(t, rc.AddRemote("upstream", "https://example.com/b.git"))
+	require.NoError(t, rc.AddRemote("other", "https://example.com/c.git"))
+	require.NoError(t, rc.RemoveRemote("other"))
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	expected := original + "[remote \"upstream\"]\n\turl = https://example.com/b.git\n\tfetch = +refs/heads/*:refs/remotes/upstream/*\n"
+	assert.Equal(t, expected, string(data))
+}
+
+func TestRemoteConfigSaveKeepsUserSection(t *testing.T) {
+	gitDir := t.TempDir()
+	configPath := filepath.Join(gitDir, "config")
+	original := "[user]\n\tname = Jane Doe\n\temail = jane@example.com\n" +
+		"[remote \"origin\"]\n\turl=https://example.com/a.git\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(original), 0644))
+
+	rc := NewRemoteConfig(gitDir)
+	require.NoError(t, rc.Load())
+	require.NoError(t, rc.AddRemote("upstream", "https://example.com/b.git"))
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), original), "existing lines should be left as written")
+	assert.Contains(t, string(data), "[user]\n\tname = Jane Doe\n\temail = jane@example.com\n")
+	assert.Equal(t, 1, strings.Count(string(data), "fetch = +refs/heads/*:refs/remotes/origin/*"))
+	assert.Equal(t, 1, strings.Count(string(data), "fetch = +refs/heads/*:refs/remotes/upstream/*"))
+
+	reloaded := NewRemoteConfig(gitDir)
+	require.NoError(t, reloaded.Load())
+	for _, r := range reloaded.ListRemotes() {
+		assert.Len(t, r.Fetch, 1, "remote %s", r.Name)
+	}
+}
+
+func TestRemoteConfigRename(t *testing.T) {
+	gitDir := t.TempDir()
+	configPath := filepath.Join(gitDir, "config")
+	original := "[remote \"origin\"]\n\turl = https://example.com/a.git\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n" +
+		"[branch \"main\"]\n\tremote = origin\n\tmerge = refs/heads/main\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(original), 0644))
+
+	hash := strings.Repeat("a", 40)
+	originDir := filepath.Join(gitDir, "refs", "remotes", "origin")
+	require.NoError(t, os.MkdirAll(originDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(originDir, "main"), []byte(hash+"\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(originDir, "HEAD"), []byte("ref: refs/remotes/origin/main\n"), 0644))
+	packed := "# pack-refs with: peeled fully-peeled sorted \n" + hash + " refs/remotes/origin/dev\n" + hash + " refs/tags/v1\n"
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "packed-refs"), []byte(packed), 0644))
+
+	rc := NewRemoteConfig(gitDir)
+	require.NoError(t, rc.Load())
+	require.NoError(t, rc.Rename("origin", "upstream"))
+
+	_, err := rc.GetRemote("origin")
+	assert.Error(t, err)
+	renamed, err := rc.GetRemote("upstream")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"+refs/heads/*:refs/remotes/upstream/*"}, renamed.Fetch)
+
+	upstreamDir := filepath.Join(gitDir, "refs", "remotes", "upstream")
+	assert.NoDirExists(t, originDir)
+	content, err := os.ReadFile(filepath.Join(upstreamDir, "main"))
+	require.NoError(t, err)
+	assert.Equal(t, hash+"\n", string(content))
+	content, err = os.ReadFile(filepath.Join(upstreamDir, "HEAD"))
+	require.NoError(t, err)
+	assert.Equal(t, "ref: refs/remotes/upstream/main\n", string(content))
+
+	content, err = os.ReadFile(filepath.Join(gitDir, "packed-refs"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), hash+" refs/remotes/upstream/dev\n")
+	assert.Contains(t, string(content), hash+" refs/tags/v1\n")
+	assert.NotContains(t, string(content), "refs/remotes/origin/")
+
+	reloaded := NewRemoteConfig(gitDir)
+	require.NoError(t, reloaded.Load())
+	_, err = reloaded.GetRemote("upstream")
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "[branch \"main\"]\n\tremote = upstream\n")
+	assert.NotContains(t, string(data), "origin")
+
+	assert.Error(t, rc.Rename("missing", "other"))
+}
+
+func TestRemoteConfigSetURL(t *testing.T) {
+	gitDir := t.TempDir()
+
+	rc := NewRemoteConfig(gitDir)
+	require.NoError(t, rc.AddRemote("origin", "https://example.com/a.git"))
+
+	require.NoError(t, rc.SetURL("origin", "https://example.com/push.git", true))
+	require.NoError(t, rc.SetURL("origin", "https://example.com/b.git", false))
+
+	reloaded := NewRemoteConfig(gitDir)
+	require.NoError(t, reloaded.Load())
+	origin, err := reloaded.GetRemote("origin")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b.git", origin.URL)
+	assert.Equal(t, "https://example.com/push.git", origin.PushURL)
+
+	assert.Error(t, rc.SetURL("missing", "https://example.com/c.git", false))
+}
+
+func TestHTTPTransportRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	// failingServer answers 503 to the first failures requests
+	failingServer := func(failures int) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if int(calls.Add(1)) <= failures {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			io.Copy(io.Discard, r.Body)
+			w.Header().Set("Content-Type", uploadPackAdvertisement)
+			w.Write(advertisement(strings.Repeat("a", 40) + " refs/heads/main"))
+		}))
+		t.Cleanup(server.Close)
+		return server, &calls
+	}
+
+	t.Run("ListRefsRecovers", func(t *testing.T) {
+		server, calls := failingServer(2)
+		transport, err := NewHTTPTransport(server.URL, nil)
+		require.NoError(t, err)
+		transport.WithRetryPolicy(policy)
+
+		refs, err := transport.ListRefs(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat("a", 40), refs["refs/heads/main"])
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("FetchPackGivesUp", func(t *testing.T) {
+		server, calls := failingServer(10)
+		transport, err := NewHTTPTransport(server.URL, nil)
+		require.NoError(t, err)
+		transport.WithRetryPolicy(policy)
+
+		_, err = transport.FetchPack(context.Background(), []string{strings.Repeat("a", 40)}, nil)
+		require.Error(t, err)
+		assert.Equal(t, int32(4), calls.Load())
+	})
+
+	t.Run("SendPackNotRetriedAfterBody", func(t *testing.T) {
+		server, calls := failingServer(1)
+		transport, err := NewHTTPTransport(server.URL, nil)
+		require.NoError(t, err)
+		transport.WithRetryPolicy(policy)
+
+		_, err = transport.SendPack(context.Background(), map[string]RefUpdate{
+			"refs/heads/main": {RefName: "refs/heads/main", NewHash: strings.Repeat("b", 40)},
+		}, []byte("PACK"))
+		require.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestHTTPTransportFetchPackFrom(t *testing.T) {
+	response := []byte("0008NAK\n0000")
+	wants := []string{strings.Repeat("a", 40)}
+
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		wantStart int64
+		wantBody  string
+	}{
+		{
+			name: "RangeHonoured",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "bytes=4-", r.Header.Get("Range"))
+				assert.Equal(t, uploadPackType, r.Header.Get("Content-Type"))
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 4-%d/%d", len(response)-1, len(response)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(response[4:])
+			},
+			wantStart: 4,
+			wantBody:  string(response[4:]),
+		},
+		{
+			name: "RangeIgnored",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write(response)
+			},
+			wantStart: 0,
+			wantBody:  string(response),
+		},
+		{
+			name: "WrongRangeRefetched",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "" {
+					w.Header().Set("Content-Range", fmt.Sprintf("bytes 2-%d/%d", len(response)-1, len(response)))
+					w.WriteHeader(http.StatusPartialContent)
+					w.Write(response[2:])
+					return
+				}
+				w.Write(response)
+			},
+			wantStart: 0,
+			wantBody:  string(response),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			transport, err := NewHTTPTransport(server.URL, nil)
+			require.NoError(t, err)
+
+			reader, start, err := transport.FetchPackFrom(context.Background(), wants, nil, 4)
+			require.NoError(t, err)
+			defer reader.Close()
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStart, start)
+			assert.Equal(t, tt.wantBody, string(body))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	header := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{value}}}
+	}
+
+	wait, ok := retryAfter(header("7"), now)
+	assert.True(t, ok)
+	assert.Equal(t, 7*time.Second, wait)
+
+	wait, ok = retryAfter(header(now.Add(90*time.Second).Format(http.TimeFormat)), now)
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, wait)
+
+	_, ok = retryAfter(&http.Response{Header: http.Header{}}, now)
+	assert.False(t, ok)
+}
+
+// advertisement builds a smart HTTP upload-pack ref advertisement
+func advertisement(refLines ...string) []byte {
+	pkt := func(line string) string { return fmt.Sprintf("%04x%s\n", len(line)+5, line) }
+	out := pkt(servicePrefix+gitUploadPack) + flushPacket
+	for _, line := range refLines {
+		out += pkt(line)
+	}
+	return []byte(out + flushPacket)
+}
+
+func TestHTTPTransportConnectionReuse(t *testing.T) {
+	head := strings.Repeat("a", 40)
+	pack := "0008NAK\n" + strings.Repeat("0009\x01data", 100) + flushPacket
+
+	// newServer counts the TLS connections, each one a handshake, that
+	// clients open to it
+	newServer := func(t *testing.T, http2 bool) (*httptest.Server, *atomic.Int32, *atomic.Int32) {
+		var handshakes, protoMajor atomic.Int32
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			protoMajor.Store(int32(r.ProtoMajor))
+			io.Copy(io.Discard, r.Body)
+			if strings.HasSuffix(r.URL.Path, infoRefsPath) {
+				w.Header().Set("Content-Type", uploadPackAdvertisement)
+				w.Write(advertisement(head + " refs/heads/main"))
+				return
+			}
+			io.WriteString(w, pack)
+		}))
+		server.EnableHTTP2 = http2
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				handshakes.Add(1)
+			}
+		}
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server, &handshakes, &protoMajor
+	}
+
+	newClient := func(server *httptest.Server) *http.Client {
+		roots := x509.NewCertPool()
+		roots.AddCert(server.Certificate())
+		roundTripper := newRoundTripper(&tls.Config{RootCAs: roots})
+		t.Cleanup(roundTripper.CloseIdleConnections)
+		return &http.Client{Transport: roundTripper}
+	}
+
+	// clone makes the requests of a clone through its own transport:
+	// connect, list refs, then fetch a pack read only up to its NAK
+	clone := func(t *testing.T, server *httptest.Server, client *http.Client) {
+		transport, err := NewHTTPTransport(server.URL, nil)
+		require.NoError(t, err)
+		transport.WithHTTPClient(client)
+
+		ctx := context.Background()
+		require.NoError(t, transport.Connect(ctx, server.URL))
+		_, err = transport.ListRefs(ctx)
+		require.NoError(t, err)
+		reader, err := transport.FetchPack(ctx, []string{head}, nil)
+		require.NoError(t, err)
+		_, err = io.ReadFull(reader, make([]byte, 8))
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+	}
+
+	t.Run("ClientPerTransport", func(t *testing.T) {
+		server, handshakes, _ := newServer(t, false)
+		clone(t, server, newClient(server))
+		clone(t, server, newClient(server))
+		assert.Equal(t, int32(2), handshakes.Load())
+	})
+
+	for _, http2 := range []bool{false, true} {
+		t.Run(fmt.Sprintf("SharedClientHTTP2=%v", http2), func(t *testing.T) {
+			server, handshakes, protoMajor := newServer(t, http2)
+			client := newClient(server)
+			clone(t, server, client)
+			clone(t, server, client)
+			assert.Equal(t, int32(1), handshakes.Load())
+			if http2 {
+				assert.Equal(t, int32(2), protoMajor.Load())
+			} else {
+				assert.Equal(t, int32(1), protoMajor.Load())
+			}
+		})
+	}
+}
+
+func TestHTTPTransportListRefsRedirect(t *testing.T) {
+	head := strings.Repeat("c", 40)
+	var fetchPath string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repo/info/refs", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/repo.git/info/refs?"+r.URL.RawQuery, http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/repo.git/info/refs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "service="+gitUploadPack, r.URL.RawQuery)
+		w.Header().Set("Content-Type", uploadPackAdvertisement)
+		w.Write(advertisement(head + " refs/heads/main"))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fetchPath = r.URL.Path
+		w.Write([]byte(flushPacket))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	transport, err := NewHTTPTransport(server.URL+"/repo", nil)
+	require.NoError(t, err)
+
+	refs, err := transport.ListRefs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"refs/heads/main": head}, refs)
+
+	pack, err := transport.FetchPack(context.Background(), []string{head}, nil)
+	require.NoError(t, err)
+	pack.Close()
+	assert.Equal(t, "/repo.git/"+gitUploadPack, fetchPath)
+}
+
+func TestHTTPTransportListRefsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{"dumb server", "text/plain", strings.Repeat("c", 40) + "\trefs/heads/main\n", "\"" + strings.Repeat("c", 40) + "\\trefs/heads/main\""},
+		{"login page", "text/html; charset=utf-8", "<html><body>Sign in</body></html>\n", "<html><body>Sign in</body></html>"},
+		{"wrong service", uploadPackAdvertisement, "001f# service=git-receive-pack\n0000", "git-receive-pack"},
+		{"no banner", uploadPackAdvertisement, "0000", "malformed first packet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			transport, err := NewHTTPTransport(server.URL, nil)
+			require.NoError(t, err)
+
+			_, err = transport.ListRefs(context.Background())
+			require.ErrorIs(t, err, errors.ErrInvalidAdvertisement)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	hosts := parseNetrc([]byte(`machine github.com login gh-user password gh-pass
+machine gitlab.com
+	login gl-user
+	password gl-pass
+macdef init
+machine ignored.com login x password y
+
+default login anon password anon-pass
+machine github.com login dup password dup
+`))
+
+	assert.Equal(t, map[string]Credentials{
+		"github.com": {Username: "gh-user", Password: "gh-pass"},
+		"gitlab.com": {Username: "gl-user", Password: "gl-pass"},
+		"":           {Username: "anon", Password: "anon-pass"},
+	}, hosts)
+}
+
+func TestAuthConfigForURL(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{"GITHUB_TOKEN", "GITLAB_TOKEN", "GIT_USERNAME", "GIT_PASSWORD"} {
+		t.Setenv(name, "")
+	}
+
+	netrc := filepath.Join(home, "netrc")
+	require.NoError(t, os.WriteFile(netrc, []byte(
+		"machine github.com login gh-user password gh-pass\n"+
+			"machine gitlab.com login gl-user password gl-pass\n"), 0600))
+	t.Setenv(netrcEnv, netrc)
+
+	credentialsFor := func(auth *AuthConfig, remoteURL string) [2]string {
+		transport, err := CreateTransport(remoteURL, auth)
+		require.NoError(t, err)
+		httpTransport := transport.(*HTTPTransport)
+		return [2]string{httpTransport.username, httpTransport.password}
+	}
+
+	t.Run("NetrcPerHost", func(t *testing.T) {
+		auth, err := LoadAuthConfig()
+		require.NoError(t, err)
+		assert.Equal(t, [2]string{"gh-user", "gh-pass"}, credentialsFor(auth, "https://github.com/user/repo.git"))
+		assert.Equal(t, [2]string{"gl-user", "gl-pass"}, credentialsFor(auth, "https://gitlab.com:443/user/repo.git"))
+		assert.Equal(t, [2]string{"", ""}, credentialsFor(auth, "https://example.com/repo.git"))
+	})
+
+	t.Run("HelperBeforeNetrc", func(t *testing.T) {
+		gitDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(gitDir, "config"), []byte(
+			"[credential]\n\thelper = \"!f() { test \\\"$1\\\" = get || exit 1; "+
+				"grep -q host=github.com && echo username=helper-user && echo password=helper-pass; }; f\"\n"), 0644))
+
+		auth, err := LoadRepoAuthConfig(gitDir)
+		require.NoError(t, err)
+		assert.Equal(t, [2]string{"helper-user", "helper-pass"}, credentialsFor(auth, "https://github.com/user/repo.git"))
+		assert.Equal(t, [2]string{"gl-user", "gl-pass"}, credentialsFor(auth, "https://gitlab.com/user/repo.git"))
+	})
+
+	t.Run("EnvBeforeHelper", func(t *testing.T) {
+		t.Setenv("GIT_USERNAME", "env-user")
+		t.Setenv("GIT_PASSWORD", "env-pass")
+
+		auth, err := LoadAuthConfig()
+		require.NoError(t, err)
+		auth.Helper = "!echo username=helper-user; echo password=helper-pass"
+		assert.Equal(t, [2]string{"env-user", "env-pass"}, credentialsFor(auth, "https://github.com/user/repo.git"))
+	})
+}
+
+func TestAuthConfigSSH(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_SSH_COMMAND", "")
+
+	gitDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "config"), []byte(
+		"[core]\n\tsshCommand = ssh -o BatchMode=yes\n"+
+			"[remote \"origin\"]\n\turl = git@example.com:user/repo.git\n\tsshKey = /keys/deploy\n"), 0644))
+
+	auth, err := LoadRepoAuthConfig(gitDir)
+	require.NoError(t, err)
+	assert.Equal(t, "ssh -o BatchMode=yes", auth.SSHCommand)
+	assert.Empty(t, auth.SSHKey)
+
+	rc := NewRemoteConfig(gitDir)
+	require.NoError(t, rc.Load())
+	origin, err := rc.GetRemote("origin")
+	require.NoError(t, err)
+
+	transport, err := CreateTransport(origin.URL, auth.ForRemote(origin))
+	require.NoError(t, err)
+	assert.Equal(t, "/keys/deploy", transport.(*SSHTransport).key)
+
+	t.Setenv("GIT_SSH_COMMAND", "ssh -F /dev/null")
+	auth, err = LoadRepoAuthConfig(gitDir)
+	require.NoError(t, err)
+	assert.Equal(t, "ssh -F /dev/null", auth.SSHCommand)
+}
+
+// pktLines encodes lines as pkt-lines followed by a flush packet
+func pktLines(lines ...string) string {
+	var out strings.Builder
+	for _, line := range lines {
+		fmt.Fprintf(&out, "%04x%s", len(line)+packetHeaderSize, line)
+	}
+	return out.String() + flushPacket
+}
+
+func TestReadPushReport(t *testing.T) {
+	status := pktLines("unpack ok\n", "ok refs/heads/main\n", "ng refs/heads/dev pre-receive hook declined\n")
+
+	t.Run("Plain", func(t *testing.T) {
+		report, err := readPushReport(bufio.NewReader(strings.NewReader(status)))
+		require.NoError(t, err)
+		assert.Empty(t, report.UnpackError)
+		assert.Equal(t, []string{"refs/heads/main"}, report.Updated)
+		assert.Equal(t, map[string]string{"refs/heads/dev": "pre-receive hook declined"}, report.Rejected)
+	})
+
+	t.Run("Sideband", func(t *testing.T) {
+		reply := pktLines("\x02Resolving deltas: 100%\r", "\x01"+status[:20], "\x01"+status[20:])
+		report, err := readPushReport(bufio.NewReader(strings.NewReader(reply)))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"refs/heads/main"}, report.Updated)
+		assert.Equal(t, "pre-receive hook declined", report.Rejected["refs/heads/dev"])
+	})
+
+	t.Run("UnpackFailed", func(t *testing.T) {
+		report, err := readPushReport(bufio.NewReader(strings.NewReader(
+			pktLines("unpack index-pack abnormal exit\n", "ng refs/heads/main unpacker error\n"))))
+		require.NoError(t, err)
+		assert.Equal(t, "index-pack abnormal exit", report.UnpackError)
+	})
+
+	t.Run("RemoteError", func(t *testing.T) {
+		_, err := readPushReport(bufio.NewReader(strings.NewReader(pktLines("\x03disk full\n"))))
+		assert.ErrorIs(t, err, errors.ErrRemoteError)
+	})
+
+	t.Run("NoStatus", func(t *testing.T) {
+		_, err := readPushReport(bufio.NewReader(strings.NewReader(flushPacket)))
 		assert.Error(t, err)
 	})
 }
+
+func TestBuildPushRequestDelete(t *testing.T) {
+	old := strings.Repeat("a", 40)
+	request := string(buildPushRequest(map[string]RefUpdate{
+		"refs/heads/topic": {RefName: "refs/heads/topic", OldHash: old, NewHash: ZeroHash},
+	}))
+
+	line := old + " " + ZeroHash + " refs/heads/topic\x00" + pushCapabilities + "\n"
+	assert.Equal(t, fmt.Sprintf("%04x%s", len(line)+packetHeaderSize, line)+flushPacket, request)
+}
+
+func TestLsRemote(t *testing.T) {
+	mock := NewMockTransport()
+	mock.refs = map[string]string{
+		"HEAD":                   "1111111111111111111111111111111111111111",
+		"refs/heads/main":        "1111111111111111111111111111111111111111",
+		"refs/heads/feature/x":   "2222222222222222222222222222222222222222",
+		"refs/tags/v1.0":         "3333333333333333333333333333333333333333",
+		"refs/remotes/origin/go": "4444444444444444444444444444444444444444",
+	}
+
+	refs, err := lsRemote(context.Background(), mock, "https://example.com/repo.git", nil)
+	require.NoError(t, err)
+	assert.Equal(t, mock.refs, refs)
+
+	refs, err = lsRemote(context.Background(), mock, "https://example.com/repo.git", []string{"refs/heads/*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"refs/heads/feature/x", "refs/heads/main"}, slices.Sorted(maps.Keys(refs)))
+
+	refs, err = lsRemote(context.Background(), mock, "https://example.com/repo.git", []string{"main", "v1.*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"refs/heads/main", "refs/tags/v1.0"}, slices.Sorted(maps.Keys(refs)))
+
+	refs, err = lsRemote(context.Background(), mock, "https://example.com/repo.git", []string{"ain"})
+	require.NoError(t, err)
+	assert.Empty(t, refs, "patterns match whole components")
+
+	mock.connectError = fmt.Errorf("connection refused")
+	_, err = lsRemote(context.Background(), mock, "https://example.com/repo.git", nil)
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestParseRefspec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Refspec
+	}{
+		{"+refs/heads/*:refs/remotes/origin/*", Refspec{Src: "refs/heads/*", Dst: "refs/remotes/origin/*", Force: true}},
+		{"refs/tags/*:refs/tags/*", Refspec{Src: "refs/tags/*", Dst: "refs/tags/*"}},
+		{"main:mirror", Refspec{Src: "main", Dst: "refs/heads/mirror"}},
+		{"refs/heads/main", Refspec{Src: "refs/heads/main"}},
+	}
+	for _, tt := range tests {
+		refspec, err := ParseRefspec(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, refspec, tt.spec)
+	}
+
+	for _, bad := range []string{"", "+", ":refs/heads/x", "^refs/heads/x", "refs/heads/*:refs/remotes/origin/main", "refs/*/*:refs/*/*"} {
+		_, err := ParseRefspec(bad)
+		assert.ErrorIs(t, err, errors.ErrInvalidRefspec, bad)
+	}
+
+	assert.Equal(t, "+refs/heads/*:refs/remotes/origin/*", DefaultFetchRefspec("origin").String())
+}
+
+func TestRefspecMap(t *testing.T) {
+	tests := []struct {
+		spec  string
+		ref   string
+		want  string
+		match bool
+	}{
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/heads/feature/x", "refs/remotes/origin/feature/x", true},
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/tags/v1", "", false},
+		{"refs/heads/*-wip:refs/wip/*", "refs/heads/login-wip", "refs/wip/login", true},
+		{"refs/heads/*-wip:refs/wip/*", "refs/heads/login", "", false},
+		{"main:refs/mirror/main", "refs/heads/main", "refs/mirror/main", true},
+		{"v1:refs/tags/v1", "refs/tags/v1", "refs/tags/v1", true},
+		{"refs/heads/*", "refs/heads/main", "", true},
+	}
+	for _, tt := range tests {
+		refspec, err := ParseRefspec(tt.spec)
+		require.NoError(t, err)
+		got, ok := refspec.Map(tt.ref)
+		assert.Equal(t, tt.match, ok, "%s on %s", tt.spec, tt.ref)
+		assert.Equal(t, tt.want, got, "%s on %s", tt.spec, tt.ref)
+	}
+}
+
+func TestRemoteFetchRefspecs(t *testing.T) {
+	remote := &Remote{Name: "upstream"}
+	refspecs, err := remote.FetchRefspecs()
+	require.NoError(t, err)
+	assert.Equal(t, []Refspec{DefaultFetchRefspec("upstream")}, refspecs)
+
+	remote.Fetch = []string{"+refs/heads/*:refs/remotes/upstream/*", "refs/tags/*:refs/tags/*"}
+	refspecs, err = remote.FetchRefspecs()
+	require.NoError(t, err)
+	require.Len(t, refspecs, 2)
+	assert.False(t, refspecs[1].Force)
+	assert.True(t, refspecs[1].IsWildcard())
+}
+
+func TestFollowTags(t *testing.T) {
+	refs := map[string]string{
+		"HEAD":                   "c2",
+		"refs/heads/main":        "c2",
+		"refs/tags/v1":           "t1",
+		"refs/tags/v1^{}":        "c1",
+		"refs/tags/v2":           "c2",
+		"refs/tags/stray":        "t3",
+		"refs/tags/stray^{}":     "c3",
+		"refs/tags/stray-light":  "c3",
+		"refs/remotes/origin/x":  "c1",
+		"refs/tags/nested/v3":    "t4",
+		"refs/tags/nested/v3^{}": "c2",
+		// names a hostile remote could use to write outside refs/tags
+		"refs/tags/../../config": "c1",
+		"refs/tags/v4.lock":      "c2",
+	}
+	has := func(hash string) bool { return hash == "c1" || hash == "c2" }
+
+	assert.Equal(t, map[string]string{
+		"refs/tags/v1":        "t1",
+		"refs/tags/v2":        "c2",
+		"refs/tags/nested/v3": "t4",
+	}, FollowTags(refs, TagFollow, has))
+	assert.Len(t, FollowTags(refs, TagAll, has), 5)
+	assert.Empty(t, FollowTags(refs, TagNone, has))
+}
@@ -0,0 +1,142 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// sideband channels of a receive-pack reply
+const (
+	bandData  = 1
+	bandError = 3
+)
+
+// PushReport is the server's report-status reply to a push
+type PushReport struct {
+	// UnpackError is why the server could not unpack the pack; empty when it
+	// could
+	UnpackError string
+	// Updated lists the refs the server accepted
+	Updated []string
+	// Rejected maps each ref the server refused to its reason
+	Rejected map[string]string
+}
+
+// readPacket reads one pkt-line, returning nil for a flush packet
+func readPacket(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, packetHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid packet length %q", header)
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	if length < packetHeaderSize {
+		return nil, fmt.Errorf("invalid packet length %d", length)
+	}
+
+	payload := make([]byte, length-packetHeaderSize)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// skipAdvertisement reads past the ref advertisement a server sends when a
+// receive-pack session starts
+func skipAdvertisement(r *bufio.Reader) error {
+	for {
+		packet, err := readPacket(r)
+		if err != nil {
+			return fmt.Errorf("failed to read ref advertisement: %w", err)
+		}
+		if packet == nil {
+			return nil
+		}
+	}
+}
+
+// readPushReport reads a report-status reply, unwrapping it from sideband
+// channel 1 when the server sent it that way. An unpack failure or a
+// rejected ref is reported in the result, not as an error.
+func readPushReport(r *bufio.Reader) (*PushReport, error) {
+	var status bytes.Buffer
+	sideband := false
+
+	for {
+		packet, err := readPacket(r)
+		if err == io.EOF && status.Len() > 0 {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read push status: %w", err)
+		}
+		if packet == nil {
+			break
+		}
+
+		// without sideband the reply opens with the "unpack" line itself
+		if status.Len() == 0 && !sideband && len(packet) > 0 && packet[0] <= bandError {
+			sideband = true
+		}
+		if !sideband {
+			fmt.Fprintf(&status, "%04x%s", len(packet)+packetHeaderSize, packet)
+			continue
+		}
+
+		switch packet[0] {
+		case bandData:
+			status.Write(packet[1:])
+		case bandError:
+			return nil, fmt.Errorf("%w: %s", errors.ErrRemoteError, strings.TrimSpace(string(packet[1:])))
+		}
+	}
+
+	return parsePushReport(bufio.NewReader(&status))
+}
+
+// parsePushReport parses the pkt-lines of a report-status reply
+func parsePushReport(r *bufio.Reader) (*PushReport, error) {
+	report := &PushReport{Rejected: make(map[string]string)}
+	sawUnpack := false
+
+	for {
+		packet, err := readPacket(r)
+		if err == io.EOF || (err == nil && packet == nil) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read push status: %w", err)
+		}
+
+		line := strings.TrimSuffix(string(packet), "\n")
+		switch {
+		case strings.HasPrefix(line, "unpack "):
+			sawUnpack = true
+			if result := strings.TrimPrefix(line, "unpack "); result != "ok" {
+				report.UnpackError = result
+			}
+		case strings.HasPrefix(line, "ok "):
+			report.Updated = append(report.Updated, strings.TrimPrefix(line, "ok "))
+		case strings.HasPrefix(line, "ng "):
+			ref, reason, _ := strings.Cut(strings.TrimPrefix(line, "ng "), " ")
+			report.Rejected[ref] = reason
+		}
+	}
+
+	if !sawUnpack {
+		return nil, fmt.Errorf("no push status in server reply")
+	}
+	return report, nil
+}
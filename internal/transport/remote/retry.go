@@ -0,0 +1,151 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how HTTP requests are retried after a transient
+// failure: a dropped connection, 429 or a 5xx gateway error
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries; below 1 means one try
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled after each
+	InitialBackoff time.Duration
+	// MaxBackoff caps every wait, including one asked for by Retry-After;
+	// zero leaves waits uncapped
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used by new HTTP transports
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// NoRetry makes every request a single try
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+func (p RetryPolicy) capBackoff(wait time.Duration) time.Duration {
+	if p.MaxBackoff > 0 {
+		return min(wait, p.MaxBackoff)
+	}
+	return wait
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the wait a response asks for in its Retry-After
+// header, given in seconds or as an HTTP date
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return max(when.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// bodyTracker records whether any of a request body has been read
+type bodyTracker struct {
+	reader  io.Reader
+	started bool
+}
+
+func (b *bodyTracker) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if n > 0 {
+		b.started = true
+	}
+	return n, err
+}
+
+// do sends a request, retrying transient failures as t.retry allows.
+// Idempotent requests are retried after any transient failure; others only
+// while none of the body has been sent.
+func (t *HTTPTransport) do(ctx context.Context, method, url, contentType string, body []byte, idempotent bool) (*http.Response, error) {
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return t.doWithHeader(ctx, method, url, header, body, idempotent)
+}
+
+// doWithHeader is do with every request header given
+func (t *HTTPTransport) doWithHeader(ctx context.Context, method, url string, header http.Header, body []byte, idempotent bool) (*http.Response, error) {
+	attempts := max(t.retry.MaxAttempts, 1)
+	backoff := t.retry.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		var tracker *bodyTracker
+		var reqBody io.Reader
+		if body != nil {
+			tracker = &bodyTracker{reader: bytes.NewReader(body)}
+			reqBody = tracker
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if body != nil {
+			req.ContentLength = int64(len(body))
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if t.username != "" && t.password != "" {
+			req.SetBasicAuth(t.username, t.password)
+		}
+
+		resp, err := t.client.Do(req)
+		if err == nil {
+			resp.Body = drainingBody{resp.Body}
+		}
+		retryable := idempotent || tracker == nil || !tracker.started
+		if attempt >= attempts || !retryable || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := backoff
+		if err == nil {
+			if !retryableStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			if after, ok := retryAfter(resp, time.Now()); ok {
+				wait = after
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		wait = t.retry.capBackoff(wait)
+		backoff = t.retry.capBackoff(backoff * 2)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
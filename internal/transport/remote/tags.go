@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+)
+
+const peeledSuffix = "^{}"
+
+// TagMode says which of the tags a remote advertises are fetched
+type TagMode int
+
+const (
+	// TagFollow fetches the tags pointing at objects the fetch brought in or
+	// that were there already, as git fetch does by default
+	TagFollow TagMode = iota
+	// TagAll fetches every tag, as git fetch --tags does
+	TagAll
+	// TagNone fetches no tags other than those a refspec names
+	TagNone
+)
+
+// AdvertisedTags returns the tags among refs, leaving out their peeled
+// entries and any name that fails check-ref-format, which could otherwise
+// be written outside refs/tags
+func AdvertisedTags(refs map[string]string) map[string]string {
+	tags := make(map[string]string)
+	for name, hash := range refs {
+		if !strings.HasPrefix(name, tagsPrefix) || strings.HasSuffix(name, peeledSuffix) {
+			continue
+		}
+		if repository.CheckRefName(name) == nil {
+			tags[name] = hash
+		}
+	}
+	return tags
+}
+
+// FollowTags returns the tags of refs that mode fetches. Following a tag
+// takes the object it finally points at, as the remote peeled it, to be
+// one that has reports the repository holding.
+func FollowTags(refs map[string]string, mode TagMode, has func(hash string) bool) map[string]string {
+	tags := AdvertisedTags(refs)
+	switch mode {
+	case TagAll:
+		return tags
+	case TagNone:
+		return map[string]string{}
+	}
+
+	followed := make(map[string]string)
+	for name, hash := range tags {
+		// a lightweight tag has no peeled entry and points at the object itself
+		target, ok := refs[name+peeledSuffix]
+		if !ok {
+			target = hash
+		}
+		if has(target) {
+			followed[name] = hash
+		}
+	}
+	return followed
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/pktline"
+	"github.com/unkn0wn-root/git-go/internal/transport/receivepack"
+	"github.com/unkn0wn-root/git-go/internal/transport/uploadpack"
+)
+
+// defaultIdleTimeout is how long a connection may go without the client
+// sending anything when Daemon.Timeout is not set
+const defaultIdleTimeout = 2 * time.Minute
+
+// Daemon serves repositories over the anonymous git:// protocol, as git
+// daemon does: each connection opens with a "<service> <path>\0host=<host>\0"
+// packet and is then handed to that service.
+type Daemon struct {
+	// Repositories maps the paths clients ask for, such as "/repo.git", to
+	// the repositories served under them
+	Repositories map[string]*repository.Repository
+	// ReceivePack serves pushes as well, which git daemon leaves off by
+	// default since nobody is authenticated
+	ReceivePack bool
+	// Timeout drops a connection whose client sends nothing for that long,
+	// defaultIdleTimeout when zero
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// Serve accepts connections on l until Close is called, serving each on a
+// goroutine of its own
+func (d *Daemon) Serve(l net.Listener) error {
+	d.mu.Lock()
+	d.listener = l
+	if d.closed {
+		l.Close()
+	}
+	d.mu.Unlock()
+
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = defaultIdleTimeout
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			d.wg.Wait()
+			return err
+		}
+		if !d.track(conn) {
+			conn.Close()
+			continue
+		}
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			defer d.untrack(conn)
+			d.handle(&idleConn{Conn: conn, timeout: timeout})
+		}()
+	}
+}
+
+// Close stops accepting connections and drops those still open; Serve
+// returns once their goroutines have finished
+func (d *Daemon) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	for conn := range d.conns {
+		conn.Close()
+	}
+	if d.listener == nil {
+		return nil
+	}
+	return d.listener.Close()
+}
+
+// track records an open connection, refusing it once Close has been called
+func (d *Daemon) track(conn net.Conn) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+	if d.conns == nil {
+		d.conns = make(map[net.Conn]struct{})
+	}
+	d.conns[conn] = struct{}{}
+	return true
+}
+
+func (d *Daemon) untrack(conn net.Conn) {
+	d.mu.Lock()
+	delete(d.conns, conn)
+	d.mu.Unlock()
+	conn.Close()
+}
+
+// idleConn pushes the read deadline back before every read, so a client
+// that stops sending is dropped however long the whole exchange takes
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	if err := c.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+func (d *Daemon) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	packet, err := pktline.Read(reader)
+	if err != nil || packet == nil {
+		return
+	}
+
+	// the host and any extra parameters after the path are not needed to
+	// pick a repository here
+	request, _, _ := strings.Cut(string(packet), "\x00")
+	service, path, _ := strings.Cut(strings.TrimSuffix(request, "\n"), " ")
+
+	repo := d.Repositories[path]
+	if repo == nil {
+		refuse(conn, "access denied or repository not exported: "+path)
+		return
+	}
+
+	switch {
+	case service == uploadPack:
+		handler := uploadpack.New(repo)
+		if err := handler.AdvertiseRefs(conn); err != nil {
+			return
+		}
+		handler.Serve(reader, conn)
+	case service == receivePack && d.ReceivePack:
+		handler := receivepack.New(repo)
+		if err := handler.AdvertiseRefs(conn); err != nil {
+			return
+		}
+		handler.Serve(reader, conn)
+	default:
+		refuse(conn, fmt.Sprintf("service not enabled: '%s'", service))
+	}
+}
+
+// refuse answers a request with git daemon's "ERR" packet
+func refuse(w io.Writer, message string) {
+	var buf bytes.Buffer
+	pktline.Write(&buf, "ERR "+message)
+	w.Write(buf.Bytes())
+}
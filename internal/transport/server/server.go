@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/pktline"
+	"github.com/unkn0wn-root/git-go/internal/transport/receivepack"
+	"github.com/unkn0wn-root/git-go/internal/transport/uploadpack"
+)
+
+const (
+	uploadPack  = "git-upload-pack"
+	receivePack = "git-receive-pack"
+
+	infoRefsPath  = "/info/refs"
+	serviceParam  = "service"
+	servicePrefix = "# service="
+)
+
+// service is one smart HTTP service: its ref advertisement and the
+// exchange that follows it
+type service struct {
+	name      string
+	advertise func(w io.Writer) error
+	serve     func(r io.Reader, w io.Writer) error
+}
+
+// UploadPackHandler serves fetches and clones of repo over smart HTTP: the
+// ref advertisement at <repo URL>/info/refs?service=git-upload-pack and the
+// requests POSTed to <repo URL>/git-upload-pack
+func UploadPackHandler(repo *repository.Repository) http.Handler {
+	handler := uploadpack.New(repo)
+	return &service{name: uploadPack, advertise: handler.AdvertiseRefs, serve: handler.Serve}
+}
+
+// ReceivePackHandler serves pushes into repo over smart HTTP, at
+// <repo URL>/info/refs?service=git-receive-pack and <repo URL>/git-receive-pack.
+// It takes pushes from anyone who reaches it; authenticating them is left
+// to the handler wrapping it.
+func ReceivePackHandler(repo *repository.Repository) http.Handler {
+	handler := receivepack.New(repo)
+	serve := func(r io.Reader, w io.Writer) error {
+		_, err := handler.Serve(r, w)
+		return err
+	}
+	return &service{name: receivePack, advertise: handler.AdvertiseRefs, serve: serve}
+}
+
+// Handler serves repo for both fetching and pushing
+func Handler(repo *repository.Repository) http.Handler {
+	upload, receive := UploadPackHandler(repo), ReceivePackHandler(repo)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get(serviceParam) == receivePack || strings.HasSuffix(r.URL.Path, "/"+receivePack) {
+			receive.ServeHTTP(w, r)
+			return
+		}
+		upload.ServeHTTP(w, r)
+	})
+}
+
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, infoRefsPath):
+		s.serveInfoRefs(w, r)
+	case strings.HasSuffix(r.URL.Path, "/"+s.name):
+		s.serveRPC(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *service) serveInfoRefs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// dumb clients ask without a service and would want files we do not serve
+	if r.URL.Query().Get(serviceParam) != s.name {
+		http.Error(w, "only the smart protocol is served", http.StatusForbidden)
+		return
+	}
+
+	var body bytes.Buffer
+	pktline.Write(&body, servicePrefix+s.name+"\n")
+	body.WriteString(pktline.Flush)
+	if err := s.advertise(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-"+s.name+"-advertisement")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(body.Bytes())
+}
+
+func (s *service) serveRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/x-"+s.name+"-request" {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	// git compresses large requests, such as fetches with many haves
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	// the reply is held back so that a failed request still gets an error
+	// status rather than a truncated reply
+	var reply bytes.Buffer
+	if err := s.serve(body, &reply); err != nil && reply.Len() == 0 {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-"+s.name+"-result")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(reply.Bytes())
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/commands/clone"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/push"
+	"github.com/unkn0wn-root/git-go/internal/transport/remote"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+// newSourceRepo commits a file and a nested one on main and tags the
+// commit with an annotated tag
+func newSourceRepo(t *testing.T) (*repository.Repository, string) {
+	t.Helper()
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+
+	readme, err := repo.StoreObject(objects.NewBlob([]byte("hello\n")))
+	require.NoError(t, err)
+	code, err := repo.StoreObject(objects.NewBlob([]byte("package main\n")))
+	require.NoError(t, err)
+	sub, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{{Mode: objects.FileModeBlob, Name: "main.go", Hash: code}}))
+	require.NoError(t, err)
+	tree, err := repo.StoreObject(objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.FileModeBlob, Name: "README", Hash: readme},
+		{Mode: objects.FileModeTree, Name: "cmd", Hash: sub},
+	}))
+	require.NoError(t, err)
+
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0).UTC()}
+	commit, err := repo.StoreObject(objects.NewCommit(tree, nil, sig, sig, "initial\n"))
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateRef("refs/heads/main", commit))
+
+	tag, err := repo.StoreRawObject(objects.ObjectTypeTag, []byte(fmt.Sprintf(
+		"object %s\ntype commit\ntag v1\ntagger Test <test@example.com> 1700000000 +0000\n\nfirst release\n", commit)))
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateRef("refs/tags/v1", tag))
+
+	return repo, commit
+}
+
+func newServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	server := httptest.NewServer(http.StripPrefix("/repo.git", handler))
+	t.Cleanup(server.Close)
+	return server.URL + "/repo.git"
+}
+
+func TestCloneFromHandler(t *testing.T) {
+	source, commit := newSourceRepo(t)
+	url := newServer(t, UploadPackHandler(source))
+
+	opts := clone.DefaultCloneOptions()
+	opts.URL = url
+	opts.Directory = filepath.Join(t.TempDir(), "copy")
+	opts.Progress = false
+	result, err := clone.NewCloner().Clone(context.Background(), opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, "main", result.DefaultBranch)
+	assert.Equal(t, commit, result.ClonedCommit)
+	// the commit, two trees, two blobs and the tag
+	assert.Equal(t, 6, result.ObjectCount)
+	content, err := os.ReadFile(filepath.Join(opts.Directory, "cmd", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content))
+
+	head, err := result.Repository.GetHead()
+	require.NoError(t, err)
+	assert.Equal(t, commit, head)
+	tag, err := source.ReadRef("refs/tags/v1")
+	require.NoError(t, err)
+	cloned, err := result.Repository.ReadRef("refs/tags/v1")
+	require.NoError(t, err)
+	assert.Equal(t, tag, cloned)
+}
+
+func TestPushToHandler(t *testing.T) {
+	source, commit := newSourceRepo(t)
+	bare := repository.NewBare(t.TempDir())
+	require.NoError(t, bare.Init())
+	url := newServer(t, Handler(bare))
+
+	require.NoError(t, os.WriteFile(filepath.Join(source.GitDir, "config"),
+		[]byte("[remote \"origin\"]\n\turl = "+url+"\n"), 0644))
+	opts := push.DefaultPushOptions()
+	opts.Branch = "main"
+	_, err := push.NewPusher(source).Push(context.Background(), opts)
+	require.NoError(t, err)
+
+	pushed, err := bare.ReadRef("refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, commit, pushed)
+
+	// what was pushed can be cloned back
+	cloneOpts := clone.DefaultCloneOptions()
+	cloneOpts.URL = url
+	cloneOpts.Directory = filepath.Join(t.TempDir(), "copy")
+	cloneOpts.Progress = false
+	result, err := clone.NewCloner().Clone(context.Background(), cloneOpts)
+	require.NoError(t, err)
+	assert.Equal(t, commit, result.ClonedCommit)
+	assert.FileExists(t, filepath.Join(cloneOpts.Directory, "README"))
+}
+
+func TestHandlerRejectsOtherRequests(t *testing.T) {
+	source, _ := newSourceRepo(t)
+	url := newServer(t, UploadPackHandler(source))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"dumb protocol", http.MethodGet, "/info/refs", http.StatusForbidden},
+		{"service not served", http.MethodGet, "/info/refs?service=git-receive-pack", http.StatusForbidden},
+		{"rpc with GET", http.MethodGet, "/git-upload-pack", http.StatusMethodNotAllowed},
+		{"wrong content type", http.MethodPost, "/git-upload-pack", http.StatusUnsupportedMediaType},
+		{"unknown path", http.MethodGet, "/objects/info/packs", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, url+tt.path, strings.NewReader(""))
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+// TestHandlerServesGit has git itself clone from the handler, push a new
+// commit back through it, and fetch it again
+func TestHandlerServesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	bare := repository.NewBare(t.TempDir())
+	require.NoError(t, bare.Init())
+	url := newServer(t, Handler(bare))
+
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_CONFIG_NOSYSTEM=1", "HOME="+dir)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %v: %s", args, out)
+		return strings.TrimSpace(string(out))
+	}
+
+	work := t.TempDir()
+	git(work, "init", "-q", "-b", "main", "first")
+	first := filepath.Join(work, "first")
+	require.NoError(t, os.WriteFile(filepath.Join(first, "a.txt"), []byte("a\n"), 0644))
+	git(first, "add", "a.txt")
+	git(first, "commit", "-q", "-m", "one")
+	git(first, "tag", "-a", "-m", "release", "v1")
+	git(first, "push", "-q", url, "main", "v1")
+
+	head, err := bare.ReadRef("refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, git(first, "rev-parse", "HEAD"), head)
+
+	git(work, "clone", "-q", url, "second")
+	second := filepath.Join(work, "second")
+	assert.Equal(t, head, git(second, "rev-parse", "HEAD"))
+	assert.Equal(t, head, git(second, "rev-parse", "v1^{commit}"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(second, "b.txt"), []byte("b\n"), 0644))
+	git(second, "add", "b.txt")
+	git(second, "commit", "-q", "-m", "two")
+	git(second, "push", "-q", "origin", "main")
+
+	git(first, "fetch", "-q", url, "main")
+	assert.Equal(t, git(second, "rev-parse", "HEAD"), git(first, "rev-parse", "FETCH_HEAD"))
+	git(first, "fsck", "--strict")
+}
+
+// newDaemon serves d on a local port until the test ends, returning its
+// git:// URL
+func newDaemon(t *testing.T, d *Daemon) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		d.Serve(listener)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		d.Close()
+		<-done
+	})
+	return "git://" + listener.Addr().String()
+}
+
+func TestDaemonFetch(t *testing.T) {
+	source, commit := newSourceRepo(t)
+	url := newDaemon(t, &Daemon{Repositories: map[string]*repository.Repository{"/repo.git": source}})
+
+	transport, err := remote.CreateTransport(url+"/repo.git", nil)
+	require.NoError(t, err)
+	refs, err := transport.ListRefs(context.Background())
+	require.NoError(t, err)
+	tag, err := source.ReadRef("refs/tags/v1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"HEAD":            commit,
+		"refs/heads/main": commit,
+		"refs/tags/v1":    tag,
+		"refs/tags/v1^{}": commit,
+	}, refs)
+
+	opts := clone.DefaultCloneOptions()
+	opts.URL = url + "/repo.git"
+	opts.Directory = filepath.Join(t.TempDir(), "copy")
+	opts.Progress = false
+	result, err := clone.NewCloner().Clone(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, commit, result.ClonedCommit)
+	assert.Equal(t, 6, result.ObjectCount)
+	assert.FileExists(t, filepath.Join(opts.Directory, "cmd", "main.go"))
+
+	missing, err := remote.NewGitTransport(url + "/other.git")
+	require.NoError(t, err)
+	_, err = missing.ListRefs(context.Background())
+	assert.ErrorIs(t, err, errors.ErrRemoteError)
+	assert.Contains(t, err.Error(), "not exported: /other.git")
+}
+
+func TestDaemonPush(t *testing.T) {
+	source, commit := newSourceRepo(t)
+	bare := repository.NewBare(t.TempDir())
+	require.NoError(t, bare.Init())
+	daemon := &Daemon{Repositories: map[string]*repository.Repository{"/repo.git": bare}}
+	url := newDaemon(t, daemon) + "/repo.git"
+
+	require.NoError(t, os.WriteFile(filepath.Join(source.GitDir, "config"),
+		[]byte("[remote \"origin\"]\n\turl = "+url+"\n"), 0644))
+	opts := push.DefaultPushOptions()
+	opts.Branch = "main"
+
+	_, err := push.NewPusher(source).Push(context.Background(), opts)
+	require.ErrorIs(t, err, errors.ErrRemoteError)
+	assert.Contains(t, err.Error(), "service not enabled")
+
+	daemon.ReceivePack = true
+	_, err = push.NewPusher(source).Push(context.Background(), opts)
+	require.NoError(t, err)
+	pushed, err := bare.ReadRef("refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, commit, pushed)
+}
+
+// TestDaemonServesGit has git itself clone and list refs over git://
+func TestDaemonServesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	source, commit := newSourceRepo(t)
+	url := newDaemon(t, &Daemon{Repositories: map[string]*repository.Repository{"/repo.git": source}}) + "/repo.git"
+
+	work := t.TempDir()
+	cmd := exec.Command("git", "clone", "-q", url, "copy")
+	cmd.Dir = work
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "%s", out)
+
+	cmd = exec.Command("git", "rev-parse", "HEAD", "v1^{commit}")
+	cmd.Dir = filepath.Join(work, "copy")
+	out, err = cmd.CombinedOutput()
+	require.NoError(t, err, "%s", out)
+	assert.Equal(t, commit+"\n"+commit+"\n", string(out))
+}
+
+func TestDaemonIdleTimeout(t *testing.T) {
+	source, _ := newSourceRepo(t)
+	url := newDaemon(t, &Daemon{
+		Repositories: map[string]*repository.Repository{"/repo.git": source},
+		Timeout:      50 * time.Millisecond,
+	})
+
+	// a client that connects and never sends its request is dropped
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "git://"))
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestDaemonCloseDropsConnections(t *testing.T) {
+	source, _ := newSourceRepo(t)
+	daemon := &Daemon{Repositories: map[string]*repository.Repository{"/repo.git": source}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		daemon.Serve(listener)
+		close(done)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	// the request packet is split so the connection is open and in use
+	_, err = conn.Write([]byte("0032"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		daemon.mu.Lock()
+		defer daemon.mu.Unlock()
+		return len(daemon.conns) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, daemon.Close())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Close with a connection open")
+	}
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,157 @@
+package ssh
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	knownHostsFile = "known_hosts"
+
+	knownHostsDirMode  = 0o700
+	knownHostsFileMode = 0o600
+)
+
+// HostKeyPolicy says what to do with a host whose key is not in
+// known_hosts, like OpenSSH's StrictHostKeyChecking. A key that differs from
+// the known one is always refused.
+type HostKeyPolicy int
+
+const (
+	// HostKeyAcceptNew records the key of an unknown host and connects
+	HostKeyAcceptNew HostKeyPolicy = iota
+	// HostKeyAsk records the key of an unknown host when ConfirmHostKey
+	// agrees, and refuses it otherwise
+	HostKeyAsk
+	// HostKeyReject refuses unknown hosts
+	HostKeyReject
+)
+
+// sshOption returns the policy as a StrictHostKeyChecking value
+func (p HostKeyPolicy) sshOption() string {
+	switch p {
+	case HostKeyAsk:
+		return "ask"
+	case HostKeyReject:
+		return "yes"
+	default:
+		return "accept-new"
+	}
+}
+
+// HostKeyError reports a host key that was refused. Known holds the
+// recorded keys when the host's key has changed, and is empty when the
+// host was unknown.
+type HostKeyError struct {
+	Host  string
+	Key   ssh.PublicKey
+	Known []knownhosts.KnownKey
+}
+
+// Changed reports whether the host presented a key other than the one
+// recorded for it
+func (e *HostKeyError) Changed() bool {
+	return len(e.Known) > 0
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Changed() {
+		known := e.Known[0]
+		return fmt.Sprintf("host key for %s has changed: got %s %s, expected %s from %s:%d",
+			e.Host, e.Key.Type(), ssh.FingerprintSHA256(e.Key),
+			ssh.FingerprintSHA256(known.Key), known.Filename, known.Line)
+	}
+	return fmt.Sprintf("host key for %s is not known: %s %s", e.Host, e.Key.Type(), ssh.FingerprintSHA256(e.Key))
+}
+
+func (e *HostKeyError) Unwrap() error {
+	if e.Changed() {
+		return errors.ErrHostKeyChanged
+	}
+	return errors.ErrUnknownHostKey
+}
+
+// WithHostKeyPolicy sets how unknown hosts are treated; confirm is asked
+// about them under HostKeyAsk and may be nil. It returns c for chaining.
+func (c *SSHClient) WithHostKeyPolicy(policy HostKeyPolicy, confirm func(host string, key ssh.PublicKey) bool) *SSHClient {
+	c.opts.HostKeyPolicy = policy
+	c.opts.ConfirmHostKey = confirm
+	return c
+}
+
+// knownHostsPath returns the known_hosts file host keys are checked against
+func (c *SSHClient) knownHostsPath() string {
+	if c.opts.KnownHosts != "" {
+		return c.opts.KnownHosts
+	}
+	if c.opts.KeyDir != "" {
+		return filepath.Join(c.opts.KeyDir, knownHostsFile)
+	}
+	return ""
+}
+
+// checkHostKey is the ssh.HostKeyCallback of Connect: it checks key
+// against known_hosts and records it for an unknown host as the policy
+// allows
+func (c *SSHClient) checkHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	path := c.knownHostsPath()
+	if path == "" {
+		return fmt.Errorf("no known_hosts file to verify %s against", hostname)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		check, err := knownhosts.New(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		var keyErr *knownhosts.KeyError
+		err = check(hostname, remote, key)
+		if err == nil || !stderrors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return &HostKeyError{Host: hostname, Key: key, Known: keyErr.Want}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	switch c.opts.HostKeyPolicy {
+	case HostKeyAsk:
+		if c.opts.ConfirmHostKey == nil || !c.opts.ConfirmHostKey(hostname, key) {
+			return &HostKeyError{Host: hostname, Key: key}
+		}
+	case HostKeyReject:
+		return &HostKeyError{Host: hostname, Key: key}
+	}
+
+	return appendKnownHost(path, hostname, key)
+}
+
+// appendKnownHost records key for hostname at the end of the known_hosts
+// file at path, creating the file if needed
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), knownHostsDirMode); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, knownHostsFileMode)
+	if err != nil {
+		return err
+	}
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -34,80 +34,138 @@ const (
 	unixNetwork = "unix"
 )
 
-type SSHClient struct {
-	client  *ssh.Client
-	host    string
-	port    string
-	user    string
-	keyPath string
+// ClientOptions configures an SSHClient
+type ClientOptions struct {
+	Host string
+	Port string
+	User string
+	// KeyPath is a private key tried before any other
+	KeyPath string
+	// KeyDir is searched for id_ed25519, id_ecdsa and id_rsa, in that
+	// order; empty means ~/.ssh
+	KeyDir string
+	// AgentSocket is the ssh-agent socket; empty means $SSH_AUTH_SOCK
+	AgentSocket string
+	// Command replaces the ssh program run by Execute, as core.sshCommand
+	// does; it is run by the shell
+	Command string
+	// KnownHosts is the file host keys are checked against; empty means
+	// known_hosts in KeyDir
+	KnownHosts string
+	// HostKeyPolicy says what to do with hosts missing from KnownHosts
+	HostKeyPolicy HostKeyPolicy
+	// ConfirmHostKey is asked whether to trust an unknown host under
+	// HostKeyAsk; nil refuses it
+	ConfirmHostKey func(host string, key ssh.PublicKey) bool
 }
 
-func NewSSHClient(host, port, user, keyPath string) *SSHClient {
-	return &SSHClient{
-		host:    host,
-		port:    port,
-		user:    user,
-		keyPath: keyPath,
-	}
+type SSHClient struct {
+	client *ssh.Client
+	opts   ClientOptions
+	agent  net.Conn
 }
 
-func (c *SSHClient) Connect(ctx context.Context) (*SSHConnection, error) {
-	var authMethods []ssh.AuthMethod
-	if agentAuth := c.tryAgentAuth(); agentAuth != nil {
-		authMethods = append(authMethods, agentAuth)
+func NewSSHClient(opts ClientOptions) *SSHClient {
+	if opts.Port == "" {
+		opts.Port = defaultSSHPort
 	}
-
-	if c.keyPath != "" {
-		keyAuth, err := c.keyFileAuth(c.keyPath)
-		if err == nil {
-			authMethods = append(authMethods, keyAuth)
-		}
+	if opts.User == "" {
+		opts.User = defaultGitUser
 	}
-
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		defaultKeys := []string{keyRSA, keyED25519, keyECDSA}
-		for _, keyName := range defaultKeys {
-			keyPath := filepath.Join(homeDir, sshDirName, keyName)
-			if keyAuth, err := c.keyFileAuth(keyPath); err == nil {
-				authMethods = append(authMethods, keyAuth)
-			}
+	if opts.KeyDir == "" {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			opts.KeyDir = filepath.Join(homeDir, sshDirName)
 		}
 	}
+	if opts.AgentSocket == "" {
+		opts.AgentSocket = os.Getenv(sshAuthSock)
+	}
+	return &SSHClient{opts: opts}
+}
 
-	if len(authMethods) == 0 {
+func (c *SSHClient) Connect(ctx context.Context) (*SSHConnection, error) {
+	signers := c.signers()
+	if len(signers) == 0 {
 		return nil, fmt.Errorf("no valid authentication methods found")
 	}
 
 	config := &ssh.ClientConfig{
-		User:            c.user,
-		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // todo: Implement proper host key verification
+		User:            c.opts.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: c.checkHostKey,
 		Timeout:         defaultSSHTimeout,
 	}
 
-	addr := net.JoinHostPort(c.host, c.port)
+	addr := net.JoinHostPort(c.opts.Host, c.opts.Port)
 	client, err := ssh.Dial(sshProtocol, addr, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to SSH server: %w", err)
 	}
+	c.client = client
 
 	return &SSHConnection{
 		client: client,
 	}, nil
 }
 
-func (c *SSHClient) tryAgentAuth() ssh.AuthMethod {
-	agentConn, err := net.Dial(unixNetwork, os.Getenv(sshAuthSock))
-	if err != nil {
+// Close releases the connection to ssh-agent, if one was made
+func (c *SSHClient) Close() error {
+	if c.agent != nil {
+		err := c.agent.Close()
+		c.agent = nil
+		return err
+	}
+	return nil
+}
+
+// signers returns the keys to offer the server: the explicit key, then
+// those held by ssh-agent, then the default key files. Keys that cannot
+// be read are skipped.
+func (c *SSHClient) signers() []ssh.Signer {
+	var signers []ssh.Signer
+	if c.opts.KeyPath != "" {
+		if signer, err := c.keyFileSigner(c.opts.KeyPath); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+
+	signers = append(signers, c.agentSigners()...)
+
+	if c.opts.KeyDir != "" {
+		for _, keyName := range []string{keyED25519, keyECDSA, keyRSA} {
+			keyPath := filepath.Join(c.opts.KeyDir, keyName)
+			if keyPath == c.opts.KeyPath {
+				continue
+			}
+			if signer, err := c.keyFileSigner(keyPath); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+
+	return signers
+}
+
+func (c *SSHClient) agentSigners() []ssh.Signer {
+	if c.opts.AgentSocket == "" {
 		return nil
 	}
+	if c.agent == nil {
+		agentConn, err := net.Dial(unixNetwork, c.opts.AgentSocket)
+		if err != nil {
+			return nil
+		}
+		c.agent = agentConn
+	}
 
-	agentClient := agent.NewClient(agentConn)
-	return ssh.PublicKeysCallback(agentClient.Signers)
+	signers, err := agent.NewClient(c.agent).Signers()
+	if err != nil {
+		return nil
+	}
+	return signers
 }
 
-func (c *SSHClient) keyFileAuth(keyPath string) (ssh.AuthMethod, error) {
+func (c *SSHClient) keyFileSigner(keyPath string) (ssh.Signer, error) {
 	key, err := os.ReadFile(keyPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read private key: %w", err)
@@ -118,7 +176,50 @@ func (c *SSHClient) keyFileAuth(keyPath string) (ssh.AuthMethod, error) {
 		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
 
-	return ssh.PublicKeys(signer), nil
+	return signer, nil
+}
+
+// Execute runs command with args on the server through the ssh program, or
+// the configured Command, passing the explicit key when there is one
+func (c *SSHClient) Execute(ctx context.Context, command string, args []string) (io.ReadWriteCloser, error) {
+	cmd := exec.CommandContext(ctx, sshCommand, c.commandArgs(command, args)...)
+	if c.opts.Command != "" {
+		// like git, let the shell split the configured command
+		cmd = exec.CommandContext(ctx, "sh", append([]string{"-c", c.opts.Command + ` "$@"`, c.opts.Command},
+			c.commandArgs(command, args)...)...)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start SSH command: %w", err)
+	}
+
+	return &cmdStream{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: stdout,
+	}, nil
+}
+
+func (c *SSHClient) commandArgs(command string, args []string) []string {
+	sshArgs := []string{"-p", c.opts.Port, "-o", "StrictHostKeyChecking=" + c.opts.HostKeyPolicy.sshOption()}
+	if c.opts.KnownHosts != "" {
+		sshArgs = append(sshArgs, "-o", "UserKnownHostsFile="+c.opts.KnownHosts)
+	}
+	if c.opts.KeyPath != "" {
+		sshArgs = append(sshArgs, "-i", c.opts.KeyPath)
+	}
+	sshArgs = append(sshArgs, fmt.Sprintf("%s@%s", c.opts.User, c.opts.Host), command)
+	return append(sshArgs, args...)
 }
 
 type SSHConnection struct {
@@ -186,33 +287,7 @@ func (s *sshStream) Close() error {
 
 // fallback to using ssh command if crypto/ssh doesn't work
 func ExecuteSSHCommand(ctx context.Context, host, port, user, command string, args []string) (io.ReadWriteCloser, error) {
-	sshArgs := []string{
-		"-p", port,
-		fmt.Sprintf("%s@%s", user, host),
-		command,
-	}
-	sshArgs = append(sshArgs, args...)
-
-	cmd := exec.CommandContext(ctx, sshCommand, sshArgs...)
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
-	}
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start SSH command: %w", err)
-	}
-
-	return &cmdStream{
-		cmd:    cmd,
-		stdin:  stdin,
-		stdout: stdout,
-	}, nil
+	return NewSSHClient(ClientOptions{Host: host, Port: port, User: user}).Execute(ctx, command, args)
 }
 
 type cmdStream struct {
@@ -0,0 +1,226 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	stderrors "errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startAgent serves an in-memory ssh-agent holding one new key and returns
+// its socket path and public key
+func startAgent(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	// unix socket paths are short, so avoid the long test temp dir
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "sock")
+	listener, err := net.Listen(unixNetwork, socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return socket, signer.PublicKey()
+}
+
+// writeKey writes a new unencrypted private key to path and returns its
+// public key
+func writeKey(t *testing.T, path string) ssh.PublicKey {
+	t.Helper()
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshPub
+}
+
+func publicKeys(signers []ssh.Signer) [][]byte {
+	var keys [][]byte
+	for _, signer := range signers {
+		keys = append(keys, signer.PublicKey().Marshal())
+	}
+	return keys
+}
+
+func TestSignersAgentWithoutKeyFiles(t *testing.T) {
+	socket, agentKey := startAgent(t)
+
+	client := NewSSHClient(ClientOptions{Host: "example.com", KeyDir: t.TempDir(), AgentSocket: socket})
+	defer client.Close()
+
+	keys := publicKeys(client.signers())
+	if len(keys) != 1 || !bytes.Equal(keys[0], agentKey.Marshal()) {
+		t.Fatalf("expected only the agent key, got %d keys", len(keys))
+	}
+}
+
+func TestSignersOrder(t *testing.T) {
+	socket, agentKey := startAgent(t)
+	keyDir := t.TempDir()
+	rsaKey := writeKey(t, filepath.Join(keyDir, keyRSA))
+	ed25519Key := writeKey(t, filepath.Join(keyDir, keyED25519))
+	explicitPath := filepath.Join(t.TempDir(), "deploy_key")
+	explicitKey := writeKey(t, explicitPath)
+
+	client := NewSSHClient(ClientOptions{Host: "example.com", KeyPath: explicitPath, KeyDir: keyDir, AgentSocket: socket})
+	defer client.Close()
+
+	want := [][]byte{explicitKey.Marshal(), agentKey.Marshal(), ed25519Key.Marshal(), rsaKey.Marshal()}
+	if got := publicKeys(client.signers()); !slices.EqualFunc(got, want, bytes.Equal) {
+		t.Fatalf("expected explicit, agent, id_ed25519 then id_rsa keys, got %d keys in another order", len(got))
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	client := NewSSHClient(ClientOptions{Host: "example.com", Port: "2222", KeyPath: "/keys/deploy"})
+
+	got := client.commandArgs("git-upload-pack", []string{"user/repo.git"})
+	want := []string{"-p", "2222", "-o", "StrictHostKeyChecking=accept-new", "-i", "/keys/deploy",
+		"git@example.com", "git-upload-pack", "user/repo.git"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("commandArgs = %q, want %q", got, want)
+	}
+}
+
+func newPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestCheckHostKey(t *testing.T) {
+	const host = "example.com:22"
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+	key, otherKey := newPublicKey(t), newPublicKey(t)
+
+	t.Run("AcceptNew", func(t *testing.T) {
+		knownHosts := filepath.Join(t.TempDir(), "ssh", knownHostsFile)
+		client := NewSSHClient(ClientOptions{Host: "example.com", KnownHosts: knownHosts})
+
+		if err := client.checkHostKey(host, remote, key); err != nil {
+			t.Fatalf("unknown host not accepted: %v", err)
+		}
+		data, err := os.ReadFile(knownHosts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "example.com ssh-ed25519 ") {
+			t.Fatalf("unexpected known_hosts content %q", data)
+		}
+
+		if err := client.checkHostKey(host, remote, key); err != nil {
+			t.Fatalf("recorded key refused: %v", err)
+		}
+
+		err = client.checkHostKey(host, remote, otherKey)
+		var hostKeyErr *HostKeyError
+		if !stderrors.As(err, &hostKeyErr) || !hostKeyErr.Changed() || !stderrors.Is(err, errors.ErrHostKeyChanged) {
+			t.Fatalf("expected a changed host key error, got %v", err)
+		}
+		if hostKeyErr.Known[0].Line != 1 {
+			t.Errorf("expected the offending line to be 1, got %d", hostKeyErr.Known[0].Line)
+		}
+		if after, _ := os.ReadFile(knownHosts); string(after) != string(data) {
+			t.Error("known_hosts changed after a refused key")
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		knownHosts := filepath.Join(t.TempDir(), knownHostsFile)
+		client := NewSSHClient(ClientOptions{Host: "example.com", KnownHosts: knownHosts}).
+			WithHostKeyPolicy(HostKeyReject, nil)
+
+		err := client.checkHostKey(host, remote, key)
+		var hostKeyErr *HostKeyError
+		if !stderrors.As(err, &hostKeyErr) || hostKeyErr.Changed() || !stderrors.Is(err, errors.ErrUnknownHostKey) {
+			t.Fatalf("expected an unknown host key error, got %v", err)
+		}
+		if _, err := os.Stat(knownHosts); !os.IsNotExist(err) {
+			t.Error("rejected host was recorded")
+		}
+	})
+
+	t.Run("Ask", func(t *testing.T) {
+		knownHosts := filepath.Join(t.TempDir(), knownHostsFile)
+		var asked []string
+		answer := false
+		client := NewSSHClient(ClientOptions{Host: "example.com", KnownHosts: knownHosts}).
+			WithHostKeyPolicy(HostKeyAsk, func(host string, _ ssh.PublicKey) bool {
+				asked = append(asked, host)
+				return answer
+			})
+
+		if err := client.checkHostKey(host, remote, key); !stderrors.Is(err, errors.ErrUnknownHostKey) {
+			t.Fatalf("declined host accepted: %v", err)
+		}
+		answer = true
+		if err := client.checkHostKey(host, remote, key); err != nil {
+			t.Fatalf("confirmed host refused: %v", err)
+		}
+		if err := client.checkHostKey(host, remote, key); err != nil {
+			t.Fatalf("recorded key refused: %v", err)
+		}
+		if !slices.Equal(asked, []string{host, host}) {
+			t.Errorf("expected two prompts, got %q", asked)
+		}
+	})
+}
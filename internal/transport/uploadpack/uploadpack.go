@@ -0,0 +1,405 @@
+package uploadpack
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/unkn0wn-root/git-go/internal/core/hash"
+	"github.com/unkn0wn-root/git-go/internal/core/mergebase"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/pktline"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+const (
+	zeroHash = "0000000000000000000000000000000000000000"
+	headRef  = "HEAD"
+
+	capabilities     = "multi_ack_detailed side-band-64k side-band"
+	multiAckDetailed = "multi_ack_detailed"
+	sideband64k      = "side-band-64k"
+	sideband         = "side-band"
+	// maxSmallSidebandData is what a side-band packet carries, for clients
+	// that did not ask for side-band-64k
+	maxSmallSidebandData = 995
+
+	peeledSuffix = "^{}"
+)
+
+// Handler serves fetches and clones from repo: the counterpart of a
+// transport's ListRefs and FetchPack
+type Handler struct {
+	repo *repository.Repository
+}
+
+func New(repo *repository.Repository) *Handler {
+	return &Handler{repo: repo}
+}
+
+// AdvertiseRefs writes HEAD and every ref, with annotated tags followed by
+// what they peel to, the capabilities attached to the first line, and a
+// flush packet
+func (h *Handler) AdvertiseRefs(w io.Writer) error {
+	refs, err := h.repo.ListRefs()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	caps := capabilities
+	target, detached, head, err := h.repo.HeadRef()
+	if err != nil {
+		return err
+	}
+	if !detached && head != "" {
+		caps += " symref=" + headRef + ":" + target
+	}
+
+	var lines []string
+	if head != "" {
+		lines = append(lines, head+" "+headRef)
+	}
+	for _, name := range names {
+		lines = append(lines, refs[name]+" "+name)
+		if peeled, ok := h.peel(refs[name]); ok {
+			lines = append(lines, peeled+" "+name+peeledSuffix)
+		}
+	}
+	if len(lines) == 0 {
+		// an empty repository still has to announce its capabilities
+		lines = append(lines, zeroHash+" capabilities"+peeledSuffix)
+	}
+
+	var buf bytes.Buffer
+	for i, line := range lines {
+		if i == 0 {
+			line += "\x00" + caps
+		}
+		pktline.Write(&buf, line+"\n")
+	}
+	buf.WriteString(pktline.Flush)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// tips returns the hashes AdvertiseRefs announces: HEAD, every ref, and
+// what annotated tags peel to
+func (h *Handler) tips() (map[string]bool, error) {
+	refs, err := h.repo.ListRefs()
+	if err != nil {
+		return nil, err
+	}
+	head, err := h.repo.GetHead()
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make(map[string]bool, len(refs)+1)
+	if head != "" {
+		tips[head] = true
+	}
+	for _, objHash := range refs {
+		tips[objHash] = true
+		if peeled, ok := h.peel(objHash); ok {
+			tips[peeled] = true
+		}
+	}
+	return tips, nil
+}
+
+// peel returns what the annotated tag objHash points at, following tags of
+// tags, and false for any other object
+func (h *Handler) peel(objHash string) (string, bool) {
+	peeled := false
+	for {
+		objType, data, err := h.repo.ReadObjectData(objHash)
+		if err != nil || objType != objects.ObjectTypeTag {
+			return objHash, peeled
+		}
+		if objHash, err = objects.ParseTagTarget(data); err != nil {
+			return "", false
+		}
+		peeled = true
+	}
+}
+
+// Serve reads a fetch request from r and answers on w: the wants, then
+// rounds of haves acknowledged one flush at a time, and once the client
+// says done, the pack of everything it wants that it does not have. A
+// stateless client, as over HTTP, ends the request after a round and
+// comes back with a new one.
+func (h *Handler) Serve(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	wants, caps, err := readWants(reader)
+	if err != nil {
+		return err
+	}
+	// a client with everything it wants sends no wants at all
+	if len(wants) == 0 {
+		return nil
+	}
+	// only what was advertised may be asked for, so an object no ref
+	// reaches, such as one a branch left behind, stays private
+	tips, err := h.tips()
+	if err != nil {
+		return err
+	}
+	for _, want := range wants {
+		if !tips[want] {
+			var buf bytes.Buffer
+			pktline.Write(&buf, "ERR upload-pack: not our ref "+want+"\n")
+			w.Write(buf.Bytes())
+			return errors.NewObjectError(want, "", errors.ErrObjectNotFound)
+		}
+	}
+
+	var common []string
+	for {
+		haves, done, err := readHaves(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var round bytes.Buffer
+		for _, have := range haves {
+			if slices.Contains(common, have) || !h.repo.HasObject(have) {
+				continue
+			}
+			common = append(common, have)
+			if !done && slices.Contains(caps, multiAckDetailed) {
+				pktline.Write(&round, "ACK "+have+" common\n")
+			}
+		}
+		if done {
+			break
+		}
+		pktline.Write(&round, "NAK\n")
+		if _, err := w.Write(round.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	var reply bytes.Buffer
+	if len(common) > 0 {
+		pktline.Write(&reply, "ACK "+common[len(common)-1]+"\n")
+	} else {
+		pktline.Write(&reply, "NAK\n")
+	}
+
+	hashes, err := objectsToSend(h.repo, wants, common)
+	if err != nil {
+		return err
+	}
+	packData, _, err := pack.BuildPack(h.repo, hashes)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case slices.Contains(caps, sideband64k):
+		pktline.WriteBand(&reply, pktline.BandData, packData)
+		reply.WriteString(pktline.Flush)
+	case slices.Contains(caps, sideband):
+		for chunk := range slices.Chunk(packData, maxSmallSidebandData) {
+			pktline.WriteBand(&reply, pktline.BandData, chunk)
+		}
+		reply.WriteString(pktline.Flush)
+	default:
+		reply.Write(packData)
+	}
+
+	_, err = w.Write(reply.Bytes())
+	return err
+}
+
+// readWants reads the "want" lines up to the flush, along with the
+// capabilities the client put after the first one
+func readWants(r *bufio.Reader) ([]string, []string, error) {
+	var wants, caps []string
+
+	for {
+		packet, err := pktline.Read(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read wants: %w", err)
+		}
+		if packet == nil {
+			return wants, caps, nil
+		}
+
+		fields := strings.Fields(string(packet))
+		switch {
+		case len(fields) >= 2 && fields[0] == "want" && hash.ValidateHash(fields[1]):
+			if len(wants) == 0 {
+				caps = fields[2:]
+			}
+			if !slices.Contains(wants, fields[1]) {
+				wants = append(wants, fields[1])
+			}
+		case len(fields) > 0 && (fields[0] == "shallow" || strings.HasPrefix(fields[0], "deepen")):
+			return nil, nil, fmt.Errorf("shallow fetches are not supported")
+		default:
+			return nil, nil, fmt.Errorf("invalid fetch request line %q", packet)
+		}
+	}
+}
+
+// readHaves reads one round of "have" lines, ended by a flush or by "done"
+func readHaves(r *bufio.Reader) ([]string, bool, error) {
+	var haves []string
+
+	for {
+		packet, err := pktline.Read(r)
+		if err != nil {
+			return nil, false, err
+		}
+		if packet == nil {
+			return haves, false, nil
+		}
+
+		line := strings.TrimSuffix(string(packet), "\n")
+		if line == "done" {
+			return haves, true, nil
+		}
+		have, ok := strings.CutPrefix(line, "have ")
+		if !ok || !hash.ValidateHash(have) {
+			return nil, false, fmt.Errorf("invalid fetch request line %q", line)
+		}
+		haves = append(haves, have)
+	}
+}
+
+// objectsToSend lists what a client holding common needs for wants: the
+// tags and commits from wants down to common's history, and the trees and
+// blobs beneath them that are not already under the commits where the new
+// history meets the client's
+func objectsToSend(repo *repository.Repository, wants, common []string) ([]string, error) {
+	var haveCommits []string
+	for _, have := range common {
+		if _, err := repo.LoadCommit(have); err == nil {
+			haveCommits = append(haveCommits, have)
+		}
+	}
+	clientHas, err := mergebase.Ancestors(repo, haveCommits...)
+	if err != nil {
+		return nil, err
+	}
+
+	// trees and blobs wanted directly, or through a tag, are sent like the
+	// ones under commits: only when the client does not already have them
+	var result, trees, blobs []string
+	boundary := haveCommits
+	seen := make(map[string]bool)
+	stack := slices.Clone(wants)
+
+	for len(stack) > 0 {
+		objHash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[objHash] || clientHas[objHash] {
+			continue
+		}
+		seen[objHash] = true
+
+		objType, data, err := repo.ReadObjectData(objHash)
+		if err != nil {
+			return nil, errors.NewObjectError(objHash, "", err)
+		}
+
+		switch objType {
+		case objects.ObjectTypeTag:
+			target, err := objects.ParseTagTarget(data)
+			if err != nil {
+				return nil, errors.NewObjectError(objHash, string(objType), err)
+			}
+			result = append(result, objHash)
+			stack = append(stack, target)
+		case objects.ObjectTypeCommit:
+			obj, err := objects.ParseObject(objType, data)
+			if err != nil {
+				return nil, errors.NewObjectError(objHash, string(objType), err)
+			}
+			commit := obj.(*objects.Commit)
+			result = append(result, objHash)
+			trees = append(trees, commit.Tree())
+			for _, parent := range commit.Parents() {
+				if clientHas[parent] {
+					boundary = append(boundary, parent)
+				} else {
+					stack = append(stack, parent)
+				}
+			}
+		case objects.ObjectTypeTree:
+			trees = append(trees, objHash)
+		default:
+			blobs = append(blobs, objHash)
+		}
+	}
+
+	have := make(map[string]bool)
+	var boundaryTrees []string
+	for _, commitHash := range boundary {
+		if commit, err := repo.LoadCommit(commitHash); err == nil {
+			boundaryTrees = append(boundaryTrees, commit.Tree())
+		}
+	}
+	if err := walkTrees(repo, boundaryTrees, have, func(string) {}); err != nil {
+		return nil, err
+	}
+	if err := walkTrees(repo, trees, have, func(objHash string) { result = append(result, objHash) }); err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		if !have[blob] {
+			have[blob] = true
+			result = append(result, blob)
+		}
+	}
+	return result, nil
+}
+
+// walkTrees passes each tree under roots, and each blob in them, to visit
+// once, marking it in seen. Whatever seen already holds is skipped along
+// with everything beneath it.
+func walkTrees(repo *repository.Repository, roots []string, seen map[string]bool, visit func(string)) error {
+	stack := slices.Clone(roots)
+	for len(stack) > 0 {
+		treeHash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[treeHash] {
+			continue
+		}
+		seen[treeHash] = true
+		visit(treeHash)
+
+		tree, err := repo.LoadTree(treeHash)
+		if err != nil {
+			return errors.NewObjectError(treeHash, string(objects.ObjectTypeTree), err)
+		}
+		for _, entry := range tree.Entries() {
+			switch {
+			// a submodule's commit is fetched from its own repository
+			case entry.IsSubmodule() || seen[entry.Hash]:
+			case entry.IsDir():
+				stack = append(stack, entry.Hash)
+			default:
+				seen[entry.Hash] = true
+				visit(entry.Hash)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package uploadpack
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unkn0wn-root/git-go/internal/core/objects"
+	"github.com/unkn0wn-root/git-go/internal/core/pack"
+	"github.com/unkn0wn-root/git-go/internal/core/repository"
+	"github.com/unkn0wn-root/git-go/internal/transport/pktline"
+	"github.com/unkn0wn-root/git-go/pkg/errors"
+)
+
+type history struct {
+	t    *testing.T
+	repo *repository.Repository
+	tick int64
+}
+
+func newHistory(t *testing.T) *history {
+	repo := repository.New(t.TempDir())
+	require.NoError(t, repo.Init())
+	return &history{t: t, repo: repo}
+}
+
+// commit stores a commit whose tree holds files, returning it along with
+// its tree
+func (h *history) commit(files map[string]string, parents ...string) (string, string) {
+	h.t.Helper()
+	var entries []objects.TreeEntry
+	for name, content := range files {
+		blob, err := h.repo.StoreObject(objects.NewBlob([]byte(content)))
+		require.NoError(h.t, err)
+		entries = append(entries, objects.TreeEntry{Mode: objects.FileModeBlob, Name: name, Hash: blob})
+	}
+	slices.SortFunc(entries, func(a, b objects.TreeEntry) int { return strings.Compare(a.Name, b.Name) })
+	tree, err := h.repo.StoreObject(objects.NewTree(entries))
+	require.NoError(h.t, err)
+
+	h.tick++
+	sig := &objects.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000+h.tick, 0)}
+	commit, err := h.repo.StoreObject(objects.NewCommit(tree, parents, sig, sig, "commit\n"))
+	require.NoError(h.t, err)
+	return commit, tree
+}
+
+func request(caps string, wants, haves []string, done bool) []byte {
+	var buf bytes.Buffer
+	for i, want := range wants {
+		line := "want " + want
+		if i == 0 && caps != "" {
+			line += " " + caps
+		}
+		pktline.Write(&buf, line+"\n")
+	}
+	buf.WriteString(pktline.Flush)
+	for _, have := range haves {
+		pktline.Write(&buf, "have "+have+"\n")
+	}
+	if done {
+		pktline.Write(&buf, "done\n")
+	} else {
+		buf.WriteString(pktline.Flush)
+	}
+	return buf.Bytes()
+}
+
+// readReply returns the negotiation lines of a reply and the objects of the
+// sideband pack that follows them, stored into a fresh repository
+func readReply(t *testing.T, reply []byte) ([]string, []string) {
+	t.Helper()
+	r := bufio.NewReader(bytes.NewReader(reply))
+
+	var lines []string
+	var packData []byte
+	for {
+		packet, err := pktline.Read(r)
+		if err != nil || packet == nil {
+			break
+		}
+		if packet[0] == pktline.BandData {
+			packData = append(packData, packet[1:]...)
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(string(packet), "\n"))
+	}
+	if packData == nil {
+		return lines, nil
+	}
+
+	client := repository.New(t.TempDir())
+	require.NoError(t, client.Init())
+	require.NoError(t, pack.NewPackProcessor(client).ProcessPack(bytes.NewReader(packData)))
+	var received []string
+	require.NoError(t, client.ForEachObject(func(hash string, _ objects.ObjectType) error {
+		received = append(received, hash)
+		return nil
+	}))
+	slices.Sort(received)
+	return lines, received
+}
+
+func TestAdvertiseRefs(t *testing.T) {
+	h := newHistory(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, New(h.repo).AdvertiseRefs(&buf))
+	var want bytes.Buffer
+	pktline.Write(&want, zeroHash+" capabilities^{}\x00"+capabilities+"\n")
+	want.WriteString(pktline.Flush)
+	assert.Equal(t, want.String(), buf.String())
+
+	commit, _ := h.commit(map[string]string{"a.txt": "a\n"})
+	require.NoError(t, h.repo.UpdateRef("refs/heads/main", commit))
+	tag, err := h.repo.StoreRawObject(objects.ObjectTypeTag, []byte(fmt.Sprintf(
+		"object %s\ntype commit\ntag v1\ntagger Test <test@example.com> 1700000000 +0000\n\nv1\n", commit)))
+	require.NoError(t, err)
+	require.NoError(t, h.repo.UpdateRef("refs/tags/v1", tag))
+
+	buf.Reset()
+	want.Reset()
+	require.NoError(t, New(h.repo).AdvertiseRefs(&buf))
+	pktline.Write(&want, commit+" HEAD\x00"+capabilities+" symref=HEAD:refs/heads/main\n")
+	pktline.Write(&want, commit+" refs/heads/main\n")
+	pktline.Write(&want, tag+" refs/tags/v1\n")
+	pktline.Write(&want, commit+" refs/tags/v1^{}\n")
+	want.WriteString(pktline.Flush)
+	assert.Equal(t, want.String(), buf.String())
+}
+
+func TestServe_Clone(t *testing.T) {
+	h := newHistory(t)
+	first, firstTree := h.commit(map[string]string{"a.txt": "a\n"})
+	second, secondTree := h.commit(map[string]string{"a.txt": "a\n", "b.txt": "b\n"}, first)
+	require.NoError(t, h.repo.UpdateRef("refs/heads/main", second))
+
+	var reply bytes.Buffer
+	require.NoError(t, New(h.repo).Serve(bytes.NewReader(request("side-band-64k", []string{second}, nil, true)), &reply))
+	lines, received := readReply(t, reply.Bytes())
+
+	assert.Equal(t, []string{"NAK"}, lines)
+	var all []string
+	require.NoError(t, h.repo.ForEachObject(func(hash string, _ objects.ObjectType) error {
+		all = append(all, hash)
+		return nil
+	}))
+	slices.Sort(all)
+	assert.Equal(t, all, received)
+	assert.Contains(t, received, firstTree)
+	assert.Contains(t, received, secondTree)
+}
+
+func TestServe_Negotiation(t *testing.T) {
+	h := newHistory(t)
+	base, _ := h.commit(map[string]string{"a.txt": "a\n"})
+	tip, tipTree := h.commit(map[string]string{"a.txt": "a\n", "b.txt": "b\n"}, base)
+	require.NoError(t, h.repo.UpdateRef("refs/heads/main", tip))
+	unknown := strings.Repeat("1", 40)
+	caps := "multi_ack_detailed side-band-64k"
+
+	// a round without done only acknowledges what is common
+	var reply bytes.Buffer
+	require.NoError(t, New(h.repo).Serve(bytes.NewReader(request(caps, []string{tip}, []string{unknown, base}, false)), &reply))
+	lines, received := readReply(t, reply.Bytes())
+	assert.Equal(t, []string{"ACK " + base + " common", "NAK"}, lines)
+	assert.Nil(t, received)
+
+	reply.Reset()
+	require.NoError(t, New(h.repo).Serve(bytes.NewReader(request(caps, []string{tip}, []string{unknown, base}, true)), &reply))
+	lines, received = readReply(t, reply.Bytes())
+	assert.Equal(t, []string{"ACK " + base}, lines)
+
+	tipCommit, err := h.repo.LoadCommit(tip)
+	require.NoError(t, err)
+	tree, err := h.repo.LoadTree(tipCommit.Tree())
+	require.NoError(t, err)
+	var newBlob string
+	for _, entry := range tree.Entries() {
+		if entry.Name == "b.txt" {
+			newBlob = entry.Hash
+		}
+	}
+	// a.txt is unchanged since base, so it is left out
+	want := []string{tip, tipTree, newBlob}
+	slices.Sort(want)
+	assert.Equal(t, want, received)
+}
+
+func TestServe_WithoutSideband(t *testing.T) {
+	h := newHistory(t)
+	commit, _ := h.commit(map[string]string{"a.txt": "a\n"})
+	require.NoError(t, h.repo.UpdateRef("refs/heads/main", commit))
+
+	var reply bytes.Buffer
+	require.NoError(t, New(h.repo).Serve(bytes.NewReader(request("", []string{commit}, nil, true)), &reply))
+
+	var nak bytes.Buffer
+	pktline.Write(&nak, "NAK\n")
+	require.True(t, bytes.HasPrefix(reply.Bytes(), nak.Bytes()))
+	assert.Equal(t, "PACK", string(reply.Bytes()[nak.Len():nak.Len()+4]))
+}
+
+func TestServe_UnknownWant(t *testing.T) {
+	h := newHistory(t)
+	missing := strings.Repeat("2", 40)
+
+	var reply bytes.Buffer
+	err := New(h.repo).Serve(bytes.NewReader(request("", []string{missing}, nil, true)), &reply)
+	assert.ErrorIs(t, err, errors.ErrObjectNotFound)
+	assert.Contains(t, reply.String(), "ERR upload-pack: not our ref "+missing)
+}
+
+func TestServe_UnadvertisedWant(t *testing.T) {
+	h := newHistory(t)
+	base, baseTree := h.commit(map[string]string{"a.txt": "a\n"})
+	tip, _ := h.commit(map[string]string{"a.txt": "a\n", "b.txt": "b\n"}, base)
+	require.NoError(t, h.repo.UpdateRef("refs/heads/main", base))
+	tag, err := h.repo.StoreRawObject(objects.ObjectTypeTag, []byte(fmt.Sprintf(
+		"object %s\ntype commit\ntag v1\ntagger Test <test@example.com> 1700000000 +0000\n\nv1\n", base)))
+	require.NoError(t, err)
+	require.NoError(t, h.repo.UpdateRef("refs/tags/v1", tag))
+
+	// a commit no ref points at and a tree below a tip are both refused
+	for _, want := range []string{tip, baseTree} {
+		var reply bytes.Buffer
+		err := New(h.repo).Serve(bytes.NewReader(request("", []string{want}, nil, true)), &reply)
+		assert.ErrorIs(t, err, errors.ErrObjectNotFound)
+		assert.Contains(t, reply.String(), "ERR upload-pack: not our ref "+want)
+	}
+
+	// the tag and what it peels to are advertised
+	for _, want := range []string{tag, base} {
+		var reply bytes.Buffer
+		require.NoError(t, New(h.repo).Serve(bytes.NewReader(request("side-band-64k", []string{want}, nil, true)), &reply))
+	}
+}
@@ -2,6 +2,7 @@ package display
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -14,6 +15,7 @@ const (
 	RefUpdateForced
 	RefUpdateOK
 	RefUpdateRejected
+	RefUpdateDeleted
 )
 
 type RefUpdate struct {
@@ -73,6 +75,10 @@ func (cf *CommandFormatter) FormatPushResult(remote string, updates map[string]R
 					cf.Hash(update.NewHash),
 					cf.Branch(branchName)))
 			}
+		case RefUpdateDeleted:
+			buf.WriteString(fmt.Sprintf(" - %s         %s\n",
+				cf.Apply(WarningStyle, "[deleted]"),
+				cf.Branch(branchName)))
 		case RefUpdateRejected:
 			buf.WriteString(fmt.Sprintf(" ! %s        %s %s\n",
 				cf.Apply(ErrorStyle, "[rejected]"),
@@ -101,13 +107,21 @@ func (cf *CommandFormatter) FormatCloneProgress(repo, progress string) string {
 	return buf.String()
 }
 
-func (cf *CommandFormatter) FormatCommitResult(hash, branch, message string, filesChanged, insertions, deletions int) string {
+func (cf *CommandFormatter) FormatCommitResult(hash, branch, message string, root bool, filesChanged, insertions, deletions int) string {
 	var buf strings.Builder
 
-	buf.WriteString(fmt.Sprintf("[%s %s] %s\n",
-		cf.Branch(branch),
-		cf.Hash(hash),
-		message))
+	if root {
+		buf.WriteString(fmt.Sprintf("[%s %s %s] %s\n",
+			cf.Branch(branch),
+			cf.Apply(SecondaryStyle, "(root-commit)"),
+			cf.Hash(hash),
+			message))
+	} else {
+		buf.WriteString(fmt.Sprintf("[%s %s] %s\n",
+			cf.Branch(branch),
+			cf.Hash(hash),
+			message))
+	}
 
 	if filesChanged > 0 {
 		parts := []string{
@@ -165,6 +179,22 @@ func (cf *CommandFormatter) FormatRemoteList(remotes map[string]string, verbose
 	return buf.String()
 }
 
+// FormatRemoteRefs lists refs as ls-remote prints them, one "<hash>\t<ref>"
+// line per ref in ref name order
+func (cf *CommandFormatter) FormatRemoteRefs(refs map[string]string) string {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf strings.Builder
+	for _, name := range names {
+		buf.WriteString(fmt.Sprintf("%s\t%s\n", cf.Apply(HashStyle, refs[name]), cf.Branch(name)))
+	}
+	return buf.String()
+}
+
 func (cf *CommandFormatter) FormatInitResult(path string, bare bool) string {
 	var msg string
 	if bare {
@@ -257,8 +287,8 @@ func FormatPushResult(remote string, updates map[string]RefUpdate, newBranch boo
 func FormatCloneProgress(repo, progress string) string {
 	return defaultCommandFormatter.FormatCloneProgress(repo, progress)
 }
-func FormatCommitResult(hash, branch, message string, filesChanged, insertions, deletions int) string {
-	return defaultCommandFormatter.FormatCommitResult(hash, branch, message, filesChanged, insertions, deletions)
+func FormatCommitResult(hash, branch, message string, root bool, filesChanged, insertions, deletions int) string {
+	return defaultCommandFormatter.FormatCommitResult(hash, branch, message, root, filesChanged, insertions, deletions)
 }
 func FormatResetResult(mode, target string, filesChanged int) string {
 	return defaultCommandFormatter.FormatResetResult(mode, target, filesChanged)
@@ -269,6 +299,9 @@ func FormatRemoteResult(operation, remote, url string) string {
 func FormatRemoteList(remotes map[string]string, verbose bool) string {
 	return defaultCommandFormatter.FormatRemoteList(remotes, verbose)
 }
+func FormatRemoteRefs(refs map[string]string) string {
+	return defaultCommandFormatter.FormatRemoteRefs(refs)
+}
 func FormatInitResult(path string, bare bool) string {
 	return defaultCommandFormatter.FormatInitResult(path, bare)
 }
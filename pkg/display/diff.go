@@ -2,6 +2,7 @@ package display
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -18,6 +19,8 @@ type DiffLine struct {
 	Content string
 	OldLine int
 	NewLine int
+	// NoNewline marks the last line of a file without a final newline
+	NoNewline bool
 }
 
 type DiffHunk struct {
@@ -28,6 +31,35 @@ type DiffHunk struct {
 	Lines    []DiffLine
 }
 
+// CombinedDiffLine is a line of a merge's combined diff, with a mark
+// column per parent
+type CombinedDiffLine struct {
+	Marks   string
+	Content string
+}
+
+type CombinedDiffHunk struct {
+	OldStarts []int
+	OldCounts []int
+	NewStart  int
+	NewCount  int
+	Lines     []CombinedDiffLine
+}
+
+// DiffStat is the change count of one file in a --stat summary
+type DiffStat struct {
+	Path    string
+	Added   int
+	Removed int
+	Binary  bool
+}
+
+// bars in a --stat summary are scaled down beyond this many columns
+const maxStatBarWidth = 50
+
+// devNull names the missing side of a diff that creates or deletes a file
+const devNull = "/dev/null"
+
 type DiffFormatter struct {
 	*Formatter
 }
@@ -52,7 +84,11 @@ func (df *DiffFormatter) FormatDiffLine(line DiffLine) string {
 		style = DiffRemovedStyle
 	}
 
-	return df.Apply(style, prefix+line.Content)
+	formatted := df.Apply(style, prefix+line.Content)
+	if line.NoNewline {
+		formatted += "\n" + df.FormatNoNewlineWarning()
+	}
+	return formatted
 }
 
 func (df *DiffFormatter) FormatDiffHeader(oldPath, newPath string) string {
@@ -66,9 +102,43 @@ func (df *DiffFormatter) FormatDiffHeader(oldPath, newPath string) string {
 	return buf.String()
 }
 
+// FormatNewFileHeader is the header of a diff that creates path, whose
+// old side is /dev/null
+func (df *DiffFormatter) FormatNewFileHeader(path, mode string) string {
+	var buf strings.Builder
+	buf.WriteString(df.Apply(DiffHeaderStyle, fmt.Sprintf("diff --git a/%s b/%s", path, path)))
+	buf.WriteString("\n")
+	buf.WriteString(df.Apply(DiffHeaderStyle, fmt.Sprintf("new file mode %s", mode)))
+	buf.WriteString("\n")
+	buf.WriteString(df.Apply(DiffRemovedStyle, "--- "+devNull))
+	buf.WriteString("\n")
+	buf.WriteString(df.Apply(DiffAddedStyle, fmt.Sprintf("+++ b/%s", path)))
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// FormatDeletedFileHeader is the header of a diff that deletes path, whose
+// new side is /dev/null
+func (df *DiffFormatter) FormatDeletedFileHeader(path, mode string) string {
+	var buf strings.Builder
+	buf.WriteString(df.Apply(DiffHeaderStyle, fmt.Sprintf("diff --git a/%s b/%s", path, path)))
+	buf.WriteString("\n")
+	buf.WriteString(df.Apply(DiffHeaderStyle, fmt.Sprintf("deleted file mode %s", mode)))
+	buf.WriteString("\n")
+	buf.WriteString(df.Apply(DiffRemovedStyle, fmt.Sprintf("--- a/%s", path)))
+	buf.WriteString("\n")
+	buf.WriteString(df.Apply(DiffAddedStyle, "+++ "+devNull))
+	buf.WriteString("\n")
+	return buf.String()
+}
+
 func (df *DiffFormatter) FormatFileDiff(oldPath, newPath string, lines []DiffLine) string {
+	return df.FormatDiffHeader(oldPath, newPath) + df.FormatLines(lines)
+}
+
+// FormatLines renders diff lines without a header or hunk headers
+func (df *DiffFormatter) FormatLines(lines []DiffLine) string {
 	var buf strings.Builder
-	buf.WriteString(df.FormatDiffHeader(oldPath, newPath))
 	for _, line := range lines {
 		buf.WriteString(df.FormatDiffLine(line))
 		buf.WriteString("\n")
@@ -97,9 +167,12 @@ func (df *DiffFormatter) FormatHunkSeparator() string {
 }
 
 func (df *DiffFormatter) FormatFileHunks(oldPath, newPath string, hunks []DiffHunk) string {
-	var buf strings.Builder
-	buf.WriteString(df.FormatDiffHeader(oldPath, newPath))
+	return df.FormatDiffHeader(oldPath, newPath) + df.FormatHunks(hunks)
+}
 
+// FormatHunks renders hunks without a file header
+func (df *DiffFormatter) FormatHunks(hunks []DiffHunk) string {
+	var buf strings.Builder
 	for i, hunk := range hunks {
 		if i > 0 {
 			buf.WriteString(df.FormatHunkSeparator())
@@ -111,6 +184,55 @@ func (df *DiffFormatter) FormatFileHunks(oldPath, newPath string, hunks []DiffHu
 	return buf.String()
 }
 
+// FormatCombinedDiff renders a "diff --cc" of one path, whose hunk headers
+// carry a range per parent between one more '@' than there are parents
+func (df *DiffFormatter) FormatCombinedDiff(path string, hunks []CombinedDiffHunk) string {
+	var buf strings.Builder
+	buf.WriteString(df.Apply(DiffHeaderStyle, fmt.Sprintf("diff --cc %s", path)))
+	buf.WriteString("\n")
+	buf.WriteString(df.Apply(DiffRemovedStyle, fmt.Sprintf("--- a/%s", path)))
+	buf.WriteString("\n")
+	buf.WriteString(df.Apply(DiffAddedStyle, fmt.Sprintf("+++ b/%s", path)))
+	buf.WriteString("\n")
+
+	for i, hunk := range hunks {
+		if i > 0 {
+			buf.WriteString(df.FormatHunkSeparator())
+			buf.WriteString("\n")
+		}
+		buf.WriteString(df.FormatCombinedHunkHeader(hunk))
+		buf.WriteString("\n")
+		for _, line := range hunk.Lines {
+			buf.WriteString(df.FormatCombinedDiffLine(line))
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.String()
+}
+
+func (df *DiffFormatter) FormatCombinedHunkHeader(hunk CombinedDiffHunk) string {
+	at := strings.Repeat("@", len(hunk.OldStarts)+1)
+	var buf strings.Builder
+	buf.WriteString(at)
+	for i := range hunk.OldStarts {
+		buf.WriteString(fmt.Sprintf(" -%d,%d", hunk.OldStarts[i], hunk.OldCounts[i]))
+	}
+	buf.WriteString(fmt.Sprintf(" +%d,%d %s", hunk.NewStart, hunk.NewCount, at))
+	return df.Apply(DiffHeaderStyle, buf.String())
+}
+
+func (df *DiffFormatter) FormatCombinedDiffLine(line CombinedDiffLine) string {
+	style := DiffContextStyle
+	switch {
+	case strings.Contains(line.Marks, "-"):
+		style = DiffRemovedStyle
+	case strings.Contains(line.Marks, "+"):
+		style = DiffAddedStyle
+	}
+	return df.Apply(style, line.Marks+line.Content)
+}
+
 func (df *DiffFormatter) FormatNewFile(path string) string {
 	return df.Apply(AddedStyle, fmt.Sprintf("new file: %s", path))
 }
@@ -167,6 +289,75 @@ func (df *DiffFormatter) FormatDiffSummary(filesChanged, insertions, deletions i
 	return strings.Join(parts, ", ")
 }
 
+// FormatDiffStat renders the git --stat summary: a " path | N ++--" line per
+// file, aligned, followed by the totals line
+func (df *DiffFormatter) FormatDiffStat(stats []DiffStat) string {
+	if len(stats) == 0 {
+		return ""
+	}
+
+	pathWidth, countWidth, maxChanges := 0, 0, 0
+	for _, stat := range stats {
+		pathWidth = max(pathWidth, len(stat.Path))
+		if stat.Binary {
+			countWidth = max(countWidth, len("Bin"))
+			continue
+		}
+		changes := stat.Added + stat.Removed
+		maxChanges = max(maxChanges, changes)
+		countWidth = max(countWidth, len(strconv.Itoa(changes)))
+	}
+
+	var buf strings.Builder
+	insertions, deletions := 0, 0
+	for _, stat := range stats {
+		buf.WriteString(" ")
+		buf.WriteString(df.Path(stat.Path))
+		buf.WriteString(strings.Repeat(" ", pathWidth-len(stat.Path)))
+		buf.WriteString(" | ")
+
+		if stat.Binary {
+			buf.WriteString(fmt.Sprintf("%*s\n", countWidth, "Bin"))
+			continue
+		}
+
+		changes := stat.Added + stat.Removed
+		insertions += stat.Added
+		deletions += stat.Removed
+		buf.WriteString(fmt.Sprintf("%*d", countWidth, changes))
+
+		if changes > 0 {
+			added, removed := statBar(stat.Added, stat.Removed, maxChanges)
+			buf.WriteString(" ")
+			buf.WriteString(df.Apply(DiffAddedStyle, strings.Repeat("+", added)))
+			buf.WriteString(df.Apply(DiffRemovedStyle, strings.Repeat("-", removed)))
+		}
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString(" ")
+	buf.WriteString(df.FormatDiffSummary(len(stats), insertions, deletions))
+	buf.WriteString("\n")
+
+	return buf.String()
+}
+
+// statBar sizes the +/- bar of one file, scaling it so the file with the
+// most changes fits in maxStatBarWidth while any change stays visible
+func statBar(added, removed, maxChanges int) (int, int) {
+	if maxChanges <= maxStatBarWidth {
+		return added, removed
+	}
+
+	scale := func(n int) int {
+		if n == 0 {
+			return 0
+		}
+		return max(1, n*maxStatBarWidth/maxChanges)
+	}
+	return scale(added), scale(removed)
+}
+
 func (df *DiffFormatter) FormatCompactDiff(path string, additions, deletions int) string {
 	var buf strings.Builder
 	buf.WriteString(df.Path(path))
@@ -207,6 +398,17 @@ func FormatHunkSeparator() string     { return defaultDiffFormatter.FormatHunkSe
 func FormatFileHunks(oldPath, newPath string, hunks []DiffHunk) string {
 	return defaultDiffFormatter.FormatFileHunks(oldPath, newPath, hunks)
 }
+func FormatNewFileHeader(path, mode string) string {
+	return defaultDiffFormatter.FormatNewFileHeader(path, mode)
+}
+func FormatDeletedFileHeader(path, mode string) string {
+	return defaultDiffFormatter.FormatDeletedFileHeader(path, mode)
+}
+func FormatLines(lines []DiffLine) string { return defaultDiffFormatter.FormatLines(lines) }
+func FormatHunks(hunks []DiffHunk) string { return defaultDiffFormatter.FormatHunks(hunks) }
+func FormatCombinedDiff(path string, hunks []CombinedDiffHunk) string {
+	return defaultDiffFormatter.FormatCombinedDiff(path, hunks)
+}
 func FormatNewFile(path string) string      { return defaultDiffFormatter.FormatNewFile(path) }
 func FormatDeletedFile(path string) string  { return defaultDiffFormatter.FormatDeletedFile(path) }
 func FormatModifiedFile(path string) string { return defaultDiffFormatter.FormatModifiedFile(path) }
@@ -221,6 +423,9 @@ func FormatNoNewlineWarning() string      { return defaultDiffFormatter.FormatNo
 func FormatDiffSummary(filesChanged, insertions, deletions int) string {
 	return defaultDiffFormatter.FormatDiffSummary(filesChanged, insertions, deletions)
 }
+func FormatDiffStat(stats []DiffStat) string {
+	return defaultDiffFormatter.FormatDiffStat(stats)
+}
 func FormatCompactDiff(path string, additions, deletions int) string {
 	return defaultDiffFormatter.FormatCompactDiff(path, additions, deletions)
 }
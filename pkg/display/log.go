@@ -2,6 +2,7 @@ package display
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -33,6 +34,20 @@ type LogOptions struct {
 	Until      *time.Time
 }
 
+// GraphEdge carries a lane of a log graph from column From on one row to
+// column To on the next
+type GraphEdge struct {
+	From int
+	To   int
+}
+
+// GraphRow is one line of a log graph. Node is the column of the commit on
+// this line, or -1 when the line only routes lanes.
+type GraphRow struct {
+	Node  int
+	Edges []GraphEdge
+}
+
 type LogFormatter struct {
 	*Formatter
 }
@@ -142,6 +157,50 @@ func (lf *LogFormatter) FormatLogGraph(entries []LogEntry, options LogOptions) s
 	return buf.String()
 }
 
+// FormatGraphRow draws a graph row the way git log --graph does, padded to
+// width columns: * for the commit, | for lanes running straight down, / and \
+// for lanes moving one column left or right, and _ under the columns a lane
+// crosses on its way further left
+func (lf *LogFormatter) FormatGraphRow(row GraphRow, width int) string {
+	cells := make([]byte, 2*width)
+	for i := range cells {
+		cells[i] = ' '
+	}
+	set := func(i int, c byte) {
+		for i >= len(cells) {
+			cells = append(cells, ' ', ' ')
+		}
+		cells[i] = c
+	}
+
+	for _, edge := range row.Edges {
+		switch {
+		case edge.To == edge.From:
+			set(2*edge.From, '|')
+		case edge.To > edge.From:
+			set(2*edge.From+1, '\\')
+		default:
+			set(2*edge.From-1, '/')
+			for col := edge.To + 1; col < edge.From; col++ {
+				if cells[2*col-1] == ' ' {
+					set(2*col-1, '_')
+				}
+			}
+		}
+	}
+	if row.Node >= 0 {
+		set(2*row.Node, '*')
+	}
+
+	line := string(cells)
+	if row.Node < 0 {
+		return lf.Apply(SecondaryStyle, line)
+	}
+	return lf.Apply(SecondaryStyle, line[:2*row.Node]) +
+		lf.Apply(SuccessStyle, "*") +
+		lf.Apply(SecondaryStyle, line[2*row.Node+1:])
+}
+
 func (lf *LogFormatter) FormatLogStats(totalCommits int, authors map[string]int, dateRange string) string {
 	var buf strings.Builder
 
@@ -193,6 +252,39 @@ func (lf *LogFormatter) FormatShortLog(entries []LogEntry, maxWidth int) string
 	return buf.String()
 }
 
+// FormatShortlogGroups renders commit subjects grouped by author as git
+// shortlog does, authors sorted by name or, when numbered, by commit count.
+// With summary only each author's count is shown.
+func (lf *LogFormatter) FormatShortlogGroups(groups map[string][]string, summary, numbered bool) string {
+	authors := make([]string, 0, len(groups))
+	for author := range groups {
+		authors = append(authors, author)
+	}
+	sort.Strings(authors)
+	if numbered {
+		sort.SliceStable(authors, func(i, j int) bool {
+			return len(groups[authors[i]]) > len(groups[authors[j]])
+		})
+	}
+
+	var buf strings.Builder
+	for _, author := range authors {
+		subjects := groups[author]
+		if summary {
+			buf.WriteString(fmt.Sprintf("%6d\t%s\n", len(subjects), author))
+			continue
+		}
+
+		buf.WriteString(fmt.Sprintf("%s (%d):\n", author, len(subjects)))
+		for _, subject := range subjects {
+			buf.WriteString(fmt.Sprintf("      %s\n", subject))
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.String()
+}
+
 func (lf *LogFormatter) FormatBranchLog(branch string, entries []LogEntry, options LogOptions) string {
 	var buf strings.Builder
 
@@ -266,12 +358,18 @@ func FormatLogEntry(entry LogEntry, options LogOptions) string {
 func FormatLogGraph(entries []LogEntry, options LogOptions) string {
 	return defaultLogFormatter.FormatLogGraph(entries, options)
 }
+func FormatGraphRow(row GraphRow, width int) string {
+	return defaultLogFormatter.FormatGraphRow(row, width)
+}
 func FormatLogStats(totalCommits int, authors map[string]int, dateRange string) string {
 	return defaultLogFormatter.FormatLogStats(totalCommits, authors, dateRange)
 }
 func FormatShortLog(entries []LogEntry, maxWidth int) string {
 	return defaultLogFormatter.FormatShortLog(entries, maxWidth)
 }
+func FormatShortlogGroups(groups map[string][]string, summary, numbered bool) string {
+	return defaultLogFormatter.FormatShortlogGroups(groups, summary, numbered)
+}
 func FormatBranchLog(branch string, entries []LogEntry, options LogOptions) string {
 	return defaultLogFormatter.FormatBranchLog(branch, entries, options)
 }
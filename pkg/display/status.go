@@ -14,6 +14,7 @@ const (
 	FileStatusDeleted
 	FileStatusRenamed
 	FileStatusUnmodified
+	FileStatusUnmerged
 )
 
 type StatusEntry struct {
@@ -42,6 +43,8 @@ func (sf *StatusFormatter) FormatFileStatus(status FileStatus) string {
 		return sf.Apply(DeletedStyle, "D ")
 	case FileStatusRenamed:
 		return sf.Apply(RenamedStyle, "R ")
+	case FileStatusUnmerged:
+		return sf.Apply(ErrorStyle, "U ")
 	default:
 		return "  "
 	}
@@ -59,6 +62,10 @@ func (sf *StatusFormatter) FormatBranchHeader(branch string, isInitial bool) str
 	return buf.String()
 }
 
+func (sf *StatusFormatter) FormatDetachedHeader(hash string) string {
+	return sf.Apply(UnstagedStyle, "HEAD detached at ") + sf.Hash(hash)
+}
+
 func (sf *StatusFormatter) FormatStagedSection(entries []StatusEntry) string {
 	if len(entries) == 0 {
 		return ""
@@ -97,6 +104,24 @@ func (sf *StatusFormatter) FormatUnstagedSection(entries []StatusEntry) string {
 	return buf.String()
 }
 
+func (sf *StatusFormatter) FormatUnmergedSection(entries []StatusEntry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("\n")
+	buf.WriteString(sf.Apply(UnstagedStyle, "Unmerged paths:"))
+	buf.WriteString("\n")
+	buf.WriteString(sf.Hint("  (use \"git add <file>...\" to mark resolution)"))
+	buf.WriteString("\n\n")
+	for _, entry := range entries {
+		buf.WriteString(fmt.Sprintf("  %s %s\n",
+			sf.FormatFileStatus(FileStatusUnmerged),
+			sf.Path(entry.Path)))
+	}
+	return buf.String()
+}
+
 func (sf *StatusFormatter) FormatUntrackedSection(entries []StatusEntry) string {
 	if len(entries) == 0 {
 		return ""
@@ -117,12 +142,22 @@ func (sf *StatusFormatter) FormatCleanMessage() string {
 	return sf.Apply(SuccessStyle, "nothing to commit, working tree clean")
 }
 
-func (sf *StatusFormatter) FormatStatusResult(branch string, entries []StatusEntry, isInitial bool) string {
+// FormatStatusResult formats a status; when detached, branch holds the
+// abbreviated hash HEAD is detached at
+func (sf *StatusFormatter) FormatStatusResult(branch string, detached bool, entries []StatusEntry, isInitial bool) string {
 	var buf strings.Builder
-	buf.WriteString(sf.FormatBranchHeader(branch, isInitial))
+	if detached {
+		buf.WriteString(sf.FormatDetachedHeader(branch))
+	} else {
+		buf.WriteString(sf.FormatBranchHeader(branch, isInitial))
+	}
 
-	var staged, unstaged, untracked []StatusEntry
+	var staged, unstaged, unmerged, untracked []StatusEntry
 	for _, entry := range entries {
+		if entry.IndexStatus == FileStatusUnmerged {
+			unmerged = append(unmerged, entry)
+			continue
+		}
 		if entry.IndexStatus != FileStatusUnmodified {
 			staged = append(staged, entry)
 		}
@@ -134,10 +169,11 @@ func (sf *StatusFormatter) FormatStatusResult(branch string, entries []StatusEnt
 	}
 
 	buf.WriteString(sf.FormatStagedSection(staged))
+	buf.WriteString(sf.FormatUnmergedSection(unmerged))
 	buf.WriteString(sf.FormatUnstagedSection(unstaged))
 	buf.WriteString(sf.FormatUntrackedSection(untracked))
 
-	if len(staged) == 0 && len(unstaged) == 0 && len(untracked) == 0 {
+	if len(staged) == 0 && len(unstaged) == 0 && len(unmerged) == 0 && len(untracked) == 0 {
 		buf.WriteString("\n")
 		buf.WriteString(sf.FormatCleanMessage())
 	}
@@ -154,16 +190,22 @@ func FormatFileStatus(status FileStatus) string {
 func FormatBranchHeader(branch string, isInitial bool) string {
 	return defaultStatusFormatter.FormatBranchHeader(branch, isInitial)
 }
+func FormatDetachedHeader(hash string) string {
+	return defaultStatusFormatter.FormatDetachedHeader(hash)
+}
 func FormatStagedSection(entries []StatusEntry) string {
 	return defaultStatusFormatter.FormatStagedSection(entries)
 }
 func FormatUnstagedSection(entries []StatusEntry) string {
 	return defaultStatusFormatter.FormatUnstagedSection(entries)
 }
+func FormatUnmergedSection(entries []StatusEntry) string {
+	return defaultStatusFormatter.FormatUnmergedSection(entries)
+}
 func FormatUntrackedSection(entries []StatusEntry) string {
 	return defaultStatusFormatter.FormatUntrackedSection(entries)
 }
 func FormatCleanMessage() string { return defaultStatusFormatter.FormatCleanMessage() }
-func FormatStatusResult(branch string, entries []StatusEntry, isInitial bool) string {
-	return defaultStatusFormatter.FormatStatusResult(branch, entries, isInitial)
+func FormatStatusResult(branch string, detached bool, entries []StatusEntry, isInitial bool) string {
+	return defaultStatusFormatter.FormatStatusResult(branch, detached, entries, isInitial)
 }
@@ -20,6 +20,8 @@ var (
 	ErrNothingToCommit      = stderrors.New("nothing to commit")
 	ErrInvalidReference     = stderrors.New("invalid reference")
 	ErrReferenceNotFound    = stderrors.New("reference not found")
+	ErrReferenceLocked      = stderrors.New("reference is locked")
+	ErrReferenceChanged     = stderrors.New("reference changed")
 	ErrCorruptedRepository  = stderrors.New("corrupted repository")
 	ErrInvalidObjectFormat  = stderrors.New("invalid object format")
 	ErrPermissionDenied     = stderrors.New("permission denied")
@@ -34,6 +36,26 @@ var (
 	ErrMergeConflict        = stderrors.New("merge conflict")
 	ErrInvalidURL           = stderrors.New("invalid URL")
 	ErrUnsupportedProtocol  = stderrors.New("unsupported protocol")
+	ErrBranchAlreadyExists  = stderrors.New("branch already exists")
+	ErrBranchNotFound       = stderrors.New("branch not found")
+	ErrBranchNotMerged      = stderrors.New("branch not fully merged")
+	ErrInvalidBranchName    = stderrors.New("invalid branch name")
+	ErrLocalChanges         = stderrors.New("local changes would be overwritten")
+	ErrAmbiguousHash        = stderrors.New("short object hash is ambiguous")
+	ErrHookFailed           = stderrors.New("hook failed")
+	ErrUnsignedCommit       = stderrors.New("commit is not signed")
+	ErrBadSignature         = stderrors.New("bad signature")
+	ErrInvalidConfig        = stderrors.New("invalid config")
+	ErrNotACommit           = stderrors.New("object is not a commit")
+	ErrNotATree             = stderrors.New("object is not a tree")
+	ErrNotABlob             = stderrors.New("object is not a blob")
+	ErrHashMismatch         = stderrors.New("object hash mismatch")
+	ErrUntrackedOverwritten = stderrors.New("untracked working tree files would be overwritten")
+	ErrRemoteError          = stderrors.New("remote error")
+	ErrInvalidAdvertisement = stderrors.New("invalid ref advertisement")
+	ErrUnknownHostKey       = stderrors.New("unknown host key")
+	ErrHostKeyChanged       = stderrors.New("host key changed")
+	ErrInvalidRefspec       = stderrors.New("invalid refspec")
 )
 
 type GitError struct {